@@ -23,10 +23,11 @@ func (p *noopProxy) Write(data []byte) (int, error) { return len(data), nil }
 func (p *noopProxy) Done() <-chan struct{}          { return nil }
 func (p *noopProxy) Close() error                   { return nil }
 
-func (c *core) MySQL() (Proxy, error)    { return &noopProxy{connectionType: "mysql"}, nil }
-func (c *core) MSSQL() (Proxy, error)    { return &noopProxy{connectionType: "mssql"}, nil }
+// the protocols returning a nil proxy and a nil error are implemented natively by the agent
+func (c *core) MySQL() (Proxy, error)    { return nil, nil }
+func (c *core) MSSQL() (Proxy, error)    { return nil, nil }
 func (c *core) MongoDB() (Proxy, error)  { return &noopProxy{connectionType: "mongodb"}, nil }
-func (c *core) Postgres() (Proxy, error) { return &noopProxy{connectionType: "postgres"}, nil }
+func (c *core) Postgres() (Proxy, error) { return nil, nil }
 
 func NewAdHocExec(rawEnvVarList map[string]any, args []string, payload []byte, stdout, stderr io.WriteCloser, opts map[string]string) (Proxy, error) {
 	return &noopProxy{connectionType: "terminal-exec"}, nil
@@ -6,13 +6,16 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 	"github.com/hoophq/hoop/agent/config"
+	"github.com/hoophq/hoop/agent/dbproxy"
 	"github.com/hoophq/hoop/agent/secretsmanager"
 	term "github.com/hoophq/hoop/agent/terminal"
 	"github.com/hoophq/hoop/common/log"
@@ -43,7 +46,22 @@ type (
 		insecure         bool
 		options          string
 		postgresSSLMode  string
-		connectionString string
+		postgresPoolMode string
+		postgresPoolSize string
+		// the PEM encoded root certificates to verify the server in the verify sslmodes
+		postgresSSLRootCert string
+		// provisions a database user per session, the credentials
+		// of the connection are used as admin to manage the users
+		ephemeralUser       bool
+		ephemeralUserGrants string
+		ephemeralUserTTL    string
+		oracleSID           string
+		sshPrivateKey       string
+		sshPassphrase       string
+		sshHostKey          string
+		httpRemoteURL       string
+		httpHeaders         http.Header
+		connectionString    string
 	}
 )
 
@@ -123,10 +141,26 @@ func (a *Agent) Run() error {
 		case pbagent.MSSQLConnectionWrite:
 			a.processMSSQLProtocol(pkt)
 
+		// OracleDB Protocol
+		case pbagent.OracleDBConnectionWrite:
+			a.processOracleDBProtocol(pkt)
+
+		// Redis Protocol
+		case pbagent.RedisConnectionWrite:
+			a.processRedisProtocol(pkt)
+
 		// MongoDB Protocol
 		case pbagent.MongoDBConnectionWrite:
 			a.processMongoDBProtocol(pkt)
 
+		// SSH Protocol
+		case pbagent.SSHConnectionWrite:
+			a.processSSHProtocol(pkt)
+
+		// HTTP Protocol
+		case pbagent.HTTPConnectionWrite:
+			a.processHTTPProtocol(pkt)
+
 		// raw tcp
 		case pbagent.TCPConnectionWrite:
 			a.processTCPWriteServer(pkt)
@@ -154,6 +188,7 @@ func (a *Agent) processSessionOpen(pkt *pb.Packet) {
 	connParams, err := a.buildConnectionParams(pkt)
 	if err != nil {
 		log.Warnf("failed building connection params, err=%v", err)
+		secretsmanager.CloseSession(sessionIDKey)
 		_ = a.client.Send(&pb.Packet{
 			Type:    pbclient.SessionClose,
 			Payload: []byte(err.Error()),
@@ -221,6 +256,10 @@ func (a *Agent) processSessionOpen(pkt *pb.Packet) {
 					pb.SpecGatewaySessionID:  sessionID,
 				},
 			})
+		} else if err := a.setupEphemeralUser(sessionIDKey, connParams); err != nil {
+			log.Warnf("session=%v - %v", sessionIDKey, err)
+			a.sendClientSessionClose(sessionIDKey, err.Error(), fmt.Sprintf("%s=1", pb.SpecClientExitCodeKey))
+			return
 		}
 		a.connStore.Set(string(sessionID), connParams)
 		_ = a.client.Send(&pb.Packet{
@@ -263,6 +302,14 @@ func (a *Agent) processSessionClose(pkt *pb.Packet) {
 
 func (a *Agent) sessionCleanup(sessionID string) {
 	log.Infof("session=%s - cleaning up session", sessionID)
+	// kill the kubernetes port-forward process, the close waits for a process being started
+	if sf, ok := a.connStore.Pop(fmt.Sprintf(portForwardStoreKey, sessionID)).(*kubeSessionForward); ok {
+		go func() {
+			if err := sf.Close(); err != nil {
+				log.Warnf("session=%s - failed killing kubernetes port-forward, err=%v", sessionID, err)
+			}
+		}()
+	}
 	filterFn := func(k string) bool { return strings.Contains(k, sessionID) }
 	for key, obj := range a.connStore.Filter(filterFn) {
 		if v, ok := obj.(io.Closer); ok {
@@ -274,6 +321,18 @@ func (a *Agent) sessionCleanup(sessionID string) {
 			a.connStore.Del(key)
 		}
 	}
+	go secretsmanager.CloseSession(sessionID)
+}
+
+// onProxyError closes the session of the client when the proxy fails. When the server
+// rejects the credentials, the cached secrets of the session are invalidated.
+func (a *Agent) onProxyError(sessionID string) func(exitCode int, errMsg string) {
+	return func(exitCode int, errMsg string) {
+		if exitCode == dbproxy.ExitCodeAuthFailed {
+			secretsmanager.InvalidateSession(sessionID)
+		}
+		a.sendClientSessionClose(sessionID, errMsg)
+	}
 }
 
 func (a *Agent) sendClientSessionClose(sessionID string, errMsg string, specKeyVal ...string) {
@@ -357,6 +416,8 @@ func (a *Agent) buildConnectionParams(pkt *pb.Packet) (*pb.AgentConnectionParams
 			connParams.EnvVars["envvar:MYSQL_PWD"] = b64EncPaswd
 		case pb.ConnectionTypeMSSQL:
 			connParams.EnvVars["envvar:SQLCMDPASSWORD"] = b64EncPaswd
+		case pb.ConnectionTypeRedis:
+			connParams.EnvVars["envvar:REDISCLI_AUTH"] = b64EncPaswd
 		}
 	}
 	return connParams, nil
@@ -369,6 +430,10 @@ func (a *Agent) checkTCPLiveness(pkt *pb.Packet, envVars map[string]any) error {
 		connType == pb.ConnectionTypeTCP ||
 		connType == pb.ConnectionTypeMySQL ||
 		connType == pb.ConnectionTypeMSSQL ||
+		connType == pb.ConnectionTypeOracleDB ||
+		connType == pb.ConnectionTypeRedis ||
+		connType == pb.ConnectionTypeSSH ||
+		connType == pb.ConnectionTypeHTTP ||
 		connType == pb.ConnectionTypeMongoDB {
 		connEnvVars, err := parseConnectionEnvVars(envVars, connType)
 		if err != nil {
@@ -401,7 +466,7 @@ func (a *Agent) decodeConnectionParams(sessionID []byte, pkt *pb.Packet) *pb.Age
 		})
 		return nil
 	}
-	envVars, err := secretsmanager.Decode(connParams.EnvVars)
+	envVars, err := secretsmanager.Decode(string(sessionID), connParams.EnvVars)
 	if err != nil {
 		errMsg := fmt.Sprintf("failed decoding environment variables %v", err)
 		log.With("sid", string(sessionID)).Warn(errMsg)
@@ -497,15 +562,26 @@ func parseConnectionEnvVars(envVars map[string]any, connType pb.ConnectionType)
 	}
 
 	env := &connEnv{
-		scheme:          envVarS.Getenv("SCHEME"),
-		host:            envVarS.Getenv("HOST"),
-		user:            envVarS.Getenv("USER"),
-		pass:            envVarS.Getenv("PASS"),
-		port:            envVarS.Getenv("PORT"),
-		dbname:          envVarS.Getenv("DB"),
-		insecure:        envVarS.Getenv("INSECURE") == "true",
-		postgresSSLMode: envVarS.Getenv("SSLMODE"),
-		options:         envVarS.Getenv("OPTIONS"),
+		scheme:              envVarS.Getenv("SCHEME"),
+		host:                envVarS.Getenv("HOST"),
+		user:                envVarS.Getenv("USER"),
+		pass:                envVarS.Getenv("PASS"),
+		port:                envVarS.Getenv("PORT"),
+		dbname:              envVarS.Getenv("DB"),
+		insecure:            envVarS.Getenv("INSECURE") == "true",
+		postgresSSLMode:     envVarS.Getenv("SSLMODE"),
+		postgresSSLRootCert: envVarS.Getenv("SSLROOTCERT"),
+		postgresPoolMode:    envVarS.Getenv("POOL_MODE"),
+		postgresPoolSize:    envVarS.Getenv("POOL_SIZE"),
+		ephemeralUser:       envVarS.Getenv("EPHEMERAL_USER") == "true",
+		ephemeralUserGrants: envVarS.Getenv("EPHEMERAL_USER_GRANTS"),
+		ephemeralUserTTL:    envVarS.Getenv("EPHEMERAL_USER_TTL"),
+		oracleSID:           envVarS.Getenv("SID"),
+		sshPrivateKey:       envVarS.Getenv("PRIVATE_KEY"),
+		sshPassphrase:       envVarS.Getenv("PASSPHRASE"),
+		sshHostKey:          envVarS.Getenv("HOST_KEY"),
+		httpRemoteURL:       envVarS.Getenv("REMOTE_URL"),
+		options:             envVarS.Getenv("OPTIONS"),
 		// this option is only used by mongodb at the momento
 		connectionString: envVarS.Getenv("CONNECTION_STRING"),
 	}
@@ -521,9 +597,23 @@ func parseConnectionEnvVars(envVars map[string]any, connType pb.ConnectionType)
 		if mode == "" {
 			mode = "prefer"
 		}
-		if mode != "require" && mode != "verify-full" && mode != "prefer" && mode != "disable" {
-			return nil, fmt.Errorf("wrong option (%q) for SSLMODE, accept only: %v", mode,
-				[]string{"disable", "prefer", "require", "verify-full"})
+		sslModes := []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+		if !slices.Contains(sslModes, mode) {
+			return nil, fmt.Errorf("wrong option (%q) for SSLMODE, accept only: %v", mode, sslModes)
+		}
+		if env.postgresSSLRootCert != "" && mode != "verify-ca" && mode != "verify-full" {
+			return nil, fmt.Errorf("SSLROOTCERT is only used with the verify-ca and verify-full SSLMODE")
+		}
+		if env.postgresPoolMode != "" && env.postgresPoolMode != dbproxy.PGPoolModeSession &&
+			env.postgresPoolMode != dbproxy.PGPoolModeTransaction {
+			return nil, fmt.Errorf("wrong option (%q) for POOL_MODE, accept only: %v", env.postgresPoolMode,
+				[]string{dbproxy.PGPoolModeSession, dbproxy.PGPoolModeTransaction})
+		}
+		if env.ephemeralUser && env.postgresPoolMode != "" {
+			return nil, fmt.Errorf("EPHEMERAL_USER is not supported with the %v POOL_MODE", env.postgresPoolMode)
+		}
+		if err := validateEphemeralUserEnv(env); err != nil {
+			return nil, err
 		}
 	case pb.ConnectionTypeMySQL:
 		if env.port == "" {
@@ -532,6 +622,9 @@ func parseConnectionEnvVars(envVars map[string]any, connType pb.ConnectionType)
 		if env.host == "" || env.pass == "" || env.user == "" {
 			return nil, fmt.Errorf("missing required secrets for mysql connection [HOST, USER, PASS]")
 		}
+		if err := validateEphemeralUserEnv(env); err != nil {
+			return nil, err
+		}
 	case pb.ConnectionTypeMSSQL:
 		if env.port == "" {
 			env.port = "1433"
@@ -539,6 +632,47 @@ func parseConnectionEnvVars(envVars map[string]any, connType pb.ConnectionType)
 		if env.host == "" || env.pass == "" || env.user == "" {
 			return nil, fmt.Errorf("missing required secrets for mssql connection [HOST, USER, PASS]")
 		}
+	case pb.ConnectionTypeOracleDB:
+		if env.port == "" {
+			env.port = "1521"
+		}
+		if env.host == "" || env.pass == "" || env.user == "" {
+			return nil, fmt.Errorf("missing required secrets for oracledb connection [HOST, USER, PASS]")
+		}
+	case pb.ConnectionTypeRedis:
+		if env.port == "" {
+			env.port = "6379"
+		}
+		if env.host == "" {
+			return nil, fmt.Errorf("missing required secrets for redis connection [HOST]")
+		}
+	case pb.ConnectionTypeSSH:
+		if env.port == "" {
+			env.port = "22"
+		}
+		if env.host == "" || env.user == "" || (env.pass == "" && env.sshPrivateKey == "") {
+			return nil, fmt.Errorf("missing required secrets for ssh connection [HOST, USER, PASS or PRIVATE_KEY]")
+		}
+	case pb.ConnectionTypeHTTP:
+		if env.httpRemoteURL == "" {
+			return nil, fmt.Errorf("missing required secrets for http connection [REMOTE_URL]")
+		}
+		remoteURL, err := url.Parse(env.httpRemoteURL)
+		if err != nil || remoteURL.Host == "" {
+			return nil, fmt.Errorf("failed parsing REMOTE_URL of http connection, expected scheme://host[:port][/path]")
+		}
+		env.host, env.port = remoteURL.Hostname(), remoteURL.Port()
+		if env.port == "" {
+			env.port = "80"
+			if remoteURL.Scheme == "https" {
+				env.port = "443"
+			}
+		}
+		env.httpHeaders = parseHTTPHeaders(envVars, envVarS)
+		if env.user != "" && env.httpHeaders.Get("Authorization") == "" {
+			auth := base64.StdEncoding.EncodeToString([]byte(env.user + ":" + env.pass))
+			env.httpHeaders.Set("Authorization", "Basic "+auth)
+		}
 	case pb.ConnectionTypeMongoDB:
 		if env.connectionString != "" {
 			connStr, err := connstring.ParseAndValidate(env.connectionString)
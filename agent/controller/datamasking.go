@@ -0,0 +1,222 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hoophq/hoop/common/dlp"
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/common/pgtypes"
+	pb "github.com/hoophq/hoop/common/proto"
+	"github.com/hoophq/hoop/common/proto/spectypes"
+	_ "github.com/lib/pq"
+)
+
+// parseColumnPolicies returns the column masking policies of the connection, the policies
+// with the hash transformation require the hash key of the connection sent by the gateway.
+func parseColumnPolicies(connParams *pb.AgentConnectionParams) ([]*dlp.ColumnPolicy, error) {
+	policies, err := dlp.ParseColumnPolicies(connParams.DataMaskingPolicies)
+	if err != nil {
+		return nil, err
+	}
+	if len(connParams.DataMaskingHashKey) > 0 {
+		return policies, nil
+	}
+	for _, p := range policies {
+		if p.Transformation == dlp.TransformationHash {
+			return nil, fmt.Errorf("unable to apply the column policy %q, the gateway didn't send the hash key, upgrade the gateway", p)
+		}
+	}
+	return policies, nil
+}
+
+// dataMaskingWriter applies the column masking policies to the rows
+// sent by the database before writing them to the client stream.
+type dataMaskingWriter struct {
+	client     pb.ClientTransport
+	packetType pb.PacketType
+	spec       map[string][]byte
+	rows       *dlp.RowRewriter
+	mu         sync.Mutex
+}
+
+func newDataMaskingWriter(client pb.ClientTransport, pktType pb.PacketType, spec map[string][]byte,
+	connType pb.ConnectionType, masker *dlp.ColumnMasker) *dataMaskingWriter {
+	return &dataMaskingWriter{
+		client:     client,
+		packetType: pktType,
+		spec:       spec,
+		rows:       dlp.NewRowRewriter(connType, masker),
+	}
+}
+
+func (w *dataMaskingWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	summary := &dlp.Summary{}
+	payload, err := w.rows.Rewrite(data, summary)
+	if err != nil {
+		// it's not safe to keep writing the rows without the policies applied
+		return 0, fmt.Errorf("failed applying data masking policies, reason=%v", err)
+	}
+	if len(payload) == 0 {
+		return len(data), nil
+	}
+	spec := map[string][]byte{}
+	for key, val := range w.spec {
+		spec[key] = val
+	}
+	if !summary.IsEmpty() {
+		if infoEnc, err := summary.DataMaskingInfo().Encode(); err == nil {
+			spec[spectypes.DataMaskingInfoKey] = infoEnc
+		}
+	}
+	return len(data), w.client.Send(&pb.Packet{
+		Type:    w.packetType.String(),
+		Spec:    spec,
+		Payload: payload,
+	})
+}
+
+// serverWriter returns a writer that inspects the packets sent by the client to the database,
+// the closer is called when the connection is closed.
+func (w *dataMaskingWriter) serverWriter(serverWriter io.WriteCloser, closer io.Closer) io.WriteCloser {
+	return &maskedServerWriter{WriteCloser: serverWriter, masking: w, closer: closer}
+}
+
+type maskedServerWriter struct {
+	io.WriteCloser
+	masking *dataMaskingWriter
+	closer  io.Closer
+}
+
+func (w *maskedServerWriter) Write(data []byte) (int, error) {
+	w.masking.mu.Lock()
+	w.masking.rows.OnClientPacket(data)
+	w.masking.mu.Unlock()
+	return w.WriteCloser.Write(data)
+}
+
+func (w *maskedServerWriter) Close() error {
+	if w.closer != nil {
+		_ = w.closer.Close()
+	}
+	return w.WriteCloser.Close()
+}
+
+// pgTableResolver resolves the names of tables using a distinct connection to the database,
+// postgres informs only the oid of the table and the attribute number of the columns in the rows.
+type pgTableResolver struct {
+	connenv *connEnv
+	dbname  string
+	db      *sql.DB
+	connErr error
+	mu      sync.Mutex
+}
+
+// newPGTableResolver returns a resolver for the database of the startup packet of the client
+func newPGTableResolver(connenv *connEnv, startupPacket []byte) *pgTableResolver {
+	dbname := connenv.dbname
+	if pkt, err := pgtypes.Decode(bytes.NewBuffer(startupPacket)); err == nil {
+		if db := pkt.StartupParameters()["database"]; db != "" {
+			dbname = db
+		}
+	}
+	return &pgTableResolver{connenv: connenv, dbname: dbname}
+}
+
+func (r *pgTableResolver) Resolve(tableOID uint32) (string, map[int16]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.db == nil && r.connErr == nil {
+		if r.db, r.connErr = openPostgresDB(r.connenv, r.dbname); r.connErr != nil {
+			log.Warnf("failed connecting to resolve the tables of data masking policies, reason=%v", r.connErr)
+		}
+	}
+	if r.connErr != nil {
+		return "", nil, r.connErr
+	}
+	ctx, cancelFn := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancelFn()
+	rows, err := r.db.QueryContext(ctx, `
+	SELECT c.relname, a.attnum, a.attname
+	FROM pg_catalog.pg_class c
+	JOIN pg_catalog.pg_attribute a ON a.attrelid = c.oid
+	WHERE c.oid = $1 AND a.attnum > 0 AND NOT a.attisdropped`, tableOID)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+	var table string
+	columns := map[int16]string{}
+	for rows.Next() {
+		var attnum int16
+		var attname string
+		if err := rows.Scan(&table, &attnum, &attname); err != nil {
+			return "", nil, err
+		}
+		columns[attnum] = attname
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, err
+	}
+	if table == "" {
+		return "", nil, fmt.Errorf("table with oid %v not found", tableOID)
+	}
+	return table, columns, nil
+}
+
+// openPostgresDB opens a single connection with the database using the credentials of the connection
+func openPostgresDB(connenv *connEnv, dbname string) (*sql.DB, error) {
+	sslModes := []string{connenv.postgresSSLMode}
+	// the driver doesn't support the allow and prefer modes
+	switch connenv.postgresSSLMode {
+	case "", "prefer":
+		sslModes = []string{"require", "disable"}
+	case "allow":
+		sslModes = []string{"disable", "require"}
+	}
+	var err error
+	for _, sslMode := range sslModes {
+		dsn := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(connenv.user, connenv.pass),
+			Host:     net.JoinHostPort(connenv.host, connenv.port),
+			Path:     "/" + dbname,
+			RawQuery: url.Values{"sslmode": {sslMode}, "connect_timeout": {"10"}}.Encode(),
+		}
+		if connenv.postgresSSLRootCert != "" {
+			query := dsn.Query()
+			query.Set("sslrootcert", connenv.postgresSSLRootCert)
+			query.Set("sslinline", "true")
+			dsn.RawQuery = query.Encode()
+		}
+		var db *sql.DB
+		if db, err = sql.Open("postgres", dsn.String()); err != nil {
+			continue
+		}
+		if err = db.Ping(); err != nil {
+			_ = db.Close()
+			continue
+		}
+		db.SetMaxOpenConns(1)
+		return db, nil
+	}
+	return nil, err
+}
+
+func (r *pgTableResolver) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.db != nil {
+		return r.db.Close()
+	}
+	return nil
+}
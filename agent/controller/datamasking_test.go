@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"testing"
+
+	pb "github.com/hoophq/hoop/common/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColumnPolicies(t *testing.T) {
+	for _, tt := range []struct {
+		msg     string
+		params  *pb.AgentConnectionParams
+		wantLen int
+		wantErr string
+	}{
+		{
+			msg: "it should parse the policies with the hash key",
+			params: &pb.AgentConnectionParams{
+				DataMaskingPolicies: []string{"users.email -> hash", "*.ssn -> redact"},
+				DataMaskingHashKey:  []byte("key"),
+			},
+			wantLen: 2,
+		},
+		{
+			msg:     "it should parse the policies without hash transformations when the key is missing",
+			params:  &pb.AgentConnectionParams{DataMaskingPolicies: []string{"*.ssn -> redact"}},
+			wantLen: 1,
+		},
+		{
+			msg:     "it should fail when the hash key is missing",
+			params:  &pb.AgentConnectionParams{DataMaskingPolicies: []string{"users.email -> hash"}},
+			wantErr: `unable to apply the column policy "users.email -> hash", the gateway didn't send the hash key`,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := parseColumnPolicies(tt.params)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, got, tt.wantLen)
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hoophq/hoop/agent/dbproxy"
+	"github.com/hoophq/hoop/common/log"
+	pb "github.com/hoophq/hoop/common/proto"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultEphemeralUserTTL = time.Hour * 12
+	ephemeralUserTimeout    = time.Second * 30
+	// the max length of mysql user names
+	ephemeralUserMaxLength = 32
+)
+
+var invalidUserCharsRe = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// ephemeralUserTemplateData are the attributes available in the grants template
+// of the connection (EPHEMERAL_USER_GRANTS), e.g.: GRANT readonly TO {{ .User }}
+type ephemeralUserTemplateData struct {
+	// the quoted name of the user, it's the account ('user'@'%') in mysql
+	User     string
+	Database string
+}
+
+// ephemeralUser is a database user provisioned for a single session with the admin
+// credentials of the connection, it's dropped when the session is closed.
+type ephemeralUser struct {
+	sessionID string
+	connType  pb.ConnectionType
+	admin     *connEnv
+	name      string
+	password  string
+}
+
+// newEphemeralUserName returns a name that identifies the user of hoop and the session,
+// e.g.: hoop_john_doe_8c2f1a3b
+func newEphemeralUserName(userEmail, sessionID string) string {
+	localPart, _, _ := strings.Cut(strings.ToLower(userEmail), "@")
+	localPart = strings.Trim(invalidUserCharsRe.ReplaceAllString(localPart, "_"), "_")
+	suffix := strings.ReplaceAll(sessionID, "-", "")
+	if len(suffix) > 8 {
+		suffix = suffix[:8]
+	}
+	// hoop_ + _ + suffix
+	if maxSize := ephemeralUserMaxLength - 6 - len(suffix); len(localPart) > maxSize {
+		localPart = localPart[:maxSize]
+	}
+	if localPart == "" {
+		return "hoop_" + suffix
+	}
+	return fmt.Sprintf("hoop_%s_%s", localPart, suffix)
+}
+
+func newEphemeralUserPassword() (string, error) {
+	data := make([]byte, 24)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// parseEphemeralUserGrants renders the grants template of the connection, the statements
+// of the template are executed as a single query, it could contain many statements separated by ;
+func parseEphemeralUserGrants(tmpl string, data ephemeralUserTemplateData) (string, error) {
+	t, err := template.New("grants").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("failed parsing EPHEMERAL_USER_GRANTS template: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed rendering EPHEMERAL_USER_GRANTS template: %v", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// provisionEphemeralUser creates the user of the session with the grants of the connection
+func provisionEphemeralUser(sessionID, userEmail string, connType pb.ConnectionType, admin *connEnv) (*ephemeralUser, error) {
+	password, err := newEphemeralUserPassword()
+	if err != nil {
+		return nil, fmt.Errorf("failed generating password: %v", err)
+	}
+	u := &ephemeralUser{
+		sessionID: sessionID,
+		connType:  connType,
+		admin:     admin,
+		name:      newEphemeralUserName(userEmail, sessionID),
+		password:  password,
+	}
+	ttl := defaultEphemeralUserTTL
+	if admin.ephemeralUserTTL != "" {
+		// it's validated when parsing the connection
+		ttl, _ = time.ParseDuration(admin.ephemeralUserTTL)
+	}
+	ctx, cancelFn := context.WithTimeout(context.Background(), ephemeralUserTimeout)
+	defer cancelFn()
+	switch connType {
+	case pb.ConnectionTypePostgres:
+		err = u.createPostgres(ctx, userEmail, ttl)
+	case pb.ConnectionTypeMySQL:
+		err = u.createMySQL(ctx, ttl)
+	default:
+		return nil, fmt.Errorf("ephemeral users are not supported for %v connections", connType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("session=%v - ephemeral %v user %v created, ttl=%v", sessionID, connType, u.name, ttl)
+	return u, nil
+}
+
+func (u *ephemeralUser) createPostgres(ctx context.Context, userEmail string, ttl time.Duration) error {
+	grants, err := parseEphemeralUserGrants(u.admin.ephemeralUserGrants,
+		ephemeralUserTemplateData{User: pq.QuoteIdentifier(u.name), Database: pq.QuoteIdentifier(u.postgresDBName())})
+	if err != nil {
+		return err
+	}
+	db, err := u.openPostgres()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed starting transaction: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	statements := []string{
+		fmt.Sprintf("CREATE ROLE %s WITH LOGIN PASSWORD %s VALID UNTIL %s",
+			pq.QuoteIdentifier(u.name), pq.QuoteLiteral(u.password),
+			pq.QuoteLiteral(time.Now().UTC().Add(ttl).Format(time.RFC3339))),
+		fmt.Sprintf("COMMENT ON ROLE %s IS %s", pq.QuoteIdentifier(u.name),
+			pq.QuoteLiteral(fmt.Sprintf("hoop ephemeral user, user=%s, session=%s", userEmail, u.sessionID))),
+	}
+	if grants != "" {
+		statements = append(statements, grants)
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed creating postgres user: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed creating postgres user: %v", err)
+	}
+	return nil
+}
+
+// createMySQL creates the user with an attribute that identifies the users created by hoop and their
+// expiration. The server doesn't remove the users when they expire, they are dropped when the session
+// is closed. The expired users left behind by sessions that were not cleaned up (e.g.: the agent crashed)
+// are dropped when another user is created in the server, the ttl is not enforced between sessions.
+// The attributes of users require MySQL 8.0.21 or later.
+func (u *ephemeralUser) createMySQL(ctx context.Context, ttl time.Duration) error {
+	account := fmt.Sprintf("'%s'@'%%'", u.name)
+	grants, err := parseEphemeralUserGrants(u.admin.ephemeralUserGrants,
+		ephemeralUserTemplateData{User: account, Database: "`" + strings.ReplaceAll(u.admin.dbname, "`", "``") + "`"})
+	if err != nil {
+		return err
+	}
+	if err := dbproxy.MySQLExec(ctx, u.mysqlOptions(), mysqlDropExpiredUsersStatements()...); err != nil {
+		log.Warnf("session=%v - failed dropping expired ephemeral mysql users: %v", u.sessionID, err)
+	}
+	createStmt, err := newMySQLCreateUserStatement(account, u.password, u.sessionID, time.Now().UTC().Add(ttl))
+	if err != nil {
+		return err
+	}
+	statements := []string{createStmt}
+	if grants != "" {
+		statements = append(statements, grants)
+	}
+	if err := dbproxy.MySQLExec(ctx, u.mysqlOptions(), statements...); err != nil {
+		// the user is removed if the grants fail
+		_ = dbproxy.MySQLExec(ctx, u.mysqlOptions(), fmt.Sprintf("DROP USER IF EXISTS %s", account))
+		return fmt.Errorf("failed creating mysql user: %v", err)
+	}
+	return nil
+}
+
+// mysqlEphemeralUserAttribute is the attribute of the users created by hoop,
+// the expiration is formatted as RFC3339 in UTC to be compared as a string.
+type mysqlEphemeralUserAttribute struct {
+	SessionID string `json:"session_id"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// newMySQLCreateUserStatement returns the statement that creates the user
+// with the attribute that identifies it as an ephemeral user of hoop.
+func newMySQLCreateUserStatement(account, password, sessionID string, expiresAt time.Time) (string, error) {
+	attr, err := json.Marshal(map[string]mysqlEphemeralUserAttribute{
+		"hoop_ephemeral_user": {SessionID: sessionID, ExpiresAt: expiresAt.UTC().Format(time.RFC3339)},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed encoding mysql user attribute: %v", err)
+	}
+	attrLiteral := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(string(attr))
+	return fmt.Sprintf("CREATE USER %s IDENTIFIED BY '%s' ATTRIBUTE '%s'", account, password, attrLiteral), nil
+}
+
+// mysqlDropExpiredUsersStatements returns the statements that drop the expired ephemeral users,
+// the users without the attribute of hoop are never dropped. The users are dropped with a single
+// DROP USER statement prepared from the accounts found, it's a no-op when there are no expired users.
+func mysqlDropExpiredUsersStatements() []string {
+	return []string{
+		"SET SESSION group_concat_max_len = 1048576",
+		`SELECT COALESCE(CONCAT('DROP USER IF EXISTS ', GROUP_CONCAT(CONCAT(QUOTE(USER), '@', QUOTE(HOST)))), 'DO 0') ` +
+			`INTO @hoop_expired_users FROM INFORMATION_SCHEMA.USER_ATTRIBUTES ` +
+			`WHERE USER LIKE 'hoop\\_%' AND ` +
+			`JSON_UNQUOTE(JSON_EXTRACT(ATTRIBUTE, '$.hoop_ephemeral_user.expires_at')) < DATE_FORMAT(UTC_TIMESTAMP(), '%Y-%m-%dT%H:%i:%sZ')`,
+		"PREPARE hoop_drop_expired_users FROM @hoop_expired_users",
+		"EXECUTE hoop_drop_expired_users",
+		"DEALLOCATE PREPARE hoop_drop_expired_users",
+	}
+}
+
+// Close drops the user, the objects owned by the user in the database of
+// the connection are reassigned to the admin user.
+func (u *ephemeralUser) Close() error {
+	ctx, cancelFn := context.WithTimeout(context.Background(), ephemeralUserTimeout)
+	defer cancelFn()
+	var err error
+	switch u.connType {
+	case pb.ConnectionTypePostgres:
+		err = u.dropPostgres(ctx)
+	case pb.ConnectionTypeMySQL:
+		err = dbproxy.MySQLExec(ctx, u.mysqlOptions(), fmt.Sprintf("DROP USER IF EXISTS '%s'@'%%'", u.name))
+	}
+	if err != nil {
+		log.Warnf("session=%v - failed dropping ephemeral user %v: %v", u.sessionID, u.name, err)
+		return err
+	}
+	log.Infof("session=%v - ephemeral %v user %v dropped", u.sessionID, u.connType, u.name)
+	return nil
+}
+
+func (u *ephemeralUser) dropPostgres(ctx context.Context) error {
+	db, err := u.openPostgres()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	role := pq.QuoteIdentifier(u.name)
+	statements := []string{
+		// the connections of the session could still be open
+		fmt.Sprintf("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE usename = %s", pq.QuoteLiteral(u.name)),
+		fmt.Sprintf("REASSIGN OWNED BY %s TO CURRENT_USER", role),
+		fmt.Sprintf("DROP OWNED BY %s", role),
+		fmt.Sprintf("DROP ROLE IF EXISTS %s", role),
+	}
+	for _, stmt := range statements {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *ephemeralUser) openPostgres() (*sql.DB, error) {
+	db, err := openPostgresDB(u.admin, u.postgresDBName())
+	if err != nil {
+		return nil, fmt.Errorf("failed connecting with postgres admin user: %v", err)
+	}
+	return db, nil
+}
+
+func (u *ephemeralUser) postgresDBName() string {
+	if u.admin.dbname == "" {
+		return "postgres"
+	}
+	return u.admin.dbname
+}
+
+func (u *ephemeralUser) mysqlOptions() map[string]string {
+	return map[string]string{
+		"hostname": u.admin.host,
+		"port":     u.admin.port,
+		"username": u.admin.user,
+		"password": u.admin.pass,
+	}
+}
+
+func validateEphemeralUserEnv(env *connEnv) error {
+	if !env.ephemeralUser {
+		return nil
+	}
+	if env.ephemeralUserTTL != "" {
+		if ttl, err := time.ParseDuration(env.ephemeralUserTTL); err != nil || ttl <= 0 {
+			return fmt.Errorf("wrong option (%q) for EPHEMERAL_USER_TTL, expected a duration, e.g.: 12h", env.ephemeralUserTTL)
+		}
+	}
+	if _, err := template.New("grants").Parse(env.ephemeralUserGrants); err != nil {
+		return fmt.Errorf("failed parsing EPHEMERAL_USER_GRANTS template: %v", err)
+	}
+	return nil
+}
+
+// setupEphemeralUser provisions the user of the session when the connection requires it, the
+// credentials of the session are replaced by the ones of the user. The user is kept in the
+// store of the session, it's dropped when the session is cleaned up.
+func (a *Agent) setupEphemeralUser(sessionID string, connParams *pb.AgentConnectionParams) error {
+	connType := pb.ConnectionType(connParams.ConnectionType)
+	if connType != pb.ConnectionTypePostgres && connType != pb.ConnectionTypeMySQL {
+		return nil
+	}
+	env, err := parseConnectionEnvVars(connParams.EnvVars, connType)
+	if err != nil || !env.ephemeralUser {
+		return err
+	}
+	u, err := provisionEphemeralUser(sessionID, connParams.UserEmail, connType, env)
+	if err != nil {
+		return fmt.Errorf("failed provisioning ephemeral user: %v", err)
+	}
+	a.connStore.Set(fmt.Sprintf("ephemeral-user:%s", sessionID), u)
+	b64EncPasswd := b64Enc([]byte(u.password))
+	connParams.EnvVars["envvar:USER"] = b64Enc([]byte(u.name))
+	connParams.EnvVars["envvar:PASS"] = b64EncPasswd
+	for _, envKey := range []string{"envvar:PGPASSWORD", "envvar:MYSQL_PWD"} {
+		if _, ok := connParams.EnvVars[envKey]; ok {
+			connParams.EnvVars[envKey] = b64EncPasswd
+		}
+	}
+	return nil
+}
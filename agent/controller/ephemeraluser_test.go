@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEphemeralUserName(t *testing.T) {
+	for _, tt := range []struct {
+		msg       string
+		userEmail string
+		sessionID string
+		want      string
+	}{
+		{
+			msg:       "it should use the local part of the email and the session id",
+			userEmail: "John.Doe@example.com",
+			sessionID: "8c2f1a3b-4d5e-6f70-8192-a3b4c5d6e7f8",
+			want:      "hoop_john_doe_8c2f1a3b",
+		},
+		{
+			msg:       "it should truncate long names to the max length of mysql users",
+			userEmail: "a-very-long-user-name-with-many-parts@example.com",
+			sessionID: "8c2f1a3b-4d5e-6f70-8192-a3b4c5d6e7f8",
+			want:      "hoop_a_very_long_user_n_8c2f1a3b",
+		},
+		{
+			msg:       "it should use only the session id when the email has no valid characters",
+			userEmail: "+++@example.com",
+			sessionID: "8c2f1a3b-4d5e-6f70-8192-a3b4c5d6e7f8",
+			want:      "hoop_8c2f1a3b",
+		},
+		{
+			msg:       "it should accept short session ids",
+			userEmail: "jane@example.com",
+			sessionID: "abc",
+			want:      "hoop_jane_abc",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got := newEphemeralUserName(tt.userEmail, tt.sessionID)
+			assert.Equal(t, tt.want, got)
+			assert.LessOrEqual(t, len(got), ephemeralUserMaxLength)
+		})
+	}
+}
+
+func TestParseEphemeralUserGrants(t *testing.T) {
+	data := ephemeralUserTemplateData{User: `"hoop_jane_abc"`, Database: `"app"`}
+	for _, tt := range []struct {
+		msg     string
+		tmpl    string
+		want    string
+		wantErr string
+	}{
+		{
+			msg:  "it should render the statements",
+			tmpl: "GRANT CONNECT ON DATABASE {{ .Database }} TO {{ .User }};\n GRANT readonly TO {{ .User }};\n",
+			want: "GRANT CONNECT ON DATABASE \"app\" TO \"hoop_jane_abc\";\n GRANT readonly TO \"hoop_jane_abc\";",
+		},
+		{
+			msg:  "it should keep the semicolons of string literals",
+			tmpl: "GRANT readonly TO {{ .User }}; COMMENT ON ROLE {{ .User }} IS 'readonly; app'",
+			want: `GRANT readonly TO "hoop_jane_abc"; COMMENT ON ROLE "hoop_jane_abc" IS 'readonly; app'`,
+		},
+		{
+			msg:  "it should return no statements when the template is empty",
+			tmpl: " \n ",
+		},
+		{
+			msg:     "it should fail with unknown attributes",
+			tmpl:    "GRANT readonly TO {{ .Role }}",
+			wantErr: "failed rendering EPHEMERAL_USER_GRANTS template",
+		},
+		{
+			msg:     "it should fail with invalid templates",
+			tmpl:    "GRANT readonly TO {{ .User }",
+			wantErr: "failed parsing EPHEMERAL_USER_GRANTS template",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := parseEphemeralUserGrants(tt.tmpl, data)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMySQLEphemeralUserStatements(t *testing.T) {
+	expiresAt := time.Date(2026, 10, 18, 12, 30, 0, 0, time.FixedZone("", -3*3600))
+	got, err := newMySQLCreateUserStatement("'hoop_jane_abc'@'%'", "secret", "8c2f1a3b", expiresAt)
+	assert.NoError(t, err)
+	assert.Equal(t, `CREATE USER 'hoop_jane_abc'@'%' IDENTIFIED BY 'secret' ATTRIBUTE `+
+		`'{"hoop_ephemeral_user":{"session_id":"8c2f1a3b","expires_at":"2026-10-18T15:30:00Z"}}'`, got)
+
+	// the attribute is escaped as a string literal
+	got, err = newMySQLCreateUserStatement("'hoop_jane_abc'@'%'", "secret", `it's\`, expiresAt)
+	assert.NoError(t, err)
+	assert.Contains(t, got, `"session_id":"it\'s\\\\"`)
+
+	// only the expired users created by hoop are dropped
+	statements := mysqlDropExpiredUsersStatements()
+	assert.Contains(t, strings.Join(statements, ";"), `FROM INFORMATION_SCHEMA.USER_ATTRIBUTES WHERE USER LIKE 'hoop\\_%' AND `+
+		`JSON_UNQUOTE(JSON_EXTRACT(ATTRIBUTE, '$.hoop_ephemeral_user.expires_at')) < DATE_FORMAT(UTC_TIMESTAMP(), '%Y-%m-%dT%H:%i:%sZ')`)
+	assert.Equal(t, "EXECUTE hoop_drop_expired_users", statements[len(statements)-2])
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hoophq/hoop/agent/httpproxy"
+	term "github.com/hoophq/hoop/agent/terminal"
+	"github.com/hoophq/hoop/common/log"
+	pb "github.com/hoophq/hoop/common/proto"
+	pbclient "github.com/hoophq/hoop/common/proto/client"
+)
+
+// httpHeaderEnvPrefix is the prefix of the environment variables injected as headers
+// of the requests, e.g.: HEADER_X_API_KEY is sent as the header X-Api-Key
+const httpHeaderEnvPrefix = "HEADER_"
+
+func (a *Agent) processHTTPProtocol(pkt *pb.Packet) {
+	sessionID := string(pkt.Spec[pb.SpecGatewaySessionID])
+	streamClient := pb.NewStreamWriter(a.client, pbclient.HTTPConnectionWrite, pkt.Spec)
+	connParams := a.connectionParams(sessionID)
+	if connParams == nil {
+		log.Errorf("session=%s - connection params not found", sessionID)
+		a.sendClientSessionClose(sessionID, "connection params not found, contact the administrator")
+		return
+	}
+
+	clientConnectionID := string(pkt.Spec[pb.SpecClientConnectionID])
+	if clientConnectionID == "" && pkt.Payload != nil {
+		log.Errorf("connection id not found in memory")
+		a.sendClientSessionClose(sessionID, "connection id not found, contact the administrator")
+		return
+	}
+	clientConnectionIDKey := fmt.Sprintf("%s:%s", sessionID, string(clientConnectionID))
+	clientObj := a.connStore.Get(clientConnectionIDKey)
+	if serverWriter, ok := clientObj.(io.WriteCloser); ok {
+		if _, err := serverWriter.Write(pkt.Payload); err != nil {
+			log.Errorf("failed sending packet, err=%v", err)
+			a.sendClientSessionClose(sessionID, "fail to write packet")
+			_ = serverWriter.Close()
+		}
+		return
+	}
+
+	connenv, err := parseConnectionEnvVars(connParams.EnvVars, pb.ConnectionTypeHTTP)
+	if err != nil {
+		log.Error("http credentials not found in memory, err=%v", err)
+		a.sendClientSessionClose(sessionID, "credentials are empty, contact the administrator")
+		return
+	}
+
+	log.Infof("session=%v - starting http connection at %v:%v", sessionID, connenv.host, connenv.port)
+	opts := map[string]string{
+		"remote_url": connenv.httpRemoteURL,
+		"insecure":   fmt.Sprintf("%v", connenv.insecure),
+	}
+	serverWriter, err := httpproxy.NewProxy(context.Background(), streamClient, opts, connenv.httpHeaders)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed starting http proxy, err=%v", err)
+		log.Errorf(errMsg)
+		a.sendClientSessionClose(sessionID, errMsg)
+		return
+	}
+	serverWriter.Run(func(_ int, errMsg string) {
+		a.sendClientSessionClose(sessionID, errMsg)
+	})
+	// write the first packet when establishing the connection
+	_, _ = serverWriter.Write(pkt.Payload)
+	a.connStore.Set(clientConnectionIDKey, serverWriter)
+}
+
+// parseHTTPHeaders returns the headers set with the environment variables of the connection
+func parseHTTPHeaders(envVars map[string]any, envVarS *term.EnvVarStore) http.Header {
+	headers := http.Header{}
+	for key := range envVars {
+		_, envKey, _ := strings.Cut(key, ":")
+		if !strings.HasPrefix(envKey, httpHeaderEnvPrefix) {
+			continue
+		}
+		name := strings.ReplaceAll(strings.TrimPrefix(envKey, httpHeaderEnvPrefix), "_", "-")
+		if name == "" {
+			continue
+		}
+		headers.Set(name, envVarS.Getenv(envKey))
+	}
+	return headers
+}
@@ -0,0 +1,298 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	term "github.com/hoophq/hoop/agent/terminal"
+	"github.com/hoophq/hoop/common/log"
+	pb "github.com/hoophq/hoop/common/proto"
+)
+
+const kubectlTimeout = time.Second * 15
+
+var (
+	portForwardAddrRe = regexp.MustCompile(`Forwarding from (127\.0\.0\.1:[0-9]+)`)
+	// kubeSessionForwardsMu guards the creation of the port-forward of each session
+	kubeSessionForwardsMu sync.Mutex
+)
+
+type (
+	kubeEnv struct {
+		namespace string
+		pod       string
+		selector  string
+		container string
+		service   string
+		port      string
+		// kubeconfig is the credential to access the cluster, when it's empty
+		// kubectl uses the service account of the pod running the agent (in-cluster)
+		kubeconfig *term.EnvVar
+	}
+	kubePortForward struct {
+		cmd    *exec.Cmd
+		addr   string
+		stderr *bytes.Buffer
+		doneC  chan struct{}
+	}
+	// kubeSessionForward is the port-forward process of a session, the connections of the
+	// session wait the process to start and it's killed when the session is cleaned up.
+	kubeSessionForward struct {
+		mu     sync.Mutex
+		pf     *kubePortForward
+		closed bool
+	}
+)
+
+// parseKubernetesEnvVars parses the secrets of a kubernetes connection:
+//
+//	KUBECONFIG - the kubeconfig file (filesystem) to access the cluster, defaults to in-cluster credentials
+//	NAMESPACE - the namespace of the resources, defaults to "default"
+//	POD, SELECTOR - the pod or the label selector of the pods to execute commands
+//	CONTAINER - the container of the pod to execute commands
+//	SERVICE, PORT - the service and port to forward connections
+func parseKubernetesEnvVars(envVars map[string]any) (*kubeEnv, error) {
+	if envVars == nil {
+		return nil, fmt.Errorf("empty env vars")
+	}
+	envVarS, err := term.NewEnvVarStore(envVars)
+	if err != nil {
+		return nil, err
+	}
+	env := &kubeEnv{
+		namespace:  envVarS.Getenv("NAMESPACE"),
+		pod:        envVarS.Getenv("POD"),
+		selector:   envVarS.Getenv("SELECTOR"),
+		container:  envVarS.Getenv("CONTAINER"),
+		service:    envVarS.Getenv("SERVICE"),
+		port:       envVarS.Getenv("PORT"),
+		kubeconfig: envVarS.Get("KUBECONFIG"),
+	}
+	if env.namespace == "" {
+		env.namespace = "default"
+	}
+	return env, nil
+}
+
+// command returns a kubectl command with the credentials of the connection,
+// the cleanup function must be called when the command exits.
+func (e *kubeEnv) command(ctx context.Context, args ...string) (cmd *exec.Cmd, cleanup func(), err error) {
+	cmd = exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Env = os.Environ()
+	cleanup = func() {}
+	if e.kubeconfig != nil {
+		if e.kubeconfig.OnPreExec != nil {
+			if err := e.kubeconfig.OnPreExec(); err != nil {
+				return nil, nil, err
+			}
+			cleanup = func() { _ = e.kubeconfig.OnPostExec() }
+		}
+		cmd.Env = append(cmd.Env, "KUBECONFIG="+string(e.kubeconfig.Val))
+	}
+	return cmd, cleanup, nil
+}
+
+// resolvePod returns the pod of the connection or the first
+// running pod matching the label selector of the connection
+func (e *kubeEnv) resolvePod(ctx context.Context) (string, error) {
+	if e.pod != "" {
+		return e.pod, nil
+	}
+	if e.selector == "" {
+		return "", fmt.Errorf("missing required secrets for kubernetes exec [POD or SELECTOR]")
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, kubectlTimeout)
+	defer cancelFn()
+	cmd, cleanup, err := e.command(ctx, "get", "pods", "-n", e.namespace, "-l", e.selector,
+		"--field-selector=status.phase=Running", "-o", "jsonpath={.items[*].metadata.name}")
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			err = fmt.Errorf("%s", bytes.TrimSpace(exitErr.Stderr))
+		}
+		return "", fmt.Errorf("failed listing pods: %v", err)
+	}
+	pods := strings.Fields(string(out))
+	if len(pods) == 0 {
+		return "", fmt.Errorf("no running pods found in namespace %q with selector %q", e.namespace, e.selector)
+	}
+	return pods[0], nil
+}
+
+// execArgs returns the kubectl command that executes the command in the pod,
+// the input of the session is sent to the stdin of the command.
+func (e *kubeEnv) execArgs(pod string, command []string) []string {
+	args := []string{"kubectl", "exec", "-i", "-n", e.namespace, pod}
+	if e.container != "" {
+		args = append(args, "-c", e.container)
+	}
+	if len(command) == 0 {
+		command = []string{"sh"}
+	}
+	return append(append(args, "--"), command...)
+}
+
+// kubernetesExecArgs returns the kubectl arguments to execute the command
+// and the labels of the session with the namespace and pod of the execution
+func kubernetesExecArgs(envVars map[string]any, command []string) ([]string, map[string][]byte, error) {
+	env, err := parseKubernetesEnvVars(envVars)
+	if err != nil {
+		return nil, nil, err
+	}
+	pod, err := env.resolvePod(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+	labels := map[string][]byte{
+		pb.SpecKubernetesNamespace: []byte(env.namespace),
+		pb.SpecKubernetesPod:       []byte(pod),
+	}
+	return env.execArgs(pod, command), labels, nil
+}
+
+// newKubePortForward starts forwarding a random local port to the service of the connection
+func newKubePortForward(env *kubeEnv) (*kubePortForward, error) {
+	if env.service == "" || env.port == "" {
+		return nil, fmt.Errorf("missing required secrets for kubernetes port-forward [SERVICE, PORT]")
+	}
+	cmd, cleanup, err := env.command(context.Background(), "port-forward", "-n", env.namespace,
+		"--address", "127.0.0.1", "svc/"+env.service, ":"+env.port)
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	pf := &kubePortForward{cmd: cmd, stderr: &bytes.Buffer{}, doneC: make(chan struct{})}
+	cmd.Stderr = pf.stderr
+	if err := cmd.Start(); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed starting port-forward: %v", err)
+	}
+	addrC := make(chan string, 1)
+	go func() {
+		defer close(pf.doneC)
+		defer cleanup()
+		// keep reading the output until the process exits, it logs every forwarded connection
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			if m := portForwardAddrRe.FindStringSubmatch(scanner.Text()); m != nil {
+				select {
+				case addrC <- m[1]:
+				default:
+				}
+			}
+		}
+		err := cmd.Wait()
+		log.Infof("kubernetes port-forward to svc/%s:%s exited, err=%v", env.service, env.port, err)
+	}()
+	select {
+	case pf.addr = <-addrC:
+		return pf, nil
+	case <-pf.doneC:
+		return nil, fmt.Errorf("port-forward exited: %s", bytes.TrimSpace(pf.stderr.Bytes()))
+	case <-time.After(kubectlTimeout):
+		_ = pf.Close()
+		return nil, fmt.Errorf("timeout (%v) waiting for port-forward to start", kubectlTimeout)
+	}
+}
+
+// Exited reports if the port-forward process is not running anymore
+func (f *kubePortForward) Exited() bool {
+	select {
+	case <-f.doneC:
+		return true
+	default:
+		return false
+	}
+}
+
+func (f *kubePortForward) Close() error {
+	if f.Exited() {
+		return nil
+	}
+	if err := f.cmd.Process.Kill(); err != nil {
+		return err
+	}
+	<-f.doneC
+	return nil
+}
+
+// get returns the running port-forward of the session, the process is (re)started when it exited
+func (s *kubeSessionForward) get(start func() (*kubePortForward, error)) (*kubePortForward, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, fmt.Errorf("the session is closed")
+	}
+	if s.pf == nil || s.pf.Exited() {
+		pf, err := start()
+		if err != nil {
+			return nil, err
+		}
+		s.pf = pf
+	}
+	return s.pf, nil
+}
+
+// Close kills the port-forward process, it waits for the process being started
+func (s *kubeSessionForward) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.pf == nil {
+		return nil
+	}
+	return s.pf.Close()
+}
+
+// kubeSessionForward returns the port-forward of the session, it's removed by the session cleanup
+func (a *Agent) kubeSessionForward(sessionID string) *kubeSessionForward {
+	kubeSessionForwardsMu.Lock()
+	defer kubeSessionForwardsMu.Unlock()
+	storeKey := fmt.Sprintf(portForwardStoreKey, sessionID)
+	sf, ok := a.connStore.Get(storeKey).(*kubeSessionForward)
+	if !ok {
+		sf = &kubeSessionForward{}
+		a.connStore.Set(storeKey, sf)
+	}
+	return sf
+}
+
+// dialKubernetesService connects to the service of the connection through a port-forward process.
+// The process is started once per session and it's killed when the session is cleaned up.
+func (a *Agent) dialKubernetesService(sessionID string, connParams *pb.AgentConnectionParams, spec map[string][]byte) (net.Conn, error) {
+	env, err := parseKubernetesEnvVars(connParams.EnvVars)
+	if err != nil {
+		return nil, err
+	}
+	pf, err := a.kubeSessionForward(sessionID).get(func() (*kubePortForward, error) {
+		pf, err := newKubePortForward(env)
+		if err == nil {
+			log.Infof("session=%v - started kubernetes port-forward to svc/%s:%s at %v",
+				sessionID, env.service, env.port, pf.addr)
+		}
+		return pf, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	spec[pb.SpecKubernetesNamespace] = []byte(env.namespace)
+	spec[pb.SpecKubernetesService] = []byte(env.service)
+	return net.DialTimeout("tcp4", pf.addr, time.Second*10)
+}
@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoop/common/memory"
+	pb "github.com/hoophq/hoop/common/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeKubectl installs a kubectl script in the PATH, each execution is
+// appended to the returned file with the pid of the script
+func fakeKubectl(t *testing.T, script string) string {
+	dir := t.TempDir()
+	execLog := filepath.Join(dir, "exec.log")
+	script = "#!/bin/sh\necho \"$$ $@\" >> " + execLog + "\n" + script + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "kubectl"), []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return execLog
+}
+
+func readExecLog(t *testing.T, execLog string) []string {
+	data, err := os.ReadFile(execLog)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestKubernetesPortForwardLifecycle(t *testing.T) {
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	execLog := fakeKubectl(t, `echo "Forwarding from `+listener.Addr().String()+` -> 5432"
+exec sleep 60`)
+
+	a := &Agent{connStore: memory.New()}
+	connParams := &pb.AgentConnectionParams{EnvVars: map[string]any{
+		"envvar:SERVICE": "cG9zdGdyZXM=", // postgres
+		"envvar:PORT":    "NTQzMg==",     // 5432
+	}}
+	// the connections of the session share the same process
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := a.dialKubernetesService("sid", connParams, map[string][]byte{})
+			if assert.NoError(t, err) {
+				_ = conn.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, readExecLog(t, execLog), 1, "it should start a single port-forward per session")
+	assert.Contains(t, readExecLog(t, execLog)[0], "port-forward -n default --address 127.0.0.1 svc/postgres :5432")
+
+	sf := a.kubeSessionForward("sid")
+	pf := sf.pf
+	// the process is started again when it exits
+	assert.NoError(t, pf.Close())
+	_, err = a.dialKubernetesService("sid", connParams, map[string][]byte{})
+	assert.NoError(t, err)
+	assert.Len(t, readExecLog(t, execLog), 2)
+	assert.NotSame(t, pf, sf.pf)
+
+	// the process is killed when the session is cleaned up
+	a.sessionCleanup("sid")
+	select {
+	case <-sf.pf.doneC:
+	case <-time.After(time.Second * 5):
+		t.Fatal("timeout waiting the port-forward process to be killed")
+	}
+	assert.False(t, a.connStore.Has("portforward:sid"))
+	_, err = sf.get(func() (*kubePortForward, error) { return newKubePortForward(&kubeEnv{}) })
+	assert.EqualError(t, err, "the session is closed")
+}
+
+func TestKubernetesPortForwardFailures(t *testing.T) {
+	env := &kubeEnv{namespace: "default", service: "postgres", port: "5432"}
+	for _, tt := range []struct {
+		msg     string
+		script  string
+		env     *kubeEnv
+		wantErr string
+	}{
+		{
+			msg:     "it should fail when the service and port are missing",
+			env:     &kubeEnv{namespace: "default"},
+			wantErr: "missing required secrets for kubernetes port-forward [SERVICE, PORT]",
+		},
+		{
+			msg:     "it should return the error of kubectl when it exits",
+			script:  `echo 'Error from server (NotFound): services "postgres" not found' >&2; exit 1`,
+			env:     env,
+			wantErr: `port-forward exited: Error from server (NotFound): services "postgres" not found`,
+		},
+		{
+			msg:     "it should fail when kubectl exits without forwarding",
+			script:  `echo "unexpected output"`,
+			env:     env,
+			wantErr: "port-forward exited: ",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			fakeKubectl(t, tt.script)
+			_, err := newKubePortForward(tt.env)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+
+	t.Run("it should fail when kubectl is not found", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		_, err := newKubePortForward(env)
+		assert.ErrorContains(t, err, "failed starting port-forward")
+	})
+}
+
+func TestKubernetesResolvePod(t *testing.T) {
+	for _, tt := range []struct {
+		msg     string
+		script  string
+		env     *kubeEnv
+		want    string
+		wantErr string
+	}{
+		{
+			msg:  "it should use the pod of the connection",
+			env:  &kubeEnv{namespace: "default", pod: "app-0"},
+			want: "app-0",
+		},
+		{
+			msg:    "it should use the first running pod of the selector",
+			script: `echo "app-1 app-2"`,
+			env:    &kubeEnv{namespace: "default", selector: "app=web"},
+			want:   "app-1",
+		},
+		{
+			msg:     "it should fail without the pod and the selector",
+			env:     &kubeEnv{namespace: "default"},
+			wantErr: "missing required secrets for kubernetes exec [POD or SELECTOR]",
+		},
+		{
+			msg:     "it should fail when there are no running pods",
+			script:  `exit 0`,
+			env:     &kubeEnv{namespace: "default", selector: "app=web"},
+			wantErr: `no running pods found in namespace "default" with selector "app=web"`,
+		},
+		{
+			msg:     "it should return the error of kubectl",
+			script:  `echo "error: You must be logged in to the server (Unauthorized)" >&2; exit 1`,
+			env:     &kubeEnv{namespace: "default", selector: "app=web"},
+			wantErr: "failed listing pods: error: You must be logged in to the server (Unauthorized)",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			fakeKubectl(t, tt.script)
+			got, err := tt.env.resolvePod(context.Background())
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"context"
+	"io"
+	"libhoop"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDBProxyFallback(t *testing.T) {
+	if p, err := libhoop.NewDBCore(context.Background(), io.Discard, nil).Postgres(); p != nil || err != nil {
+		t.Skip("the database protocols are implemented by libhoop")
+	}
+	for name, newProxy := range map[string]func(context.Context, io.Writer, map[string]string) (libhoop.Proxy, error){
+		"postgres": newPostgresProxy,
+		"mysql":    newMySQLProxy,
+		"mssql":    newMSSQLProxy,
+	} {
+		// the native implementation validates the options of the connection
+		_, err := newProxy(context.Background(), io.Discard, map[string]string{})
+		assert.EqualError(t, err, "missing hostname or username of the connection", name)
+	}
+}
@@ -59,9 +59,7 @@ func (a *Agent) processMongoDBProtocol(pkt *pb.Packet) {
 		a.sendClientSessionClose(sid, errMsg)
 		return
 	}
-	serverWriter.Run(func(_ int, errMsg string) {
-		a.sendClientSessionClose(sid, errMsg)
-	})
+	serverWriter.Run(a.onProxyError(sid))
 	// write the first packet when establishing the connection
 	_, _ = serverWriter.Write(pkt.Payload)
 	a.connStore.Set(clientConnectionIDKey, serverWriter)
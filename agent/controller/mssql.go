@@ -6,6 +6,7 @@ import (
 	"io"
 	"libhoop"
 
+	"github.com/hoophq/hoop/agent/dbproxy"
 	"github.com/hoophq/hoop/common/log"
 	pb "github.com/hoophq/hoop/common/proto"
 	pbclient "github.com/hoophq/hoop/common/proto/client"
@@ -51,19 +52,29 @@ func (a *Agent) processMSSQLProtocol(pkt *pb.Packet) {
 		"port":     connenv.port,
 		"username": connenv.user,
 		"password": connenv.pass,
+		"database": connenv.dbname,
 		"insecure": fmt.Sprintf("%v", connenv.insecure),
 	}
-	serverWriter, err := libhoop.NewDBCore(context.Background(), streamClient, opts).MSSQL()
+	serverWriter, err := newMSSQLProxy(context.Background(), streamClient, opts)
 	if err != nil {
 		errMsg := fmt.Sprintf("failed connecting with mssql server, err=%v", err)
 		log.Errorf(errMsg)
 		a.sendClientSessionClose(sessionID, errMsg)
 		return
 	}
-	serverWriter.Run(func(_ int, errMsg string) {
-		a.sendClientSessionClose(sessionID, errMsg)
-	})
+	serverWriter.Run(a.onProxyError(sessionID))
 	// write the first packet when establishing the connection
 	_, _ = serverWriter.Write(pkt.Payload)
 	a.connStore.Set(clientConnectionIDKey, serverWriter)
 }
+
+// newMSSQLProxy returns the mssql proxy of libhoop, when the library is not
+// available (open source builds) it fallbacks to the native implementation.
+func newMSSQLProxy(ctx context.Context, clientW io.Writer, opts map[string]string) (libhoop.Proxy, error) {
+	serverWriter, err := libhoop.NewDBCore(ctx, clientW, opts).MSSQL()
+	// the library returns a nil proxy for the protocols implemented by the agent
+	if serverWriter != nil || err != nil {
+		return serverWriter, err
+	}
+	return dbproxy.NewMSSQL(ctx, clientW, opts)
+}
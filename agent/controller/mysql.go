@@ -6,6 +6,8 @@ import (
 	"io"
 	"libhoop"
 
+	"github.com/hoophq/hoop/agent/dbproxy"
+	"github.com/hoophq/hoop/common/dlp"
 	"github.com/hoophq/hoop/common/log"
 	pb "github.com/hoophq/hoop/common/proto"
 	pbclient "github.com/hoophq/hoop/common/proto/client"
@@ -51,16 +53,45 @@ func (a *Agent) processMySQLProtocol(pkt *pb.Packet) {
 		"port":     connenv.port,
 		"username": connenv.user,
 		"password": connenv.pass,
+		"database": connenv.dbname,
+		"insecure": fmt.Sprintf("%v", connenv.insecure),
 	}
-	serverWriter, err := libhoop.NewDBCore(context.Background(), streamClient, opts).MySQL()
+	policies, err := parseColumnPolicies(connParams)
+	if err != nil {
+		log.Errorf("session=%v - %v", sessionID, err)
+		a.sendClientSessionClose(sessionID, err.Error())
+		return
+	}
+	var clientWriter io.Writer = streamClient
+	var masking *dataMaskingWriter
+	if len(policies) > 0 {
+		// mysql informs the name of the tables in the column definitions
+		masking = newDataMaskingWriter(a.client, pbclient.MySQLConnectionWrite, pkt.Spec,
+			pb.ConnectionTypeMySQL, dlp.NewColumnMasker(policies, connParams.DataMaskingHashKey, nil))
+		clientWriter = masking
+	}
+	serverWriter, err := newMySQLProxy(context.Background(), clientWriter, opts)
 	if err != nil {
 		errMsg := fmt.Sprintf("failed connecting with mysql server, err=%v", err)
 		log.Errorf(errMsg)
 		a.sendClientSessionClose(sessionID, errMsg)
 		return
 	}
-	serverWriter.Run(func(_ int, errMsg string) {
-		a.sendClientSessionClose(sessionID, errMsg)
-	})
-	a.connStore.Set(clientConnectionIDKey, serverWriter)
+	serverWriter.Run(a.onProxyError(sessionID))
+	var connWriter io.WriteCloser = serverWriter
+	if masking != nil {
+		connWriter = masking.serverWriter(serverWriter, nil)
+	}
+	a.connStore.Set(clientConnectionIDKey, connWriter)
+}
+
+// newMySQLProxy returns the mysql proxy of libhoop, when the library is not
+// available (open source builds) it fallbacks to the native implementation.
+func newMySQLProxy(ctx context.Context, clientW io.Writer, opts map[string]string) (libhoop.Proxy, error) {
+	serverWriter, err := libhoop.NewDBCore(ctx, clientW, opts).MySQL()
+	// the library returns a nil proxy for the protocols implemented by the agent
+	if serverWriter != nil || err != nil {
+		return serverWriter, err
+	}
+	return dbproxy.NewMySQL(ctx, clientW, opts)
 }
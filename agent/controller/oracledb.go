@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/hoophq/hoop/agent/dbproxy"
+	"github.com/hoophq/hoop/common/log"
+	pb "github.com/hoophq/hoop/common/proto"
+	pbclient "github.com/hoophq/hoop/common/proto/client"
+)
+
+func (a *Agent) processOracleDBProtocol(pkt *pb.Packet) {
+	sessionID := string(pkt.Spec[pb.SpecGatewaySessionID])
+	streamClient := pb.NewStreamWriter(a.client, pbclient.OracleDBConnectionWrite, pkt.Spec)
+	connParams := a.connectionParams(sessionID)
+	if connParams == nil {
+		log.Errorf("session=%s - connection params not found", sessionID)
+		a.sendClientSessionClose(sessionID, "connection params not found, contact the administrator")
+		return
+	}
+
+	clientConnectionID := string(pkt.Spec[pb.SpecClientConnectionID])
+	if clientConnectionID == "" && pkt.Payload != nil {
+		log.Errorf("connection id not found in memory")
+		a.sendClientSessionClose(sessionID, "connection id not found, contact the administrator")
+		return
+	}
+	clientConnectionIDKey := fmt.Sprintf("%s:%s", sessionID, string(clientConnectionID))
+	clientObj := a.connStore.Get(clientConnectionIDKey)
+	if serverWriter, ok := clientObj.(io.WriteCloser); ok {
+		if _, err := serverWriter.Write(pkt.Payload); err != nil {
+			log.Errorf("failed sending packet, err=%v", err)
+			a.sendClientSessionClose(sessionID, "fail to write packet")
+			_ = serverWriter.Close()
+		}
+		return
+	}
+
+	connenv, err := parseConnectionEnvVars(connParams.EnvVars, pb.ConnectionTypeOracleDB)
+	if err != nil {
+		log.Error("oracledb credentials not found in memory, err=%v", err)
+		a.sendClientSessionClose(sessionID, "credentials are empty, contact the administrator")
+		return
+	}
+
+	log.Infof("session=%v - starting oracledb connection at %v:%v", sessionID, connenv.host, connenv.port)
+	opts := map[string]string{
+		"hostname":     connenv.host,
+		"port":         connenv.port,
+		"username":     connenv.user,
+		"password":     connenv.pass,
+		"service_name": connenv.oracleSID,
+	}
+	// libhoop doesn't implement the oracledb protocol, use the native implementation
+	serverWriter, err := dbproxy.NewOracleDB(context.Background(), streamClient, opts)
+	if err != nil {
+		errMsg := fmt.Sprintf("failed connecting with oracledb server, err=%v", err)
+		log.Errorf(errMsg)
+		a.sendClientSessionClose(sessionID, errMsg)
+		return
+	}
+	serverWriter.Run(a.onProxyError(sessionID))
+	// write the first packet when establishing the connection
+	_, _ = serverWriter.Write(pkt.Payload)
+	a.connStore.Set(clientConnectionIDKey, serverWriter)
+}
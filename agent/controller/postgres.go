@@ -7,6 +7,8 @@ import (
 	"libhoop"
 	"strings"
 
+	"github.com/hoophq/hoop/agent/dbproxy"
+	"github.com/hoophq/hoop/common/dlp"
 	"github.com/hoophq/hoop/common/log"
 	pb "github.com/hoophq/hoop/common/proto"
 	pbclient "github.com/hoophq/hoop/common/proto/client"
@@ -54,21 +56,62 @@ func (a *Agent) processPGProtocol(pkt *pb.Packet) {
 		"username":              connenv.user,
 		"password":              connenv.pass,
 		"sslmode":               connenv.postgresSSLMode,
+		"sslrootcert":           connenv.postgresSSLRootCert,
+		"pool_mode":             connenv.postgresPoolMode,
+		"pool_size":             connenv.postgresPoolSize,
+		"database":              connenv.dbname,
 		"dlp_gcp_credentials":   a.getGCPCredentials(),
 		"dlp_info_types":        strings.Join(connParams.DLPInfoTypes, ","),
 		"dlp_masking_character": "#",
 	}
-	serverWriter, err := libhoop.NewDBCore(context.Background(), streamClient, opts).Postgres()
+	policies, err := parseColumnPolicies(connParams)
+	if err != nil {
+		log.Errorf("session=%v - %v", sessionID, err)
+		a.sendClientSessionClose(sessionID, err.Error())
+		return
+	}
+	var clientWriter io.Writer = streamClient
+	var masking *dataMaskingWriter
+	var resolver *pgTableResolver
+	if len(policies) > 0 {
+		resolver = newPGTableResolver(connenv, pkt.Payload)
+		masking = newDataMaskingWriter(a.client, pbclient.PGConnectionWrite, pkt.Spec,
+			pb.ConnectionTypePostgres, dlp.NewColumnMasker(policies, connParams.DataMaskingHashKey, resolver.Resolve))
+		clientWriter = masking
+	}
+	serverWriter, err := newPostgresProxy(context.Background(), clientWriter, opts)
 	if err != nil {
 		errMsg := fmt.Sprintf("failed connecting with postgres server, err=%v", err)
 		log.Errorf(errMsg)
 		a.sendClientSessionClose(sessionID, errMsg)
 		return
 	}
-	serverWriter.Run(func(_ int, errMsg string) {
-		a.sendClientSessionClose(sessionID, errMsg)
-	})
+	serverWriter.Run(a.onProxyError(sessionID))
+	var connWriter io.WriteCloser = serverWriter
+	if masking != nil {
+		connWriter = masking.serverWriter(serverWriter, resolver)
+	}
 	// write the first packet when establishing the connection
-	_, _ = serverWriter.Write(pkt.Payload)
-	a.connStore.Set(clientConnectionIDKey, serverWriter)
+	_, _ = connWriter.Write(pkt.Payload)
+	a.connStore.Set(clientConnectionIDKey, connWriter)
+}
+
+// newPostgresProxy returns the postgres proxy of libhoop, when the library is not
+// available (open source builds) it fallbacks to the native implementation.
+// The connection pooling is only available in the native implementation, it's refused
+// when the data loss prevention is configured because the results would not be redacted.
+func newPostgresProxy(ctx context.Context, clientW io.Writer, opts map[string]string) (libhoop.Proxy, error) {
+	if opts["pool_mode"] != "" {
+		if opts["dlp_info_types"] != "" {
+			return nil, fmt.Errorf("the %v pool mode is not supported with data loss prevention, "+
+				"remove the info types of the connection or disable the pool", opts["pool_mode"])
+		}
+		return dbproxy.NewPostgresPool(ctx, clientW, opts)
+	}
+	serverWriter, err := libhoop.NewDBCore(ctx, clientW, opts).Postgres()
+	// the library returns a nil proxy for the protocols implemented by the agent
+	if serverWriter != nil || err != nil {
+		return serverWriter, err
+	}
+	return dbproxy.NewPostgres(ctx, clientW, opts)
 }
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/hoophq/hoop/agent/sshproxy"
+	"github.com/hoophq/hoop/common/log"
+	pb "github.com/hoophq/hoop/common/proto"
+	pbclient "github.com/hoophq/hoop/common/proto/client"
+)
+
+func (a *Agent) processSSHProtocol(pkt *pb.Packet) {
+	sessionID := string(pkt.Spec[pb.SpecGatewaySessionID])
+	streamClient := pb.NewStreamWriter(a.client, pbclient.SSHConnectionWrite, pkt.Spec)
+	connParams := a.connectionParams(sessionID)
+	if connParams == nil {
+		log.Errorf("session=%s - connection params not found", sessionID)
+		a.sendClientSessionClose(sessionID, "connection params not found, contact the administrator")
+		return
+	}
+
+	clientConnectionID := string(pkt.Spec[pb.SpecClientConnectionID])
+	if clientConnectionID == "" && pkt.Payload != nil {
+		log.Errorf("connection id not found in memory")
+		a.sendClientSessionClose(sessionID, "connection id not found, contact the administrator")
+		return
+	}
+	clientConnectionIDKey := fmt.Sprintf("%s:%s", sessionID, string(clientConnectionID))
+	clientObj := a.connStore.Get(clientConnectionIDKey)
+	if serverWriter, ok := clientObj.(io.WriteCloser); ok {
+		if _, err := serverWriter.Write(pkt.Payload); err != nil {
+			log.Errorf("failed sending packet, err=%v", err)
+			a.sendClientSessionClose(sessionID, "fail to write packet")
+			_ = serverWriter.Close()
+		}
+		return
+	}
+
+	opts, err := sshOptions(connParams)
+	if err != nil {
+		log.Error("ssh credentials not found in memory, err=%v", err)
+		a.sendClientSessionClose(sessionID, "credentials are empty, contact the administrator")
+		return
+	}
+	log.Infof("session=%v - starting ssh connection at %v:%v", sessionID, opts["hostname"], opts["port"])
+	serverWriter := sshproxy.NewProxy(context.Background(), streamClient, opts)
+	serverWriter.Run(func(_ int, errMsg string) {
+		a.sendClientSessionClose(sessionID, errMsg)
+	})
+	// write the first packet when establishing the connection
+	if _, err := serverWriter.Write(pkt.Payload); err != nil {
+		log.Errorf("failed sending first packet, err=%v", err)
+	}
+	a.connStore.Set(clientConnectionIDKey, serverWriter)
+}
+
+// sshOptions returns the options to open sessions with the ssh server of the connection
+func sshOptions(connParams *pb.AgentConnectionParams) (map[string]string, error) {
+	connenv, err := parseConnectionEnvVars(connParams.EnvVars, pb.ConnectionTypeSSH)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"hostname":    connenv.host,
+		"port":        connenv.port,
+		"username":    connenv.user,
+		"password":    connenv.pass,
+		"private_key": connenv.sshPrivateKey,
+		"passphrase":  connenv.sshPassphrase,
+		"host_key":    connenv.sshHostKey,
+		"insecure":    fmt.Sprintf("%v", connenv.insecure),
+	}, nil
+}
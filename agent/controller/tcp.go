@@ -3,6 +3,7 @@ package controller
 import (
 	"fmt"
 	"io"
+	"net"
 
 	"github.com/hoophq/hoop/common/log"
 	pb "github.com/hoophq/hoop/common/proto"
@@ -32,19 +33,30 @@ func (a *Agent) processTCPWriteServer(pkt *pb.Packet) {
 		}
 		return
 	}
-	tcpClient := pb.NewStreamWriter(a.client, pbclient.TCPConnectionWrite, pkt.Spec)
-	connenv, err := parseConnectionEnvVars(connParams.EnvVars, pb.ConnectionTypeTCP)
-	if err != nil {
-		log.Printf("session=%s - missing connection credentials in memory, err=%v", sessionID, err)
-		a.sendClientSessionClose(sessionID, "credentials are empty, contact the administrator")
-		return
-	}
-	tcpServer, err := newTCPConn(connenv)
-	if err != nil {
-		log.Printf("session=%s - failed connecting to %v, err=%v", sessionID, connenv.host, err)
-		a.sendClientSessionClose(sessionID, fmt.Sprintf("failed connecting to internal service, reason=%v", err))
-		return
+	var tcpServer net.Conn
+	if pb.ConnectionType(connParams.ConnectionType) == pb.ConnectionTypeKubernetes {
+		var err error
+		tcpServer, err = a.dialKubernetesService(sessionID, connParams, pkt.Spec)
+		if err != nil {
+			log.Printf("session=%s - failed connecting to kubernetes service, err=%v", sessionID, err)
+			a.sendClientSessionClose(sessionID, fmt.Sprintf("failed connecting to kubernetes service, reason=%v", err))
+			return
+		}
+	} else {
+		connenv, err := parseConnectionEnvVars(connParams.EnvVars, pb.ConnectionTypeTCP)
+		if err != nil {
+			log.Printf("session=%s - missing connection credentials in memory, err=%v", sessionID, err)
+			a.sendClientSessionClose(sessionID, "credentials are empty, contact the administrator")
+			return
+		}
+		tcpServer, err = newTCPConn(connenv)
+		if err != nil {
+			log.Printf("session=%s - failed connecting to %v, err=%v", sessionID, connenv.host, err)
+			a.sendClientSessionClose(sessionID, fmt.Sprintf("failed connecting to internal service, reason=%v", err))
+			return
+		}
 	}
+	tcpClient := pb.NewStreamWriter(a.client, pbclient.TCPConnectionWrite, pkt.Spec)
 	a.connStore.Set(clientConnectionIDKey, tcpServer)
 	go func() {
 		defer a.connStore.Del(clientConnectionIDKey)
@@ -1,11 +1,14 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	"libhoop"
+	"maps"
 	"strconv"
 	"strings"
 
+	"github.com/hoophq/hoop/agent/sshproxy"
 	"github.com/hoophq/hoop/common/log"
 	pb "github.com/hoophq/hoop/common/proto"
 	pbclient "github.com/hoophq/hoop/common/proto/client"
@@ -23,6 +26,20 @@ func (a *Agent) doExec(pkt *pb.Packet) {
 	}
 
 	spec := map[string][]byte{pb.SpecGatewaySessionID: []byte(sessionID)}
+	args := append(connParams.CmdList, connParams.ClientArgs...)
+	// the labels of the session are sent with the output of the command
+	var labels map[string][]byte
+	if pb.ConnectionType(connParams.ConnectionType) == pb.ConnectionTypeKubernetes {
+		var err error
+		args, labels, err = kubernetesExecArgs(connParams.EnvVars, args)
+		if err != nil {
+			errMsg := fmt.Sprintf("failed configuring kubernetes exec, reason=%v", err)
+			log.Printf("session=%s - %s", sessionID, errMsg)
+			a.sendClientSessionClose(sessionID, errMsg)
+			return
+		}
+		maps.Copy(spec, labels)
+	}
 	stdoutw := pb.NewStreamWriter(a.client, pbclient.WriteStdout, spec)
 	stderrw := pb.NewStreamWriter(a.client, pbclient.WriteStderr, spec)
 	opts := map[string]string{
@@ -32,8 +49,16 @@ func (a *Agent) doExec(pkt *pb.Packet) {
 		"dlp_gcp_credentials":       a.getGCPCredentials(),
 		"dlp_info_types":            strings.Join(connParams.DLPInfoTypes, ","),
 	}
-	args := append(connParams.CmdList, connParams.ClientArgs...)
-	cmd, err := libhoop.NewAdHocExec(connParams.EnvVars, args, pkt.Payload, stdoutw, stderrw, opts)
+	var cmd libhoop.Proxy
+	var err error
+	if pb.ConnectionType(connParams.ConnectionType) == pb.ConnectionTypeSSH {
+		var sshOpts map[string]string
+		if sshOpts, err = sshOptions(connParams); err == nil {
+			cmd = sshproxy.NewExec(context.Background(), strings.Join(args, " "), pkt.Payload, stdoutw, stderrw, sshOpts)
+		}
+	} else {
+		cmd, err = libhoop.NewAdHocExec(connParams.EnvVars, args, pkt.Payload, stdoutw, stderrw, opts)
+	}
 	if err != nil {
 		errMsg := fmt.Sprintf("failed configuring command, reason=%v", err)
 		log.Printf("session=%s - %s", sessionID, errMsg)
@@ -53,13 +78,11 @@ func (a *Agent) doExec(pkt *pb.Packet) {
 		if errMsg != "" {
 			log.Infof("session=%v, exitcode=%v - err=%v", string(sessionID), exitCode, errMsg)
 		}
-		_, _ = pb.NewStreamWriter(
-			a.client,
-			pbclient.SessionClose,
-			map[string][]byte{
-				pb.SpecGatewaySessionID:  []byte(sessionID),
-				pb.SpecClientExitCodeKey: []byte(strconv.Itoa(exitCode)),
-			},
-		).Write([]byte(errMsg))
+		closeSpec := map[string][]byte{
+			pb.SpecGatewaySessionID:  []byte(sessionID),
+			pb.SpecClientExitCodeKey: []byte(strconv.Itoa(exitCode)),
+		}
+		maps.Copy(closeSpec, labels)
+		_, _ = pb.NewStreamWriter(a.client, pbclient.SessionClose, closeSpec).Write([]byte(errMsg))
 	})
 }
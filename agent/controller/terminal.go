@@ -1,11 +1,13 @@
 package controller
 
 import (
+	"context"
 	"fmt"
 	"libhoop"
 	"strconv"
 	"strings"
 
+	"github.com/hoophq/hoop/agent/sshproxy"
 	"github.com/hoophq/hoop/common/log"
 
 	"github.com/creack/pty"
@@ -36,12 +38,20 @@ func (a *Agent) doTerminalWriteAgentStdin(pkt *pb.Packet) {
 
 	spec := map[string][]byte{pb.SpecGatewaySessionID: []byte(sessionID)}
 	stdoutWriter := pb.NewStreamWriter(a.client, pbclient.WriteStdout, spec)
-	opts := map[string]string{
-		"dlp_gcp_credentials": a.getGCPCredentials(),
-		"dlp_info_types":      strings.Join(connParams.DLPInfoTypes, ","),
+	var err error
+	if pb.ConnectionType(connParams.ConnectionType) == pb.ConnectionTypeSSH {
+		var sshOpts map[string]string
+		if sshOpts, err = sshOptions(connParams); err == nil {
+			cmd = sshproxy.NewTerminal(context.Background(), stdoutWriter, sshOpts)
+		}
+	} else {
+		opts := map[string]string{
+			"dlp_gcp_credentials": a.getGCPCredentials(),
+			"dlp_info_types":      strings.Join(connParams.DLPInfoTypes, ","),
+		}
+		args := append(connParams.CmdList, connParams.ClientArgs...)
+		cmd, err = libhoop.NewConsole(connParams.EnvVars, args, stdoutWriter, opts)
 	}
-	args := append(connParams.CmdList, connParams.ClientArgs...)
-	cmd, err := libhoop.NewConsole(connParams.EnvVars, args, stdoutWriter, opts)
 	if err != nil {
 		// should we propagate the error back to the client?
 		// In case of sensitive contents in the error this could be propagate back
@@ -0,0 +1,53 @@
+package dbproxy
+
+import (
+	"io"
+	"sync"
+)
+
+// clientReader buffers the packets written by the client, reads block until
+// there's data available or the reader is closed.
+type clientReader struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    []byte
+	closed bool
+}
+
+func newClientReader() *clientReader {
+	r := &clientReader{}
+	r.cond = sync.NewCond(&r.mu)
+	return r
+}
+
+func (r *clientReader) write(data []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return 0, io.ErrClosedPipe
+	}
+	r.buf = append(r.buf, data...)
+	r.cond.Signal()
+	return len(data), nil
+}
+
+func (r *clientReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for len(r.buf) == 0 && !r.closed {
+		r.cond.Wait()
+	}
+	if len(r.buf) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *clientReader) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	r.cond.Broadcast()
+}
@@ -0,0 +1,221 @@
+package dbproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/hoophq/hoop/common/mssqltypes"
+)
+
+// prelogin options
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/60f56408-0188-4cd5-8b90-25c6f2423868
+const (
+	mssqlPreloginEncryption byte = 0x01
+	mssqlPreloginTerminator byte = 0xff
+
+	mssqlEncryptOff    byte = 0x00
+	mssqlEncryptOn     byte = 0x01
+	mssqlEncryptNotSup byte = 0x02
+	mssqlEncryptReq    byte = 0x03
+)
+
+type mssqlProxy struct {
+	*proxy
+	host     string
+	port     string
+	user     string
+	password string
+	database string
+	insecure bool
+}
+
+// NewMSSQL returns a proxy that writes the server packets to clientW. The options are:
+//
+//	hostname, port, username, password - the address and credentials of the server
+//	database - the database to connect when the client doesn't inform one
+//	insecure - skip the verification of the server certificate when it's "true"
+//
+// The proxy always requests encryption to the server, the connection is
+// not encrypted only when the server doesn't support it in insecure mode.
+func NewMSSQL(ctx context.Context, clientW io.Writer, opts map[string]string) (*mssqlProxy, error) {
+	if opts["hostname"] == "" || opts["username"] == "" {
+		return nil, fmt.Errorf("missing hostname or username of the connection")
+	}
+	port := opts["port"]
+	if port == "" {
+		port = "1433"
+	}
+	p := &mssqlProxy{
+		proxy:    newProxy(ctx, "mssql", clientW),
+		host:     opts["hostname"],
+		port:     port,
+		user:     opts["username"],
+		password: opts["password"],
+		database: opts["database"],
+		insecure: opts["insecure"] == "true",
+	}
+	p.serve = p.serveMSSQL
+	return p, nil
+}
+
+func (p *mssqlProxy) serveMSSQL() error {
+	clientPrelogin, err := mssqltypes.Decode(p.clientR)
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return fmt.Errorf("failed reading prelogin packet: %v", err)
+	}
+	if clientPrelogin.Type() != mssqltypes.PacketPreloginType {
+		return fmt.Errorf("expected a prelogin packet, got=%X", clientPrelogin.Type())
+	}
+	conn, err := p.dial(p.host, p.port)
+	if err != nil {
+		return err
+	}
+	if err := setMSSQLPreloginEncryption(clientPrelogin.Frame, mssqlEncryptOn); err != nil {
+		return err
+	}
+	if _, err := conn.Write(mssqltypes.New(mssqltypes.PacketPreloginType, clientPrelogin.Frame).Encode()); err != nil {
+		return fmt.Errorf("failed writing prelogin packet: %v", err)
+	}
+	serverPrelogin, err := mssqltypes.Decode(conn)
+	if err != nil {
+		return fmt.Errorf("failed reading prelogin response: %v", err)
+	}
+	encryption, err := getMSSQLPreloginEncryption(serverPrelogin.Frame)
+	if err != nil {
+		return err
+	}
+	// the client connects without TLS, the agent is the one
+	// responsible for encrypting the connection with the server
+	if err := setMSSQLPreloginEncryption(serverPrelogin.Frame, mssqlEncryptNotSup); err != nil {
+		return err
+	}
+	if _, err := p.clientW.Write(mssqltypes.New(mssqltypes.PacketReplyType, serverPrelogin.Frame).Encode()); err != nil {
+		return err
+	}
+
+	loginPkt, err := mssqltypes.Decode(p.clientR)
+	if err != nil {
+		return fmt.Errorf("failed reading login packet: %v", err)
+	}
+	if loginPkt.Type() != mssqltypes.PacketLogin7Type {
+		return fmt.Errorf("expected a login packet, got=%X", loginPkt.Type())
+	}
+	login := mssqltypes.DecodeLogin(loginPkt.Frame)
+	login.UserName = p.user
+	login.Password = p.password
+	if login.Database == "" {
+		login.Database = p.database
+	}
+	login.DisablePasswordChange()
+	serverLogin, err := mssqltypes.EncodeLogin(*login)
+	if err != nil {
+		return fmt.Errorf("failed encoding login packet: %v", err)
+	}
+
+	var loginConn net.Conn = conn
+	switch encryption {
+	case mssqlEncryptOn, mssqlEncryptReq, mssqlEncryptOff:
+		if loginConn, err = p.handshakeTLS(conn); err != nil {
+			return err
+		}
+	case mssqlEncryptNotSup:
+		if !p.insecure {
+			return fmt.Errorf("mssql server does not support encryption, the connection requires the insecure mode")
+		}
+	}
+	if _, err := loginConn.Write(serverLogin.Encode()); err != nil {
+		return fmt.Errorf("failed writing login packet: %v", err)
+	}
+	// when the server turns off the encryption, only the login packet is encrypted
+	if encryption == mssqlEncryptOff {
+		return p.relay(conn, conn)
+	}
+	return p.relay(loginConn, loginConn)
+}
+
+// handshakeTLS performs the TLS handshake, the handshake records are sent as the payload of prelogin packets
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/60f56408-0188-4cd5-8b90-25c6f2423868
+func (p *mssqlProxy) handshakeTLS(conn net.Conn) (net.Conn, error) {
+	handshakeConn := &mssqlHandshakeConn{Conn: conn}
+	tlsConn := tls.Client(handshakeConn, &tls.Config{
+		ServerName:         p.host,
+		InsecureSkipVerify: p.insecure,
+	})
+	ctx, cancelFn := context.WithTimeout(p.ctx, dialTimeout)
+	defer cancelFn()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed tls handshake with mssql server: %v", err)
+	}
+	handshakeConn.passthrough = true
+	p.setServer(tlsConn)
+	return tlsConn, nil
+}
+
+// mssqlHandshakeConn wraps the TLS handshake in prelogin packets,
+// after the handshake the TLS records are sent directly to the server
+type mssqlHandshakeConn struct {
+	net.Conn
+	buf         bytes.Buffer
+	passthrough bool
+}
+
+func (c *mssqlHandshakeConn) Read(b []byte) (int, error) {
+	if c.passthrough {
+		return c.Conn.Read(b)
+	}
+	if c.buf.Len() == 0 {
+		pkt, err := mssqltypes.Decode(c.Conn)
+		if err != nil {
+			return 0, err
+		}
+		c.buf.Write(pkt.Frame)
+	}
+	return c.buf.Read(b)
+}
+
+func (c *mssqlHandshakeConn) Write(b []byte) (int, error) {
+	if c.passthrough {
+		return c.Conn.Write(b)
+	}
+	if _, err := c.Conn.Write(mssqltypes.New(mssqltypes.PacketPreloginType, b).Encode()); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// mssqlPreloginOffset returns the position of the data of a prelogin option
+func mssqlPreloginOffset(frame []byte, token byte) (int, bool) {
+	for pos := 0; pos+5 <= len(frame) && frame[pos] != mssqlPreloginTerminator; pos += 5 {
+		offset := int(binary.BigEndian.Uint16(frame[pos+1:]))
+		length := int(binary.BigEndian.Uint16(frame[pos+3:]))
+		if frame[pos] == token && length > 0 && offset+length <= len(frame) {
+			return offset, true
+		}
+	}
+	return 0, false
+}
+
+func getMSSQLPreloginEncryption(frame []byte) (byte, error) {
+	offset, ok := mssqlPreloginOffset(frame, mssqlPreloginEncryption)
+	if !ok {
+		return 0, fmt.Errorf("missing encryption option in prelogin packet")
+	}
+	return frame[offset], nil
+}
+
+func setMSSQLPreloginEncryption(frame []byte, encryption byte) error {
+	offset, ok := mssqlPreloginOffset(frame, mssqlPreloginEncryption)
+	if !ok {
+		return fmt.Errorf("missing encryption option in prelogin packet")
+	}
+	frame[offset] = encryption
+	return nil
+}
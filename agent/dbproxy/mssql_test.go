@@ -0,0 +1,165 @@
+package dbproxy
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/hoophq/hoop/common/mssqltypes"
+	"github.com/stretchr/testify/assert"
+)
+
+// newMSSQLTestPrelogin returns a prelogin frame with the encryption option
+func newMSSQLTestPrelogin(encryption byte) []byte {
+	return []byte{mssqlPreloginEncryption, 0x00, 0x06, 0x00, 0x01, mssqlPreloginTerminator, encryption}
+}
+
+func newMSSQLTestLogin(t *testing.T, user, password, database string) []byte {
+	// a login without options, the header has 94 bytes
+	login := mssqltypes.DecodeLogin(make([]byte, 94))
+	login.UserName = user
+	login.Password = password
+	login.Database = database
+	pkt, err := mssqltypes.EncodeLogin(*login)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkt.Encode()
+}
+
+// fakeMSSQLServer replies the prelogin with the encryption option, the connection is encrypted when tlsConfig
+// is set. It validates the login of the user hoop with the password secret and echoes a single sql batch.
+func fakeMSSQLServer(t *testing.T, encryption byte, tlsConfig *tls.Config) func(conn net.Conn) error {
+	wantLogin := mssqltypes.DecodeLogin(newMSSQLTestLogin(t, "hoop", "secret", "mydb")[8:])
+	return func(conn net.Conn) error {
+		pkt, err := mssqltypes.Decode(conn)
+		if err != nil {
+			return err
+		}
+		if clientEncryption, _ := getMSSQLPreloginEncryption(pkt.Frame); pkt.Type() != mssqltypes.PacketPreloginType || clientEncryption != mssqlEncryptOn {
+			return fmt.Errorf("expected a prelogin requesting encryption, got=%X", pkt.Encode())
+		}
+		if _, err := conn.Write(mssqltypes.New(mssqltypes.PacketReplyType, newMSSQLTestPrelogin(encryption)).Encode()); err != nil {
+			return err
+		}
+		var serverConn net.Conn = conn
+		if tlsConfig != nil {
+			handshakeConn := &mssqlHandshakeConn{Conn: conn}
+			tlsConn := tls.Server(handshakeConn, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return fmt.Errorf("failed tls handshake: %v", err)
+			}
+			handshakeConn.passthrough = true
+			serverConn = tlsConn
+		}
+		pkt, err = mssqltypes.Decode(serverConn)
+		if err != nil {
+			return fmt.Errorf("failed reading login packet: %v", err)
+		}
+		login := mssqltypes.DecodeLogin(pkt.Frame)
+		if login.UserName != wantLogin.UserName || login.Password != wantLogin.Password || login.Database != wantLogin.Database {
+			return fmt.Errorf("unexpected login, user=%v, database=%v", login.UserName, login.Database)
+		}
+		// only the login packet is encrypted when the encryption is off
+		if encryption == mssqlEncryptOff {
+			serverConn = conn
+		}
+		if _, err := serverConn.Write(mssqltypes.New(mssqltypes.PacketReplyType, []byte("login-ack")).Encode()); err != nil {
+			return err
+		}
+		pkt, err = mssqltypes.Decode(serverConn)
+		if err != nil {
+			return err
+		}
+		_, err = serverConn.Write(mssqltypes.New(mssqltypes.PacketReplyType, pkt.Frame).Encode())
+		return err
+	}
+}
+
+func TestMSSQLHandshake(t *testing.T) {
+	tlsConfig := newSelfSignedTLSConfig(t)
+	for _, tt := range []struct {
+		msg        string
+		encryption byte
+		tlsConfig  *tls.Config
+		insecure   bool
+		wantErr    string
+	}{
+		{
+			msg:        "it should encrypt the connection when the server supports it",
+			encryption: mssqlEncryptOn,
+			tlsConfig:  tlsConfig,
+			insecure:   true,
+		},
+		{
+			msg:        "it should encrypt only the login packet when the server turns off the encryption",
+			encryption: mssqlEncryptOff,
+			tlsConfig:  tlsConfig,
+			insecure:   true,
+		},
+		{
+			msg:        "it should connect without encryption in insecure mode",
+			encryption: mssqlEncryptNotSup,
+			insecure:   true,
+		},
+		{
+			msg:        "it should fail when the server does not support encryption",
+			encryption: mssqlEncryptNotSup,
+			wantErr:    "mssql server does not support encryption, the connection requires the insecure mode",
+		},
+		{
+			msg:        "it should fail when the server certificate is not trusted",
+			encryption: mssqlEncryptOn,
+			tlsConfig:  tlsConfig,
+			wantErr:    "failed tls handshake with mssql server",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			host, port, errCh := newFakeServer(t, fakeMSSQLServer(t, tt.encryption, tt.tlsConfig))
+			clientR, clientW := newClientPipe(t)
+			opts := map[string]string{"hostname": host, "port": port, "username": "hoop", "password": "secret", "database": "mydb"}
+			if tt.insecure {
+				opts["insecure"] = "true"
+			}
+			p, err := NewMSSQL(context.Background(), clientW, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+			onErrCh := make(chan string, 1)
+			p.Run(func(_ int, errMsg string) { onErrCh <- errMsg })
+			closeOnDone(p.proxy, clientW)
+
+			_, _ = p.Write(mssqltypes.New(mssqltypes.PacketPreloginType, newMSSQLTestPrelogin(mssqlEncryptOff)).Encode())
+			pkt, err := mssqltypes.Decode(clientR)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encryption, _ := getMSSQLPreloginEncryption(pkt.Frame)
+			assert.Equal(t, mssqlEncryptNotSup, encryption, "the client must not be asked to encrypt the connection")
+
+			_, _ = p.Write(newMSSQLTestLogin(t, "client-user", "", ""))
+			if tt.wantErr != "" {
+				assert.Contains(t, <-onErrCh, tt.wantErr)
+				_, err := mssqltypes.Decode(clientR)
+				assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+				return
+			}
+			pkt, err = mssqltypes.Decode(clientR)
+			if err != nil {
+				waitServer(t, errCh)
+				t.Fatal(err)
+			}
+			assert.Equal(t, "login-ack", string(pkt.Frame))
+
+			_, _ = p.Write(mssqltypes.New(mssqltypes.PacketSQLBatchType, []byte("SELECT 1")).Encode())
+			pkt, err = mssqltypes.Decode(clientR)
+			assert.NoError(t, err)
+			assert.Equal(t, "SELECT 1", string(pkt.Frame))
+			waitServer(t, errCh)
+		})
+	}
+}
@@ -0,0 +1,502 @@
+package dbproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/hoophq/hoop/common/mysqltypes"
+)
+
+// capability flags
+// https://dev.mysql.com/doc/dev/mysql-server/latest/group__group__cs__capabilities__flags.html
+const (
+	mysqlClientConnectWithDB              uint32 = 0x00000008
+	mysqlClientProtocol41                 uint32 = 0x00000200
+	mysqlClientSSL                        uint32 = 0x00000800
+	mysqlClientSecureConnection           uint32 = 0x00008000
+	mysqlClientMultiStatements            uint32 = 0x00010000
+	mysqlClientMultiResults               uint32 = 0x00020000
+	mysqlClientPluginAuth                 uint32 = 0x00080000
+	mysqlClientConnectAttrs               uint32 = 0x00100000
+	mysqlClientPluginAuthLenencClientData uint32 = 0x00200000
+)
+
+const (
+	mysqlNativePassword      = "mysql_native_password"
+	mysqlCachingSHA2Password = "caching_sha2_password"
+
+	mysqlAuthSwitchRequest mysqltypes.PacketType = 0xfe
+	mysqlAuthMoreData      mysqltypes.PacketType = 0x01
+
+	// caching_sha2_password states of the AuthMoreData packet
+	mysqlFastAuthSuccess  byte = 0x03
+	mysqlPerformFullAuth  byte = 0x04
+	mysqlRequestPublicKey byte = 0x02
+)
+
+type mysqlProxy struct {
+	*proxy
+	host     string
+	port     string
+	user     string
+	password string
+	database string
+	insecure bool
+}
+
+// mysqlHandshake is the initial handshake packet (v10) of the server
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_connection_phase_packets_protocol_handshake_v10.html
+type mysqlHandshake struct {
+	pkt          *mysqltypes.Packet
+	capabilities uint32
+	// the offset of the lower and upper capability flags in the frame
+	capLowerPos int
+	capUpperPos int
+	authData    []byte
+	authPlugin  string
+}
+
+// mysqlHandshakeResponse is the HandshakeResponse41 packet of the client
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_connection_phase_packets_protocol_handshake_response.html
+type mysqlHandshakeResponse struct {
+	capabilities  uint32
+	maxPacketSize uint32
+	charset       byte
+	username      string
+	authResponse  []byte
+	database      string
+	authPlugin    string
+	connectAttrs  []byte
+}
+
+// NewMySQL returns a proxy that writes the server packets to clientW. The options are:
+//
+//	hostname, port, username, password - the address and credentials of the server
+//	database - the database to connect when the client doesn't inform one
+//	insecure - skip the verification of the server certificate when it's "true"
+//
+// The connection with the server is encrypted when the server supports TLS.
+func NewMySQL(ctx context.Context, clientW io.Writer, opts map[string]string) (*mysqlProxy, error) {
+	if opts["hostname"] == "" || opts["username"] == "" {
+		return nil, fmt.Errorf("missing hostname or username of the connection")
+	}
+	port := opts["port"]
+	if port == "" {
+		port = "3306"
+	}
+	p := &mysqlProxy{
+		proxy:    newProxy(ctx, "mysql", clientW),
+		host:     opts["hostname"],
+		port:     port,
+		user:     opts["username"],
+		password: opts["password"],
+		database: opts["database"],
+		insecure: opts["insecure"] == "true",
+	}
+	p.serve = p.serveMySQL
+	return p, nil
+}
+
+func (p *mysqlProxy) serveMySQL() error {
+	conn, err := p.dial(p.host, p.port)
+	if err != nil {
+		return err
+	}
+	serverR := bufio.NewReader(conn)
+	pkt, err := mysqltypes.Decode(serverR)
+	if err != nil {
+		return fmt.Errorf("failed reading initial handshake: %v", err)
+	}
+	if pkt.Type() == mysqltypes.PacketErrType {
+		_, _ = p.clientW.Write(pkt.Encode())
+		return fmt.Errorf("mysql server refused the connection: %v", mysqlErrMessage(pkt.Frame))
+	}
+	handshake, err := decodeMySQLHandshake(pkt)
+	if err != nil {
+		return err
+	}
+	// the client connects without TLS, the agent is the one
+	// responsible for encrypting the connection with the server
+	serverCapabilities := handshake.capabilities
+	handshake.setCapabilities(handshake.capabilities &^ mysqlClientSSL)
+	if _, err := p.clientW.Write(handshake.pkt.Encode()); err != nil {
+		return err
+	}
+
+	clientPkt, err := mysqltypes.Decode(p.clientR)
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return fmt.Errorf("failed reading handshake response: %v", err)
+	}
+	clientResp, err := decodeMySQLHandshakeResponse(clientPkt.Frame)
+	if err != nil {
+		return err
+	}
+
+	var serverConn io.ReadWriter = struct {
+		io.Reader
+		io.Writer
+	}{serverR, conn}
+	resp := &mysqlHandshakeResponse{
+		capabilities:  clientResp.capabilities & (handshake.capabilities | mysqlClientConnectWithDB),
+		maxPacketSize: clientResp.maxPacketSize,
+		charset:       clientResp.charset,
+		username:      p.user,
+		database:      clientResp.database,
+		authPlugin:    handshake.authPlugin,
+		connectAttrs:  clientResp.connectAttrs,
+	}
+	if resp.database == "" {
+		resp.database = p.database
+	}
+	if resp.database != "" {
+		resp.capabilities |= mysqlClientConnectWithDB
+	}
+	if handshake.capabilities&mysqlClientPluginAuth > 0 {
+		resp.capabilities |= mysqlClientPluginAuth
+	}
+	if !isMySQLAuthPluginSupported(resp.authPlugin) {
+		// the server switches to the plugin of the user when it's different
+		resp.authPlugin = mysqlNativePassword
+	}
+	if resp.authResponse, err = mysqlScramble(resp.authPlugin, p.password, handshake.authData); err != nil {
+		return err
+	}
+
+	seq := clientPkt.Seq
+	isTLS := serverCapabilities&mysqlClientSSL > 0
+	if isTLS {
+		if serverConn, err = p.startTLS(conn, resp, seq); err != nil {
+			return err
+		}
+		seq++
+	}
+	if _, err := serverConn.Write(mysqltypes.NewPacket(seq, resp.encode()).Encode()); err != nil {
+		return fmt.Errorf("failed writing handshake response: %v", err)
+	}
+	if err := p.authenticate(serverConn, clientPkt.Seq+1, resp.authPlugin, handshake.authData, isTLS); err != nil {
+		return err
+	}
+	return p.relay(serverConn, serverConn)
+}
+
+// authenticate handles the authentication exchange with the server, the result of
+// the authentication (OK or ERR) is sent to the client with the sequence clientSeq.
+func (p *mysqlProxy) authenticate(serverConn io.ReadWriter, clientSeq uint8, plugin string, authData []byte, isTLS bool) error {
+	for {
+		pkt, err := mysqltypes.Decode(serverConn)
+		if err != nil {
+			return fmt.Errorf("failed reading authentication packet: %v", err)
+		}
+		var response []byte
+		switch pkt.Type() {
+		case mysqltypes.PacketOKType:
+			_, err := p.clientW.Write(mysqltypes.NewPacket(clientSeq, pkt.Frame).Encode())
+			return err
+		case mysqltypes.PacketErrType:
+			_, _ = p.clientW.Write(mysqltypes.NewPacket(clientSeq, pkt.Frame).Encode())
+			if len(pkt.Frame) >= 3 && binary.LittleEndian.Uint16(pkt.Frame[1:3]) == mysqltypes.ErrAccessDeniedCode {
+				return fmt.Errorf("mysql %w: %v", ErrAuthFailed, mysqlErrMessage(pkt.Frame))
+			}
+			return fmt.Errorf("mysql authentication failed: %v", mysqlErrMessage(pkt.Frame))
+		case mysqlAuthSwitchRequest:
+			name, data, _ := bytes.Cut(pkt.Frame[1:], []byte{0x00})
+			plugin, authData = string(name), bytes.TrimSuffix(data, []byte{0x00})
+			if response, err = mysqlScramble(plugin, p.password, authData); err != nil {
+				return err
+			}
+		case mysqlAuthMoreData:
+			if plugin != mysqlCachingSHA2Password || len(pkt.Frame) < 2 {
+				return fmt.Errorf("unexpected authentication data for plugin %v", plugin)
+			}
+			switch data := pkt.Frame[1:]; {
+			case data[0] == mysqlFastAuthSuccess && len(data) == 1:
+				// the server sends an OK packet next
+				continue
+			case data[0] == mysqlPerformFullAuth && len(data) == 1:
+				response = []byte{mysqlRequestPublicKey}
+				if isTLS {
+					response = append([]byte(p.password), 0x00)
+				}
+			default:
+				// the public key of the server to encrypt the password
+				if response, err = mysqlEncryptPassword(p.password, authData, data); err != nil {
+					return err
+				}
+			}
+		default:
+			return fmt.Errorf("unexpected packet (%X) during authentication", pkt.Type())
+		}
+		if _, err := serverConn.Write(mysqltypes.NewPacket(pkt.Seq+1, response).Encode()); err != nil {
+			return fmt.Errorf("failed writing authentication response: %v", err)
+		}
+	}
+}
+
+// startTLS sends the ssl request with the capabilities of the handshake response
+// and upgrades the connection with the server to TLS
+func (p *mysqlProxy) startTLS(conn net.Conn, resp *mysqlHandshakeResponse, seq uint8) (*tls.Conn, error) {
+	resp.capabilities |= mysqlClientSSL
+	sslRequest := resp.encode()[:32]
+	if _, err := conn.Write(mysqltypes.NewPacket(seq, sslRequest).Encode()); err != nil {
+		return nil, fmt.Errorf("failed writing ssl request: %v", err)
+	}
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: p.host, InsecureSkipVerify: p.insecure})
+	ctx, cancelFn := context.WithTimeout(p.ctx, dialTimeout)
+	defer cancelFn()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("failed tls handshake with mysql server: %v", err)
+	}
+	p.setServer(tlsConn)
+	return tlsConn, nil
+}
+
+func decodeMySQLHandshake(pkt *mysqltypes.Packet) (*mysqlHandshake, error) {
+	frame := pkt.Frame
+	if len(frame) < 1 || frame[0] != 10 {
+		return nil, fmt.Errorf("unsupported mysql protocol version")
+	}
+	h := &mysqlHandshake{pkt: pkt}
+	pos := bytes.IndexByte(frame[1:], 0x00)
+	if pos == -1 {
+		return nil, fmt.Errorf("invalid initial handshake packet")
+	}
+	// server version, connection id
+	pos += 2 + 4
+	if len(frame) < pos+8+1+2 {
+		return nil, fmt.Errorf("invalid initial handshake packet")
+	}
+	h.authData = append(h.authData, frame[pos:pos+8]...)
+	pos += 8 + 1
+	h.capLowerPos = pos
+	h.capabilities = uint32(binary.LittleEndian.Uint16(frame[pos:]))
+	pos += 2
+	if len(frame) < pos+1+2+2+1+10 {
+		return nil, fmt.Errorf("mysql server does not support the protocol 4.1")
+	}
+	// charset, status flags
+	pos += 1 + 2
+	h.capUpperPos = pos
+	h.capabilities |= uint32(binary.LittleEndian.Uint16(frame[pos:])) << 16
+	pos += 2
+	authDataLen := int(frame[pos])
+	// auth plugin data length, reserved
+	pos += 1 + 10
+	if h.capabilities&mysqlClientProtocol41 == 0 || h.capabilities&mysqlClientSecureConnection == 0 {
+		return nil, fmt.Errorf("mysql server does not support the protocol 4.1")
+	}
+	size := max(13, authDataLen-8)
+	if len(frame) < pos+size {
+		return nil, fmt.Errorf("invalid initial handshake packet")
+	}
+	// the last byte is a null terminator
+	h.authData = append(h.authData, frame[pos:pos+size-1]...)
+	pos += size
+	if h.capabilities&mysqlClientPluginAuth > 0 {
+		name, _, _ := bytes.Cut(frame[pos:], []byte{0x00})
+		h.authPlugin = string(name)
+	}
+	return h, nil
+}
+
+func (h *mysqlHandshake) setCapabilities(capabilities uint32) {
+	h.capabilities = capabilities
+	binary.LittleEndian.PutUint16(h.pkt.Frame[h.capLowerPos:], uint16(capabilities))
+	binary.LittleEndian.PutUint16(h.pkt.Frame[h.capUpperPos:], uint16(capabilities>>16))
+}
+
+func decodeMySQLHandshakeResponse(frame []byte) (*mysqlHandshakeResponse, error) {
+	if len(frame) < 32 {
+		return nil, fmt.Errorf("invalid handshake response packet")
+	}
+	r := &mysqlHandshakeResponse{
+		capabilities:  binary.LittleEndian.Uint32(frame),
+		maxPacketSize: binary.LittleEndian.Uint32(frame[4:]),
+		charset:       frame[8],
+	}
+	if r.capabilities&mysqlClientProtocol41 == 0 {
+		return nil, fmt.Errorf("mysql client does not support the protocol 4.1")
+	}
+	if r.capabilities&mysqlClientSSL > 0 && len(frame) == 32 {
+		return nil, fmt.Errorf("mysql client must connect without ssl")
+	}
+	buf := bytes.NewBuffer(frame[32:])
+	username, err := buf.ReadBytes(0x00)
+	if err != nil {
+		return nil, fmt.Errorf("invalid handshake response packet, missing username")
+	}
+	r.username = string(bytes.TrimSuffix(username, []byte{0x00}))
+	switch {
+	case r.capabilities&mysqlClientPluginAuthLenencClientData > 0:
+		size, ok := readMySQLLenencInt(buf)
+		if !ok {
+			return nil, fmt.Errorf("invalid handshake response packet, invalid auth response")
+		}
+		r.authResponse = buf.Next(int(size))
+	case r.capabilities&mysqlClientSecureConnection > 0:
+		size, _ := buf.ReadByte()
+		r.authResponse = buf.Next(int(size))
+	default:
+		data, _ := buf.ReadBytes(0x00)
+		r.authResponse = bytes.TrimSuffix(data, []byte{0x00})
+	}
+	if r.capabilities&mysqlClientConnectWithDB > 0 {
+		db, _ := buf.ReadBytes(0x00)
+		r.database = string(bytes.TrimSuffix(db, []byte{0x00}))
+	}
+	if r.capabilities&mysqlClientPluginAuth > 0 {
+		name, _ := buf.ReadBytes(0x00)
+		r.authPlugin = string(bytes.TrimSuffix(name, []byte{0x00}))
+	}
+	if r.capabilities&mysqlClientConnectAttrs > 0 {
+		r.connectAttrs = buf.Bytes()
+	}
+	return r, nil
+}
+
+func (r *mysqlHandshakeResponse) encode() []byte {
+	frame := binary.LittleEndian.AppendUint32(nil, r.capabilities)
+	frame = binary.LittleEndian.AppendUint32(frame, r.maxPacketSize)
+	frame = append(frame, r.charset)
+	frame = append(frame, make([]byte, 23)...)
+	frame = append(frame, r.username...)
+	frame = append(frame, 0x00)
+	if r.capabilities&mysqlClientPluginAuthLenencClientData > 0 {
+		frame = appendMySQLLenencInt(frame, uint64(len(r.authResponse)))
+	} else {
+		frame = append(frame, byte(len(r.authResponse)))
+	}
+	frame = append(frame, r.authResponse...)
+	if r.capabilities&mysqlClientConnectWithDB > 0 {
+		frame = append(frame, r.database...)
+		frame = append(frame, 0x00)
+	}
+	if r.capabilities&mysqlClientPluginAuth > 0 {
+		frame = append(frame, r.authPlugin...)
+		frame = append(frame, 0x00)
+	}
+	if r.capabilities&mysqlClientConnectAttrs > 0 {
+		frame = append(frame, r.connectAttrs...)
+	}
+	return frame
+}
+
+func isMySQLAuthPluginSupported(plugin string) bool {
+	return plugin == mysqlNativePassword || plugin == mysqlCachingSHA2Password
+}
+
+// mysqlScramble returns the auth response of the password for an authentication plugin
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_connection_phase_authentication_methods.html
+func mysqlScramble(plugin, password string, authData []byte) ([]byte, error) {
+	if password == "" {
+		return []byte{}, nil
+	}
+	switch plugin {
+	case mysqlNativePassword:
+		// SHA1(password) XOR SHA1(authData + SHA1(SHA1(password)))
+		stage1 := sha1.Sum([]byte(password))
+		stage2 := sha1.Sum(stage1[:])
+		hash := sha1.Sum(append(append([]byte{}, authData...), stage2[:]...))
+		return xorBytes(stage1[:], hash[:]), nil
+	case mysqlCachingSHA2Password:
+		// SHA256(password) XOR SHA256(SHA256(SHA256(password)) + authData)
+		stage1 := sha256.Sum256([]byte(password))
+		stage2 := sha256.Sum256(stage1[:])
+		hash := sha256.Sum256(append(stage2[:], authData...))
+		return xorBytes(stage1[:], hash[:]), nil
+	}
+	return nil, fmt.Errorf("unsupported mysql authentication plugin %q", plugin)
+}
+
+// mysqlEncryptPassword encrypts the password with the public key of the server, it's used
+// by the full authentication of caching_sha2_password when the connection is not encrypted
+func mysqlEncryptPassword(password string, authData, pemData []byte) ([]byte, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, fmt.Errorf("failed decoding the public key of the mysql server")
+	}
+	pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		if pubKey, err = x509.ParsePKCS1PublicKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("failed parsing the public key of the mysql server: %v", err)
+		}
+	}
+	rsaPubKey, ok := pubKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("the public key of the mysql server is not a rsa key")
+	}
+	plain := append([]byte(password), 0x00)
+	for i := range plain {
+		plain[i] ^= authData[i%len(authData)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, rsaPubKey, plain, nil)
+}
+
+func mysqlErrMessage(frame []byte) string {
+	// type, error code, sql state marker and sql state
+	if len(frame) < 9 {
+		return "unknown error"
+	}
+	return string(frame[9:])
+}
+
+func readMySQLLenencInt(buf *bytes.Buffer) (uint64, bool) {
+	b, err := buf.ReadByte()
+	if err != nil {
+		return 0, false
+	}
+	var size int
+	switch b {
+	case 0xfc:
+		size = 2
+	case 0xfd:
+		size = 3
+	case 0xfe:
+		size = 8
+	default:
+		return uint64(b), true
+	}
+	data := buf.Next(size)
+	if len(data) < size {
+		return 0, false
+	}
+	var val uint64
+	for i := size - 1; i >= 0; i-- {
+		val = val<<8 | uint64(data[i])
+	}
+	return val, true
+}
+
+func appendMySQLLenencInt(dst []byte, val uint64) []byte {
+	switch {
+	case val < 0xfb:
+		return append(dst, byte(val))
+	case val < 1<<16:
+		return append(dst, 0xfc, byte(val), byte(val>>8))
+	case val < 1<<24:
+		return append(dst, 0xfd, byte(val), byte(val>>8), byte(val>>16))
+	}
+	return binary.LittleEndian.AppendUint64(append(dst, 0xfe), val)
+}
+
+func xorBytes(a, b []byte) []byte {
+	dst := make([]byte, len(a))
+	for i := range a {
+		dst[i] = a[i] ^ b[i]
+	}
+	return dst
+}
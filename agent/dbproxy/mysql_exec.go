@@ -0,0 +1,108 @@
+package dbproxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/hoophq/hoop/common/mysqltypes"
+)
+
+// utf8mb4_general_ci
+const mysqlCharsetUTF8MB4 byte = 45
+
+// MySQLExec authenticates with the server using the options of NewMySQL and executes
+// the statements in order, it returns at the first statement that fails. A statement could
+// contain many statements separated by ; when the server supports multi statements.
+// The statements must not return result sets, e.g.: CREATE USER, GRANT, DROP USER.
+func MySQLExec(ctx context.Context, opts map[string]string, statements ...string) error {
+	p, err := NewMySQL(ctx, io.Discard, opts)
+	if err != nil {
+		return err
+	}
+	defer p.Close()
+	conn, err := p.dial(p.host, p.port)
+	if err != nil {
+		return err
+	}
+	serverR := bufio.NewReader(conn)
+	pkt, err := mysqltypes.Decode(serverR)
+	if err != nil {
+		return fmt.Errorf("failed reading initial handshake: %v", err)
+	}
+	if pkt.Type() == mysqltypes.PacketErrType {
+		return fmt.Errorf("mysql server refused the connection: %v", mysqlErrMessage(pkt.Frame))
+	}
+	handshake, err := decodeMySQLHandshake(pkt)
+	if err != nil {
+		return err
+	}
+	resp := &mysqlHandshakeResponse{
+		capabilities: handshake.capabilities & (mysqlClientProtocol41 | mysqlClientSecureConnection |
+			mysqlClientPluginAuth | mysqlClientPluginAuthLenencClientData |
+			mysqlClientMultiStatements | mysqlClientMultiResults),
+		maxPacketSize: 1<<24 - 1,
+		charset:       mysqlCharsetUTF8MB4,
+		username:      p.user,
+		authPlugin:    handshake.authPlugin,
+	}
+	if !isMySQLAuthPluginSupported(resp.authPlugin) {
+		resp.authPlugin = mysqlNativePassword
+	}
+	if resp.authResponse, err = mysqlScramble(resp.authPlugin, p.password, handshake.authData); err != nil {
+		return err
+	}
+
+	var serverConn io.ReadWriter = struct {
+		io.Reader
+		io.Writer
+	}{serverR, conn}
+	seq := pkt.Seq + 1
+	isTLS := handshake.capabilities&mysqlClientSSL > 0
+	if isTLS {
+		if serverConn, err = p.startTLS(conn, resp, seq); err != nil {
+			return err
+		}
+		seq++
+	}
+	if _, err := serverConn.Write(mysqltypes.NewPacket(seq, resp.encode()).Encode()); err != nil {
+		return fmt.Errorf("failed writing handshake response: %v", err)
+	}
+	if err := p.authenticate(serverConn, seq+1, resp.authPlugin, handshake.authData, isTLS); err != nil {
+		return err
+	}
+	for _, stmt := range statements {
+		frame := append([]byte{byte(mysqltypes.ComQuery)}, stmt...)
+		if _, err := serverConn.Write(mysqltypes.NewPacket(0, frame).Encode()); err != nil {
+			return fmt.Errorf("failed writing query: %v", err)
+		}
+		if err := readMySQLExecResponses(serverConn); err != nil {
+			return err
+		}
+	}
+	// COM_QUIT
+	_, _ = serverConn.Write(mysqltypes.NewPacket(0, []byte{0x01}).Encode())
+	return nil
+}
+
+// readMySQLExecResponses reads the responses of a query, the server sends a response per
+// statement and stops at the first one that fails when the query has many statements.
+func readMySQLExecResponses(r io.Reader) error {
+	for {
+		pkt, err := mysqltypes.Decode(r)
+		if err != nil {
+			return fmt.Errorf("failed reading query response: %v", err)
+		}
+		switch pkt.Type() {
+		case mysqltypes.PacketOKType:
+			if mysqltypes.DecodeEOFStatus(pkt.Frame)&mysqltypes.ServerMoreResultsExists == 0 {
+				return nil
+			}
+		case mysqltypes.PacketErrType:
+			return fmt.Errorf("mysql: %v", mysqlErrMessage(pkt.Frame))
+		default:
+			return fmt.Errorf("unexpected response (%X) executing statement, result sets are not supported", pkt.Type())
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package dbproxy
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/hoophq/hoop/common/mysqltypes"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMySQLExecServer authenticates any user and answers the query with the responses,
+// it expects the client to enable multi statements.
+func fakeMySQLExecServer(wantQuery string, responses ...[]byte) func(conn net.Conn) error {
+	return func(conn net.Conn) error {
+		capabilities := mysqlClientProtocol41 | mysqlClientSecureConnection | mysqlClientPluginAuth |
+			mysqlClientMultiStatements | mysqlClientMultiResults
+		if _, err := conn.Write(mysqltypes.NewPacket(0, newMySQLTestHandshake(capabilities)).Encode()); err != nil {
+			return err
+		}
+		pkt, err := mysqltypes.Decode(conn)
+		if err != nil {
+			return fmt.Errorf("failed reading handshake response: %v", err)
+		}
+		if caps := binary.LittleEndian.Uint32(pkt.Frame); caps&mysqlClientMultiStatements == 0 || caps&mysqlClientMultiResults == 0 {
+			return fmt.Errorf("expected multi statements capabilities, got=%X", caps)
+		}
+		if _, err := conn.Write(mysqltypes.NewPacket(2, []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}).Encode()); err != nil {
+			return err
+		}
+		pkt, err = mysqltypes.Decode(conn)
+		if err != nil {
+			return fmt.Errorf("failed reading query: %v", err)
+		}
+		if got := string(pkt.Frame[1:]); got != wantQuery {
+			return fmt.Errorf("expected query %q, got=%q", wantQuery, got)
+		}
+		for i, resp := range responses {
+			if _, err := conn.Write(mysqltypes.NewPacket(uint8(i+1), resp).Encode()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestMySQLExecMultiStatements(t *testing.T) {
+	query := "GRANT SELECT ON app.* TO 'hoop_jane_abc'@'%'; GRANT INSERT ON app.* TO 'hoop_jane_abc'@'%'"
+	// status: autocommit(0x02) | more results exists(0x08)
+	okMoreResults := []byte{0x00, 0x00, 0x00, 0x0a, 0x00, 0x00, 0x00}
+	ok := []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}
+	for _, tt := range []struct {
+		msg       string
+		responses [][]byte
+		wantErr   string
+	}{
+		{
+			msg:       "it should read the response of every statement",
+			responses: [][]byte{okMoreResults, ok},
+		},
+		{
+			msg: "it should return the error of the statement that fails",
+			responses: [][]byte{okMoreResults,
+				mysqltypes.NewErrPacket(0, mysqltypes.ErrSpecificAccessDeniedCode, "42000", "access denied").Frame},
+			wantErr: "mysql: access denied",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			host, port, errCh := newFakeServer(t, fakeMySQLExecServer(query, tt.responses...))
+			err := MySQLExec(context.Background(), map[string]string{
+				"hostname": host, "port": port, "username": "hoop", "password": "secret"}, query)
+			waitServer(t, errCh)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package dbproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/hoophq/hoop/common/mysqltypes"
+	"github.com/stretchr/testify/assert"
+)
+
+var mysqlTestAuthData = []byte("0123456789abcdefghij")
+
+func newMySQLTestHandshake(capabilities uint32) []byte {
+	frame := append([]byte{10}, "8.0.36\x00"...)
+	frame = append(frame, 1, 0, 0, 0)
+	frame = append(frame, mysqlTestAuthData[:8]...)
+	frame = append(frame, 0x00)
+	frame = binary.LittleEndian.AppendUint16(frame, uint16(capabilities))
+	frame = append(frame, 0xff, 0x02, 0x00)
+	frame = binary.LittleEndian.AppendUint16(frame, uint16(capabilities>>16))
+	frame = append(frame, byte(len(mysqlTestAuthData)+1))
+	frame = append(frame, make([]byte, 10)...)
+	frame = append(frame, mysqlTestAuthData[8:]...)
+	frame = append(frame, 0x00)
+	return append(frame, mysqlNativePassword+"\x00"...)
+}
+
+// fakeMySQLServer authenticates the user hoop with the password secret, the
+// connection is upgraded to TLS when tlsConfig is set.
+func fakeMySQLServer(tlsConfig *tls.Config) func(conn net.Conn) error {
+	return func(conn net.Conn) error {
+		capabilities := mysqlClientProtocol41 | mysqlClientSecureConnection | mysqlClientPluginAuth
+		if tlsConfig != nil {
+			capabilities |= mysqlClientSSL
+		}
+		if _, err := conn.Write(mysqltypes.NewPacket(0, newMySQLTestHandshake(capabilities)).Encode()); err != nil {
+			return err
+		}
+		var serverConn io.ReadWriter = conn
+		seq := uint8(1)
+		if tlsConfig != nil {
+			pkt, err := mysqltypes.Decode(conn)
+			if err != nil {
+				return fmt.Errorf("failed reading ssl request: %v", err)
+			}
+			if len(pkt.Frame) != 32 || binary.LittleEndian.Uint32(pkt.Frame)&mysqlClientSSL == 0 {
+				return fmt.Errorf("expected a ssl request, got=%X", pkt.Frame)
+			}
+			tlsConn := tls.Server(conn, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return fmt.Errorf("failed tls handshake: %v", err)
+			}
+			serverConn = tlsConn
+			seq++
+		}
+		pkt, err := mysqltypes.Decode(serverConn)
+		if err != nil {
+			return fmt.Errorf("failed reading handshake response: %v", err)
+		}
+		if pkt.Seq != seq {
+			return fmt.Errorf("expected sequence %v, got=%v", seq, pkt.Seq)
+		}
+		resp, err := decodeMySQLHandshakeResponse(pkt.Frame)
+		if err != nil {
+			return err
+		}
+		authResponse, _ := mysqlScramble(mysqlNativePassword, "secret", mysqlTestAuthData)
+		if resp.username != "hoop" || !bytes.Equal(resp.authResponse, authResponse) {
+			_, _ = serverConn.Write(mysqltypes.NewErrPacket(seq+1, mysqltypes.ErrAccessDeniedCode, "28000", "access denied").Encode())
+			return nil
+		}
+		if resp.database != "mydb" {
+			return fmt.Errorf("expected database mydb, got=%v", resp.database)
+		}
+		_, err = serverConn.Write(mysqltypes.NewPacket(seq+1, []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}).Encode())
+		return err
+	}
+}
+
+func TestMySQLHandshake(t *testing.T) {
+	tlsConfig := newSelfSignedTLSConfig(t)
+	for _, tt := range []struct {
+		msg       string
+		tlsConfig *tls.Config
+		insecure  bool
+		password  string
+		wantType  mysqltypes.PacketType
+		wantErr   string
+	}{
+		{
+			msg:       "it should encrypt the connection when the server supports tls",
+			tlsConfig: tlsConfig,
+			insecure:  true,
+			password:  "secret",
+			wantType:  mysqltypes.PacketOKType,
+		},
+		{
+			msg:       "it should fail when the server certificate is not trusted",
+			tlsConfig: tlsConfig,
+			password:  "secret",
+			wantErr:   "failed tls handshake with mysql server",
+		},
+		{
+			msg:      "it should authenticate without tls when the server does not support it",
+			password: "secret",
+			wantType: mysqltypes.PacketOKType,
+		},
+		{
+			msg:      "it should report the authentication failure",
+			password: "wrong",
+			wantType: mysqltypes.PacketErrType,
+			wantErr:  "mysql authentication failed: access denied",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			host, port, errCh := newFakeServer(t, fakeMySQLServer(tt.tlsConfig))
+			clientR, clientW := newClientPipe(t)
+			opts := map[string]string{"hostname": host, "port": port, "username": "hoop", "password": tt.password, "database": "mydb"}
+			if tt.insecure {
+				opts["insecure"] = "true"
+			}
+			p, err := NewMySQL(context.Background(), clientW, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+			onErrCh := make(chan string, 1)
+			p.Run(func(_ int, errMsg string) { onErrCh <- errMsg })
+			closeOnDone(p.proxy, clientW)
+
+			pkt, err := mysqltypes.Decode(clientR)
+			if err != nil {
+				t.Fatal(err)
+			}
+			handshake, err := decodeMySQLHandshake(pkt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Zero(t, handshake.capabilities&mysqlClientSSL, "the client must not be asked to use ssl")
+
+			resp := &mysqlHandshakeResponse{
+				capabilities:  mysqlClientProtocol41 | mysqlClientSecureConnection | mysqlClientPluginAuth,
+				maxPacketSize: mysqltypes.DefaultMaxPacketSize,
+				username:      "client-user",
+				authPlugin:    mysqlNativePassword,
+			}
+			_, _ = p.Write(mysqltypes.NewPacket(1, resp.encode()).Encode())
+			if tt.tlsConfig != nil && !tt.insecure {
+				assert.Contains(t, <-onErrCh, tt.wantErr)
+				_, err := mysqltypes.Decode(clientR)
+				assert.Error(t, err)
+				return
+			}
+			pkt, err = mysqltypes.Decode(clientR)
+			if err != nil {
+				waitServer(t, errCh)
+				t.Fatal(err)
+			}
+			assert.Equal(t, uint8(2), pkt.Seq)
+			assert.Equal(t, tt.wantType, pkt.Type())
+			waitServer(t, errCh)
+			if tt.wantErr != "" {
+				assert.Equal(t, tt.wantErr, <-onErrCh)
+			}
+		})
+	}
+}
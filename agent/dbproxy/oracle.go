@@ -0,0 +1,217 @@
+package dbproxy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/common/oracletypes"
+)
+
+type oracleProxy struct {
+	*proxy
+	host        string
+	port        string
+	user        string
+	password    string
+	serviceName string
+
+	// the length of the packets is encoded with 4 bytes after the server accepts a version >= 315
+	largeSDU atomic.Bool
+
+	authMu        sync.Mutex
+	auth          *o5logon
+	authenticated atomic.Bool
+}
+
+// NewOracleDB returns a proxy that writes the server packets to clientW. The options are:
+//
+//	hostname, port, username, password - the address and credentials of the server
+//	service_name - replaces the service name (or SID) of the connect descriptor of the client
+//
+// The clients authenticate with the password "noop", the proxy translates the
+// keys of the O5LOGON authentication to the ones derived from the real password.
+// The native network encryption (ANO) of the server is not supported.
+func NewOracleDB(ctx context.Context, clientW io.Writer, opts map[string]string) (*oracleProxy, error) {
+	if opts["hostname"] == "" || opts["username"] == "" {
+		return nil, fmt.Errorf("missing hostname or username of the connection")
+	}
+	port := opts["port"]
+	if port == "" {
+		port = "1521"
+	}
+	p := &oracleProxy{
+		proxy:       newProxy(ctx, "oracledb", clientW),
+		host:        opts["hostname"],
+		port:        port,
+		user:        opts["username"],
+		password:    opts["password"],
+		serviceName: opts["service_name"],
+	}
+	p.serve = p.serveOracle
+	return p, nil
+}
+
+func (p *oracleProxy) serveOracle() error {
+	conn, err := p.dial(p.host, p.port)
+	if err != nil {
+		return err
+	}
+	errCh := make(chan error, 2)
+	go func() { errCh <- p.copyClientPackets(conn) }()
+	go func() { errCh <- p.copyServerPackets(conn) }()
+	select {
+	case err = <-errCh:
+	case <-p.ctx.Done():
+		return nil
+	}
+	if err == nil || err == io.EOF || p.ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// copyClientPackets replaces the connect descriptor and the credentials of the client packets
+func (p *oracleProxy) copyClientPackets(serverW io.Writer) error {
+	clientR := bufio.NewReader(p.clientR)
+	for {
+		pkt, err := p.decodeClientPacket(clientR)
+		if err != nil {
+			return err
+		}
+		switch pkt.Type() {
+		case oracletypes.PacketConnectType:
+			if err := p.rewriteConnect(pkt, clientR, serverW); err != nil {
+				return err
+			}
+			continue
+		case oracletypes.PacketDataType:
+			if !p.authenticated.Load() {
+				if err := p.rewriteAuthRequest(pkt); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := serverW.Write(pkt.Encode()); err != nil {
+			return fmt.Errorf("failed writing packet to server: %v", err)
+		}
+	}
+}
+
+// decodeClientPacket waits the header of the packet before decoding it, the client
+// sends the packets with the format accepted by the server after it receives the ACCEPT.
+func (p *oracleProxy) decodeClientPacket(clientR *bufio.Reader) (*oracletypes.Packet, error) {
+	if _, err := clientR.Peek(8); err != nil {
+		return nil, err
+	}
+	return oracletypes.Decode(clientR, p.largeSDU.Load())
+}
+
+// rewriteConnect replaces the service name of the connect descriptor. When the
+// descriptor is too long, the client sends it in the DATA packet after the CONNECT packet.
+func (p *oracleProxy) rewriteConnect(pkt *oracletypes.Packet, clientR *bufio.Reader, serverW io.Writer) error {
+	if p.serviceName == "" {
+		_, err := serverW.Write(pkt.Encode())
+		return err
+	}
+	if descriptor, ok := pkt.ConnectData(); ok {
+		if err := pkt.SetConnectData(oracletypes.ReplaceServiceName(descriptor, p.serviceName)); err != nil {
+			return err
+		}
+		_, err := serverW.Write(pkt.Encode())
+		return err
+	}
+	dataPkt, err := p.decodeClientPacket(clientR)
+	if err != nil {
+		return fmt.Errorf("failed reading connect data: %v", err)
+	}
+	if dataPkt.Type() != oracletypes.PacketDataType || len(dataPkt.Data) < 2 {
+		return fmt.Errorf("expected the connect data in a data packet, got=%X", dataPkt.Type())
+	}
+	descriptor := oracletypes.ReplaceServiceName(string(dataPkt.Data[2:]), p.serviceName)
+	dataPkt.Data = append(dataPkt.Data[:2:2], descriptor...)
+	if err := pkt.SetConnectDataLength(len(descriptor)); err != nil {
+		return err
+	}
+	if _, err := serverW.Write(pkt.Encode()); err != nil {
+		return err
+	}
+	_, err = serverW.Write(dataPkt.Encode())
+	return err
+}
+
+func (p *oracleProxy) rewriteAuthRequest(pkt *oracletypes.Packet) error {
+	req, err := oracletypes.DecodeAuthRequest(pkt.TTCMessage())
+	if err != nil || req == nil {
+		// it's not an authentication packet
+		return nil
+	}
+	req.Username = p.user
+	if req.Function == oracletypes.FuncAuthPhaseTwo {
+		p.authMu.Lock()
+		auth := p.auth
+		p.authMu.Unlock()
+		if auth == nil {
+			return fmt.Errorf("received the second phase of the authentication before the server keys")
+		}
+		if err := auth.transcodeClientAuth(req); err != nil {
+			return err
+		}
+		p.authenticated.Store(true)
+	}
+	pkt.Data = append(pkt.Data[:2:2], req.Encode()...)
+	return nil
+}
+
+// copyServerPackets negotiates the format of the packets and replaces the session key of the server
+func (p *oracleProxy) copyServerPackets(serverR io.Reader) error {
+	for {
+		pkt, err := oracletypes.Decode(serverR, p.largeSDU.Load())
+		if err != nil {
+			return err
+		}
+		switch pkt.Type() {
+		case oracletypes.PacketAcceptType:
+			if pkt.Version() >= oracletypes.LargeSDUVersion {
+				p.largeSDU.Store(true)
+			}
+		case oracletypes.PacketRedirectType:
+			log.Warnf("oracledb server redirected the connection, redirects are not supported by the proxy")
+		case oracletypes.PacketDataType:
+			if !p.authenticated.Load() {
+				if err := p.rewriteServerKeys(pkt); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := p.clientW.Write(pkt.Encode()); err != nil {
+			return fmt.Errorf("failed writing packet to client: %v", err)
+		}
+	}
+}
+
+func (p *oracleProxy) rewriteServerKeys(pkt *oracletypes.Packet) error {
+	params, err := oracletypes.DecodeReturnParameters(pkt.TTCMessage())
+	if err != nil || params == nil {
+		return nil
+	}
+	if _, ok := params.KeyVals.Get(oracletypes.AuthSessionKey); !ok {
+		return nil
+	}
+	auth, err := newO5Logon(params, p.password)
+	if err != nil {
+		return err
+	}
+	if err := auth.transcodeServerSessionKey(params); err != nil {
+		return err
+	}
+	p.authMu.Lock()
+	p.auth = auth
+	p.authMu.Unlock()
+	pkt.Data = append(pkt.Data[:2:2], params.Encode()...)
+	return nil
+}
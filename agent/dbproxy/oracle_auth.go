@@ -0,0 +1,257 @@
+package dbproxy
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hoophq/hoop/common/oracletypes"
+)
+
+// oracleClientPassword is the password that the clients use to authenticate with the proxy
+const oracleClientPassword = "noop"
+
+// o5logon translates the keys of the O5LOGON authentication between the client and the server.
+//
+// The server sends its session key encrypted with a key derived from the password (verifier), the
+// client decrypts it and sends its own session key encrypted with the same key. Both session keys
+// derive the key that encrypts the password. The proxy decrypts the session keys with the verifier
+// of the password of each side, thus both sides share the same session keys and the password is
+// replaced by the real one encrypted with the combined key.
+type o5logon struct {
+	verifierType uint32
+	salt         []byte
+	cskSalt      []byte
+	vgenCount    int
+	sderCount    int
+
+	serverVerifier []byte
+	serverSpeedy   []byte
+	clientVerifier []byte
+	clientSpeedy   []byte
+	password       string
+
+	// the decrypted session key of the server
+	serverSessionKey []byte
+}
+
+func newO5Logon(params *oracletypes.ReturnParameters, password string) (*o5logon, error) {
+	vfrData, ok := params.KeyVals.Get(oracletypes.AuthVerifierData)
+	if !ok {
+		return nil, fmt.Errorf("oracledb server did not send the verifier data")
+	}
+	if vfrData.Flag != oracletypes.VerifierType11g && vfrData.Flag != oracletypes.VerifierType12c {
+		return nil, fmt.Errorf("oracledb verifier type %v is not supported, the password must use the 11g or 12c verifier", vfrData.Flag)
+	}
+	salt, err := hex.DecodeString(string(vfrData.Value))
+	if err != nil {
+		return nil, fmt.Errorf("invalid oracledb verifier data: %v", err)
+	}
+	a := &o5logon{
+		verifierType: vfrData.Flag,
+		salt:         salt,
+		vgenCount:    4096,
+		sderCount:    3,
+		password:     password,
+	}
+	if kv, ok := params.KeyVals.Get(oracletypes.AuthPBKDF2CSKSalt); ok {
+		if a.cskSalt, err = hex.DecodeString(string(kv.Value)); err != nil {
+			return nil, fmt.Errorf("invalid oracledb pbkdf2 salt: %v", err)
+		}
+	}
+	if kv, ok := params.KeyVals.Get(oracletypes.AuthPBKDF2VgenCount); ok {
+		if a.vgenCount, err = strconv.Atoi(string(kv.Value)); err != nil || a.vgenCount < 1 {
+			return nil, fmt.Errorf("invalid oracledb pbkdf2 vgen count %q", kv.Value)
+		}
+	}
+	if kv, ok := params.KeyVals.Get(oracletypes.AuthPBKDF2SderCount); ok {
+		if a.sderCount, err = strconv.Atoi(string(kv.Value)); err != nil || a.sderCount < 1 {
+			return nil, fmt.Errorf("invalid oracledb pbkdf2 sder count %q", kv.Value)
+		}
+	}
+	a.serverVerifier, a.serverSpeedy = a.verifierKey(password)
+	a.clientVerifier, a.clientSpeedy = a.verifierKey(oracleClientPassword)
+	return a, nil
+}
+
+// verifierKey derives the key that encrypts the session keys from the password
+func (a *o5logon) verifierKey(password string) (key, speedyKey []byte) {
+	if a.verifierType == oracletypes.VerifierType11g {
+		hash := sha1.Sum(append([]byte(password), a.salt...))
+		return append(hash[:], 0, 0, 0, 0), nil
+	}
+	salt := append(append([]byte{}, a.salt...), oracletypes.AuthPBKDF2SpeedyKey...)
+	speedyKey = pbkdf2(sha512.New, []byte(password), salt, a.vgenCount)
+	hash := sha512.Sum512(append(append([]byte{}, speedyKey...), a.salt...))
+	return hash[:32], speedyKey
+}
+
+// transcodeServerSessionKey encrypts the session key of the server with the verifier of the client password
+func (a *o5logon) transcodeServerSessionKey(params *oracletypes.ReturnParameters) error {
+	kv, _ := params.KeyVals.Get(oracletypes.AuthSessionKey)
+	sessionKey, err := transcodeOracleKey(kv.Value, a.serverVerifier, a.clientVerifier)
+	if err != nil {
+		return fmt.Errorf("failed decoding server session key: %v", err)
+	}
+	a.serverSessionKey = sessionKey.plain
+	kv.Value = sessionKey.encoded
+	return nil
+}
+
+// transcodeClientAuth encrypts the session key of the client with the verifier of the
+// real password and replaces the password of the client with the real one.
+func (a *o5logon) transcodeClientAuth(req *oracletypes.AuthRequest) error {
+	sessKV, ok := req.KeyVals.Get(oracletypes.AuthSessionKey)
+	if !ok {
+		return fmt.Errorf("oracledb client did not send the session key")
+	}
+	passwordKV, ok := req.KeyVals.Get(oracletypes.AuthPassword)
+	if !ok {
+		return fmt.Errorf("oracledb client did not send the password")
+	}
+	sessionKey, err := transcodeOracleKey(sessKV.Value, a.clientVerifier, a.serverVerifier)
+	if err != nil {
+		return fmt.Errorf("failed decoding client session key: %v", err)
+	}
+	sessKV.Value = sessionKey.encoded
+
+	clientPassword, err := hex.DecodeString(string(passwordKV.Value))
+	if err != nil || len(clientPassword) < aes.BlockSize {
+		return fmt.Errorf("invalid oracledb client password")
+	}
+	var combinedKey []byte
+	for _, key := range a.combinedKeys(sessionKey.plain) {
+		encrypted, err := encryptOraclePassword(key, clientPassword, len(oracleClientPassword), []byte(oracleClientPassword))
+		if err == nil && bytes.Equal(encrypted, clientPassword) {
+			combinedKey = key
+			break
+		}
+	}
+	if combinedKey == nil {
+		return fmt.Errorf("failed validating the password of the client, the client must authenticate with the password %q", oracleClientPassword)
+	}
+	password, err := encryptOraclePassword(combinedKey, clientPassword, len(oracleClientPassword), []byte(a.password))
+	if err != nil {
+		return err
+	}
+	passwordKV.Value = []byte(strings.ToUpper(hex.EncodeToString(password)))
+
+	if kv, ok := req.KeyVals.Get(oracletypes.AuthPBKDF2SpeedyKey); ok && a.serverSpeedy != nil {
+		clientSpeedy, err := hex.DecodeString(string(kv.Value))
+		if err != nil || len(clientSpeedy) < aes.BlockSize {
+			return fmt.Errorf("invalid oracledb client speedy key")
+		}
+		speedyKey, err := encryptOraclePassword(combinedKey, clientSpeedy, len(a.clientSpeedy), a.serverSpeedy)
+		if err != nil {
+			return err
+		}
+		kv.Value = []byte(strings.ToUpper(hex.EncodeToString(speedyKey)))
+	}
+	return nil
+}
+
+// combinedKeys returns the candidates of the key that encrypts the password. The
+// clients differ on how the session keys are padded, the proxy validates each
+// candidate with the password of the client.
+func (a *o5logon) combinedKeys(clientSessionKey []byte) (keys [][]byte) {
+	for _, clientKey := range unpaddedCandidates(clientSessionKey) {
+		for _, serverKey := range unpaddedCandidates(a.serverSessionKey) {
+			if a.cskSalt != nil {
+				size, c, s := 32, clientKey, serverKey
+				if a.verifierType == oracletypes.VerifierType11g {
+					size, c, s = 24, c[:min(24, len(c))], s[:min(24, len(s))]
+				}
+				password := strings.ToUpper(hex.EncodeToString(append(append([]byte{}, c...), s...)))
+				keys = append(keys, pbkdf2(sha512.New, []byte(password), a.cskSalt, a.sderCount)[:size])
+			}
+			if a.verifierType == oracletypes.VerifierType11g && len(clientKey) >= 40 && len(serverKey) >= 40 {
+				buf := make([]byte, 24)
+				for i := range buf {
+					buf[i] = clientKey[16+i] ^ serverKey[16+i]
+				}
+				part1, part2 := md5.Sum(buf[:16]), md5.Sum(buf[16:])
+				keys = append(keys, append(part1[:], part2[:]...)[:24])
+			}
+		}
+	}
+	return
+}
+
+// unpaddedCandidates returns the key with and without its PKCS#5 padding
+func unpaddedCandidates(key []byte) [][]byte {
+	candidates := [][]byte{key}
+	if n := int(key[len(key)-1]); n > 0 && n <= aes.BlockSize && n < len(key) &&
+		bytes.Equal(key[len(key)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		candidates = append(candidates, key[:len(key)-n])
+	}
+	return candidates
+}
+
+type oracleKey struct {
+	plain   []byte
+	encoded []byte
+}
+
+// transcodeOracleKey decrypts a session key encoded in hex and encrypts it with another key
+func transcodeOracleKey(encoded, decryptKey, encryptKey []byte) (*oracleKey, error) {
+	data, err := hex.DecodeString(string(encoded))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aesCBC(decryptKey, data, false)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := aesCBC(encryptKey, plain, true)
+	if err != nil {
+		return nil, err
+	}
+	return &oracleKey{plain: plain, encoded: []byte(strings.ToUpper(hex.EncodeToString(encrypted)))}, nil
+}
+
+// encryptOraclePassword encrypts the data with the random prefix of the encrypted value of the client,
+// clientDataLen is the length of the data encrypted by the client. The clients may truncate the
+// padding of the encrypted value, the result follows the same format.
+func encryptOraclePassword(key, clientValue []byte, clientDataLen int, data []byte) ([]byte, error) {
+	prefix, err := aesCBC(key, clientValue[:aes.BlockSize], false)
+	if err != nil {
+		return nil, err
+	}
+	plain := append(prefix, data...)
+	padding := aes.BlockSize - len(plain)%aes.BlockSize
+	encrypted, err := aesCBC(key, append(plain, bytes.Repeat([]byte{byte(padding)}, padding)...), true)
+	if err != nil {
+		return nil, err
+	}
+	// the client sends the value without the padding
+	if len(clientValue) == len(prefix)+clientDataLen {
+		return encrypted[:len(plain)], nil
+	}
+	return encrypted, nil
+}
+
+// aesCBC encrypts or decrypts the data with a zero IV, the data must be aligned with the block size
+func aesCBC(key, data []byte, encrypt bool) ([]byte, error) {
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid length of encrypted data (%v)", len(data))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(data))
+	iv := make([]byte, aes.BlockSize)
+	if encrypt {
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(out, data)
+	} else {
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	}
+	return out, nil
+}
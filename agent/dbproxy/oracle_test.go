@@ -0,0 +1,201 @@
+package dbproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hoophq/hoop/common/oracletypes"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	oracleTestSalt    = bytes.Repeat([]byte{0xab}, 16)
+	oracleTestCSKSalt = bytes.Repeat([]byte{0xcd}, 32)
+	// the session keys don't end with a padding
+	oracleTestServerKey = bytes.Repeat([]byte{0x31}, 48)
+	oracleTestClientKey = bytes.Repeat([]byte{0x42}, 48)
+)
+
+func newOracleTestConnect(descriptor string) *oracletypes.Packet {
+	data := make([]byte, 50)
+	// version 318, the length (16) and the offset (18) of the connect data
+	data[0], data[1] = 0x01, 0x3e
+	data[17] = byte(len(descriptor))
+	data[19] = byte(len(data) + 8)
+	return oracletypes.NewPacket(oracletypes.PacketConnectType, append(data, descriptor...), false)
+}
+
+func newOracleTestData(msg []byte) *oracletypes.Packet {
+	return oracletypes.NewPacket(oracletypes.PacketDataType, append([]byte{0x00, 0x00}, msg...), true)
+}
+
+// oracleTestVerifier returns the verifier (12c) of the password
+func oracleTestVerifier(password string) []byte {
+	key, _ := (&o5logon{verifierType: oracletypes.VerifierType12c, salt: oracleTestSalt, vgenCount: 4096}).verifierKey(password)
+	return key
+}
+
+// oracleTestCombinedKey returns the key that encrypts the password
+func oracleTestCombinedKey() []byte {
+	password := strings.ToUpper(hex.EncodeToString(append(append([]byte{}, oracleTestClientKey...), oracleTestServerKey...)))
+	return pbkdf2(sha512.New, []byte(password), oracleTestCSKSalt, 3)[:32]
+}
+
+func mustAESCBC(t *testing.T, key, data []byte, encrypt bool) []byte {
+	t.Helper()
+	out, err := aesCBC(key, data, encrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func encryptOracleTestPassword(t *testing.T, password string) []byte {
+	plain := append(bytes.Repeat([]byte{0x07}, 16), password...)
+	padding := 16 - len(plain)%16
+	plain = append(plain, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	return []byte(strings.ToUpper(hex.EncodeToString(mustAESCBC(t, oracleTestCombinedKey(), plain, true))))
+}
+
+// fakeOracleServer accepts the service ORCL and authenticates the user hoop with the password secret (O5LOGON 12c)
+func fakeOracleServer(t *testing.T) func(conn net.Conn) error {
+	return func(conn net.Conn) error {
+		pkt, err := oracletypes.Decode(conn, false)
+		if err != nil {
+			return err
+		}
+		if descriptor, _ := pkt.ConnectData(); !strings.Contains(descriptor, "(SERVICE_NAME=ORCL)") {
+			return fmt.Errorf("expected the service name ORCL, got=%q", descriptor)
+		}
+		// version 315, the next packets use large session data units
+		accept := oracletypes.NewPacket(oracletypes.PacketAcceptType, append([]byte{0x01, 0x3b}, make([]byte, 30)...), false)
+		if _, err := conn.Write(accept.Encode()); err != nil {
+			return err
+		}
+
+		pkt, err = oracletypes.Decode(conn, true)
+		if err != nil {
+			return err
+		}
+		req, err := oracletypes.DecodeAuthRequest(pkt.TTCMessage())
+		if err != nil || req == nil || req.Function != oracletypes.FuncAuthPhaseOne || req.Username != "hoop" {
+			return fmt.Errorf("expected the first phase of the authentication of hoop, got=%+v, err=%v", req, err)
+		}
+		sessionKey := mustAESCBC(t, oracleTestVerifier("secret"), oracleTestServerKey, true)
+		params := &oracletypes.ReturnParameters{KeyVals: oracletypes.KeyVals{
+			{Key: oracletypes.AuthSessionKey, Value: []byte(strings.ToUpper(hex.EncodeToString(sessionKey)))},
+			{Key: oracletypes.AuthVerifierData, Value: []byte(strings.ToUpper(hex.EncodeToString(oracleTestSalt))), Flag: oracletypes.VerifierType12c},
+			{Key: oracletypes.AuthPBKDF2CSKSalt, Value: []byte(strings.ToUpper(hex.EncodeToString(oracleTestCSKSalt)))},
+			{Key: oracletypes.AuthPBKDF2VgenCount, Value: []byte("4096")},
+			{Key: oracletypes.AuthPBKDF2SderCount, Value: []byte("3")},
+		}}
+		if _, err := conn.Write(newOracleTestData(params.Encode()).Encode()); err != nil {
+			return err
+		}
+
+		pkt, err = oracletypes.Decode(conn, true)
+		if err != nil {
+			return err
+		}
+		req, err = oracletypes.DecodeAuthRequest(pkt.TTCMessage())
+		if err != nil || req == nil || req.Function != oracletypes.FuncAuthPhaseTwo || req.Username != "hoop" {
+			return fmt.Errorf("expected the second phase of the authentication of hoop, got=%+v, err=%v", req, err)
+		}
+		sessKV, _ := req.KeyVals.Get(oracletypes.AuthSessionKey)
+		encSessionKey, _ := hex.DecodeString(string(sessKV.Value))
+		if clientKey := mustAESCBC(t, oracleTestVerifier("secret"), encSessionKey, false); !bytes.Equal(clientKey, oracleTestClientKey) {
+			return fmt.Errorf("the client session key must be encrypted with the verifier of the password")
+		}
+		passwordKV, _ := req.KeyVals.Get(oracletypes.AuthPassword)
+		encPassword, _ := hex.DecodeString(string(passwordKV.Value))
+		plain := mustAESCBC(t, oracleTestCombinedKey(), encPassword, false)
+		if password := plain[16 : len(plain)-int(plain[len(plain)-1])]; string(password) != "secret" {
+			return fmt.Errorf("expected the password of the connection, got=%q", password)
+		}
+		_, err = conn.Write(newOracleTestData([]byte("auth-ok")).Encode())
+		return err
+	}
+}
+
+func TestOracleHandshake(t *testing.T) {
+	for _, tt := range []struct {
+		msg            string
+		clientPassword string
+		wantErr        string
+	}{
+		{
+			msg:            "it should authenticate with the password of the connection",
+			clientPassword: oracleClientPassword,
+		},
+		{
+			msg:            "it should fail when the client does not use the proxy password",
+			clientPassword: "other",
+			wantErr:        `failed validating the password of the client, the client must authenticate with the password "noop"`,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			host, port, errCh := newFakeServer(t, fakeOracleServer(t))
+			clientR, clientW := newClientPipe(t)
+			p, err := NewOracleDB(context.Background(), clientW, map[string]string{
+				"hostname": host, "port": port, "username": "hoop", "password": "secret", "service_name": "ORCL"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+			onErrCh := make(chan string, 1)
+			p.Run(func(_ int, errMsg string) { onErrCh <- errMsg })
+			closeOnDone(p.proxy, clientW)
+
+			_, _ = p.Write(newOracleTestConnect("(DESCRIPTION=(CONNECT_DATA=(SERVICE_NAME=client)))").Encode())
+			pkt, err := oracletypes.Decode(clientR, false)
+			if err != nil {
+				waitServer(t, errCh)
+				t.Fatal(err)
+			}
+			assert.Equal(t, oracletypes.PacketAcceptType, pkt.Type())
+
+			phaseOne := &oracletypes.AuthRequest{Function: oracletypes.FuncAuthPhaseOne, Seq: 1, Username: "client-user"}
+			_, _ = p.Write(newOracleTestData(phaseOne.Encode()).Encode())
+			pkt, err = oracletypes.Decode(clientR, true)
+			if err != nil {
+				waitServer(t, errCh)
+				t.Fatal(err)
+			}
+			params, err := oracletypes.DecodeReturnParameters(pkt.TTCMessage())
+			if err != nil || params == nil {
+				t.Fatalf("expected the return parameters, err=%v", err)
+			}
+			sessKV, _ := params.KeyVals.Get(oracletypes.AuthSessionKey)
+			encSessionKey, _ := hex.DecodeString(string(sessKV.Value))
+			serverKey := mustAESCBC(t, oracleTestVerifier(oracleClientPassword), encSessionKey, false)
+			assert.Equal(t, oracleTestServerKey, serverKey, "the server session key must be encrypted with the verifier of the proxy password")
+
+			clientSessionKey := mustAESCBC(t, oracleTestVerifier(oracleClientPassword), oracleTestClientKey, true)
+			phaseTwo := &oracletypes.AuthRequest{Function: oracletypes.FuncAuthPhaseTwo, Seq: 2, Username: "client-user", KeyVals: oracletypes.KeyVals{
+				{Key: oracletypes.AuthSessionKey, Value: []byte(strings.ToUpper(hex.EncodeToString(clientSessionKey)))},
+				{Key: oracletypes.AuthPassword, Value: encryptOracleTestPassword(t, tt.clientPassword)},
+			}}
+			_, _ = p.Write(newOracleTestData(phaseTwo.Encode()).Encode())
+			if tt.wantErr != "" {
+				assert.Equal(t, tt.wantErr, <-onErrCh)
+				_, err := oracletypes.Decode(clientR, true)
+				assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+				return
+			}
+			pkt, err = oracletypes.Decode(clientR, true)
+			if err != nil {
+				waitServer(t, errCh)
+				t.Fatal(err)
+			}
+			assert.Equal(t, "auth-ok", string(pkt.TTCMessage()))
+			waitServer(t, errCh)
+		})
+	}
+}
@@ -0,0 +1,638 @@
+package dbproxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/common/pgtypes"
+)
+
+const (
+	// PGPoolModeTransaction shares the server connections between the clients,
+	// a server connection is assigned to a client for the duration of a transaction.
+	PGPoolModeTransaction = "transaction"
+	// PGPoolModeSession assigns a server connection for the duration of the client connection,
+	// the connection returns to the pool when the client disconnects
+	PGPoolModeSession = "session"
+
+	defaultPGPoolSize         = 10
+	pgPoolIdleTimeout         = time.Minute * 5
+	pgPoolIdleCheckInterval   = time.Minute
+	pgPoolAcquireTimeout      = time.Second * 30
+	pgServerParameterStatus   = 'S'
+	pgServerBackendKeyData    = 'K'
+	pgServerReadyForQuery     = 'Z'
+	pgCancelRequestPacketSize = 16
+)
+
+var (
+	pgPoolsMu sync.Mutex
+	pgPools   = map[string]*pgPool{}
+	// pgPoolSessionParams are the startup parameters of the clients that change the behavior of the
+	// session, they are sent to the server connections. The other parameters (e.g.: application_name)
+	// are not forwarded, the clients with the same session parameters share the pool.
+	pgPoolSessionParams = []string{"client_encoding", "datestyle", "timezone", "intervalstyle",
+		"extra_float_digits", "search_path", "options"}
+	// pgPoolClients are the clients of all pools by their process id, the cancel
+	// requests don't inform the database, thus they could be routed to any pool
+	pgPoolClients = map[uint32]*pgPooledProxy{}
+)
+
+// pgServerConn is an authenticated connection with the server that is shared by the clients
+type pgServerConn struct {
+	net.Conn
+	r         *bufio.Reader
+	keyData   pgtypes.BackendKeyData
+	idleSince time.Time
+}
+
+// pgPool keeps the authenticated connections of a server, it allows up to size
+// connections open at the same time. The idle connections are closed after pgPoolIdleTimeout,
+// the pool is removed when it doesn't have clients and connections.
+type pgPool struct {
+	key string
+	cfg pgServerConfig
+	// startupParams are the parameters of the clients sent in the startup message of the connections
+	startupParams map[string]string
+	sem           chan struct{}
+	// clients is the number of clients using the pool, it's guarded by pgPoolsMu
+	clients int
+	// stop is closed when the pool is removed
+	stop chan struct{}
+
+	mu     sync.Mutex
+	idle   []*pgServerConn
+	params [][]byte
+}
+
+// getPGPool returns the pool of the server, the pools are shared by all sessions with the same
+// address, credentials and startup parameters. The size of the pool is defined when it's created.
+// The client must call releasePGPool when it stops using the pool.
+func getPGPool(cfg pgServerConfig, startupParams map[string]string, size int) *pgPool {
+	hasher := sha256.New()
+	_, _ = fmt.Fprintf(hasher, "%s:%s:%s:%s:%s:%s:%s",
+		cfg.host, cfg.port, cfg.user, cfg.password, cfg.database, cfg.sslMode, cfg.sslRootCert)
+	keys := make([]string, 0, len(startupParams))
+	for key := range startupParams {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(hasher, ":%s=%s", key, startupParams[key])
+	}
+	key := hex.EncodeToString(hasher.Sum(nil))
+	pgPoolsMu.Lock()
+	defer pgPoolsMu.Unlock()
+	if pool, ok := pgPools[key]; ok {
+		pool.clients++
+		return pool
+	}
+	pool := &pgPool{
+		key:           key,
+		cfg:           cfg,
+		startupParams: startupParams,
+		sem:           make(chan struct{}, size),
+		clients:       1,
+		stop:          make(chan struct{}),
+	}
+	pgPools[key] = pool
+	go pool.closeIdleConnections(pgPoolIdleCheckInterval)
+	return pool
+}
+
+func releasePGPool(pool *pgPool) {
+	pgPoolsMu.Lock()
+	pool.clients--
+	pgPoolsMu.Unlock()
+}
+
+// acquire returns an idle connection of the pool or opens a new one, it blocks
+// until a connection is available when the pool has reached its size.
+func (p *pgPool) acquire(ctx context.Context) (*pgServerConn, error) {
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timeout waiting for an available connection of the pool (size=%v)", cap(p.sem))
+	}
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		conn := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return conn, nil
+	}
+	p.mu.Unlock()
+	conn, err := p.connect(ctx)
+	if err != nil {
+		<-p.sem
+		return nil, err
+	}
+	return conn, nil
+}
+
+// release returns a connection without an open transaction to the pool
+func (p *pgPool) release(conn *pgServerConn) {
+	conn.idleSince = time.Now()
+	p.mu.Lock()
+	p.idle = append(p.idle, conn)
+	p.mu.Unlock()
+	<-p.sem
+}
+
+// reset discards the session state of a connection before returning it to the pool, the connections
+// are shared by the clients of distinct users. The connection is closed when the reset fails.
+func (p *pgPool) reset(conn *pgServerConn) {
+	if err := conn.discardAll(); err != nil {
+		log.Infof("postgres pool - failed resetting server connection, reason=%v", err)
+		p.discard(conn)
+		return
+	}
+	p.release(conn)
+}
+
+// discard closes a connection that is in an unknown state, e.g.: the client disconnected in the middle of a transaction
+func (p *pgPool) discard(conn *pgServerConn) {
+	_ = conn.Close()
+	<-p.sem
+}
+
+func (p *pgPool) dial(ctx context.Context) (net.Conn, error) {
+	addr := net.JoinHostPort(p.cfg.host, p.cfg.port)
+	ctx, cancelFn := context.WithTimeout(ctx, dialTimeout)
+	defer cancelFn()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed connecting with postgres server %v, reason=%v", addr, err)
+	}
+	tlsConn, err := negotiatePGTLS(ctx, conn, p.cfg)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// connect opens and authenticates a new connection reading the startup messages of the server.
+// The parameters of the first connection are sent to the clients when they connect to the pool.
+func (p *pgPool) connect(ctx context.Context) (*pgServerConn, error) {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	serverConn := &pgServerConn{Conn: conn, r: bufio.NewReader(conn)}
+	params, err := p.readStartup(serverConn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	p.mu.Lock()
+	if p.params == nil {
+		p.params = params
+	}
+	p.mu.Unlock()
+	return serverConn, nil
+}
+
+func (p *pgPool) readStartup(conn *pgServerConn) (params [][]byte, err error) {
+	startupParams := maps.Clone(p.startupParams)
+	startupParams["database"] = p.cfg.database
+	if err := p.cfg.authenticate(io.Discard, conn, conn.r, startupParams); err != nil {
+		return nil, err
+	}
+	for {
+		typ, frame, err := readPGMessage(conn.r)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading startup message: %v", err)
+		}
+		switch typ {
+		case pgServerParameterStatus:
+			params = append(params, pgtypes.NewPacket(pgtypes.PacketType(typ), frame).Encode())
+		case pgServerBackendKeyData:
+			if len(frame) < 8 {
+				return nil, fmt.Errorf("invalid backend key data message")
+			}
+			conn.keyData = pgtypes.BackendKeyData{
+				Pid:       binary.BigEndian.Uint32(frame[:4]),
+				SecretKey: binary.BigEndian.Uint32(frame[4:8]),
+			}
+		case byte(pgtypes.ServerErrorResponse):
+			return nil, fmt.Errorf("postgres startup failed: %v", errorResponseMessage(frame))
+		case pgServerReadyForQuery:
+			return params, nil
+		}
+	}
+}
+
+// registerPGPoolClient generates the key data of a client, the process
+// id is unique in the agent and it's used to route the cancel requests.
+func registerPGPoolClient(client *pgPooledProxy) {
+	pgPoolsMu.Lock()
+	defer pgPoolsMu.Unlock()
+	for {
+		var data [8]byte
+		_, _ = rand.Read(data[:])
+		keyData := pgtypes.BackendKeyData{
+			Pid:       binary.BigEndian.Uint32(data[:4]) & 0x7fffffff,
+			SecretKey: binary.BigEndian.Uint32(data[4:]),
+		}
+		if _, ok := pgPoolClients[keyData.Pid]; ok || keyData.Pid == 0 {
+			continue
+		}
+		client.keyData = keyData
+		pgPoolClients[keyData.Pid] = client
+		return
+	}
+}
+
+func unregisterPGPoolClient(pid uint32) {
+	pgPoolsMu.Lock()
+	defer pgPoolsMu.Unlock()
+	delete(pgPoolClients, pid)
+}
+
+// cancelPGPoolClient sends a cancel request to the server connection in use by the client of the
+// key data. The request is ignored when the client is not running a statement or when the client
+// is connected to another server.
+func cancelPGPoolClient(ctx context.Context, cfg pgServerConfig, pid, secretKey uint32) error {
+	pgPoolsMu.Lock()
+	client := pgPoolClients[pid]
+	pgPoolsMu.Unlock()
+	if client == nil || client.keyData.SecretKey != secretKey ||
+		client.pool.cfg.host != cfg.host || client.pool.cfg.port != cfg.port || client.pool.cfg.user != cfg.user {
+		log.Infof("postgres pool - cancel request of unknown process %v", pid)
+		return nil
+	}
+	server := client.currentServer()
+	if server == nil {
+		log.Infof("postgres pool - process %v does not have a server connection, nothing to cancel", pid)
+		return nil
+	}
+	conn, err := client.pool.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	cancelRequest := binary.BigEndian.AppendUint32(nil, pgCancelRequestPacketSize)
+	cancelRequest = binary.BigEndian.AppendUint32(cancelRequest, pgtypes.ClientCancelRequestMessage)
+	cancelRequest = binary.BigEndian.AppendUint32(cancelRequest, server.keyData.Pid)
+	cancelRequest = binary.BigEndian.AppendUint32(cancelRequest, server.keyData.SecretKey)
+	_, err = conn.Write(cancelRequest)
+	log.Infof("postgres pool - cancel request of process %v routed to server process %v", pid, server.keyData.Pid)
+	return err
+}
+
+// discardAll runs DISCARD ALL and waits for the server to be idle, it resets the settings (SET ROLE,
+// search_path), prepared statements, listeners, temporary tables and advisory locks of the session.
+func (c *pgServerConn) discardAll() error {
+	_ = c.SetDeadline(time.Now().Add(dialTimeout))
+	defer func() { _ = c.SetDeadline(time.Time{}) }()
+	query := pgtypes.NewPacket(pgtypes.ClientSimpleQuery, append([]byte("DISCARD ALL"), 0x00))
+	if _, err := c.Write(query.Encode()); err != nil {
+		return fmt.Errorf("failed writing discard query: %v", err)
+	}
+	var queryErr error
+	for {
+		typ, frame, err := readPGMessage(c.r)
+		if err != nil {
+			return fmt.Errorf("failed reading discard response: %v", err)
+		}
+		switch typ {
+		case byte(pgtypes.ServerErrorResponse):
+			queryErr = fmt.Errorf("failed discarding session state: %v", errorResponseMessage(frame))
+		case pgServerReadyForQuery:
+			if queryErr != nil {
+				return queryErr
+			}
+			if len(frame) != 1 || frame[0] != pgtypes.ServerIdle {
+				return fmt.Errorf("server connection is not idle after discarding the session state")
+			}
+			return nil
+		}
+	}
+}
+
+func (p *pgPool) closeIdleConnections(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.closeIdle(pgPoolIdleTimeout)
+		}
+	}
+}
+
+// closeIdle closes the connections idle for longer than the timeout, the pool
+// is removed and stopped when it doesn't have clients and connections.
+func (p *pgPool) closeIdle(timeout time.Duration) {
+	pgPoolsMu.Lock()
+	defer pgPoolsMu.Unlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var active []*pgServerConn
+	for _, conn := range p.idle {
+		if time.Since(conn.idleSince) > timeout {
+			_ = conn.Close()
+			continue
+		}
+		active = append(active, conn)
+	}
+	p.idle = active
+	if p.clients > 0 || len(p.idle) > 0 || len(p.sem) > 0 {
+		return
+	}
+	if pgPools[p.key] == p {
+		delete(pgPools, p.key)
+	}
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+}
+
+// pgPooledProxy authenticates the client with the parameters of the pool and assigns a server
+// connection for each transaction. The server connection returns to the pool when the server
+// informs it's idle and there are no pending messages of the client.
+//
+// The session state (SET, LISTEN, prepared statements by name) is not kept between transactions,
+// it's discarded before the server connection returns to the pool.
+//
+// In the session mode, the server connection is assigned when the client connects and
+// it returns to the pool when the client disconnects, the session state is kept.
+type pgPooledProxy struct {
+	*pgProxy
+	poolSize    int
+	sessionMode bool
+	pool        *pgPool
+	keyData     pgtypes.BackendKeyData
+
+	serverMu sync.Mutex
+	server   *pgServerConn
+	// reading indicates the messages of the server are being relayed to the client, in the session
+	// mode the server connection is kept by the client without being read when the server is idle
+	reading bool
+	// pending is the number of queries and sync messages waiting for a ReadyForQuery
+	pending int
+	// unsynced indicates messages of the extended protocol were sent without a sync message
+	unsynced bool
+}
+
+// NewPostgresPool returns a proxy that shares the connections with the server, besides
+// the options of NewPostgres, it accepts:
+//
+//	pool_mode - transaction or session, defaults to transaction
+//	pool_size - the maximum number of server connections, defaults to 10
+func NewPostgresPool(ctx context.Context, clientW io.Writer, opts map[string]string) (*pgPooledProxy, error) {
+	pgProxy, err := NewPostgres(ctx, clientW, opts)
+	if err != nil {
+		return nil, err
+	}
+	poolSize := defaultPGPoolSize
+	if v := opts["pool_size"]; v != "" {
+		if poolSize, err = strconv.Atoi(v); err != nil || poolSize <= 0 {
+			return nil, fmt.Errorf("invalid pool size %q, expected a positive number", v)
+		}
+	}
+	switch opts["pool_mode"] {
+	case "", PGPoolModeTransaction, PGPoolModeSession:
+	default:
+		return nil, fmt.Errorf("unknown pool mode %q", opts["pool_mode"])
+	}
+	p := &pgPooledProxy{pgProxy: pgProxy, poolSize: poolSize, sessionMode: opts["pool_mode"] == PGPoolModeSession}
+	p.serve = p.servePooled
+	return p, nil
+}
+
+func (p *pgPooledProxy) servePooled() error {
+	startupPkt, err := p.readStartupMessage()
+	if err != nil || startupPkt == nil {
+		return err
+	}
+	if startupPkt.IsCancelRequest() {
+		frame := startupPkt.Frame()
+		if len(frame) < 12 {
+			return fmt.Errorf("invalid cancel request message")
+		}
+		return cancelPGPoolClient(p.ctx, p.pgServerConfig,
+			binary.BigEndian.Uint32(frame[4:8]), binary.BigEndian.Uint32(frame[8:12]))
+	}
+	clientParams := startupPkt.StartupParameters()
+	startupParams, err := pgPoolStartupParams(clientParams)
+	if err != nil {
+		_, _ = p.clientW.Write(pgtypes.NewErrorResponse(pgtypes.ErrCodeFeatureNotSupported, "%v", err).Encode())
+		return err
+	}
+	cfg := p.pgServerConfig
+	cfg.database = p.resolveDatabase(clientParams)
+	p.pool = getPGPool(cfg, startupParams, p.poolSize)
+	defer releasePGPool(p.pool)
+
+	// validates the credentials and obtain the parameters of the server
+	ctx, cancelFn := context.WithTimeout(p.ctx, pgPoolAcquireTimeout)
+	server, err := p.pool.acquire(ctx)
+	cancelFn()
+	if err != nil {
+		_, _ = p.clientW.Write(pgtypes.NewErrorResponse(pgtypes.ErrCodeTooManyConnections, "%v", err).Encode())
+		return err
+	}
+	if p.sessionMode {
+		p.serverMu.Lock()
+		p.server = server
+		p.serverMu.Unlock()
+	} else {
+		p.pool.release(server)
+	}
+
+	registerPGPoolClient(p)
+	defer unregisterPGPoolClient(p.keyData.Pid)
+	defer func() {
+		p.serverMu.Lock()
+		server, idle := p.server, !p.reading
+		p.server = nil
+		p.serverMu.Unlock()
+		switch {
+		case server == nil:
+		case idle:
+			// the session mode keeps the connection of an idle server
+			p.pool.reset(server)
+		default:
+			// the client has disconnected in the middle of a transaction
+			p.pool.discard(server)
+		}
+	}()
+	if err := p.writeStartupResponse(); err != nil {
+		return err
+	}
+	for {
+		pkt, err := pgtypes.Decode(p.clientR)
+		if err != nil {
+			if err == io.EOF || p.ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed decoding client packet: %v", err)
+		}
+		if pkt.Type() == pgtypes.ClientTerminate {
+			return nil
+		}
+		if err := p.writeServer(pkt); err != nil {
+			_, _ = p.clientW.Write(pgtypes.NewErrorResponse(pgtypes.ErrCodeConnectionFailure, "%v", err).Encode())
+			return err
+		}
+	}
+}
+
+// pgPoolStartupParams returns the session parameters of the client forwarded to the server
+// connections, see pgPoolSessionParams. The clients with distinct session parameters use
+// distinct pools. The user and database are defined by the pool.
+func pgPoolStartupParams(clientParams map[string]string) (map[string]string, error) {
+	params := map[string]string{}
+	for key, val := range clientParams {
+		switch {
+		case key == "replication":
+			return nil, fmt.Errorf("replication connections are not supported in the pool modes")
+		case slices.Contains(pgPoolSessionParams, strings.ToLower(key)):
+			params[key] = val
+		}
+	}
+	return params, nil
+}
+
+// writeStartupResponse sends the startup messages of the pool to the client, the key data
+// of the client is sent in a distinct packet allowing the client proxy to route the cancel requests.
+func (p *pgPooledProxy) writeStartupResponse() error {
+	authOk := pgtypes.NewPacket(pgtypes.PacketType(pgServerAuthentication), binary.BigEndian.AppendUint32(nil, pgAuthOk)).Encode()
+	p.pool.mu.Lock()
+	for _, param := range p.pool.params {
+		authOk = append(authOk, param...)
+	}
+	p.pool.mu.Unlock()
+	keyData := binary.BigEndian.AppendUint32(nil, p.keyData.Pid)
+	keyData = binary.BigEndian.AppendUint32(keyData, p.keyData.SecretKey)
+	for _, data := range [][]byte{
+		authOk,
+		pgtypes.NewPacket(pgtypes.ServerBackendKeyData, keyData).Encode(),
+		pgtypes.NewReadyForQuery(pgtypes.ServerIdle).Encode(),
+	} {
+		if _, err := p.clientW.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *pgPooledProxy) currentServer() *pgServerConn {
+	p.serverMu.Lock()
+	defer p.serverMu.Unlock()
+	return p.server
+}
+
+// writeServer writes the packet to the server connection of the client,
+// a connection is acquired from the pool when the client doesn't have one.
+func (p *pgPooledProxy) writeServer(pkt *pgtypes.Packet) error {
+	p.serverMu.Lock()
+	if p.server == nil {
+		p.serverMu.Unlock()
+		ctx, cancelFn := context.WithTimeout(p.ctx, pgPoolAcquireTimeout)
+		server, err := p.pool.acquire(ctx)
+		cancelFn()
+		if err != nil {
+			return err
+		}
+		p.serverMu.Lock()
+		p.server, p.pending, p.unsynced = server, 0, false
+	}
+	server := p.server
+	if !p.reading {
+		p.reading = true
+		go p.readServer(server)
+	}
+	switch pkt.Type() {
+	case pgtypes.ClientSimpleQuery, pgtypes.ClientSync:
+		p.pending++
+		p.unsynced = false
+	case pgtypes.ClientParse, pgtypes.ClientBind, pgtypes.ClientDescribe,
+		pgtypes.ClientExecute, pgtypes.ClientClose, pgtypes.ClientFlush:
+		p.unsynced = true
+	}
+	p.serverMu.Unlock()
+	if _, err := server.Write(pkt.Encode()); err != nil {
+		return fmt.Errorf("failed writing to postgres server: %v", err)
+	}
+	return nil
+}
+
+// readServer relays the messages of the server connection to the client,
+// it returns when the connection is released to the pool.
+func (p *pgPooledProxy) readServer(server *pgServerConn) {
+	for {
+		typ, frame, err := readPGMessage(server.r)
+		if err != nil {
+			p.serverMu.Lock()
+			owned := p.server == server
+			if owned {
+				p.server, p.reading = nil, false
+			}
+			p.serverMu.Unlock()
+			if owned {
+				p.pool.discard(server)
+				if p.ctx.Err() == nil {
+					log.Infof("postgres pool - failed reading server connection, reason=%v", err)
+					_, _ = p.clientW.Write(pgtypes.NewErrorResponse(pgtypes.ErrCodeConnectionFailure,
+						"connection with the server was closed").Encode())
+					_ = p.Close()
+				}
+			}
+			return
+		}
+		// the connection is released before the client receives the ReadyForQuery,
+		// the next query of the client must not be written to the released connection.
+		// In the session mode the connection is kept by the client and it stops being read.
+		var release, park bool
+		if typ == pgServerReadyForQuery {
+			p.serverMu.Lock()
+			p.pending = max(p.pending-1, 0)
+			idle := p.server == server && p.pending == 0 && !p.unsynced &&
+				len(frame) == 1 && frame[0] == pgtypes.ServerIdle
+			if idle {
+				p.reading = false
+				park = p.sessionMode
+				release = !p.sessionMode
+				if release {
+					p.server = nil
+				}
+			}
+			p.serverMu.Unlock()
+		}
+		if _, err := p.clientW.Write(pgtypes.NewPacket(pgtypes.PacketType(typ), frame).Encode()); err != nil {
+			if release {
+				p.pool.reset(server)
+			}
+			_ = p.Close()
+			return
+		}
+		if release {
+			p.pool.reset(server)
+			return
+		}
+		if park {
+			return
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package dbproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoop/common/pgtypes"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePGServer accepts the startup message without a password and replies to the
+// simple queries with a CommandComplete, the queries are sent to the channel.
+func fakePGServer(queries chan<- string) func(conn net.Conn) error {
+	return func(conn net.Conn) error {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, make([]byte, binary.BigEndian.Uint32(size[:])-4)); err != nil {
+			return err
+		}
+		var startup []byte
+		startup = append(startup, newPGTestMessage(pgServerAuthentication, binary.BigEndian.AppendUint32(nil, pgAuthOk))...)
+		startup = append(startup, newPGTestMessage(pgServerParameterStatus, []byte("server_version\x0016\x00"))...)
+		startup = append(startup, newPGTestMessage(pgServerBackendKeyData, []byte{0, 0, 0, 1, 0, 0, 0, 2})...)
+		startup = append(startup, pgtypes.NewReadyForQuery(pgtypes.ServerIdle).Encode()...)
+		if _, err := conn.Write(startup); err != nil {
+			return err
+		}
+		r := bufio.NewReader(conn)
+		for {
+			typ, frame, err := readPGMessage(r)
+			if err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+			if typ != byte(pgtypes.ClientSimpleQuery) {
+				return fmt.Errorf("unexpected message %q", typ)
+			}
+			queries <- string(bytes.TrimSuffix(frame, []byte{0x00}))
+			resp := newPGTestMessage('C', []byte("OK\x00"))
+			resp = append(resp, pgtypes.NewReadyForQuery(pgtypes.ServerIdle).Encode()...)
+			if _, err := conn.Write(resp); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func TestPGPoolDiscardSessionState(t *testing.T) {
+	queries := make(chan string, 10)
+	host, port, _ := newFakeServer(t, fakePGServer(queries))
+	clientR, clientW := newClientPipe(t)
+	p, err := NewPostgresPool(context.Background(), clientW, map[string]string{
+		"hostname": host, "port": port, "username": "hoop", "database": "mydb", "sslmode": "disable", "pool_size": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.Run(func(_ int, errMsg string) { t.Errorf("proxy failed: %v", errMsg) })
+	closeOnDone(p.proxy, clientW)
+
+	_, _ = p.Write(encodeStartupMessage(map[string]string{"user": "client-user"}))
+	readPGTestUntilReady(t, clientR)
+	for _, query := range []string{"SET ROLE admin", "SELECT 1"} {
+		_, _ = p.Write(newPGTestQuery(query))
+		readPGTestUntilReady(t, clientR)
+	}
+	var got []string
+	for range 3 {
+		got = append(got, <-queries)
+	}
+	assert.Equal(t, []string{"SET ROLE admin", "DISCARD ALL", "SELECT 1"}, got)
+}
+
+func TestPGPoolSessionMode(t *testing.T) {
+	queries := make(chan string, 10)
+	// the fake server accepts a single connection, the second client must reuse it
+	host, port, _ := newFakeServer(t, fakePGServer(queries))
+	opts := map[string]string{"hostname": host, "port": port, "username": "hoop", "database": "mydb",
+		"sslmode": "disable", "pool_mode": PGPoolModeSession, "pool_size": "1"}
+	connect := func() (*pgPooledProxy, *io.PipeReader) {
+		clientR, clientW := newClientPipe(t)
+		p, err := NewPostgresPool(context.Background(), clientW, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Run(func(_ int, errMsg string) { t.Errorf("proxy failed: %v", errMsg) })
+		closeOnDone(p.proxy, clientW)
+		_, _ = p.Write(encodeStartupMessage(map[string]string{"user": "client-user"}))
+		readPGTestUntilReady(t, clientR)
+		return p, clientR
+	}
+
+	p, clientR := connect()
+	for _, query := range []string{"SET ROLE admin", "SELECT 1"} {
+		_, _ = p.Write(newPGTestQuery(query))
+		readPGTestUntilReady(t, clientR)
+	}
+	_, _ = p.Write(newPGTestMessage(byte(pgtypes.ClientTerminate), nil))
+	select {
+	case <-p.Done():
+	case <-time.After(time.Second * 5):
+		t.Fatal("timeout waiting the client to disconnect")
+	}
+
+	p, clientR = connect()
+	defer p.Close()
+	_, _ = p.Write(newPGTestQuery("SELECT 2"))
+	readPGTestUntilReady(t, clientR)
+	var got []string
+	for range 4 {
+		got = append(got, <-queries)
+	}
+	// the session state is kept between the transactions and discarded when the client disconnects
+	assert.Equal(t, []string{"SET ROLE admin", "SELECT 1", "DISCARD ALL", "SELECT 2"}, got)
+}
+
+func TestPGPoolReset(t *testing.T) {
+	for _, tt := range []struct {
+		msg      string
+		response []byte
+		wantIdle int
+	}{
+		{
+			msg: "it should return the connection to the pool after discarding the session state",
+			response: append(newPGTestMessage('C', []byte("DISCARD ALL\x00")),
+				pgtypes.NewReadyForQuery(pgtypes.ServerIdle).Encode()...),
+			wantIdle: 1,
+		},
+		{
+			msg: "it should close the connection when the server fails to discard the session state",
+			response: append(pgtypes.NewErrorResponse("25001", "DISCARD ALL cannot run inside a transaction block").Encode(),
+				pgtypes.NewReadyForQuery('E').Encode()...),
+		},
+		{
+			msg:      "it should close the connection when the server closes it",
+			response: nil,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			defer serverConn.Close()
+			go func() {
+				typ, frame, err := readPGMessage(serverConn)
+				if err != nil || typ != byte(pgtypes.ClientSimpleQuery) || string(frame) != "DISCARD ALL\x00" {
+					t.Errorf("expected a discard query, got=%q, err=%v", frame, err)
+				}
+				if tt.response == nil {
+					_ = serverConn.Close()
+					return
+				}
+				_, _ = serverConn.Write(tt.response)
+			}()
+			pool := &pgPool{sem: make(chan struct{}, 1)}
+			pool.sem <- struct{}{}
+			pool.reset(&pgServerConn{Conn: clientConn, r: bufio.NewReader(clientConn)})
+			assert.Len(t, pool.idle, tt.wantIdle)
+			assert.Len(t, pool.sem, 0, "the connection must be returned to the pool")
+		})
+	}
+}
+
+func TestPGPoolStartupParams(t *testing.T) {
+	params, err := pgPoolStartupParams(map[string]string{
+		"user": "client-user", "database": "mydb", "application_name": "psql",
+		"client_encoding": "UTF8", "DateStyle": "ISO, MDY", "options": "-c search_path=app"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"client_encoding": "UTF8", "DateStyle": "ISO, MDY", "options": "-c search_path=app"}, params)
+
+	_, err = pgPoolStartupParams(map[string]string{"replication": "database"})
+	assert.ErrorContains(t, err, "replication connections are not supported")
+}
+
+func TestPGPoolShareAndEvict(t *testing.T) {
+	cfg := pgServerConfig{host: "127.0.0.1", port: "5432", user: "hoop", database: "evict"}
+	newParams := func(clientParams map[string]string) map[string]string {
+		params, err := pgPoolStartupParams(clientParams)
+		assert.NoError(t, err)
+		return params
+	}
+	pool := getPGPool(cfg, newParams(map[string]string{"application_name": "psql"}), 1)
+	// the pool is shared by clients with the same session parameters
+	assert.Same(t, pool, getPGPool(cfg, newParams(map[string]string{"application_name": "pgcli"}), 1))
+	other := getPGPool(cfg, newParams(map[string]string{"options": "-c search_path=app"}), 1)
+	assert.NotSame(t, pool, other)
+	releasePGPool(other)
+
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	pool.mu.Lock()
+	pool.idle = append(pool.idle, &pgServerConn{Conn: clientConn, idleSince: time.Now()})
+	pool.mu.Unlock()
+	releasePGPool(pool)
+	releasePGPool(pool)
+
+	// the pool is kept while it has idle connections
+	pool.closeIdle(pgPoolIdleTimeout)
+	pgPoolsMu.Lock()
+	assert.Same(t, pool, pgPools[pool.key])
+	pgPoolsMu.Unlock()
+
+	pool.closeIdle(0)
+	pgPoolsMu.Lock()
+	_, ok := pgPools[pool.key]
+	pgPoolsMu.Unlock()
+	assert.False(t, ok, "the pool must be removed when it doesn't have clients and connections")
+	assert.Empty(t, pool.idle)
+	select {
+	case <-pool.stop:
+	case <-time.After(time.Second):
+		t.Fatal("the pool must be stopped when it's removed")
+	}
+
+	// the goroutine of the pool returns when it's removed
+	done := make(chan struct{})
+	go func() { other.closeIdleConnections(time.Millisecond); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("timeout waiting the idle connections routine of the pool to stop")
+	}
+}
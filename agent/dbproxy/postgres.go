@@ -0,0 +1,386 @@
+package dbproxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/hoophq/hoop/common/pgtypes"
+)
+
+const (
+	pgProtocolVersion3     uint32 = 196608
+	pgSSLRequestCode       uint32 = 80877103
+	pgGSSEncRequestCode    uint32 = 80877104
+	pgServerAuthentication byte   = 'R'
+	pgServerNoticeResponse byte   = 'N'
+
+	pgAuthOk                = 0
+	pgAuthCleartextPassword = 3
+	pgAuthMD5Password       = 5
+	pgAuthSASL              = 10
+	pgAuthSASLContinue      = 11
+	pgAuthSASLFinal         = 12
+)
+
+// pgServerConfig has the address and the credentials of the server
+type pgServerConfig struct {
+	host     string
+	port     string
+	user     string
+	password string
+	database string
+	sslMode  string
+	// the PEM encoded certificates of the authorities that
+	// sign the server certificate in the verify modes
+	sslRootCert string
+}
+
+type pgProxy struct {
+	*proxy
+	pgServerConfig
+}
+
+// NewPostgres returns a proxy that writes the server packets to clientW. The options are:
+//
+//	hostname, port, username, password - the address and credentials of the server
+//	database - the database to connect when the client doesn't inform one
+//	sslmode - disable, allow, prefer, require, verify-ca or verify-full. Defaults to prefer
+//	sslrootcert - the PEM encoded root certificates used by the verify modes, defaults to the system roots
+func NewPostgres(ctx context.Context, clientW io.Writer, opts map[string]string) (*pgProxy, error) {
+	if opts["hostname"] == "" || opts["username"] == "" {
+		return nil, fmt.Errorf("missing hostname or username of the connection")
+	}
+	port := opts["port"]
+	if port == "" {
+		port = "5432"
+	}
+	cfg := pgServerConfig{
+		host:        opts["hostname"],
+		port:        port,
+		user:        opts["username"],
+		password:    opts["password"],
+		database:    opts["database"],
+		sslMode:     opts["sslmode"],
+		sslRootCert: opts["sslrootcert"],
+	}
+	if _, err := newPGTLSConfig(cfg); err != nil {
+		return nil, err
+	}
+	p := &pgProxy{
+		proxy:          newProxy(ctx, "postgres", clientW),
+		pgServerConfig: cfg,
+	}
+	p.serve = p.servePostgres
+	return p, nil
+}
+
+func (p *pgProxy) servePostgres() error {
+	startupPkt, err := p.readStartupMessage()
+	if err != nil || startupPkt == nil {
+		return err
+	}
+	conn, err := p.dialTLS()
+	if err != nil {
+		return err
+	}
+	if startupPkt.IsCancelRequest() {
+		// the server closes the connection after processing the cancel request
+		_, err := conn.Write(startupPkt.Encode())
+		return err
+	}
+	serverR := bufio.NewReader(conn)
+	if err := p.authenticate(p.clientW, conn, serverR, startupPkt.StartupParameters()); err != nil {
+		return err
+	}
+	return p.relay(conn, serverR)
+}
+
+// readStartupMessage reads the startup packets of the client until a startup or a
+// cancel request message. The encryption is refused because the agent is the one
+// responsible for encrypting the connection with the server.
+func (p *pgProxy) readStartupMessage() (*pgtypes.Packet, error) {
+	for {
+		pkt, err := pgtypes.Decode(p.clientR)
+		if err != nil {
+			if err == io.EOF {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed decoding startup message: %v", err)
+		}
+		if pkt.Type() != 0 || len(pkt.Frame()) < 4 {
+			return nil, fmt.Errorf("invalid startup message")
+		}
+		if pkt.IsCancelRequest() {
+			return pkt, nil
+		}
+		switch code := binary.BigEndian.Uint32(pkt.Frame()[:4]); code {
+		case pgSSLRequestCode, pgGSSEncRequestCode:
+			if _, err := p.clientW.Write([]byte{'N'}); err != nil {
+				return nil, err
+			}
+		case pgProtocolVersion3:
+			return pkt, nil
+		default:
+			return nil, fmt.Errorf("unsupported protocol version %v", code)
+		}
+	}
+}
+
+// dialTLS connects with the server negotiating the encryption with the sslmode
+func (p *pgProxy) dialTLS() (net.Conn, error) {
+	conn, err := p.dial(p.host, p.port)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancelFn := context.WithTimeout(p.ctx, dialTimeout)
+	defer cancelFn()
+	tlsConn, err := negotiatePGTLS(ctx, conn, p.pgServerConfig)
+	if err != nil {
+		return nil, err
+	}
+	p.setServer(tlsConn)
+	return tlsConn, nil
+}
+
+// resolveDatabase returns the database to connect, when the client
+// doesn't inform one it defaults to the database of the connection.
+func (c *pgServerConfig) resolveDatabase(clientParams map[string]string) string {
+	if db := clientParams["database"]; db != "" && db != clientParams["user"] {
+		return db
+	}
+	if c.database == "" {
+		return c.user
+	}
+	return c.database
+}
+
+// authenticate sends the startup message with the credentials of the connection and performs
+// the authentication with the server. The AuthenticationOk is sent to the client, which
+// receives the remaining startup messages from the server, e.g.: ParameterStatus, BackendKeyData.
+func (c *pgServerConfig) authenticate(clientW, serverW io.Writer, serverR *bufio.Reader, clientParams map[string]string) error {
+	params := map[string]string{}
+	for key, val := range clientParams {
+		params[key] = val
+	}
+	params["database"] = c.resolveDatabase(clientParams)
+	params["user"] = c.user
+	if _, err := serverW.Write(encodeStartupMessage(params)); err != nil {
+		return fmt.Errorf("failed writing startup message: %v", err)
+	}
+
+	var scram *scramClient
+	// the signature of the server must be verified before the
+	// authentication succeeds when the scram exchange started
+	serverVerified := false
+	for {
+		typ, frame, err := readPGMessage(serverR)
+		if err != nil {
+			return fmt.Errorf("failed reading authentication message: %v", err)
+		}
+		switch typ {
+		case pgServerAuthentication:
+		case byte(pgtypes.ServerErrorResponse):
+			// forward the error to the client, e.g.: the database doesn't exist
+			_, _ = clientW.Write(pgtypes.NewPacket(pgtypes.ServerErrorResponse, frame).Encode())
+			// class 28 - invalid authorization specification
+			if strings.HasPrefix(errorResponseField(frame, pgtypes.ErrorFieldCode), "28") {
+				return fmt.Errorf("postgres %w: %v", ErrAuthFailed, errorResponseMessage(frame))
+			}
+			return fmt.Errorf("postgres authentication failed: %v", errorResponseMessage(frame))
+		case pgServerNoticeResponse:
+			continue
+		default:
+			return fmt.Errorf("unexpected message %q during authentication", typ)
+		}
+		if len(frame) < 4 {
+			return fmt.Errorf("invalid authentication message")
+		}
+		var response []byte
+		switch authType := binary.BigEndian.Uint32(frame[:4]); authType {
+		case pgAuthOk:
+			if scram != nil && !serverVerified {
+				return fmt.Errorf("sasl authentication completed without the server final message")
+			}
+			_, err := clientW.Write(pgtypes.NewPacket(pgtypes.PacketType(pgServerAuthentication), frame).Encode())
+			return err
+		case pgAuthCleartextPassword:
+			response = append([]byte(c.password), 0x00)
+		case pgAuthMD5Password:
+			if len(frame) < 8 {
+				return fmt.Errorf("invalid md5 authentication message")
+			}
+			response = append([]byte(md5Password(c.user, c.password, frame[4:8])), 0x00)
+		case pgAuthSASL:
+			mechanisms := parseSASLMechanisms(frame[4:])
+			if !slices.Contains(mechanisms, scramSHA256Mechanism) {
+				return fmt.Errorf("unsupported sasl authentication mechanisms %v", mechanisms)
+			}
+			if scram, err = newSCRAMClient(c.password); err != nil {
+				return err
+			}
+			clientFirst := scram.clientFirstMessage()
+			response = append([]byte(scramSHA256Mechanism), 0x00)
+			response = binary.BigEndian.AppendUint32(response, uint32(len(clientFirst)))
+			response = append(response, clientFirst...)
+		case pgAuthSASLContinue:
+			if scram == nil {
+				return fmt.Errorf("unexpected sasl continue message")
+			}
+			if response, err = scram.clientFinalMessage(frame[4:]); err != nil {
+				return err
+			}
+		case pgAuthSASLFinal:
+			if scram == nil {
+				return fmt.Errorf("unexpected sasl final message")
+			}
+			if err := scram.verifyServerFinal(frame[4:]); err != nil {
+				return err
+			}
+			serverVerified = true
+			continue
+		default:
+			return fmt.Errorf("unsupported authentication method (%v)", authType)
+		}
+		if _, err := serverW.Write(pgtypes.NewPacket(pgtypes.ClientPassword, response).Encode()); err != nil {
+			return fmt.Errorf("failed writing authentication response: %v", err)
+		}
+	}
+}
+
+func encodeStartupMessage(params map[string]string) []byte {
+	frame := binary.BigEndian.AppendUint32(nil, pgProtocolVersion3)
+	for key, val := range params {
+		frame = append(frame, key...)
+		frame = append(frame, 0x00)
+		frame = append(frame, val...)
+		frame = append(frame, 0x00)
+	}
+	frame = append(frame, 0x00)
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(frame)+4)), frame...)
+}
+
+// readPGMessage reads a typed message, the server and client types
+// overlap, thus it's not possible to use pgtypes.Decode
+func readPGMessage(r io.Reader) (typ byte, frame []byte, err error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := int(binary.BigEndian.Uint32(header[1:])) - 4
+	if size < 0 || size > pgtypes.DefaultBufferSize {
+		return 0, nil, fmt.Errorf("invalid message size (%v)", size)
+	}
+	frame = make([]byte, size)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return 0, nil, err
+	}
+	return header[0], frame, nil
+}
+
+// md5Password returns the password in the format: md5(md5(password + user) + salt)
+func md5Password(user, password string, salt []byte) string {
+	sum := md5.Sum([]byte(password + user))
+	sum = md5.Sum(append([]byte(hex.EncodeToString(sum[:])), salt...))
+	return "md5" + hex.EncodeToString(sum[:])
+}
+
+func errorResponseMessage(frame []byte) string {
+	if msg := errorResponseField(frame, pgtypes.ErrorFieldMessage); msg != "" {
+		return msg
+	}
+	return "unknown error"
+}
+
+func errorResponseField(frame []byte, fieldType byte) string {
+	for len(frame) > 1 {
+		field := frame[0]
+		end := 1
+		for end < len(frame) && frame[end] != 0x00 {
+			end++
+		}
+		if field == fieldType {
+			return string(frame[1:end])
+		}
+		frame = frame[min(end+1, len(frame)):]
+	}
+	return ""
+}
+
+// newPGTLSConfig returns the tls configuration of a sslmode, it returns nil when
+// the connection must not be encrypted. https://www.postgresql.org/docs/current/libpq-ssl.html
+//
+// The mode allow requests encryption first like prefer, both fallback to a plain connection.
+func newPGTLSConfig(cfg pgServerConfig) (*tls.Config, error) {
+	var roots *x509.CertPool
+	if cfg.sslRootCert != "" {
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM([]byte(cfg.sslRootCert)) {
+			return nil, fmt.Errorf("failed parsing the root certificates of sslrootcert, expected PEM encoded certificates")
+		}
+	}
+	switch cfg.sslMode {
+	case "disable":
+		return nil, nil
+	case "", "allow", "prefer", "require":
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	case "verify-ca":
+		// verify the chain of certificates without validating the hostname
+		config := &tls.Config{InsecureSkipVerify: true}
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return fmt.Errorf("missing server certificate")
+			}
+			opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+			for _, cert := range state.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := state.PeerCertificates[0].Verify(opts)
+			return err
+		}
+		return config, nil
+	case "verify-full":
+		return &tls.Config{ServerName: cfg.host, RootCAs: roots}, nil
+	}
+	return nil, fmt.Errorf("unknown sslmode %q", cfg.sslMode)
+}
+
+// negotiatePGTLS sends a SSLRequest to the server and upgrades the connection when the server accepts it.
+// The modes allow and prefer fallback to a plain connection when the server doesn't support encryption.
+func negotiatePGTLS(ctx context.Context, conn net.Conn, cfg pgServerConfig) (net.Conn, error) {
+	config, err := newPGTLSConfig(cfg)
+	if err != nil || config == nil {
+		return conn, err
+	}
+	sslRequest := binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, 8), pgSSLRequestCode)
+	if _, err := conn.Write(sslRequest); err != nil {
+		return nil, fmt.Errorf("failed writing ssl request: %v", err)
+	}
+	resp := make([]byte, 1)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, fmt.Errorf("failed reading ssl response: %v", err)
+	}
+	switch resp[0] {
+	case 'S':
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, fmt.Errorf("failed tls handshake with postgres server: %v", err)
+		}
+		return tlsConn, nil
+	case 'N':
+		if cfg.sslMode == "" || cfg.sslMode == "allow" || cfg.sslMode == "prefer" {
+			return conn, nil
+		}
+		return nil, fmt.Errorf("postgres server does not support ssl, sslmode=%v", cfg.sslMode)
+	}
+	return nil, fmt.Errorf("unexpected ssl response %q from postgres server", resp[0])
+}
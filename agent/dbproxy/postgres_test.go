@@ -0,0 +1,395 @@
+package dbproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hoophq/hoop/common/pgtypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPGTestMessage(typ byte, frame []byte) []byte {
+	return pgtypes.NewPacket(pgtypes.PacketType(typ), frame).Encode()
+}
+
+func newPGTestQuery(query string) []byte {
+	return newPGTestMessage(byte(pgtypes.ClientSimpleQuery), append([]byte(query), 0x00))
+}
+
+// readPGTestUntilReady reads the messages of the client until a ReadyForQuery
+func readPGTestUntilReady(t *testing.T, r io.Reader) {
+	t.Helper()
+	for {
+		typ, _, err := readPGMessage(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ == pgServerReadyForQuery {
+			return
+		}
+	}
+}
+
+// readPGTestStartup reads the startup message, it upgrades the connection to tls when
+// the client sends a SSLRequest and tlsConfig is set, otherwise the encryption is refused.
+func readPGTestStartup(conn net.Conn, tlsConfig *tls.Config) (net.Conn, map[string]string, error) {
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return nil, nil, err
+		}
+		frame := make([]byte, binary.BigEndian.Uint32(size[:])-4)
+		if _, err := io.ReadFull(conn, frame); err != nil {
+			return nil, nil, err
+		}
+		switch binary.BigEndian.Uint32(frame[:4]) {
+		case pgSSLRequestCode:
+			if tlsConfig == nil {
+				if _, err := conn.Write([]byte{'N'}); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			if _, err := conn.Write([]byte{'S'}); err != nil {
+				return nil, nil, err
+			}
+			tlsConn := tls.Server(conn, tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return nil, nil, fmt.Errorf("failed tls handshake: %v", err)
+			}
+			conn = tlsConn
+		case pgProtocolVersion3:
+			params := map[string]string{}
+			parts := bytes.Split(frame[4:], []byte{0x00})
+			for i := 0; i+1 < len(parts); i += 2 {
+				params[string(parts[i])] = string(parts[i+1])
+			}
+			return conn, params, nil
+		default:
+			return nil, nil, fmt.Errorf("unexpected startup message %X", frame)
+		}
+	}
+}
+
+func newPGTestAuth(authType uint32, data []byte) []byte {
+	return newPGTestMessage(pgServerAuthentication, append(binary.BigEndian.AppendUint32(nil, authType), data...))
+}
+
+func readPGTestPassword(r io.Reader) ([]byte, error) {
+	typ, frame, err := readPGMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	if typ != byte(pgtypes.ClientPassword) {
+		return nil, fmt.Errorf("expected a password message, got=%q", typ)
+	}
+	return frame, nil
+}
+
+// authenticatePGTestSCRAM performs the server side of the SCRAM-SHA-256 authentication
+func authenticatePGTestSCRAM(conn net.Conn, r io.Reader, password string) (bool, error) {
+	if _, err := conn.Write(newPGTestAuth(pgAuthSASL, []byte(scramSHA256Mechanism+"\x00\x00"))); err != nil {
+		return false, err
+	}
+	frame, err := readPGTestPassword(r)
+	if err != nil {
+		return false, err
+	}
+	mechanism, rest, _ := bytes.Cut(frame, []byte{0x00})
+	if string(mechanism) != scramSHA256Mechanism || len(rest) < 4 {
+		return false, fmt.Errorf("unexpected sasl initial response %q", frame)
+	}
+	clientFirstBare, found := strings.CutPrefix(string(rest[4:]), "n,,")
+	if !found {
+		return false, fmt.Errorf("unexpected gs2 header %q", rest[4:])
+	}
+	nonce := parseSCRAMAttributes(clientFirstBare)["r"] + "server-nonce"
+	salt := []byte("0123456789abcdef")
+	serverFirst := fmt.Sprintf("r=%s,s=%s,i=4096", nonce, base64.StdEncoding.EncodeToString(salt))
+	if _, err := conn.Write(newPGTestAuth(pgAuthSASLContinue, []byte(serverFirst))); err != nil {
+		return false, err
+	}
+	clientFinal, err := readPGTestPassword(r)
+	if err != nil {
+		return false, err
+	}
+	clientFinalWithoutProof, proof, _ := strings.Cut(string(clientFinal), ",p=")
+	proofBytes, _ := base64.StdEncoding.DecodeString(proof)
+	authMessage := []byte(clientFirstBare + "," + serverFirst + "," + clientFinalWithoutProof)
+	saltedPassword := pbkdf2(sha256.New, []byte(password), salt, 4096)
+	storedKey := sha256.Sum256(hmacSHA256(saltedPassword, []byte("Client Key")))
+	clientSignature := hmacSHA256(storedKey[:], authMessage)
+	if len(proofBytes) != len(clientSignature) {
+		return false, nil
+	}
+	clientKey := make([]byte, len(proofBytes))
+	for i := range proofBytes {
+		clientKey[i] = proofBytes[i] ^ clientSignature[i]
+	}
+	if sha256.Sum256(clientKey) != storedKey {
+		return false, nil
+	}
+	serverSignature := hmacSHA256(hmacSHA256(saltedPassword, []byte("Server Key")), authMessage)
+	serverFinal := "v=" + base64.StdEncoding.EncodeToString(serverSignature)
+	_, err = conn.Write(newPGTestAuth(pgAuthSASLFinal, []byte(serverFinal)))
+	return true, err
+}
+
+// fakePGAuthServer authenticates the user hoop with the password secret using the
+// authentication method, after the authentication it replies a single simple query.
+func fakePGAuthServer(tlsConfig *tls.Config, authMethod string) func(conn net.Conn) error {
+	return func(conn net.Conn) error {
+		conn, params, err := readPGTestStartup(conn, tlsConfig)
+		if err != nil {
+			return err
+		}
+		if params["user"] != "hoop" || params["database"] != "mydb" || params["application_name"] != "psql" {
+			return fmt.Errorf("unexpected startup parameters %v", params)
+		}
+		r := bufio.NewReader(conn)
+		var authenticated bool
+		switch authMethod {
+		case "md5":
+			salt := []byte{1, 2, 3, 4}
+			if _, err := conn.Write(newPGTestAuth(pgAuthMD5Password, salt)); err != nil {
+				return err
+			}
+			frame, err := readPGTestPassword(r)
+			if err != nil {
+				return err
+			}
+			authenticated = string(frame) == md5Password("hoop", "secret", salt)+"\x00"
+		case "scram":
+			if authenticated, err = authenticatePGTestSCRAM(conn, r, "secret"); err != nil {
+				return err
+			}
+		case "cleartext":
+			if _, err := conn.Write(newPGTestAuth(pgAuthCleartextPassword, nil)); err != nil {
+				return err
+			}
+			frame, err := readPGTestPassword(r)
+			if err != nil {
+				return err
+			}
+			authenticated = string(frame) == "secret\x00"
+		}
+		if !authenticated {
+			_, err := conn.Write(pgtypes.NewErrorResponse("28P01", `password authentication failed for user "hoop"`).Encode())
+			return err
+		}
+		var resp []byte
+		resp = append(resp, newPGTestAuth(pgAuthOk, nil)...)
+		resp = append(resp, newPGTestMessage(pgServerParameterStatus, []byte("server_version\x0016\x00"))...)
+		resp = append(resp, pgtypes.NewReadyForQuery(pgtypes.ServerIdle).Encode()...)
+		if _, err := conn.Write(resp); err != nil {
+			return err
+		}
+		typ, frame, err := readPGMessage(r)
+		if err != nil {
+			return err
+		}
+		if typ != byte(pgtypes.ClientSimpleQuery) || string(frame) != "SELECT 1\x00" {
+			return fmt.Errorf("unexpected query %q %q", typ, frame)
+		}
+		resp = newPGTestMessage('C', []byte("SELECT 1\x00"))
+		resp = append(resp, pgtypes.NewReadyForQuery(pgtypes.ServerIdle).Encode()...)
+		_, err = conn.Write(resp)
+		return err
+	}
+}
+
+func TestPostgresHandshake(t *testing.T) {
+	for _, tt := range []struct {
+		msg        string
+		tlsConfig  *tls.Config
+		sslMode    string
+		authMethod string
+		password   string
+		wantErr    string
+	}{
+		{
+			msg:        "it should authenticate with scram over tls",
+			tlsConfig:  newSelfSignedTLSConfig(t),
+			sslMode:    "require",
+			authMethod: "scram",
+			password:   "secret",
+		},
+		{
+			msg:        "it should authenticate with md5 when the server does not support tls",
+			sslMode:    "prefer",
+			authMethod: "md5",
+			password:   "secret",
+		},
+		{
+			msg:        "it should authenticate with cleartext passwords",
+			sslMode:    "disable",
+			authMethod: "cleartext",
+			password:   "secret",
+		},
+		{
+			msg:        "it should report the authentication failure",
+			sslMode:    "disable",
+			authMethod: "scram",
+			password:   "wrong",
+			wantErr:    `postgres authentication failed: password authentication failed for user "hoop"`,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			host, port, errCh := newFakeServer(t, fakePGAuthServer(tt.tlsConfig, tt.authMethod))
+			clientR, clientW := newClientPipe(t)
+			p, err := NewPostgres(context.Background(), clientW, map[string]string{
+				"hostname": host, "port": port, "username": "hoop", "password": tt.password,
+				"database": "mydb", "sslmode": tt.sslMode})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+			onErrCh := make(chan string, 1)
+			var exitCode int
+			p.Run(func(code int, errMsg string) { exitCode = code; onErrCh <- errMsg })
+			closeOnDone(p.proxy, clientW)
+
+			// the encryption with the client is refused
+			sslRequest := binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, 8), pgSSLRequestCode)
+			_, _ = p.Write(sslRequest)
+			resp := make([]byte, 1)
+			if _, err := io.ReadFull(clientR, resp); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, []byte{'N'}, resp)
+
+			_, _ = p.Write(encodeStartupMessage(map[string]string{"user": "client-user", "application_name": "psql"}))
+			typ, frame, err := readPGMessage(clientR)
+			if err != nil {
+				waitServer(t, errCh)
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" {
+				assert.Equal(t, byte(pgtypes.ServerErrorResponse), typ)
+				assert.Equal(t, tt.wantErr, <-onErrCh)
+				assert.Equal(t, ExitCodeAuthFailed, exitCode)
+				waitServer(t, errCh)
+				return
+			}
+			assert.Equal(t, pgServerAuthentication, typ)
+			assert.Equal(t, binary.BigEndian.AppendUint32(nil, pgAuthOk), frame)
+			readPGTestUntilReady(t, clientR)
+
+			_, _ = p.Write(newPGTestQuery("SELECT 1"))
+			typ, _, err = readPGMessage(clientR)
+			assert.NoError(t, err)
+			assert.Equal(t, byte('C'), typ)
+			readPGTestUntilReady(t, clientR)
+			waitServer(t, errCh)
+		})
+	}
+}
+
+func TestPostgresSCRAMWithoutServerFinal(t *testing.T) {
+	// the server skips the sasl final message with its signature
+	host, port, errCh := newFakeServer(t, func(conn net.Conn) error {
+		conn, _, err := readPGTestStartup(conn, nil)
+		if err != nil {
+			return err
+		}
+		r := bufio.NewReader(conn)
+		if _, err := conn.Write(newPGTestAuth(pgAuthSASL, []byte(scramSHA256Mechanism+"\x00\x00"))); err != nil {
+			return err
+		}
+		frame, err := readPGTestPassword(r)
+		if err != nil {
+			return err
+		}
+		_, rest, _ := bytes.Cut(frame, []byte{0x00})
+		clientFirstBare, _ := strings.CutPrefix(string(rest[4:]), "n,,")
+		nonce := parseSCRAMAttributes(clientFirstBare)["r"] + "server-nonce"
+		serverFirst := fmt.Sprintf("r=%s,s=%s,i=4096", nonce, base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")))
+		if _, err := conn.Write(newPGTestAuth(pgAuthSASLContinue, []byte(serverFirst))); err != nil {
+			return err
+		}
+		if _, err := readPGTestPassword(r); err != nil {
+			return err
+		}
+		_, err = conn.Write(newPGTestAuth(pgAuthOk, nil))
+		return err
+	})
+	conn, err := net.Dial("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	cfg := &pgServerConfig{user: "hoop", password: "secret", database: "mydb"}
+	var clientW bytes.Buffer
+	err = cfg.authenticate(&clientW, conn, bufio.NewReader(conn), map[string]string{"user": "client-user"})
+	assert.EqualError(t, err, "sasl authentication completed without the server final message")
+	assert.Empty(t, clientW.Bytes(), "it should not send the authentication ok to the client")
+	waitServer(t, errCh)
+}
+
+func TestPGTLSConfigVerifyModes(t *testing.T) {
+	serverConfig := newSelfSignedTLSConfig(t)
+	rootCert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverConfig.Certificates[0].Certificate[0]}))
+	for _, tt := range []struct {
+		msg     string
+		cfg     pgServerConfig
+		wantErr string
+	}{
+		{
+			msg: "it should verify the server certificate with the root certificates",
+			cfg: pgServerConfig{host: "localhost", sslMode: "verify-ca", sslRootCert: rootCert},
+		},
+		{
+			msg:     "it should fail when the server certificate is not signed by the root certificates",
+			cfg:     pgServerConfig{host: "localhost", sslMode: "verify-ca"},
+			wantErr: "certificate signed by unknown authority",
+		},
+		{
+			msg:     "it should fail when the hostname does not match the server certificate",
+			cfg:     pgServerConfig{host: "db.example.com", sslMode: "verify-full", sslRootCert: rootCert},
+			wantErr: "certificate is not valid for any names",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			config, err := newPGTLSConfig(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer lis.Close()
+			go func() {
+				if serverConn, err := lis.Accept(); err == nil {
+					_ = tls.Server(serverConn, serverConfig).Handshake()
+					_ = serverConn.Close()
+				}
+			}()
+			clientConn, err := net.Dial("tcp", lis.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer clientConn.Close()
+			err = tls.Client(clientConn, config).Handshake()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	_, err := newPGTLSConfig(pgServerConfig{sslMode: "verify-ca", sslRootCert: "invalid"})
+	assert.EqualError(t, err, "failed parsing the root certificates of sslrootcert, expected PEM encoded certificates")
+}
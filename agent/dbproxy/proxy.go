@@ -0,0 +1,131 @@
+// Package dbproxy implements database proxies that authenticate the connections of the clients
+// with the credentials of the connection. After the authentication, the frames of the
+// client and the server are passed through. The proxies implement the contract of libhoop.Proxy.
+package dbproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hoophq/hoop/common/log"
+)
+
+const (
+	dialTimeout = time.Second * 15
+
+	// ExitCodeAuthFailed is reported to the callback of Run
+	// when the server rejects the credentials of the connection
+	ExitCodeAuthFailed = 2
+)
+
+// ErrAuthFailed indicates that the server rejected the credentials of the connection
+var ErrAuthFailed = errors.New("authentication failed")
+
+// proxy has the lifecycle shared by the protocols, the serve function
+// performs the handshake of each protocol and relays the connection.
+type proxy struct {
+	ctx      context.Context
+	cancelFn context.CancelFunc
+	name     string
+	clientW  io.Writer
+	clientR  *clientReader
+	done     chan struct{}
+	serve    func() error
+
+	mu        sync.Mutex
+	server    net.Conn
+	closeOnce sync.Once
+}
+
+func newProxy(ctx context.Context, name string, clientW io.Writer) *proxy {
+	ctx, cancelFn := context.WithCancel(ctx)
+	return &proxy{
+		ctx:      ctx,
+		cancelFn: cancelFn,
+		name:     name,
+		clientW:  clientW,
+		clientR:  newClientReader(),
+		done:     make(chan struct{}),
+	}
+}
+
+// Run starts the proxy in background, the callback is called when the connection fails
+func (p *proxy) Run(onErr func(exitCode int, errMsg string)) {
+	go func() {
+		defer p.Close()
+		if err := p.serve(); err != nil {
+			log.Infof("%v proxy closed with error, reason=%v", p.name, err)
+			exitCode := 1
+			if errors.Is(err, ErrAuthFailed) {
+				exitCode = ExitCodeAuthFailed
+			}
+			onErr(exitCode, err.Error())
+		}
+	}()
+}
+
+// Write buffers the packets of the client, it doesn't block while the proxy authenticates
+func (p *proxy) Write(data []byte) (int, error) { return p.clientR.write(data) }
+func (p *proxy) Done() <-chan struct{}          { return p.done }
+
+func (p *proxy) Close() error {
+	p.closeOnce.Do(func() {
+		p.cancelFn()
+		p.clientR.close()
+		p.mu.Lock()
+		if p.server != nil {
+			_ = p.server.Close()
+		}
+		p.mu.Unlock()
+		close(p.done)
+	})
+	return nil
+}
+
+// setServer keeps the connection with the server to close it when the proxy closes
+func (p *proxy) setServer(conn net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.server = conn
+}
+
+// dial opens a tcp connection with the server
+func (p *proxy) dial(host, port string) (net.Conn, error) {
+	addr := net.JoinHostPort(host, port)
+	ctx, cancelFn := context.WithTimeout(p.ctx, dialTimeout)
+	defer cancelFn()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed connecting with %v server %v, reason=%v", p.name, addr, err)
+	}
+	p.setServer(conn)
+	return conn, nil
+}
+
+// relay passes through the frames of the client and the server until one of the sides closes
+func (p *proxy) relay(serverW io.Writer, serverR io.Reader) error {
+	errCh := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(serverW, p.clientR)
+		errCh <- err
+	}()
+	go func() {
+		_, err := io.Copy(p.clientW, serverR)
+		errCh <- err
+	}()
+	var err error
+	select {
+	case err = <-errCh:
+	case <-p.ctx.Done():
+		return nil
+	}
+	if err == nil || err == io.EOF || p.ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
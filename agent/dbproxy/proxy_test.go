@@ -0,0 +1,87 @@
+package dbproxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// newFakeServer starts a tcp server that accepts a single connection and
+// handles it with fn, the returned channel has the error of the handler.
+func newFakeServer(t *testing.T, fn func(conn net.Conn) error) (host, port string, errCh chan error) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lis.Close() })
+	errCh = make(chan error, 1)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer conn.Close()
+		_ = conn.SetDeadline(time.Now().Add(time.Second * 10))
+		errCh <- fn(conn)
+	}()
+	host, port, _ = net.SplitHostPort(lis.Addr().String())
+	return
+}
+
+// newClientPipe returns the writer to pass as the client of a proxy and
+// the reader of the packets that the proxy sends to the client.
+func newClientPipe(t *testing.T) (*io.PipeReader, *io.PipeWriter) {
+	r, w := io.Pipe()
+	t.Cleanup(func() { _ = r.Close() })
+	return r, w
+}
+
+// closeOnDone closes the client when the proxy is done, it unblocks the reads of the tests
+func closeOnDone(p *proxy, clientW *io.PipeWriter) {
+	go func() {
+		<-p.Done()
+		_ = clientW.CloseWithError(io.ErrUnexpectedEOF)
+	}()
+}
+
+func newSelfSignedTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}
+}
+
+func waitServer(t *testing.T, errCh chan error) {
+	t.Helper()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("fake server failed: %v", err)
+		}
+	case <-time.After(time.Second * 10):
+		t.Fatal("timeout waiting the fake server")
+	}
+}
@@ -0,0 +1,179 @@
+package dbproxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/hoophq/hoop/common/redistypes"
+)
+
+type redisProxy struct {
+	*proxy
+	host     string
+	port     string
+	user     string
+	password string
+	database string
+	tls      bool
+	insecure bool
+}
+
+// NewRedis returns a proxy that writes the server replies to clientW. The options are:
+//
+//	hostname, port - the address of the server
+//	username, password - the credentials of the server, the username is used with ACL
+//	database - the database index selected after the authentication
+//	tls - connect with the server using TLS when it's "true"
+//	insecure - skip the verification of the server certificate when it's "true"
+//
+// The authentication commands of the client (AUTH, HELLO) are sent with the credentials of the connection.
+func NewRedis(ctx context.Context, clientW io.Writer, opts map[string]string) (*redisProxy, error) {
+	if opts["hostname"] == "" {
+		return nil, fmt.Errorf("missing hostname of the connection")
+	}
+	port := opts["port"]
+	if port == "" {
+		port = "6379"
+	}
+	p := &redisProxy{
+		proxy:    newProxy(ctx, "redis", clientW),
+		host:     opts["hostname"],
+		port:     port,
+		user:     opts["username"],
+		password: opts["password"],
+		database: opts["database"],
+		tls:      opts["tls"] == "true",
+		insecure: opts["insecure"] == "true",
+	}
+	p.serve = p.serveRedis
+	return p, nil
+}
+
+func (p *redisProxy) serveRedis() error {
+	conn, err := p.dial(p.host, p.port)
+	if err != nil {
+		return err
+	}
+	if p.tls {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: p.host, InsecureSkipVerify: p.insecure})
+		ctx, cancelFn := context.WithTimeout(p.ctx, dialTimeout)
+		defer cancelFn()
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return fmt.Errorf("failed tls handshake with redis server: %v", err)
+		}
+		p.setServer(tlsConn)
+		conn = tlsConn
+	}
+	serverR := bufio.NewReader(conn)
+	if p.password != "" {
+		if err := p.exec(conn, serverR, p.authCommand()); err != nil {
+			if _, ok := err.(redisReplyError); ok {
+				return fmt.Errorf("redis %w: %v", ErrAuthFailed, err)
+			}
+			return fmt.Errorf("failed authenticating with redis server: %v", err)
+		}
+	}
+	if p.database != "" && p.database != "0" {
+		if err := p.exec(conn, serverR, redistypes.NewCommand("SELECT", p.database)); err != nil {
+			return fmt.Errorf("failed selecting redis database %v: %v", p.database, err)
+		}
+	}
+	errCh := make(chan error, 2)
+	go func() { errCh <- p.copyCommands(conn) }()
+	go func() {
+		_, err := io.Copy(p.clientW, serverR)
+		errCh <- err
+	}()
+	select {
+	case err = <-errCh:
+	case <-p.ctx.Done():
+		return nil
+	}
+	if err == nil || err == io.EOF || p.ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// exec sends a command to the server and validates its reply
+func (p *redisProxy) exec(conn net.Conn, serverR *bufio.Reader, cmd *redistypes.Command) error {
+	if _, err := conn.Write(cmd.Encode()); err != nil {
+		return err
+	}
+	reply, err := redistypes.DecodeReply(serverR)
+	if err != nil {
+		return err
+	}
+	if reply.IsError() {
+		return redisReplyError(reply.Data)
+	}
+	return nil
+}
+
+// redisReplyError is an error reply of the server, e.g.: WRONGPASS
+type redisReplyError string
+
+func (e redisReplyError) Error() string { return string(e) }
+
+func (p *redisProxy) authCommand() *redistypes.Command {
+	if p.user != "" {
+		return redistypes.NewCommand("AUTH", p.user, p.password)
+	}
+	return redistypes.NewCommand("AUTH", p.password)
+}
+
+// copyCommands sends the commands of the client to the server replacing the credentials of
+// the authentication commands. The connection is already authenticated, the commands are
+// sent to the server anyway to keep the order of the replies.
+func (p *redisProxy) copyCommands(serverW io.Writer) error {
+	clientR := bufio.NewReader(p.clientR)
+	for {
+		cmd, err := redistypes.DecodeCommand(clientR)
+		if err != nil {
+			return err
+		}
+		switch cmd.Name() {
+		case "AUTH":
+			if p.password == "" {
+				if _, err := p.clientW.Write(redistypes.NewSimpleString("OK")); err != nil {
+					return err
+				}
+				continue
+			}
+			cmd.SetArgs(p.authCommand().Args)
+		case "HELLO":
+			cmd.SetArgs(p.helloArgs(cmd.Args))
+		}
+		if _, err := serverW.Write(cmd.Raw()); err != nil {
+			return fmt.Errorf("failed writing command to server: %v", err)
+		}
+	}
+}
+
+// helloArgs replaces the credentials of the AUTH option of the HELLO command
+// HELLO [protover [AUTH username password] [SETNAME clientname]]
+func (p *redisProxy) helloArgs(args [][]byte) [][]byte {
+	newArgs := make([][]byte, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if !strings.EqualFold(string(args[i]), "AUTH") || i+2 >= len(args) {
+			newArgs = append(newArgs, args[i])
+			continue
+		}
+		// skip the credentials of the client
+		i += 2
+		if p.password == "" {
+			continue
+		}
+		user := p.user
+		if user == "" {
+			user = "default"
+		}
+		newArgs = append(newArgs, []byte("AUTH"), []byte(user), []byte(p.password))
+	}
+	return newArgs
+}
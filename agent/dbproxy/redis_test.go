@@ -0,0 +1,117 @@
+package dbproxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/hoophq/hoop/common/redistypes"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedisServer accepts the user hoop with the password secret, the commands of
+// the client are replied with their arguments separated by spaces as a simple string.
+func fakeRedisServer(tlsConfig *tls.Config) func(conn net.Conn) error {
+	return func(conn net.Conn) error {
+		if tlsConfig != nil {
+			conn = tls.Server(conn, tlsConfig)
+		}
+		r := bufio.NewReader(conn)
+		authenticated := false
+		for {
+			cmd, err := redistypes.DecodeCommand(r)
+			if err != nil {
+				return nil
+			}
+			var reply []byte
+			switch {
+			case cmd.Name() == "AUTH" && cmd.String() == "AUTH hoop secret":
+				authenticated = true
+				reply = redistypes.NewSimpleString("OK")
+			case cmd.Name() == "AUTH":
+				reply = redistypes.NewError("WRONGPASS invalid username-password pair or user is disabled.")
+			case !authenticated:
+				reply = redistypes.NewError("NOAUTH Authentication required.")
+			default:
+				reply = redistypes.NewSimpleString(cmd.String())
+			}
+			if _, err := conn.Write(reply); err != nil {
+				return fmt.Errorf("failed writing reply: %v", err)
+			}
+		}
+	}
+}
+
+func TestRedisHandshake(t *testing.T) {
+	tlsConfig := newSelfSignedTLSConfig(t)
+	for _, tt := range []struct {
+		msg       string
+		tlsConfig *tls.Config
+		password  string
+		wantErr   string
+	}{
+		{
+			msg:       "it should authenticate over tls and replace the credentials of the client",
+			tlsConfig: tlsConfig,
+			password:  "secret",
+		},
+		{
+			msg:      "it should authenticate and replace the credentials of the client",
+			password: "secret",
+		},
+		{
+			msg:      "it should report the authentication failure",
+			password: "wrong",
+			wantErr:  "redis authentication failed: WRONGPASS invalid username-password pair or user is disabled.",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			host, port, errCh := newFakeServer(t, fakeRedisServer(tt.tlsConfig))
+			clientR, clientW := newClientPipe(t)
+			opts := map[string]string{"hostname": host, "port": port, "username": "hoop", "password": tt.password, "database": "2"}
+			if tt.tlsConfig != nil {
+				opts["tls"] = "true"
+				opts["insecure"] = "true"
+			}
+			p, err := NewRedis(context.Background(), clientW, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+			onErrCh := make(chan string, 1)
+			var exitCode int
+			p.Run(func(code int, errMsg string) { exitCode = code; onErrCh <- errMsg })
+			closeOnDone(p.proxy, clientW)
+
+			if tt.wantErr != "" {
+				assert.Equal(t, tt.wantErr, <-onErrCh)
+				assert.Equal(t, ExitCodeAuthFailed, exitCode)
+				waitServer(t, errCh)
+				return
+			}
+			clientReader := bufio.NewReader(clientR)
+			for _, tc := range []struct {
+				cmd  *redistypes.Command
+				want string
+			}{
+				{cmd: redistypes.NewCommand("AUTH", "client-user", "noop"), want: "OK"},
+				{cmd: redistypes.NewCommand("HELLO", "2", "AUTH", "client-user", "noop", "SETNAME", "app"), want: "HELLO 2 AUTH hoop secret SETNAME app"},
+				{cmd: redistypes.NewCommand("GET", "key"), want: "GET key"},
+			} {
+				_, _ = p.Write(tc.cmd.Encode())
+				reply, err := redistypes.DecodeReply(clientReader)
+				if err != nil {
+					waitServer(t, errCh)
+					t.Fatal(err)
+				}
+				assert.Equal(t, redistypes.TypeSimpleString, reply.Type)
+				assert.Equal(t, tc.want, string(reply.Data))
+			}
+			_ = p.Close()
+			waitServer(t, errCh)
+		})
+	}
+}
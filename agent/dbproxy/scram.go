@@ -0,0 +1,134 @@
+package dbproxy
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+const scramSHA256Mechanism = "SCRAM-SHA-256"
+
+// scramClient implements the client side of the SCRAM-SHA-256 authentication without channel binding.
+// Postgres ignores the username of the SCRAM messages, it uses the one of the startup message.
+// https://www.postgresql.org/docs/current/sasl-authentication.html
+type scramClient struct {
+	password        string
+	clientNonce     string
+	clientFirstBare string
+	serverSignature []byte
+}
+
+func newSCRAMClient(password string) (*scramClient, error) {
+	nonce := make([]byte, 18)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed generating scram nonce: %v", err)
+	}
+	return newSCRAMClientWithNonce(password, base64.StdEncoding.EncodeToString(nonce)), nil
+}
+
+func newSCRAMClientWithNonce(password, nonce string) *scramClient {
+	return &scramClient{
+		password:        password,
+		clientNonce:     nonce,
+		clientFirstBare: "n=,r=" + nonce,
+	}
+}
+
+// clientFirstMessage returns the message informing that the client doesn't support channel binding
+func (c *scramClient) clientFirstMessage() []byte { return []byte("n,," + c.clientFirstBare) }
+
+// clientFinalMessage computes the proof of the password with the salt and iterations of the server
+func (c *scramClient) clientFinalMessage(serverFirst []byte) ([]byte, error) {
+	attrs := parseSCRAMAttributes(string(serverFirst))
+	nonce, salt, iterations := attrs["r"], attrs["s"], attrs["i"]
+	if !strings.HasPrefix(nonce, c.clientNonce) || len(nonce) == len(c.clientNonce) {
+		return nil, fmt.Errorf("invalid scram server nonce")
+	}
+	saltBytes, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scram salt: %v", err)
+	}
+	iter, err := strconv.Atoi(iterations)
+	if err != nil || iter < 1 {
+		return nil, fmt.Errorf("invalid scram iteration count %q", iterations)
+	}
+	// base64 of the gs2 header "n,,"
+	clientFinalWithoutProof := "c=biws,r=" + nonce
+	authMessage := []byte(c.clientFirstBare + "," + string(serverFirst) + "," + clientFinalWithoutProof)
+
+	saltedPassword := pbkdf2(sha256.New, []byte(c.password), saltBytes, iter)
+	clientKey := hmacSHA256(saltedPassword, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+	clientSignature := hmacSHA256(storedKey[:], authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSignature[i]
+	}
+	serverKey := hmacSHA256(saltedPassword, []byte("Server Key"))
+	c.serverSignature = hmacSHA256(serverKey, authMessage)
+	return []byte(clientFinalWithoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// verifyServerFinal validates that the server knows the password as well
+func (c *scramClient) verifyServerFinal(serverFinal []byte) error {
+	attrs := parseSCRAMAttributes(string(serverFinal))
+	if errMsg, ok := attrs["e"]; ok {
+		return fmt.Errorf("scram %w: %v", ErrAuthFailed, errMsg)
+	}
+	signature, err := base64.StdEncoding.DecodeString(attrs["v"])
+	if err != nil || !hmac.Equal(signature, c.serverSignature) {
+		return fmt.Errorf("invalid scram server signature")
+	}
+	return nil
+}
+
+func parseSCRAMAttributes(msg string) map[string]string {
+	attrs := map[string]string{}
+	for _, attr := range strings.Split(msg, ",") {
+		if key, val, found := strings.Cut(attr, "="); found {
+			attrs[key] = val
+		}
+	}
+	return attrs
+}
+
+// parseSASLMechanisms parses the list of mechanisms of the AuthenticationSASL message
+func parseSASLMechanisms(data []byte) (mechanisms []string) {
+	for _, m := range bytes.Split(data, []byte{0x00}) {
+		if len(m) > 0 {
+			mechanisms = append(mechanisms, string(m))
+		}
+	}
+	return
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// pbkdf2 derives a key with the size of the hash, it requires a single block (RFC 8018)
+func pbkdf2(newHash func() hash.Hash, password, salt []byte, iterations int) []byte {
+	prf := hmac.New(newHash, password)
+	prf.Write(salt)
+	prf.Write(binary.BigEndian.AppendUint32(nil, 1))
+	u := prf.Sum(nil)
+	key := append([]byte{}, u...)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range key {
+			key[j] ^= u[j]
+		}
+	}
+	return key
+}
@@ -0,0 +1,204 @@
+// Package httpproxy implements a reverse proxy of the requests sent by the client to an
+// internal HTTP server. The credentials of the connection are injected as headers of the
+// requests, the client never has access to them.
+package httpproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hoophq/hoop/common/httptypes"
+	"github.com/hoophq/hoop/common/log"
+)
+
+// responseHeaderTimeout is the max time waiting the headers of a response
+const responseHeaderTimeout = 5 * time.Minute
+
+// hopHeaders are the headers of a single connection, they are not forwarded
+// https://www.rfc-editor.org/rfc/rfc9110#section-7.6.1
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Proxy forwards the requests of a client connection to the remote url, the requests
+// are processed in order and each response is written to the client in a single message.
+type Proxy struct {
+	ctx       context.Context
+	cancelFn  context.CancelFunc
+	clientW   io.Writer
+	remoteURL *url.URL
+	headers   http.Header
+	transport *http.Transport
+	reqC      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewProxy returns a proxy to the remote url, the headers are set in all requests
+// replacing the ones sent by the client. The options of the proxy are:
+//
+//	remote_url - the base url of the server, e.g.: https://api.internal:8443/v1
+//	insecure - skip the verification of the certificate of the server when it's "true"
+func NewProxy(ctx context.Context, clientW io.Writer, opts map[string]string, headers http.Header) (*Proxy, error) {
+	remoteURL, err := url.Parse(opts["remote_url"])
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing remote url: %v", err)
+	}
+	if remoteURL.Scheme != "http" && remoteURL.Scheme != "https" {
+		return nil, fmt.Errorf("remote url must have the scheme http or https, got=%q", remoteURL.Scheme)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.ResponseHeaderTimeout = responseHeaderTimeout
+	transport.TLSClientConfig = &tls.Config{
+		ServerName:         remoteURL.Hostname(),
+		InsecureSkipVerify: opts["insecure"] == "true",
+	}
+	ctx, cancelFn := context.WithCancel(ctx)
+	return &Proxy{
+		ctx:       ctx,
+		cancelFn:  cancelFn,
+		clientW:   clientW,
+		remoteURL: remoteURL,
+		headers:   headers,
+		transport: transport,
+		reqC:      make(chan []byte, 1024),
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// Run processes the requests in background, the callback is not called when
+// a request fails, an error response is returned to the client instead.
+func (p *Proxy) Run(_ func(exitCode int, errMsg string)) {
+	go func() {
+		defer p.Close()
+		for {
+			select {
+			case <-p.ctx.Done():
+				return
+			case data := <-p.reqC:
+				if err := p.send(p.roundTrip(data)); err != nil {
+					log.Infof("http proxy closed, reason=%v", err)
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Write enqueues a request encoded with httptypes.EncodeRequest
+func (p *Proxy) Write(data []byte) (int, error) {
+	select {
+	case p.reqC <- bytes.Clone(data):
+		return len(data), nil
+	case <-p.done:
+		return 0, io.EOF
+	}
+}
+
+func (p *Proxy) Done() <-chan struct{} { return p.done }
+
+func (p *Proxy) Close() error {
+	p.closeOnce.Do(func() {
+		p.cancelFn()
+		p.transport.CloseIdleConnections()
+		close(p.done)
+	})
+	return nil
+}
+
+// roundTrip sends the request to the server and returns the encoded response
+func (p *Proxy) roundTrip(data []byte) []byte {
+	req, err := httptypes.DecodeRequest(data)
+	if err != nil {
+		return httptypes.NewErrorResponse(http.StatusBadRequest, fmt.Sprintf("failed decoding request: %v", err))
+	}
+	outReq := req.WithContext(p.ctx)
+	outReq.RequestURI = ""
+	outReq.URL.Scheme = p.remoteURL.Scheme
+	outReq.URL.Host = p.remoteURL.Host
+	outReq.URL.Path, outReq.URL.RawPath = joinURLPath(p.remoteURL, req.URL)
+	outReq.Host = p.remoteURL.Host
+	outReq.Close = false
+	removeHopHeaders(outReq.Header)
+	// an empty value prevents sending the default user agent of the go client
+	if _, ok := outReq.Header["User-Agent"]; !ok {
+		outReq.Header.Set("User-Agent", "")
+	}
+	for key, values := range p.headers {
+		outReq.Header[key] = values
+	}
+	resp, err := p.transport.RoundTrip(outReq)
+	if err != nil {
+		log.Infof("failed sending request to %v, reason=%v", p.remoteURL.Host, err)
+		statusCode := http.StatusBadGateway
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			statusCode = http.StatusGatewayTimeout
+		}
+		return httptypes.NewErrorResponse(statusCode, err.Error())
+	}
+	removeHopHeaders(resp.Header)
+	resp.Close = false
+	encResp, err := httptypes.EncodeResponse(resp)
+	if err != nil {
+		return httptypes.NewErrorResponse(http.StatusBadGateway, err.Error())
+	}
+	return encResp
+}
+
+func (p *Proxy) send(data []byte) error {
+	if _, err := p.clientW.Write(data); err != nil {
+		return fmt.Errorf("failed writing response to client: %v", err)
+	}
+	return nil
+}
+
+func removeHopHeaders(h http.Header) {
+	for _, field := range h.Values("Connection") {
+		for _, key := range strings.Split(field, ",") {
+			h.Del(strings.TrimSpace(key))
+		}
+	}
+	for _, key := range hopHeaders {
+		h.Del(key)
+	}
+}
+
+// joinURLPath prefixes the path of the request with the path of the remote url
+func joinURLPath(base, reqURL *url.URL) (path, rawPath string) {
+	if base.RawPath == "" && reqURL.RawPath == "" {
+		return singleJoiningSlash(base.Path, reqURL.Path), ""
+	}
+	basePath, reqPath := base.EscapedPath(), reqURL.EscapedPath()
+	rawPath = singleJoiningSlash(basePath, reqPath)
+	path, _ = url.PathUnescape(rawPath)
+	return path, rawPath
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash, bslash := strings.HasSuffix(a, "/"), strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
@@ -0,0 +1,159 @@
+package httpproxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoop/common/httptypes"
+	"github.com/stretchr/testify/assert"
+)
+
+// responseWriter receives the responses written to the client, each write is a single response
+type responseWriter chan []byte
+
+func (w responseWriter) Write(data []byte) (int, error) {
+	w <- bytes.Clone(data)
+	return len(data), nil
+}
+
+func newTestProxy(t *testing.T, opts map[string]string, headers http.Header) (*Proxy, responseWriter) {
+	t.Helper()
+	clientW := make(responseWriter, 10)
+	p, err := NewProxy(context.Background(), clientW, opts, headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	p.Run(nil)
+	return p, clientW
+}
+
+// roundTrip writes the request to the proxy and returns the response written to the client
+func roundTrip(t *testing.T, p *Proxy, clientW responseWriter, rawRequest string) (*http.Response, string) {
+	t.Helper()
+	if _, err := p.Write([]byte(rawRequest)); err != nil {
+		t.Fatal(err)
+	}
+	var data []byte
+	select {
+	case data = <-clientW:
+	case <-time.After(time.Second * 5):
+		t.Fatal("timeout waiting for the response")
+	}
+	resp, err := httptypes.DecodeResponse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestProxyRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Connection", "X-Internal")
+		w.Header().Set("X-Internal", "secret")
+		w.Header().Set("X-Request-Path", r.URL.EscapedPath())
+		w.WriteHeader(http.StatusCreated)
+		_, _ = fmt.Fprintf(w, "%s %s?%s host=%s auth=%s agent=%q hop=%q body=%s",
+			r.Method, r.URL.Path, r.URL.RawQuery, r.Host, r.Header.Get("Authorization"),
+			r.Header.Get("User-Agent"), r.Header.Get("X-Hop"), body)
+	}))
+	defer srv.Close()
+	srvURL, _ := url.Parse(srv.URL)
+
+	p, clientW := newTestProxy(t, map[string]string{"remote_url": srv.URL + "/api/v1/"},
+		http.Header{"Authorization": {"Bearer server-token"}})
+	resp, body := roundTrip(t, p, clientW, "POST /users?limit=10 HTTP/1.1\r\n"+
+		"Host: 127.0.0.1:8081\r\n"+
+		"Authorization: Bearer client-token\r\n"+
+		"Connection: X-Hop\r\n"+
+		"X-Hop: value\r\n"+
+		"Content-Length: 11\r\n\r\n"+
+		`{"id": 10}`+"\n")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, fmt.Sprintf("POST /api/v1/users?limit=10 host=%s auth=Bearer server-token agent=\"\" hop=\"\" body={\"id\": 10}\n", srvURL.Host), body)
+	assert.Empty(t, resp.Header.Get("X-Internal"), "the hop headers of the response must be removed")
+
+	resp, _ = roundTrip(t, p, clientW, "GET /files/a%2Fb HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Equal(t, "/api/v1/files/a%2Fb", resp.Header.Get("X-Request-Path"), "the escaped path must be preserved")
+
+	resp, body = roundTrip(t, p, clientW, "invalid request\r\n\r\n")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, "failed decoding request")
+}
+
+func TestProxyRemoteTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	for _, tt := range []struct {
+		msg        string
+		insecure   string
+		wantStatus int
+		wantBody   string
+	}{
+		{msg: "it should skip the verification of the certificate in insecure mode", insecure: "true", wantStatus: http.StatusOK, wantBody: "ok"},
+		{msg: "it should fail when the certificate is not trusted", wantStatus: http.StatusBadGateway, wantBody: "certificate signed by unknown authority"},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			p, clientW := newTestProxy(t, map[string]string{"remote_url": srv.URL, "insecure": tt.insecure}, nil)
+			resp, body := roundTrip(t, p, clientW, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Contains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestProxyRemoteUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	p, clientW := newTestProxy(t, map[string]string{"remote_url": srv.URL}, nil)
+	resp, body := roundTrip(t, p, clientW, "GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Contains(t, body, "connection refused")
+}
+
+func TestNewProxyRemoteURL(t *testing.T) {
+	for _, remoteURL := range []string{"ftp://api.internal", "api.internal:8080", "://invalid"} {
+		_, err := NewProxy(context.Background(), io.Discard, map[string]string{"remote_url": remoteURL}, nil)
+		assert.Error(t, err, remoteURL)
+	}
+}
+
+func TestJoinURLPath(t *testing.T) {
+	for _, tt := range []struct {
+		base        string
+		reqPath     string
+		wantPath    string
+		wantRawPath string
+	}{
+		{base: "https://api.internal", reqPath: "/users", wantPath: "/users"},
+		{base: "https://api.internal/", reqPath: "/users", wantPath: "/users"},
+		{base: "https://api.internal/v1", reqPath: "/users", wantPath: "/v1/users"},
+		{base: "https://api.internal/v1/", reqPath: "/users/", wantPath: "/v1/users/"},
+		{base: "https://api.internal/v1", reqPath: "/a%2Fb", wantPath: "/v1/a/b", wantRawPath: "/v1/a%2Fb"},
+		{base: "https://api.internal/a%2Fb", reqPath: "/users", wantPath: "/a/b/users", wantRawPath: "/a%2Fb/users"},
+	} {
+		t.Run(tt.base+tt.reqPath, func(t *testing.T) {
+			base, _ := url.Parse(tt.base)
+			reqURL, _ := url.Parse(tt.reqPath)
+			path, rawPath := joinURLPath(base, reqURL)
+			assert.Equal(t, tt.wantPath, path)
+			assert.Equal(t, tt.wantRawPath, rawPath)
+			assert.False(t, strings.Contains(path, "//"))
+		})
+	}
+}
@@ -29,6 +29,9 @@ var (
 
 func Run() {
 	_, _ = monitoring.StartSentry()
+	if _, err := monitoring.StartMetricsExporter("hoopagent"); err != nil {
+		log.Warnf("failed starting metrics exporter, reason=%v", err)
+	}
 	config, err := agentconfig.Load()
 	if err != nil {
 		log.With("version", vi.Version).Fatal(err)
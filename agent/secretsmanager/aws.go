@@ -5,21 +5,32 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/smithy-go/logging"
 	"github.com/hoophq/hoop/common/log"
-	"github.com/hoophq/hoop/common/memory"
+)
+
+var (
+	awsProviderMu sync.Mutex
+	awsProv       *awsProvider
 )
 
 type awsProvider struct {
 	client *secretsmanager.Client
-	cache  memory.Store
+	cache  *secretCache
 }
 
-func newAwsProvider() (*awsProvider, error) {
+// getAwsProvider returns the provider shared by all sessions
+func getAwsProvider() (*awsProvider, error) {
+	awsProviderMu.Lock()
+	defer awsProviderMu.Unlock()
+	if awsProv != nil {
+		return awsProv, nil
+	}
 	cfg, err := config.LoadDefaultConfig(context.Background())
 	if err != nil {
 		return nil, err
@@ -31,32 +42,29 @@ func newAwsProvider() (*awsProvider, error) {
 		// TODO: add zap as logger
 		o.Logger = logging.NewStandardLogger(os.Stdout)
 	})
-	return &awsProvider{svc, memory.New()}, nil
+	awsProv = &awsProvider{svc, getCache(secretProviderAWSSecretsManager, false)}
+	return awsProv, nil
 }
 
 func (p *awsProvider) GetKey(secretID, secretKey string) (string, error) {
-	if obj := p.cache.Get(secretID); obj != nil {
-		if keyVal, ok := obj.(map[string]any); ok {
-			if v, ok := keyVal[secretKey]; ok {
-				return fmt.Sprintf("%v", v), nil
-			}
-			return "", fmt.Errorf("secret key not found. secret=%v, key=%v", secretID, secretKey)
+	secret, err := p.cache.get(secretID, func() (*secretValue, error) {
+		input := &secretsmanager.GetSecretValueInput{
+			SecretId: &secretID,
 		}
-	}
-
-	input := &secretsmanager.GetSecretValueInput{
-		SecretId: &secretID,
-	}
-	result, err := p.client.GetSecretValue(context.Background(), input)
+		result, err := p.client.GetSecretValue(context.Background(), input)
+		if err != nil {
+			return nil, fmt.Errorf("(%v) %v", secretID, err)
+		}
+		var keyValSecret map[string]any
+		if err := json.Unmarshal([]byte(*result.SecretString), &keyValSecret); err != nil {
+			return nil, fmt.Errorf("failed deserializing secret key/val, err=%v", err)
+		}
+		return &secretValue{data: keyValSecret}, nil
+	})
 	if err != nil {
-		return "", fmt.Errorf("(%v) %v", secretID, err)
-	}
-	var keyValSecret map[string]any
-	if err := json.Unmarshal([]byte(*result.SecretString), &keyValSecret); err != nil {
-		return "", fmt.Errorf("failed deserializing secret key/val, err=%v", err)
+		return "", err
 	}
-	if v, ok := keyValSecret[secretKey]; ok {
-		p.cache.Set(secretID, keyValSecret)
+	if v, ok := secret.data[secretKey]; ok {
 		return fmt.Sprintf("%v", v), nil
 	}
 	return "", fmt.Errorf("secret id %s found, but key %s was not", secretID, secretKey)
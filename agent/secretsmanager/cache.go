@@ -0,0 +1,250 @@
+package secretsmanager
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/common/monitoring"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"golang.org/x/sync/singleflight"
+)
+
+const defaultCacheTTL = time.Minute * 5
+
+var (
+	cachesMu sync.Mutex
+	caches   = map[secretProviderType]*secretCache{}
+
+	sessionSecretsMu sync.Mutex
+	// session id -> secrets used to decode the connection of the session
+	sessionSecrets = map[string][]secretRef{}
+
+	cacheMetricsOnce sync.Once
+)
+
+type secretRef struct {
+	provider secretProviderType
+	secretID string
+}
+
+// secretValue is the content of a secret. Secrets with a lease
+// are issued for a single session and they are never cached.
+type secretValue struct {
+	data          map[string]any
+	leaseID       string
+	leaseDuration int
+}
+
+type cacheEntry struct {
+	val       *secretValue
+	expiresAt time.Time
+}
+
+// secretCache keeps the secrets of a provider for a period of time (ttl),
+// concurrent lookups of the same secret are fetched only once.
+type secretCache struct {
+	provider secretProviderType
+	ttl      time.Duration
+	// the provider may issue secrets with a lease,
+	// lookups are deduplicated only when it's known that the secret has no lease
+	dynamic bool
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	// secret id -> the secret has a lease
+	leased map[string]bool
+	group  singleflight.Group
+
+	hits   atomic.Int64
+	misses atomic.Int64
+	errors atomic.Int64
+}
+
+// cacheStat are the counters of the secrets cache of a provider
+type cacheStat struct {
+	provider string
+	hits     int64
+	misses   int64
+	errors   int64
+}
+
+// cacheTTL returns the ttl of the provider, it could be configured for all providers
+// with HOOP_SECRETS_CACHE_TTL or per provider, e.g.: HOOP_SECRETS_CACHE_TTL_AWS.
+// A zero value disables the cache.
+func cacheTTL(provider secretProviderType) time.Duration {
+	providerEnv := "HOOP_SECRETS_CACHE_TTL_" + strings.ToUpper(strings.TrimPrefix(string(provider), "_"))
+	for _, envKey := range []string{providerEnv, "HOOP_SECRETS_CACHE_TTL"} {
+		val := os.Getenv(envKey)
+		if val == "" {
+			continue
+		}
+		ttl, err := time.ParseDuration(val)
+		if err != nil || ttl < 0 {
+			log.Warnf("invalid %v duration %q, using the default (%v)", envKey, val, defaultCacheTTL)
+			return defaultCacheTTL
+		}
+		return ttl
+	}
+	return defaultCacheTTL
+}
+
+func getCache(provider secretProviderType, dynamic bool) *secretCache {
+	cacheMetricsOnce.Do(registerCacheMetrics)
+	cachesMu.Lock()
+	defer cachesMu.Unlock()
+	if c, ok := caches[provider]; ok {
+		return c
+	}
+	c := &secretCache{
+		provider: provider,
+		ttl:      cacheTTL(provider),
+		dynamic:  dynamic,
+		entries:  map[string]cacheEntry{},
+		leased:   map[string]bool{},
+	}
+	caches[provider] = c
+	return c
+}
+
+func (c *secretCache) get(secretID string, fetchFn func() (*secretValue, error)) (*secretValue, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[secretID]
+	leased, known := c.leased[secretID]
+	c.mu.Unlock()
+	if ok && time.Now().UTC().Before(entry.expiresAt) {
+		c.hits.Add(1)
+		return entry.val, nil
+	}
+	c.misses.Add(1)
+
+	var val *secretValue
+	var err error
+	if leased || (c.dynamic && !known) {
+		val, err = fetchFn()
+		if err == nil {
+			c.set(secretID, val)
+		}
+	} else {
+		var v any
+		v, err, _ = c.group.Do(secretID, func() (any, error) {
+			val, err := fetchFn()
+			if err == nil {
+				c.set(secretID, val)
+			}
+			return val, err
+		})
+		val, _ = v.(*secretValue)
+	}
+	if err != nil {
+		c.errors.Add(1)
+		return nil, err
+	}
+	return val, nil
+}
+
+func (c *secretCache) set(secretID string, val *secretValue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.leased[secretID] = val.leaseID != ""
+	if val.leaseID != "" || c.ttl == 0 {
+		return
+	}
+	c.entries[secretID] = cacheEntry{val: val, expiresAt: time.Now().UTC().Add(c.ttl)}
+}
+
+// invalidate removes the secret from the cache, the next lookup fetches it from the provider
+func (c *secretCache) invalidate(secretID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, secretID)
+	c.group.Forget(secretID)
+}
+
+func (c *secretCache) stat() cacheStat {
+	return cacheStat{
+		provider: strings.TrimPrefix(string(c.provider), "_"),
+		hits:     c.hits.Load(),
+		misses:   c.misses.Load(),
+		errors:   c.errors.Load(),
+	}
+}
+
+// registerCacheMetrics reports the counters of the caches of each provider, the hit
+// ratio of a provider is the rate of hits divided by the rate of hits and misses
+func registerCacheMetrics() {
+	meter := monitoring.Meter()
+	hits, err := meter.Int64ObservableCounter("hoop.agent.secrets_cache.hits",
+		metric.WithDescription("The number of secret lookups served by the cache"))
+	if err != nil {
+		log.Warnf("failed registering secrets cache metrics, reason=%v", err)
+		return
+	}
+	misses, err := meter.Int64ObservableCounter("hoop.agent.secrets_cache.misses",
+		metric.WithDescription("The number of secret lookups fetched from the provider"))
+	if err != nil {
+		log.Warnf("failed registering secrets cache metrics, reason=%v", err)
+		return
+	}
+	errors, err := meter.Int64ObservableCounter("hoop.agent.secrets_cache.errors",
+		metric.WithDescription("The number of secret lookups that failed fetching from the provider"))
+	if err != nil {
+		log.Warnf("failed registering secrets cache metrics, reason=%v", err)
+		return
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		cachesMu.Lock()
+		defer cachesMu.Unlock()
+		for _, c := range caches {
+			stat := c.stat()
+			attrs := metric.WithAttributes(attribute.String("provider", stat.provider))
+			o.ObserveInt64(hits, stat.hits, attrs)
+			o.ObserveInt64(misses, stat.misses, attrs)
+			o.ObserveInt64(errors, stat.errors, attrs)
+		}
+		return nil
+	}, hits, misses, errors)
+	if err != nil {
+		log.Warnf("failed registering secrets cache metrics, reason=%v", err)
+	}
+}
+
+func addSessionSecrets(sessionID string, refs []secretRef) {
+	if len(refs) == 0 {
+		return
+	}
+	sessionSecretsMu.Lock()
+	defer sessionSecretsMu.Unlock()
+	sessionSecrets[sessionID] = refs
+}
+
+// InvalidateSession removes from the cache the secrets used by the session,
+// it must be called when the credentials of the connection are rejected, e.g.: the secret was rotated.
+func InvalidateSession(sessionID string) {
+	sessionSecretsMu.Lock()
+	refs := sessionSecrets[sessionID]
+	sessionSecretsMu.Unlock()
+	for _, ref := range refs {
+		cachesMu.Lock()
+		c, ok := caches[ref.provider]
+		cachesMu.Unlock()
+		if ok {
+			c.invalidate(ref.secretID)
+			log.Infof("session=%v - secret %v of provider %v invalidated", sessionID, ref.secretID, ref.provider)
+		}
+	}
+}
+
+// CloseSession revokes the dynamic secrets issued for the session
+// and releases the references of the secrets used by the session.
+func CloseSession(sessionID string) {
+	sessionSecretsMu.Lock()
+	delete(sessionSecrets, sessionID)
+	sessionSecretsMu.Unlock()
+	revokeSessionLeases(sessionID)
+}
@@ -0,0 +1,217 @@
+package secretsmanager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func newTestCache(ttl time.Duration, dynamic bool) *secretCache {
+	return &secretCache{
+		provider: "_test",
+		ttl:      ttl,
+		dynamic:  dynamic,
+		entries:  map[string]cacheEntry{},
+		leased:   map[string]bool{},
+	}
+}
+
+// countingFetch returns a fetch function that counts its calls, the secret has a lease when leaseID is set
+func countingFetch(calls *atomic.Int64, leaseID string) func() (*secretValue, error) {
+	return func() (*secretValue, error) {
+		n := calls.Add(1)
+		return &secretValue{data: map[string]any{"version": n}, leaseID: leaseID}, nil
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	for _, tt := range []struct {
+		msg  string
+		env  map[string]string
+		want time.Duration
+	}{
+		{msg: "it should use the default ttl", want: defaultCacheTTL},
+		{msg: "it should use the ttl of all providers", env: map[string]string{"HOOP_SECRETS_CACHE_TTL": "30s"}, want: time.Second * 30},
+		{msg: "it should prefer the ttl of the provider", env: map[string]string{"HOOP_SECRETS_CACHE_TTL": "30s", "HOOP_SECRETS_CACHE_TTL_AWS": "1m"}, want: time.Minute},
+		{msg: "it should disable the cache with a zero ttl", env: map[string]string{"HOOP_SECRETS_CACHE_TTL_AWS": "0s"}, want: 0},
+		{msg: "it should use the default ttl with invalid durations", env: map[string]string{"HOOP_SECRETS_CACHE_TTL_AWS": "-1m"}, want: defaultCacheTTL},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			t.Setenv("HOOP_SECRETS_CACHE_TTL", "")
+			t.Setenv("HOOP_SECRETS_CACHE_TTL_AWS", "")
+			for key, val := range tt.env {
+				t.Setenv(key, val)
+			}
+			assert.Equal(t, tt.want, cacheTTL(secretProviderAWSSecretsManager))
+		})
+	}
+}
+
+func TestSecretCacheTTL(t *testing.T) {
+	c := newTestCache(time.Minute, false)
+	var calls atomic.Int64
+	for i := 0; i < 3; i++ {
+		val, err := c.get("db", countingFetch(&calls, ""))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), val.data["version"])
+	}
+	assert.Equal(t, cacheStat{provider: "test", hits: 2, misses: 1}, c.stat())
+
+	// expire the entry
+	c.mu.Lock()
+	c.entries["db"] = cacheEntry{val: c.entries["db"].val, expiresAt: time.Now().UTC().Add(-time.Second)}
+	c.mu.Unlock()
+	val, err := c.get("db", countingFetch(&calls, ""))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), val.data["version"], "the secret must be fetched again after the ttl")
+
+	disabled := newTestCache(0, false)
+	for i := 1; i <= 2; i++ {
+		val, err := disabled.get("db", countingFetch(&calls, ""))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2+i), val.data["version"], "the secret must not be cached with a zero ttl")
+	}
+}
+
+func TestSecretCacheErrors(t *testing.T) {
+	c := newTestCache(time.Minute, false)
+	_, err := c.get("db", func() (*secretValue, error) { return nil, fmt.Errorf("throttled") })
+	assert.EqualError(t, err, "throttled")
+	var calls atomic.Int64
+	val, err := c.get("db", countingFetch(&calls, ""))
+	assert.NoError(t, err, "the errors must not be cached")
+	assert.Equal(t, int64(1), val.data["version"])
+	assert.Equal(t, cacheStat{provider: "test", misses: 2, errors: 1}, c.stat())
+}
+
+func TestSecretCacheSingleflight(t *testing.T) {
+	for _, tt := range []struct {
+		msg       string
+		dynamic   bool
+		wantCalls int64
+	}{
+		{msg: "it should fetch concurrent lookups only once", wantCalls: 1},
+		{msg: "it should not deduplicate lookups of unknown secrets of dynamic providers", dynamic: true, wantCalls: 5},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			c := newTestCache(time.Minute, tt.dynamic)
+			var calls atomic.Int64
+			release := make(chan struct{})
+			fetchFn := func() (*secretValue, error) {
+				<-release
+				return countingFetch(&calls, "")()
+			}
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := c.get("db", fetchFn)
+					assert.NoError(t, err)
+				}()
+			}
+			// wait until all the lookups are in flight
+			assert.Eventually(t, func() bool { return c.stat().misses == 5 }, time.Second*5, time.Millisecond)
+			close(release)
+			wg.Wait()
+			assert.Equal(t, tt.wantCalls, calls.Load())
+		})
+	}
+}
+
+func TestSecretCacheLeased(t *testing.T) {
+	c := newTestCache(time.Minute, true)
+	var calls atomic.Int64
+	for i := 1; i <= 3; i++ {
+		val, err := c.get("database/creds/app", countingFetch(&calls, "lease-id"))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(i), val.data["version"], "the secrets with a lease must be issued for each session")
+	}
+	assert.Empty(t, c.entries)
+	assert.True(t, c.leased["database/creds/app"])
+}
+
+func TestInvalidateSession(t *testing.T) {
+	c := newTestCache(time.Minute, false)
+	cachesMu.Lock()
+	caches[c.provider] = c
+	cachesMu.Unlock()
+	t.Cleanup(func() {
+		cachesMu.Lock()
+		delete(caches, c.provider)
+		cachesMu.Unlock()
+		CloseSession("sid-1")
+	})
+
+	var calls atomic.Int64
+	_, _ = c.get("db", countingFetch(&calls, ""))
+	_, _ = c.get("other", countingFetch(&calls, ""))
+	addSessionSecrets("sid-1", []secretRef{{provider: c.provider, secretID: "db"}})
+
+	InvalidateSession("sid-1")
+	val, err := c.get("db", countingFetch(&calls, ""))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), val.data["version"], "the secret of the session must be fetched again")
+	val, err = c.get("other", countingFetch(&calls, ""))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), val.data["version"], "the secrets of other sessions must be kept")
+
+	CloseSession("sid-1")
+	sessionSecretsMu.Lock()
+	_, ok := sessionSecrets["sid-1"]
+	sessionSecretsMu.Unlock()
+	assert.False(t, ok)
+}
+
+func TestCacheMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	registerCacheMetrics()
+
+	c := newTestCache(time.Minute, false)
+	cachesMu.Lock()
+	caches[c.provider] = c
+	cachesMu.Unlock()
+	defer func() {
+		cachesMu.Lock()
+		delete(caches, c.provider)
+		cachesMu.Unlock()
+	}()
+	var calls atomic.Int64
+	for i := 0; i < 3; i++ {
+		_, _ = c.get("db", countingFetch(&calls, ""))
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int64{}
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				continue
+			}
+			for _, point := range sum.DataPoints {
+				if provider, _ := point.Attributes.Value(attribute.Key("provider")); provider.AsString() == "test" {
+					got[m.Name] = point.Value
+				}
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{
+		"hoop.agent.secrets_cache.hits":   2,
+		"hoop.agent.secrets_cache.misses": 1,
+		"hoop.agent.secrets_cache.errors": 0,
+	}, got)
+}
@@ -17,19 +17,27 @@ const (
 	secretProviderAWSSecretsManager secretProviderType = "_aws"
 	// fetches secrets from environment variables mapped as json in unix environments
 	secretProviderEnvJSON secretProviderType = "_envjson"
+	// fetch secrets from hashicorp vault, it could be kv secrets
+	// or dynamic credentials that are revoked when the session ends.
+	secretProviderVault secretProviderType = "_vault"
 )
 
 // Decode environment variables based on the provider of a certain env.
 // When a value contains a _<provider>:<secret-id>:<secret-key> it will load
 // the value from an external source. If the provider isn't implemented then
 // it will be a noop.
-func Decode(envVars map[string]any) (map[string]any, error) {
+//
+// The secrets are cached by provider, dynamic secrets issued for the session
+// are revoked when calling CloseSession.
+func Decode(sessionID string, envVars map[string]any) (map[string]any, error) {
 	providerSingleton := map[secretProviderType]secretsGetter{
 		secretProviderAWSSecretsManager: nil,
 		secretProviderEnvJSON:           nil,
+		secretProviderVault:             nil,
 	}
 	decodedEnvVars := map[string]any{}
 	var errors []string
+	var refs []secretRef
 	for envKey, encEnvVal := range envVars {
 		attr, err := decodeVal(encEnvVal)
 		if err != nil {
@@ -46,8 +54,9 @@ func Decode(envVars map[string]any) (map[string]any, error) {
 		case secretProviderAWSSecretsManager:
 			provider = providerSingleton[secretProviderAWSSecretsManager]
 			if provider == nil {
-				awsProv, err := newAwsProvider()
+				awsProv, err := getAwsProvider()
 				if err != nil {
+					revokeSessionLeases(sessionID)
 					return nil, fmt.Errorf("failed initializing aws provider, err=%v", err)
 				}
 				providerSingleton[secretProviderAWSSecretsManager] = awsProv
@@ -60,11 +69,23 @@ func Decode(envVars map[string]any) (map[string]any, error) {
 				providerSingleton[secretProviderEnvJSON] = envJsonProv
 				provider = envJsonProv
 			}
+		case secretProviderVault:
+			provider = providerSingleton[secretProviderVault]
+			if provider == nil {
+				vaultProv, err := newVaultProvider(sessionID)
+				if err != nil {
+					revokeSessionLeases(sessionID)
+					return nil, fmt.Errorf("failed initializing vault provider, err=%v", err)
+				}
+				providerSingleton[secretProviderVault] = vaultProv
+				provider = vaultProv
+			}
 		default:
 			// it's not an secrets manager env definition
 			decodedEnvVars[envKey] = encEnvVal
 			continue
 		}
+		refs = append(refs, secretRef{attr.provider, attr.secretID})
 		val, err := provider.GetKey(attr.secretID, attr.secretKey)
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("%s %v", envKey, err))
@@ -73,8 +94,10 @@ func Decode(envVars map[string]any) (map[string]any, error) {
 		decodedEnvVars[envKey] = base64.StdEncoding.EncodeToString([]byte(val))
 	}
 	if len(errors) > 0 {
+		revokeSessionLeases(sessionID)
 		return nil, fmt.Errorf("%q", errors)
 	}
+	addSessionSecrets(sessionID, refs)
 	return decodedEnvVars, nil
 }
 
@@ -0,0 +1,430 @@
+package secretsmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hoophq/hoop/common/log"
+)
+
+const (
+	vaultAuthMethodToken      = "token"
+	vaultAuthMethodAppRole    = "approle"
+	vaultAuthMethodKubernetes = "kubernetes"
+
+	defaultVaultK8sTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	vaultRequestTimeout      = time.Second * 15
+)
+
+var (
+	vaultClientMu sync.Mutex
+	vaultClient   *vaultHttpClient
+
+	vaultLeasesMu sync.Mutex
+	// session id -> lease ids issued for the session
+	vaultLeases = map[string][]string{}
+)
+
+type vaultConfig struct {
+	address    string
+	namespace  string
+	authMethod string
+	authMount  string
+
+	token        string
+	roleID       string
+	secretID     string
+	k8sRole      string
+	k8sTokenPath string
+}
+
+// loadVaultConfig loads the configuration from the environment of the agent.
+// The authentication method is inferred from the credentials when VAULT_AUTH_METHOD is not set.
+func loadVaultConfig() (*vaultConfig, error) {
+	c := &vaultConfig{
+		address:      strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/"),
+		namespace:    os.Getenv("VAULT_NAMESPACE"),
+		authMethod:   os.Getenv("VAULT_AUTH_METHOD"),
+		authMount:    os.Getenv("VAULT_AUTH_MOUNT"),
+		token:        os.Getenv("VAULT_TOKEN"),
+		roleID:       os.Getenv("VAULT_ROLE_ID"),
+		secretID:     os.Getenv("VAULT_SECRET_ID"),
+		k8sRole:      os.Getenv("VAULT_K8S_ROLE"),
+		k8sTokenPath: os.Getenv("VAULT_K8S_TOKEN_PATH"),
+	}
+	if c.address == "" {
+		return nil, fmt.Errorf("missing VAULT_ADDR environment variable")
+	}
+	if c.authMethod == "" {
+		switch {
+		case c.token != "":
+			c.authMethod = vaultAuthMethodToken
+		case c.roleID != "":
+			c.authMethod = vaultAuthMethodAppRole
+		case c.k8sRole != "":
+			c.authMethod = vaultAuthMethodKubernetes
+		}
+	}
+	switch c.authMethod {
+	case vaultAuthMethodToken:
+		if c.token == "" {
+			return nil, fmt.Errorf("missing VAULT_TOKEN environment variable")
+		}
+	case vaultAuthMethodAppRole:
+		if c.roleID == "" || c.secretID == "" {
+			return nil, fmt.Errorf("missing VAULT_ROLE_ID or VAULT_SECRET_ID environment variables")
+		}
+		if c.authMount == "" {
+			c.authMount = vaultAuthMethodAppRole
+		}
+	case vaultAuthMethodKubernetes:
+		if c.k8sRole == "" {
+			return nil, fmt.Errorf("missing VAULT_K8S_ROLE environment variable")
+		}
+		if c.authMount == "" {
+			c.authMount = vaultAuthMethodKubernetes
+		}
+		if c.k8sTokenPath == "" {
+			c.k8sTokenPath = defaultVaultK8sTokenPath
+		}
+	case "":
+		return nil, fmt.Errorf("missing vault credentials, set VAULT_TOKEN, VAULT_ROLE_ID/VAULT_SECRET_ID or VAULT_K8S_ROLE")
+	default:
+		return nil, fmt.Errorf("unknown vault auth method %q", c.authMethod)
+	}
+	return c, nil
+}
+
+type vaultSecret struct {
+	LeaseID       string         `json:"lease_id"`
+	LeaseDuration int            `json:"lease_duration"`
+	Renewable     bool           `json:"renewable"`
+	Data          map[string]any `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+type vaultHttpClient struct {
+	config *vaultConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	// mount path -> kv version
+	kvMounts map[string]string
+	// paths that could not be looked up, they are read as they are
+	unknownMountPaths map[string]struct{}
+}
+
+func newVaultHttpClient(c *vaultConfig) (*vaultHttpClient, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: os.Getenv("VAULT_SKIP_VERIFY") == "true"}
+	if caCertFile := os.Getenv("VAULT_CACERT"); caCertFile != "" {
+		caCert, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading VAULT_CACERT: %v", err)
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("failed parsing VAULT_CACERT, no certificates found")
+		}
+		tlsConfig.RootCAs = certPool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &vaultHttpClient{
+		config:            c,
+		client:            &http.Client{Transport: transport, Timeout: vaultRequestTimeout},
+		token:             c.token,
+		kvMounts:          map[string]string{},
+		unknownMountPaths: map[string]struct{}{},
+	}, nil
+}
+
+// getVaultClient returns the client shared by all sessions,
+// it keeps the login token until it expires.
+func getVaultClient() (*vaultHttpClient, error) {
+	vaultClientMu.Lock()
+	defer vaultClientMu.Unlock()
+	if vaultClient != nil {
+		return vaultClient, nil
+	}
+	config, err := loadVaultConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := newVaultHttpClient(config)
+	if err != nil {
+		return nil, err
+	}
+	vaultClient = client
+	return vaultClient, nil
+}
+
+func (c *vaultHttpClient) do(method, path, token string, body any) (*vaultSecret, int, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, 0, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	ctx, cancelFn := context.WithTimeout(context.Background(), vaultRequestTimeout)
+	defer cancelFn()
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/v1/%s", c.config.address, path), reqBody)
+	if err != nil {
+		return nil, 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if c.config.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.config.namespace)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	var secret vaultSecret
+	if len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, &secret); err != nil {
+			return nil, resp.StatusCode, fmt.Errorf("failed decoding response (%v): %v", resp.StatusCode, err)
+		}
+	}
+	if resp.StatusCode > 299 {
+		errMsg := strings.Join(secret.Errors, ", ")
+		if errMsg == "" {
+			errMsg = http.StatusText(resp.StatusCode)
+		}
+		return nil, resp.StatusCode, fmt.Errorf("%s %s (%v): %v", method, path, resp.StatusCode, errMsg)
+	}
+	return &secret, resp.StatusCode, nil
+}
+
+// login obtains a new token when the auth method isn't a static token
+func (c *vaultHttpClient) login() (string, error) {
+	var loginPath string
+	var body map[string]string
+	switch c.config.authMethod {
+	case vaultAuthMethodToken:
+		return c.config.token, nil
+	case vaultAuthMethodAppRole:
+		loginPath = fmt.Sprintf("auth/%s/login", c.config.authMount)
+		body = map[string]string{"role_id": c.config.roleID, "secret_id": c.config.secretID}
+	case vaultAuthMethodKubernetes:
+		jwt, err := os.ReadFile(c.config.k8sTokenPath)
+		if err != nil {
+			return "", fmt.Errorf("failed reading kubernetes service account token: %v", err)
+		}
+		loginPath = fmt.Sprintf("auth/%s/login", c.config.authMount)
+		body = map[string]string{"role": c.config.k8sRole, "jwt": strings.TrimSpace(string(jwt))}
+	}
+	secret, _, err := c.do(http.MethodPost, loginPath, "", body)
+	if err != nil {
+		return "", fmt.Errorf("failed authenticating with %v: %v", c.config.authMethod, err)
+	}
+	if secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return "", fmt.Errorf("failed authenticating with %v: empty client token", c.config.authMethod)
+	}
+	c.token = secret.Auth.ClientToken
+	c.tokenExpiry = time.Time{}
+	if secret.Auth.LeaseDuration > 0 {
+		// renew it before it expires
+		ttl := time.Duration(secret.Auth.LeaseDuration) * time.Second
+		c.tokenExpiry = time.Now().UTC().Add(ttl - ttl/10)
+	}
+	log.Infof("vault - authenticated with %v, ttl=%vs", c.config.authMethod, secret.Auth.LeaseDuration)
+	return c.token, nil
+}
+
+func (c *vaultHttpClient) getToken(forceLogin bool) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expired := !c.tokenExpiry.IsZero() && time.Now().UTC().After(c.tokenExpiry)
+	if c.token == "" || expired || forceLogin {
+		return c.login()
+	}
+	return c.token, nil
+}
+
+// request performs an authenticated request, it logs in again
+// when the token is denied for not static tokens.
+func (c *vaultHttpClient) request(method, path string, body any) (*vaultSecret, error) {
+	token, err := c.getToken(false)
+	if err != nil {
+		return nil, err
+	}
+	secret, statusCode, err := c.do(method, path, token, body)
+	if statusCode == http.StatusForbidden && c.config.authMethod != vaultAuthMethodToken {
+		if token, err = c.getToken(true); err != nil {
+			return nil, err
+		}
+		secret, _, err = c.do(method, path, token, body)
+	}
+	return secret, err
+}
+
+// resolveKVPath returns the api path of secrets stored in kv version 2 mounts,
+// e.g.: secret/myapp -> secret/data/myapp. Other paths are returned as they are.
+func (c *vaultHttpClient) resolveKVPath(secretPath string) string {
+	c.mu.Lock()
+	if _, ok := c.unknownMountPaths[secretPath]; ok {
+		c.mu.Unlock()
+		return secretPath
+	}
+	for mountPath, version := range c.kvMounts {
+		if strings.HasPrefix(secretPath, mountPath) {
+			c.mu.Unlock()
+			return kvPath(mountPath, version, secretPath)
+		}
+	}
+	c.mu.Unlock()
+
+	// the token may not have access to this endpoint, in this case
+	// the secret must be configured with the api path
+	token, err := c.getToken(false)
+	if err != nil {
+		return secretPath
+	}
+	secret, _, err := c.do(http.MethodGet, "sys/internal/ui/mounts/"+secretPath, token, nil)
+	if err != nil || secret.Data == nil {
+		log.Debugf("vault - unable to lookup mount of %v, err=%v", secretPath, err)
+		c.mu.Lock()
+		c.unknownMountPaths[secretPath] = struct{}{}
+		c.mu.Unlock()
+		return secretPath
+	}
+	mountPath, _ := secret.Data["path"].(string)
+	if mountPath == "" || !strings.HasPrefix(secretPath, mountPath) {
+		return secretPath
+	}
+	var version string
+	if options, ok := secret.Data["options"].(map[string]any); ok {
+		version, _ = options["version"].(string)
+	}
+	c.mu.Lock()
+	c.kvMounts[mountPath] = version
+	c.mu.Unlock()
+	return kvPath(mountPath, version, secretPath)
+}
+
+func kvPath(mountPath, version, secretPath string) string {
+	if version != "2" || strings.HasPrefix(secretPath, mountPath+"data/") {
+		return secretPath
+	}
+	return mountPath + "data/" + strings.TrimPrefix(secretPath, mountPath)
+}
+
+func (c *vaultHttpClient) read(secretPath string) (*vaultSecret, error) {
+	secret, err := c.request(http.MethodGet, c.resolveKVPath(secretPath), nil)
+	if err != nil {
+		return nil, err
+	}
+	// kv version 2 wraps the data with the metadata of the secret
+	if data, ok := secret.Data["data"].(map[string]any); ok {
+		if _, hasMetadata := secret.Data["metadata"]; hasMetadata {
+			secret.Data = data
+		}
+	}
+	return secret, nil
+}
+
+func (c *vaultHttpClient) revokeLease(leaseID string) error {
+	_, err := c.request(http.MethodPut, "sys/leases/revoke", map[string]string{"lease_id": leaseID})
+	return err
+}
+
+// vaultProvider reads kv secrets and issues dynamic credentials (e.g.: database/creds/<role>).
+// The secrets are read once per decoding, it guarantees that keys
+// of the same dynamic secret belong to the same lease.
+type vaultProvider struct {
+	client    *vaultHttpClient
+	cache     *secretCache
+	sessionID string
+	secrets   map[string]*secretValue
+}
+
+func newVaultProvider(sessionID string) (*vaultProvider, error) {
+	client, err := getVaultClient()
+	if err != nil {
+		return nil, err
+	}
+	return &vaultProvider{
+		client:    client,
+		cache:     getCache(secretProviderVault, true),
+		sessionID: sessionID,
+		secrets:   map[string]*secretValue{},
+	}, nil
+}
+
+func (p *vaultProvider) GetKey(secretID, secretKey string) (string, error) {
+	secret, ok := p.secrets[secretID]
+	if !ok {
+		var err error
+		secret, err = p.cache.get(secretID, func() (*secretValue, error) {
+			secret, err := p.client.read(secretID)
+			if err != nil {
+				return nil, fmt.Errorf("(%v) %v", secretID, err)
+			}
+			return &secretValue{data: secret.Data, leaseID: secret.LeaseID, leaseDuration: secret.LeaseDuration}, nil
+		})
+		if err != nil {
+			return "", err
+		}
+		p.secrets[secretID] = secret
+		if secret.leaseID != "" {
+			addVaultLease(p.sessionID, secret.leaseID)
+			log.Infof("session=%v - vault lease issued for %v, ttl=%vs", p.sessionID, secretID, secret.leaseDuration)
+		}
+	}
+	if v, ok := secret.data[secretKey]; ok {
+		return fmt.Sprintf("%v", v), nil
+	}
+	return "", fmt.Errorf("secret id %s found, but key %s was not", secretID, secretKey)
+}
+
+func addVaultLease(sessionID, leaseID string) {
+	vaultLeasesMu.Lock()
+	defer vaultLeasesMu.Unlock()
+	vaultLeases[sessionID] = append(vaultLeases[sessionID], leaseID)
+}
+
+// revokeSessionLeases revokes the dynamic secrets issued for a session
+func revokeSessionLeases(sessionID string) {
+	vaultLeasesMu.Lock()
+	leases := vaultLeases[sessionID]
+	delete(vaultLeases, sessionID)
+	vaultLeasesMu.Unlock()
+	if len(leases) == 0 {
+		return
+	}
+	client, err := getVaultClient()
+	if err != nil {
+		log.Warnf("session=%v - failed revoking vault leases: %v", sessionID, err)
+		return
+	}
+	for _, leaseID := range leases {
+		if err := client.revokeLease(leaseID); err != nil {
+			log.Warnf("session=%v - failed revoking vault lease %v: %v", sessionID, leaseID, err)
+			continue
+		}
+		log.Infof("session=%v - vault lease %v revoked", sessionID, leaseID)
+	}
+}
@@ -0,0 +1,235 @@
+package secretsmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeVault is a vault server which issues a new token on each approle login
+// and accepts only the last issued token.
+type fakeVault struct {
+	mu       sync.Mutex
+	logins   int
+	token    string
+	requests []string
+	revoked  []string
+	// path -> response data of the secret
+	secrets map[string]map[string]any
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	path := r.URL.Path[len("/v1/"):]
+	v.requests = append(v.requests, r.Method+" "+path)
+	writeJSON := func(statusCode int, obj any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		_ = json.NewEncoder(w).Encode(obj)
+	}
+	if path == "auth/approle/login" {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			writeJSON(http.StatusBadRequest, map[string]any{"errors": []string{"invalid role or secret id"}})
+			return
+		}
+		v.logins++
+		v.token = fmt.Sprintf("token-%v", v.logins)
+		writeJSON(http.StatusOK, map[string]any{"auth": map[string]any{"client_token": v.token, "lease_duration": 3600}})
+		return
+	}
+	if r.Header.Get("X-Vault-Token") != v.token {
+		writeJSON(http.StatusForbidden, map[string]any{"errors": []string{"permission denied"}})
+		return
+	}
+	switch {
+	case path == "sys/leases/revoke":
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		v.revoked = append(v.revoked, body["lease_id"])
+		w.WriteHeader(http.StatusNoContent)
+	case path == "sys/internal/ui/mounts/secret/myapp":
+		writeJSON(http.StatusOK, map[string]any{"data": map[string]any{"path": "secret/", "options": map[string]any{"version": "2"}}})
+	case v.secrets[path] != nil:
+		writeJSON(http.StatusOK, map[string]any{"data": v.secrets[path], "lease_id": v.secrets[path]["lease_id"]})
+	default:
+		writeJSON(http.StatusNotFound, map[string]any{"errors": []string{}})
+	}
+}
+
+func (v *fakeVault) getRequests() []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]string{}, v.requests...)
+}
+
+func newFakeVaultClient(t *testing.T, v *fakeVault) *vaultHttpClient {
+	srv := httptest.NewServer(v)
+	t.Cleanup(srv.Close)
+	client, err := newVaultHttpClient(&vaultConfig{
+		address:    srv.URL,
+		authMethod: vaultAuthMethodAppRole,
+		authMount:  vaultAuthMethodAppRole,
+		roleID:     "role",
+		secretID:   "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestVaultAppRoleLogin(t *testing.T) {
+	v := &fakeVault{secrets: map[string]map[string]any{
+		"database/creds/readonly": {"username": "v-user", "password": "pwd"},
+	}}
+	client := newFakeVaultClient(t, v)
+	secret, err := client.read("database/creds/readonly")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"username": "v-user", "password": "pwd"}, secret.Data)
+	assert.Equal(t, "token-1", client.token)
+	assert.WithinDuration(t, time.Now().UTC().Add(time.Minute*54), client.tokenExpiry, time.Minute,
+		"the token must be renewed before the lease expires")
+
+	_, err = client.read("database/creds/readonly")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v.logins, "the token must be reused until it expires")
+
+	t.Run("it should fail when the credentials are invalid", func(t *testing.T) {
+		client := newFakeVaultClient(t, &fakeVault{})
+		client.config.secretID = "wrong"
+		_, err := client.read("database/creds/readonly")
+		assert.ErrorContains(t, err, "failed authenticating with approle")
+	})
+}
+
+func TestVaultRelogin(t *testing.T) {
+	for _, tt := range []struct {
+		msg   string
+		setup func(v *fakeVault, client *vaultHttpClient)
+	}{
+		{
+			msg: "it should login again when the token expires",
+			setup: func(_ *fakeVault, client *vaultHttpClient) {
+				client.tokenExpiry = time.Now().UTC().Add(-time.Second)
+			},
+		},
+		{
+			msg: "it should login again when the token is denied",
+			setup: func(v *fakeVault, _ *vaultHttpClient) {
+				// the token is revoked in the server
+				v.mu.Lock()
+				v.token = "revoked"
+				v.mu.Unlock()
+			},
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			v := &fakeVault{secrets: map[string]map[string]any{"kv/myapp": {"user": "admin"}}}
+			client := newFakeVaultClient(t, v)
+			// known mount, it skips the lookup of the kv version
+			client.kvMounts["kv/"] = "1"
+			_, err := client.read("kv/myapp")
+			assert.NoError(t, err)
+
+			tt.setup(v, client)
+			secret, err := client.read("kv/myapp")
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]any{"user": "admin"}, secret.Data)
+			assert.Equal(t, 2, v.logins)
+			assert.Equal(t, "token-2", client.token)
+		})
+	}
+
+	t.Run("it should not login again with static tokens", func(t *testing.T) {
+		v := &fakeVault{token: "static", secrets: map[string]map[string]any{"kv/myapp": {"user": "admin"}}}
+		client := newFakeVaultClient(t, v)
+		client.config.authMethod = vaultAuthMethodToken
+		client.config.token = "wrong"
+		client.token = "wrong"
+		client.kvMounts["kv/"] = "1"
+		_, err := client.read("kv/myapp")
+		assert.ErrorContains(t, err, "(403): permission denied")
+		assert.Equal(t, []string{"GET kv/myapp"}, v.getRequests())
+	})
+}
+
+func TestVaultKVPath(t *testing.T) {
+	v := &fakeVault{secrets: map[string]map[string]any{
+		"secret/data/myapp": {"data": map[string]any{"user": "admin"}, "metadata": map[string]any{"version": 1}},
+	}}
+	client := newFakeVaultClient(t, v)
+	for range 2 {
+		secret, err := client.read("secret/myapp")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]any{"user": "admin"}, secret.Data, "the data of kv version 2 must be unwrapped")
+	}
+	assert.Equal(t, []string{
+		"POST auth/approle/login",
+		"GET sys/internal/ui/mounts/secret/myapp",
+		"GET secret/data/myapp",
+		"GET secret/data/myapp",
+	}, v.getRequests(), "the mount must be looked up once")
+
+	for _, tt := range []struct {
+		msg        string
+		mountPath  string
+		version    string
+		secretPath string
+		want       string
+	}{
+		{msg: "it should add the data prefix to kv version 2 paths", mountPath: "secret/", version: "2", secretPath: "secret/myapp", want: "secret/data/myapp"},
+		{msg: "it should keep paths with the data prefix", mountPath: "secret/", version: "2", secretPath: "secret/data/myapp", want: "secret/data/myapp"},
+		{msg: "it should keep kv version 1 paths", mountPath: "kv/", version: "1", secretPath: "kv/myapp", want: "kv/myapp"},
+		{msg: "it should keep paths of other engines", mountPath: "database/", secretPath: "database/creds/ro", want: "database/creds/ro"},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			assert.Equal(t, tt.want, kvPath(tt.mountPath, tt.version, tt.secretPath))
+		})
+	}
+}
+
+func TestVaultRevokeSessionLeases(t *testing.T) {
+	v := &fakeVault{secrets: map[string]map[string]any{
+		"database/creds/readonly": {"username": "v-user", "password": "pwd", "lease_id": "database/creds/readonly/lease-1"},
+	}}
+	client := newFakeVaultClient(t, v)
+	client.kvMounts["database/"] = ""
+	vaultClientMu.Lock()
+	vaultClient = client
+	vaultClientMu.Unlock()
+	t.Cleanup(func() {
+		vaultClientMu.Lock()
+		vaultClient = nil
+		vaultClientMu.Unlock()
+	})
+
+	p := &vaultProvider{
+		client:    client,
+		cache:     &secretCache{ttl: time.Minute, dynamic: true, entries: map[string]cacheEntry{}, leased: map[string]bool{}},
+		sessionID: "sid-1",
+		secrets:   map[string]*secretValue{},
+	}
+	username, err := p.GetKey("database/creds/readonly", "username")
+	assert.NoError(t, err)
+	assert.Equal(t, "v-user", username)
+	addVaultLease("sid-2", "database/creds/readonly/lease-2")
+
+	revokeSessionLeases("sid-1")
+	v.mu.Lock()
+	assert.Equal(t, []string{"database/creds/readonly/lease-1"}, v.revoked, "only the leases of the session must be revoked")
+	v.mu.Unlock()
+	vaultLeasesMu.Lock()
+	assert.NotContains(t, vaultLeases, "sid-1")
+	assert.Contains(t, vaultLeases, "sid-2")
+	delete(vaultLeases, "sid-2")
+	vaultLeasesMu.Unlock()
+}
@@ -0,0 +1,106 @@
+// Package sshproxy opens SSH sessions with the credentials of the connection. The terminal and
+// the exec implement interactive and one-off sessions, the proxy relays the channels of the local
+// SSH clients. They implement the contract of libhoop.Proxy.
+package sshproxy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/hoophq/hoop/common/log"
+	"golang.org/x/crypto/ssh"
+)
+
+const dialTimeout = time.Second * 15
+
+// dial opens a connection with the server and authenticates with the options:
+//
+//	hostname, port - the address of the server, the port defaults to 22
+//	username - the user of the session
+//	password - the password of the user, it's used when the private key is empty or it's rejected
+//	private_key - the private key of the user in the PEM format
+//	passphrase - the passphrase of the private key
+//	host_key - the public key of the server in the authorized keys format, it's required unless insecure is "true"
+//	insecure - skip the verification of the host key when it's "true"
+func dial(ctx context.Context, opts map[string]string) (*ssh.Client, error) {
+	if opts["hostname"] == "" || opts["username"] == "" {
+		return nil, fmt.Errorf("missing hostname or username of the connection")
+	}
+	if opts["private_key"] == "" && opts["password"] == "" {
+		return nil, fmt.Errorf("missing private key or password of the connection")
+	}
+	port := opts["port"]
+	if port == "" {
+		port = "22"
+	}
+	config := &ssh.ClientConfig{User: opts["username"], Timeout: dialTimeout}
+	if privateKey := opts["private_key"]; privateKey != "" {
+		signer, err := parsePrivateKey([]byte(privateKey), opts["passphrase"])
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing private key: %v", err)
+		}
+		config.Auth = append(config.Auth, ssh.PublicKeys(signer))
+	}
+	if password := opts["password"]; password != "" {
+		config.Auth = append(config.Auth,
+			ssh.Password(password),
+			ssh.KeyboardInteractive(func(_, _ string, questions []string, _ []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}))
+	}
+	addr := net.JoinHostPort(opts["hostname"], port)
+	switch hostKey := opts["host_key"]; {
+	case hostKey != "":
+		pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing host key: %v", err)
+		}
+		config.HostKeyCallback = ssh.FixedHostKey(pubKey)
+	case opts["insecure"] == "true":
+		log.Warnf("the host key of the ssh server %v is not verified, the connection is in insecure mode", addr)
+		config.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, fmt.Errorf("missing the host key of the ssh server, set the HOST_KEY of the connection " +
+			"or set INSECURE=true to skip the verification")
+	}
+
+	ctx, cancelFn := context.WithTimeout(ctx, dialTimeout)
+	defer cancelFn()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed connecting with ssh server %v, reason=%v", addr, err)
+	}
+	// the handshake must not hang when the server doesn't respond
+	_ = conn.SetDeadline(time.Now().Add(dialTimeout))
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed authenticating with ssh server %v, reason=%v", addr, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+func parsePrivateKey(key []byte, passphrase string) (ssh.Signer, error) {
+	if passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+	}
+	return ssh.ParsePrivateKey(key)
+}
+
+// exitCode returns the exit status of the remote command
+func exitCode(err error) int {
+	switch v := err.(type) {
+	case nil:
+		return 0
+	case *ssh.ExitError:
+		return v.ExitStatus()
+	}
+	return 1
+}
@@ -0,0 +1,189 @@
+package sshproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, privKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// newFakeSSHServer accepts the user hoop with the password secret. The exec requests write
+// the command to stdout, the command "fail" writes to stderr and exits with the status 3.
+func newFakeSSHServer(t *testing.T, hostKey ssh.Signer) (host, port string) {
+	t.Helper()
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "hoop" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("invalid password")
+		},
+	}
+	config.AddHostKey(hostKey)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeSSHConn(conn, config)
+		}
+	}()
+	host, port, _ = net.SplitHostPort(lis.Addr().String())
+	return
+}
+
+func serveFakeSSHConn(conn net.Conn, config *ssh.ServerConfig) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	// any channel type is accepted, the proxy must refuse the types it doesn't allow
+	for newCh := range chans {
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer ch.Close()
+			for req := range chReqs {
+				if req.Type != "exec" || len(req.Payload) < 4 {
+					_ = req.Reply(false, nil)
+					continue
+				}
+				_ = req.Reply(true, nil)
+				command := string(req.Payload[4:])
+				status := uint32(0)
+				if command == "fail" {
+					_, _ = ch.Stderr().Write([]byte("failed"))
+					status = 3
+				} else {
+					_, _ = ch.Write([]byte(command))
+				}
+				_, _ = ch.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, status))
+				return
+			}
+		}()
+	}
+}
+
+func TestDialHostKey(t *testing.T) {
+	hostKey := newTestSigner(t)
+	host, port := newFakeSSHServer(t, hostKey)
+	for _, tt := range []struct {
+		msg     string
+		opts    map[string]string
+		wantErr string
+	}{
+		{
+			msg:  "it should connect when the host key matches",
+			opts: map[string]string{"host_key": string(ssh.MarshalAuthorizedKey(hostKey.PublicKey()))},
+		},
+		{
+			msg:  "it should connect without verifying the host key in insecure mode",
+			opts: map[string]string{"insecure": "true"},
+		},
+		{
+			msg:     "it should fail when the host key does not match",
+			opts:    map[string]string{"host_key": string(ssh.MarshalAuthorizedKey(newTestSigner(t).PublicKey())), "insecure": "true"},
+			wantErr: "ssh: host key mismatch",
+		},
+		{
+			msg:     "it should fail when the host key is missing",
+			opts:    map[string]string{},
+			wantErr: "missing the host key of the ssh server",
+		},
+		{
+			msg:     "it should fail with an invalid host key",
+			opts:    map[string]string{"host_key": "ssh-ed25519 invalid"},
+			wantErr: "failed parsing host key",
+		},
+		{
+			msg:     "it should fail with a wrong password",
+			opts:    map[string]string{"insecure": "true", "password": "wrong"},
+			wantErr: "failed authenticating with ssh server",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			opts := map[string]string{"hostname": host, "port": port, "username": "hoop", "password": "secret"}
+			for key, val := range tt.opts {
+				opts[key] = val
+			}
+			client, err := dial(context.Background(), opts)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			if assert.NoError(t, err) {
+				_ = client.Close()
+			}
+		})
+	}
+}
+
+func TestExec(t *testing.T) {
+	host, port := newFakeSSHServer(t, newTestSigner(t))
+	for _, tt := range []struct {
+		msg          string
+		command      string
+		wantStdout   string
+		wantStderr   string
+		wantExitCode int
+	}{
+		{
+			msg:        "it should write the output of the command",
+			command:    "echo hello",
+			wantStdout: "echo hello",
+		},
+		{
+			msg:          "it should report the exit status of the command",
+			command:      "fail",
+			wantStderr:   "failed",
+			wantExitCode: 3,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			opts := map[string]string{"hostname": host, "port": port, "username": "hoop", "password": "secret", "insecure": "true"}
+			e := NewExec(context.Background(), tt.command, nil, &stdout, &stderr, opts)
+			exitCh := make(chan int, 1)
+			e.Run(func(exitCode int, errMsg string) {
+				assert.Empty(t, errMsg)
+				exitCh <- exitCode
+			})
+			assert.Equal(t, tt.wantExitCode, <-exitCh)
+			<-e.Done()
+			assert.Equal(t, tt.wantStdout, stdout.String())
+			assert.Equal(t, tt.wantStderr, stderr.String())
+			assert.False(t, strings.Contains(e.String(), "secret"))
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package sshproxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/common/sshtypes"
+	"golang.org/x/crypto/ssh"
+)
+
+const sessionChannelType = "session"
+
+// Proxy relays the channels of a local SSH connection to the server. The messages of the client
+// are processed in order, the channels opened in the server are identified by the id of the client.
+type Proxy struct {
+	ctx      context.Context
+	cancelFn context.CancelFunc
+	clientW  io.Writer
+	opts     map[string]string
+	msgC     chan *sshtypes.Message
+	done     chan struct{}
+
+	mu        sync.Mutex
+	client    *ssh.Client
+	channels  map[uint32]ssh.Channel
+	closeOnce sync.Once
+}
+
+// NewProxy returns a proxy that writes the messages of the server channels to clientW.
+// See the options of the connection in the dial function.
+func NewProxy(ctx context.Context, clientW io.Writer, opts map[string]string) *Proxy {
+	ctx, cancelFn := context.WithCancel(ctx)
+	return &Proxy{
+		ctx:      ctx,
+		cancelFn: cancelFn,
+		clientW:  clientW,
+		opts:     opts,
+		msgC:     make(chan *sshtypes.Message, 1024),
+		done:     make(chan struct{}),
+		channels: map[uint32]ssh.Channel{},
+	}
+}
+
+// Run connects with the server in background, the callback is called when the connection fails
+func (p *Proxy) Run(onErr func(exitCode int, errMsg string)) {
+	go func() {
+		defer p.Close()
+		if err := p.serve(); err != nil {
+			log.Infof("ssh proxy closed with error, reason=%v", err)
+			onErr(1, err.Error())
+		}
+	}()
+}
+
+// Write decodes a message of the client, the messages are buffered while the proxy connects
+func (p *Proxy) Write(data []byte) (int, error) {
+	msg, err := sshtypes.Decode(data)
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case p.msgC <- msg:
+		return len(data), nil
+	case <-p.done:
+		return 0, io.EOF
+	}
+}
+
+func (p *Proxy) Done() <-chan struct{} { return p.done }
+
+func (p *Proxy) Close() error {
+	p.closeOnce.Do(func() {
+		p.cancelFn()
+		p.mu.Lock()
+		if p.client != nil {
+			_ = p.client.Close()
+		}
+		p.mu.Unlock()
+		close(p.done)
+	})
+	return nil
+}
+
+func (p *Proxy) serve() error {
+	client, err := dial(p.ctx, p.opts)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.client = client
+	p.mu.Unlock()
+	go func() {
+		_ = client.Wait()
+		p.cancelFn()
+	}()
+	for {
+		select {
+		case <-p.ctx.Done():
+			return nil
+		case msg := <-p.msgC:
+			if err := p.processMessage(client, msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (p *Proxy) processMessage(client *ssh.Client, msg *sshtypes.Message) error {
+	if msg.Type == sshtypes.MessageOpenChannel {
+		// shell, exec and scp run in session channels, the other types (direct-tcpip,
+		// x11, etc) would open connections from the server that are not audited
+		if msg.Name != sessionChannelType {
+			return p.send(&sshtypes.Message{
+				Type:      sshtypes.MessageOpenFailure,
+				ChannelID: msg.ChannelID,
+				Data:      []byte(fmt.Sprintf("channel type %q is not allowed", msg.Name)),
+			})
+		}
+		ch, reqs, err := client.OpenChannel(msg.Name, msg.Data)
+		if err != nil {
+			return p.send(&sshtypes.Message{
+				Type:      sshtypes.MessageOpenFailure,
+				ChannelID: msg.ChannelID,
+				Data:      []byte(err.Error()),
+			})
+		}
+		p.mu.Lock()
+		p.channels[msg.ChannelID] = ch
+		p.mu.Unlock()
+		if err := p.send(&sshtypes.Message{Type: sshtypes.MessageOpenConfirm, ChannelID: msg.ChannelID}); err != nil {
+			return err
+		}
+		go p.relayChannel(msg.ChannelID, ch, reqs)
+		return nil
+	}
+
+	p.mu.Lock()
+	ch, ok := p.channels[msg.ChannelID]
+	p.mu.Unlock()
+	if !ok {
+		log.Debugf("ssh channel not found, %v", msg)
+		if msg.Type == sshtypes.MessageRequest && msg.WantReply {
+			return p.send(&sshtypes.Message{Type: sshtypes.MessageRequestReply, ChannelID: msg.ChannelID})
+		}
+		return nil
+	}
+	var err error
+	switch msg.Type {
+	case sshtypes.MessageRequest:
+		var ok bool
+		ok, err = ch.SendRequest(msg.Name, msg.WantReply, msg.Data)
+		if msg.WantReply {
+			return p.send(&sshtypes.Message{
+				Type:      sshtypes.MessageRequestReply,
+				ChannelID: msg.ChannelID,
+				WantReply: ok && err == nil,
+			})
+		}
+	case sshtypes.MessageData:
+		_, err = ch.Write(msg.Data)
+	case sshtypes.MessageExtendedData:
+		_, err = ch.Stderr().Write(msg.Data)
+	case sshtypes.MessageEOF:
+		err = ch.CloseWrite()
+	case sshtypes.MessageClose:
+		p.mu.Lock()
+		delete(p.channels, msg.ChannelID)
+		p.mu.Unlock()
+		err = ch.Close()
+	}
+	// the errors of a channel don't end the connection
+	if err != nil && err != io.EOF {
+		log.Debugf("failed processing ssh message, %v, reason=%v", msg, err)
+	}
+	return nil
+}
+
+// relayChannel sends the content and requests of the server channel to the client
+// until the channel is closed by the server
+func (p *Proxy) relayChannel(channelID uint32, ch ssh.Channel, reqs <-chan *ssh.Request) {
+	dataDone := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = sshtypes.Copy(p.clientW, channelID, sshtypes.MessageData, ch)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = sshtypes.Copy(p.clientW, channelID, sshtypes.MessageExtendedData, ch.Stderr())
+		}()
+		wg.Wait()
+		_ = p.send(&sshtypes.Message{Type: sshtypes.MessageEOF, ChannelID: channelID})
+		close(dataDone)
+	}()
+	for req := range reqs {
+		// the replies of the client are not relayed, the requests
+		// of the server are informative (exit-status, keepalive)
+		if req.WantReply {
+			_ = req.Reply(false, nil)
+		}
+		// the exit status must be sent after the output of the command
+		if strings.HasPrefix(req.Type, "exit-") {
+			<-dataDone
+		}
+		_ = p.send(&sshtypes.Message{
+			Type:      sshtypes.MessageRequest,
+			ChannelID: channelID,
+			Name:      req.Type,
+			Data:      req.Payload,
+		})
+	}
+	<-dataDone
+	p.mu.Lock()
+	delete(p.channels, channelID)
+	p.mu.Unlock()
+	_ = ch.Close()
+	_ = p.send(&sshtypes.Message{Type: sshtypes.MessageClose, ChannelID: channelID})
+}
+
+func (p *Proxy) send(msg *sshtypes.Message) error {
+	if _, err := p.clientW.Write(msg.Encode()); err != nil {
+		return fmt.Errorf("failed writing ssh message to client: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package sshproxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoop/common/sshtypes"
+	"github.com/stretchr/testify/assert"
+)
+
+// messageWriter decodes the messages written to the client, each write is a single message
+type messageWriter chan *sshtypes.Message
+
+func (w messageWriter) Write(data []byte) (int, error) {
+	msg, err := sshtypes.Decode(bytes.Clone(data))
+	if err != nil {
+		return 0, err
+	}
+	w <- msg
+	return len(data), nil
+}
+
+func (w messageWriter) next(t *testing.T) *sshtypes.Message {
+	t.Helper()
+	select {
+	case msg := <-w:
+		return msg
+	case <-time.After(time.Second * 5):
+		t.Fatal("timeout waiting for ssh message")
+	}
+	return nil
+}
+
+func TestProxyRelayChannel(t *testing.T) {
+	host, port := newFakeSSHServer(t, newTestSigner(t))
+	clientW := make(messageWriter, 100)
+	p := NewProxy(context.Background(), clientW, map[string]string{
+		"hostname": host, "port": port, "username": "hoop", "password": "secret", "insecure": "true"})
+	defer p.Close()
+	p.Run(func(_ int, errMsg string) { t.Errorf("unexpected proxy error: %v", errMsg) })
+
+	write := func(msg *sshtypes.Message) {
+		if _, err := p.Write(msg.Encode()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(&sshtypes.Message{Type: sshtypes.MessageOpenChannel, ChannelID: 1, Name: "session"})
+	msg := clientW.next(t)
+	assert.Equal(t, sshtypes.MessageOpenConfirm, msg.Type)
+	assert.Equal(t, uint32(1), msg.ChannelID)
+
+	command := binary.BigEndian.AppendUint32(nil, uint32(len("uptime")))
+	write(&sshtypes.Message{Type: sshtypes.MessageRequest, ChannelID: 1, Name: "exec", WantReply: true, Data: append(command, "uptime"...)})
+
+	// the reply of the request may be relayed in any order,
+	// the exit status is relayed after the output of the command
+	var types []sshtypes.MessageType
+	var stdout []byte
+	var replied, closed bool
+	for !replied || !closed {
+		msg := clientW.next(t)
+		assert.Equal(t, uint32(1), msg.ChannelID)
+		switch msg.Type {
+		case sshtypes.MessageRequestReply:
+			assert.True(t, msg.WantReply, "the request must be accepted by the server")
+			replied = true
+			continue
+		case sshtypes.MessageClose:
+			closed = true
+		case sshtypes.MessageData:
+			stdout = append(stdout, msg.Data...)
+		case sshtypes.MessageRequest:
+			assert.Equal(t, "exit-status", msg.Name)
+			assert.Equal(t, []byte{0, 0, 0, 0}, msg.Data)
+		}
+		types = append(types, msg.Type)
+	}
+	assert.Equal(t, "uptime", string(stdout))
+	assert.Equal(t, []sshtypes.MessageType{sshtypes.MessageData, sshtypes.MessageEOF, sshtypes.MessageRequest, sshtypes.MessageClose}, types)
+
+	// the requests of unknown channels are refused
+	write(&sshtypes.Message{Type: sshtypes.MessageRequest, ChannelID: 1, Name: "exec", WantReply: true})
+	msg = clientW.next(t)
+	assert.Equal(t, sshtypes.MessageRequestReply, msg.Type)
+	assert.False(t, msg.WantReply)
+}
+
+func TestProxyRejectChannelTypes(t *testing.T) {
+	host, port := newFakeSSHServer(t, newTestSigner(t))
+	clientW := make(messageWriter, 100)
+	p := NewProxy(context.Background(), clientW, map[string]string{
+		"hostname": host, "port": port, "username": "hoop", "password": "secret", "insecure": "true"})
+	defer p.Close()
+	p.Run(func(_ int, errMsg string) { t.Errorf("unexpected proxy error: %v", errMsg) })
+
+	for i, channelType := range []string{"direct-tcpip", "x11", "auth-agent@openssh.com"} {
+		channelID := uint32(i + 1)
+		msg := &sshtypes.Message{Type: sshtypes.MessageOpenChannel, ChannelID: channelID, Name: channelType}
+		if _, err := p.Write(msg.Encode()); err != nil {
+			t.Fatal(err)
+		}
+		msg = clientW.next(t)
+		assert.Equal(t, sshtypes.MessageOpenFailure, msg.Type)
+		assert.Equal(t, channelID, msg.ChannelID)
+		assert.Equal(t, fmt.Sprintf("channel type %q is not allowed", channelType), string(msg.Data))
+	}
+}
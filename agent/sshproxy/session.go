@@ -0,0 +1,199 @@
+package sshproxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/creack/pty"
+	"golang.org/x/crypto/ssh"
+)
+
+// session runs a shell or a command in the server
+type session struct {
+	ctx      context.Context
+	cancelFn context.CancelFunc
+	opts     map[string]string
+	stdout   io.Writer
+	stderr   io.Writer
+	done     chan struct{}
+	serve    func(client *ssh.Client) error
+
+	mu        sync.Mutex
+	client    *ssh.Client
+	closeOnce sync.Once
+}
+
+func newSession(ctx context.Context, stdout, stderr io.Writer, opts map[string]string) *session {
+	ctx, cancelFn := context.WithCancel(ctx)
+	return &session{
+		ctx:      ctx,
+		cancelFn: cancelFn,
+		opts:     opts,
+		stdout:   stdout,
+		stderr:   stderr,
+		done:     make(chan struct{}),
+	}
+}
+
+// Run connects with the server in background, the callback is called when the session ends
+func (s *session) Run(onExit func(exitCode int, errMsg string)) {
+	go func() {
+		defer s.Close()
+		client, err := dial(s.ctx, s.opts)
+		if err != nil {
+			onExit(1, err.Error())
+			return
+		}
+		s.mu.Lock()
+		s.client = client
+		s.mu.Unlock()
+		err = s.serve(client)
+		if s.ctx.Err() != nil {
+			onExit(0, "")
+			return
+		}
+		var errMsg string
+		if _, isExitErr := err.(*ssh.ExitError); err != nil && !isExitErr {
+			errMsg = err.Error()
+		}
+		onExit(exitCode(err), errMsg)
+	}()
+}
+
+func (s *session) Done() <-chan struct{} { return s.done }
+
+// String returns the destination of the session, the options must not be logged
+func (s *session) String() string {
+	return fmt.Sprintf("ssh %s@%s", s.opts["username"], net.JoinHostPort(s.opts["hostname"], s.opts["port"]))
+}
+
+func (s *session) Close() error {
+	s.closeOnce.Do(func() {
+		s.cancelFn()
+		s.mu.Lock()
+		if s.client != nil {
+			_ = s.client.Close()
+		}
+		s.mu.Unlock()
+		close(s.done)
+	})
+	return nil
+}
+
+// Terminal is an interactive shell with a pseudo terminal
+type Terminal struct {
+	*session
+	stdinC chan []byte
+
+	sizeMu  sync.Mutex
+	size    *pty.Winsize
+	sshSess *ssh.Session
+}
+
+// NewTerminal returns a shell that writes the output of the terminal to stdout.
+// See the options of the connection in the dial function.
+func NewTerminal(ctx context.Context, stdout io.Writer, opts map[string]string) *Terminal {
+	t := &Terminal{
+		session: newSession(ctx, stdout, stdout, opts),
+		stdinC:  make(chan []byte, 1024),
+		size:    &pty.Winsize{Rows: 24, Cols: 80},
+	}
+	t.serve = t.serveTerminal
+	return t
+}
+
+func (t *Terminal) serveTerminal(client *ssh.Client) error {
+	sshSess, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed opening ssh session: %v", err)
+	}
+	defer sshSess.Close()
+	sshSess.Stdout = t.stdout
+	sshSess.Stderr = t.stderr
+	stdin, err := sshSess.StdinPipe()
+	if err != nil {
+		return err
+	}
+	t.sizeMu.Lock()
+	modes := ssh.TerminalModes{ssh.ECHO: 1, ssh.TTY_OP_ISPEED: 14400, ssh.TTY_OP_OSPEED: 14400}
+	err = sshSess.RequestPty("xterm-256color", int(t.size.Rows), int(t.size.Cols), modes)
+	t.sshSess = sshSess
+	t.sizeMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed requesting pty: %v", err)
+	}
+	if err := sshSess.Shell(); err != nil {
+		return fmt.Errorf("failed starting shell: %v", err)
+	}
+	go func() {
+		for {
+			select {
+			case data := <-t.stdinC:
+				if _, err := stdin.Write(data); err != nil {
+					return
+				}
+			case <-t.ctx.Done():
+				return
+			}
+		}
+	}()
+	return sshSess.Wait()
+}
+
+// Write sends the input to the terminal, it's buffered until the session starts
+func (t *Terminal) Write(data []byte) (int, error) {
+	select {
+	case t.stdinC <- bytes.Clone(data):
+		return len(data), nil
+	case <-t.done:
+		return 0, io.EOF
+	}
+}
+
+// ResizeTTY changes the size of the terminal, the size is used when the session starts
+func (t *Terminal) ResizeTTY(size *pty.Winsize) error {
+	t.sizeMu.Lock()
+	defer t.sizeMu.Unlock()
+	t.size = size
+	if t.sshSess == nil {
+		return nil
+	}
+	return t.sshSess.WindowChange(int(size.Rows), int(size.Cols))
+}
+
+// Exec runs a command with the input of the session,
+// the input is sent to a shell when the command is empty
+type Exec struct {
+	*session
+}
+
+// NewExec returns a command that writes its output to stdout and stderr.
+// See the options of the connection in the dial function.
+func NewExec(ctx context.Context, command string, input []byte, stdout, stderr io.Writer, opts map[string]string) *Exec {
+	e := &Exec{session: newSession(ctx, stdout, stderr, opts)}
+	e.serve = func(client *ssh.Client) error {
+		sshSess, err := client.NewSession()
+		if err != nil {
+			return fmt.Errorf("failed opening ssh session: %v", err)
+		}
+		defer sshSess.Close()
+		sshSess.Stdin = bytes.NewReader(input)
+		sshSess.Stdout = e.stdout
+		sshSess.Stderr = e.stderr
+		if command == "" {
+			if err := sshSess.Shell(); err != nil {
+				return fmt.Errorf("failed starting shell: %v", err)
+			}
+			return sshSess.Wait()
+		}
+		return sshSess.Run(command)
+	}
+	return e
+}
+
+// Write is a noop, the input is sent when the command starts
+func (e *Exec) Write(data []byte) (int, error) { return len(data), nil }
@@ -34,6 +34,9 @@ func (s *EnvVarStore) Getenv(key string) string {
 	return string(env.Val)
 }
 
+// Get returns the environment variable of the key or nil if it doesn't exist
+func (s *EnvVarStore) Get(key string) *EnvVar { return s.store[key] }
+
 func (s *EnvVarStore) Add(env *EnvVar) {
 	s.store[env.Key] = env
 }
@@ -31,7 +31,7 @@ var (
 
 func init() {
 	createConnectionCmd.Flags().StringVarP(&connAgentFlag, "agent", "a", "", "Name of the agent")
-	createConnectionCmd.Flags().StringVarP(&connTypeFlag, "type", "t", "custom", "Type of the connection. One off: (application,custom,database,application/tcp,database/mssql,database/mysql,database/postgres,database/mongodb)")
+	createConnectionCmd.Flags().StringVarP(&connTypeFlag, "type", "t", "custom", "Type of the connection. One off: (application,custom,database,application/tcp,application/kubernetes,application/ssh,application/http,database/mssql,database/mysql,database/postgres,database/mongodb,database/oracledb,database/redis)")
 	createConnectionCmd.Flags().StringSliceVarP(&connPuginFlag, "plugin", "p", nil, "Plugins that will be enabled for this connection in the form of: <plugin>:<config01>;<config02>,...")
 	createConnectionCmd.Flags().StringSliceVar(&reviewersFlag, "reviewers", nil, "The approval groups for this connection")
 	createConnectionCmd.Flags().StringSliceVar(&connRedactTypesFlag, "redact-types", nil, "The redact types for this connection")
@@ -47,6 +47,9 @@ func init() {
 var createConnExamplesDesc = `
 hoop admin create connection hello-hoop -a default -- bash -c 'echo hello hoop'
 hoop admin create connection tcpsvc -a default -t application/tcp -e HOST=127.0.0.1 -e PORT=3000
+hoop admin create connection k8s -a default -t application/kubernetes -e b64-filesystem:KUBECONFIG=$(base64 -w0 ~/.kube/config) -e NAMESPACE=default -e SELECTOR=app=api -e SERVICE=api -e PORT=8080
+hoop admin create connection bastion -a default -t application/ssh -e HOST=10.0.0.10 -e USER=ubuntu -e b64-envvar:PRIVATE_KEY=$(base64 -w0 ~/.ssh/id_ed25519)
+hoop admin create connection admin-api -a default -t application/http -e REMOTE_URL=https://admin.internal:8443 -e 'HEADER_AUTHORIZATION=Bearer <token>'
 `
 var createConnectionCmd = &cobra.Command{
 	Use:     "connection NAME [-- COMMAND]",
@@ -101,10 +104,29 @@ var createConnectionCmd = &cobra.Command{
 				if err := validateTcpEnvs(envVar); err != nil {
 					styles.PrintErrorAndExit(err.Error())
 				}
-			case pb.ConnectionTypePostgres, pb.ConnectionTypeMySQL, pb.ConnectionTypeMSSQL:
+			case pb.ConnectionTypePostgres, pb.ConnectionTypeMySQL, pb.ConnectionTypeMSSQL, pb.ConnectionTypeOracleDB:
 				if err := validateNativeDbEnvs(envVar); err != nil {
 					styles.PrintErrorAndExit(err.Error())
 				}
+			case pb.ConnectionTypeRedis:
+				if envVar["envvar:HOST"] == "" {
+					styles.PrintErrorAndExit("missing required HOST env for %v", pb.ConnectionTypeRedis)
+				}
+			case pb.ConnectionTypeKubernetes:
+				if envVar["envvar:POD"] == "" && envVar["envvar:SELECTOR"] == "" && envVar["envvar:SERVICE"] == "" {
+					styles.PrintErrorAndExit("missing required POD, SELECTOR or SERVICE env for %v", pb.ConnectionTypeKubernetes)
+				}
+			case pb.ConnectionTypeSSH:
+				if envVar["envvar:HOST"] == "" || envVar["envvar:USER"] == "" {
+					styles.PrintErrorAndExit("missing required HOST and USER env for %v", pb.ConnectionTypeSSH)
+				}
+				if envVar["envvar:PASS"] == "" && envVar["envvar:PRIVATE_KEY"] == "" {
+					styles.PrintErrorAndExit("missing required PASS or PRIVATE_KEY env for %v", pb.ConnectionTypeSSH)
+				}
+			case pb.ConnectionTypeHTTP:
+				if envVar["envvar:REMOTE_URL"] == "" {
+					styles.PrintErrorAndExit("missing required REMOTE_URL env for %v", pb.ConnectionTypeHTTP)
+				}
 			case pb.ConnectionTypeMongoDB:
 				if envVar["envvar:CONNECTION_STRING"] == "" {
 					styles.PrintErrorAndExit("missing required CONNECTION_STRING env for %v", pb.ConnectionTypeMongoDB)
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hoophq/hoop/client/cmd/styles"
+	clientconfig "github.com/hoophq/hoop/client/config"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	verifySessionCmd.Flags().StringVarP(&outputFlag, "output", "o", "", "Output format. One off: (json)")
+}
+
+var verifySessionOutput = `Session:        %v
+Verified:       %v
+Source:         %v
+Events:         %v
+Tampered Event: %v
+Unverifiable:   %v
+Key ID:         %v
+Reason:         %v
+`
+
+var verifySessionCmd = &cobra.Command{
+	Use:   "verify-session SESSION_ID",
+	Short: "Verify the integrity of the events of a session",
+	Long: `Recompute the chain of hashes of the events of a session and validate its signed digest.
+It reports the first tampered event and exits with status 1 when the session could not be verified.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			styles.PrintErrorAndExit("missing the session id argument")
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		conf := clientconfig.GetClientConfigOrDie()
+		obj, _, err := httpRequest(&apiResource{
+			suffixEndpoint: fmt.Sprintf("/api/sessions/%s/verify", args[0]),
+			conf:           conf,
+			decodeTo:       "raw",
+		})
+		if err != nil {
+			styles.PrintErrorAndExit("failed verifying session, reason=%v", err)
+		}
+		rawData, _ := obj.([]byte)
+		var resp map[string]any
+		if err := json.Unmarshal(rawData, &resp); err != nil {
+			styles.PrintErrorAndExit("failed decoding response, reason=%v", err)
+		}
+		if outputFlag == "json" {
+			fmt.Println(string(rawData))
+		} else {
+			tamperedEvent := "-"
+			if idx, ok := resp["tampered_event_index"].(float64); ok {
+				tamperedEvent = fmt.Sprintf("%v", int(idx))
+			}
+			fmt.Printf(verifySessionOutput,
+				resp["session_id"],
+				resp["verified"],
+				resp["source"],
+				resp["events_count"],
+				tamperedEvent,
+				resp["unverifiable"],
+				resp["key_id"],
+				resp["reason"],
+			)
+		}
+		if verified, _ := resp["verified"].(bool); !verified {
+			os.Exit(1)
+		}
+	},
+}
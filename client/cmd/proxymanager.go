@@ -108,8 +108,9 @@ func runAutoConnect(client pb.ClientTransport) (err error) {
 
 		switch pb.PacketType(pkt.Type) {
 		case pbclient.ProxyManagerConnectOK:
-			log.Infof("received connect response from gateway, waiting for connection")
+			log.Infof("received connect response from gateway, waiting for connections")
 			_ = client.Send(&pb.Packet{Type: pbgateway.ProxyManagerConnectOKAck})
+			client.StartKeepAlive()
 		case pbclient.SessionOpenWaitingApproval:
 			log.Infof("waiting for approval %v", string(pkt.Payload))
 		case pbclient.SessionOpenOK:
@@ -119,53 +120,38 @@ func runAutoConnect(client pb.ClientTransport) (err error) {
 				return fmt.Errorf("session is empty")
 			}
 			log.With("type", connnectionType).Infof("session opened")
-			client.StartKeepAlive()
-			switch connnectionType {
-			case pb.ConnectionTypePostgres:
-				srv := proxy.NewPGServer(proxyPort, client)
-				if err := srv.Serve(sid); err != nil {
-					return err
-				}
-				connStore.Set(sid, srv)
-			case pb.ConnectionTypeMySQL:
-				srv := proxy.NewMySQLServer(proxyPort, client)
-				if err := srv.Serve(sid); err != nil {
-					return err
-				}
-				connStore.Set(sid, srv)
-			case pb.ConnectionTypeMSSQL:
-				srv := proxy.NewMSSQLServer(proxyPort, client)
-				if err := srv.Serve(sid); err != nil {
-					return err
-				}
-				connStore.Set(sid, srv)
-			case pb.ConnectionTypeMongoDB:
-				srv := proxy.NewMongoDBServer(proxyPort, client)
-				if err := srv.Serve(sid); err != nil {
-					return err
-				}
-				connStore.Set(sid, srv)
-			case pb.ConnectionTypeTCP:
-				srv := proxy.NewTCPServer(proxyPort, client, pbagent.TCPConnectionWrite)
-				if err := srv.Serve(sid); err != nil {
-					return err
-				}
-				connStore.Set(sid, srv)
-			default:
-				return fmt.Errorf(`connection type %q not implemented`, string(connnectionType))
+			srv, err := newProxyManagerServer(client, connnectionType, proxyPort)
+			if err == nil {
+				err = srv.Serve(sid)
 			}
+			// a failure listening in the port ends only the session of this connection
+			if err != nil {
+				log.With("port", proxyPort).Warnf("failed serving connection, reason=%v", err)
+				_ = client.Send(&pb.Packet{
+					Type:    pbagent.SessionClose,
+					Spec:    map[string][]byte{pb.SpecGatewaySessionID: []byte(sid)},
+					Payload: []byte(err.Error()),
+				})
+				continue
+			}
+			connStore.Set(sid, srv)
 			log.With("port", proxyPort).Infof("ready to accept connections")
 		case pbclient.SessionOpenApproveOK:
 			log.Infof("session approved")
 		case pbclient.SessionOpenAgentOffline:
-			return pb.ErrAgentOffline
+			log.Warnf("failed opening session, reason=%v", pb.ErrAgentOffline)
 		case pbclient.SessionOpenTimeout:
-			return fmt.Errorf("session ended, reached connection duration")
+			if srv, ok := connStore.Get(sid).(proxy.Closer); ok {
+				_ = srv.Close()
+			}
+			connStore.Del(sid)
+			log.Infof("session ended, reached connection duration")
 		case pbclient.PGConnectionWrite:
 			srvObj := connStore.Get(sid)
 			srv, ok := srvObj.(*proxy.PGServer)
 			if !ok {
-				return fmt.Errorf("postgres proxy server not found")
+				log.Debugf("postgres proxy server not found, discarding packet")
+				continue
 			}
 			connectionID := string(pkt.Spec[pb.SpecClientConnectionID])
 			if _, err := srv.PacketWriteClient(connectionID, pkt); err != nil {
@@ -175,7 +161,8 @@ func runAutoConnect(client pb.ClientTransport) (err error) {
 			srvObj := connStore.Get(sid)
 			srv, ok := srvObj.(*proxy.MySQLServer)
 			if !ok {
-				return fmt.Errorf("mysql proxy server instance not found")
+				log.Debugf("mysql proxy server instance not found, discarding packet")
+				continue
 			}
 			connectionID := string(pkt.Spec[pb.SpecClientConnectionID])
 			if _, err := srv.PacketWriteClient(connectionID, pkt); err != nil {
@@ -185,7 +172,52 @@ func runAutoConnect(client pb.ClientTransport) (err error) {
 			srvObj := connStore.Get(sid)
 			srv, ok := srvObj.(*proxy.MSSQLServer)
 			if !ok {
-				return fmt.Errorf("mssql proxy server instance not found")
+				log.Debugf("mssql proxy server instance not found, discarding packet")
+				continue
+			}
+			connectionID := string(pkt.Spec[pb.SpecClientConnectionID])
+			if _, err := srv.PacketWriteClient(connectionID, pkt); err != nil {
+				return fmt.Errorf("failed writing to client, err=%v", err)
+			}
+		case pbclient.OracleDBConnectionWrite:
+			srvObj := connStore.Get(sid)
+			srv, ok := srvObj.(*proxy.OracleDBServer)
+			if !ok {
+				log.Debugf("oracledb proxy server instance not found, discarding packet")
+				continue
+			}
+			connectionID := string(pkt.Spec[pb.SpecClientConnectionID])
+			if _, err := srv.PacketWriteClient(connectionID, pkt); err != nil {
+				return fmt.Errorf("failed writing to client, err=%v", err)
+			}
+		case pbclient.RedisConnectionWrite:
+			srvObj := connStore.Get(sid)
+			srv, ok := srvObj.(*proxy.RedisServer)
+			if !ok {
+				log.Debugf("redis proxy server instance not found, discarding packet")
+				continue
+			}
+			connectionID := string(pkt.Spec[pb.SpecClientConnectionID])
+			if _, err := srv.PacketWriteClient(connectionID, pkt); err != nil {
+				return fmt.Errorf("failed writing to client, err=%v", err)
+			}
+		case pbclient.SSHConnectionWrite:
+			srvObj := connStore.Get(sid)
+			srv, ok := srvObj.(*proxy.SSHServer)
+			if !ok {
+				log.Debugf("ssh proxy server instance not found, discarding packet")
+				continue
+			}
+			connectionID := string(pkt.Spec[pb.SpecClientConnectionID])
+			if _, err := srv.PacketWriteClient(connectionID, pkt); err != nil {
+				return fmt.Errorf("failed writing to client, err=%v", err)
+			}
+		case pbclient.HTTPConnectionWrite:
+			srvObj := connStore.Get(sid)
+			srv, ok := srvObj.(*proxy.HTTPServer)
+			if !ok {
+				log.Debugf("http proxy server instance not found, discarding packet")
+				continue
 			}
 			connectionID := string(pkt.Spec[pb.SpecClientConnectionID])
 			if _, err := srv.PacketWriteClient(connectionID, pkt); err != nil {
@@ -195,7 +227,8 @@ func runAutoConnect(client pb.ClientTransport) (err error) {
 			srvObj := connStore.Get(sid)
 			srv, ok := srvObj.(*proxy.MongoDBServer)
 			if !ok {
-				return fmt.Errorf("mongodb proxy server instance not found")
+				log.Debugf("mongodb proxy server instance not found, discarding packet")
+				continue
 			}
 			connectionID := string(pkt.Spec[pb.SpecClientConnectionID])
 			if _, err := srv.PacketWriteClient(connectionID, pkt); err != nil {
@@ -219,10 +252,41 @@ func runAutoConnect(client pb.ClientTransport) (err error) {
 		case pbclient.SessionClose:
 			if srv, ok := connStore.Get(sid).(proxy.Closer); ok {
 				_ = srv.Close()
-				log.Infof("session closed")
+				log.Infof("session closed, reason=%v", string(pkt.Payload))
 			}
+			connStore.Del(sid)
 		default:
 			return fmt.Errorf("unknown packet %v", pkt.Type)
 		}
 	}
 }
+
+// proxyManagerServer is a local server of a connection, each session listens in its own port
+type proxyManagerServer interface {
+	proxy.Closer
+	Serve(sid string) error
+}
+
+func newProxyManagerServer(client pb.ClientTransport, connType pb.ConnectionType, port string) (proxyManagerServer, error) {
+	switch connType {
+	case pb.ConnectionTypePostgres:
+		return proxy.NewPGServer(port, client), nil
+	case pb.ConnectionTypeMySQL:
+		return proxy.NewMySQLServer(port, client), nil
+	case pb.ConnectionTypeMSSQL:
+		return proxy.NewMSSQLServer(port, client), nil
+	case pb.ConnectionTypeOracleDB:
+		return proxy.NewOracleDBServer(port, client), nil
+	case pb.ConnectionTypeRedis:
+		return proxy.NewRedisServer(port, client), nil
+	case pb.ConnectionTypeSSH:
+		return proxy.NewSSHServer(port, client), nil
+	case pb.ConnectionTypeHTTP:
+		return proxy.NewHTTPServer(port, client), nil
+	case pb.ConnectionTypeMongoDB:
+		return proxy.NewMongoDBServer(port, client), nil
+	case pb.ConnectionTypeTCP, pb.ConnectionTypeKubernetes:
+		return proxy.NewTCPServer(port, client, pbagent.TCPConnectionWrite), nil
+	}
+	return nil, fmt.Errorf(`connection type %q not implemented`, string(connType))
+}
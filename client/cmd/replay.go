@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	clientconfig "github.com/hoophq/hoop/client/config"
+	"github.com/hoophq/hoop/client/replay"
+	"github.com/hoophq/hoop/common/httpclient"
+	"github.com/hoophq/hoop/common/log"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	replaySpeedFlag float64
+	replayInputFlag bool
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay SESSION_ID",
+	Short: "Replay a terminal session",
+	Long: `Replay a connect or exec session in the terminal with the timing of each event.
+
+Keys:
+  space, p          pause or resume
+  right arrow, l    move forward 5 seconds
+  left arrow, h     move backward 5 seconds
+  q, ctrl+c         quit`,
+	Example: `hoop replay 5364ec99-653b-41ba-8165-67236e894990 --speed 2`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.Usage()
+			os.Exit(1)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		runReplay(args[0])
+	},
+}
+
+func init() {
+	replayCmd.Flags().Float64Var(&replaySpeedFlag, "speed", 1, "The playback speed multiplier")
+	replayCmd.Flags().BoolVar(&replayInputFlag, "input", false, "Include input events in the playback")
+	rootCmd.AddCommand(replayCmd)
+}
+
+func runReplay(sessionID string) {
+	config := clientconfig.GetClientConfigOrDie()
+	body, err := fetchSessionCast(config, sessionID)
+	if err != nil {
+		printErrorAndExit(err.Error())
+	}
+	defer body.Close()
+	header, events, err := replay.Decode(body)
+	if err != nil {
+		printErrorAndExit(err.Error())
+	}
+	log.Debugf("decoded session %v, title=%v, events=%v", sessionID, header.Title, len(events))
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	commands := make(chan replay.Command)
+	stdinFd := int(os.Stdin.Fd())
+	// os.Exit doesn't run deferred functions, the terminal must be restored before exiting
+	restoreTerm := func() {}
+	if term.IsTerminal(stdinFd) {
+		oldState, err := term.MakeRaw(stdinFd)
+		if err != nil {
+			printErrorAndExit("failed setting terminal in raw mode, err=%v", err)
+		}
+		restoreTerm = func() { _ = term.Restore(stdinFd, oldState) }
+		defer restoreTerm()
+		go func() {
+			buf := make([]byte, 64)
+			for {
+				n, err := os.Stdin.Read(buf)
+				if err != nil {
+					return
+				}
+				for _, cmd := range replay.ParseKeys(buf[:n]) {
+					select {
+					case commands <- cmd:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	player := replay.NewPlayer(events, os.Stdout, replaySpeedFlag)
+	if err := player.Play(ctx, commands); err != nil {
+		restoreTerm()
+		printErrorAndExit(err.Error())
+	}
+	fmt.Print("\r\n")
+}
+
+// fetchSessionCast obtains a download link of the session in the asciicast format
+// and returns the body of the download request, it's up to the caller to close it.
+func fetchSessionCast(c *clientconfig.Config, sessionID string) (io.ReadCloser, error) {
+	events := "o,e"
+	if replayInputFlag {
+		events = "i,o,e"
+	}
+	apiURL := fmt.Sprintf("%s/api/sessions/%s?extension=cast&events=%s",
+		c.ApiURL, url.PathEscape(sessionID), url.QueryEscape(events))
+	req, err := http.NewRequest(http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.Token))
+	client := httpclient.NewHttpClient(c.TlsCA())
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetching session, err=%v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed fetching session, status-code=%v, payload=%v", resp.StatusCode, string(data))
+	}
+	var download struct {
+		DownloadURL string `json:"download_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&download); err != nil {
+		return nil, fmt.Errorf("failed decoding session response, err=%v", err)
+	}
+	if download.DownloadURL == "" {
+		return nil, fmt.Errorf("session %v does not have a download url", sessionID)
+	}
+
+	req, err = http.NewRequest(http.MethodGet, download.DownloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	downloadResp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed downloading session, err=%v", err)
+	}
+	if downloadResp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(downloadResp.Body)
+		_ = downloadResp.Body.Close()
+		return nil, fmt.Errorf("failed downloading session, status-code=%v, payload=%v", downloadResp.StatusCode, string(data))
+	}
+	return downloadResp.Body, nil
+}
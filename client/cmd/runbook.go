@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/hoophq/hoop/client/cmd/styles"
+	clientconfig "github.com/hoophq/hoop/client/config"
+	"github.com/hoophq/hoop/common/httpclient"
+	"github.com/hoophq/hoop/common/version"
+	"github.com/hoophq/hoop/gateway/api/openapi"
+	"github.com/hoophq/hoop/gateway/api/runbooks/templates"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var runbookFlags = struct {
+	inputs []string
+	ref    string
+}{}
+
+var exampleRunbookRun = `
+hoop runbook run pgdemo ops/charge.runbook.sql
+
+# provide the inputs as flags, the missing ones are prompted
+hoop runbook run pgdemo ops/charge.runbook.sql -i customer_id=10 -i env=prod
+
+# run the runbook from a branch of the repository
+hoop runbook run pgdemo ops/charge.runbook.sql --ref staging
+`
+
+var runbookCmd = &cobra.Command{
+	Use:   "runbook",
+	Short: "Runbooks commands",
+}
+
+var runbookRunCmd = &cobra.Command{
+	Use:     "run CONNECTION FILE",
+	Short:   "Execute a runbook prompting for its inputs",
+	Example: exampleRunbookRun,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if len(args) < 2 {
+			cmd.Usage()
+			os.Exit(1)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		runRunbook(args[0], args[1])
+	},
+}
+
+func init() {
+	runbookRunCmd.Flags().StringArrayVarP(&runbookFlags.inputs, "input", "i", nil, "The inputs of the runbook in the format key=value")
+	runbookRunCmd.Flags().StringVar(&runbookFlags.ref, "ref", "", "The branch, tag or commit sha of the runbooks repository")
+	runbookCmd.AddCommand(runbookRunCmd)
+	rootCmd.AddCommand(runbookCmd)
+}
+
+func runRunbook(connectionName, fileName string) {
+	config := clientconfig.GetClientConfigOrDie()
+	inputs := map[string]string{}
+	for _, keyVal := range runbookFlags.inputs {
+		key, val, found := strings.Cut(keyVal, "=")
+		if !found {
+			printErrorAndExit("invalid input %q, expected key=value", keyVal)
+		}
+		inputs[key] = val
+	}
+
+	query := url.Values{}
+	if runbookFlags.ref != "" {
+		query.Set("ref", runbookFlags.ref)
+	}
+	var runbookList openapi.RunbookList
+	listURI := fmt.Sprintf("/api/plugins/runbooks/connections/%s/templates?%s", url.PathEscape(connectionName), query.Encode())
+	if err := runbookHTTPRequest(config, "GET", listURI, nil, &runbookList); err != nil {
+		printErrorAndExit(err.Error())
+	}
+	var runbook *openapi.Runbook
+	for _, item := range runbookList.Items {
+		if item.Name == fileName {
+			runbook = item
+			break
+		}
+	}
+	if runbook == nil {
+		printErrorAndExit("runbook %v not found for connection %v", fileName, connectionName)
+	}
+	if runbook.Error != nil {
+		printErrorAndExit("runbook %v has errors: %v", fileName, *runbook.Error)
+	}
+
+	schema := runbook.Schema
+	if schema == nil {
+		schema = schemaFromMetadata(runbook.Metadata)
+	}
+	parameters, err := promptRunbookInputs(schema, inputs)
+	if err != nil {
+		printErrorAndExit(err.Error())
+	}
+
+	loader := spinner.New(spinner.CharSets[11], 70*time.Millisecond,
+		spinner.WithWriter(os.Stderr), spinner.WithHiddenCursor(true))
+	loader.Color("green")
+	loader.Suffix = " running ..."
+	loader.Start()
+	var resp openapi.ExecResponse
+	execURI := fmt.Sprintf("/api/plugins/runbooks/connections/%s/exec", url.PathEscape(connectionName))
+	err = runbookHTTPRequest(config, "POST", execURI, openapi.RunbookRequest{
+		FileName:   fileName,
+		Ref:        runbookFlags.ref,
+		RefHash:    runbookList.Commit,
+		Parameters: parameters,
+	}, &resp)
+	loader.Stop()
+	if err != nil {
+		printErrorAndExit(err.Error())
+	}
+	switch {
+	case resp.HasReview:
+		fmt.Fprintf(os.Stderr, "the execution requires a review, session: %v\n", resp.SessionID)
+	case resp.OutputStatus == "running":
+		fmt.Fprintf(os.Stderr, "the execution is still running, session: %v\n", resp.SessionID)
+	}
+	os.Stdout.Write([]byte(resp.Output))
+	if resp.ExitCode > 0 {
+		os.Exit(resp.ExitCode)
+	}
+	if resp.OutputStatus == "failed" {
+		os.Exit(1)
+	}
+}
+
+// schemaFromMetadata returns a schema from the attributes of runbooks without a schema file
+func schemaFromMetadata(metadata map[string]any) *openapi.RunbookSchema {
+	var names []string
+	for name := range metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	schema := &openapi.RunbookSchema{}
+	for _, name := range names {
+		attr, _ := metadata[name].(map[string]any)
+		in := openapi.RunbookSchemaInput{Name: name, Type: templates.InputTypeText}
+		in.Description, _ = attr["description"].(string)
+		in.Default, _ = attr["default"].(string)
+		in.Required, _ = attr["required"].(bool)
+		if options, _ := attr["options"].([]any); len(options) > 0 && attr["type"] == "select" {
+			in.Type = templates.InputTypeEnum
+			for _, opt := range options {
+				in.Options = append(in.Options, fmt.Sprintf("%v", opt))
+			}
+		}
+		schema.Inputs = append(schema.Inputs, in)
+	}
+	return schema
+}
+
+// promptRunbookInputs asks for the enabled inputs that were not provided, the inputs are
+// validated by the gateway as well, it only improves the feedback for the user.
+func promptRunbookInputs(schema *openapi.RunbookSchema, inputs map[string]string) (map[string]string, error) {
+	stdinFd := int(os.Stdin.Fd())
+	isTerminal := term.IsTerminal(stdinFd)
+	reader := bufio.NewReader(os.Stdin)
+	for _, in := range schema.Inputs {
+		if !templates.IsInputEnabled(in, inputs) {
+			delete(inputs, in.Name)
+			continue
+		}
+		if val, ok := inputs[in.Name]; ok {
+			if val == "" {
+				continue
+			}
+			if _, err := templates.ValidateInput(in, val); err != nil {
+				return nil, fmt.Errorf("input %v %v", in.Name, err)
+			}
+			continue
+		}
+		if !isTerminal {
+			if in.Required && in.Default == "" {
+				return nil, fmt.Errorf("missing required input %v, use the flag --input %v=<value>", in.Name, in.Name)
+			}
+			continue
+		}
+		for {
+			fmt.Fprint(os.Stderr, inputPromptLabel(in))
+			var val string
+			if in.Type == templates.InputTypeSecret {
+				secret, err := term.ReadPassword(stdinFd)
+				fmt.Fprintln(os.Stderr)
+				if err != nil {
+					return nil, fmt.Errorf("failed reading input %v: %v", in.Name, err)
+				}
+				val = string(secret)
+			} else {
+				line, err := reader.ReadString('\n')
+				if err != nil && err != io.EOF {
+					return nil, fmt.Errorf("failed reading input %v: %v", in.Name, err)
+				}
+				val = strings.TrimSpace(line)
+			}
+			if val == "" {
+				val = in.Default
+			}
+			if val == "" && in.Required {
+				fmt.Fprintln(os.Stderr, styles.ClientError(fmt.Sprintf("%v is required", in.Name)))
+				continue
+			}
+			if val != "" {
+				if _, err := templates.ValidateInput(in, val); err != nil {
+					fmt.Fprintln(os.Stderr, styles.ClientError(fmt.Sprintf("%v %v", in.Name, err)))
+					continue
+				}
+			}
+			inputs[in.Name] = val
+			break
+		}
+	}
+	return inputs, nil
+}
+
+// e.g.: env (the environment) one of [prod staging] [staging]:
+func inputPromptLabel(in openapi.RunbookSchemaInput) string {
+	label := styles.Keyword(" " + in.Name + " ")
+	if in.Description != "" {
+		label += fmt.Sprintf(" (%v)", in.Description)
+	}
+	switch in.Type {
+	case templates.InputTypeEnum:
+		label += fmt.Sprintf(" one of %v", in.Options)
+	case templates.InputTypeDate:
+		label += " YYYY-MM-DD"
+	case templates.InputTypeList:
+		separator := in.Separator
+		if separator == "" {
+			separator = ","
+		}
+		label += fmt.Sprintf(" separated by %q", separator)
+	}
+	if in.Default != "" {
+		label += fmt.Sprintf(" [%v]", in.Default)
+	}
+	return label + ": "
+}
+
+func runbookHTTPRequest(conf *clientconfig.Config, method, uri string, body, into any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed encoding request body, err=%v", err)
+		}
+		reqBody = bytes.NewBuffer(data)
+	}
+	req, err := http.NewRequest(method, conf.ApiURL+uri, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed creating http request, err=%v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", conf.Token))
+	req.Header.Set("User-Agent", fmt.Sprintf("hoopcli/%s", version.Get().Version))
+	resp, err := httpclient.NewHttpClient(conf.TlsCA()).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed performing request, err=%v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		var httpErr openapi.HTTPError
+		respBody, _ := io.ReadAll(resp.Body)
+		if err := json.Unmarshal(respBody, &httpErr); err == nil && httpErr.Message != "" {
+			return fmt.Errorf("%v", httpErr.Message)
+		}
+		return fmt.Errorf("failed performing request, status=%v, body=%v", resp.StatusCode, string(respBody))
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
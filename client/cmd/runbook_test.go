@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hoophq/hoop/gateway/api/openapi"
+)
+
+func TestPromptRunbookInputsWithoutTerminal(t *testing.T) {
+	schema := &openapi.RunbookSchema{Inputs: []openapi.RunbookSchemaInput{
+		{Name: "customer_id", Type: "int", Required: true, Max: "100"},
+		{Name: "env", Type: "enum", Options: []string{"prod", "staging"}, Default: "staging"},
+		{Name: "reason", Type: "text", Required: true, DependsOn: map[string][]string{"env": {"prod"}}},
+	}}
+	for _, tt := range []struct {
+		msg     string
+		inputs  map[string]string
+		want    map[string]string
+		wantErr error
+	}{
+		{
+			msg:    "it should skip disabled inputs",
+			inputs: map[string]string{"customer_id": "10", "env": "staging", "reason": "noop"},
+			want:   map[string]string{"customer_id": "10", "env": "staging"},
+		},
+		{
+			msg:     "it should return an error when a required input is not provided",
+			inputs:  map[string]string{"env": "staging"},
+			wantErr: fmt.Errorf("missing required input customer_id, use the flag --input customer_id=<value>"),
+		},
+		{
+			msg:     "it should return an error when a dependent required input is not provided",
+			inputs:  map[string]string{"customer_id": "10", "env": "prod"},
+			wantErr: fmt.Errorf("missing required input reason, use the flag --input reason=<value>"),
+		},
+		{
+			msg:     "it should return an error when an input is not valid",
+			inputs:  map[string]string{"customer_id": "101"},
+			wantErr: fmt.Errorf("input customer_id must be less than or equal to 100"),
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := promptRunbookInputs(schema, tt.inputs)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("expected error %q, got=%v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("inputs does not match: %v", diff)
+			}
+		})
+	}
+}
+
+func TestSchemaFromMetadata(t *testing.T) {
+	got := schemaFromMetadata(map[string]any{
+		"wallet_id": map[string]any{"type": "text", "required": true, "description": "the wallet"},
+		"country":   map[string]any{"type": "select", "options": []any{"US", "BR"}, "default": "US"},
+	})
+	want := &openapi.RunbookSchema{Inputs: []openapi.RunbookSchemaInput{
+		{Name: "country", Type: "enum", Options: []string{"US", "BR"}, Default: "US"},
+		{Name: "wallet_id", Type: "text", Required: true, Description: "the wallet"},
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("schema does not match: %v", diff)
+	}
+}
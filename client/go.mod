@@ -9,6 +9,7 @@ require (
 	github.com/creack/pty v1.1.21
 	github.com/hoophq/hoop/common v0.0.0-00010101000000-000000000000
 	github.com/spf13/cobra v1.6.1
+	golang.org/x/crypto v0.24.0
 	golang.org/x/term v0.21.0
 )
 
@@ -176,7 +177,6 @@ require (
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.25.0 // indirect
 	golang.org/x/arch v0.5.0 // indirect
-	golang.org/x/crypto v0.24.0 // indirect
 	golang.org/x/mod v0.17.0 // indirect
 	golang.org/x/oauth2 v0.17.0 // indirect
 	golang.org/x/sync v0.7.0 // indirect
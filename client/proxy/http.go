@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/hoophq/hoop/common/httptypes"
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/common/memory"
+	pb "github.com/hoophq/hoop/common/proto"
+	pbagent "github.com/hoophq/hoop/common/proto/agent"
+)
+
+const defaultHTTPPort = "8081"
+
+// HTTPServer accepts plain HTTP requests of local clients, the requests are sent
+// to the agent that forwards them to the remote server of the connection.
+type HTTPServer struct {
+	listenPort      string
+	client          pb.ClientTransport
+	connectionStore memory.Store
+	listener        net.Listener
+}
+
+func NewHTTPServer(listenPort string, client pb.ClientTransport) *HTTPServer {
+	if listenPort == "" {
+		listenPort = defaultHTTPPort
+	}
+	return &HTTPServer{
+		listenPort:      listenPort,
+		client:          client,
+		connectionStore: memory.New(),
+	}
+}
+
+func (s *HTTPServer) Serve(sessionID string) error {
+	listenAddr := fmt.Sprintf("127.0.0.1:%s", s.listenPort)
+	lis, err := net.Listen("tcp4", listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed listening to address %v, err=%v", listenAddr, err)
+	}
+	s.listener = lis
+	go func() {
+		connectionID := 0
+		for {
+			connectionID++
+			httpClient, err := lis.Accept()
+			if err != nil {
+				log.Infof("failed obtain listening connection, err=%v", err)
+				lis.Close()
+				break
+			}
+			go s.serveConn(sessionID, strconv.Itoa(connectionID), httpClient)
+		}
+	}()
+	return nil
+}
+
+func (s *HTTPServer) serveConn(sessionID, connectionID string, httpClient net.Conn) {
+	defer func() {
+		log.Infof("session=%v | conn=%s | remote=%s - closing tcp connection",
+			sessionID, connectionID, httpClient.RemoteAddr())
+		s.connectionStore.Del(connectionID)
+		if err := httpClient.Close(); err != nil {
+			log.Warnf("failed closing client connection, err=%v", err)
+		}
+		_ = s.client.Send(&pb.Packet{
+			Type: pbagent.TCPConnectionClose,
+			Spec: map[string][]byte{
+				pb.SpecClientConnectionID: []byte(connectionID),
+				pb.SpecGatewaySessionID:   []byte(sessionID),
+			}})
+	}()
+	connWrapper := pb.NewConnectionWrapper(httpClient, make(chan struct{}))
+	s.connectionStore.Set(connectionID, connWrapper)
+
+	log.Infof("session=%v | conn=%s | client=%s - connected", sessionID, connectionID, httpClient.RemoteAddr())
+	w := pb.NewStreamWriter(s.client, pbagent.HTTPConnectionWrite, map[string][]byte{
+		string(pb.SpecClientConnectionID): []byte(connectionID),
+		string(pb.SpecGatewaySessionID):   []byte(sessionID),
+	})
+	// send a request per stream, it allows decoding the requests in the gateway
+	clientR := bufio.NewReader(httpClient)
+	for {
+		req, err := httptypes.ReadRequest(clientR)
+		if err != nil {
+			switch {
+			case err == httptypes.ErrBodyTooLarge:
+				_, _ = connWrapper.Write(httptypes.NewErrorResponse(http.StatusRequestEntityTooLarge, err.Error()))
+			case err != io.EOF:
+				log.Infof("failed decoding request, err=%v", err)
+				_, _ = connWrapper.Write(httptypes.NewErrorResponse(http.StatusBadRequest, err.Error()))
+			}
+			connWrapper.Close()
+			return
+		}
+		if _, err := w.Write(req); err != nil {
+			log.Infof("failed copying buffer, err=%v", err)
+			connWrapper.Close()
+			return
+		}
+	}
+}
+
+func (s *HTTPServer) PacketWriteClient(connectionID string, pkt *pb.Packet) (int, error) {
+	conn, err := s.getConnection(connectionID)
+	if err != nil {
+		// the client could close the connection before receiving the response of a request
+		log.Debugf("discarding http response, %v", err)
+		return 0, nil
+	}
+	return conn.Write(pkt.Payload)
+}
+
+func (s *HTTPServer) CloseTCPConnection(connectionID string) {
+	if conn, err := s.getConnection(connectionID); err == nil {
+		_ = conn.Close()
+	}
+}
+
+func (s *HTTPServer) Close() error       { return s.listener.Close() }
+func (s *HTTPServer) ListenPort() string { return s.listenPort }
+
+func (s *HTTPServer) getConnection(connectionID string) (io.WriteCloser, error) {
+	connWrapperObj := s.connectionStore.Get(connectionID)
+	conn, ok := connWrapperObj.(io.WriteCloser)
+	if !ok {
+		return nil, fmt.Errorf("local connection %q not found", connectionID)
+	}
+	return conn, nil
+}
@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/common/memory"
+	"github.com/hoophq/hoop/common/oracletypes"
+	pb "github.com/hoophq/hoop/common/proto"
+	pbagent "github.com/hoophq/hoop/common/proto/agent"
+)
+
+const defaultOracleDBPort = "1522"
+
+type OracleDBServer struct {
+	listenPort      string
+	client          pb.ClientTransport
+	connectionStore memory.Store
+	listener        net.Listener
+}
+
+// oracleConn keeps the format of the packets negotiated by the connection
+type oracleConn struct {
+	io.WriteCloser
+	largeSDU atomic.Bool
+}
+
+func NewOracleDBServer(listenPort string, client pb.ClientTransport) *OracleDBServer {
+	if listenPort == "" {
+		listenPort = defaultOracleDBPort
+	}
+	return &OracleDBServer{
+		listenPort:      listenPort,
+		client:          client,
+		connectionStore: memory.New(),
+	}
+}
+
+func (s *OracleDBServer) Serve(sessionID string) error {
+	listenAddr := fmt.Sprintf("127.0.0.1:%s", s.listenPort)
+	lis, err := net.Listen("tcp4", listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed listening to address %v, err=%v", listenAddr, err)
+	}
+	s.listener = lis
+	go func() {
+		connectionID := 0
+		for {
+			connectionID++
+			oracleClient, err := lis.Accept()
+			if err != nil {
+				log.Infof("failed obtain listening connection, err=%v", err)
+				lis.Close()
+				break
+			}
+			go s.serveConn(sessionID, strconv.Itoa(connectionID), oracleClient)
+		}
+	}()
+	return nil
+}
+
+func (s *OracleDBServer) serveConn(sessionID, connectionID string, oracleClient net.Conn) {
+	defer func() {
+		log.Infof("session=%v | conn=%s | remote=%s - closing tcp connection",
+			sessionID, connectionID, oracleClient.RemoteAddr())
+		s.connectionStore.Del(connectionID)
+		if err := oracleClient.Close(); err != nil {
+			log.Warnf("failed closing client connection, err=%v", err)
+		}
+		_ = s.client.Send(&pb.Packet{
+			Type: pbagent.TCPConnectionClose,
+			Spec: map[string][]byte{
+				pb.SpecClientConnectionID: []byte(connectionID),
+				pb.SpecGatewaySessionID:   []byte(sessionID),
+			}})
+	}()
+	conn := &oracleConn{WriteCloser: pb.NewConnectionWrapper(oracleClient, make(chan struct{}))}
+	s.connectionStore.Set(connectionID, conn)
+
+	log.Infof("session=%v | conn=%s | client=%s - connected", sessionID, connectionID, oracleClient.RemoteAddr())
+	w := pb.NewStreamWriter(s.client, pbagent.OracleDBConnectionWrite, map[string][]byte{
+		string(pb.SpecClientConnectionID): []byte(connectionID),
+		string(pb.SpecGatewaySessionID):   []byte(sessionID),
+	})
+	// send a packet per stream, it allows decoding the statements in the gateway
+	for {
+		pkt, err := oracletypes.Decode(oracleClient, conn.largeSDU.Load())
+		if err != nil {
+			if err != io.EOF {
+				log.Infof("failed decoding packet, err=%v", err)
+			}
+			conn.Close()
+			return
+		}
+		if _, err := w.Write(pkt.Encode()); err != nil {
+			log.Infof("failed copying buffer, err=%v", err)
+			conn.Close()
+			return
+		}
+	}
+}
+
+func (s *OracleDBServer) PacketWriteClient(connectionID string, pkt *pb.Packet) (int, error) {
+	conn, err := s.getConnection(connectionID)
+	if err != nil {
+		return 0, err
+	}
+	// the client encodes the length of the next packets with 4 bytes after the server accepts the
+	// version, it must be set before the client receives the packet. The accept packet is always
+	// sent in a single stream by the agent.
+	if len(pkt.Payload) > 4 && oracletypes.PacketType(pkt.Payload[4]) == oracletypes.PacketAcceptType {
+		accept, err := oracletypes.Decode(bytes.NewReader(pkt.Payload), false)
+		if err == nil && accept.Version() >= oracletypes.LargeSDUVersion {
+			conn.largeSDU.Store(true)
+		}
+	}
+	return conn.Write(pkt.Payload)
+}
+
+func (s *OracleDBServer) CloseTCPConnection(connectionID string) {
+	if conn, err := s.getConnection(connectionID); err == nil {
+		_ = conn.Close()
+	}
+}
+
+func (s *OracleDBServer) Close() error       { return s.listener.Close() }
+func (s *OracleDBServer) ListenPort() string { return s.listenPort }
+
+func (s *OracleDBServer) getConnection(connectionID string) (*oracleConn, error) {
+	connWrapperObj := s.connectionStore.Get(connectionID)
+	conn, ok := connWrapperObj.(*oracleConn)
+	if !ok {
+		return nil, fmt.Errorf("local connection %q not found", connectionID)
+	}
+	return conn, nil
+}
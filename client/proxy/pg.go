@@ -151,13 +151,15 @@ func (p *PGServer) ListenPort() string {
 	return ""
 }
 
-func (p *PGServer) lookupConnectionByPid(pid uint32) *pgConnection {
+// lookupConnectionByPid returns the connection of the key data, the secret key is validated
+// because the agent could generate the key data when the connections are pooled.
+func (p *PGServer) lookupConnectionByPid(pid, secretKey uint32) *pgConnection {
 	for _, obj := range p.connectionStore.List() {
 		conn, _ := obj.(*pgConnection)
 		if conn == nil || conn.backendKeyData == nil {
 			continue
 		}
-		if conn.backendKeyData.Pid == pid {
+		if conn.backendKeyData.Pid == pid && conn.backendKeyData.SecretKey == secretKey {
 			return conn
 		}
 	}
@@ -177,10 +179,9 @@ func (p *PGServer) copyPGBuffer(dst io.Writer, src *pgConnection) (written int64
 		// A cancel request is sent by a second connection
 		// the response must be received by the pid's connection.
 		// See: https://www.postgresql.org/docs/current/protocol-flow.html#PROTOCOL-FLOW-CANCELING-REQUESTS
-		if pkt.IsCancelRequest() {
-			frame := pkt.Frame()
+		if frame := pkt.Frame(); pkt.IsCancelRequest() && len(frame) >= 12 {
 			pid := binary.BigEndian.Uint32(frame[4:8])
-			pidsConn := p.lookupConnectionByPid(pid)
+			pidsConn := p.lookupConnectionByPid(pid, binary.BigEndian.Uint32(frame[8:12]))
 			if pidsConn != nil {
 				// swap the cancel connection with the pid's connection
 				p.connectionStore.Set(src.id, pidsConn)
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/common/memory"
+	pb "github.com/hoophq/hoop/common/proto"
+	pbagent "github.com/hoophq/hoop/common/proto/agent"
+	"github.com/hoophq/hoop/common/redistypes"
+)
+
+const defaultRedisPort = "6380"
+
+type RedisServer struct {
+	listenPort      string
+	client          pb.ClientTransport
+	connectionStore memory.Store
+	listener        net.Listener
+}
+
+func NewRedisServer(listenPort string, client pb.ClientTransport) *RedisServer {
+	if listenPort == "" {
+		listenPort = defaultRedisPort
+	}
+	return &RedisServer{
+		listenPort:      listenPort,
+		client:          client,
+		connectionStore: memory.New(),
+	}
+}
+
+func (s *RedisServer) Serve(sessionID string) error {
+	listenAddr := fmt.Sprintf("127.0.0.1:%s", s.listenPort)
+	lis, err := net.Listen("tcp4", listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed listening to address %v, err=%v", listenAddr, err)
+	}
+	s.listener = lis
+	go func() {
+		connectionID := 0
+		for {
+			connectionID++
+			redisClient, err := lis.Accept()
+			if err != nil {
+				log.Infof("failed obtain listening connection, err=%v", err)
+				lis.Close()
+				break
+			}
+			go s.serveConn(sessionID, strconv.Itoa(connectionID), redisClient)
+		}
+	}()
+	return nil
+}
+
+func (s *RedisServer) serveConn(sessionID, connectionID string, redisClient net.Conn) {
+	defer func() {
+		log.Infof("session=%v | conn=%s | remote=%s - closing tcp connection",
+			sessionID, connectionID, redisClient.RemoteAddr())
+		s.connectionStore.Del(connectionID)
+		if err := redisClient.Close(); err != nil {
+			log.Warnf("failed closing client connection, err=%v", err)
+		}
+		_ = s.client.Send(&pb.Packet{
+			Type: pbagent.TCPConnectionClose,
+			Spec: map[string][]byte{
+				pb.SpecClientConnectionID: []byte(connectionID),
+				pb.SpecGatewaySessionID:   []byte(sessionID),
+			}})
+	}()
+	connWrapper := pb.NewConnectionWrapper(redisClient, make(chan struct{}))
+	s.connectionStore.Set(connectionID, connWrapper)
+
+	log.Infof("session=%v | conn=%s | client=%s - connected", sessionID, connectionID, redisClient.RemoteAddr())
+	w := pb.NewStreamWriter(s.client, pbagent.RedisConnectionWrite, map[string][]byte{
+		string(pb.SpecClientConnectionID): []byte(connectionID),
+		string(pb.SpecGatewaySessionID):   []byte(sessionID),
+	})
+	// send a command per stream, it allows decoding the commands in the gateway
+	clientR := bufio.NewReader(redisClient)
+	for {
+		cmd, err := redistypes.DecodeCommand(clientR)
+		if err != nil {
+			if err != io.EOF {
+				log.Infof("failed decoding command, err=%v", err)
+			}
+			connWrapper.Close()
+			return
+		}
+		if _, err := w.Write(cmd.Raw()); err != nil {
+			log.Infof("failed copying buffer, err=%v", err)
+			connWrapper.Close()
+			return
+		}
+	}
+}
+
+func (s *RedisServer) PacketWriteClient(connectionID string, pkt *pb.Packet) (int, error) {
+	conn, err := s.getConnection(connectionID)
+	if err != nil {
+		return 0, err
+	}
+	return conn.Write(pkt.Payload)
+}
+
+func (s *RedisServer) CloseTCPConnection(connectionID string) {
+	if conn, err := s.getConnection(connectionID); err == nil {
+		_ = conn.Close()
+	}
+}
+
+func (s *RedisServer) Close() error       { return s.listener.Close() }
+func (s *RedisServer) ListenPort() string { return s.listenPort }
+
+func (s *RedisServer) getConnection(connectionID string) (io.WriteCloser, error) {
+	connWrapperObj := s.connectionStore.Get(connectionID)
+	conn, ok := connWrapperObj.(io.WriteCloser)
+	if !ok {
+		return nil, fmt.Errorf("local connection %q not found", connectionID)
+	}
+	return conn, nil
+}
@@ -0,0 +1,299 @@
+package proxy
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/common/memory"
+	pb "github.com/hoophq/hoop/common/proto"
+	pbagent "github.com/hoophq/hoop/common/proto/agent"
+	"github.com/hoophq/hoop/common/sshtypes"
+	"golang.org/x/crypto/ssh"
+)
+
+const defaultSSHPort = "2222"
+
+// SSHServer accepts connections of native SSH clients without authentication,
+// the channels of the connections are relayed to the server by the agent.
+type SSHServer struct {
+	listenPort      string
+	client          pb.ClientTransport
+	connectionStore memory.Store
+	listener        net.Listener
+	hostKey         ssh.Signer
+}
+
+// sshConn is a local SSH connection, the channels are identified by the id sent to the agent
+type sshConn struct {
+	conn  *ssh.ServerConn
+	w     io.Writer
+	doneC chan struct{}
+
+	mu        sync.Mutex
+	channels  map[uint32]*sshChannel
+	closeOnce sync.Once
+}
+
+type sshChannel struct {
+	newCh ssh.NewChannel
+	// ch is set when the agent confirms that the channel was opened in the server
+	ch     ssh.Channel
+	replyC chan bool
+}
+
+func NewSSHServer(listenPort string, client pb.ClientTransport) *SSHServer {
+	if listenPort == "" {
+		listenPort = defaultSSHPort
+	}
+	return &SSHServer{
+		listenPort:      listenPort,
+		client:          client,
+		connectionStore: memory.New(),
+	}
+}
+
+func (s *SSHServer) Serve(sessionID string) error {
+	// the host key is ephemeral, the clients are always local
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed generating host key, err=%v", err)
+	}
+	if s.hostKey, err = ssh.NewSignerFromKey(privateKey); err != nil {
+		return fmt.Errorf("failed generating host key, err=%v", err)
+	}
+	listenAddr := fmt.Sprintf("127.0.0.1:%s", s.listenPort)
+	lis, err := net.Listen("tcp4", listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed listening to address %v, err=%v", listenAddr, err)
+	}
+	s.listener = lis
+	go func() {
+		connectionID := 0
+		for {
+			connectionID++
+			sshClient, err := lis.Accept()
+			if err != nil {
+				log.Infof("failed obtain listening connection, err=%v", err)
+				lis.Close()
+				break
+			}
+			go s.serveConn(sessionID, strconv.Itoa(connectionID), sshClient)
+		}
+	}()
+	return nil
+}
+
+func (s *SSHServer) serveConn(sessionID, connectionID string, sshClient net.Conn) {
+	defer func() {
+		log.Infof("session=%v | conn=%s | remote=%s - closing tcp connection",
+			sessionID, connectionID, sshClient.RemoteAddr())
+		s.connectionStore.Del(connectionID)
+		if err := sshClient.Close(); err != nil {
+			log.Warnf("failed closing client connection, err=%v", err)
+		}
+		_ = s.client.Send(&pb.Packet{
+			Type: pbagent.TCPConnectionClose,
+			Spec: map[string][]byte{
+				pb.SpecClientConnectionID: []byte(connectionID),
+				pb.SpecGatewaySessionID:   []byte(sessionID),
+			}})
+	}()
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(s.hostKey)
+	serverConn, chans, reqs, err := ssh.NewServerConn(sshClient, config)
+	if err != nil {
+		log.Infof("session=%v | conn=%s - failed ssh handshake, err=%v", sessionID, connectionID, err)
+		return
+	}
+	// global requests (e.g.: tcpip-forward) are not supported
+	go ssh.DiscardRequests(reqs)
+
+	log.Infof("session=%v | conn=%s | client=%s - connected", sessionID, connectionID, sshClient.RemoteAddr())
+	conn := &sshConn{
+		conn: serverConn,
+		w: pb.NewStreamWriter(s.client, pbagent.SSHConnectionWrite, map[string][]byte{
+			string(pb.SpecClientConnectionID): []byte(connectionID),
+			string(pb.SpecGatewaySessionID):   []byte(sessionID),
+		}),
+		doneC:    make(chan struct{}),
+		channels: map[uint32]*sshChannel{},
+	}
+	s.connectionStore.Set(connectionID, conn)
+	defer conn.Close()
+	var channelID uint32
+	for newCh := range chans {
+		channelID++
+		conn.setChannel(channelID, &sshChannel{newCh: newCh, replyC: make(chan bool, 1)})
+		err := conn.send(&sshtypes.Message{
+			Type:      sshtypes.MessageOpenChannel,
+			ChannelID: channelID,
+			Name:      newCh.ChannelType(),
+			Data:      newCh.ExtraData(),
+		})
+		if err != nil {
+			log.Infof("failed opening channel, err=%v", err)
+			return
+		}
+	}
+}
+
+func (s *SSHServer) PacketWriteClient(connectionID string, pkt *pb.Packet) (int, error) {
+	conn, err := s.getConnection(connectionID)
+	if err != nil {
+		// the agent could send the last messages of the channels after the local client disconnects
+		log.Debugf("discarding ssh message, %v", err)
+		return 0, nil
+	}
+	msg, err := sshtypes.Decode(pkt.Payload)
+	if err != nil {
+		return 0, err
+	}
+	return len(pkt.Payload), conn.processMessage(msg)
+}
+
+func (s *SSHServer) CloseTCPConnection(connectionID string) {
+	if conn, err := s.getConnection(connectionID); err == nil {
+		_ = conn.Close()
+	}
+}
+
+func (s *SSHServer) Close() error       { return s.listener.Close() }
+func (s *SSHServer) ListenPort() string { return s.listenPort }
+
+func (s *SSHServer) getConnection(connectionID string) (*sshConn, error) {
+	connObj := s.connectionStore.Get(connectionID)
+	conn, ok := connObj.(*sshConn)
+	if !ok {
+		return nil, fmt.Errorf("local connection %q not found", connectionID)
+	}
+	return conn, nil
+}
+
+// processMessage applies the message of the agent in the local channel
+func (c *sshConn) processMessage(msg *sshtypes.Message) error {
+	channel := c.getChannel(msg.ChannelID)
+	if channel == nil {
+		log.Debugf("ssh channel not found, %v", msg)
+		return nil
+	}
+	switch msg.Type {
+	case sshtypes.MessageOpenConfirm:
+		ch, reqs, err := channel.newCh.Accept()
+		if err != nil {
+			c.delChannel(msg.ChannelID)
+			return fmt.Errorf("failed accepting ssh channel, err=%v", err)
+		}
+		channel.ch = ch
+		go c.relayChannel(msg.ChannelID, channel, reqs)
+		return nil
+	case sshtypes.MessageOpenFailure:
+		c.delChannel(msg.ChannelID)
+		return channel.newCh.Reject(ssh.ConnectionFailed, string(msg.Data))
+	case sshtypes.MessageRequestReply:
+		select {
+		case channel.replyC <- msg.WantReply:
+		default:
+		}
+		return nil
+	}
+	if channel.ch == nil {
+		return nil
+	}
+	var err error
+	switch msg.Type {
+	case sshtypes.MessageRequest:
+		_, err = channel.ch.SendRequest(msg.Name, false, msg.Data)
+	case sshtypes.MessageData:
+		_, err = channel.ch.Write(msg.Data)
+	case sshtypes.MessageExtendedData:
+		_, err = channel.ch.Stderr().Write(msg.Data)
+	case sshtypes.MessageEOF:
+		err = channel.ch.CloseWrite()
+	case sshtypes.MessageClose:
+		c.delChannel(msg.ChannelID)
+		err = channel.ch.Close()
+	}
+	// the errors of a channel don't end the connection
+	if err != nil && err != io.EOF {
+		log.Debugf("failed processing ssh message, %v, reason=%v", msg, err)
+	}
+	return nil
+}
+
+// relayChannel sends the content and requests of the local channel
+// to the agent until the channel is closed by the local client
+func (c *sshConn) relayChannel(channelID uint32, channel *sshChannel, reqs <-chan *ssh.Request) {
+	dataDone := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = sshtypes.Copy(c.w, channelID, sshtypes.MessageData, channel.ch)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = sshtypes.Copy(c.w, channelID, sshtypes.MessageExtendedData, channel.ch.Stderr())
+		}()
+		wg.Wait()
+		_ = c.send(&sshtypes.Message{Type: sshtypes.MessageEOF, ChannelID: channelID})
+		close(dataDone)
+	}()
+	for req := range reqs {
+		err := c.send(&sshtypes.Message{
+			Type:      sshtypes.MessageRequest,
+			ChannelID: channelID,
+			WantReply: req.WantReply,
+			Name:      req.Type,
+			Data:      req.Payload,
+		})
+		if err != nil || !req.WantReply {
+			continue
+		}
+		// the requests are replied in order by the agent
+		select {
+		case ok := <-channel.replyC:
+			_ = req.Reply(ok, nil)
+		case <-c.doneC:
+			_ = req.Reply(false, nil)
+		}
+	}
+	<-dataDone
+	c.delChannel(channelID)
+	_ = channel.ch.Close()
+	_ = c.send(&sshtypes.Message{Type: sshtypes.MessageClose, ChannelID: channelID})
+}
+
+func (c *sshConn) send(msg *sshtypes.Message) error {
+	_, err := c.w.Write(msg.Encode())
+	return err
+}
+
+func (c *sshConn) getChannel(channelID uint32) *sshChannel {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.channels[channelID]
+}
+
+func (c *sshConn) setChannel(channelID uint32, channel *sshChannel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.channels[channelID] = channel
+}
+
+func (c *sshConn) delChannel(channelID uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.channels, channelID)
+}
+
+func (c *sshConn) Close() error {
+	c.closeOnce.Do(func() { close(c.doneC) })
+	return c.conn.Close()
+}
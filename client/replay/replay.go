@@ -0,0 +1,237 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Command controls the playback of a session
+type Command int
+
+const (
+	CommandTogglePause Command = iota + 1
+	CommandForward
+	CommandBackward
+	CommandQuit
+)
+
+// SeekStep is the amount of seconds to move forward or backward in a seek command
+const SeekStep = 5.0
+
+// resetTerminal clears the screen and resets the state of the terminal
+var resetTerminal = []byte("\x1bc")
+
+// Header is the first line of an asciicast v2 file
+// https://docs.asciinema.org/manual/asciicast/v2/
+type Header struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Title     string `json:"title"`
+}
+
+type Event struct {
+	// Time is the amount of seconds since the start of the session
+	Time float64
+	// Code is the type of the event: o (output) or i (input)
+	Code string
+	Data string
+}
+
+// Decode parses an asciicast v2 file
+func Decode(r io.Reader) (*Header, []Event, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, nil, fmt.Errorf("failed reading asciicast header, reason=%v", err)
+		}
+		return nil, nil, fmt.Errorf("asciicast file is empty")
+	}
+	var header Header
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, nil, fmt.Errorf("failed decoding asciicast header, reason=%v", err)
+	}
+	if header.Version != 2 {
+		return nil, nil, fmt.Errorf("unsupported asciicast version %v", header.Version)
+	}
+	var events []Event
+	for line := 2; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var ev []any
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			return nil, nil, fmt.Errorf("failed decoding event at line %v, reason=%v", line, err)
+		}
+		if len(ev) != 3 {
+			return nil, nil, fmt.Errorf("event at line %v in wrong format, got=%v items", line, len(ev))
+		}
+		eventTime, _ := ev[0].(float64)
+		code, _ := ev[1].(string)
+		data, _ := ev[2].(string)
+		events = append(events, Event{Time: eventTime, Code: code, Data: data})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed reading asciicast events, reason=%v", err)
+	}
+	return &header, events, nil
+}
+
+// Player writes the events of a session to out respecting the timing of each event
+type Player struct {
+	events []Event
+	out    io.Writer
+	speed  float64
+
+	// pos is the current time of the playback in seconds
+	pos float64
+	// idx is the index of the next event to be written
+	idx int
+}
+
+func NewPlayer(events []Event, out io.Writer, speed float64) *Player {
+	if speed <= 0 {
+		speed = 1
+	}
+	return &Player{events: events, out: out, speed: speed}
+}
+
+// Duration returns the total time of the session in seconds
+func (p *Player) Duration() float64 {
+	if len(p.events) == 0 {
+		return 0
+	}
+	return p.events[len(p.events)-1].Time
+}
+
+// Play writes the events until the end of the session, the context is done
+// or a quit command is received.
+func (p *Player) Play(ctx context.Context, commands <-chan Command) error {
+	paused := false
+	for p.idx < len(p.events) {
+		if paused {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case cmd := <-commands:
+				if cmd == CommandQuit {
+					return nil
+				}
+				if cmd == CommandTogglePause {
+					paused = false
+					continue
+				}
+				if err := p.handleSeek(cmd); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		ev := p.events[p.idx]
+		wait := time.Duration((ev.Time - p.pos) / p.speed * float64(time.Second))
+		startAt := time.Now()
+		timer := time.NewTimer(max(wait, 0))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+			if err := p.write(ev); err != nil {
+				return err
+			}
+			p.pos = max(p.pos, ev.Time)
+			p.idx++
+		case cmd := <-commands:
+			timer.Stop()
+			p.pos = min(p.pos+time.Since(startAt).Seconds()*p.speed, ev.Time)
+			switch cmd {
+			case CommandQuit:
+				return nil
+			case CommandTogglePause:
+				paused = true
+			default:
+				if err := p.handleSeek(cmd); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Player) handleSeek(cmd Command) error {
+	switch cmd {
+	case CommandForward:
+		return p.Seek(p.pos + SeekStep)
+	case CommandBackward:
+		return p.Seek(p.pos - SeekStep)
+	}
+	return nil
+}
+
+// Seek moves the playback to the target time. Moving forward writes all events up to
+// the target instantly. Moving backward resets the terminal and renders the session
+// again from the start, the state of the screen depends on all previous events.
+func (p *Player) Seek(target float64) error {
+	target = max(0, min(target, p.Duration()))
+	if target < p.pos {
+		if _, err := p.out.Write(resetTerminal); err != nil {
+			return err
+		}
+		p.idx = 0
+	}
+	for p.idx < len(p.events) && p.events[p.idx].Time <= target {
+		if err := p.write(p.events[p.idx]); err != nil {
+			return err
+		}
+		p.idx++
+	}
+	p.pos = target
+	return nil
+}
+
+func (p *Player) write(ev Event) error {
+	_, err := io.WriteString(p.out, ev.Data)
+	return err
+}
+
+// ParseKeys maps the keys pressed in a terminal in raw mode to playback commands:
+//
+//	space, p - pause or resume
+//	right arrow, l - forward
+//	left arrow, h - backward
+//	q, ctrl+c - quit
+func ParseKeys(data []byte) []Command {
+	var commands []Command
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case ' ', 'p':
+			commands = append(commands, CommandTogglePause)
+		case 'l':
+			commands = append(commands, CommandForward)
+		case 'h':
+			commands = append(commands, CommandBackward)
+		case 'q', 0x03:
+			commands = append(commands, CommandQuit)
+		case 0x1b:
+			// arrow keys are escape sequences: ESC [ C (right) and ESC [ D (left)
+			if i+2 < len(data) && data[i+1] == '[' {
+				switch data[i+2] {
+				case 'C':
+					commands = append(commands, CommandForward)
+				case 'D':
+					commands = append(commands, CommandBackward)
+				}
+				i += 2
+			}
+		}
+	}
+	return commands
+}
@@ -0,0 +1,142 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testCast = `{"version":2,"width":80,"height":24,"timestamp":1700000000,"title":"bash"}
+[0.1,"o","$ "]
+[1.5,"i","ls\r"]
+[2,"o","ls\r\n"]
+[7.25,"o","file.txt\r\n"]
+`
+
+func TestDecode(t *testing.T) {
+	for _, tt := range []struct {
+		msg        string
+		cast       string
+		wantEvents []Event
+		wantErr    string
+	}{
+		{
+			msg:  "it must decode the header and events",
+			cast: testCast,
+			wantEvents: []Event{
+				{Time: 0.1, Code: "o", Data: "$ "},
+				{Time: 1.5, Code: "i", Data: "ls\r"},
+				{Time: 2, Code: "o", Data: "ls\r\n"},
+				{Time: 7.25, Code: "o", Data: "file.txt\r\n"},
+			},
+		},
+		{
+			msg:     "it must fail with unsupported versions",
+			cast:    `{"version":1}`,
+			wantErr: "unsupported asciicast version 1",
+		},
+		{
+			msg:     "it must fail when the file is empty",
+			cast:    "",
+			wantErr: "asciicast file is empty",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			_, got, err := Decode(strings.NewReader(tt.cast))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expect error to match, got=%v, want=%v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("did not expect error, got=%v", err)
+			}
+			if diff := cmp.Diff(tt.wantEvents, got); diff != "" {
+				t.Errorf("not equal: %v", diff)
+			}
+		})
+	}
+}
+
+func TestSeek(t *testing.T) {
+	_, events, err := Decode(strings.NewReader(testCast))
+	if err != nil {
+		t.Fatalf("did not expect error, got=%v", err)
+	}
+	for _, tt := range []struct {
+		msg     string
+		seeks   []float64
+		want    string
+		wantPos float64
+	}{
+		{
+			msg:     "it must write all events up to the target",
+			seeks:   []float64{2},
+			want:    "$ ls\rls\r\n",
+			wantPos: 2,
+		},
+		{
+			msg:     "it must stop at the end of the session",
+			seeks:   []float64{100},
+			want:    "$ ls\rls\r\nfile.txt\r\n",
+			wantPos: 7.25,
+		},
+		{
+			msg:     "it must reset the terminal when seeking backward",
+			seeks:   []float64{7.25, 1},
+			want:    "$ ls\rls\r\nfile.txt\r\n\x1bc$ ",
+			wantPos: 1,
+		},
+		{
+			msg:     "it must not go before the start of the session",
+			seeks:   []float64{2, -5},
+			want:    "$ ls\rls\r\n\x1bc",
+			wantPos: 0,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			var out bytes.Buffer
+			p := NewPlayer(events, &out, 1)
+			for _, target := range tt.seeks {
+				if err := p.Seek(target); err != nil {
+					t.Fatalf("did not expect error, got=%v", err)
+				}
+			}
+			if diff := cmp.Diff(tt.want, out.String()); diff != "" {
+				t.Errorf("not equal: %v", diff)
+			}
+			if p.pos != tt.wantPos {
+				t.Errorf("expect position to match, got=%v, want=%v", p.pos, tt.wantPos)
+			}
+		})
+	}
+}
+
+func TestPlay(t *testing.T) {
+	_, events, err := Decode(strings.NewReader(testCast))
+	if err != nil {
+		t.Fatalf("did not expect error, got=%v", err)
+	}
+	var out bytes.Buffer
+	// play at a high speed to avoid waiting the real time of the session
+	if err := NewPlayer(events, &out, 1000).Play(context.Background(), nil); err != nil {
+		t.Fatalf("did not expect error, got=%v", err)
+	}
+	if diff := cmp.Diff("$ ls\rls\r\nfile.txt\r\n", out.String()); diff != "" {
+		t.Errorf("not equal: %v", diff)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	want := []Command{
+		CommandTogglePause, CommandForward, CommandBackward,
+		CommandForward, CommandBackward, CommandQuit, CommandQuit,
+	}
+	if diff := cmp.Diff(want, ParseKeys([]byte("p\x1b[C\x1b[Dlhq\x03"))); diff != "" {
+		t.Errorf("not equal: %v", diff)
+	}
+}
@@ -0,0 +1,245 @@
+package dlp
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type detector struct {
+	infoType string
+	re       *regexp.Regexp
+	// valid reports if a match is a finding, it's used to discard false positives
+	valid func(match []byte) bool
+}
+
+// detectors are evaluated in order, when two findings have the same
+// position the one of the first detector is redacted.
+var detectors = []*detector{
+	{
+		infoType: "CREDIT_CARD_TRACK_NUMBER",
+		re:       regexp.MustCompile(`%?B\d{13,19}\^[^^\r\n]{2,26}\^\d{7,}\??|;?\b\d{13,19}=\d{7,}\??`),
+		valid: func(match []byte) bool {
+			pan := strings.TrimLeft(string(match), "%B;")
+			if idx := strings.IndexAny(pan, "^="); idx > 0 {
+				pan = pan[:idx]
+			}
+			return luhn(pan)
+		},
+	},
+	{
+		infoType: "CREDIT_CARD_NUMBER",
+		re:       regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		valid: func(match []byte) bool {
+			digits := onlyDigits(match)
+			return len(digits) >= 13 && len(digits) <= 19 && luhn(digits)
+		},
+	},
+	{
+		infoType: "IMEI_HARDWARE_ID",
+		re:       regexp.MustCompile(`\b\d{2}[ -]?\d{6}[ -]?\d{6}[ -]?\d\b`),
+		valid:    func(match []byte) bool { return luhn(onlyDigits(match)) },
+	},
+	{
+		infoType: "US_SOCIAL_SECURITY_NUMBER",
+		re:       regexp.MustCompile(`\b\d{3}[- ]\d{2}[- ]\d{4}\b`),
+		valid: func(match []byte) bool {
+			digits := onlyDigits(match)
+			area, group, serial := digits[:3], digits[3:5], digits[5:]
+			return area != "000" && area != "666" && area[0] != '9' &&
+				group != "00" && serial != "0000"
+		},
+	},
+	{
+		infoType: "BRAZIL_CPF_NUMBER",
+		re:       regexp.MustCompile(`\b\d{3}\.?\d{3}\.?\d{3}-?\d{2}\b`),
+		valid:    func(match []byte) bool { return validCPF(onlyDigits(match)) },
+	},
+	{
+		// National Drug Code in the 4-4-2, 5-3-2 or 5-4-1 formats
+		infoType: "FDA_CODE",
+		re:       regexp.MustCompile(`\b(?:\d{4}-\d{4}-\d{2}|\d{5}-\d{3}-\d{2}|\d{5}-\d{4}-\d)\b`),
+	},
+	{
+		infoType: "IBAN_CODE",
+		re:       regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`),
+		valid:    func(match []byte) bool { return validIBAN(strings.ReplaceAll(string(match), " ", "")) },
+	},
+	{
+		infoType: "VEHICLE_IDENTIFICATION_NUMBER",
+		re:       regexp.MustCompile(`\b[A-HJ-NPR-Z0-9]{17}\b`),
+		valid:    func(match []byte) bool { return validVIN(string(match)) },
+	},
+	{
+		infoType: "AMERICAN_BANKERS_CUSIP_ID",
+		re:       regexp.MustCompile(`\b\d{3}[0-9A-Z]{5}\d\b`),
+		valid:    func(match []byte) bool { return validCUSIP(string(match)) },
+	},
+	{
+		// passport books issued since 2021 have a letter followed by 8 digits
+		infoType: "US_PASSPORT",
+		re:       regexp.MustCompile(`\b[A-Z]\d{8}\b`),
+	},
+	{
+		infoType: "EMAIL_ADDRESS",
+		re:       regexp.MustCompile(`\b[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9\-]+(?:\.[a-zA-Z0-9\-]+)*\.[a-zA-Z]{2,}\b`),
+	},
+	{
+		infoType: "IP_ADDRESS",
+		re:       regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|(?i)(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{1,4}\b`),
+		valid: func(match []byte) bool {
+			ip := net.ParseIP(string(match))
+			return ip != nil && !ip.IsUnspecified()
+		},
+	},
+	{
+		infoType: "PHONE_NUMBER",
+		re:       regexp.MustCompile(`(?:\+\d{1,3}[ .\-]?)?(?:\(\d{2,4}\)[ .\-]?|\b\d{2,4}[ .\-])\d{3,4}[ .\-]?\d{3,4}\b`),
+		valid: func(match []byte) bool {
+			digits := onlyDigits(match)
+			return len(digits) >= 10 && len(digits) <= 15
+		},
+	},
+	{
+		infoType: "HTTP_COOKIE",
+		re:       regexp.MustCompile(`(?i)\b(?:set-)?cookie:[ \t]*([^\r\n]+)`),
+	},
+	{
+		infoType: "STORAGE_SIGNED_URL",
+		re:       regexp.MustCompile(`(?i)\bhttps?://[^\s"'<>]+`),
+		valid: func(match []byte) bool {
+			for _, param := range []string{"x-goog-signature=", "x-amz-signature=", "signature=", "sig="} {
+				if strings.Contains(strings.ToLower(string(match)), param) {
+					return true
+				}
+			}
+			return false
+		},
+	},
+	{
+		infoType: "URL",
+		re:       regexp.MustCompile(`(?i)\bhttps?://[^\s"'<>]+`),
+	},
+}
+
+func onlyDigits(data []byte) string {
+	var sb strings.Builder
+	for _, c := range data {
+		if c >= '0' && c <= '9' {
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// luhn validates the check digit of credit card numbers and IMEI codes
+// https://en.wikipedia.org/wiki/Luhn_algorithm
+func luhn(digits string) bool {
+	if len(digits) < 2 {
+		return false
+	}
+	var sum int
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		if digits[i] < '0' || digits[i] > '9' {
+			return false
+		}
+		d := int(digits[i] - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// validIBAN validates the length and the mod 97 checksum
+// https://en.wikipedia.org/wiki/International_Bank_Account_Number#Validating_the_IBAN
+func validIBAN(iban string) bool {
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	rearranged := iban[4:] + iban[:4]
+	var remainder int
+	for _, c := range rearranged {
+		switch {
+		case c >= '0' && c <= '9':
+			remainder = (remainder*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			remainder = (remainder*100 + int(c-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+	return remainder == 1
+}
+
+// validCPF validates the two check digits of a brazilian CPF
+func validCPF(digits string) bool {
+	if len(digits) != 11 || strings.Count(digits, digits[:1]) == 11 {
+		return false
+	}
+	for _, size := range []int{9, 10} {
+		var sum int
+		for i := 0; i < size; i++ {
+			sum += int(digits[i]-'0') * (size + 1 - i)
+		}
+		check := (sum * 10) % 11 % 10
+		if check != int(digits[size]-'0') {
+			return false
+		}
+	}
+	return true
+}
+
+// validVIN validates the check digit (9th position) of a vehicle identification number
+// https://en.wikipedia.org/wiki/Vehicle_identification_number#Check-digit_calculation
+func validVIN(vin string) bool {
+	const transliteration = "0123456789.ABCDEFGH..JKLMN.P.R..STUVWXYZ"
+	weights := []int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+	if len(vin) != 17 || onlyDigits([]byte(vin)) == vin {
+		return false
+	}
+	var sum int
+	for i := 0; i < len(vin); i++ {
+		idx := strings.IndexByte(transliteration, vin[i])
+		if idx < 0 {
+			return false
+		}
+		sum += (idx % 10) * weights[i]
+	}
+	check := sum % 11
+	if check == 10 {
+		return vin[8] == 'X'
+	}
+	return vin[8] == strconv.Itoa(check)[0]
+}
+
+// validCUSIP validates the check digit of a CUSIP identifier
+// https://en.wikipedia.org/wiki/CUSIP#Check_digit_pseudocode
+func validCUSIP(cusip string) bool {
+	if len(cusip) != 9 {
+		return false
+	}
+	var sum int
+	for i := 0; i < 8; i++ {
+		var v int
+		switch c := cusip[i]; {
+		case c >= '0' && c <= '9':
+			v = int(c - '0')
+		case c >= 'A' && c <= 'Z':
+			v = int(c-'A') + 10
+		default:
+			return false
+		}
+		if i%2 == 1 {
+			v *= 2
+		}
+		sum += v/10 + v%10
+	}
+	return int(cusip[8]-'0') == (10-sum%10)%10
+}
@@ -0,0 +1,202 @@
+// Package dlp is an offline data masking provider. It finds the info types
+// of pb.DefaultInfoTypes with regular expressions and checksum validators
+// without relying on external services like GCP DLP or MS Presidio.
+// It also applies column masking policies to the rows of database result sets.
+package dlp
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/hoophq/hoop/common/proto/spectypes"
+)
+
+// ProviderBuiltin is the value of the DLP_PROVIDER to use this package as the data masking provider
+const ProviderBuiltin = "builtin"
+
+// Engine redacts the info types of a connection
+type Engine struct {
+	detectors []*detector
+}
+
+type finding struct {
+	start, end int
+	infoType   string
+}
+
+// NewEngine returns an engine for the supported info types, the
+// unsupported ones are ignored. It returns nil if none is supported.
+func NewEngine(infoTypes []string) *Engine {
+	enabled := map[string]bool{}
+	for _, infoType := range infoTypes {
+		enabled[infoType] = true
+	}
+	e := &Engine{}
+	for _, d := range detectors {
+		if enabled[d.infoType] {
+			e.detectors = append(e.detectors, d)
+		}
+	}
+	if len(e.detectors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// IsSupported reports if an info type could be redacted by this provider
+func IsSupported(infoType string) bool {
+	for _, d := range detectors {
+		if d.infoType == infoType {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns a copy of data replacing the findings by the name of its info type,
+// e.g.: [EMAIL_ADDRESS]. The findings are accumulated in the summary.
+func (e *Engine) Redact(data []byte, summary *Summary) []byte {
+	var findings []finding
+	for _, d := range e.detectors {
+		for _, loc := range d.re.FindAllSubmatchIndex(data, -1) {
+			start, end := loc[0], loc[1]
+			if d.valid != nil && !d.valid(data[start:end]) {
+				continue
+			}
+			// redact only the first group when the expression has one
+			if len(loc) > 2 && loc[2] >= 0 {
+				start, end = loc[2], loc[3]
+			}
+			findings = append(findings, finding{start, end, d.infoType})
+		}
+	}
+	if len(findings) == 0 {
+		return data
+	}
+	// overlapped findings are resolved by the first and longest one
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].start == findings[j].start {
+			return findings[i].end > findings[j].end
+		}
+		return findings[i].start < findings[j].start
+	})
+	redacted := make([]byte, 0, len(data))
+	pos := 0
+	for _, f := range findings {
+		if f.start < pos {
+			continue
+		}
+		redacted = append(redacted, data[pos:f.start]...)
+		redacted = append(redacted, fmt.Sprintf("[%s]", f.infoType)...)
+		summary.add(f.infoType, int64(f.end-f.start))
+		pos = f.end
+	}
+	return append(redacted, data[pos:]...)
+}
+
+// maxStreamTail is the max size of the data held by a stream redactor. The detectors
+// don't match line breaks, the end of a line longer than it is not held.
+const maxStreamTail = 256
+
+// StreamRedactor redacts data split in chunks, e.g.: the output of a command.
+// The last line of each chunk is held until the next one is received,
+// the findings split across two chunks are redacted.
+type StreamRedactor struct {
+	engine *Engine
+	tail   []byte
+}
+
+func (e *Engine) NewStreamRedactor() *StreamRedactor { return &StreamRedactor{engine: e} }
+
+// Redact returns the held data followed by the chunk redacted until its last line break,
+// the remaining data is held until the next call to Redact or Flush.
+func (s *StreamRedactor) Redact(chunk []byte, summary *Summary) []byte {
+	data := append(s.tail, chunk...)
+	end := bytes.LastIndexByte(data, '\n') + 1
+	if len(data)-end > maxStreamTail {
+		end = len(data) - maxStreamTail
+	}
+	s.tail = bytes.Clone(data[end:])
+	return s.engine.Redact(data[:end], summary)
+}
+
+// Flush returns the held data redacted
+func (s *StreamRedactor) Flush(summary *Summary) []byte {
+	data := s.engine.Redact(s.tail, summary)
+	s.tail = nil
+	return data
+}
+
+// Summary accumulates the findings of the redacted data
+type Summary struct {
+	transformedBytes int64
+	infoTypes        []string
+	counts           map[string]int64
+	fields           []fieldResult
+	errMsg           string
+}
+
+// fieldResult is the result of a column policy
+type fieldResult struct {
+	field          string
+	transformation string
+	count          int64
+}
+
+func (s *Summary) add(infoType string, size int64) {
+	if s.counts == nil {
+		s.counts = map[string]int64{}
+	}
+	if _, ok := s.counts[infoType]; !ok {
+		s.infoTypes = append(s.infoTypes, infoType)
+	}
+	s.counts[infoType]++
+	s.transformedBytes += size
+}
+
+func (s *Summary) addField(field, transformation string) {
+	for i := range s.fields {
+		if s.fields[i].field == field && s.fields[i].transformation == transformation {
+			s.fields[i].count++
+			return
+		}
+	}
+	s.fields = append(s.fields, fieldResult{field, transformation, 1})
+}
+
+// SetError records an error of the redact process
+func (s *Summary) SetError(err error) { s.errMsg = err.Error() }
+
+// IsEmpty reports if there's nothing to report
+func (s *Summary) IsEmpty() bool { return len(s.counts) == 0 && len(s.fields) == 0 && s.errMsg == "" }
+
+// DataMaskingInfo returns the summary in the same format of the other providers.
+// Errors are reported as results with the ERROR code because
+// the field Err could not be encoded.
+func (s *Summary) DataMaskingInfo() *spectypes.DataMaskingInfo {
+	overview := &spectypes.TransformationOverview{
+		TransformedBytes: s.transformedBytes,
+		Summaries:        []spectypes.TransformationSummary{},
+	}
+	for _, infoType := range s.infoTypes {
+		overview.Summaries = append(overview.Summaries, spectypes.TransformationSummary{
+			InfoType: infoType,
+			Results: []spectypes.SummaryResult{
+				{Count: s.counts[infoType], Code: "SUCCESS", Details: "redacted"},
+			},
+		})
+	}
+	for _, f := range s.fields {
+		overview.Summaries = append(overview.Summaries, spectypes.TransformationSummary{
+			Field:   f.field,
+			Results: []spectypes.SummaryResult{{Count: f.count, Code: "SUCCESS", Details: f.transformation}},
+		})
+	}
+	if s.errMsg != "" {
+		overview.Summaries = append(overview.Summaries, spectypes.TransformationSummary{
+			Results: []spectypes.SummaryResult{{Count: 1, Code: "ERROR", Details: s.errMsg}},
+		})
+	}
+	return &spectypes.DataMaskingInfo{Items: []*spectypes.TransformationOverview{overview}}
+}
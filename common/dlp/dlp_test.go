@@ -0,0 +1,110 @@
+package dlp
+
+import (
+	"fmt"
+	"testing"
+
+	pb "github.com/hoophq/hoop/common/proto"
+	"github.com/hoophq/hoop/common/proto/spectypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultInfoTypesAreSupported(t *testing.T) {
+	for _, infoType := range pb.DefaultInfoTypes {
+		assert.True(t, IsSupported(infoType), fmt.Sprintf("info type %v is not supported", infoType))
+	}
+}
+
+func TestRedact(t *testing.T) {
+	for _, tt := range []struct {
+		msg      string
+		infoType string
+		data     string
+		want     string
+	}{
+		{msg: "it should redact credit card numbers", infoType: "CREDIT_CARD_NUMBER",
+			data: "card: 4111 1111 1111 1111", want: "card: [CREDIT_CARD_NUMBER]"},
+		{msg: "it should not redact numbers with an invalid luhn check digit", infoType: "CREDIT_CARD_NUMBER",
+			data: "card: 4111 1111 1111 1112", want: "card: 4111 1111 1111 1112"},
+		{msg: "it should redact credit card track numbers", infoType: "CREDIT_CARD_TRACK_NUMBER",
+			data: ";4111111111111111=25121010000000000000?", want: "[CREDIT_CARD_TRACK_NUMBER]"},
+		{msg: "it should redact email addresses", infoType: "EMAIL_ADDRESS",
+			data: "id=1, email=john.doe@example.com", want: "id=1, email=[EMAIL_ADDRESS]"},
+		{msg: "it should redact phone numbers", infoType: "PHONE_NUMBER",
+			data: "call +1 415-555-2671 now", want: "call [PHONE_NUMBER] now"},
+		{msg: "it should not redact short phone numbers", infoType: "PHONE_NUMBER",
+			data: "ext 555-2671", want: "ext 555-2671"},
+		{msg: "it should redact iban codes", infoType: "IBAN_CODE",
+			data: "GB82 WEST 1234 5698 7654 32", want: "[IBAN_CODE]"},
+		{msg: "it should not redact iban codes with an invalid checksum", infoType: "IBAN_CODE",
+			data: "GB83WEST12345698765432", want: "GB83WEST12345698765432"},
+		{msg: "it should redact only the value of cookies", infoType: "HTTP_COOKIE",
+			data: "Cookie: session=abc123\r\nHost: localhost", want: "Cookie: [HTTP_COOKIE]\r\nHost: localhost"},
+		{msg: "it should redact imei codes", infoType: "IMEI_HARDWARE_ID",
+			data: "imei 490154203237518", want: "imei [IMEI_HARDWARE_ID]"},
+		{msg: "it should redact ipv4 and ipv6 addresses", infoType: "IP_ADDRESS",
+			data: "from 192.168.10.1 and 2001:db8::1", want: "from [IP_ADDRESS] and [IP_ADDRESS]"},
+		{msg: "it should not redact invalid ip addresses", infoType: "IP_ADDRESS",
+			data: "version 999.1.1.1 at 12:30:45", want: "version 999.1.1.1 at 12:30:45"},
+		{msg: "it should redact signed urls", infoType: "STORAGE_SIGNED_URL",
+			data: "https://bucket.s3.amazonaws.com/file?X-Amz-Signature=abc", want: "[STORAGE_SIGNED_URL]"},
+		{msg: "it should redact urls", infoType: "URL",
+			data: "see https://hoop.dev/docs", want: "see [URL]"},
+		{msg: "it should redact vehicle identification numbers", infoType: "VEHICLE_IDENTIFICATION_NUMBER",
+			data: "vin: 1M8GDM9AXKP042788", want: "vin: [VEHICLE_IDENTIFICATION_NUMBER]"},
+		{msg: "it should redact brazilian cpf numbers", infoType: "BRAZIL_CPF_NUMBER",
+			data: "cpf 529.982.247-25", want: "cpf [BRAZIL_CPF_NUMBER]"},
+		{msg: "it should not redact cpf numbers with invalid check digits", infoType: "BRAZIL_CPF_NUMBER",
+			data: "cpf 111.111.111-11", want: "cpf 111.111.111-11"},
+		{msg: "it should redact cusip identifiers", infoType: "AMERICAN_BANKERS_CUSIP_ID",
+			data: "cusip 037833100", want: "cusip [AMERICAN_BANKERS_CUSIP_ID]"},
+		{msg: "it should redact national drug codes", infoType: "FDA_CODE",
+			data: "ndc 0777-3105-02", want: "ndc [FDA_CODE]"},
+		{msg: "it should redact us passports", infoType: "US_PASSPORT",
+			data: "passport A12345678", want: "passport [US_PASSPORT]"},
+		{msg: "it should redact social security numbers", infoType: "US_SOCIAL_SECURITY_NUMBER",
+			data: "ssn 123-45-6789", want: "ssn [US_SOCIAL_SECURITY_NUMBER]"},
+		{msg: "it should not redact social security numbers of invalid areas", infoType: "US_SOCIAL_SECURITY_NUMBER",
+			data: "ssn 666-45-6789 or 900-45-6789", want: "ssn 666-45-6789 or 900-45-6789"},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got := NewEngine([]string{tt.infoType}).Redact([]byte(tt.data), &Summary{})
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestRedactOverlappedFindings(t *testing.T) {
+	engine := NewEngine(pb.DefaultInfoTypes)
+	summary := &Summary{}
+	got := engine.Redact([]byte("john@example.com paid with 4111-1111-1111-1111 from 10.0.0.1"), summary)
+	assert.Equal(t, "[EMAIL_ADDRESS] paid with [CREDIT_CARD_NUMBER] from [IP_ADDRESS]", string(got))
+	assert.Equal(t, &spectypes.DataMaskingInfo{Items: []*spectypes.TransformationOverview{{
+		TransformedBytes: 43,
+		Summaries: []spectypes.TransformationSummary{
+			{InfoType: "EMAIL_ADDRESS", Results: []spectypes.SummaryResult{{Count: 1, Code: "SUCCESS", Details: "redacted"}}},
+			{InfoType: "CREDIT_CARD_NUMBER", Results: []spectypes.SummaryResult{{Count: 1, Code: "SUCCESS", Details: "redacted"}}},
+			{InfoType: "IP_ADDRESS", Results: []spectypes.SummaryResult{{Count: 1, Code: "SUCCESS", Details: "redacted"}}},
+		},
+	}}}, summary.DataMaskingInfo())
+}
+
+func TestStreamRedactor(t *testing.T) {
+	s := NewEngine([]string{"EMAIL_ADDRESS", "CREDIT_CARD_NUMBER"}).NewStreamRedactor()
+	summary := &Summary{}
+	var got []byte
+	for _, chunk := range []string{"card: 4111 1111", " 1111 1111\nemail: john@exa", "mple.com\nbye"} {
+		got = append(got, s.Redact([]byte(chunk), summary)...)
+	}
+	// it should hold the last line of the chunks
+	assert.Equal(t, "card: [CREDIT_CARD_NUMBER]\nemail: [EMAIL_ADDRESS]\n", string(got))
+	got = append(got, s.Flush(summary)...)
+	assert.Equal(t, "card: [CREDIT_CARD_NUMBER]\nemail: [EMAIL_ADDRESS]\nbye", string(got))
+	assert.Equal(t, map[string]int64{"CREDIT_CARD_NUMBER": 1, "EMAIL_ADDRESS": 1}, summary.counts)
+	assert.Empty(t, s.Flush(summary))
+}
+
+func TestNewEngineWithUnsupportedInfoTypes(t *testing.T) {
+	assert.Nil(t, NewEngine([]string{"PERSON_NAME"}))
+	assert.NotNil(t, NewEngine([]string{"PERSON_NAME", "EMAIL_ADDRESS"}))
+}
@@ -0,0 +1,217 @@
+package dlp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Column masking policies are configured alongside the info types of a connection,
+// a wildcard (*) matches any table or column. Examples:
+//
+//	users.email -> hash - replaces the values by its hmac-sha256 (hex encoded) keyed by the connection
+//	payments.card_number -> last4 - masks all characters except the last 4 ones
+//	*.ssn -> redact - replaces the values of the column ssn of any table by [REDACTED]
+const (
+	TransformationHash   = "hash"
+	TransformationLast4  = "last4"
+	TransformationRedact = "redact"
+
+	policySeparator = "->"
+	redactedValue   = "[REDACTED]"
+)
+
+// ColumnPolicy is a transformation applied to the values of a column
+type ColumnPolicy struct {
+	Table          string
+	Column         string
+	Transformation string
+}
+
+// IsColumnPolicy reports if an entry of the connection configuration is a column policy
+func IsColumnPolicy(entry string) bool { return strings.Contains(entry, policySeparator) }
+
+// ParseColumnPolicy parses a policy in the format: <table>.<column> -> <transformation>
+func ParseColumnPolicy(entry string) (*ColumnPolicy, error) {
+	target, transformation, found := strings.Cut(entry, policySeparator)
+	if !found {
+		return nil, fmt.Errorf("invalid column policy %q, expected <table>.<column> -> <transformation>", entry)
+	}
+	table, column, found := strings.Cut(strings.TrimSpace(target), ".")
+	if !found || table == "" || column == "" || strings.Contains(column, ".") {
+		return nil, fmt.Errorf("invalid column policy %q, expected <table>.<column> -> <transformation>", entry)
+	}
+	policy := &ColumnPolicy{Table: table, Column: column, Transformation: strings.TrimSpace(transformation)}
+	switch policy.Transformation {
+	case TransformationHash, TransformationLast4, TransformationRedact:
+	default:
+		return nil, fmt.Errorf("invalid column policy %q, unknown transformation %q", entry, policy.Transformation)
+	}
+	return policy, nil
+}
+
+// ParseColumnPolicies returns the column policies of the connection configuration,
+// the other entries are ignored.
+func ParseColumnPolicies(config []string) ([]*ColumnPolicy, error) {
+	var policies []*ColumnPolicy
+	for _, entry := range config {
+		if !IsColumnPolicy(entry) {
+			continue
+		}
+		policy, err := ParseColumnPolicy(entry)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+func (p *ColumnPolicy) String() string {
+	return fmt.Sprintf("%s.%s %s %s", p.Table, p.Column, policySeparator, p.Transformation)
+}
+
+// match reports if the policy applies to a column, an empty table means
+// the table is unknown and only the name of the column is compared.
+func (p *ColumnPolicy) match(table, column string) bool {
+	if p.Column != "*" && !strings.EqualFold(p.Column, column) {
+		return false
+	}
+	return table == "" || p.Table == "*" || strings.EqualFold(p.Table, table)
+}
+
+// NewHashKey derives the key of the hash transformation of a connection from the secret of the gateway.
+// The values are hashed with a key per connection, thus the hashes of a connection can't be
+// compared with the ones of other connections or brute forced without knowing the secret.
+func NewHashKey(secret []byte, orgID, connectionID string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(orgID + ":" + connectionID))
+	return mac.Sum(nil)
+}
+
+// Apply returns the transformed value, the hash key is only used by the hash transformation
+func (p *ColumnPolicy) Apply(val, hashKey []byte) []byte {
+	switch p.Transformation {
+	case TransformationHash:
+		mac := hmac.New(sha256.New, hashKey)
+		mac.Write(val)
+		return []byte(hex.EncodeToString(mac.Sum(nil)))
+	case TransformationLast4:
+		size := utf8.RuneCount(val)
+		if size <= 4 {
+			return []byte(strings.Repeat("*", size))
+		}
+		masked := []byte(strings.Repeat("*", size-4))
+		for i := 0; i < size-4; i++ {
+			_, n := utf8.DecodeRune(val)
+			val = val[n:]
+		}
+		return append(masked, val...)
+	}
+	return []byte(redactedValue)
+}
+
+// TableResolver returns the name of a postgres table and the names of its columns by attribute number
+type TableResolver func(tableOID uint32) (table string, columns map[int16]string, err error)
+
+type resolvedTable struct {
+	name    string
+	columns map[int16]string
+}
+
+// postgres types which the binary format is the same of the text format
+var pgTextTypes = map[uint32]bool{
+	18:   true, // char
+	19:   true, // name
+	25:   true, // text
+	1042: true, // bpchar
+	1043: true, // varchar
+}
+
+// ColumnMasker applies column policies to the rows of result sets, it implements RowTransformer.
+// When the table of a column is unknown, e.g.: the resolver failed or it's an expression,
+// the policies are matched by the name of the column only.
+type ColumnMasker struct {
+	policies []*ColumnPolicy
+	hashKey  []byte
+	resolver TableResolver
+	tables   map[uint32]*resolvedTable
+	// the policy and the binary format of each column of the current result set
+	columns []*maskedColumn
+}
+
+type maskedColumn struct {
+	policy *ColumnPolicy
+	field  string
+	// nullify is true for postgres binary values that could not be represented as text
+	nullify bool
+}
+
+// NewColumnMasker returns a masker of the policies, the resolver is only used by postgres connections.
+// The hash key is the key of the connection returned by NewHashKey.
+func NewColumnMasker(policies []*ColumnPolicy, hashKey []byte, resolver TableResolver) *ColumnMasker {
+	return &ColumnMasker{policies: policies, hashKey: hashKey, resolver: resolver, tables: map[uint32]*resolvedTable{}}
+}
+
+func (m *ColumnMasker) OnColumns(columns []Column) {
+	m.columns = make([]*maskedColumn, len(columns))
+	for i, col := range columns {
+		table, name := col.Table, col.Name
+		if col.OrgName != "" {
+			name = col.OrgName
+		}
+		if col.TableOID > 0 {
+			if t := m.resolveTable(col.TableOID); t != nil {
+				table = t.name
+				if orgName, ok := t.columns[col.ColumnAttr]; ok {
+					name = orgName
+				}
+			}
+		}
+		for _, p := range m.policies {
+			if !p.match(table, name) {
+				continue
+			}
+			field := name
+			if table != "" {
+				field = table + "." + name
+			}
+			m.columns[i] = &maskedColumn{policy: p, field: field, nullify: col.Binary && !pgTextTypes[col.TypeOID]}
+			break
+		}
+	}
+}
+
+func (m *ColumnMasker) TransformRow(values [][]byte, summary *Summary) {
+	for i, val := range values {
+		if i >= len(m.columns) || m.columns[i] == nil || val == nil {
+			continue
+		}
+		col := m.columns[i]
+		if col.nullify {
+			values[i] = nil
+			summary.addField(col.field, "null")
+			continue
+		}
+		values[i] = col.policy.Apply(val, m.hashKey)
+		summary.addField(col.field, col.policy.Transformation)
+	}
+}
+
+func (m *ColumnMasker) resolveTable(oid uint32) *resolvedTable {
+	if t, ok := m.tables[oid]; ok {
+		return t
+	}
+	var t *resolvedTable
+	if m.resolver != nil {
+		if name, columns, err := m.resolver(oid); err == nil {
+			t = &resolvedTable{name: name, columns: columns}
+		}
+	}
+	// failures are cached as well to avoid resolving the table for each result set
+	m.tables[oid] = t
+	return t
+}
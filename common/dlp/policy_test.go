@@ -0,0 +1,184 @@
+package dlp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/hoophq/hoop/common/mysqltypes"
+	"github.com/hoophq/hoop/common/pgtypes"
+	pb "github.com/hoophq/hoop/common/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseColumnPolicies(t *testing.T) {
+	for _, tt := range []struct {
+		msg     string
+		config  []string
+		want    []*ColumnPolicy
+		wantErr string
+	}{
+		{msg: "it should ignore info types", config: []string{"EMAIL_ADDRESS", "PHONE_NUMBER"}},
+		{msg: "it should parse policies", config: []string{"EMAIL_ADDRESS", "users.email -> hash", "*.ssn->redact"},
+			want: []*ColumnPolicy{
+				{Table: "users", Column: "email", Transformation: TransformationHash},
+				{Table: "*", Column: "ssn", Transformation: TransformationRedact}}},
+		{msg: "it should fail with unknown transformations", config: []string{"users.email -> encrypt"},
+			wantErr: `invalid column policy "users.email -> encrypt", unknown transformation "encrypt"`},
+		{msg: "it should fail without the table", config: []string{"email -> hash"},
+			wantErr: `invalid column policy "email -> hash", expected <table>.<column> -> <transformation>`},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := ParseColumnPolicies(tt.config)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestColumnPolicyApply(t *testing.T) {
+	hashKey := NewHashKey([]byte("secret"), "org", "conn")
+	for _, tt := range []struct {
+		msg            string
+		transformation string
+		val            string
+		want           string
+	}{
+		{msg: "it should hash the value with the key of the connection", transformation: TransformationHash, val: "john@example.com",
+			want: "d829f4f789e30351abcef1146fd9651be527a7dc2c8177872d9db064e1cb09aa"},
+		{msg: "it should keep the last 4 characters", transformation: TransformationLast4,
+			val: "4111111111111111", want: "************1111"},
+		{msg: "it should mask short values", transformation: TransformationLast4, val: "123", want: "***"},
+		{msg: "it should redact the value", transformation: TransformationRedact, val: "123-45-6789", want: "[REDACTED]"},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			p := &ColumnPolicy{Table: "*", Column: "*", Transformation: tt.transformation}
+			assert.Equal(t, tt.want, string(p.Apply([]byte(tt.val), hashKey)))
+		})
+	}
+}
+
+func TestNewHashKey(t *testing.T) {
+	hashKey := NewHashKey([]byte("secret"), "org", "conn")
+	assert.Equal(t, "6cac40ec12d80d86377004870f60c852b59cf7c4649dea54a21fc34f5803c95a", fmt.Sprintf("%x", hashKey))
+	assert.NotEqual(t, hashKey, NewHashKey([]byte("secret"), "org", "other-conn"))
+	assert.NotEqual(t, hashKey, NewHashKey([]byte("secret"), "other-org", "conn"))
+	assert.NotEqual(t, hashKey, NewHashKey([]byte("other-secret"), "org", "conn"))
+}
+
+type pgColumn struct {
+	name     string
+	tableOID uint32
+	attnum   int16
+}
+
+func newPGRows(columns []pgColumn, rows ...[][]byte) []byte {
+	rowDesc := binary.BigEndian.AppendUint16(nil, uint16(len(columns)))
+	for _, col := range columns {
+		rowDesc = append(rowDesc, append([]byte(col.name), 0x00)...)
+		rowDesc = binary.BigEndian.AppendUint32(rowDesc, col.tableOID)
+		rowDesc = binary.BigEndian.AppendUint16(rowDesc, uint16(col.attnum))
+		// type oid (text), size, modifier and format
+		rowDesc = binary.BigEndian.AppendUint32(rowDesc, 25)
+		rowDesc = append(rowDesc, make([]byte, 8)...)
+	}
+	data := pgtypes.NewPacket(pgtypes.ServerRowDescription, rowDesc).Encode()
+	for _, row := range rows {
+		data = append(data, pgtypes.NewPacket(pgtypes.ServerDataRow, pgtypes.EncodeDataRow(row)).Encode()...)
+	}
+	return append(data, pgtypes.NewReadyForQuery(pgtypes.ServerIdle).Encode()...)
+}
+
+func newMySQLRows(table string, columns []string, rows ...[][]byte) []byte {
+	lenencStr := func(v string) []byte { return append([]byte{byte(len(v))}, v...) }
+	seq := uint8(1)
+	var data []byte
+	appendPacket := func(frame []byte) {
+		data = append(data, mysqltypes.NewPacket(seq, frame).Encode()...)
+		seq++
+	}
+	eof := []byte{0xfe, 0x00, 0x00, 0x02, 0x00}
+	appendPacket([]byte{byte(len(columns))})
+	for _, name := range columns {
+		var colDef []byte
+		for _, v := range []string{"def", "testdb", table, table, name, name} {
+			colDef = append(colDef, lenencStr(v)...)
+		}
+		appendPacket(colDef)
+	}
+	appendPacket(eof)
+	for _, row := range rows {
+		appendPacket(mysqltypes.EncodeTextRow(row))
+	}
+	appendPacket(eof)
+	return data
+}
+
+func TestColumnMasker(t *testing.T) {
+	policies, _ := ParseColumnPolicies([]string{"users.email -> hash", "payments.card_number -> last4", "*.ssn -> redact"})
+	resolver := func(oid uint32) (string, map[int16]string, error) {
+		switch oid {
+		case 1000:
+			return "users", map[int16]string{1: "id", 2: "email", 3: "ssn"}, nil
+		case 2000:
+			return "payments", map[int16]string{1: "card_number"}, nil
+		}
+		return "", nil, fmt.Errorf("not found")
+	}
+	hashKey := NewHashKey([]byte("secret"), "org", "conn")
+	emailHash := []byte("d829f4f789e30351abcef1146fd9651be527a7dc2c8177872d9db064e1cb09aa")
+	for _, tt := range []struct {
+		msg        string
+		connType   pb.ConnectionType
+		query      []byte
+		serverData []byte
+		want       []byte
+	}{
+		{
+			msg:      "it should apply the policies to postgres rows",
+			connType: pb.ConnectionTypePostgres,
+			query:    pgtypes.NewPacket(pgtypes.ClientSimpleQuery, []byte("SELECT\x00")).Encode(),
+			serverData: newPGRows([]pgColumn{{"id", 1000, 1}, {"email", 1000, 2}, {"ssn", 1000, 3}},
+				[][]byte{[]byte("1"), []byte("john@example.com"), []byte("123-45-6789")},
+				[][]byte{[]byte("2"), nil, nil}),
+			want: newPGRows([]pgColumn{{"id", 1000, 1}, {"email", 1000, 2}, {"ssn", 1000, 3}},
+				[][]byte{[]byte("1"), emailHash, []byte("[REDACTED]")},
+				[][]byte{[]byte("2"), nil, nil}),
+		},
+		{
+			msg:      "it should apply the policies to aliased postgres columns",
+			connType: pb.ConnectionTypePostgres,
+			query:    pgtypes.NewPacket(pgtypes.ClientSimpleQuery, []byte("SELECT\x00")).Encode(),
+			serverData: newPGRows([]pgColumn{{"card", 2000, 1}, {"email", 3000, 1}},
+				[][]byte{[]byte("4111111111111111"), []byte("john@example.com")}),
+			want: newPGRows([]pgColumn{{"card", 2000, 1}, {"email", 3000, 1}},
+				[][]byte{[]byte("************1111"), emailHash}),
+		},
+		{
+			msg:      "it should apply the policies to mysql rows",
+			connType: pb.ConnectionTypeMySQL,
+			query:    mysqltypes.NewPacket(0, append([]byte{mysqltypes.ComQuery.Byte()}, "SELECT"...)).Encode(),
+			serverData: newMySQLRows("payments", []string{"id", "card_number", "email"},
+				[][]byte{[]byte("1"), []byte("4111111111111111"), []byte("john@example.com")}),
+			want: newMySQLRows("payments", []string{"id", "card_number", "email"},
+				[][]byte{[]byte("1"), []byte("************1111"), []byte("john@example.com")}),
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			rows := NewRowRewriter(tt.connType, NewColumnMasker(policies, hashKey, resolver))
+			rows.OnClientPacket(tt.query)
+			// the packets could be split at any position
+			var got []byte
+			for i := 0; i < len(tt.serverData); i += 7 {
+				payload, err := rows.Rewrite(tt.serverData[i:min(i+7, len(tt.serverData))], &Summary{})
+				assert.NoError(t, err)
+				got = append(got, payload...)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package dlp
+
+import (
+	"fmt"
+
+	"github.com/hoophq/hoop/common/mysqltypes"
+	"github.com/hoophq/hoop/common/pgtypes"
+	pb "github.com/hoophq/hoop/common/proto"
+)
+
+// maxBufferSize is the max size of a protocol message waiting to be rewritten
+const maxBufferSize = 1 << 24
+
+// Column is a column of a result set
+type Column struct {
+	// Table is the original name of the table, it's empty for postgres
+	// columns because only the oid of the table is informed.
+	Table string
+	// Name is the name of the column in the result set
+	Name string
+	// OrgName is the original name of an aliased column
+	OrgName string
+	// TableOID, ColumnAttr and TypeOID are only set for postgres columns
+	TableOID   uint32
+	ColumnAttr int16
+	TypeOID    uint32
+	// Binary is true when the values of a postgres column are in the binary format
+	Binary bool
+}
+
+// RowTransformer modifies the values of the rows of a result set
+type RowTransformer interface {
+	// OnColumns is called when a new result set starts
+	OnColumns(columns []Column)
+	// TransformRow modifies the values of a row in place, null values are nil
+	TransformRow(values [][]byte, summary *Summary)
+}
+
+// RowRewriter decodes the result sets sent by a database to a client connection and
+// rewrites its rows. The packets are buffered until a protocol message is complete,
+// because the size of the transformed values differs from the original ones.
+type RowRewriter struct {
+	connType    pb.ConnectionType
+	transformer RowTransformer
+	// started is true after the first query of the client,
+	// the server stream is aligned with the protocol messages from that point.
+	started bool
+	buf     []byte
+
+	mysqlResultSet mysqltypes.TextResultSet
+}
+
+// NewRowRewriter returns a rewriter for postgres or mysql connections
+func NewRowRewriter(connType pb.ConnectionType, transformer RowTransformer) *RowRewriter {
+	return &RowRewriter{connType: connType, transformer: transformer}
+}
+
+// OnClientPacket inspects the packets sent by the client to the database,
+// the rows are rewritten after the first query.
+func (r *RowRewriter) OnClientPacket(payload []byte) {
+	if len(payload) == 0 {
+		return
+	}
+	switch r.connType {
+	case pb.ConnectionTypePostgres:
+		switch pgtypes.PacketType(payload[0]) {
+		case pgtypes.ClientSimpleQuery, pgtypes.ClientParse:
+			r.started = true
+		}
+	case pb.ConnectionTypeMySQL:
+		if r.mysqlResultSet.OnClientPacket(payload) {
+			r.started = true
+		}
+	}
+}
+
+// Rewrite returns the complete protocol messages of the payload with the rows transformed,
+// incomplete messages are returned in the next calls. In case of errors, the data that
+// could not be decoded is kept in the buffer, see Flush.
+func (r *RowRewriter) Rewrite(payload []byte, summary *Summary) ([]byte, error) {
+	if !r.started {
+		return payload, nil
+	}
+	r.buf = append(r.buf, payload...)
+	var out []byte
+	var err error
+	switch r.connType {
+	case pb.ConnectionTypePostgres:
+		out, err = r.rewritePG(summary)
+	case pb.ConnectionTypeMySQL:
+		out, err = r.rewriteMySQL(summary)
+	default:
+		return nil, fmt.Errorf("connection type %v is not supported", r.connType)
+	}
+	if err == nil && len(r.buf) > maxBufferSize {
+		err = fmt.Errorf("reached max buffer size (%v)", maxBufferSize)
+	}
+	return out, err
+}
+
+// Flush returns and clears the data waiting to be rewritten
+func (r *RowRewriter) Flush() []byte {
+	data := r.buf
+	r.buf = nil
+	return data
+}
+
+// https://www.postgresql.org/docs/current/protocol-message-formats.html#PROTOCOL-MESSAGE-FORMATS-DATAROW
+func (r *RowRewriter) rewritePG(summary *Summary) ([]byte, error) {
+	var out []byte
+	for len(r.buf) >= 5 {
+		// type(1), length(4) - the length includes itself
+		size := int(uint32(r.buf[1])<<24|uint32(r.buf[2])<<16|uint32(r.buf[3])<<8|uint32(r.buf[4])) + 1
+		if size < 5 {
+			return out, fmt.Errorf("invalid message length (%v)", size)
+		}
+		if len(r.buf) < size {
+			break
+		}
+		msg := r.buf[:size]
+		switch pgtypes.PacketType(msg[0]) {
+		case pgtypes.ServerRowDescription:
+			fields, err := pgtypes.DecodeRowDescriptionFields(msg[5:])
+			if err != nil {
+				return out, err
+			}
+			columns := make([]Column, len(fields))
+			for i, f := range fields {
+				columns[i] = Column{Name: f.Name, TableOID: f.TableOID, ColumnAttr: f.ColumnAttr,
+					TypeOID: f.TypeOID, Binary: f.Format == pgtypes.FormatBinary}
+			}
+			r.transformer.OnColumns(columns)
+		case pgtypes.ServerDataRow:
+			values, err := pgtypes.DecodeDataRow(msg[5:])
+			if err != nil {
+				return out, err
+			}
+			r.transformer.TransformRow(values, summary)
+			msg = pgtypes.NewPacket(pgtypes.ServerDataRow, pgtypes.EncodeDataRow(values)).Encode()
+		}
+		r.buf = r.buf[size:]
+		out = append(out, msg...)
+	}
+	return out, nil
+}
+
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_query_response_text_resultset_row.html
+func (r *RowRewriter) rewriteMySQL(summary *Summary) ([]byte, error) {
+	var out []byte
+	for len(r.buf) >= 4 {
+		size := int(uint32(r.buf[0])|uint32(r.buf[1])<<8|uint32(r.buf[2])<<16) + 4
+		if len(r.buf) < size {
+			break
+		}
+		msg := r.buf[:size]
+		kind, err := r.mysqlResultSet.Next(msg[4:])
+		if err != nil {
+			return out, err
+		}
+		switch kind {
+		case mysqltypes.ResultPacketColumns:
+			var columns []Column
+			for _, def := range r.mysqlResultSet.ColumnDefinitions() {
+				columns = append(columns, Column{Table: def.OrgTable, Name: def.Name, OrgName: def.OrgName})
+			}
+			r.transformer.OnColumns(columns)
+		case mysqltypes.ResultPacketRow:
+			values, err := mysqltypes.DecodeTextRow(msg[4:], len(r.mysqlResultSet.Columns()))
+			if err != nil {
+				return out, err
+			}
+			r.transformer.TransformRow(values, summary)
+			frame := mysqltypes.EncodeTextRow(values)
+			if len(frame) >= 0xffffff {
+				return out, fmt.Errorf("transformed row exceeds the max packet size")
+			}
+			msg = mysqltypes.NewPacket(msg[3], frame).Encode()
+		}
+		r.buf = r.buf[size:]
+		out = append(out, msg...)
+	}
+	return out, nil
+}
@@ -0,0 +1,100 @@
+// Package httptypes implements the encoding of the HTTP messages exchanged between the client
+// and the agent. Each request and response is sent in a single packet with its whole body,
+// it allows decoding the messages in the gateway without keeping the state of the connections.
+package httptypes
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// MaxBodySize is the maximum size of the body of a request or response
+const MaxBodySize = 8 << 20
+
+var ErrBodyTooLarge = fmt.Errorf("http body exceeds the maximum size of %d bytes", MaxBodySize)
+
+// ReadRequest reads the next request of the client and encodes it with its whole body
+func ReadRequest(r *bufio.Reader) ([]byte, error) {
+	req, err := http.ReadRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeRequest(req)
+}
+
+// EncodeRequest returns the request in the wire format, the body
+// is always sent with its length instead of the chunked encoding.
+func EncodeRequest(req *http.Request) ([]byte, error) {
+	body, err := readBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.TransferEncoding = nil
+	// an empty value prevents writing the default user agent of the go client
+	if _, ok := req.Header["User-Agent"]; !ok {
+		req.Header.Set("User-Agent", "")
+	}
+	var buf bytes.Buffer
+	if err := req.Write(&buf); err != nil {
+		return nil, fmt.Errorf("failed encoding request: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeRequest parses a request encoded with EncodeRequest
+func DecodeRequest(data []byte) (*http.Request, error) {
+	return http.ReadRequest(bufio.NewReader(bytes.NewReader(data)))
+}
+
+// EncodeResponse returns the response in the wire format, the body is
+// always sent with its length instead of the chunked encoding.
+func EncodeResponse(resp *http.Response) ([]byte, error) {
+	body, err := readBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	// responses of HEAD requests keep the length of the resource
+	if resp.Request == nil || resp.Request.Method != http.MethodHead {
+		resp.ContentLength = int64(len(body))
+	}
+	resp.TransferEncoding = nil
+	var buf bytes.Buffer
+	if err := resp.Write(&buf); err != nil {
+		return nil, fmt.Errorf("failed encoding response: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeResponse parses a response encoded with EncodeResponse. The body of responses
+// of HEAD requests is empty, reading it returns io.ErrUnexpectedEOF in this case.
+func DecodeResponse(data []byte) (*http.Response, error) {
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil)
+}
+
+// NewErrorResponse returns a response with the status code and a text message as the body
+func NewErrorResponse(statusCode int, msg string) []byte {
+	return []byte(fmt.Sprintf("HTTP/1.1 %d %s\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Length: %d\r\n\r\n%s",
+		statusCode, http.StatusText(statusCode), len(msg), msg))
+}
+
+func readBody(body io.ReadCloser) ([]byte, error) {
+	if body == nil || body == http.NoBody {
+		return nil, nil
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, MaxBodySize+1))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed reading body: %v", err)
+	}
+	if len(data) > MaxBodySize {
+		return nil, ErrBodyTooLarge
+	}
+	return data, nil
+}
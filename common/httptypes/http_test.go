@@ -0,0 +1,118 @@
+package httptypes
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReadRequest(t *testing.T) {
+	for _, tt := range []struct {
+		msg      string
+		data     string
+		wantBody string
+		wantErr  error
+	}{
+		{
+			msg:  "it should read a request without body",
+			data: "GET /v1/users?limit=10 HTTP/1.1\r\nHost: localhost:8080\r\nUser-Agent: curl/8.0\r\n\r\n",
+		},
+		{
+			msg:      "it should read a request with content length",
+			data:     "POST /v1/users HTTP/1.1\r\nHost: localhost\r\nContent-Length: 13\r\n\r\n{\"name\":\"jo\"}",
+			wantBody: `{"name":"jo"}`,
+		},
+		{
+			msg:      "it should read a chunked request",
+			data:     "POST /v1/users HTTP/1.1\r\nHost: localhost\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n6\r\n world\r\n0\r\n\r\n",
+			wantBody: "hello world",
+		},
+		{
+			msg:     "it should fail when the body is too large",
+			data:    "POST /upload HTTP/1.1\r\nHost: localhost\r\nContent-Length: 8388609\r\n\r\n" + strings.Repeat("a", MaxBodySize+1),
+			wantErr: ErrBodyTooLarge,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			data, err := ReadRequest(bufio.NewReader(strings.NewReader(tt.data)))
+			if err != tt.wantErr {
+				t.Fatalf("want error=%v, got=%v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if bytes.Contains(data, []byte("Go-http-client")) {
+				t.Errorf("it should not add the default user agent, got=%q", data)
+			}
+			req, err := DecodeRequest(data)
+			if err != nil {
+				t.Fatalf("did not expect error decoding request, got=%v", err)
+			}
+			body, _ := io.ReadAll(req.Body)
+			if string(body) != tt.wantBody {
+				t.Errorf("want body=%q, got=%q", tt.wantBody, body)
+			}
+			if len(req.TransferEncoding) > 0 {
+				t.Errorf("expected the body to be sent with its length, got=%v", req.TransferEncoding)
+			}
+		})
+	}
+}
+
+func TestEncodeResponse(t *testing.T) {
+	for _, tt := range []struct {
+		msg      string
+		data     string
+		method   string
+		wantBody string
+	}{
+		{
+			msg:      "it should encode a chunked response with its length",
+			data:     "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n2\r\n{}\r\n0\r\n\r\n",
+			method:   http.MethodGet,
+			wantBody: "{}",
+		},
+		{
+			msg:    "it should not encode a body for head requests",
+			data:   "HTTP/1.1 200 OK\r\nContent-Length: 100\r\n\r\n",
+			method: http.MethodHead,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, "http://localhost", nil)
+			resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(tt.data)), req)
+			if err != nil {
+				t.Fatalf("did not expect error reading response, got=%v", err)
+			}
+			data, err := EncodeResponse(resp)
+			if err != nil {
+				t.Fatalf("did not expect error, got=%v", err)
+			}
+			got, err := DecodeResponse(data)
+			if err != nil {
+				t.Fatalf("did not expect error decoding response, got=%v", err)
+			}
+			if got.StatusCode != http.StatusOK {
+				t.Errorf("want status=200, got=%v", got.StatusCode)
+			}
+			body, _ := io.ReadAll(got.Body)
+			if string(body) != tt.wantBody {
+				t.Errorf("want body=%q, got=%q", tt.wantBody, body)
+			}
+		})
+	}
+}
+
+func TestNewErrorResponse(t *testing.T) {
+	resp, err := DecodeResponse(NewErrorResponse(http.StatusBadGateway, "connection refused"))
+	if err != nil {
+		t.Fatalf("did not expect error, got=%v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusBadGateway || string(body) != "connection refused" {
+		t.Errorf("response does not match, status=%v, body=%q", resp.StatusCode, body)
+	}
+}
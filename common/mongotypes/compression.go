@@ -0,0 +1,99 @@
+package mongotypes
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// maxUncompressedSize is the max size of a message, it prevents decompressing large payloads.
+// https://www.mongodb.com/docs/manual/reference/command/hello/#mongodb-data-hello.maxMessageSizeBytes
+const maxUncompressedSize = 48000000
+
+// zstdDecoder is safe to be used concurrently when decoding with DecodeAll
+var zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+	return zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxUncompressedSize))
+})
+
+// Decompress returns the original message of an OP_COMPRESSED packet,
+// other opcodes are returned as they are.
+// https://www.mongodb.com/docs/manual/reference/mongodb-wire-protocol/#op_compressed
+func Decompress(pkt *Packet) (*Packet, error) {
+	if pkt.OpCode != OpCompressed {
+		return pkt, nil
+	}
+	// original opcode (4), uncompressed size (4) and compressor id (1)
+	if len(pkt.Frame) < 9 {
+		return nil, fmt.Errorf("invalid OP_COMPRESSED message size (%v)", len(pkt.Frame))
+	}
+	originalOpCode := binary.LittleEndian.Uint32(pkt.Frame[0:4])
+	uncompressedSize := binary.LittleEndian.Uint32(pkt.Frame[4:8])
+	compressorID := pkt.Frame[8]
+	if uncompressedSize > maxUncompressedSize {
+		return nil, fmt.Errorf("OP_COMPRESSED uncompressed size (%v) exceeds the max size (%v)",
+			uncompressedSize, maxUncompressedSize)
+	}
+	frame, err := decompress(compressorID, pkt.Frame[9:], int(uncompressedSize))
+	if err != nil {
+		return nil, err
+	}
+	if len(frame) != int(uncompressedSize) {
+		return nil, fmt.Errorf("OP_COMPRESSED uncompressed size mismatch, expected=%v, got=%v",
+			uncompressedSize, len(frame))
+	}
+	return &Packet{
+		MessageLength: uint32(len(frame) + 16),
+		RequestID:     pkt.RequestID,
+		ResponseTo:    pkt.ResponseTo,
+		OpCode:        originalOpCode,
+		Frame:         frame,
+	}, nil
+}
+
+func decompress(compressorID uint8, data []byte, size int) ([]byte, error) {
+	switch compressorID {
+	case CompressorNoop:
+		return data, nil
+	case CompressorSnappy:
+		decodedLen, err := snappy.DecodedLen(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed decoding snappy length: %v", err)
+		}
+		if decodedLen != size {
+			return nil, fmt.Errorf("snappy decoded length (%v) mismatch the uncompressed size (%v)", decodedLen, size)
+		}
+		out, err := snappy.Decode(nil, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed decompressing snappy message: %v", err)
+		}
+		return out, nil
+	case CompressorZlib:
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed decompressing zlib message: %v", err)
+		}
+		defer r.Close()
+		out, err := io.ReadAll(io.LimitReader(r, int64(size)+1))
+		if err != nil {
+			return nil, fmt.Errorf("failed decompressing zlib message: %v", err)
+		}
+		return out, nil
+	case CompressorZstd:
+		decoder, err := zstdDecoder()
+		if err != nil {
+			return nil, fmt.Errorf("failed creating zstd decoder: %v", err)
+		}
+		out, err := decoder.DecodeAll(data, make([]byte, 0, size))
+		if err != nil {
+			return nil, fmt.Errorf("failed decompressing zstd message: %v", err)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unknown compressor id %v", compressorID)
+}
@@ -13,4 +13,31 @@ const (
 	// Reply to a client request. responseTo is set.
 	// Deprecated in MongoDB 5.0. Removed in MongoDB 5.1.
 	OpReplyType uint32 = 1
+
+	// the query failed, the reply contains a single document describing the error
+	opReplyQueryFailureFlag uint32 = 1 << 1
+	// the message ends with a crc-32c checksum
+	opMsgChecksumPresentFlag uint32 = 1 << 0
+)
+
+// kinds of the sections of OP_MSG messages
+const (
+	opMsgSectionBody             byte = 0
+	opMsgSectionDocumentSequence byte = 1
+)
+
+// compressors of OP_COMPRESSED messages
+// https://www.mongodb.com/docs/manual/reference/mongodb-wire-protocol/#op_compressed
+const (
+	CompressorNoop   uint8 = 0
+	CompressorSnappy uint8 = 1
+	CompressorZlib   uint8 = 2
+	CompressorZstd   uint8 = 3
+)
+
+// server error codes
+// https://www.mongodb.com/docs/manual/reference/error-codes/
+const (
+	ErrUnauthorizedCode     int32 = 13
+	ErrUnauthorizedCodeName       = "Unauthorized"
 )
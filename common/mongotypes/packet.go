@@ -1,10 +1,12 @@
 package mongotypes
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"io"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 )
@@ -69,15 +71,270 @@ func Decode(r io.Reader) (*Packet, error) {
 	return &p, nil
 }
 
+// DecodeCommandToJSON decodes the command of OP_MSG and OP_QUERY packets
+// to JSON, compressed packets are decompressed before decoding it.
+// Other opcodes returns an empty result.
+func DecodeCommandToJSON(pkt *Packet) (data []byte, err error) {
+	pkt, err = Decompress(pkt)
+	if err != nil {
+		return nil, err
+	}
+	switch pkt.OpCode {
+	case OpMsgType:
+		return DecodeOpMsgToJSON(pkt)
+	case OpQueryType:
+		return DecodeOpQueryToJSON(pkt)
+	}
+	return
+}
+
+// DecodeOpMsgToJSON decodes the body of an OP_MSG packet to JSON. The documents of the
+// document sequence sections are added to the body as arrays named by their identifiers,
+// the same way the server interprets them, e.g.: the deletes of a delete command.
+// https://www.mongodb.com/docs/manual/reference/mongodb-wire-protocol/#op_msg
 func DecodeOpMsgToJSON(pkt *Packet) (data []byte, err error) {
 	if pkt.OpCode != OpMsgType {
 		return
 	}
-	var decDoc bson.D
-	// skip message flags (4) and document kind body (1)
-	err = bson.Unmarshal(pkt.Frame[5:], &decDoc)
+	frame := pkt.Frame
+	if len(frame) < 5 {
+		return nil, fmt.Errorf("invalid OP_MSG message size (%v)", len(frame))
+	}
+	// the checksum is placed after the sections
+	if binary.LittleEndian.Uint32(frame[:4])&opMsgChecksumPresentFlag > 0 {
+		if len(frame) < 9 {
+			return nil, fmt.Errorf("invalid OP_MSG message size (%v)", len(frame))
+		}
+		frame = frame[:len(frame)-4]
+	}
+	var body bson.D
+	var sequences bson.D
+	// skip message flags (4)
+	for pos := 4; pos < len(frame); {
+		kind := frame[pos]
+		pos++
+		if len(frame) < pos+4 {
+			return nil, fmt.Errorf("invalid OP_MSG section size")
+		}
+		size := int(binary.LittleEndian.Uint32(frame[pos : pos+4]))
+		if size < 5 || pos+size > len(frame) {
+			return nil, fmt.Errorf("invalid OP_MSG section size (%v)", size)
+		}
+		section := frame[pos : pos+size]
+		pos += size
+		switch kind {
+		case opMsgSectionBody:
+			if body != nil {
+				return nil, fmt.Errorf("OP_MSG message with more than one body section")
+			}
+			if err := bson.Unmarshal(section, &body); err != nil {
+				return nil, fmt.Errorf("failed decoding OP_MSG document: %v", err)
+			}
+		case opMsgSectionDocumentSequence:
+			identifier, docPos, err := readCString(section, 4)
+			if err != nil {
+				return nil, fmt.Errorf("failed decoding OP_MSG document sequence identifier: %v", err)
+			}
+			docs := bson.A{}
+			for docPos < len(section) {
+				if len(section) < docPos+4 {
+					return nil, fmt.Errorf("invalid OP_MSG document sequence size")
+				}
+				docSize := int(binary.LittleEndian.Uint32(section[docPos : docPos+4]))
+				if docSize < 5 || docPos+docSize > len(section) {
+					return nil, fmt.Errorf("invalid OP_MSG document size (%v)", docSize)
+				}
+				var doc bson.D
+				if err := bson.Unmarshal(section[docPos:docPos+docSize], &doc); err != nil {
+					return nil, fmt.Errorf("failed decoding OP_MSG document sequence %q: %v", identifier, err)
+				}
+				docs = append(docs, doc)
+				docPos += docSize
+			}
+			sequences = append(sequences, bson.E{Key: identifier, Value: docs})
+		default:
+			return nil, fmt.Errorf("unknown OP_MSG section kind (%v)", kind)
+		}
+	}
+	if body == nil {
+		return nil, fmt.Errorf("OP_MSG message without a body section")
+	}
+	for _, e := range sequences {
+		if hasKey(body, e.Key) {
+			return nil, fmt.Errorf("OP_MSG document sequence %q is duplicated in the body", e.Key)
+		}
+		body = append(body, e)
+	}
+	return bson.MarshalExtJSON(body, false, false)
+}
+
+// DecodeOpQueryToJSON decodes an OP_QUERY packet to a command document.
+// Queries to the $cmd collection returns the command as it is, queries to
+// other collections are converted to the equivalent find command.
+// https://www.mongodb.com/docs/manual/legacy-opcodes/#op_query
+func DecodeOpQueryToJSON(pkt *Packet) (data []byte, err error) {
+	if pkt.OpCode != OpQueryType {
+		return
+	}
+	// skip flags (4)
+	if len(pkt.Frame) < 4 {
+		return nil, fmt.Errorf("invalid OP_QUERY message size (%v)", len(pkt.Frame))
+	}
+	fullCollectionName, pos, err := readCString(pkt.Frame, 4)
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding OP_QUERY collection name: %v", err)
+	}
+	// skip numberToSkip (4) and numberToReturn (4)
+	pos += 8
+	// the query document could be followed by the returnFieldsSelector document
+	if len(pkt.Frame) < pos+4 {
+		return nil, fmt.Errorf("invalid OP_QUERY message size (%v)", len(pkt.Frame))
+	}
+	docSize := int(binary.LittleEndian.Uint32(pkt.Frame[pos : pos+4]))
+	if docSize < 5 || pos+docSize > len(pkt.Frame) {
+		return nil, fmt.Errorf("invalid OP_QUERY document size (%v)", docSize)
+	}
+	var query bson.D
+	if err := bson.Unmarshal(pkt.Frame[pos:pos+docSize], &query); err != nil {
+		return nil, fmt.Errorf("failed decoding OP_QUERY document: %v", err)
+	}
+	// the query could be wrapped when it contains modifiers, e.g.: $readPreference
+	if wrapped, ok := lookupDocument(query, "$query"); ok {
+		query = wrapped
+	}
+	dbName, collName, _ := strings.Cut(fullCollectionName, ".")
+	var doc bson.D
+	if collName == "$cmd" {
+		doc = query
+		if !hasKey(doc, "$db") {
+			doc = append(doc, bson.E{Key: "$db", Value: dbName})
+		}
+	} else {
+		doc = bson.D{
+			{Key: "find", Value: collName},
+			{Key: "filter", Value: query},
+			{Key: "$db", Value: dbName},
+		}
+	}
+	return bson.MarshalExtJSON(doc, false, false)
+}
+
+// DecodeOpReplyToJSON decodes the documents of an OP_REPLY packet to JSON
+// https://www.mongodb.com/docs/manual/legacy-opcodes/#op_reply
+func DecodeOpReplyToJSON(pkt *Packet) (data []byte, err error) {
+	if pkt.OpCode != OpReplyType {
+		return
+	}
+	// responseFlags (4), cursorID (8), startingFrom (4) and numberReturned (4)
+	if len(pkt.Frame) < 20 {
+		return nil, fmt.Errorf("invalid OP_REPLY message size (%v)", len(pkt.Frame))
+	}
+	cursorID := int64(binary.LittleEndian.Uint64(pkt.Frame[4:12]))
+	numberReturned := int32(binary.LittleEndian.Uint32(pkt.Frame[16:20]))
+	documents := bson.A{}
+	for pos := 20; pos < len(pkt.Frame); {
+		if len(pkt.Frame[pos:]) < 4 {
+			return nil, fmt.Errorf("invalid OP_REPLY document size")
+		}
+		docSize := int(binary.LittleEndian.Uint32(pkt.Frame[pos : pos+4]))
+		if docSize < 5 || pos+docSize > len(pkt.Frame) {
+			return nil, fmt.Errorf("invalid OP_REPLY document size (%v)", docSize)
+		}
+		var doc bson.D
+		if err = bson.Unmarshal(pkt.Frame[pos:pos+docSize], &doc); err != nil {
+			return nil, fmt.Errorf("failed decoding OP_REPLY document: %v", err)
+		}
+		documents = append(documents, doc)
+		pos += docSize
+	}
+	if int(numberReturned) != len(documents) {
+		return nil, fmt.Errorf("OP_REPLY number of documents mismatch, expected=%v, got=%v",
+			numberReturned, len(documents))
+	}
+	return bson.MarshalExtJSON(bson.D{
+		{Key: "cursorId", Value: cursorID},
+		{Key: "documents", Value: documents},
+	}, false, false)
+}
+
+// NewOpMsgError creates an OP_MSG reply to the request id with a command error document.
+// https://www.mongodb.com/docs/manual/reference/mongodb-wire-protocol/#op_msg
+func NewOpMsgError(responseTo uint32, code int32, codeName, errMsg string) (*Packet, error) {
+	doc, err := bson.Marshal(bson.D{
+		{Key: "ok", Value: float64(0)},
+		{Key: "errmsg", Value: errMsg},
+		{Key: "code", Value: code},
+		{Key: "codeName", Value: codeName},
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed decoding OP_MSG document: %v", err)
+		return nil, fmt.Errorf("failed encoding OP_MSG error document: %v", err)
+	}
+	// message flags (4) and document kind body (1)
+	frame := make([]byte, 5, 5+len(doc))
+	frame = append(frame, doc...)
+	return &Packet{
+		MessageLength: uint32(len(frame) + 16),
+		ResponseTo:    responseTo,
+		OpCode:        OpMsgType,
+		Frame:         frame,
+	}, nil
+}
+
+// NewOpReplyError creates an OP_REPLY to the request id with a query failure document,
+// it's the reply of OP_QUERY requests.
+// https://www.mongodb.com/docs/manual/legacy-opcodes/#op_reply
+func NewOpReplyError(responseTo uint32, code int32, codeName, errMsg string) (*Packet, error) {
+	doc, err := bson.Marshal(bson.D{
+		{Key: "ok", Value: float64(0)},
+		{Key: "errmsg", Value: errMsg},
+		{Key: "$err", Value: errMsg},
+		{Key: "code", Value: code},
+		{Key: "codeName", Value: codeName},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding OP_REPLY error document: %v", err)
+	}
+	// responseFlags (4), cursorID (8), startingFrom (4) and numberReturned (4)
+	frame := make([]byte, 20, 20+len(doc))
+	binary.LittleEndian.PutUint32(frame[0:4], opReplyQueryFailureFlag)
+	binary.LittleEndian.PutUint32(frame[16:20], 1)
+	frame = append(frame, doc...)
+	return &Packet{
+		MessageLength: uint32(len(frame) + 16),
+		ResponseTo:    responseTo,
+		OpCode:        OpReplyType,
+		Frame:         frame,
+	}, nil
+}
+
+// readCString reads a null terminated string starting at pos,
+// it returns the string and the position after the null byte.
+func readCString(data []byte, pos int) (string, int, error) {
+	if pos > len(data) {
+		return "", 0, io.ErrUnexpectedEOF
+	}
+	idx := bytes.IndexByte(data[pos:], 0x00)
+	if idx == -1 {
+		return "", 0, fmt.Errorf("missing null terminator")
+	}
+	return string(data[pos : pos+idx]), pos + idx + 1, nil
+}
+
+func hasKey(doc bson.D, key string) bool {
+	for _, e := range doc {
+		if e.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func lookupDocument(doc bson.D, key string) (bson.D, bool) {
+	for _, e := range doc {
+		if e.Key == key {
+			v, ok := e.Value.(bson.D)
+			return v, ok
+		}
 	}
-	return bson.MarshalExtJSON(decDoc, false, false)
+	return nil, false
 }
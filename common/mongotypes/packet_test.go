@@ -2,9 +2,13 @@ package mongotypes
 
 import (
 	"bytes"
+	"compress/zlib"
+	"encoding/binary"
 	"encoding/hex"
 	"testing"
 
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -47,3 +51,260 @@ func TestEncodeDecodeBsonToJsonNonCanonical(t *testing.T) {
 	}
 
 }
+
+func newOpQueryPacket(t *testing.T, fullCollectionName string, query any, fields any) *Packet {
+	frame := make([]byte, 4)
+	frame = append(frame, append([]byte(fullCollectionName), 0x00)...)
+	// numberToSkip (4) and numberToReturn (4)
+	frame = append(frame, make([]byte, 8)...)
+	for _, doc := range []any{query, fields} {
+		if doc == nil {
+			continue
+		}
+		data, err := bson.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frame = append(frame, data...)
+	}
+	return &Packet{MessageLength: uint32(len(frame) + 16), RequestID: 10, OpCode: OpQueryType, Frame: frame}
+}
+
+func TestDecodeOpQueryToJSON(t *testing.T) {
+	encHex, _ := hex.DecodeString(`5a0100000100000000000000d40700000000000061646d696e2e24636d640000000000ffffffff330100001069736d617374657200010000000868656c6c6f4f6b000103636c69656e7400f0000000036170706c69636174696f6e001d000000026e616d65000e0000006d6f6e676f736820322e312e350000036472697665720037000000026e616d65000f0000006e6f64656a737c6d6f6e676f7368000276657273696f6e000c000000362e332e307c322e312e35000002706c6174666f726d00150000004e6f64652e6a73207632302e31312e312c204c4500036f73005b000000026e616d6500060000006c696e75780002617263686974656374757265000600000061726d3634000276657273696f6e0011000000352e31352e34392d6c696e75786b697400027479706500060000004c696e757800000004636f6d7072657373696f6e0011000000023000050000006e6f6e65000000`)
+	isMasterPkt, err := Decode(bytes.NewBuffer(encHex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		msg     string
+		pkt     *Packet
+		want    string
+		wantErr bool
+	}{
+		{
+			msg:  "it should decode the command of the $cmd collection",
+			pkt:  isMasterPkt,
+			want: `{"ismaster":1,"helloOk":true,"client":{"application":{"name":"mongosh 2.1.5"},"driver":{"name":"nodejs|mongosh","version":"6.3.0|2.1.5"},"platform":"Node.js v20.11.1, LE","os":{"name":"linux","architecture":"arm64","version":"5.15.49-linuxkit","type":"Linux"}},"compression":["none"],"$db":"admin"}`,
+		},
+		{
+			msg:  "it should unwrap commands with query modifiers",
+			pkt:  newOpQueryPacket(t, "app.$cmd", bson.D{{Key: "$query", Value: bson.D{{Key: "count", Value: "users"}}}, {Key: "$readPreference", Value: bson.D{{Key: "mode", Value: "secondary"}}}}, nil),
+			want: `{"count":"users","$db":"app"}`,
+		},
+		{
+			msg:  "it should convert queries of collections to a find command",
+			pkt:  newOpQueryPacket(t, "app.users", bson.D{{Key: "name", Value: "john"}}, bson.D{{Key: "email", Value: 1}}),
+			want: `{"find":"users","filter":{"name":"john"},"$db":"app"}`,
+		},
+		{
+			msg:     "it should return error when the collection name is not terminated",
+			pkt:     &Packet{OpCode: OpQueryType, Frame: []byte{0, 0, 0, 0, 'a', 'p', 'p'}},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := DecodeOpQueryToJSON(tt.pkt)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got=%v", string(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("expected decoded query to match, got=%v, want=%v", string(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeOpReplyToJSON(t *testing.T) {
+	pkt, err := NewOpReplyError(10, ErrUnauthorizedCode, ErrUnauthorizedCodeName, "access denied")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// encoding and decoding it must keep the same packet
+	pkt, err = Decode(bytes.NewBuffer(pkt.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkt.ResponseTo != 10 || pkt.OpCode != OpReplyType {
+		t.Fatalf("expected OP_REPLY response to request 10, got=%v/%v", pkt.OpCode, pkt.ResponseTo)
+	}
+	want := `{"cursorId":0,"documents":[{"ok":0.0,"errmsg":"access denied","$err":"access denied","code":13,"codeName":"Unauthorized"}]}`
+	got, err := DecodeOpReplyToJSON(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("expected decoded reply to match, got=%v, want=%v", string(got), want)
+	}
+}
+
+func newOpCompressedPacket(t *testing.T, pkt *Packet, compressorID uint8) *Packet {
+	var data []byte
+	switch compressorID {
+	case CompressorNoop:
+		data = pkt.Frame
+	case CompressorSnappy:
+		data = snappy.Encode(nil, pkt.Frame)
+	case CompressorZlib:
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		_, _ = w.Write(pkt.Frame)
+		_ = w.Close()
+		data = buf.Bytes()
+	case CompressorZstd:
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = enc.EncodeAll(pkt.Frame, nil)
+	}
+	frame := make([]byte, 9, 9+len(data))
+	binary.LittleEndian.PutUint32(frame[0:4], pkt.OpCode)
+	binary.LittleEndian.PutUint32(frame[4:8], uint32(len(pkt.Frame)))
+	frame[8] = compressorID
+	frame = append(frame, data...)
+	return &Packet{MessageLength: uint32(len(frame) + 16), RequestID: pkt.RequestID, OpCode: OpCompressed, Frame: frame}
+}
+
+func TestDecodeCompressedCommandToJSON(t *testing.T) {
+	want := `{"hello":1,"helloOk":true,"topologyVersion":{"processId":{"$oid":"66314ea2a13a0bf9a6366d74"},"counter":6},"maxAwaitTimeMS":10000,"$db":"admin","$readPreference":{"mode":"primaryPreferred"}}`
+	encHex, _ := hex.DecodeString(`c50000000400000000000000dd0700000000010000b00000001068656c6c6f00010000000868656c6c6f4f6b000103746f706f6c6f677956657273696f6e002d0000000770726f6365737349640066314ea2a13a0bf9a6366d7412636f756e74657200060000000000000000126d6178417761697454696d654d5300102700000000000002246462000600000061646d696e00032472656164507265666572656e63650020000000026d6f646500110000007072696d617279507265666572726564000000`)
+	opMsgPkt, err := Decode(bytes.NewBuffer(encHex))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		msg          string
+		compressorID uint8
+	}{
+		{msg: "it should decode uncompressed messages", compressorID: CompressorNoop},
+		{msg: "it should decode snappy compressed messages", compressorID: CompressorSnappy},
+		{msg: "it should decode zlib compressed messages", compressorID: CompressorZlib},
+		{msg: "it should decode zstd compressed messages", compressorID: CompressorZstd},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			pkt := newOpCompressedPacket(t, opMsgPkt, tt.compressorID)
+			got, err := DecodeCommandToJSON(pkt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want {
+				t.Errorf("expected decoded command to match, got=%v, want=%v", string(got), want)
+			}
+			decPkt, err := Decompress(pkt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decPkt.Encode(), encHex) {
+				t.Errorf("expected decompressed packet to match the original packet")
+			}
+		})
+	}
+
+	t.Run("it should return error with unknown compressors", func(t *testing.T) {
+		pkt := newOpCompressedPacket(t, opMsgPkt, CompressorNoop)
+		pkt.Frame[8] = 0x09
+		if _, err := DecodeCommandToJSON(pkt); err == nil {
+			t.Fatal("expected error with unknown compressor")
+		}
+	})
+
+	t.Run("it should return error when the uncompressed size mismatch", func(t *testing.T) {
+		pkt := newOpCompressedPacket(t, opMsgPkt, CompressorZlib)
+		binary.LittleEndian.PutUint32(pkt.Frame[4:8], 10)
+		if _, err := DecodeCommandToJSON(pkt); err == nil {
+			t.Fatal("expected error when the uncompressed size mismatch")
+		}
+	})
+}
+
+func newOpMsgPacket(t *testing.T, flags uint32, body any, sequences map[string][]any) *Packet {
+	frame := binary.LittleEndian.AppendUint32(nil, flags)
+	bodyDoc, err := bson.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame = append(append(frame, 0x00), bodyDoc...)
+	for identifier, docs := range sequences {
+		section := append([]byte(identifier), 0x00)
+		for _, doc := range docs {
+			data, err := bson.Marshal(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			section = append(section, data...)
+		}
+		frame = append(frame, 0x01)
+		frame = binary.LittleEndian.AppendUint32(frame, uint32(len(section)+4))
+		frame = append(frame, section...)
+	}
+	if flags&opMsgChecksumPresentFlag > 0 {
+		frame = append(frame, 0x00, 0x00, 0x00, 0x00)
+	}
+	return &Packet{MessageLength: uint32(len(frame) + 16), RequestID: 1, OpCode: OpMsgType, Frame: frame}
+}
+
+func TestDecodeOpMsgToJSON(t *testing.T) {
+	deleteCommand := bson.D{{Key: "delete", Value: "users"}, {Key: "$db", Value: "app"}}
+	for _, tt := range []struct {
+		msg     string
+		pkt     *Packet
+		want    string
+		wantErr bool
+	}{
+		{
+			msg:  "it should decode the body section",
+			pkt:  newOpMsgPacket(t, 0, deleteCommand, nil),
+			want: `{"delete":"users","$db":"app"}`,
+		},
+		{
+			msg: "it should add the document sequences to the body",
+			pkt: newOpMsgPacket(t, 0, deleteCommand, map[string][]any{
+				"deletes": {bson.D{{Key: "q", Value: bson.D{}}, {Key: "limit", Value: 0}}}}),
+			want: `{"delete":"users","$db":"app","deletes":[{"q":{},"limit":0}]}`,
+		},
+		{
+			msg: "it should ignore the checksum of the message",
+			pkt: newOpMsgPacket(t, opMsgChecksumPresentFlag, deleteCommand, map[string][]any{
+				"deletes": {bson.D{{Key: "q", Value: bson.D{{Key: "id", Value: 1}}}}}}),
+			want: `{"delete":"users","$db":"app","deletes":[{"q":{"id":1}}]}`,
+		},
+		{
+			msg: "it should return error when the document sequence is duplicated in the body",
+			pkt: newOpMsgPacket(t, 0, bson.D{{Key: "delete", Value: "users"}, {Key: "deletes", Value: bson.A{}}}, map[string][]any{
+				"deletes": {bson.D{{Key: "q", Value: bson.D{}}}}}),
+			wantErr: true,
+		},
+		{
+			msg: "it should return error when the document sequence is malformed",
+			pkt: func() *Packet {
+				pkt := newOpMsgPacket(t, 0, deleteCommand, map[string][]any{"deletes": {bson.D{{Key: "q", Value: bson.D{}}}}})
+				pkt.Frame = pkt.Frame[:len(pkt.Frame)-3]
+				return pkt
+			}(),
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := DecodeOpMsgToJSON(tt.pkt)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got=%s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("expected decoded command to match, got=%v, want=%v", string(got), tt.want)
+			}
+		})
+	}
+}
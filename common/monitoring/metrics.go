@@ -0,0 +1,27 @@
+package monitoring
+
+import (
+	"os"
+
+	"github.com/honeycombio/otel-config-go/otelconfig"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Meter returns the meter of the hoop instruments, the measurements
+// are discarded when the metrics exporter is not started.
+func Meter() metric.Meter { return otel.Meter("github.com/hoophq/hoop") }
+
+// StartMetricsExporter exports the metrics of the instruments with the OpenTelemetry protocol when
+// the environment variable OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_METRICS_ENDPOINT is set.
+// The exporter is configured with the standard OTEL_* environment variables, e.g.: OTEL_EXPORTER_OTLP_HEADERS.
+func StartMetricsExporter(serviceName string) (ShutdownFn, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT") == "" {
+		return func() {}, nil
+	}
+	return otelconfig.ConfigureOpenTelemetry(
+		otelconfig.WithServiceName(serviceName),
+		otelconfig.WithTracesEnabled(false),
+		otelconfig.WithMetricsEnabled(true),
+	)
+}
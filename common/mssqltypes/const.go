@@ -38,8 +38,23 @@ var packetTypeMap = map[PacketType]string{
 	PacketPreloginType:     "PacketPreloginType",
 }
 
-const sp_ExecuteSql byte = 0x0a
-
 // variable-length data types
 // http://msdn.microsoft.com/en-us/library/dd358341.aspx
 const typeNVarChar = 0xe7
+
+// token stream types
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/7091f6f6-b83d-4ed2-afeb-ba5013dfb18f
+const (
+	tokenError byte = 0xaa
+	tokenDone  byte = 0xfd
+)
+
+// done token status
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/3c06f110-98bd-4d5b-b836-b1ba66452cb7
+const (
+	doneFinal uint16 = 0x00
+	doneError uint16 = 0x02
+)
+
+// ErrPermissionDeniedNumber is the server error number when a permission is denied on an object
+const ErrPermissionDeniedNumber uint32 = 229
@@ -0,0 +1,34 @@
+package mssqltypes
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// NewErrorResponse creates a tabular result packet containing an ERROR token
+// followed by a DONE token with the error status. It assumes TDS 7.2+ where
+// the line number is 4 bytes and the done row count is 8 bytes.
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/9805e9fa-1f8b-4cf8-8f78-8d2602228635
+func NewErrorResponse(number uint32, class byte, message string) *Packet {
+	msgText := str2ucs2(message)
+	var token bytes.Buffer
+	_ = binary.Write(&token, binary.LittleEndian, number)
+	token.WriteByte(0x01) // state
+	token.WriteByte(class)
+	_ = binary.Write(&token, binary.LittleEndian, uint16(len(msgText)/2))
+	token.Write(msgText)
+	token.WriteByte(0x00)                                    // server name (B_VARCHAR)
+	token.WriteByte(0x00)                                    // proc name (B_VARCHAR)
+	_ = binary.Write(&token, binary.LittleEndian, uint32(1)) // line number
+
+	var data bytes.Buffer
+	data.WriteByte(tokenError)
+	_ = binary.Write(&data, binary.LittleEndian, uint16(token.Len()))
+	data.Write(token.Bytes())
+
+	data.WriteByte(tokenDone)
+	_ = binary.Write(&data, binary.LittleEndian, doneFinal|doneError)
+	_ = binary.Write(&data, binary.LittleEndian, uint16(0)) // current command
+	_ = binary.Write(&data, binary.LittleEndian, uint64(0)) // row count
+	return New(PacketReplyType, data.Bytes())
+}
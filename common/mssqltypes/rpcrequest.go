@@ -0,0 +1,134 @@
+package mssqltypes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// statusEOM indicates the last packet of a request
+const statusEOM byte = 0x01
+
+// stored procedures of rpc requests that execute sql statements
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/619c43b6-9495-4a58-9e49-a4950db245b3
+const (
+	spCursorOpen     uint16 = 0x02
+	spCursorPrepare  uint16 = 0x03
+	spCursorPrepExec uint16 = 0x05
+	spExecuteSql     uint16 = 0x0a
+	spPrepare        uint16 = 0x0b
+	spPrepExec       uint16 = 0x0d
+)
+
+// rpcStatementParams maps the procedures to the position of the parameter containing the statement
+var rpcStatementParams = map[uint16]int{
+	spCursorOpen:     1,
+	spCursorPrepare:  2,
+	spCursorPrepExec: 3,
+	spExecuteSql:     0,
+	spPrepare:        2,
+	spPrepExec:       2,
+}
+
+// rpcProcNames maps the procedures called by name to their ids
+var rpcProcNames = map[string]uint16{
+	"sp_cursoropen":     spCursorOpen,
+	"sp_cursorprepare":  spCursorPrepare,
+	"sp_cursorprepexec": spCursorPrepExec,
+	"sp_executesql":     spExecuteSql,
+	"sp_prepare":        spPrepare,
+	"sp_prepexec":       spPrepExec,
+}
+
+// DecodeRpcRequestToRawQuery decodes the statement of rpc requests calling procedures that execute sql
+// (sp_executesql, sp_prepare, sp_prepexec and the cursor procedures). It returns an empty statement for
+// other procedures and for the continuation packets of a request, their parameters can't be decoded.
+// When the statement doesn't fit in the packet, the available part of it is returned.
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/619c43b6-9495-4a58-9e49-a4950db245b3
+func DecodeRpcRequestToRawQuery(data []byte) (string, error) {
+	if len(data) < 8 {
+		return "", fmt.Errorf("not a valid rpc request type, data=%X", data)
+	}
+	if PacketType(data[0]) != PacketRPCRequestType {
+		return "", fmt.Errorf("it's not a rpc request type, found=%X", data[0])
+	}
+	isLastPacket := data[1]&statusEOM != 0
+	if packetNo := data[6]; packetNo != 0x01 {
+		return "", nil
+	}
+	r := &tokenReader{data: data[8:]}
+	// skip ALL_HEADERS, the length includes itself
+	_ = r.next(int(r.uint32()) - 4)
+	var procID uint16
+	if nameLength := r.uint16(); nameLength == 0xffff {
+		procID = r.uint16()
+	} else {
+		procID = rpcProcNames[strings.ToLower(ucs22str(r.next(int(nameLength)*2)))]
+	}
+	// option flags
+	_ = r.uint16()
+	if r.err != nil {
+		return "", fmt.Errorf("failed decoding rpc request header, reason=%v", r.err)
+	}
+	stmtParam, ok := rpcStatementParams[procID]
+	if !ok {
+		return "", nil
+	}
+	for i := 0; ; i++ {
+		// parameter name and status flags
+		_ = r.bVarchar()
+		_ = r.byte()
+		col, err := readTypeInfo(r)
+		if err != nil {
+			return "", fmt.Errorf("failed decoding type of rpc parameter %v, reason=%v", i, err)
+		}
+		valuePos := r.pos
+		value, err := readValue(r, col)
+		if i < stmtParam {
+			if err != nil {
+				return "", fmt.Errorf("failed decoding rpc parameter %v, reason=%v", i, err)
+			}
+			continue
+		}
+		switch col.typ {
+		case typeNVarChar, typeNChar, typeBigVarChar, typeBigChar, typeNText, typeText:
+		default:
+			return "", fmt.Errorf("rpc statement parameter has an unexpected data type %X", col.typ)
+		}
+		if err == errShortBuffer && !isLastPacket {
+			return decodePartialStatement(r.data[valuePos:], col), nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed decoding rpc statement, reason=%v", err)
+		}
+		return string(value), nil
+	}
+}
+
+// decodePartialStatement decodes the beginning of a string value that continues in the next packet
+func decodePartialStatement(data []byte, col column) string {
+	r := &tokenReader{data: data}
+	var size int
+	switch {
+	case col.isPLP:
+		// total length(8) + first chunk length(4)
+		_ = r.next(8)
+		size = int(r.uint32())
+	case col.typ == typeNText, col.typ == typeText:
+		// text pointer, timestamp(8) and length(4)
+		_ = r.next(int(r.byte()) + 8)
+		size = int(r.uint32())
+	default:
+		size = int(r.uint16())
+	}
+	if r.err != nil {
+		return ""
+	}
+	value := r.data[r.pos:]
+	if size < len(value) {
+		value = value[:size]
+	}
+	if !col.isNChar {
+		return string(value)
+	}
+	return ucs22str(value[:len(value)-len(value)%2])
+}
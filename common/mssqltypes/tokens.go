@@ -0,0 +1,547 @@
+package mssqltypes
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// token stream types of tabular results
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/7091f6f6-b83d-4ed2-afeb-ba5013dfb18f
+const (
+	tokenReturnStatus       byte = 0x79
+	tokenColMetadata        byte = 0x81
+	tokenDataClassification byte = 0xa3
+	tokenTabName            byte = 0xa4
+	tokenColInfo            byte = 0xa5
+	tokenOrder              byte = 0xa9
+	tokenInfo               byte = 0xab
+	tokenReturnValue        byte = 0xac
+	tokenLoginAck           byte = 0xad
+	tokenFeatureExtAck      byte = 0xae
+	tokenRow                byte = 0xd1
+	tokenNBCRow             byte = 0xd2
+	tokenEnvChange          byte = 0xe3
+	tokenSessionState       byte = 0xe4
+	tokenSSPI               byte = 0xed
+	tokenFedAuthInfo        byte = 0xee
+	tokenDoneProc           byte = 0xfe
+	tokenDoneInProc         byte = 0xff
+)
+
+// data types
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/ffb02215-af07-4b50-8545-1fd522106c68
+const (
+	typeNull           byte = 0x1f
+	typeInt1           byte = 0x30
+	typeBit            byte = 0x32
+	typeInt2           byte = 0x34
+	typeInt4           byte = 0x38
+	typeDateTim4       byte = 0x3a
+	typeFlt4           byte = 0x3b
+	typeMoney          byte = 0x3c
+	typeDateTime       byte = 0x3d
+	typeFlt8           byte = 0x3e
+	typeMoney4         byte = 0x7a
+	typeInt8           byte = 0x7f
+	typeGUID           byte = 0x24
+	typeIntN           byte = 0x26
+	typeDecimal        byte = 0x37
+	typeNumeric        byte = 0x3f
+	typeBitN           byte = 0x68
+	typeDecimalN       byte = 0x6a
+	typeNumericN       byte = 0x6c
+	typeFltN           byte = 0x6d
+	typeMoneyN         byte = 0x6e
+	typeDateTimeN      byte = 0x6f
+	typeDateN          byte = 0x28
+	typeTimeN          byte = 0x29
+	typeDateTime2N     byte = 0x2a
+	typeDateTimeOffset byte = 0x2b
+	typeChar           byte = 0x2f
+	typeVarChar        byte = 0x27
+	typeBinary         byte = 0x2d
+	typeVarBinary      byte = 0x25
+	typeBigVarBin      byte = 0xa5
+	typeBigVarChar     byte = 0xa7
+	typeBigBinary      byte = 0xad
+	typeBigChar        byte = 0xaf
+	typeNChar          byte = 0xef
+	typeXML            byte = 0xf1
+	typeText           byte = 0x23
+	typeImage          byte = 0x22
+	typeNText          byte = 0x63
+	typeVariant        byte = 0x62
+)
+
+var errShortBuffer = errors.New("short buffer")
+
+// ResultSetHandler receives the result sets decoded from a token stream
+type ResultSetHandler interface {
+	// OnColumns is called when a new result set starts
+	OnColumns(names []string)
+	// OnRow is called for each row with the values formatted as text, null values are nil
+	OnRow(values [][]byte)
+	// OnDone is called when the result set ends
+	OnDone()
+}
+
+// TokenDecoder decodes the rows of the token stream of tabular results,
+// it keeps the columns of the current result set between calls of Decode.
+type TokenDecoder struct {
+	columns []column
+}
+
+type column struct {
+	name     string
+	typ      byte
+	size     uint32
+	scale    byte
+	isPLP    bool
+	isNChar  bool
+	fixedLen int
+}
+
+// Decode decodes all complete tokens of data and returns the number of bytes consumed,
+// the remaining bytes must be prefixed to the next call. It returns an error when it
+// finds a token or a data type that it's unable to decode.
+func (d *TokenDecoder) Decode(data []byte, h ResultSetHandler) (int, error) {
+	consumed := 0
+	for consumed < len(data) {
+		r := &tokenReader{data: data[consumed:]}
+		if err := d.decodeToken(r, h); err != nil {
+			if err == errShortBuffer {
+				return consumed, nil
+			}
+			return consumed, err
+		}
+		consumed += r.pos
+	}
+	return consumed, nil
+}
+
+// Reset clears the state of the current result set
+func (d *TokenDecoder) Reset() { d.columns = nil }
+
+func (d *TokenDecoder) decodeToken(r *tokenReader, h ResultSetHandler) error {
+	token := r.byte()
+	switch token {
+	case tokenColMetadata:
+		columns, err := readColMetadata(r)
+		if err != nil {
+			return err
+		}
+		if r.err != nil {
+			return r.err
+		}
+		d.columns = columns
+		if len(columns) > 0 {
+			names := make([]string, len(columns))
+			for i, col := range columns {
+				names[i] = col.name
+			}
+			h.OnColumns(names)
+		}
+		return nil
+	case tokenRow, tokenNBCRow:
+		if len(d.columns) == 0 {
+			return fmt.Errorf("row token without column metadata")
+		}
+		var nullBitmap []byte
+		if token == tokenNBCRow {
+			nullBitmap = r.next((len(d.columns) + 7) / 8)
+		}
+		values := make([][]byte, len(d.columns))
+		for i, col := range d.columns {
+			if nullBitmap != nil && nullBitmap[i/8]&(1<<(i%8)) != 0 {
+				continue
+			}
+			val, err := readValue(r, col)
+			if err != nil {
+				return err
+			}
+			values[i] = val
+		}
+		if r.err != nil {
+			return r.err
+		}
+		h.OnRow(values)
+		return nil
+	case tokenDone, tokenDoneProc, tokenDoneInProc:
+		_ = r.next(12)
+		if r.err == nil && len(d.columns) > 0 {
+			d.columns = nil
+			h.OnDone()
+		}
+	case tokenReturnStatus:
+		_ = r.next(4)
+	case tokenError, tokenInfo, tokenLoginAck, tokenEnvChange, tokenOrder, tokenColInfo, tokenTabName, tokenSSPI:
+		_ = r.next(int(r.uint16()))
+	case tokenSessionState, tokenFedAuthInfo:
+		_ = r.next(int(r.uint32()))
+	case tokenFeatureExtAck:
+		for r.err == nil {
+			if featureID := r.byte(); featureID == 0xff {
+				break
+			}
+			_ = r.next(int(r.uint32()))
+		}
+	case tokenReturnValue:
+		// ordinal(2), param name, status(1), user type(4), flags(2)
+		_ = r.next(2)
+		_ = r.bVarchar()
+		_ = r.next(7)
+		col, err := readTypeInfo(r)
+		if err != nil {
+			return err
+		}
+		if _, err := readValue(r, col); err != nil {
+			return err
+		}
+	case tokenDataClassification:
+		return fmt.Errorf("data classification token is not supported")
+	default:
+		if r.err != nil {
+			return r.err
+		}
+		return fmt.Errorf("unknown token type %X", token)
+	}
+	return r.err
+}
+
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/58880b9f-381c-43b2-bf8b-0727a98c4f4c
+func readColMetadata(r *tokenReader) ([]column, error) {
+	count := r.uint16()
+	// no metadata
+	if count == 0xffff {
+		return nil, nil
+	}
+	var columns []column
+	for i := 0; i < int(count) && r.err == nil; i++ {
+		// user type(4), flags(2)
+		_ = r.next(6)
+		col, err := readTypeInfo(r)
+		if err != nil {
+			return nil, err
+		}
+		switch col.typ {
+		case typeText, typeNText, typeImage:
+			parts := r.byte()
+			for j := 0; j < int(parts) && r.err == nil; j++ {
+				_ = r.usVarchar()
+			}
+		}
+		col.name = r.bVarchar()
+		columns = append(columns, col)
+	}
+	return columns, r.err
+}
+
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/cbe9c510-eae6-4b1f-9893-a098944d430a
+func readTypeInfo(r *tokenReader) (column, error) {
+	col := column{typ: r.byte()}
+	switch col.typ {
+	case typeNull:
+	case typeInt1, typeBit:
+		col.fixedLen = 1
+	case typeInt2:
+		col.fixedLen = 2
+	case typeInt4, typeDateTim4, typeFlt4, typeMoney4:
+		col.fixedLen = 4
+	case typeMoney, typeDateTime, typeFlt8, typeInt8:
+		col.fixedLen = 8
+	case typeGUID, typeIntN, typeBitN, typeFltN, typeMoneyN, typeDateTimeN,
+		typeChar, typeVarChar, typeBinary, typeVarBinary:
+		col.size = uint32(r.byte())
+	case typeDecimal, typeNumeric, typeDecimalN, typeNumericN:
+		// size(1), precision(1), scale(1)
+		col.size = uint32(r.byte())
+		_ = r.byte()
+		col.scale = r.byte()
+	case typeDateN:
+	case typeTimeN, typeDateTime2N, typeDateTimeOffset:
+		col.scale = r.byte()
+	case typeBigVarBin, typeBigBinary:
+		col.size = uint32(r.uint16())
+		col.isPLP = col.size == 0xffff
+	case typeBigVarChar, typeBigChar, typeNVarChar, typeNChar:
+		col.size = uint32(r.uint16())
+		col.isPLP = col.size == 0xffff
+		col.isNChar = col.typ == typeNVarChar || col.typ == typeNChar
+		// collation
+		_ = r.next(5)
+	case typeText, typeNText:
+		col.size = r.uint32()
+		col.isNChar = col.typ == typeNText
+		_ = r.next(5)
+	case typeImage, typeVariant:
+		col.size = r.uint32()
+	case typeXML:
+		col.isPLP = true
+		col.isNChar = true
+		if schemaPresent := r.byte(); schemaPresent == 0x01 {
+			_ = r.bVarchar()
+			_ = r.bVarchar()
+			_ = r.usVarchar()
+		}
+	default:
+		if r.err != nil {
+			return col, r.err
+		}
+		return col, fmt.Errorf("data type %X is not supported", col.typ)
+	}
+	return col, r.err
+}
+
+func readValue(r *tokenReader, col column) ([]byte, error) {
+	var data []byte
+	switch {
+	case col.typ == typeNull:
+		return nil, r.err
+	case col.fixedLen > 0:
+		data = r.next(col.fixedLen)
+	case col.isPLP:
+		data = r.plp()
+	case col.typ == typeText, col.typ == typeNText, col.typ == typeImage:
+		textPtrSize := r.byte()
+		if textPtrSize == 0 {
+			return nil, r.err
+		}
+		// text pointer and timestamp(8)
+		_ = r.next(int(textPtrSize) + 8)
+		data = r.next(int(r.uint32()))
+	case col.typ == typeVariant:
+		size := r.uint32()
+		if size == 0 {
+			return nil, r.err
+		}
+		data = r.next(int(size))
+	case col.typ == typeBigVarBin, col.typ == typeBigBinary, col.typ == typeBigVarChar,
+		col.typ == typeBigChar, col.typ == typeNVarChar, col.typ == typeNChar:
+		size := r.uint16()
+		if size == 0xffff {
+			return nil, r.err
+		}
+		data = r.next(int(size))
+	default:
+		size := r.byte()
+		if size == 0 {
+			return nil, r.err
+		}
+		data = r.next(int(size))
+	}
+	if r.err != nil || data == nil {
+		return nil, r.err
+	}
+	return []byte(formatValue(col, data)), nil
+}
+
+func formatValue(col column, data []byte) string {
+	switch col.typ {
+	case typeInt1, typeInt2, typeInt4, typeInt8, typeIntN:
+		switch len(data) {
+		case 1:
+			return strconv.FormatUint(uint64(data[0]), 10)
+		case 2:
+			return strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(data))), 10)
+		case 4:
+			return strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(data))), 10)
+		case 8:
+			return strconv.FormatInt(int64(binary.LittleEndian.Uint64(data)), 10)
+		}
+	case typeBit, typeBitN:
+		if len(data) == 1 {
+			return strconv.Itoa(int(data[0] & 0x01))
+		}
+	case typeFlt4, typeFlt8, typeFltN:
+		switch len(data) {
+		case 4:
+			return strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), 'g', -1, 32)
+		case 8:
+			return strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(data)), 'g', -1, 64)
+		}
+	case typeMoney, typeMoney4, typeMoneyN:
+		var v int64
+		switch len(data) {
+		case 4:
+			v = int64(int32(binary.LittleEndian.Uint32(data)))
+		case 8:
+			v = int64(binary.LittleEndian.Uint32(data[0:4]))<<32 | int64(binary.LittleEndian.Uint32(data[4:8]))
+		default:
+			return hexValue(data)
+		}
+		return formatScaled(big.NewInt(v), 4)
+	case typeDecimal, typeNumeric, typeDecimalN, typeNumericN:
+		if len(data) < 2 {
+			break
+		}
+		mantissa := make([]byte, len(data)-1)
+		for i, b := range data[1:] {
+			mantissa[len(mantissa)-1-i] = b
+		}
+		v := new(big.Int).SetBytes(mantissa)
+		if data[0] == 0x00 {
+			v.Neg(v)
+		}
+		return formatScaled(v, int(col.scale))
+	case typeGUID:
+		if len(data) == 16 {
+			return strings.ToUpper(fmt.Sprintf("%x-%x-%x-%x-%x",
+				[]byte{data[3], data[2], data[1], data[0]}, []byte{data[5], data[4]},
+				[]byte{data[7], data[6]}, data[8:10], data[10:]))
+		}
+	case typeDateTime, typeDateTim4, typeDateTimeN:
+		switch len(data) {
+		case 4:
+			days := binary.LittleEndian.Uint16(data[0:2])
+			minutes := binary.LittleEndian.Uint16(data[2:4])
+			return baseDate1900.AddDate(0, 0, int(days)).Add(time.Duration(minutes) * time.Minute).Format("2006-01-02 15:04:05")
+		case 8:
+			days := int32(binary.LittleEndian.Uint32(data[0:4]))
+			ticks := binary.LittleEndian.Uint32(data[4:8])
+			t := baseDate1900.AddDate(0, 0, int(days)).Add(time.Duration(ticks) * time.Second / 300)
+			return t.Format("2006-01-02 15:04:05.000")
+		}
+	case typeDateN:
+		if len(data) == 3 {
+			return decodeDate(data).Format("2006-01-02")
+		}
+	case typeTimeN:
+		if d, ok := decodeTime(data, col.scale); ok {
+			return baseDate1.Add(d).Format("15:04:05.9999999")
+		}
+	case typeDateTime2N:
+		if len(data) > 3 {
+			if d, ok := decodeTime(data[:len(data)-3], col.scale); ok {
+				return decodeDate(data[len(data)-3:]).Add(d).Format("2006-01-02 15:04:05.9999999")
+			}
+		}
+	case typeDateTimeOffset:
+		if len(data) > 5 {
+			if d, ok := decodeTime(data[:len(data)-5], col.scale); ok {
+				offset := int16(binary.LittleEndian.Uint16(data[len(data)-2:]))
+				t := decodeDate(data[len(data)-5 : len(data)-2]).Add(d).
+					In(time.FixedZone("", int(offset)*60))
+				return t.Format("2006-01-02 15:04:05.9999999 -07:00")
+			}
+		}
+	case typeChar, typeVarChar, typeBigChar, typeBigVarChar, typeText:
+		return string(data)
+	case typeNChar, typeNVarChar, typeNText, typeXML:
+		return ucs22str(data)
+	}
+	return hexValue(data)
+}
+
+var (
+	baseDate1900 = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	baseDate1    = time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// decodeDate decodes the number of days since 0001-01-01 (3 bytes)
+func decodeDate(data []byte) time.Time {
+	days := uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+	return baseDate1.AddDate(0, 0, int(days))
+}
+
+// decodeTime decodes the time of the day in units of 10^-scale seconds (3 to 5 bytes)
+func decodeTime(data []byte, scale byte) (time.Duration, bool) {
+	if len(data) < 3 || len(data) > 5 || scale > 7 {
+		return 0, false
+	}
+	var buf [8]byte
+	_ = copy(buf[:], data)
+	units := binary.LittleEndian.Uint64(buf[:])
+	for i := scale; i < 9; i++ {
+		units *= 10
+	}
+	return time.Duration(units), true
+}
+
+func formatScaled(v *big.Int, scale int) string {
+	s := new(big.Int).Abs(v).String()
+	if scale > 0 {
+		if len(s) <= scale {
+			s = strings.Repeat("0", scale-len(s)+1) + s
+		}
+		s = s[:len(s)-scale] + "." + s[len(s)-scale:]
+	}
+	if v.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
+
+func hexValue(data []byte) string { return "0x" + strings.ToUpper(hex.EncodeToString(data)) }
+
+// tokenReader reads the fields of a token stream,
+// it sets errShortBuffer when data does not contain the field.
+type tokenReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *tokenReader) next(size int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if size < 0 || len(r.data)-r.pos < size {
+		r.err = errShortBuffer
+		return nil
+	}
+	v := r.data[r.pos : r.pos+size]
+	r.pos += size
+	return v
+}
+
+func (r *tokenReader) byte() byte {
+	if v := r.next(1); v != nil {
+		return v[0]
+	}
+	return 0
+}
+
+func (r *tokenReader) uint16() uint16 {
+	if v := r.next(2); v != nil {
+		return binary.LittleEndian.Uint16(v)
+	}
+	return 0
+}
+
+func (r *tokenReader) uint32() uint32 {
+	if v := r.next(4); v != nil {
+		return binary.LittleEndian.Uint32(v)
+	}
+	return 0
+}
+
+// bVarchar reads an unicode string with the amount of characters in a byte
+func (r *tokenReader) bVarchar() string { return ucs22str(r.next(int(r.byte()) * 2)) }
+
+// usVarchar reads an unicode string with the amount of characters in a ushort
+func (r *tokenReader) usVarchar() string { return ucs22str(r.next(int(r.uint16()) * 2)) }
+
+// plp reads a partially length-prefixed value, it returns nil for null values
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/3f983fde-0509-485a-8c40-a9fa6679a828
+func (r *tokenReader) plp() []byte {
+	size := r.next(8)
+	if size == nil || binary.LittleEndian.Uint64(size) == math.MaxUint64 {
+		return nil
+	}
+	data := []byte{}
+	for r.err == nil {
+		chunkSize := r.uint32()
+		if chunkSize == 0 {
+			break
+		}
+		data = append(data, r.next(int(chunkSize))...)
+	}
+	return data
+}
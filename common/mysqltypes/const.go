@@ -0,0 +1,36 @@
+package mysqltypes
+
+// DefaultMaxPacketSize is the maximum payload size of a single packet (16MB - 1)
+const DefaultMaxPacketSize = 1<<24 - 1
+
+type PacketType byte
+
+func (t PacketType) Byte() byte { return byte(t) }
+
+// command phase packets
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_command_phase.html
+const (
+	ComQuit        PacketType = 0x01
+	ComInitDB      PacketType = 0x02
+	ComQuery       PacketType = 0x03
+	ComPing        PacketType = 0x0e
+	ComStmtPrepare PacketType = 0x16
+	ComStmtExecute PacketType = 0x17
+	ComStmtClose   PacketType = 0x19
+)
+
+// generic response packets
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_basic_response_packets.html
+const (
+	PacketOKType  PacketType = 0x00
+	PacketEOFType PacketType = 0xfe
+	PacketErrType PacketType = 0xff
+)
+
+// server error codes
+// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+const (
+	ErrAccessDeniedCode         uint16 = 1045
+	ErrTableAccessDeniedCode    uint16 = 1142
+	ErrSpecificAccessDeniedCode uint16 = 1227
+)
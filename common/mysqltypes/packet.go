@@ -0,0 +1,110 @@
+package mysqltypes
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// Packet represents a MySQL protocol packet
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_basic_packets.html
+type Packet struct {
+	// the sequence id of the packet
+	Seq uint8
+	// the payload of the packet, without the header
+	Frame []byte
+}
+
+// NewPacket creates a packet with sequence seq and payload frame
+func NewPacket(seq uint8, frame []byte) *Packet {
+	return &Packet{Seq: seq, Frame: frame}
+}
+
+func (p *Packet) Encode() []byte {
+	dst := make([]byte, len(p.Frame)+4)
+	dst[0] = byte(len(p.Frame))
+	dst[1] = byte(len(p.Frame) >> 8)
+	dst[2] = byte(len(p.Frame) >> 16)
+	dst[3] = p.Seq
+	_ = copy(dst[4:], p.Frame)
+	return dst
+}
+
+// Type returns the first byte of the payload.
+// The meaning of it depends on which phase of the protocol the packet belongs to.
+func (p *Packet) Type() PacketType {
+	if len(p.Frame) == 0 {
+		return 0
+	}
+	return PacketType(p.Frame[0])
+}
+
+func (p *Packet) Dump() { fmt.Println(hex.Dump(p.Encode())) }
+
+func Decode(data io.Reader) (*Packet, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(data, header[:]); err != nil {
+		return nil, err
+	}
+	pktLen := int(uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16)
+	p := &Packet{Seq: header[3], Frame: make([]byte, pktLen)}
+	if _, err := io.ReadFull(data, p.Frame); err != nil {
+		return nil, fmt.Errorf("failed reading packet frame, err=%v", err)
+	}
+	return p, nil
+}
+
+// NewErrPacket creates an ERR_Packet with the error code, sql state and message.
+// The sql state must have 5 characters, it defaults to HY000 (general error) otherwise.
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_basic_err_packet.html
+func NewErrPacket(seq uint8, code uint16, sqlState, message string) *Packet {
+	if len(sqlState) != 5 {
+		sqlState = "HY000"
+	}
+	frame := make([]byte, 9, 9+len(message))
+	frame[0] = PacketErrType.Byte()
+	binary.LittleEndian.PutUint16(frame[1:3], code)
+	frame[3] = '#'
+	_ = copy(frame[4:9], sqlState)
+	frame = append(frame, message...)
+	return NewPacket(seq, frame)
+}
+
+// DecodeQuery try to decode a packet to see if it's a COM_QUERY type, it returns
+// a nil query when it's another command type.
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_query.html
+func DecodeQuery(payload []byte) ([]byte, error) {
+	if len(payload) < 5 {
+		return nil, nil
+	}
+	if PacketType(payload[4]) != ComQuery {
+		return nil, nil
+	}
+	// header + command type
+	pos := 5
+	if len(payload) < pos+1 {
+		return nil, fmt.Errorf("COM_QUERY packet is too short, length=%v", len(payload))
+	}
+	if payload[pos] == 0x00 {
+		// query attributes without parameters: param count (0x00) + param set count (0x01)
+		pos += 2
+	}
+	if len(payload) <= pos {
+		return nil, fmt.Errorf("COM_QUERY packet is too short, length=%v", len(payload))
+	}
+	return payload[pos:], nil
+}
+
+// DecodeStmtPrepare try to decode a packet to see if it's a COM_STMT_PREPARE type, it returns
+// a nil query when it's another command type.
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_stmt_prepare.html
+func DecodeStmtPrepare(payload []byte) ([]byte, error) {
+	if len(payload) < 5 || PacketType(payload[4]) != ComStmtPrepare {
+		return nil, nil
+	}
+	if len(payload) == 5 {
+		return nil, fmt.Errorf("COM_STMT_PREPARE packet is too short, length=%v", len(payload))
+	}
+	return payload[5:], nil
+}
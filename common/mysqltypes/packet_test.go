@@ -0,0 +1,83 @@
+package mysqltypes
+
+import (
+	"testing"
+)
+
+func TestDecodeQuery(t *testing.T) {
+	for _, tt := range []struct {
+		msg     string
+		payload []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			msg:     "it should decode the query",
+			payload: NewPacket(0, append([]byte{ComQuery.Byte()}, "SELECT 1"...)).Encode(),
+			want:    "SELECT 1",
+		},
+		{
+			msg:     "it should decode the query with query attributes",
+			payload: NewPacket(0, append([]byte{ComQuery.Byte(), 0x00, 0x01}, "SELECT 1"...)).Encode(),
+			want:    "SELECT 1",
+		},
+		{
+			msg:     "it should not decode other commands",
+			payload: NewPacket(0, []byte{ComPing.Byte()}).Encode(),
+		},
+		{
+			msg:     "it should return an error when the query packet is too short",
+			payload: NewPacket(0, []byte{ComQuery.Byte()}).Encode(),
+			wantErr: true,
+		},
+		{
+			msg:     "it should return an error when the query attributes are incomplete",
+			payload: NewPacket(0, []byte{ComQuery.Byte(), 0x00}).Encode(),
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := DecodeQuery(tt.payload)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("expected error=%v, got=%v", tt.wantErr, err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("query does not match, want=%q, got=%q", tt.want, string(got))
+			}
+		})
+	}
+}
+
+func TestDecodeStmtPrepare(t *testing.T) {
+	for _, tt := range []struct {
+		msg     string
+		payload []byte
+		want    string
+		wantErr bool
+	}{
+		{
+			msg:     "it should decode the statement",
+			payload: NewPacket(0, append([]byte{ComStmtPrepare.Byte()}, "DELETE FROM t WHERE id = ?"...)).Encode(),
+			want:    "DELETE FROM t WHERE id = ?",
+		},
+		{
+			msg:     "it should not decode other commands",
+			payload: NewPacket(0, append([]byte{ComQuery.Byte()}, "SELECT 1"...)).Encode(),
+		},
+		{
+			msg:     "it should return an error when the packet is too short",
+			payload: NewPacket(0, []byte{ComStmtPrepare.Byte()}).Encode(),
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := DecodeStmtPrepare(tt.payload)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("expected error=%v, got=%v", tt.wantErr, err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("statement does not match, want=%q, got=%q", tt.want, string(got))
+			}
+		})
+	}
+}
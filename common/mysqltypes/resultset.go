@@ -0,0 +1,287 @@
+package mysqltypes
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ServerMoreResultsExists is the status flag set when another result set follows the current one
+// https://dev.mysql.com/doc/dev/mysql-server/latest/mysql__com_8h.html
+const ServerMoreResultsExists uint16 = 0x0008
+
+// nullValue is the marker of a null column in a text resultset row
+const nullValue byte = 0xfb
+
+// DecodeLengthEncodedInt decodes a length encoded integer returning the value and the number of bytes read
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_basic_dt_integers.html
+func DecodeLengthEncodedInt(data []byte) (uint64, int, error) {
+	if len(data) == 0 {
+		return 0, 0, fmt.Errorf("empty length encoded integer")
+	}
+	var size int
+	switch data[0] {
+	case 0xfc:
+		size = 2
+	case 0xfd:
+		size = 3
+	case 0xfe:
+		size = 8
+	case 0xfb, 0xff:
+		return 0, 0, fmt.Errorf("invalid length encoded integer prefix %X", data[0])
+	default:
+		return uint64(data[0]), 1, nil
+	}
+	if len(data) < size+1 {
+		return 0, 0, fmt.Errorf("length encoded integer out of range, size=%v, length=%v", size, len(data)-1)
+	}
+	var buf [8]byte
+	_ = copy(buf[:], data[1:size+1])
+	return binary.LittleEndian.Uint64(buf[:]), size + 1, nil
+}
+
+// decodeLengthEncodedString returns the string and the number of bytes read
+func decodeLengthEncodedString(data []byte) ([]byte, int, error) {
+	size, n, err := DecodeLengthEncodedInt(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if uint64(len(data)-n) < size {
+		return nil, 0, fmt.Errorf("length encoded string out of range, size=%v, length=%v", size, len(data)-n)
+	}
+	return data[n : n+int(size)], n + int(size), nil
+}
+
+// IsEOFPacket reports if the frame is an EOF_Packet or an OK_Packet with the EOF header,
+// both of them terminate a resultset. Rows starting with 0xfe have at least 9 bytes.
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_basic_eof_packet.html
+func IsEOFPacket(frame []byte) bool {
+	return len(frame) > 0 && len(frame) < 9 && PacketType(frame[0]) == PacketEOFType
+}
+
+// DecodeEOFStatus returns the status flags of an EOF_Packet or an OK_Packet with the EOF header
+func DecodeEOFStatus(frame []byte) uint16 {
+	// EOF_Packet: header(1), warnings(2), status(2)
+	if len(frame) == 5 {
+		return binary.LittleEndian.Uint16(frame[3:5])
+	}
+	// OK_Packet: header(1), affected rows(lenenc), last insert id(lenenc), status(2)
+	pos := 1
+	for i := 0; i < 2; i++ {
+		if pos >= len(frame) {
+			return 0
+		}
+		_, n, err := DecodeLengthEncodedInt(frame[pos:])
+		if err != nil {
+			return 0
+		}
+		pos += n
+	}
+	if len(frame) < pos+2 {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(frame[pos : pos+2])
+}
+
+// ColumnDefinition contains the names of a column of a result set,
+// the original names are empty for computed columns.
+type ColumnDefinition struct {
+	Schema   string
+	Table    string
+	OrgTable string
+	Name     string
+	OrgName  string
+}
+
+// DecodeColumnName returns the name of a Column Definition packet frame
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_query_response_text_resultset_column_definition.html
+func DecodeColumnName(frame []byte) (string, error) {
+	col, err := DecodeColumnDefinition(frame)
+	if err != nil {
+		return "", err
+	}
+	return col.Name, nil
+}
+
+// DecodeColumnDefinition decodes the names of a Column Definition packet frame
+func DecodeColumnDefinition(frame []byte) (*ColumnDefinition, error) {
+	// catalog, schema, table, org_table, name and org_name
+	var names [6]string
+	pos := 0
+	for i := range names {
+		val, n, err := decodeLengthEncodedString(frame[pos:])
+		if err != nil {
+			return nil, fmt.Errorf("failed decoding column definition, reason=%v", err)
+		}
+		names[i] = string(val)
+		pos += n
+	}
+	return &ColumnDefinition{
+		Schema:   names[1],
+		Table:    names[2],
+		OrgTable: names[3],
+		Name:     names[4],
+		OrgName:  names[5],
+	}, nil
+}
+
+// DecodeTextRow returns the column values of a text resultset row, null values are decoded as nil
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_query_response_text_resultset_row.html
+func DecodeTextRow(frame []byte, columns int) ([][]byte, error) {
+	values := make([][]byte, 0, columns)
+	pos := 0
+	for i := 0; i < columns; i++ {
+		if pos >= len(frame) {
+			return nil, fmt.Errorf("failed decoding row, missing column %v", i)
+		}
+		if frame[pos] == nullValue {
+			values = append(values, nil)
+			pos++
+			continue
+		}
+		val, n, err := decodeLengthEncodedString(frame[pos:])
+		if err != nil {
+			return nil, fmt.Errorf("failed decoding row, reason=%v", err)
+		}
+		values = append(values, val)
+		pos += n
+	}
+	return values, nil
+}
+
+// ResultPacketKind is the kind of a server packet of a COM_QUERY response
+type ResultPacketKind int
+
+const (
+	ResultPacketOther ResultPacketKind = iota
+	// ResultPacketColumns is the last column definition of a result set
+	ResultPacketColumns
+	ResultPacketRow
+	// ResultPacketEnd terminates a result set
+	ResultPacketEnd
+)
+
+type resultState int
+
+const (
+	resultStateIdle resultState = iota
+	resultStateHeader
+	resultStateColumns
+	resultStateColumnsEOF
+	resultStateRows
+)
+
+// TextResultSet tracks the packets of text result sets returned by COM_QUERY commands.
+// The binary protocol of prepared statements (COM_STMT_EXECUTE) is not decoded.
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_query_response_text_resultset.html
+type TextResultSet struct {
+	state       resultState
+	columns     int
+	names       []string
+	definitions []*ColumnDefinition
+}
+
+// OnClientPacket must be called with each packet sent by the client (header included),
+// it reports if the packet is a COM_QUERY command.
+func (s *TextResultSet) OnClientPacket(payload []byte) bool {
+	// command packets have the sequence 0
+	if len(payload) < 5 || payload[3] != 0x00 {
+		return false
+	}
+	if PacketType(payload[4]) == ComQuery {
+		s.state = resultStateHeader
+		return true
+	}
+	s.state = resultStateIdle
+	return false
+}
+
+// Columns returns the names of the columns of the current result set
+func (s *TextResultSet) Columns() []string { return s.names }
+
+// ColumnDefinitions returns the definitions of the columns of the current result set
+func (s *TextResultSet) ColumnDefinitions() []*ColumnDefinition { return s.definitions }
+
+// Next returns the kind of a packet sent by the server, the frame must not contain the header
+func (s *TextResultSet) Next(frame []byte) (ResultPacketKind, error) {
+	if len(frame) == 0 {
+		return ResultPacketOther, nil
+	}
+	switch s.state {
+	case resultStateHeader:
+		switch PacketType(frame[0]) {
+		case PacketOKType:
+			if DecodeEOFStatus(frame)&ServerMoreResultsExists == 0 {
+				s.state = resultStateIdle
+			}
+			return ResultPacketOther, nil
+		// error or a local infile request
+		case PacketErrType, 0xfb:
+			s.state = resultStateIdle
+			return ResultPacketOther, nil
+		}
+		columns, _, err := DecodeLengthEncodedInt(frame)
+		if err != nil {
+			return ResultPacketOther, err
+		}
+		s.columns, s.names, s.definitions = int(columns), nil, nil
+		s.state = resultStateColumns
+	case resultStateColumns:
+		col, err := DecodeColumnDefinition(frame)
+		if err != nil {
+			return ResultPacketOther, err
+		}
+		s.names = append(s.names, col.Name)
+		s.definitions = append(s.definitions, col)
+		if len(s.names) >= s.columns {
+			s.state = resultStateColumnsEOF
+			return ResultPacketColumns, nil
+		}
+	case resultStateColumnsEOF:
+		s.state = resultStateRows
+		// the server omits it when the client has the CLIENT_DEPRECATE_EOF capability
+		if IsEOFPacket(frame) {
+			return ResultPacketOther, nil
+		}
+		return s.Next(frame)
+	case resultStateRows:
+		switch {
+		case IsEOFPacket(frame):
+			s.state = resultStateIdle
+			if DecodeEOFStatus(frame)&ServerMoreResultsExists > 0 {
+				s.state = resultStateHeader
+			}
+			return ResultPacketEnd, nil
+		case PacketType(frame[0]) == PacketErrType:
+			s.state = resultStateIdle
+			return ResultPacketEnd, nil
+		}
+		return ResultPacketRow, nil
+	}
+	return ResultPacketOther, nil
+}
+
+// EncodeTextRow encodes the values of a text resultset row, nil values are encoded as null
+func EncodeTextRow(values [][]byte) []byte {
+	var frame []byte
+	for _, val := range values {
+		if val == nil {
+			frame = append(frame, nullValue)
+			continue
+		}
+		frame = appendLengthEncodedInt(frame, uint64(len(val)))
+		frame = append(frame, val...)
+	}
+	return frame
+}
+
+func appendLengthEncodedInt(dst []byte, v uint64) []byte {
+	switch {
+	case v < 0xfb:
+		return append(dst, byte(v))
+	case v <= 0xffff:
+		return append(dst, 0xfc, byte(v), byte(v>>8))
+	case v <= 0xffffff:
+		return append(dst, 0xfd, byte(v), byte(v>>8), byte(v>>16))
+	}
+	return binary.LittleEndian.AppendUint64(append(dst, 0xfe), v)
+}
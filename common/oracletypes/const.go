@@ -0,0 +1,70 @@
+package oracletypes
+
+type PacketType byte
+
+func (t PacketType) Byte() byte { return byte(t) }
+
+// TNS packet types
+const (
+	PacketConnectType   PacketType = 0x01
+	PacketAcceptType    PacketType = 0x02
+	PacketAckType       PacketType = 0x03
+	PacketRefuseType    PacketType = 0x04
+	PacketRedirectType  PacketType = 0x05
+	PacketDataType      PacketType = 0x06
+	PacketNullType      PacketType = 0x07
+	PacketAbortType     PacketType = 0x09
+	PacketResendType    PacketType = 0x0b
+	PacketMarkerType    PacketType = 0x0c
+	PacketAttentionType PacketType = 0x0d
+	PacketControlType   PacketType = 0x0e
+)
+
+// LargeSDUVersion is the minimum version of the protocol that encodes
+// the length of the packets with 4 bytes after the connection is accepted
+const LargeSDUVersion = 315
+
+// headerSize is the size of the header of the packets (length, checksum, type, flags, header checksum)
+const headerSize = 8
+
+// TTC message types
+const (
+	TTCProtocol         byte = 0x01
+	TTCDataTypes        byte = 0x02
+	TTCFunctionCall     byte = 0x03
+	TTCError            byte = 0x04
+	TTCReturnParameters byte = 0x08
+	TTCPiggyback        byte = 0x11
+)
+
+// TTC function codes
+const (
+	FuncExecute      byte = 0x5e
+	FuncAuthPhaseOne byte = 0x76
+	FuncAuthPhaseTwo byte = 0x73
+)
+
+// keys of the authentication (O5LOGON)
+const (
+	AuthSessionKey      = "AUTH_SESSKEY"
+	AuthVerifierData    = "AUTH_VFR_DATA"
+	AuthPassword        = "AUTH_PASSWORD"
+	AuthPBKDF2CSKSalt   = "AUTH_PBKDF2_CSK_SALT"
+	AuthPBKDF2VgenCount = "AUTH_PBKDF2_VGEN_COUNT"
+	AuthPBKDF2SderCount = "AUTH_PBKDF2_SDER_COUNT"
+	AuthPBKDF2SpeedyKey = "AUTH_PBKDF2_SPEEDY_KEY"
+)
+
+// types of the password verifiers, informed in the flag of AUTH_VFR_DATA
+const (
+	VerifierType11g uint32 = 6949
+	VerifierType12c uint32 = 18453
+)
+
+// CLR is the encoding of byte arrays, long arrays are encoded in chunks
+const (
+	clrMaxShortLength  = 0xfc
+	clrLongIndicator   = 0xfe
+	clrNullIndicator   = 0xff
+	clrLongChunkLength = 0x40
+)
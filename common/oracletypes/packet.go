@@ -0,0 +1,132 @@
+package oracletypes
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// DefaultMaxPacketSize is the maximum size of a packet with large session data units (2MB)
+const DefaultMaxPacketSize = 1 << 21
+
+// Packet represents a TNS packet
+type Packet struct {
+	// [length(2|4), checksum(0|2), type(1), flags(1), header checksum(2)]
+	header [headerSize]byte
+
+	// Payload of the packet, without the header
+	Data []byte
+
+	// the length is encoded with 4 bytes after negotiating a large session data unit
+	largeSDU bool
+}
+
+// NewPacket creates a packet with the type and payload data
+func NewPacket(typ PacketType, data []byte, largeSDU bool) *Packet {
+	p := &Packet{Data: data, largeSDU: largeSDU}
+	p.header[4] = typ.Byte()
+	return p
+}
+
+func (p *Packet) Type() PacketType { return PacketType(p.header[4]) }
+func (p *Packet) Flags() byte      { return p.header[5] }
+func (p *Packet) Dump()            { fmt.Println(hex.Dump(p.Encode())) }
+
+func (p *Packet) Encode() []byte {
+	dst := make([]byte, headerSize+len(p.Data))
+	copy(dst, p.header[:])
+	if p.largeSDU {
+		binary.BigEndian.PutUint32(dst[0:4], uint32(len(dst)))
+	} else {
+		binary.BigEndian.PutUint16(dst[0:2], uint16(len(dst)))
+		binary.BigEndian.PutUint16(dst[2:4], 0)
+	}
+	copy(dst[headerSize:], p.Data)
+	return dst
+}
+
+// Decode reads a packet from data, the largeSDU informs how the length of the packet is encoded
+func Decode(data io.Reader, largeSDU bool) (*Packet, error) {
+	p := &Packet{largeSDU: largeSDU}
+	if _, err := io.ReadFull(data, p.header[:]); err != nil {
+		return nil, err
+	}
+	pktLen := int(binary.BigEndian.Uint16(p.header[0:2]))
+	if largeSDU {
+		pktLen = int(binary.BigEndian.Uint32(p.header[0:4]))
+	}
+	if pktLen < headerSize || pktLen > DefaultMaxPacketSize {
+		return nil, fmt.Errorf("invalid packet length (%v)", pktLen)
+	}
+	p.Data = make([]byte, pktLen-headerSize)
+	if _, err := io.ReadFull(data, p.Data); err != nil {
+		return nil, fmt.Errorf("failed reading packet data, err=%v", err)
+	}
+	return p, nil
+}
+
+// Version returns the version of the protocol of CONNECT and ACCEPT packets
+func (p *Packet) Version() uint16 {
+	if len(p.Data) < 2 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(p.Data[0:2])
+}
+
+// connect packet offsets, the offset of the connect data is relative to the start of the packet
+// [version(2), version compatible(2), service options(2), session data unit(2), ..., connect data length(2), connect data offset(2), ...]
+const (
+	connectDataLengthPos = 16
+	connectDataOffsetPos = 18
+)
+
+// ConnectData returns the connect descriptor of a CONNECT packet. When the descriptor
+// is too long, the client sends it in the next DATA packet and it returns false.
+func (p *Packet) ConnectData() (string, bool) {
+	if p.Type() != PacketConnectType || len(p.Data) < connectDataOffsetPos+2 {
+		return "", false
+	}
+	size := int(binary.BigEndian.Uint16(p.Data[connectDataLengthPos:]))
+	offset := int(binary.BigEndian.Uint16(p.Data[connectDataOffsetPos:])) - headerSize
+	if offset < connectDataOffsetPos+2 || offset+size > len(p.Data) {
+		return "", false
+	}
+	return string(p.Data[offset : offset+size]), true
+}
+
+// SetConnectData replaces the connect descriptor sent in a CONNECT packet
+func (p *Packet) SetConnectData(data string) error {
+	if _, ok := p.ConnectData(); !ok {
+		return fmt.Errorf("the packet does not contain the connect data")
+	}
+	offset := int(binary.BigEndian.Uint16(p.Data[connectDataOffsetPos:])) - headerSize
+	p.Data = append(p.Data[:offset:offset], data...)
+	return p.SetConnectDataLength(len(data))
+}
+
+// SetConnectDataLength replaces the length of the connect descriptor of a CONNECT packet,
+// it's used when the descriptor is sent in the next DATA packet.
+func (p *Packet) SetConnectDataLength(size int) error {
+	if p.Type() != PacketConnectType || len(p.Data) < connectDataOffsetPos+2 {
+		return fmt.Errorf("the packet is not a connect packet")
+	}
+	binary.BigEndian.PutUint16(p.Data[connectDataLengthPos:], uint16(size))
+	return nil
+}
+
+var serviceNameRe = regexp.MustCompile(`(?i)\(\s*(SERVICE_NAME|SID)\s*=[^)]*\)`)
+
+// ReplaceServiceName replaces the service name or the SID of a connect descriptor
+func ReplaceServiceName(descriptor, serviceName string) string {
+	return serviceNameRe.ReplaceAllLiteralString(descriptor, fmt.Sprintf("(SERVICE_NAME=%s)", serviceName))
+}
+
+// TTCMessage returns the TTC message of a DATA packet, it skips the data flags
+func (p *Packet) TTCMessage() []byte {
+	if p.Type() != PacketDataType || len(p.Data) < 2 {
+		return nil
+	}
+	return p.Data[2:]
+}
@@ -0,0 +1,99 @@
+package oracletypes
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPacketEncodeDecode(t *testing.T) {
+	for _, tt := range []struct {
+		msg      string
+		largeSDU bool
+		want     []byte
+	}{
+		{
+			msg:  "it should encode the length with 2 bytes",
+			want: []byte{0x00, 0x0b, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01},
+		},
+		{
+			msg:      "it should encode the length with 4 bytes",
+			largeSDU: true,
+			want:     []byte{0x00, 0x00, 0x00, 0x0b, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01},
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got := NewPacket(PacketDataType, []byte{0x00, 0x00, 0x01}, tt.largeSDU).Encode()
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("encoded packet does not match, want=%X, got=%X", tt.want, got)
+			}
+			pkt, err := Decode(bytes.NewBuffer(got), tt.largeSDU)
+			if err != nil {
+				t.Fatalf("do not expect error when decoding packet, err=%v", err)
+			}
+			if pkt.Type() != PacketDataType || !bytes.Equal(pkt.TTCMessage(), []byte{0x01}) {
+				t.Errorf("decoded packet does not match, type=%X, ttc=%X", pkt.Type(), pkt.TTCMessage())
+			}
+		})
+	}
+}
+
+func newConnectPacket(descriptor string) *Packet {
+	data := make([]byte, 50)
+	// version 318
+	data[0], data[1] = 0x01, 0x3e
+	data[connectDataLengthPos+1] = byte(len(descriptor))
+	data[connectDataOffsetPos+1] = byte(len(data) + headerSize)
+	return NewPacket(PacketConnectType, append(data, descriptor...), false)
+}
+
+func TestConnectData(t *testing.T) {
+	descriptor := "(DESCRIPTION=(CONNECT_DATA=(SERVICE_NAME=noop)(CID=(PROGRAM=sqlplus)))(ADDRESS=(PROTOCOL=tcp)(HOST=127.0.0.1)(PORT=1522)))"
+	pkt := newConnectPacket(descriptor)
+	if pkt.Version() != 318 {
+		t.Errorf("expect to decode the version of the packet, got=%v", pkt.Version())
+	}
+	got, ok := pkt.ConnectData()
+	if !ok || got != descriptor {
+		t.Fatalf("expect to decode the connect data, got=%q", got)
+	}
+	newDescriptor := ReplaceServiceName(descriptor, "ORCLPDB1")
+	if err := pkt.SetConnectData(newDescriptor); err != nil {
+		t.Fatalf("do not expect error when setting connect data, err=%v", err)
+	}
+	pkt, _ = Decode(bytes.NewBuffer(pkt.Encode()), false)
+	got, _ = pkt.ConnectData()
+	want := "(DESCRIPTION=(CONNECT_DATA=(SERVICE_NAME=ORCLPDB1)(CID=(PROGRAM=sqlplus)))(ADDRESS=(PROTOCOL=tcp)(HOST=127.0.0.1)(PORT=1522)))"
+	if got != want {
+		t.Errorf("expect to replace the service name, want=%q, got=%q", want, got)
+	}
+}
+
+func TestReplaceServiceName(t *testing.T) {
+	for _, tt := range []struct {
+		msg        string
+		descriptor string
+		want       string
+	}{
+		{
+			msg:        "it should replace the sid with the service name",
+			descriptor: "(DESCRIPTION=(CONNECT_DATA=(SID=XE)))",
+			want:       "(DESCRIPTION=(CONNECT_DATA=(SERVICE_NAME=ORCLPDB1)))",
+		},
+		{
+			msg:        "it should replace the service name ignoring the case",
+			descriptor: "(description=(connect_data=(service_name = xe)(server=dedicated)))",
+			want:       "(description=(connect_data=(SERVICE_NAME=ORCLPDB1)(server=dedicated)))",
+		},
+		{
+			msg:        "it should not change descriptors without service names",
+			descriptor: "(DESCRIPTION=(CONNECT_DATA=(SERVER=DEDICATED)))",
+			want:       "(DESCRIPTION=(CONNECT_DATA=(SERVER=DEDICATED)))",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			if got := ReplaceServiceName(tt.descriptor, "ORCLPDB1"); got != tt.want {
+				t.Errorf("want=%q, got=%q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package oracletypes
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+var sqlKeywords = []string{
+	"SELECT", "INSERT", "UPDATE", "DELETE", "MERGE", "WITH", "BEGIN", "DECLARE", "CALL",
+	"CREATE", "ALTER", "DROP", "TRUNCATE", "GRANT", "REVOKE", "COMMENT", "RENAME",
+	"COMMIT", "ROLLBACK", "SAVEPOINT", "EXPLAIN", "LOCK", "SET", "PURGE", "ANALYZE",
+}
+
+// DecodeSQLStatement returns the statement of an execute function call (OALL8) of a DATA packet.
+// The parameters of the function call vary with the version of the clients, thus the statement
+// is found by its encoding (CLR) followed by a SQL keyword. It returns an empty string when the
+// packet is not an execute function call, e.g.: a fetch of the rows of a cursor.
+func DecodeSQLStatement(payload []byte) string {
+	// the type of the packet is in the same position for both header formats
+	if len(payload) < headerSize+2 || PacketType(payload[4]) != PacketDataType {
+		return ""
+	}
+	msg := payload[headerSize+2:]
+	pos := findFunctionCall(msg, FuncExecute)
+	if pos == -1 {
+		return ""
+	}
+	for i := pos + 2; i < len(msg); i++ {
+		if msg[i] == 0 || msg[i] == clrNullIndicator {
+			continue
+		}
+		r := &ttcReader{data: msg, pos: i}
+		data, err := r.clr()
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		if stmt := string(data); isSQLStatement(stmt) {
+			return stmt
+		}
+	}
+	return ""
+}
+
+func isSQLStatement(stmt string) bool {
+	if !utf8.ValidString(stmt) {
+		return false
+	}
+	for _, r := range stmt {
+		if r < 0x20 && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+	}
+	stmt = strings.ToUpper(strings.TrimLeft(stmt, " \t\r\n("))
+	for _, keyword := range sqlKeywords {
+		if strings.HasPrefix(stmt, keyword) {
+			rest := stmt[len(keyword):]
+			if rest == "" || !isIdentifierChar(rest[0]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == '$' || c == '#' || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
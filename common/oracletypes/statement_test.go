@@ -0,0 +1,57 @@
+package oracletypes
+
+import (
+	"strings"
+	"testing"
+)
+
+func newExecutePacket(prefix []byte, stmt string) []byte {
+	// function call, sequence, options, cursor, pointer, length of the statement, ...
+	msg := append(prefix, TTCFunctionCall, FuncExecute, 0x05, 0x02, 0x80, 0x21, 0x00, 0x01)
+	msg = appendUB4(msg, uint32(len(stmt)))
+	msg = append(msg, 0x01, 0x01, 0x0d, 0x00, 0x00, 0x00, 0x00)
+	msg = appendCLR(msg, []byte(stmt))
+	msg = append(msg, 0x01, 0x01, 0x00, 0x00)
+	return NewPacket(PacketDataType, append([]byte{0x00, 0x00}, msg...), true).Encode()
+}
+
+func TestDecodeSQLStatement(t *testing.T) {
+	longStmt := "SELECT * FROM employees WHERE " + strings.Repeat("department_id = 10 OR ", 20) + "1 = 1"
+	for _, tt := range []struct {
+		msg     string
+		payload []byte
+		want    string
+	}{
+		{
+			msg:     "it should decode the statement of an execute function call",
+			payload: newExecutePacket(nil, "select sysdate from dual"),
+			want:    "select sysdate from dual",
+		},
+		{
+			msg:     "it should decode a long statement encoded in chunks",
+			payload: newExecutePacket(nil, longStmt),
+			want:    longStmt,
+		},
+		{
+			msg:     "it should decode the statement of an execute function call after piggyback messages",
+			payload: newExecutePacket([]byte{TTCPiggyback, 0x69, 0x00, 0x01, 0x01, 0x01}, "BEGIN dbms_output.enable(NULL); END;"),
+			want:    "BEGIN dbms_output.enable(NULL); END;",
+		},
+		{
+			msg:     "it should ignore packets that are not execute function calls",
+			payload: NewPacket(PacketDataType, []byte{0x00, 0x00, TTCFunctionCall, FuncAuthPhaseOne, 0x01}, true).Encode(),
+			want:    "",
+		},
+		{
+			msg:     "it should ignore other types of packets",
+			payload: NewPacket(PacketMarkerType, []byte{0x01, 0x00, 0x02}, true).Encode(),
+			want:    "",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			if got := DecodeSQLStatement(tt.payload); got != tt.want {
+				t.Errorf("want=%q, got=%q", tt.want, got)
+			}
+		})
+	}
+}
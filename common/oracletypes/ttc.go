@@ -0,0 +1,308 @@
+package oracletypes
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// KeyVal is a key value pair of the authentication messages
+type KeyVal struct {
+	Key   string
+	Value []byte
+	Flag  uint32
+}
+
+type ttcReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *ttcReader) byte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, fmt.Errorf("unexpected end of message")
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *ttcReader) next(size int) ([]byte, error) {
+	if size < 0 || r.pos+size > len(r.data) {
+		return nil, fmt.Errorf("unexpected end of message")
+	}
+	b := r.data[r.pos : r.pos+size]
+	r.pos += size
+	return b, nil
+}
+
+// ub4 reads a compressed integer, the first byte is the size of the number
+func (r *ttcReader) ub4() (uint32, error) {
+	size, err := r.byte()
+	if err != nil {
+		return 0, err
+	}
+	// the most significant bit is the sign
+	data, err := r.next(int(size & 0x7f))
+	if err != nil || len(data) > 4 {
+		return 0, fmt.Errorf("invalid compressed integer")
+	}
+	var val uint32
+	for _, b := range data {
+		val = val<<8 | uint32(b)
+	}
+	return val, nil
+}
+
+func (r *ttcReader) clr() ([]byte, error) {
+	size, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	switch size {
+	case 0, clrNullIndicator:
+		return nil, nil
+	case clrLongIndicator:
+		var data []byte
+		for {
+			chunkSize, err := r.byte()
+			if err != nil {
+				return nil, err
+			}
+			if chunkSize == 0 {
+				return data, nil
+			}
+			chunk, err := r.next(int(chunkSize))
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, chunk...)
+		}
+	}
+	return r.next(int(size))
+}
+
+func (r *ttcReader) keyVal() (kv KeyVal, err error) {
+	var size uint32
+	if size, err = r.ub4(); err != nil {
+		return
+	}
+	if size > 0 {
+		key, err := r.clr()
+		if err != nil {
+			return kv, err
+		}
+		kv.Key = string(key)
+	}
+	if size, err = r.ub4(); err != nil {
+		return
+	}
+	if size > 0 {
+		if kv.Value, err = r.clr(); err != nil {
+			return
+		}
+	}
+	kv.Flag, err = r.ub4()
+	return
+}
+
+func appendUB4(dst []byte, val uint32) []byte {
+	if val == 0 {
+		return append(dst, 0)
+	}
+	var data []byte
+	for ; val > 0; val >>= 8 {
+		data = append([]byte{byte(val)}, data...)
+	}
+	return append(append(dst, byte(len(data))), data...)
+}
+
+func appendCLR(dst, data []byte) []byte {
+	if len(data) <= clrMaxShortLength {
+		return append(append(dst, byte(len(data))), data...)
+	}
+	dst = append(dst, clrLongIndicator)
+	for len(data) > 0 {
+		chunk := data[:min(len(data), clrLongChunkLength)]
+		dst = append(append(dst, byte(len(chunk))), chunk...)
+		data = data[len(chunk):]
+	}
+	return append(dst, 0)
+}
+
+func appendKeyVal(dst []byte, kv KeyVal) []byte {
+	for _, data := range [][]byte{[]byte(kv.Key), kv.Value} {
+		if len(data) == 0 {
+			dst = append(dst, 0)
+			continue
+		}
+		dst = appendUB4(dst, uint32(len(data)))
+		dst = appendCLR(dst, data)
+	}
+	return appendUB4(dst, kv.Flag)
+}
+
+// KeyVals is a list of key value pairs
+type KeyVals []KeyVal
+
+// Get returns the key value pair of a key
+func (kvs KeyVals) Get(key string) (*KeyVal, bool) {
+	for i := range kvs {
+		if kvs[i].Key == key {
+			return &kvs[i], true
+		}
+	}
+	return nil, false
+}
+
+// AuthRequest is the authentication function call of the client, the
+// authentication is performed in two phases with the same structure.
+type AuthRequest struct {
+	Function byte
+	Seq      byte
+	Username string
+	Mode     uint32
+	KeyVals  KeyVals
+
+	// some clients encode the username as CLR
+	clrUsername bool
+	// the piggyback messages sent before the function call and the messages after it
+	prefix []byte
+	suffix []byte
+}
+
+// DecodeAuthRequest decodes the authentication function call of a TTC message,
+// it returns nil when the message is not an authentication function call.
+func DecodeAuthRequest(msg []byte) (*AuthRequest, error) {
+	pos := findFunctionCall(msg, FuncAuthPhaseOne, FuncAuthPhaseTwo)
+	if pos == -1 {
+		return nil, nil
+	}
+	a := &AuthRequest{Function: msg[pos+1], prefix: msg[:pos]}
+	r := &ttcReader{data: msg, pos: pos + 2}
+	var err error
+	if a.Seq, err = r.byte(); err != nil {
+		return nil, err
+	}
+	// pointer of the username
+	if _, err := r.byte(); err != nil {
+		return nil, err
+	}
+	userLen, err := r.ub4()
+	if err != nil {
+		return nil, err
+	}
+	if a.Mode, err = r.ub4(); err != nil {
+		return nil, err
+	}
+	// pointer of the key value pairs
+	if _, err := r.byte(); err != nil {
+		return nil, err
+	}
+	count, err := r.ub4()
+	if err != nil {
+		return nil, err
+	}
+	// pointers of the output key value pairs
+	if _, err := r.next(2); err != nil {
+		return nil, err
+	}
+	if userLen > 0 {
+		if r.pos < len(msg) && msg[r.pos] == byte(userLen) && userLen < 0x20 {
+			a.clrUsername = true
+			r.pos++
+		}
+		username, err := r.next(int(userLen))
+		if err != nil {
+			return nil, err
+		}
+		a.Username = string(username)
+	}
+	for i := 0; i < int(count); i++ {
+		kv, err := r.keyVal()
+		if err != nil {
+			return nil, fmt.Errorf("failed decoding authentication key value pairs: %v", err)
+		}
+		a.KeyVals = append(a.KeyVals, kv)
+	}
+	a.suffix = msg[r.pos:]
+	return a, nil
+}
+
+// Encode returns the TTC message with the function call
+func (a *AuthRequest) Encode() []byte {
+	msg := append([]byte{}, a.prefix...)
+	msg = append(msg, TTCFunctionCall, a.Function, a.Seq)
+	if a.Username != "" {
+		msg = append(msg, 1)
+		msg = appendUB4(msg, uint32(len(a.Username)))
+	} else {
+		msg = append(msg, 0, 0)
+	}
+	msg = appendUB4(msg, a.Mode)
+	msg = append(msg, 1)
+	msg = appendUB4(msg, uint32(len(a.KeyVals)))
+	msg = append(msg, 1, 1)
+	if a.clrUsername {
+		msg = append(msg, byte(len(a.Username)))
+	}
+	msg = append(msg, a.Username...)
+	for _, kv := range a.KeyVals {
+		msg = appendKeyVal(msg, kv)
+	}
+	return append(msg, a.suffix...)
+}
+
+// ReturnParameters are the key value pairs returned by the server in the first phase of the authentication
+type ReturnParameters struct {
+	KeyVals KeyVals
+
+	suffix []byte
+}
+
+// DecodeReturnParameters decodes a TTC message with return parameters,
+// it returns nil when the message has other type.
+func DecodeReturnParameters(msg []byte) (*ReturnParameters, error) {
+	if len(msg) == 0 || msg[0] != TTCReturnParameters {
+		return nil, nil
+	}
+	r := &ttcReader{data: msg, pos: 1}
+	count, err := r.ub4()
+	if err != nil {
+		return nil, err
+	}
+	p := &ReturnParameters{}
+	for i := 0; i < int(count); i++ {
+		kv, err := r.keyVal()
+		if err != nil {
+			return nil, fmt.Errorf("failed decoding return parameters: %v", err)
+		}
+		p.KeyVals = append(p.KeyVals, kv)
+	}
+	p.suffix = msg[r.pos:]
+	return p, nil
+}
+
+// Encode returns the TTC message with the return parameters
+func (p *ReturnParameters) Encode() []byte {
+	msg := appendUB4([]byte{TTCReturnParameters}, uint32(len(p.KeyVals)))
+	for _, kv := range p.KeyVals {
+		msg = appendKeyVal(msg, kv)
+	}
+	return append(msg, p.suffix...)
+}
+
+// findFunctionCall returns the position of a function call in a TTC message,
+// the function call could be preceded by piggyback messages.
+func findFunctionCall(msg []byte, functions ...byte) int {
+	for _, fn := range functions {
+		if len(msg) >= 2 && msg[0] == TTCFunctionCall && msg[1] == fn {
+			return 0
+		}
+		if len(msg) > 0 && msg[0] == TTCPiggyback {
+			if pos := bytes.Index(msg, []byte{TTCFunctionCall, fn}); pos > 0 {
+				return pos
+			}
+		}
+	}
+	return -1
+}
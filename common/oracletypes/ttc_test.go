@@ -0,0 +1,95 @@
+package oracletypes
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAuthRequestEncodeDecode(t *testing.T) {
+	for _, tt := range []struct {
+		msg  string
+		want *AuthRequest
+	}{
+		{
+			msg: "it should decode the first phase of the authentication",
+			want: &AuthRequest{
+				Function: FuncAuthPhaseOne,
+				Seq:      1,
+				Username: "noop",
+				Mode:     1,
+				KeyVals: KeyVals{
+					{Key: "AUTH_TERMINAL", Value: []byte("unknown")},
+					{Key: "AUTH_PROGRAM_NM", Value: []byte("sqlplus")},
+					{Key: "AUTH_PID", Value: []byte("1234")},
+				},
+			},
+		},
+		{
+			msg: "it should decode the second phase of the authentication with a username encoded as clr",
+			want: &AuthRequest{
+				Function: FuncAuthPhaseTwo,
+				Seq:      2,
+				Username: "noop",
+				Mode:     0x101,
+				KeyVals: KeyVals{
+					{Key: AuthSessionKey, Value: []byte(strings.Repeat("A", 64)), Flag: 1},
+					{Key: AuthPassword, Value: []byte(strings.Repeat("B", 64))},
+					{Key: "AUTH_ALTER_SESSION", Value: []byte(strings.Repeat("C", 300)), Flag: 1},
+				},
+				clrUsername: true,
+				prefix:      []byte{TTCPiggyback, 0x69, 0x00},
+			},
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := DecodeAuthRequest(tt.want.Encode())
+			if err != nil {
+				t.Fatalf("do not expect error when decoding authentication, err=%v", err)
+			}
+			if got == nil {
+				t.Fatal("expect to decode the authentication")
+			}
+			if !bytes.Equal(got.Encode(), tt.want.Encode()) {
+				t.Errorf("expect to re-encode the same message, want=%X, got=%X", tt.want.Encode(), got.Encode())
+			}
+			if got.Username != tt.want.Username || got.Mode != tt.want.Mode || len(got.KeyVals) != len(tt.want.KeyVals) {
+				t.Errorf("decoded values does not match [username, mode, keyvals], got=[%s %v %v]",
+					got.Username, got.Mode, len(got.KeyVals))
+			}
+			kv, ok := got.KeyVals.Get(tt.want.KeyVals[0].Key)
+			if !ok || !bytes.Equal(kv.Value, tt.want.KeyVals[0].Value) || kv.Flag != tt.want.KeyVals[0].Flag {
+				t.Errorf("expect to decode the key value pairs, got=%+v", kv)
+			}
+		})
+	}
+}
+
+func TestDecodeAuthRequestOtherMessages(t *testing.T) {
+	got, err := DecodeAuthRequest([]byte{TTCFunctionCall, FuncExecute, 0x01})
+	if got != nil || err != nil {
+		t.Errorf("expect to ignore other function calls, got=%+v, err=%v", got, err)
+	}
+}
+
+func TestReturnParametersEncodeDecode(t *testing.T) {
+	want := &ReturnParameters{
+		KeyVals: KeyVals{
+			{Key: AuthSessionKey, Value: []byte(strings.Repeat("A", 64))},
+			{Key: AuthVerifierData, Value: []byte("3F2A"), Flag: VerifierType12c},
+			{Key: AuthPBKDF2VgenCount, Value: []byte("4096")},
+		},
+		suffix: []byte{TTCError, 0x01},
+	}
+	got, err := DecodeReturnParameters(want.Encode())
+	if err != nil {
+		t.Fatalf("do not expect error when decoding return parameters, err=%v", err)
+	}
+	if !bytes.Equal(got.Encode(), want.Encode()) {
+		t.Errorf("expect to re-encode the same message, want=%X, got=%X", want.Encode(), got.Encode())
+	}
+	kv, ok := got.KeyVals.Get(AuthVerifierData)
+	if !ok || kv.Flag != VerifierType12c || string(kv.Value) != "3F2A" {
+		t.Errorf("expect to decode the verifier data, got=%+v", kv)
+	}
+}
@@ -31,6 +31,39 @@ const (
 	ServerBackendKeyData PacketType = 'K'
 )
 
+// server
+const (
+	ServerCommandComplete PacketType = 'C'
+	ServerDataRow         PacketType = 'D'
+	ServerErrorResponse   PacketType = 'E'
+	ServerRowDescription  PacketType = 'T'
+	ServerReadyForQuery   PacketType = 'Z'
+)
+
+// transaction status indicator of the ReadyForQuery packet
+const (
+	ServerIdle                   byte = 'I'
+	ServerTransactionBlock       byte = 'T'
+	ServerFailedTransactionBlock byte = 'E'
+)
+
+// error response fields and codes
+// https://www.postgresql.org/docs/current/protocol-error-fields.html
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	ErrorFieldSeverity             byte = 'S'
+	ErrorFieldSeverityNonLocalized byte = 'V'
+	ErrorFieldCode                 byte = 'C'
+	ErrorFieldMessage              byte = 'M'
+
+	LevelError = "ERROR"
+
+	ErrCodeInsufficientPrivilege = "42501"
+	ErrCodeTooManyConnections    = "53300"
+	ErrCodeConnectionFailure     = "08006"
+	ErrCodeFeatureNotSupported   = "0A000"
+)
+
 const ClientCancelRequestMessage uint32 = 80877102
 
 var clientPacketType = map[PacketType]string{
@@ -0,0 +1,244 @@
+package pgtypes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// object identifiers of the types decoded from parameters in binary format
+// https://github.com/postgres/postgres/blob/master/src/include/catalog/pg_type.dat
+const (
+	oidBool    uint32 = 16
+	oidInt8    uint32 = 20
+	oidInt2    uint32 = 21
+	oidInt4    uint32 = 23
+	oidText    uint32 = 25
+	oidFloat4  uint32 = 700
+	oidFloat8  uint32 = 701
+	oidVarchar uint32 = 1043
+	oidUUID    uint32 = 2950
+)
+
+const (
+	FormatText   int16 = 0
+	FormatBinary int16 = 1
+)
+
+// Parse is the message that creates a prepared statement in the extended query protocol
+// https://www.postgresql.org/docs/current/protocol-message-formats.html#PROTOCOL-MESSAGE-FORMATS-PARSE
+type Parse struct {
+	Statement string
+	Query     string
+	ParamOIDs []uint32
+}
+
+// Bind is the message that creates a portal binding parameters to a prepared statement
+// https://www.postgresql.org/docs/current/protocol-message-formats.html#PROTOCOL-MESSAGE-FORMATS-BIND
+type Bind struct {
+	Portal        string
+	Statement     string
+	ParamFormats  []int16
+	Params        [][]byte
+	ResultFormats []int16
+}
+
+// Execute is the message that executes a portal
+// https://www.postgresql.org/docs/current/protocol-message-formats.html#PROTOCOL-MESSAGE-FORMATS-EXECUTE
+type Execute struct {
+	Portal  string
+	MaxRows uint32
+}
+
+// Close is the message that closes a prepared statement ('S') or a portal ('P')
+// https://www.postgresql.org/docs/current/protocol-message-formats.html#PROTOCOL-MESSAGE-FORMATS-CLOSE
+type Close struct {
+	Type byte
+	Name string
+}
+
+// frameReader reads the fields of a packet frame
+type frameReader struct {
+	frame []byte
+	pos   int
+	err   error
+}
+
+func (r *frameReader) cstring() string {
+	if r.err != nil {
+		return ""
+	}
+	idx := bytes.IndexByte(r.frame[r.pos:], 0x00)
+	if idx == -1 {
+		r.err = fmt.Errorf("missing string terminator at position %v", r.pos)
+		return ""
+	}
+	v := string(r.frame[r.pos : r.pos+idx])
+	r.pos += idx + 1
+	return v
+}
+
+func (r *frameReader) next(size int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if size < 0 || len(r.frame)-r.pos < size {
+		r.err = fmt.Errorf("unexpected end of frame, position=%v, size=%v, length=%v", r.pos, size, len(r.frame))
+		return nil
+	}
+	v := r.frame[r.pos : r.pos+size]
+	r.pos += size
+	return v
+}
+
+func (r *frameReader) byte() byte {
+	if v := r.next(1); v != nil {
+		return v[0]
+	}
+	return 0
+}
+
+func (r *frameReader) int16() int16 {
+	if v := r.next(2); v != nil {
+		return int16(binary.BigEndian.Uint16(v))
+	}
+	return 0
+}
+
+func (r *frameReader) int32() int32 {
+	if v := r.next(4); v != nil {
+		return int32(binary.BigEndian.Uint32(v))
+	}
+	return 0
+}
+
+func (r *frameReader) int16List() []int16 {
+	size := r.int16()
+	if size < 0 {
+		r.err = fmt.Errorf("negative list size %v", size)
+	}
+	var items []int16
+	for i := 0; i < int(size) && r.err == nil; i++ {
+		items = append(items, r.int16())
+	}
+	return items
+}
+
+// DecodeParse decodes the frame of a Parse packet
+func DecodeParse(frame []byte) (*Parse, error) {
+	r := &frameReader{frame: frame}
+	msg := &Parse{Statement: r.cstring(), Query: r.cstring()}
+	size := r.int16()
+	for i := 0; i < int(size) && r.err == nil; i++ {
+		msg.ParamOIDs = append(msg.ParamOIDs, uint32(r.int32()))
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("failed decoding parse message, reason=%v", r.err)
+	}
+	return msg, nil
+}
+
+// DecodeBind decodes the frame of a Bind packet, null parameters are decoded as nil
+func DecodeBind(frame []byte) (*Bind, error) {
+	r := &frameReader{frame: frame}
+	msg := &Bind{Portal: r.cstring(), Statement: r.cstring()}
+	msg.ParamFormats = r.int16List()
+	size := r.int16()
+	for i := 0; i < int(size) && r.err == nil; i++ {
+		paramSize := r.int32()
+		if paramSize == -1 {
+			msg.Params = append(msg.Params, nil)
+			continue
+		}
+		msg.Params = append(msg.Params, r.next(int(paramSize)))
+	}
+	msg.ResultFormats = r.int16List()
+	if r.err != nil {
+		return nil, fmt.Errorf("failed decoding bind message, reason=%v", r.err)
+	}
+	return msg, nil
+}
+
+// DecodeExecute decodes the frame of an Execute packet
+func DecodeExecute(frame []byte) (*Execute, error) {
+	r := &frameReader{frame: frame}
+	msg := &Execute{Portal: r.cstring(), MaxRows: uint32(r.int32())}
+	if r.err != nil {
+		return nil, fmt.Errorf("failed decoding execute message, reason=%v", r.err)
+	}
+	return msg, nil
+}
+
+// DecodeClose decodes the frame of a Close packet
+func DecodeClose(frame []byte) (*Close, error) {
+	r := &frameReader{frame: frame}
+	msg := &Close{Type: r.byte(), Name: r.cstring()}
+	if r.err != nil {
+		return nil, fmt.Errorf("failed decoding close message, reason=%v", r.err)
+	}
+	return msg, nil
+}
+
+// ParamFormat returns the format code of the parameter at idx
+func (b *Bind) ParamFormat(idx int) int16 {
+	switch len(b.ParamFormats) {
+	case 0:
+		return FormatText
+	case 1:
+		return b.ParamFormats[0]
+	}
+	if idx < len(b.ParamFormats) {
+		return b.ParamFormats[idx]
+	}
+	return FormatText
+}
+
+// ParamValues returns the parameters as text, the type of the parameters
+// in binary format is obtained from the object identifiers of the prepared statement.
+// Null parameters are returned as nil and unknown binary types are hex encoded (\x<hex>).
+func (b *Bind) ParamValues(oids []uint32) []*string {
+	values := make([]*string, len(b.Params))
+	for i, param := range b.Params {
+		if param == nil {
+			continue
+		}
+		var v string
+		if b.ParamFormat(i) == FormatText {
+			v = string(param)
+		} else {
+			var oid uint32
+			if i < len(oids) {
+				oid = oids[i]
+			}
+			v = decodeBinaryParam(oid, param)
+		}
+		values[i] = &v
+	}
+	return values
+}
+
+func decodeBinaryParam(oid uint32, data []byte) string {
+	switch {
+	case oid == oidBool && len(data) == 1:
+		return strconv.FormatBool(data[0] == 1)
+	case oid == oidInt2 && len(data) == 2:
+		return strconv.FormatInt(int64(int16(binary.BigEndian.Uint16(data))), 10)
+	case oid == oidInt4 && len(data) == 4:
+		return strconv.FormatInt(int64(int32(binary.BigEndian.Uint32(data))), 10)
+	case oid == oidInt8 && len(data) == 8:
+		return strconv.FormatInt(int64(binary.BigEndian.Uint64(data)), 10)
+	case oid == oidFloat4 && len(data) == 4:
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.BigEndian.Uint32(data))), 'g', -1, 32)
+	case oid == oidFloat8 && len(data) == 8:
+		return strconv.FormatFloat(math.Float64frombits(binary.BigEndian.Uint64(data)), 'g', -1, 64)
+	case oid == oidText || oid == oidVarchar:
+		return string(data)
+	case oid == oidUUID && len(data) == 16:
+		h := hex.EncodeToString(data)
+		return fmt.Sprintf("%s-%s-%s-%s-%s", h[0:8], h[8:12], h[12:16], h[16:20], h[20:])
+	}
+	return `\x` + hex.EncodeToString(data)
+}
@@ -15,6 +15,41 @@ type Packet struct {
 	frame  []byte
 }
 
+// NewPacket creates a typed packet with the frame as payload
+func NewPacket(typ PacketType, frame []byte) *Packet {
+	t := typ.Byte()
+	pkt := &Packet{typ: &t, frame: frame}
+	binary.BigEndian.PutUint32(pkt.header[:], uint32(len(frame)+4))
+	return pkt
+}
+
+// NewErrorResponse creates an ErrorResponse packet with severity ERROR
+// https://www.postgresql.org/docs/current/protocol-message-formats.html#PROTOCOL-MESSAGE-FORMATS-ERRORRESPONSE
+func NewErrorResponse(code, format string, a ...any) *Packet {
+	var frame []byte
+	for _, field := range []struct {
+		typ byte
+		val string
+	}{
+		{ErrorFieldSeverity, LevelError},
+		{ErrorFieldSeverityNonLocalized, LevelError},
+		{ErrorFieldCode, code},
+		{ErrorFieldMessage, fmt.Sprintf(format, a...)},
+	} {
+		frame = append(frame, field.typ)
+		frame = append(frame, field.val...)
+		frame = append(frame, 0x00)
+	}
+	// the terminator of the field list
+	frame = append(frame, 0x00)
+	return NewPacket(ServerErrorResponse, frame)
+}
+
+// NewReadyForQuery creates a ReadyForQuery packet with the transaction status indicator
+func NewReadyForQuery(txStatus byte) *Packet {
+	return NewPacket(ServerReadyForQuery, []byte{txStatus})
+}
+
 func (p *Packet) Encode() []byte {
 	dst := make([]byte, p.HeaderLength())
 	_ = copy(dst, append(p.header[:], p.frame...))
@@ -52,6 +87,25 @@ func (p *Packet) IsCancelRequest() bool {
 	return false
 }
 
+// StartupParameters returns the parameters of a StartupMessage packet, e.g.: user, database
+// https://www.postgresql.org/docs/current/protocol-message-formats.html#PROTOCOL-MESSAGE-FORMATS-STARTUPMESSAGE
+func (p *Packet) StartupParameters() map[string]string {
+	params := map[string]string{}
+	if p.typ != nil || len(p.frame) < 4 {
+		return params
+	}
+	// skip the protocol version
+	r := &frameReader{frame: p.frame[4:]}
+	for r.err == nil && r.pos < len(r.frame) {
+		key := r.cstring()
+		if key == "" || r.err != nil {
+			break
+		}
+		params[key] = r.cstring()
+	}
+	return params
+}
+
 func Decode(data io.Reader) (*Packet, error) {
 	typ := make([]byte, 1)
 	_, err := data.Read(typ)
@@ -0,0 +1,86 @@
+package pgtypes
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// FieldDescription is a column of a RowDescription packet
+type FieldDescription struct {
+	Name string
+	// TableOID is zero when the column isn't a column of a table
+	TableOID uint32
+	// ColumnAttr is the attribute number (pg_attribute.attnum) of the column in the table
+	ColumnAttr int16
+	TypeOID    uint32
+	Format     int16
+}
+
+// DecodeRowDescription returns the column names of a RowDescription packet frame
+// https://www.postgresql.org/docs/current/protocol-message-formats.html#PROTOCOL-MESSAGE-FORMATS-ROWDESCRIPTION
+func DecodeRowDescription(frame []byte) ([]string, error) {
+	fields, err := DecodeRowDescriptionFields(frame)
+	if err != nil {
+		return nil, err
+	}
+	columns := []string{}
+	for _, f := range fields {
+		columns = append(columns, f.Name)
+	}
+	return columns, nil
+}
+
+// DecodeRowDescriptionFields returns the fields of a RowDescription packet frame
+func DecodeRowDescriptionFields(frame []byte) ([]FieldDescription, error) {
+	r := &frameReader{frame: frame}
+	size := r.int16()
+	fields := []FieldDescription{}
+	for i := 0; i < int(size) && r.err == nil; i++ {
+		f := FieldDescription{Name: r.cstring()}
+		f.TableOID = uint32(r.int32())
+		f.ColumnAttr = r.int16()
+		f.TypeOID = uint32(r.int32())
+		// type size(2), type modifier(4)
+		_ = r.next(6)
+		f.Format = r.int16()
+		fields = append(fields, f)
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("failed decoding row description message, reason=%v", r.err)
+	}
+	return fields, nil
+}
+
+// DecodeDataRow returns the column values of a DataRow packet frame, null values are decoded as nil
+// https://www.postgresql.org/docs/current/protocol-message-formats.html#PROTOCOL-MESSAGE-FORMATS-DATAROW
+func DecodeDataRow(frame []byte) ([][]byte, error) {
+	r := &frameReader{frame: frame}
+	size := r.int16()
+	values := [][]byte{}
+	for i := 0; i < int(size) && r.err == nil; i++ {
+		valSize := r.int32()
+		if valSize == -1 {
+			values = append(values, nil)
+			continue
+		}
+		values = append(values, r.next(int(valSize)))
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("failed decoding data row message, reason=%v", r.err)
+	}
+	return values, nil
+}
+
+// EncodeDataRow encodes the column values of a DataRow packet frame, nil values are encoded as null
+func EncodeDataRow(values [][]byte) []byte {
+	frame := binary.BigEndian.AppendUint16(nil, uint16(len(values)))
+	for _, val := range values {
+		if val == nil {
+			frame = binary.BigEndian.AppendUint32(frame, 0xFFFFFFFF)
+			continue
+		}
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(val)))
+		frame = append(frame, val...)
+	}
+	return frame
+}
@@ -12,10 +12,14 @@ const (
 	TerminalResizeTTY  = "AgentTerminalResizeTTY"
 	TerminalClose      = "AgentTerminalClose"
 
-	TCPConnectionClose     = "AgentCloseTCPConnection"
-	TCPConnectionWrite     = "AgentTCPConnectionWrite"
-	PGConnectionWrite      = "AgentPGConnectionWrite"
-	MySQLConnectionWrite   = "AgentMySQLConnectionWrite"
-	MSSQLConnectionWrite   = "AgentMSSQLConnectionWrite"
-	MongoDBConnectionWrite = "AgentMongoDBConnectionWrite"
+	TCPConnectionClose      = "AgentCloseTCPConnection"
+	TCPConnectionWrite      = "AgentTCPConnectionWrite"
+	PGConnectionWrite       = "AgentPGConnectionWrite"
+	MySQLConnectionWrite    = "AgentMySQLConnectionWrite"
+	MSSQLConnectionWrite    = "AgentMSSQLConnectionWrite"
+	MongoDBConnectionWrite  = "AgentMongoDBConnectionWrite"
+	OracleDBConnectionWrite = "AgentOracleDBConnectionWrite"
+	RedisConnectionWrite    = "AgentRedisConnectionWrite"
+	SSHConnectionWrite      = "AgentSSHConnectionWrite"
+	HTTPConnectionWrite     = "AgentHTTPConnectionWrite"
 )
@@ -11,12 +11,16 @@ const (
 
 	ProxyManagerConnectOK = "ClientProxyManagerConnectOK"
 
-	TCPConnectionClose     = "ClientTCPConnectionClose"
-	TCPConnectionWrite     = "ClientTCPConnectionWrite"
-	PGConnectionWrite      = "ClientPGConnectionWrite"
-	MySQLConnectionWrite   = "ClientMySQLConnectionWrite"
-	MSSQLConnectionWrite   = "ClientMSSQLConnectionWrite"
-	MongoDBConnectionWrite = "ClientMongoDBConnectionWrite"
-	WriteStdout            = "ClientWriteStdout"
-	WriteStderr            = "ClientWriteStderr"
+	TCPConnectionClose      = "ClientTCPConnectionClose"
+	TCPConnectionWrite      = "ClientTCPConnectionWrite"
+	PGConnectionWrite       = "ClientPGConnectionWrite"
+	MySQLConnectionWrite    = "ClientMySQLConnectionWrite"
+	MSSQLConnectionWrite    = "ClientMSSQLConnectionWrite"
+	MongoDBConnectionWrite  = "ClientMongoDBConnectionWrite"
+	OracleDBConnectionWrite = "ClientOracleDBConnectionWrite"
+	RedisConnectionWrite    = "ClientRedisConnectionWrite"
+	SSHConnectionWrite      = "ClientSSHConnectionWrite"
+	HTTPConnectionWrite     = "ClientHTTPConnectionWrite"
+	WriteStdout             = "ClientWriteStdout"
+	WriteStderr             = "ClientWriteStderr"
 )
@@ -13,6 +13,8 @@ const (
 	SpecConnectionType               string = "gateway.connection_type"
 	SpecHasReviewKey                 string = "gateway.has_review"
 	SpecPluginDcmDataKey             string = "plugin.dcm_data"
+	SpecPluginAccessControlDeniedKey string = "plugin.access_control_denied"
+	SpecPluginAccessControlDropKey   string = "plugin.access_control_drop"
 	SpecDLPTransformationSummary     string = "dlp.transformation_summary" // Deprecated: see spectypes.DataMaskingInfoKey
 	SpecClientConnectionID           string = "client.connection_id"
 	SpecClientExitCodeKey            string = "client.exit_code"
@@ -30,6 +32,10 @@ const (
 	SpecGatewayJitID                 string = "jit.id"
 	SpecJitStatus                    string = "jit.status"
 	SpecJitTimeout                   string = "jit.timeout"
+	SpecJitStartAt                   string = "jit.start_at"
+	SpecKubernetesNamespace          string = "kubernetes.namespace"
+	SpecKubernetesPod                string = "kubernetes.pod"
+	SpecKubernetesService            string = "kubernetes.service"
 
 	DefaultKeepAlive time.Duration = 10 * time.Second
 
@@ -39,7 +45,11 @@ const (
 	ConnectionTypeMSSQL       ConnectionType = "mssql"
 	ConnectionTypeMongoDB     ConnectionType = "mongodb"
 	ConnectionTypeOracleDB    ConnectionType = "oracledb"
+	ConnectionTypeRedis       ConnectionType = "redis"
 	ConnectionTypeTCP         ConnectionType = "tcp"
+	ConnectionTypeKubernetes  ConnectionType = "kubernetes"
+	ConnectionTypeSSH         ConnectionType = "ssh"
+	ConnectionTypeHTTP        ConnectionType = "http"
 
 	ConnectionOriginAgent              = "agent"
 	ConnectionOriginClient             = "client"
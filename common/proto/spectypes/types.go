@@ -4,6 +4,9 @@ import "github.com/vmihailenco/msgpack/v5"
 
 const (
 	DataMaskingInfoKey = "datamasking.info"
+	// DataMaskingUnsupportedKey is set in the packets of sessions with data masking
+	// when the content of the packet could not be redacted, e.g.: the protocol is not supported.
+	DataMaskingUnsupportedKey = "datamasking.unsupported"
 )
 
 type TransformationSummary struct {
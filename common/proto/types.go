@@ -41,6 +41,10 @@ type (
 		ClientVerb     string
 		ClientOrigin   string
 		DLPInfoTypes   []string
+		// DataMaskingPolicies are the column masking policies of database connections
+		DataMaskingPolicies []string
+		// DataMaskingHashKey is the key of the hash transformation of the column masking policies
+		DataMaskingHashKey []byte
 	}
 
 	// TODO: remove it later, kept for compatibility issues
@@ -157,8 +161,15 @@ func IsInList(item string, items []string) bool {
 func ToConnectionType(connectionType, subtype string) ConnectionType {
 	switch connectionType {
 	case "application":
-		if subtype == "tcp" {
+		switch subtype {
+		case "tcp":
 			return ConnectionType(ConnectionTypeTCP)
+		case "kubernetes":
+			return ConnectionType(ConnectionTypeKubernetes)
+		case "ssh":
+			return ConnectionType(ConnectionTypeSSH)
+		case "http":
+			return ConnectionType(ConnectionTypeHTTP)
 		}
 		return ConnectionType(ConnectionTypeCommandLine)
 	case "custom":
@@ -175,6 +186,8 @@ func ToConnectionType(connectionType, subtype string) ConnectionType {
 			return ConnectionType(ConnectionTypeMSSQL)
 		case "oracledb":
 			return ConnectionType(ConnectionTypeOracleDB)
+		case "redis":
+			return ConnectionType(ConnectionTypeRedis)
 		}
 	}
 	return ConnectionType(connectionType)
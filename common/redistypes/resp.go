@@ -0,0 +1,250 @@
+package redistypes
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// RESP data types
+// https://redis.io/docs/latest/develop/reference/protocol-spec/
+const (
+	TypeSimpleString byte = '+'
+	TypeError        byte = '-'
+	TypeInteger      byte = ':'
+	TypeBulkString   byte = '$'
+	TypeArray        byte = '*'
+)
+
+// maxBulkLength is the maximum size of a bulk string accepted by the server (512MB)
+const maxBulkLength = 512 << 20
+
+// Command is a request of the client, it's sent as an array of bulk strings
+// or as an inline command (space separated arguments terminated by CRLF).
+type Command struct {
+	Args [][]byte
+
+	// the command as it was read from the client
+	raw []byte
+}
+
+// NewCommand creates a command with the arguments
+func NewCommand(args ...string) *Command {
+	c := &Command{}
+	for _, arg := range args {
+		c.Args = append(c.Args, []byte(arg))
+	}
+	return c
+}
+
+// Name returns the name of the command in upper case
+func (c *Command) Name() string {
+	if len(c.Args) == 0 {
+		return ""
+	}
+	return strings.ToUpper(string(c.Args[0]))
+}
+
+// String returns the arguments of the command separated by spaces,
+// arguments with spaces or non printable characters are quoted.
+func (c *Command) String() string {
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = string(arg)
+		if !isPlainArg(arg) {
+			args[i] = strconv.Quote(args[i])
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// Raw returns the command as it was sent by the client,
+// it returns the encoded arguments when the command was created or changed.
+func (c *Command) Raw() []byte {
+	if c.raw == nil {
+		return c.Encode()
+	}
+	return c.raw
+}
+
+// SetArgs replaces the arguments of the command
+func (c *Command) SetArgs(args [][]byte) {
+	c.Args = args
+	c.raw = nil
+}
+
+// Encode returns the command as an array of bulk strings
+func (c *Command) Encode() []byte {
+	dst := fmt.Appendf(nil, "%c%d\r\n", TypeArray, len(c.Args))
+	for _, arg := range c.Args {
+		dst = fmt.Appendf(dst, "%c%d\r\n", TypeBulkString, len(arg))
+		dst = append(append(dst, arg...), '\r', '\n')
+	}
+	return dst
+}
+
+// DecodeCommand reads a command of the client
+func DecodeCommand(r *bufio.Reader) (*Command, error) {
+	raw := &bytes.Buffer{}
+	line, err := readLine(r, raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != TypeArray {
+		// inline commands are used by clients without support to RESP, e.g.: telnet
+		return &Command{Args: bytes.Fields(line), raw: raw.Bytes()}, nil
+	}
+	size, err := strconv.Atoi(string(line[1:]))
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("invalid array length %q", line[1:])
+	}
+	c := &Command{Args: make([][]byte, 0, min(size, 1024))}
+	for i := 0; i < size; i++ {
+		line, err := readLine(r, raw)
+		if err == io.EOF {
+			// the command is incomplete
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != TypeBulkString {
+			return nil, fmt.Errorf("expected a bulk string argument, got=%q", line)
+		}
+		arg, err := readBulk(r, raw, line)
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.Args = append(c.Args, arg)
+	}
+	c.raw = raw.Bytes()
+	return c, nil
+}
+
+// DecodeCommands reads all the commands of the payload, clients could
+// send many commands at once without waiting for the replies (pipelining).
+func DecodeCommands(payload []byte) ([]*Command, error) {
+	r := bufio.NewReader(bytes.NewReader(payload))
+	var commands []*Command
+	for {
+		c, err := DecodeCommand(r)
+		if err == io.EOF {
+			return commands, nil
+		}
+		if err != nil {
+			return commands, err
+		}
+		commands = append(commands, c)
+	}
+}
+
+// Reply is a response of the server
+type Reply struct {
+	Type     byte
+	Data     []byte
+	Elements []*Reply
+}
+
+// IsError reports if the reply is an error
+func (r *Reply) IsError() bool { return r.Type == TypeError }
+
+// DecodeReply reads a reply of the server, it supports only the RESP2 data types
+func DecodeReply(r *bufio.Reader) (*Reply, error) {
+	line, err := readLine(r, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, fmt.Errorf("empty reply")
+	}
+	reply := &Reply{Type: line[0]}
+	switch reply.Type {
+	case TypeSimpleString, TypeError, TypeInteger:
+		reply.Data = line[1:]
+	case TypeBulkString:
+		reply.Data, err = readBulk(r, nil, line)
+	case TypeArray:
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid array length %q", line[1:])
+		}
+		for i := 0; i < size; i++ {
+			elem, err := DecodeReply(r)
+			if err != nil {
+				return nil, err
+			}
+			reply.Elements = append(reply.Elements, elem)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported reply type %q", reply.Type)
+	}
+	return reply, err
+}
+
+// NewError returns an error reply, the message must start with an error prefix, e.g.: ERR, NOPERM
+func NewError(msg string) []byte {
+	msg = strings.NewReplacer("\r", " ", "\n", " ").Replace(msg)
+	return fmt.Appendf(nil, "%c%s\r\n", TypeError, msg)
+}
+
+// NewSimpleString returns a simple string reply
+func NewSimpleString(val string) []byte {
+	return fmt.Appendf(nil, "%c%s\r\n", TypeSimpleString, val)
+}
+
+// readLine reads a line terminated by CRLF and copies it to raw, it returns the line without the terminator
+func readLine(r *bufio.Reader, raw *bytes.Buffer) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, fmt.Errorf("line is too long")
+	}
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if raw != nil {
+		raw.Write(line)
+	}
+	// the slice is overwritten by the next read of the buffer
+	return bytes.Clone(bytes.TrimSuffix(line[:len(line)-1], []byte{'\r'})), nil
+}
+
+// readBulk reads the content of a bulk string with the length of the line
+func readBulk(r *bufio.Reader, raw *bytes.Buffer, line []byte) ([]byte, error) {
+	size, err := strconv.Atoi(string(line[1:]))
+	if err != nil || size > maxBulkLength {
+		return nil, fmt.Errorf("invalid bulk string length %q", line[1:])
+	}
+	// null bulk string
+	if size < 0 {
+		return nil, nil
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	if raw != nil {
+		raw.Write(data)
+	}
+	return data[:size], nil
+}
+
+func isPlainArg(arg []byte) bool {
+	if len(arg) == 0 {
+		return false
+	}
+	for _, c := range arg {
+		if c <= ' ' || c >= 0x7f || c == '"' {
+			return false
+		}
+	}
+	return true
+}
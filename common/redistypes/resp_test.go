@@ -0,0 +1,175 @@
+package redistypes
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecodeCommand(t *testing.T) {
+	for _, tt := range []struct {
+		msg     string
+		data    string
+		want    string
+		wantRaw string
+		wantErr bool
+	}{
+		{
+			msg:     "it should decode an array of bulk strings",
+			data:    "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$11\r\nhello world\r\n",
+			want:    `SET key "hello world"`,
+			wantRaw: "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$11\r\nhello world\r\n",
+		},
+		{
+			msg:     "it should decode an inline command",
+			data:    "get  mykey\r\n",
+			want:    "get mykey",
+			wantRaw: "get  mykey\r\n",
+		},
+		{
+			msg:     "it should decode binary arguments",
+			data:    "*2\r\n$3\r\nGET\r\n$4\r\n\x00\r\n\x01\r\n",
+			want:    `GET "\x00\r\n\x01"`,
+			wantRaw: "*2\r\n$3\r\nGET\r\n$4\r\n\x00\r\n\x01\r\n",
+		},
+		{
+			msg:     "it should fail when the arguments are not bulk strings",
+			data:    "*1\r\n:1\r\n",
+			wantErr: true,
+		},
+		{
+			msg:     "it should fail when the command is incomplete",
+			data:    "*2\r\n$3\r\nGET\r\n$4\r\nke",
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := DecodeCommand(bufio.NewReader(strings.NewReader(tt.data)))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got=%v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("did not expect error, got=%v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("want=%q, got=%q", tt.want, got.String())
+			}
+			if string(got.Raw()) != tt.wantRaw {
+				t.Errorf("raw command does not match, want=%q, got=%q", tt.wantRaw, got.Raw())
+			}
+		})
+	}
+}
+
+func TestDecodeCommandPipeline(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("*1\r\n$4\r\nPING\r\n*2\r\n$3\r\ndel\r\n$1\r\na\r\n"))
+	var names []string
+	for {
+		c, err := DecodeCommand(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("did not expect error, got=%v", err)
+		}
+		names = append(names, c.Name())
+	}
+	if strings.Join(names, ",") != "PING,DEL" {
+		t.Errorf("expected to decode all commands, got=%v", names)
+	}
+}
+
+func TestDecodeCommands(t *testing.T) {
+	for _, tt := range []struct {
+		msg       string
+		payload   string
+		wantNames string
+		wantErr   bool
+	}{
+		{
+			msg:       "it should decode all commands of a pipeline",
+			payload:   "*1\r\n$4\r\nPING\r\n*2\r\n$3\r\ndel\r\n$1\r\na\r\nflushall\r\n",
+			wantNames: "PING,DEL,FLUSHALL",
+		},
+		{
+			msg:       "it should return an error when the last command is incomplete",
+			payload:   "*1\r\n$4\r\nPING\r\n*2\r\n$3\r\ndel\r\n",
+			wantNames: "PING",
+			wantErr:   true,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			commands, err := DecodeCommands([]byte(tt.payload))
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("expected error=%v, got=%v", tt.wantErr, err)
+			}
+			var names []string
+			for _, c := range commands {
+				names = append(names, c.Name())
+			}
+			if got := strings.Join(names, ","); got != tt.wantNames {
+				t.Errorf("want=%v, got=%v", tt.wantNames, got)
+			}
+		})
+	}
+}
+
+func TestEncodeCommand(t *testing.T) {
+	c := NewCommand("AUTH", "default", "secret")
+	want := "*3\r\n$4\r\nAUTH\r\n$7\r\ndefault\r\n$6\r\nsecret\r\n"
+	if got := string(c.Raw()); got != want {
+		t.Errorf("want=%q, got=%q", want, got)
+	}
+	c, _ = DecodeCommand(bufio.NewReader(strings.NewReader("AUTH noop\r\n")))
+	c.SetArgs([][]byte{[]byte("AUTH"), []byte("secret")})
+	if got := string(c.Raw()); got != "*2\r\n$4\r\nAUTH\r\n$6\r\nsecret\r\n" {
+		t.Errorf("expected to encode the new arguments, got=%q", got)
+	}
+}
+
+func TestDecodeReply(t *testing.T) {
+	for _, tt := range []struct {
+		msg  string
+		data string
+		want *Reply
+	}{
+		{
+			msg:  "it should decode simple strings",
+			data: "+OK\r\n",
+			want: &Reply{Type: TypeSimpleString, Data: []byte("OK")},
+		},
+		{
+			msg:  "it should decode errors",
+			data: "-WRONGPASS invalid username-password pair\r\n",
+			want: &Reply{Type: TypeError, Data: []byte("WRONGPASS invalid username-password pair")},
+		},
+		{
+			msg:  "it should decode arrays",
+			data: "*2\r\n$5\r\nhello\r\n:10\r\n",
+			want: &Reply{Type: TypeArray, Elements: []*Reply{
+				{Type: TypeBulkString, Data: []byte("hello")},
+				{Type: TypeInteger, Data: []byte("10")},
+			}},
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := DecodeReply(bufio.NewReader(strings.NewReader(tt.data)))
+			if err != nil {
+				t.Fatalf("did not expect error, got=%v", err)
+			}
+			if got.Type != tt.want.Type || !bytes.Equal(got.Data, tt.want.Data) || len(got.Elements) != len(tt.want.Elements) {
+				t.Errorf("want=%+v, got=%+v", tt.want, got)
+			}
+			for i := range got.Elements {
+				if !bytes.Equal(got.Elements[i].Data, tt.want.Elements[i].Data) {
+					t.Errorf("element %v does not match, want=%q, got=%q", i, tt.want.Elements[i].Data, got.Elements[i].Data)
+				}
+			}
+		})
+	}
+}
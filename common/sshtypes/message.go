@@ -0,0 +1,159 @@
+// Package sshtypes implements the messages exchanged between the client and the agent to relay
+// the channels of a SSH connection. The client terminates the SSH connection of the local clients
+// and the agent opens a connection with the server, each message is sent in a single packet.
+package sshtypes
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+type MessageType byte
+
+const (
+	// MessageOpenChannel requests opening a channel in the server, the name is the type of the channel
+	MessageOpenChannel MessageType = iota + 1
+	// MessageOpenConfirm confirms that the channel was opened in the server
+	MessageOpenConfirm
+	// MessageOpenFailure reports that the channel was rejected, the data is the reason
+	MessageOpenFailure
+	// MessageRequest is a request of a channel, e.g.: pty-req, shell, exec, exit-status
+	MessageRequest
+	// MessageRequestReply is the reply of a request, the want reply flag is the status of the reply
+	MessageRequestReply
+	// MessageData is the content of the channel
+	MessageData
+	// MessageExtendedData is the content of the stderr of the channel
+	MessageExtendedData
+	// MessageEOF reports that no more data will be sent to the channel
+	MessageEOF
+	// MessageClose closes the channel
+	MessageClose
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case MessageOpenChannel:
+		return "open-channel"
+	case MessageOpenConfirm:
+		return "open-confirm"
+	case MessageOpenFailure:
+		return "open-failure"
+	case MessageRequest:
+		return "request"
+	case MessageRequestReply:
+		return "request-reply"
+	case MessageData:
+		return "data"
+	case MessageExtendedData:
+		return "extended-data"
+	case MessageEOF:
+		return "eof"
+	case MessageClose:
+		return "close"
+	}
+	return fmt.Sprintf("unknown(%d)", byte(t))
+}
+
+// headerSize is the size of type, channel id, want reply flag and the length of the name
+const headerSize = 8
+
+// Message is the frame of a channel
+//
+//	type(1) | channel id(4) | want reply(1) | name length(2) | name | data
+type Message struct {
+	Type      MessageType
+	ChannelID uint32
+	// WantReply is the flag of requests or the status of request replies
+	WantReply bool
+	// Name is the type of the channel or the name of the request
+	Name string
+	Data []byte
+}
+
+func (m *Message) Encode() []byte {
+	dst := make([]byte, headerSize, headerSize+len(m.Name)+len(m.Data))
+	dst[0] = byte(m.Type)
+	binary.BigEndian.PutUint32(dst[1:5], m.ChannelID)
+	if m.WantReply {
+		dst[5] = 1
+	}
+	binary.BigEndian.PutUint16(dst[6:8], uint16(len(m.Name)))
+	dst = append(dst, m.Name...)
+	return append(dst, m.Data...)
+}
+
+func (m *Message) String() string {
+	return fmt.Sprintf("type=%v, channel=%v, name=%v, want-reply=%v, length=%v",
+		m.Type, m.ChannelID, m.Name, m.WantReply, len(m.Data))
+}
+
+// Decode parses a message, the data of the message shares the same underlying array of data
+func Decode(data []byte) (*Message, error) {
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("message is too short, length=%v", len(data))
+	}
+	m := &Message{
+		Type:      MessageType(data[0]),
+		ChannelID: binary.BigEndian.Uint32(data[1:5]),
+		WantReply: data[5] == 1,
+	}
+	if m.Type < MessageOpenChannel || m.Type > MessageClose {
+		return nil, fmt.Errorf("unknown message type %v", data[0])
+	}
+	nameSize := int(binary.BigEndian.Uint16(data[6:8]))
+	if len(data) < headerSize+nameSize {
+		return nil, fmt.Errorf("message name is out of bounds, length=%v, name-length=%v", len(data), nameSize)
+	}
+	m.Name = string(data[headerSize : headerSize+nameSize])
+	m.Data = data[headerSize+nameSize:]
+	return m, nil
+}
+
+// ExecCommand returns the command of an exec request or the name of a subsystem request,
+// it returns an empty string if it's not a request starting a program in the channel.
+func (m *Message) ExecCommand() string {
+	if m.Type != MessageRequest {
+		return ""
+	}
+	switch m.Name {
+	case "shell":
+		return "shell"
+	case "exec", "subsystem":
+		// the payload is an encoded ssh string: uint32 length | value
+		if len(m.Data) < 4 {
+			return ""
+		}
+		size := binary.BigEndian.Uint32(m.Data[:4])
+		if uint32(len(m.Data)-4) < size {
+			return ""
+		}
+		value := string(m.Data[4 : 4+size])
+		if m.Name == "subsystem" {
+			return "subsystem " + value
+		}
+		return value
+	}
+	return ""
+}
+
+// Copy writes the content of r as messages of the channel to w until r returns an error
+func Copy(w io.Writer, channelID uint32, msgType MessageType, r io.Reader) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			msg := &Message{Type: msgType, ChannelID: channelID, Data: buf[:n]}
+			if _, err := w.Write(msg.Encode()); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
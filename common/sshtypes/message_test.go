@@ -0,0 +1,119 @@
+package sshtypes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func sshString(v string) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(v))), v...)
+}
+
+func TestEncodeDecode(t *testing.T) {
+	for _, tt := range []struct {
+		msg string
+		m   Message
+	}{
+		{
+			msg: "it should encode and decode an open channel message",
+			m:   Message{Type: MessageOpenChannel, ChannelID: 1, Name: "session"},
+		},
+		{
+			msg: "it should encode and decode a request with want reply",
+			m:   Message{Type: MessageRequest, ChannelID: 4294967295, WantReply: true, Name: "exec", Data: sshString("ls -l")},
+		},
+		{
+			msg: "it should encode and decode data without name",
+			m:   Message{Type: MessageData, ChannelID: 2, Data: []byte("hello\x00world")},
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := Decode(tt.m.Encode())
+			if err != nil {
+				t.Fatalf("did not expect error, got=%v", err)
+			}
+			if got.Type != tt.m.Type || got.ChannelID != tt.m.ChannelID || got.WantReply != tt.m.WantReply ||
+				got.Name != tt.m.Name || !bytes.Equal(got.Data, tt.m.Data) {
+				t.Errorf("want=%v, got=%v", tt.m.String(), got.String())
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, tt := range []struct {
+		msg  string
+		data []byte
+	}{
+		{msg: "it should fail when the message is too short", data: []byte{0x06, 0x00}},
+		{msg: "it should fail with unknown message types", data: []byte{0x0f, 0, 0, 0, 1, 0, 0, 0}},
+		{msg: "it should fail when the name is out of bounds", data: []byte{0x04, 0, 0, 0, 1, 0, 0, 10, 'e'}},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			if got, err := Decode(tt.data); err == nil {
+				t.Errorf("expected error, got=%v", got)
+			}
+		})
+	}
+}
+
+func TestExecCommand(t *testing.T) {
+	for _, tt := range []struct {
+		msg  string
+		m    Message
+		want string
+	}{
+		{
+			msg:  "it should return the command of exec requests",
+			m:    Message{Type: MessageRequest, Name: "exec", Data: sshString("scp -t /tmp")},
+			want: "scp -t /tmp",
+		},
+		{
+			msg:  "it should return the name of subsystems",
+			m:    Message{Type: MessageRequest, Name: "subsystem", Data: sshString("sftp")},
+			want: "subsystem sftp",
+		},
+		{
+			msg:  "it should return shell for shell requests",
+			m:    Message{Type: MessageRequest, Name: "shell"},
+			want: "shell",
+		},
+		{
+			msg: "it should return empty for other requests",
+			m:   Message{Type: MessageRequest, Name: "pty-req", Data: sshString("xterm")},
+		},
+		{
+			msg: "it should return empty when the command is truncated",
+			m:   Message{Type: MessageRequest, Name: "exec", Data: sshString("ls")[:5]},
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			if got := tt.m.ExecCommand(); got != tt.want {
+				t.Errorf("want=%q, got=%q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCopy(t *testing.T) {
+	var messages [][]byte
+	w := writerFunc(func(p []byte) (int, error) {
+		messages = append(messages, bytes.Clone(p))
+		return len(p), nil
+	})
+	if err := Copy(w, 7, MessageExtendedData, bytes.NewReader([]byte("error output"))); err != nil {
+		t.Fatalf("did not expect error, got=%v", err)
+	}
+	if len(messages) != 1 {
+		t.Fatalf("expected a single message, got=%v", len(messages))
+	}
+	got, _ := Decode(messages[0])
+	if got.Type != MessageExtendedData || got.ChannelID != 7 || string(got.Data) != "error output" {
+		t.Errorf("message does not match, got=%v", got.String())
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
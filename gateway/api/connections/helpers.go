@@ -7,11 +7,14 @@ import (
 	"slices"
 	"strings"
 
+	"github.com/hoophq/hoop/common/dlp"
 	pb "github.com/hoophq/hoop/common/proto"
 	"github.com/hoophq/hoop/gateway/api/openapi"
 	apivalidation "github.com/hoophq/hoop/gateway/api/validation"
 	"github.com/hoophq/hoop/gateway/pgrest"
 	pgplugins "github.com/hoophq/hoop/gateway/pgrest/plugins"
+	"github.com/hoophq/hoop/gateway/review"
+	"github.com/hoophq/hoop/gateway/transport/plugins/accesscontrol"
 	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
 )
 
@@ -29,8 +32,10 @@ func accessControlAllowed(ctx pgrest.Context) (func(connName string) bool, error
 	return func(connName string) bool {
 		for _, c := range p.Connections {
 			if c.Name == connName {
+				// the guardrail rules are stored alongside the groups, they must not grant access
+				groups := accesscontrol.Groups(c.Config)
 				for _, userGroup := range ctx.GetUserGroups() {
-					if allow := slices.Contains(c.Config, userGroup); allow {
+					if allow := slices.Contains(groups, userGroup); allow {
 						return allow
 					}
 				}
@@ -60,6 +65,14 @@ func setConnectionDefaults(req *openapi.Connection) {
 	case pb.ConnectionTypeOracleDB:
 		defaultEnvVars["envvar:LD_LIBRARY_PATH"] = base64.StdEncoding.EncodeToString([]byte(`/opt/oracle/instantclient_19_24`))
 		defaultCommand = []string{"sqlplus", "-s", "$USER/$PASS@$HOST:$PORT/$SID"}
+	case pb.ConnectionTypeRedis:
+		defaultEnvVars["envvar:PORT"] = base64.StdEncoding.EncodeToString([]byte(`6379`))
+		defaultEnvVars["envvar:DB"] = base64.StdEncoding.EncodeToString([]byte(`0`))
+		defaultCommand = []string{"redis-cli", "-h", "$HOST", "-p", "$PORT", "-n", "$DB"}
+	case pb.ConnectionTypeKubernetes:
+		defaultEnvVars["envvar:NAMESPACE"] = base64.StdEncoding.EncodeToString([]byte(`default`))
+	case pb.ConnectionTypeSSH:
+		defaultEnvVars["envvar:PORT"] = base64.StdEncoding.EncodeToString([]byte(`22`))
 	case pb.ConnectionTypeMongoDB:
 		defaultEnvVars["envvar:OPTIONS"] = base64.StdEncoding.EncodeToString([]byte(`tls=true`))
 		defaultEnvVars["envvar:PORT"] = base64.StdEncoding.EncodeToString([]byte(`27017`))
@@ -107,6 +120,14 @@ func validateConnectionRequest(req openapi.Connection) error {
 			errors = append(errors, "tags: values must contain between 1 and 128 alphanumeric characters, it may include (-), (_) or (.) characters")
 		}
 	}
+	if _, err := dlp.ParseColumnPolicies(req.RedactTypes); err != nil {
+		errors = append(errors, fmt.Sprintf("redact_types: %v", err))
+	}
+	if len(req.Reviewers) > 0 {
+		if _, err := review.ParseWorkflow(req.Reviewers); err != nil {
+			errors = append(errors, fmt.Sprintf("reviewers: %v", err))
+		}
+	}
 	if len(errors) > 0 {
 		return fmt.Errorf(strings.Join(errors, "; "))
 	}
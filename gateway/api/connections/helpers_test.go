@@ -89,6 +89,15 @@ func TestAccessControlAllowed(t *testing.T) {
 			}),
 			groups: []string{"sre"},
 		},
+		{
+			msg:                "it should deny access to groups named as the guardrail rules",
+			allow:              false,
+			wantConnectionName: "bash",
+			fakeClient: createTestServer(t, []*pgrest.PluginConnection{
+				{ConnectionConfig: []string{"sre", "rule:deny-statement:DROP"}, Connection: pgrest.Connection{Name: "bash"}},
+			}),
+			groups: []string{"rule:deny-statement:DROP"},
+		},
 		{
 			msg:                "it should deny access if the groups does not match",
 			allow:              false,
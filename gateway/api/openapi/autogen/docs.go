@@ -948,6 +948,12 @@ const docTemplate = `{
                         "name": "name",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "The branch or tag of the repository allowed in the plugin, it defaults to the ref configured in the plugin",
+                        "name": "ref",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -994,6 +1000,14 @@ const docTemplate = `{
                     "Core"
                 ],
                 "summary": "List Runbooks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The branch or tag of the repository allowed in the plugin, it defaults to the ref configured in the plugin",
+                        "name": "ref",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -1001,6 +1015,59 @@ const docTemplate = `{
                             "$ref": "#/definitions/openapi.RunbookList"
                         }
                     },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/openapi.HTTPError"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/openapi.HTTPError"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/openapi.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/openapi.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
+        "/plugins/runbooks/webhooks/{org_id}": {
+            "post": {
+                "description": "Refresh the runbooks repository when a change is pushed to it. It must be configured as a push webhook in the git provider with the secret of the plugin configuration (` + "`" + `GIT_WEBHOOK_SECRET` + "`" + `).\nThe request is validated with the ` + "`" + `X-Hub-Signature-256` + "`" + ` (Github, Gitea, Bitbucket) or the ` + "`" + `X-Gitlab-Token` + "`" + ` (Gitlab) header.",
+                "tags": [
+                    "Core"
+                ],
+                "summary": "Runbooks Refresh Webhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The id of the organization",
+                        "name": "org_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/openapi.HTTPError"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
@@ -1187,7 +1254,7 @@ const docTemplate = `{
         },
         "/proxymanager/connect": {
             "post": {
-                "description": "Send a connect request to the client. A successful response indicates the client has stablished a connection.\nIf the connection resource has the review enabled, it returns a successful response containing the link of the review in the ` + "`" + `Localtion` + "`" + ` header.",
+                "description": "Send a connect request to the client. A successful response indicates the client has stablished a connection.\nIf the connection resource has the review enabled, it returns a successful response containing the link of the review in the ` + "`" + `Localtion` + "`" + ` header.\nMultiple connections could be connected at the same time, each one must listen in a distinct port.",
                 "consumes": [
                     "application/json"
                 ],
@@ -1234,6 +1301,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/openapi.HTTPError"
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/openapi.HTTPError"
+                        }
+                    },
                     "422": {
                         "description": "Unprocessable Entity",
                         "schema": {
@@ -1251,7 +1324,7 @@ const docTemplate = `{
         },
         "/proxymanager/disconnect": {
             "post": {
-                "description": "Send a disconnect request. The transport layer will disconnect the connected client asynchronously",
+                "description": "Send a disconnect request. The transport layer will disconnect the connected client asynchronously.\nWhen the ` + "`" + `connection_name` + "`" + ` is set, only the session of this connection is closed.",
                 "produces": [
                     "application/json"
                 ],
@@ -1259,6 +1332,15 @@ const docTemplate = `{
                     "Proxy Manager"
                 ],
                 "summary": "ProxyManager Disconnect",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "format": "string",
+                        "description": "Disconnect only the session of this connection",
+                        "name": "connection_name",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "202": {
                         "description": "Accepted",
@@ -1899,7 +1981,7 @@ const docTemplate = `{
                     {
                         "type": "string",
                         "example": "csv",
-                        "description": "The file extension to donwload the session as a file content.\n* ` + "`" + `csv` + "`" + ` - it will parse the content to format in csv format\n* ` + "`" + `json` + "`" + ` - it will parse the content as a json stream.\n* ` + "`" + `\u003cany-format\u003e` + "`" + ` - No special parsing is applied",
+                        "description": "The file extension to donwload the session as a file content.\n* ` + "`" + `csv` + "`" + ` - it will parse the content to format in csv format\n* ` + "`" + `json` + "`" + ` - it will parse the content as a json stream.\n* ` + "`" + `cast` + "`" + ` - it will parse the content as an asciicast v2 file with the timing of each event\n* ` + "`" + `\u003cany-format\u003e` + "`" + ` - No special parsing is applied",
                         "name": "extension",
                         "in": "query"
                     },
@@ -2063,6 +2145,47 @@ const docTemplate = `{
                 }
             }
         },
+        "/sessions/{session_id}/verify": {
+            "get": {
+                "description": "Recompute the chain of hashes of the session events and validate the signed digest. It reports the first tampered event.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Core"
+                ],
+                "summary": "Verify Session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "The id of the resource",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/openapi.SessionVerifyResult"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/openapi.HTTPError"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/openapi.HTTPError"
+                        }
+                    }
+                }
+            }
+        },
         "/signup": {
             "post": {
                 "description": "Signup anonymous authenticated user. This endpoint is only used for multi tenant setups.",
@@ -2690,7 +2813,7 @@ const docTemplate = `{
                     "type": "boolean"
                 },
                 "redact_types": {
-                    "description": "Redact Types is a list of info types that will used to redact the output of the connection.\nPossible values are described in the DLP documentation: https://cloud.google.com/sensitive-data-protection/docs/infotypes-reference",
+                    "description": "Redact Types is a list of info types that will used to redact the output of the connection.\nPossible values are described in the DLP documentation: https://cloud.google.com/sensitive-data-protection/docs/infotypes-reference\nIt also accepts column masking policies for database connections in the format ` + "`" + `\u003ctable\u003e.\u003ccolumn\u003e -\u003e \u003chash|last4|redact\u003e` + "`" + `,\na wildcard (*) matches any table, e.g.: ` + "`" + `*.ssn -\u003e redact` + "`" + `",
                     "type": "array",
                     "items": {
                         "type": "string"
@@ -2700,7 +2823,7 @@ const docTemplate = `{
                     ]
                 },
                 "reviewers": {
-                    "description": "Reviewers is a list of groups that will review the connection before the user could execute it",
+                    "description": "Reviewers is a list of groups that will review the connection before the user could execute it.\nIt also accepts options to configure the approval workflow:\n* ` + "`" + `dba;stage=2` + "`" + ` - The group reviews after the groups of the previous stages approve it\n* ` + "`" + `sre;quorum=2` + "`" + ` - The group requires the approval of two distinct members\n* ` + "`" + `expire-after=24h` + "`" + ` - Pending reviews are rejected after the duration\n* ` + "`" + `self-approval=deny` + "`" + ` - The owner of the review can't approve it, including admins",
                     "type": "array",
                     "items": {
                         "type": "string"
@@ -2710,7 +2833,7 @@ const docTemplate = `{
                     ]
                 },
                 "secret": {
-                    "description": "Secrets are environment variables that are going to be exposed\nin the runtime of the connection:\n* { envvar:[env-key]: [base64-val] } - Expose the value as environment variable\n* { filesystem:[env-key]: [base64-val] } - Expose the value as a temporary file path creating the value in the filesystem\n\nThe value could also represent an integration with a external provider:\n* { envvar:[env-key]: _aws:[secret-name]:[secret-key] } - Obtain the value dynamically in the AWS secrets manager and expose as environment variable\n* { envvar:[env-key]: _envjson:[json-env-name]:[json-env-key] } - Obtain the value dynamically from a JSON env in the agent runtime. Example: MYENV={\"KEY\": \"val\"}",
+                    "description": "Secrets are environment variables that are going to be exposed\nin the runtime of the connection:\n* { envvar:[env-key]: [base64-val] } - Expose the value as environment variable\n* { filesystem:[env-key]: [base64-val] } - Expose the value as a temporary file path creating the value in the filesystem\n\nThe value could also represent an integration with a external provider:\n* { envvar:[env-key]: _aws:[secret-name]:[secret-key] } - Obtain the value dynamically in the AWS secrets manager and expose as environment variable\n* { envvar:[env-key]: _envjson:[json-env-name]:[json-env-key] } - Obtain the value dynamically from a JSON env in the agent runtime. Example: MYENV={\"KEY\": \"val\"}\n* { envvar:[env-key]: _vault:[secret-path]:[secret-key] } - Obtain the value dynamically from Hashicorp Vault (kv v1/v2 or dynamic credentials revoked when the session ends). Example: _vault:database/creds/readonly:username",
                     "type": "object",
                     "additionalProperties": {}
                 },
@@ -2724,7 +2847,7 @@ const docTemplate = `{
                     "readOnly": true
                 },
                 "subtype": {
-                    "description": "Sub Type is the underline implementation of the connection:\n* postgres - Implements Postgres protocol\n* mysql - Implements MySQL protocol\n* mongodb - Implements MongoDB Wire Protocol\n* mssql - Implements Microsoft SQL Server Protocol\n* tcp - Forwards a TCP connection",
+                    "description": "Sub Type is the underline implementation of the connection:\n* postgres - Implements Postgres protocol\n* mysql - Implements MySQL protocol\n* mongodb - Implements MongoDB Wire Protocol\n* mssql - Implements Microsoft SQL Server Protocol\n* oracledb - Implements Oracle Transparent Network Substrate (TNS) Protocol\n* redis - Implements Redis Serialization Protocol (RESP)\n* tcp - Forwards a TCP connection\n* kubernetes - Executes commands in a pod or forwards connections to a service of a Kubernetes cluster\n* ssh - Implements the Secure Shell Protocol (SSH)\n* http - Forwards HTTP requests to an internal server injecting the credentials as headers",
                     "type": "string",
                     "example": "postgres"
                 },
@@ -3011,7 +3134,7 @@ const docTemplate = `{
                     "example": "15B5A2FD-0706-4A47-B1CF-B93CCFC5B3D7"
                 },
                 "name": {
-                    "description": "The name of the plugin to enable\n* audit - Audit connections\n* access_control - Enable access control by groups\n* dlp - Enable Google Data Loss Prevention (requires further configuration)\n* indexer - Enable indexing session contents\n* review - Enable reviewing executions\n* runbooks - Enable configuring runbooks\n* slack - Enable reviewing execution through Slack\n* webhooks - Send events via webhooks",
+                    "description": "The name of the plugin to enable\n* audit - Audit connections\n* access_control - Enable access control by groups and statement guardrails (rule:\u003ctype\u003e:\u003cvalue\u003e)\n* dlp - Enable Google Data Loss Prevention (requires further configuration)\n* indexer - Enable indexing session contents\n* review - Enable reviewing executions\n* runbooks - Enable configuring runbooks\n* slack - Enable reviewing execution through Slack\n* webhooks - Send events via webhooks",
                     "type": "string",
                     "enum": [
                         "audit",
@@ -3125,7 +3248,7 @@ const docTemplate = `{
                     "example": "2024-07-25T19:36:41Z"
                 },
                 "connection_name": {
-                    "description": "The requested connection name",
+                    "description": "The last requested connection name",
                     "type": "string"
                 },
                 "id": {
@@ -3149,16 +3272,54 @@ const docTemplate = `{
                     }
                 },
                 "port": {
-                    "description": "The requested client port to listen",
+                    "description": "The last requested client port to listen",
                     "type": "string"
                 },
                 "status": {
-                    "description": "The status of the connection request\n* ready - indicates the grpc client is ready to subscribe to a new connection\n* connected - indicates the client has opened a new session\n* disconnected - indicates the grpc client has disconnected",
+                    "description": "The status of the connection request\n* ready - indicates the grpc client is ready to subscribe to a new connection\n* connected - indicates the client has opened at least one session\n* disconnected - indicates the grpc client has disconnected",
                     "allOf": [
                         {
                             "$ref": "#/definitions/openapi.ClientStatusType"
                         }
                     ]
+                },
+                "tunnels": {
+                    "description": "The connections opened by the client, each one listening in a distinct port",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/openapi.ProxyManagerTunnel"
+                    }
+                }
+            }
+        },
+        "openapi.ProxyManagerTunnel": {
+            "type": "object",
+            "properties": {
+                "access_duration": {
+                    "description": "The access duration of the session in case of review",
+                    "type": "integer",
+                    "example": 1800000000000
+                },
+                "connected-at": {
+                    "description": "The time (RFC3339) when the session was opened",
+                    "type": "string",
+                    "example": "2024-07-25T19:36:41Z"
+                },
+                "connection_name": {
+                    "description": "The name of the connection",
+                    "type": "string",
+                    "example": "pgdemo"
+                },
+                "port": {
+                    "description": "The port listening in the client",
+                    "type": "string",
+                    "example": "5432"
+                },
+                "session_id": {
+                    "description": "The session identifier of the connection",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "15B3C616-6B43-4F85-B4FD-B83378A866C2"
                 }
             }
         },
@@ -3172,12 +3333,35 @@ const docTemplate = `{
                     "readOnly": true,
                     "example": 0
                 },
+                "access_start_at": {
+                    "description": "The start of a scheduled access window. The access is valid from this time until it's added the access duration.\nIt's valid only for ` + "`" + `jit` + "`" + ` type reviews",
+                    "type": "string",
+                    "readOnly": true,
+                    "example": "2024-07-27T02:00:00Z"
+                },
                 "created_at": {
                     "description": "The time the resource was created",
                     "type": "string",
                     "readOnly": true,
                     "example": "2024-07-25T15:56:35.317601Z"
                 },
+                "current_stage": {
+                    "description": "The stage of the workflow being reviewed, the groups of this stage could approve it in any order",
+                    "type": "integer",
+                    "readOnly": true,
+                    "example": 1
+                },
+                "deny_self_approval": {
+                    "description": "If the owner of this review is not allowed to approve it, including admins",
+                    "type": "boolean",
+                    "readOnly": true
+                },
+                "expire_at": {
+                    "description": "The time when this review expires if it's still pending",
+                    "type": "string",
+                    "readOnly": true,
+                    "example": "2024-07-26T15:56:35Z"
+                },
                 "id": {
                     "description": "Reousrce identifier",
                     "type": "string",
@@ -3295,6 +3479,14 @@ const docTemplate = `{
         "openapi.ReviewGroup": {
             "type": "object",
             "properties": {
+                "approvals": {
+                    "description": "The users that approved this group",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/openapi.ReviewOwner"
+                    },
+                    "readOnly": true
+                },
                 "group": {
                     "description": "The group to approve this review",
                     "type": "string",
@@ -3308,6 +3500,12 @@ const docTemplate = `{
                     "readOnly": true,
                     "example": "20A5AABE-C35D-4F04-A5A7-C856EE6C7703"
                 },
+                "quorum": {
+                    "description": "The number of distinct approvals required by this group",
+                    "type": "integer",
+                    "readOnly": true,
+                    "example": 1
+                },
                 "review_date": {
                     "description": "The date which this review was performed",
                     "type": "string",
@@ -3323,6 +3521,12 @@ const docTemplate = `{
                     ],
                     "readOnly": true
                 },
+                "stage": {
+                    "description": "The stage of the workflow which this group reviews",
+                    "type": "integer",
+                    "readOnly": true,
+                    "example": 1
+                },
                 "status": {
                     "description": "The reviewed status\n* APPROVED - Approve the review resource\n* REJECTED - Reject the review resource\n* REVOKED - Revoke an approved review",
                     "allOf": [
@@ -3442,6 +3646,14 @@ const docTemplate = `{
                     "description": "File path relative to repository root containing runbook file in the following format: ` + "`" + `/path/to/file.runbook.\u003cext\u003e` + "`" + `",
                     "type": "string",
                     "example": "ops/update-user.runbook.sh"
+                },
+                "schema": {
+                    "description": "The typed inputs declared in the schema file of the runbook (` + "`" + `\u003crunbook-file\u003e.schema.yaml` + "`" + `), it's empty when the runbook has no schema.\nThe attributes of the schema are also reflected in the ` + "`" + `metadata` + "`" + ` field.",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/openapi.RunbookSchema"
+                        }
+                    ]
                 }
             }
         },
@@ -3508,6 +3720,11 @@ const docTemplate = `{
                         "wallet_id": "6736"
                     }
                 },
+                "ref": {
+                    "description": "The branch or tag to obtain the file, it must be one of the allowed refs (GIT_ALLOWED_REFS) of the plugin.\nIt defaults to the ref configured in the plugin",
+                    "type": "string",
+                    "example": "staging"
+                },
                 "ref_hash": {
                     "description": "The commit sha reference to obtain the file",
                     "type": "string",
@@ -3515,6 +3732,102 @@ const docTemplate = `{
                 }
             }
         },
+        "openapi.RunbookSchema": {
+            "type": "object",
+            "properties": {
+                "inputs": {
+                    "description": "The inputs of the runbook, in the order they should be asked",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/openapi.RunbookSchemaInput"
+                    }
+                }
+            }
+        },
+        "openapi.RunbookSchemaInput": {
+            "type": "object",
+            "properties": {
+                "default": {
+                    "description": "The default value to use when the input is empty",
+                    "type": "string",
+                    "example": "1"
+                },
+                "depends_on": {
+                    "description": "The input is enabled only when the inputs declared before it have one of the values.\nDisabled inputs are rendered as empty values.",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "description": {
+                    "description": "The description of the input",
+                    "type": "string",
+                    "example": "the id of the customer"
+                },
+                "max": {
+                    "description": "The maximum value of the input (inclusive)",
+                    "type": "string",
+                    "example": "1000"
+                },
+                "min": {
+                    "description": "The minimum value of the input (inclusive)",
+                    "type": "string",
+                    "example": "1"
+                },
+                "name": {
+                    "description": "The name of the input, it must match the input used in the template",
+                    "type": "string",
+                    "example": "customer_id"
+                },
+                "options": {
+                    "description": "The allowed values of enum inputs, for list inputs it's the allowed values of each item",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "prod",
+                        "staging"
+                    ]
+                },
+                "pattern": {
+                    "description": "A regular expression that text, secret inputs and the items of list inputs must match",
+                    "type": "string",
+                    "example": "^[0-9]+$"
+                },
+                "placeholder": {
+                    "description": "A placeholder of the input",
+                    "type": "string",
+                    "example": "the customer id"
+                },
+                "required": {
+                    "description": "If the input is required",
+                    "type": "boolean",
+                    "example": true
+                },
+                "separator": {
+                    "description": "The separator of the items of list inputs, defaults to comma",
+                    "type": "string",
+                    "example": ","
+                },
+                "type": {
+                    "description": "The type of the input\n* text - free text, it could be validated with a pattern\n* int - an integer number, it could be validated with a range (min, max)\n* enum - one of the options\n* date - a date in the format YYYY-MM-DD, it could be validated with a range (min, max)\n* list - a list of values separated by the separator, the range (min, max) validates the number of items\n* secret - a sensitive value, it's redacted from the session and must be passed as an environment variable to the template",
+                    "type": "string",
+                    "enum": [
+                        "text",
+                        "int",
+                        "enum",
+                        "date",
+                        "list",
+                        "secret"
+                    ],
+                    "example": "int"
+                }
+            }
+        },
         "openapi.ServerInfo": {
             "type": "object",
             "properties": {
@@ -3724,7 +4037,7 @@ const docTemplate = `{
                     "example": 569
                 },
                 "event_stream": {
-                    "description": "The stream containing the output of the execution in the following format\n\n` + "`" + `[[0.268589438, \"i\", \"ZW52\"], ...]` + "`" + `\n\n* ` + "`" + `\u003cevent-time\u003e` + "`" + ` - relative time in miliseconds to start_date\n* ` + "`" + `\u003cevent-type\u003e` + "`" + ` - the event type as string (i: input, o: output e: output-error)\n* ` + "`" + `\u003cbase64-content\u003e` + "`" + ` - the content of the session encoded as base64 string",
+                    "description": "The stream containing the output of the execution in the following format\n\n` + "`" + `[[0.268589438, \"i\", \"ZW52\"], ...]` + "`" + `\n\n* ` + "`" + `\u003cevent-time\u003e` + "`" + ` - relative time in miliseconds to start_date\n* ` + "`" + `\u003cevent-type\u003e` + "`" + ` - the event type as string (i: input, o: output e: output-error)\n* ` + "`" + `\u003cbase64-content\u003e` + "`" + ` - the content of the session encoded as base64 string\n* ` + "`" + `\u003cintegrity-hash\u003e` + "`" + ` - the chained hash of the event, it's absent on sessions recorded without integrity\n* ` + "`" + `\u003cmetadata\u003e` + "`" + ` - an object with the metadata of the event, it's present only in events with metadata.\nE.g.: ` + "`" + `{\"postgres.params\": [\"1\", null]}` + "`" + ` are the parameters bound to a postgres statement",
                     "type": "array",
                     "items": {}
                 },
@@ -3895,6 +4208,56 @@ const docTemplate = `{
                 "type": "string"
             }
         },
+        "openapi.SessionVerifyResult": {
+            "type": "object",
+            "properties": {
+                "events_count": {
+                    "description": "The amount of events verified",
+                    "type": "integer",
+                    "example": 12
+                },
+                "key_id": {
+                    "description": "The identifier of the key used to sign the digest",
+                    "type": "string",
+                    "example": "5f0c6f9ab6f8a6d1"
+                },
+                "reason": {
+                    "description": "The reason when the session could not be verified",
+                    "type": "string",
+                    "example": "event 3 hash mismatch"
+                },
+                "session_id": {
+                    "description": "The resource unique identifier of the session",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "1CBC8DB5-FBF8-4293-8E35-59A6EEA40207"
+                },
+                "source": {
+                    "description": "Where the events were loaded from\n* blobstore - the full recording of the session\n* database - the event stream stored in the database, it could be truncated",
+                    "type": "string",
+                    "enum": [
+                        "blobstore",
+                        "database"
+                    ],
+                    "example": "database"
+                },
+                "tampered_event_index": {
+                    "description": "The index of the first tampered event. It's equal to ` + "`" + `events_count` + "`" + ` when events were removed or appended to the end of the session",
+                    "type": "integer",
+                    "example": 3
+                },
+                "unverifiable": {
+                    "description": "It's true when the event stream in the database is truncated and the digest could not be verified.\nAll the stored events matched the chain of hashes.",
+                    "type": "boolean",
+                    "example": false
+                },
+                "verified": {
+                    "description": "It's true when the chain of hashes of all events and the signature of the digest are valid",
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
         "openapi.SignupRequest": {
             "type": "object",
             "required": [
@@ -188,7 +188,12 @@ type Connection struct {
 	// * mysql - Implements MySQL protocol
 	// * mongodb - Implements MongoDB Wire Protocol
 	// * mssql - Implements Microsoft SQL Server Protocol
+	// * oracledb - Implements Oracle Transparent Network Substrate (TNS) Protocol
+	// * redis - Implements Redis Serialization Protocol (RESP)
 	// * tcp - Forwards a TCP connection
+	// * kubernetes - Executes commands in a pod or forwards connections to a service of a Kubernetes cluster
+	// * ssh - Implements the Secure Shell Protocol (SSH)
+	// * http - Forwards HTTP requests to an internal server injecting the credentials as headers
 	SubType string `json:"subtype" example:"postgres"`
 	// Secrets are environment variables that are going to be exposed
 	// in the runtime of the connection:
@@ -198,6 +203,7 @@ type Connection struct {
 	// The value could also represent an integration with a external provider:
 	// * { envvar:[env-key]: _aws:[secret-name]:[secret-key] } - Obtain the value dynamically in the AWS secrets manager and expose as environment variable
 	// * { envvar:[env-key]: _envjson:[json-env-name]:[json-env-key] } - Obtain the value dynamically from a JSON env in the agent runtime. Example: MYENV={"KEY": "val"}
+	// * { envvar:[env-key]: _vault:[secret-path]:[secret-key] } - Obtain the value dynamically from Hashicorp Vault (kv v1/v2 or dynamic credentials revoked when the session ends). Example: _vault:database/creds/readonly:username
 	Secrets map[string]any `json:"secret"`
 	// The agent associated with this connection
 	AgentId string `json:"agent_id" binding:"required" format:"uuid" example:"1837453e-01fc-46f3-9e4c-dcf22d395393"`
@@ -205,12 +211,19 @@ type Connection struct {
 	// * online - The agent is connected and alive
 	// * offline - The agent is not connected
 	Status string `json:"status" readonly:"true" enums:"online,offline"`
-	// Reviewers is a list of groups that will review the connection before the user could execute it
+	// Reviewers is a list of groups that will review the connection before the user could execute it.
+	// It also accepts options to configure the approval workflow:
+	// * `dba;stage=2` - The group reviews after the groups of the previous stages approve it
+	// * `sre;quorum=2` - The group requires the approval of two distinct members
+	// * `expire-after=24h` - Pending reviews are rejected after the duration
+	// * `self-approval=deny` - The owner of the review can't approve it, including admins
 	Reviewers []string `json:"reviewers" example:"dba-group"`
 	// When this option is enabled it will allow managing the redact types through the attribute `redact_types`
 	RedactEnabled bool `json:"redact_enabled"`
 	// Redact Types is a list of info types that will used to redact the output of the connection.
 	// Possible values are described in the DLP documentation: https://cloud.google.com/sensitive-data-protection/docs/infotypes-reference
+	// It also accepts column masking policies for database connections in the format `<table>.<column> -> <hash|last4|redact>`,
+	// a wildcard (*) matches any table, e.g.: `*.ssn -> redact`
 	RedactTypes []string `json:"redact_types" example:"EMAIL_ADDRESS"`
 	// Managed By is a read only field that indicates who is managing this resource.
 	// When this attribute is set, this resource is considered immutable
@@ -276,6 +289,9 @@ type RunbookRequest struct {
 	FileName string `json:"file_name" binding:"required" example:"myrunbooks/run-backup.runbook.sql"`
 	// The commit sha reference to obtain the file
 	RefHash string `json:"ref_hash" example:"20320ebbf9fc612256b67dc9e899bbd6e4745c77"`
+	// The branch or tag to obtain the file, it must be one of the allowed refs (GIT_ALLOWED_REFS) of the plugin.
+	// It defaults to the ref configured in the plugin
+	Ref string `json:"ref" example:"staging"`
 	// The parameters of the runbook. It must match with the declared attributes
 	Parameters map[string]string `json:"parameters" example:"amount:10,wallet_id:6736"`
 	// Additional arguments to pass down to the connection
@@ -319,6 +335,9 @@ type Runbook struct {
 	Metadata map[string]any `json:"metadata"`
 	// The connections that could be used for this runbook
 	ConnectionList []string `json:"connections,omitempty" example:"pgdemo,bash"`
+	// The typed inputs declared in the schema file of the runbook (`<runbook-file>.schema.yaml`), it's empty when the runbook has no schema.
+	// The attributes of the schema are also reflected in the `metadata` field.
+	Schema *RunbookSchema `json:"schema,omitempty"`
 	// The error description if it failed to render
 	Error      *string           `json:"error"`
 	EnvVars    map[string]string `json:"-"`
@@ -326,6 +345,45 @@ type Runbook struct {
 	CommitHash string            `json:"-"`
 }
 
+type RunbookSchema struct {
+	// The inputs of the runbook, in the order they should be asked
+	Inputs []RunbookSchemaInput `json:"inputs" yaml:"inputs"`
+}
+
+type RunbookSchemaInput struct {
+	// The name of the input, it must match the input used in the template
+	Name string `json:"name" yaml:"name" example:"customer_id"`
+	// The type of the input
+	// * text - free text, it could be validated with a pattern
+	// * int - an integer number, it could be validated with a range (min, max)
+	// * enum - one of the options
+	// * date - a date in the format YYYY-MM-DD, it could be validated with a range (min, max)
+	// * list - a list of values separated by the separator, the range (min, max) validates the number of items
+	// * secret - a sensitive value, it's redacted from the session and must be passed as an environment variable to the template
+	Type string `json:"type" yaml:"type" enums:"text,int,enum,date,list,secret" example:"int"`
+	// The description of the input
+	Description string `json:"description,omitempty" yaml:"description" example:"the id of the customer"`
+	// If the input is required
+	Required bool `json:"required" yaml:"required" example:"true"`
+	// The default value to use when the input is empty
+	Default string `json:"default,omitempty" yaml:"default" example:"1"`
+	// A placeholder of the input
+	Placeholder string `json:"placeholder,omitempty" yaml:"placeholder" example:"the customer id"`
+	// The allowed values of enum inputs, for list inputs it's the allowed values of each item
+	Options []string `json:"options,omitempty" yaml:"options" example:"prod,staging"`
+	// A regular expression that text, secret inputs and the items of list inputs must match
+	Pattern string `json:"pattern,omitempty" yaml:"pattern" example:"^[0-9]+$"`
+	// The minimum value of the input (inclusive)
+	Min string `json:"min,omitempty" yaml:"min" example:"1"`
+	// The maximum value of the input (inclusive)
+	Max string `json:"max,omitempty" yaml:"max" example:"1000"`
+	// The separator of the items of list inputs, defaults to comma
+	Separator string `json:"separator,omitempty" yaml:"separator" example:","`
+	// The input is enabled only when the inputs declared before it have one of the values.
+	// Disabled inputs are rendered as empty values.
+	DependsOn map[string][]string `json:"depends_on,omitempty" yaml:"depends_on"`
+}
+
 type SessionList struct {
 	Items       []Session `json:"data"`
 	Total       int64     `json:"total" example:"100"`
@@ -344,6 +402,7 @@ type SessionGetByIDParams struct {
 	// The file extension to donwload the session as a file content.
 	// * `csv` - it will parse the content to format in csv format
 	// * `json` - it will parse the content as a json stream.
+	// * `cast` - it will parse the content as an asciicast v2 file with the timing of each event
 	// * `<any-format>` - No special parsing is applied
 	Extension string `json:"extension" example:"csv"`
 	// Choose the type of events to include
@@ -424,6 +483,9 @@ type Session struct {
 	// * `<event-time>` - relative time in miliseconds to start_date
 	// * `<event-type>` - the event type as string (i: input, o: output e: output-error)
 	// * `<base64-content>` - the content of the session encoded as base64 string
+	// * `<integrity-hash>` - the chained hash of the event, it's absent on sessions recorded without integrity
+	// * `<metadata>` - an object with the metadata of the event, it's present only in events with metadata.
+	//   E.g.: `{"postgres.params": ["1", null]}` are the parameters bound to a postgres statement
 	EventStream      SessionEventStream                   `json:"event_stream"`
 	NonIndexedStream SessionNonIndexedEventStreamListType `json:"-"`
 	// The stored resource size in bytes
@@ -434,6 +496,28 @@ type Session struct {
 	EndSession *time.Time `json:"end_date" example:"2024-07-25T15:56:35.361101Z"`
 }
 
+type SessionVerifyResult struct {
+	// The resource unique identifier of the session
+	SessionID string `json:"session_id" format:"uuid" example:"1CBC8DB5-FBF8-4293-8E35-59A6EEA40207"`
+	// It's true when the chain of hashes of all events and the signature of the digest are valid
+	Verified bool `json:"verified" example:"false"`
+	// Where the events were loaded from
+	// * blobstore - the full recording of the session
+	// * database - the event stream stored in the database, it could be truncated
+	Source string `json:"source" enums:"blobstore,database" example:"database"`
+	// The amount of events verified
+	EventsCount int `json:"events_count" example:"12"`
+	// The index of the first tampered event. It's equal to `events_count` when events were removed or appended to the end of the session
+	TamperedEventIndex *int `json:"tampered_event_index" example:"3"`
+	// It's true when the event stream in the database is truncated and the digest could not be verified.
+	// All the stored events matched the signed truncation point of the integrity record.
+	Unverifiable bool `json:"unverifiable" example:"false"`
+	// The identifier of the key used to sign the digest
+	KeyID string `json:"key_id" example:"5f0c6f9ab6f8a6d1"`
+	// The reason when the session could not be verified
+	Reason string `json:"reason" example:"event 3 hash mismatch"`
+}
+
 type SessionReportParams struct {
 	// Group by this field
 	GroupBy string `json:"group_by" enums:"connection,connection_type,id,user_email" default:"connection" example:"connection_type"`
@@ -518,6 +602,9 @@ type Review struct {
 	InputClientArgs []string `json:"input_clientargs" readonly:"true" example:"-x"`
 	// The amount of time (nanoseconds) to allow access to the connection. It's valid only for `jit` type reviews`
 	AccessDuration time.Duration `json:"access_duration" swaggertype:"integer" readonly:"true" default:"1800000000000" example:"0"`
+	// The start of a scheduled access window. The access is valid from this time until it's added the access duration.
+	// It's valid only for `jit` type reviews
+	AccessStartAt *time.Time `json:"access_start_at" readonly:"true" example:"2024-07-27T02:00:00Z"`
 	// The status of the review
 	// * PENDING - The resource is waiting to be reviewed
 	// * APPROVED - The resource is fully approved
@@ -535,6 +622,12 @@ type Review struct {
 	Connection ReviewConnection `json:"review_connection" readonly:"true"`
 	// Contains the groups that requires to approve this review
 	ReviewGroupsData []ReviewGroup `json:"review_groups_data" readonly:"true"`
+	// The stage of the workflow being reviewed, the groups of this stage could approve it in any order
+	CurrentStage int `json:"current_stage" readonly:"true" example:"1"`
+	// The time when this review expires if it's still pending
+	ExpireAt *time.Time `json:"expire_at" readonly:"true" example:"2024-07-26T15:56:35Z"`
+	// If the owner of this review is not allowed to approve it, including admins
+	DenySelfApproval bool `json:"deny_self_approval" readonly:"true"`
 }
 
 type ReviewOwner struct {
@@ -569,6 +662,12 @@ type ReviewGroup struct {
 	ReviewedBy *ReviewOwner `json:"reviewed_by" readonly:"true"`
 	// The date which this review was performed
 	ReviewDate *string `json:"review_date" readonly:"true" example:"2024-07-25T19:36:41Z"`
+	// The stage of the workflow which this group reviews
+	Stage int `json:"stage" readonly:"true" example:"1"`
+	// The number of distinct approvals required by this group
+	Quorum int `json:"quorum" readonly:"true" example:"1"`
+	// The users that approved this group
+	Approvals []ReviewOwner `json:"approvals" readonly:"true"`
 }
 
 type Plugin struct {
@@ -576,7 +675,7 @@ type Plugin struct {
 	ID string `json:"id" format:"uuid" readonly:"true" example:"15B5A2FD-0706-4A47-B1CF-B93CCFC5B3D7"`
 	// The name of the plugin to enable
 	// * audit - Audit connections
-	// * access_control - Enable access control by groups
+	// * access_control - Enable access control by groups and statement guardrails (rule:<type>:<value>)
 	// * dlp - Enable Google Data Loss Prevention (requires further configuration)
 	// * indexer - Enable indexing session contents
 	// * review - Enable reviewing executions
@@ -627,7 +726,7 @@ const (
 	// ClientStatusReady indicates the grpc client is ready to
 	// subscribe to a new connection
 	ClientStatusReady ClientStatusType = "ready"
-	// ClientStatusConnected indicates the client has opened a new session
+	// ClientStatusConnected indicates the client has opened at least one session
 	ClientStatusConnected ClientStatusType = "connected"
 	// ClientStatusDisconnected indicates the grpc client has disconnected
 	ClientStatusDisconnected ClientStatusType = "disconnected"
@@ -638,12 +737,12 @@ type ProxyManagerResponse struct {
 	ID string `json:"id" format:"uuid" example:"20A5AABE-C35D-4F04-A5A7-C856EE6C7703"`
 	// The status of the connection request
 	// * ready - indicates the grpc client is ready to subscribe to a new connection
-	// * connected - indicates the client has opened a new session
+	// * connected - indicates the client has opened at least one session
 	// * disconnected - indicates the grpc client has disconnected
 	Status ClientStatusType `json:"status"`
-	// The requested connection name
+	// The last requested connection name
 	RequestConnectionName string `json:"connection_name"`
-	// The requested client port to listen
+	// The last requested client port to listen
 	RequestPort string `json:"port"`
 	// The request access duration in case of review
 	RequestAccessDuration time.Duration `json:"access_duration" swaggertype:"integer" example:"1800000000000"`
@@ -651,6 +750,21 @@ type ProxyManagerResponse struct {
 	ClientMetadata map[string]string `json:"metadata" example:"session:15B3C616-6B43-4F85-B4FD-B83378A866C2,version:1.23.4,go-version:1.22.4,platform:amd64,hostname:johnwick.local"`
 	// The time (RFC3339) when the client connect
 	ConnectedAt string `json:"connected-at" example:"2024-07-25T19:36:41Z"`
+	// The connections opened by the client, each one listening in a distinct port
+	Tunnels []ProxyManagerTunnel `json:"tunnels"`
+}
+
+type ProxyManagerTunnel struct {
+	// The session identifier of the connection
+	SessionID string `json:"session_id" format:"uuid" example:"15B3C616-6B43-4F85-B4FD-B83378A866C2"`
+	// The name of the connection
+	ConnectionName string `json:"connection_name" example:"pgdemo"`
+	// The port listening in the client
+	Port string `json:"port" example:"5432"`
+	// The access duration of the session in case of review
+	AccessDuration time.Duration `json:"access_duration" swaggertype:"integer" example:"1800000000000"`
+	// The time (RFC3339) when the session was opened
+	ConnectedAt string `json:"connected-at" example:"2024-07-25T19:36:41Z"`
 }
 
 type OrgKeyResponse struct {
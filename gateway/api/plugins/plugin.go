@@ -9,12 +9,15 @@ import (
 	"github.com/getsentry/sentry-go"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/hoophq/hoop/common/dlp"
 	"github.com/hoophq/hoop/common/log"
 	"github.com/hoophq/hoop/common/proto"
 	pgconnections "github.com/hoophq/hoop/gateway/pgrest/connections"
 	pgplugins "github.com/hoophq/hoop/gateway/pgrest/plugins"
+	"github.com/hoophq/hoop/gateway/review"
 	"github.com/hoophq/hoop/gateway/storagev2"
 	"github.com/hoophq/hoop/gateway/storagev2/types"
+	"github.com/hoophq/hoop/gateway/transport/plugins/accesscontrol"
 	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
 )
 
@@ -315,6 +318,24 @@ func parsePluginConnections(c *gin.Context, req PluginRequest) ([]*types.PluginC
 		if req.Name == plugintypes.PluginDLPName && len(connConfig) == 0 {
 			connConfig = proto.DefaultInfoTypes
 		}
+		if req.Name == plugintypes.PluginDLPName {
+			if _, err := dlp.ParseColumnPolicies(connConfig); err != nil {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+				return nil, nil, io.EOF
+			}
+		}
+		if req.Name == plugintypes.PluginReviewName && len(connConfig) > 0 {
+			if _, err := review.ParseWorkflow(connConfig); err != nil {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+				return nil, nil, io.EOF
+			}
+		}
+		if req.Name == plugintypes.PluginAccessControlName {
+			if err := accesscontrol.ValidateRules(connConfig); err != nil {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+				return nil, nil, io.EOF
+			}
+		}
 		// create deterministic uuid to allow plugin connection entities
 		// to be updated instead of generating new ones
 		docUUID := uuid.NewSHA1(uuid.NameSpaceURL, []byte(fmt.Sprintf("%s:%s", req.Name, conn.Id)))
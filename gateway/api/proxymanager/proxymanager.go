@@ -42,15 +42,7 @@ func Get(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"message": "entity not found"})
 		return
 	}
-	c.JSON(http.StatusOK, &openapi.ProxyManagerResponse{
-		ID:                    obj.ID,
-		Status:                openapi.ClientStatusType(obj.Status),
-		RequestConnectionName: obj.RequestConnectionName,
-		RequestPort:           obj.RequestPort,
-		RequestAccessDuration: obj.RequestAccessDuration,
-		ClientMetadata:        obj.ClientMetadata,
-		ConnectedAt:           obj.ConnectedAt.Format(time.RFC3339),
-	})
+	c.JSON(http.StatusOK, toOpenApi(obj))
 }
 
 // ProxyManagerConnect
@@ -58,13 +50,14 @@ func Get(c *gin.Context) {
 //	@Summary		ProxyManager Connect
 //	@Description	Send a connect request to the client. A successful response indicates the client has stablished a connection.
 //	@Description	If the connection resource has the review enabled, it returns a successful response containing the link of the review in the `Localtion` header.
+//	@Description	Multiple connections could be connected at the same time, each one must listen in a distinct port.
 //	@Tags			Proxy Manager
 //	@Accept			json
 //	@Produce		json
 //	@Param			request			body		openapi.ProxyManagerRequest	true	"The request body resource"
 //	@Success		200				{object}	openapi.ProxyManagerResponse
 //	@Header			200				{string}	Location	"It will contain the url of the review in case the connection resource has the review enabled"
-//	@Failure		400,404,422,500	{object}	openapi.HTTPError
+//	@Failure		400,404,409,422,500	{object}	openapi.HTTPError
 //	@Router			/proxymanager/connect [post]
 func Post(c *gin.Context) {
 	ctx := storagev2.ParseContext(c)
@@ -97,6 +90,9 @@ func Post(c *gin.Context) {
 			case codes.NotFound:
 				c.JSON(http.StatusNotFound, gin.H{"message": "connection not found"})
 				return
+			case codes.AlreadyExists:
+				c.JSON(http.StatusConflict, gin.H{"message": status.Message()})
+				return
 			}
 		}
 		if err == transport.ErrForceReconnect {
@@ -113,24 +109,16 @@ func Post(c *gin.Context) {
 
 		switch pkt.Type {
 		case pbclient.SessionOpenWaitingApproval:
-			obj, err := clientstate.Update(ctx, types.ClientStatusDisconnected)
-			if err != nil {
-				errMsg := fmt.Sprintf("failed updating status, err=%v", err)
+			// the session of the connection is closed by the transport layer,
+			// the other connections of the client are kept open
+			obj, err := pgproxymanager.New().FetchOne(ctx, clientstate.DeterministicClientUUID(ctx.UserID))
+			if err != nil || obj == nil {
+				errMsg := fmt.Sprintf("failed obtaining client entity, err=%v", err)
 				c.JSON(http.StatusInternalServerError, gin.H{"message": errMsg})
 				return
 			}
-			// disconnect grpc-client
-			_ = transport.DispatchDisconnect(obj)
 			c.Header("Location", string(pkt.Payload))
-			c.JSON(http.StatusOK, &openapi.ProxyManagerResponse{
-				ID:                    obj.ID,
-				Status:                openapi.ClientStatusType(obj.Status),
-				RequestConnectionName: obj.RequestConnectionName,
-				RequestPort:           obj.RequestPort,
-				RequestAccessDuration: obj.RequestAccessDuration,
-				ClientMetadata:        obj.ClientMetadata,
-				ConnectedAt:           obj.ConnectedAt.Format(time.RFC3339),
-			})
+			c.JSON(http.StatusOK, toOpenApi(obj))
 		default:
 			errMsg := fmt.Sprintf("internal error, packet %v condition not implemented", pkt.Type)
 			c.JSON(http.StatusInternalServerError, gin.H{"message": errMsg})
@@ -149,22 +137,16 @@ func Post(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, &openapi.ProxyManagerResponse{
-		ID:                    obj.ID,
-		Status:                openapi.ClientStatusType(obj.Status),
-		RequestConnectionName: obj.RequestConnectionName,
-		RequestPort:           obj.RequestPort,
-		RequestAccessDuration: obj.RequestAccessDuration,
-		ClientMetadata:        obj.ClientMetadata,
-		ConnectedAt:           obj.ConnectedAt.Format(time.RFC3339),
-	})
+	c.JSON(http.StatusOK, toOpenApi(obj))
 }
 
 // ProxyManagerDisconnect
 //
 //	@Summary		ProxyManager Disconnect
-//	@Description	Send a disconnect request. The transport layer will disconnect the connected client asynchronously
+//	@Description	Send a disconnect request. The transport layer will disconnect the connected client asynchronously.
+//	@Description	When the `connection_name` is set, only the session of this connection is closed.
 //	@Tags			Proxy Manager
+//	@Param			connection_name	query	string	false	"Disconnect only the session of this connection"	Format(string)
 //	@Produce		json
 //	@Success		202			{object}	openapi.ProxyManagerResponse
 //	@Failure		404,422,500	{object}	openapi.HTTPError
@@ -182,6 +164,27 @@ func Disconnect(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"message": "entity not found"})
 		return
 	}
+	if connectionName := c.Query("connection_name"); connectionName != "" {
+		err := transport.DispatchCloseTunnel(obj, connectionName)
+		if status, ok := status.FromError(err); ok && status.Code() == codes.NotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": status.Message()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		if len(transport.ListTunnels(obj.ID)) == 0 {
+			obj, err = clientstate.Update(ctx, types.ClientStatusReady)
+			if err != nil {
+				log.Error(err)
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "disconnected the session, but it fail to update the status"})
+				return
+			}
+		}
+		c.JSON(http.StatusAccepted, toOpenApi(obj))
+		return
+	}
 	if err := transport.DispatchDisconnect(obj); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 		return
@@ -192,7 +195,21 @@ func Disconnect(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "disconnected grpc client, but it fail to update the status"})
 		return
 	}
-	c.JSON(http.StatusAccepted, &openapi.ProxyManagerResponse{
+	c.JSON(http.StatusAccepted, toOpenApi(obj))
+}
+
+func toOpenApi(obj *types.Client) *openapi.ProxyManagerResponse {
+	tunnels := []openapi.ProxyManagerTunnel{}
+	for _, t := range transport.ListTunnels(obj.ID) {
+		tunnels = append(tunnels, openapi.ProxyManagerTunnel{
+			SessionID:      t.SessionID,
+			ConnectionName: t.ConnectionName,
+			Port:           t.Port,
+			AccessDuration: t.AccessDuration,
+			ConnectedAt:    t.ConnectedAt.Format(time.RFC3339),
+		})
+	}
+	return &openapi.ProxyManagerResponse{
 		ID:                    obj.ID,
 		Status:                openapi.ClientStatusType(obj.Status),
 		RequestConnectionName: obj.RequestConnectionName,
@@ -200,5 +217,6 @@ func Disconnect(c *gin.Context) {
 		RequestAccessDuration: obj.RequestAccessDuration,
 		ClientMetadata:        obj.ClientMetadata,
 		ConnectedAt:           obj.ConnectedAt.Format(time.RFC3339),
-	})
+		Tunnels:               tunnels,
+	}
 }
@@ -15,6 +15,10 @@ import (
 const maxTemplateSize = 1000000 // 1MB
 
 func fetchRunbookFile(config *templates.RunbookConfig, req openapi.RunbookRequest) (*openapi.Runbook, error) {
+	config, err := config.WithRef(req.Ref)
+	if err != nil {
+		return nil, err
+	}
 	c, err := templates.FetchRepo(config)
 	if err != nil {
 		return nil, err
@@ -39,12 +43,24 @@ func fetchRunbookFile(config *templates.RunbookConfig, req openapi.RunbookReques
 			if err != nil {
 				return nil, err
 			}
+			schema, err := loadSchema(f.Name, ctree)
+			if err != nil {
+				return nil, err
+			}
+			if err := templates.ValidateSchemaTemplate(schema, t.Attributes()); err != nil {
+				return nil, err
+			}
+			inputs, err := templates.ValidateInputs(schema, req.Parameters)
+			if err != nil {
+				return nil, err
+			}
 			parsedTemplate := bytes.NewBuffer([]byte{})
-			if err := t.Execute(parsedTemplate, req.Parameters); err != nil {
+			if err := t.Execute(parsedTemplate, inputs); err != nil {
 				return nil, err
 			}
 			return &openapi.Runbook{
 				Name:       f.Name,
+				Schema:     schema,
 				InputFile:  parsedTemplate.Bytes(),
 				EnvVars:    t.EnvVars(),
 				CommitHash: c.Hash.String()}, nil
@@ -107,8 +123,18 @@ func listRunbookFiles(pluginConnectionList []*types.PluginConnection, config *te
 				connectionList = append(connectionList, conn.Name)
 			}
 		}
+		schema, err := loadSchema(f.Name, ctree)
+		if err == nil {
+			err = templates.ValidateSchemaTemplate(schema, t.Attributes())
+		}
+		if err != nil {
+			runbook.Error = toPtrStr(err)
+			runbookList.Items = append(runbookList.Items, runbook)
+			return nil
+		}
 		runbook.ConnectionList = connectionList
-		runbook.Metadata = t.Attributes()
+		runbook.Schema = schema
+		runbook.Metadata = templates.SchemaAttributes(schema, t.Attributes())
 		runbookList.Items = append(runbookList.Items, runbook)
 		return nil
 	})
@@ -159,13 +185,46 @@ func listRunbookFilesByPathPrefix(pathPrefix string, config *templates.RunbookCo
 			runbookList.Items = append(runbookList.Items, runbook)
 			return nil
 		}
+		schema, err := loadSchema(f.Name, ctree)
+		if err == nil {
+			err = templates.ValidateSchemaTemplate(schema, t.Attributes())
+		}
+		if err != nil {
+			runbook.Error = toPtrStr(err)
+			runbookList.Items = append(runbookList.Items, runbook)
+			return nil
+		}
 		runbook.ConnectionList = nil
-		runbook.Metadata = t.Attributes()
+		runbook.Schema = schema
+		runbook.Metadata = templates.SchemaAttributes(schema, t.Attributes())
 		runbookList.Items = append(runbookList.Items, runbook)
 		return nil
 	})
 }
 
+// loadSchema returns the schema of the runbook file if it exists in the tree
+func loadSchema(fileName string, ctree *object.Tree) (*openapi.RunbookSchema, error) {
+	f, err := ctree.File(templates.SchemaFileName(fileName))
+	if err == object.ErrFileNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed reading schema file: %v", err)
+	}
+	blob, err := templates.ReadBlob(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(blob) > maxTemplateSize {
+		return nil, fmt.Errorf("max schema size [%v KB] reached for %v", maxTemplateSize/1000, f.Name)
+	}
+	schema, err := templates.ParseSchema(blob)
+	if err != nil {
+		return nil, fmt.Errorf("schema parse error: %v", err)
+	}
+	return schema, nil
+}
+
 func toPtrStr(v any) *string {
 	if v == nil || fmt.Sprintf("%v", v) == "" {
 		return nil
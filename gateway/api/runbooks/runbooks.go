@@ -33,8 +33,9 @@ import (
 //	@Description	List all Runbooks
 //	@Tags			Core
 //	@Produce		json
-//	@Success		200			{object}	openapi.RunbookList
-//	@Failure		404,422,500	{object}	openapi.HTTPError
+//	@Param			ref				query		string	false	"The branch or tag of the repository allowed in the plugin, it defaults to the ref configured in the plugin"
+//	@Success		200				{object}	openapi.RunbookList
+//	@Failure		400,404,422,500	{object}	openapi.HTTPError
 //	@Router			/plugins/runbooks/templates [get]
 func List(c *gin.Context) {
 	ctx := storagev2.ParseContext(c)
@@ -61,6 +62,11 @@ func List(c *gin.Context) {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
 		return
 	}
+	config, err = config.WithRef(c.Query("ref"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
 	runbookList, err := listRunbookFiles(p.Connections, config)
 	if err != nil {
 		log.Infof("failed listing runbooks, err=%v", err)
@@ -77,6 +83,7 @@ func List(c *gin.Context) {
 //	@Tags			Core
 //	@Produce		json
 //	@Param			name			path		string	true	"The name of the connection"
+//	@Param			ref				query		string	false	"The branch or tag of the repository allowed in the plugin, it defaults to the ref configured in the plugin"
 //	@Success		200				{object}	openapi.RunbookList
 //	@Failure		400,404,422,500	{object}	openapi.HTTPError
 //	@Router			/plugins/runbooks/connections/{name}/templates [get]
@@ -119,6 +126,11 @@ func ListByConnection(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"message": "runbooks plugin does not have this connection"})
 		return
 	}
+	config, err = config.WithRef(c.Query("ref"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		return
+	}
 	runbookList, err := listRunbookFilesByPathPrefix(pathPrefix, config)
 	if err != nil {
 		log.Infof("failed listing runbooks, err=%v", err)
@@ -171,12 +183,21 @@ func RunExec(c *gin.Context) {
 		return
 	}
 
-	runbookParamsJson, _ := json.Marshal(req.Parameters)
+	runbookParamsJson, _ := json.Marshal(redactSecretParameters(runbook.Schema, req.Parameters))
 	sessionLabels := types.SessionLabels{
 		"runbookFile":       req.FileName,
 		"runbookParameters": string(runbookParamsJson),
 	}
 
+	// keep track of the version of the runbook used in the execution
+	if req.Metadata == nil {
+		req.Metadata = map[string]any{}
+	}
+	req.Metadata["runbook_commit"] = runbook.CommitHash
+	if req.Ref != "" {
+		req.Metadata["runbook_ref"] = req.Ref
+	}
+
 	sessionID := uuid.NewString()
 	userAgent := apiutils.NormalizeUserAgent(c.Request.Header.Values)
 	if userAgent == "webapp.core" {
@@ -225,8 +246,8 @@ func RunExec(c *gin.Context) {
 		params += fmt.Sprintf("%s:len[%v],", key, len(val))
 	}
 	log = log.With("sid", sessionID)
-	log.Infof("runbook exec, commit=%s, name=%s, connection=%s, parameters=%v",
-		runbook.CommitHash[:8], req.FileName, connectionName, strings.TrimSpace(params))
+	log.Infof("runbook exec, commit=%s, ref=%s, name=%s, connection=%s, parameters=%v",
+		runbook.CommitHash[:8], req.Ref, req.FileName, connectionName, strings.TrimSpace(params))
 
 	respCh := make(chan *clientexec.Response)
 	go func() {
@@ -250,6 +271,23 @@ func RunExec(c *gin.Context) {
 	}
 }
 
+// redactSecretParameters prevents storing the value of secret inputs in the session
+func redactSecretParameters(schema *openapi.RunbookSchema, params map[string]string) map[string]string {
+	if schema == nil {
+		return params
+	}
+	redacted := map[string]string{}
+	for key, val := range params {
+		redacted[key] = val
+	}
+	for _, in := range schema.Inputs {
+		if _, ok := redacted[in.Name]; ok && in.Type == templates.InputTypeSecret {
+			redacted[in.Name] = "[REDACTED]"
+		}
+	}
+	return redacted
+}
+
 func getConnectionID(ctx pgrest.Context, c *gin.Context, connectionName string) (string, error) {
 	conn, err := apiconnections.FetchByName(ctx, connectionName)
 	if err != nil {
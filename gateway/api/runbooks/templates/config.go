@@ -1,11 +1,15 @@
 package templates
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"os/exec"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
@@ -17,6 +21,18 @@ import (
 type RunbookConfig struct {
 	GitURL string
 	Auth   transport.AuthMethod
+	// Ref is the branch, tag or commit sha to obtain the runbooks,
+	// it defaults to the main or master branch when it's empty
+	Ref string
+	// AllowedRefs are the refs that could be requested in place of Ref,
+	// the runbooks of any other branch, tag or commit are not used
+	AllowedRefs []string
+	// CacheTTL is the period of time a fetched repository is considered fresh,
+	// a zero value fetches the repository on every lookup
+	CacheTTL time.Duration
+
+	// identifies the repository and the credentials used to fetch it
+	cacheKey string
 }
 
 var sshKeyScanKnownHostsContent string
@@ -78,7 +94,78 @@ func parseKnownHosts(envVars map[string]string) (string, map[string]string, erro
 	return string(gitURLBytes), sshKnownHosts, nil
 }
 
+// parseCacheConfig parses the ref and the cache ttl of the repository from the plugin configuration
+func parseCacheConfig(envVars map[string]string) (ref string, ttl time.Duration, err error) {
+	ttl = defaultCacheTTL
+	if refEnc := envVars["GIT_REF"]; refEnc != "" {
+		refBytes, err := base64.StdEncoding.DecodeString(refEnc)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed decoding GIT_REF")
+		}
+		ref = strings.TrimSpace(string(refBytes))
+	}
+	if ttlEnc := envVars["GIT_CACHE_TTL"]; ttlEnc != "" {
+		ttlBytes, err := base64.StdEncoding.DecodeString(ttlEnc)
+		if err != nil {
+			return "", 0, fmt.Errorf("failed decoding GIT_CACHE_TTL")
+		}
+		ttl, err = time.ParseDuration(string(ttlBytes))
+		if err != nil || ttl < 0 {
+			return "", 0, fmt.Errorf("wrong option (%q) for GIT_CACHE_TTL, expected a duration, e.g.: 5m", ttlBytes)
+		}
+	}
+	return
+}
+
+// newCacheKey returns a key that identifies the repository and the credentials of the plugin,
+// a repository is never shared between distinct credentials
+func newCacheKey(envVars map[string]string) string {
+	h := sha256.New()
+	for _, key := range []string{"GIT_URL", "GIT_USER", "GIT_PASSWORD", "GIT_SSH_KEY", "GIT_SSH_USER", "GIT_SSH_KEYPASS"} {
+		_, _ = h.Write([]byte(key + "=" + envVars[key] + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func NewRunbookConfig(envVars map[string]string) (*RunbookConfig, error) {
+	config, err := newRunbookConfig(envVars)
+	if err != nil {
+		return nil, err
+	}
+	config.Ref, config.CacheTTL, err = parseCacheConfig(envVars)
+	if err != nil {
+		return nil, err
+	}
+	if refsEnc := envVars["GIT_ALLOWED_REFS"]; refsEnc != "" {
+		refsBytes, err := base64.StdEncoding.DecodeString(refsEnc)
+		if err != nil {
+			return nil, fmt.Errorf("failed decoding GIT_ALLOWED_REFS")
+		}
+		for _, ref := range strings.Split(string(refsBytes), ",") {
+			if ref = strings.TrimSpace(ref); ref != "" {
+				config.AllowedRefs = append(config.AllowedRefs, ref)
+			}
+		}
+	}
+	config.cacheKey = newCacheKey(envVars)
+	return config, nil
+}
+
+// WithRef returns a copy of the configuration using the ref of a request,
+// it allows using runbooks from the branches or tags of GIT_ALLOWED_REFS
+func (c *RunbookConfig) WithRef(ref string) (*RunbookConfig, error) {
+	if ref == "" || ref == c.Ref {
+		return c, nil
+	}
+	if !slices.Contains(c.AllowedRefs, ref) {
+		return nil, fmt.Errorf("ref %q is not allowed, add it to the GIT_ALLOWED_REFS of the plugin", ref)
+	}
+	newConfig := *c
+	newConfig.Ref = ref
+	return &newConfig, nil
+}
+
+func newRunbookConfig(envVars map[string]string) (*RunbookConfig, error) {
 	gitURL, knownHosts, err := parseKnownHosts(envVars)
 	if err != nil {
 		return nil, err
@@ -118,7 +205,7 @@ func NewRunbookConfig(envVars map[string]string) (*RunbookConfig, error) {
 		// It uses a custom callback function instead of relying in the known hosts
 		// file from the filesystem.
 		auth.HostKeyCallback = trustedHostKeyCallback(knownHosts)
-		return &RunbookConfig{gitURL, auth}, nil
+		return &RunbookConfig{GitURL: gitURL, Auth: auth}, nil
 	case gitPasswordEnc != "":
 		gitPassword, err := base64.StdEncoding.DecodeString(gitPasswordEnc)
 		if err != nil {
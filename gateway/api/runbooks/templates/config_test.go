@@ -0,0 +1,59 @@
+package templates
+
+import (
+	"encoding/base64"
+	"slices"
+	"testing"
+)
+
+func TestNewRunbookConfigAllowedRefs(t *testing.T) {
+	encode := func(v string) string { return base64.StdEncoding.EncodeToString([]byte(v)) }
+	config, err := NewRunbookConfig(map[string]string{
+		"GIT_URL":          encode("https://github.com/hoophq/runbooks"),
+		"GIT_PASSWORD":     encode("secret"),
+		"GIT_REF":          encode("main"),
+		"GIT_ALLOWED_REFS": encode("staging, v1,,"),
+	})
+	if err != nil {
+		t.Fatalf("failed parsing config: %v", err)
+	}
+	if want := []string{"staging", "v1"}; !slices.Equal(config.AllowedRefs, want) {
+		t.Errorf("allowed refs does not match, want=%v, got=%v", want, config.AllowedRefs)
+	}
+}
+
+func TestRunbookConfigWithRef(t *testing.T) {
+	config := &RunbookConfig{Ref: "main", AllowedRefs: []string{"staging", "v1"}}
+	for _, tt := range []struct {
+		msg     string
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{msg: "it should use the configured ref when the ref is empty", ref: "", want: "main"},
+		{msg: "it should use the configured ref", ref: "main", want: "main"},
+		{msg: "it should use an allowed branch", ref: "staging", want: "staging"},
+		{msg: "it should use an allowed tag", ref: "v1", want: "v1"},
+		{msg: "it should return an error when the branch is not allowed", ref: "feature", wantErr: true},
+		{msg: "it should return an error when the ref is a commit sha", ref: "20320ebbf9fc612256b67dc9e899bbd6e4745c77", wantErr: true},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := config.WithRef(tt.ref)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got ref=%v", got.Ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed using ref: %v", err)
+			}
+			if got.Ref != tt.want {
+				t.Errorf("ref does not match, want=%v, got=%v", tt.want, got.Ref)
+			}
+		})
+	}
+	if config.Ref != "main" {
+		t.Errorf("expected the plugin config to keep the ref main, got=%v", config.Ref)
+	}
+}
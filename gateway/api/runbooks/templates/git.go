@@ -1,64 +1,219 @@
 package templates
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/hoophq/hoop/common/log"
 )
 
+const defaultCacheTTL = time.Minute * 5
+
+var (
+	errRefNotFound = errors.New("reference not found")
+	commitShaRe    = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+	repositoriesMu sync.Mutex
+	repositories   = map[string]*repository{}
+	// the directory where the repositories are cloned
+	cacheDir = filepath.Join(os.TempDir(), "hoop-runbooks")
+)
+
+// repository is a bare clone of a runbook repository kept on disk,
+// all the branches and tags are fetched to resolve any configured ref.
+type repository struct {
+	dir string
+	// serializes the fetches of the repository
+	fetchMu  sync.Mutex
+	fetching atomic.Bool
+
+	// the references are resolved from a snapshot taken after each fetch,
+	// it allows reading the objects while the repository is being fetched
+	mu        sync.RWMutex
+	refs      map[plumbing.ReferenceName]plumbing.Hash
+	lastFetch time.Time
+}
+
+func getRepository(rbConfig *RunbookConfig) *repository {
+	repositoriesMu.Lock()
+	defer repositoriesMu.Unlock()
+	key := rbConfig.cacheKey
+	if key == "" {
+		key = newCacheKey(map[string]string{"GIT_URL": rbConfig.GitURL})
+	}
+	if r, ok := repositories[key]; ok {
+		return r
+	}
+	r := &repository{dir: filepath.Join(cacheDir, key)}
+	repositories[key] = r
+	return r
+}
+
+// FetchRepo returns the commit of the configured ref. The repository is fetched when it's
+// the first lookup or the ref is not found locally, after the cache ttl expires it's
+// updated in background and the last known commit of the ref is returned.
 func FetchRepo(rbConfig *RunbookConfig) (*object.Commit, error) {
-	r, err := git.Init(memory.NewStorage(), memfs.New())
-	if err != nil {
-		return nil, err
+	r := getRepository(rbConfig)
+	// a commit never changes, there's no need to fetch it again
+	if isCommitSha(rbConfig.Ref) {
+		if c, err := r.resolve(rbConfig.Ref); err == nil {
+			return c, nil
+		}
+	}
+	startedAt := time.Now().UTC()
+	r.mu.RLock()
+	lastFetch := r.lastFetch
+	r.mu.RUnlock()
+	fetched := false
+	switch {
+	case lastFetch.IsZero() || rbConfig.CacheTTL == 0:
+		if err := r.fetch(rbConfig, startedAt); err != nil {
+			return nil, err
+		}
+		fetched = true
+	case time.Since(lastFetch) > rbConfig.CacheTTL:
+		r.fetchAsync(rbConfig)
+	}
+	c, err := r.resolve(rbConfig.Ref)
+	if errors.Is(err, errRefNotFound) && !fetched {
+		// the ref could have been created after the last fetch
+		if err := r.fetch(rbConfig, startedAt); err != nil {
+			return nil, err
+		}
+		c, err = r.resolve(rbConfig.Ref)
 	}
+	return c, err
+}
 
-	_, err = r.CreateRemote(&config.RemoteConfig{
-		Name: "origin",
-		URLs: []string{rbConfig.GitURL},
-	})
+// RefreshRepo fetches the repository ignoring the cache ttl
+func RefreshRepo(rbConfig *RunbookConfig) error {
+	return getRepository(rbConfig).fetch(rbConfig, time.Now().UTC())
+}
+
+func (r *repository) fetchAsync(rbConfig *RunbookConfig) {
+	if !r.fetching.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer r.fetching.Store(false)
+		if err := r.fetch(rbConfig, time.Now().UTC()); err != nil {
+			log.Warnf("failed refreshing runbook repository %v, reason=%v", rbConfig.GitURL, err)
+		}
+	}()
+}
+
+// fetch updates all the branches and tags of the repository. It's a noop if
+// the repository was fetched after since, concurrent lookups share the same fetch.
+func (r *repository) fetch(rbConfig *RunbookConfig, since time.Time) error {
+	r.fetchMu.Lock()
+	defer r.fetchMu.Unlock()
+	r.mu.RLock()
+	lastFetch := r.lastFetch
+	r.mu.RUnlock()
+	if lastFetch.After(since) {
+		return nil
+	}
+	repo, err := git.PlainOpen(r.dir)
+	if err == git.ErrRepositoryNotExists {
+		repo, err = git.PlainInit(r.dir, true)
+		if err != nil {
+			return fmt.Errorf("failed creating repository, err=%v", err)
+		}
+		_, err = repo.CreateRemote(&config.RemoteConfig{
+			Name: "origin",
+			URLs: []string{rbConfig.GitURL},
+		})
+		if err != nil {
+			return fmt.Errorf("failed creating remote, err=%v", err)
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed creating remote, err=%v", err)
+		return fmt.Errorf("failed opening repository, err=%v", err)
 	}
-	err = r.Fetch(&git.FetchOptions{
+	err = repo.Fetch(&git.FetchOptions{
 		RemoteURL:  rbConfig.GitURL,
 		Auth:       rbConfig.Auth,
 		RemoteName: "origin",
-		Tags:       git.NoTags,
-		Depth:      1,
-		// RefSpecs:   []config.RefSpec{"refs/heads/main:refs/heads/main"},
+		RefSpecs:   []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+		Tags:       git.AllTags,
+		Force:      true,
 	})
 	if err != nil && err != git.NoErrAlreadyUpToDate {
-		return nil, fmt.Errorf("failed pulling repo %v, err=%v", rbConfig.GitURL, err)
+		return fmt.Errorf("failed pulling repo %v, err=%v", rbConfig.GitURL, err)
 	}
-	refs, err := r.References()
+	iter, err := repo.References()
 	if err != nil {
-		return nil, fmt.Errorf("failed getting references, err=%v", err)
+		return fmt.Errorf("failed getting references, err=%v", err)
 	}
-	var refList []string
-	var resRef *plumbing.Reference
-	refs.ForEach(func(ref *plumbing.Reference) error {
-		if resRef != nil {
-			return nil
-		}
+	refs := map[plumbing.ReferenceName]plumbing.Hash{}
+	_ = iter.ForEach(func(ref *plumbing.Reference) error {
 		// The HEAD is omitted in a `git show-ref` so we ignore the symbolic
 		// references, the HEAD
-		if ref.Type() == plumbing.SymbolicReference {
-			return nil
+		if ref.Type() != plumbing.SymbolicReference {
+			refs[ref.Name()] = ref.Hash()
 		}
-		if ref.Name() == "refs/remotes/origin/master" || ref.Name() == "refs/remotes/origin/main" {
-			resRef = ref
-			return nil
-		}
-		refList = append(refList, fmt.Sprintf("%v=%s", ref.Name(), ref.Hash().String()))
 		return nil
 	})
-	if resRef != nil {
-		return r.CommitObject(resRef.Hash())
+	r.mu.Lock()
+	r.refs, r.lastFetch = refs, time.Now().UTC()
+	r.mu.Unlock()
+	return nil
+}
+
+// resolve returns the commit of a branch, tag or commit sha. An empty
+// ref resolves to the main or master branch.
+func (r *repository) resolve(ref string) (*object.Commit, error) {
+	r.mu.RLock()
+	refs := r.refs
+	r.mu.RUnlock()
+	repo, err := git.PlainOpen(r.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening repository, err=%v: %w", err, errRefNotFound)
+	}
+	if isCommitSha(ref) {
+		c, err := repo.CommitObject(plumbing.NewHash(ref))
+		if err == plumbing.ErrObjectNotFound {
+			return nil, fmt.Errorf("commit %v: %w", ref, errRefNotFound)
+		}
+		return c, err
+	}
+	var refNames []plumbing.ReferenceName
+	switch {
+	case ref == "":
+		refNames = []plumbing.ReferenceName{"refs/remotes/origin/main", "refs/remotes/origin/master"}
+	case strings.HasPrefix(ref, "refs/heads/"):
+		refNames = []plumbing.ReferenceName{plumbing.NewRemoteReferenceName("origin", strings.TrimPrefix(ref, "refs/heads/"))}
+	case strings.HasPrefix(ref, "refs/"):
+		refNames = []plumbing.ReferenceName{plumbing.ReferenceName(ref)}
+	default:
+		refNames = []plumbing.ReferenceName{plumbing.NewRemoteReferenceName("origin", ref), plumbing.NewTagReferenceName(ref)}
 	}
-	return nil, fmt.Errorf("master or main ref not found. refs=%v", refList)
+	for _, name := range refNames {
+		hash, ok := refs[name]
+		if !ok {
+			continue
+		}
+		// annotated tags point to a tag object
+		if tag, err := repo.TagObject(hash); err == nil {
+			return tag.Commit()
+		}
+		return repo.CommitObject(hash)
+	}
+	if ref == "" {
+		return nil, fmt.Errorf("master or main ref not found: %w", errRefNotFound)
+	}
+	return nil, fmt.Errorf("ref %v: %w", ref, errRefNotFound)
 }
+
+func isCommitSha(ref string) bool { return commitShaRe.MatchString(ref) }
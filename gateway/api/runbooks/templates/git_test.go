@@ -0,0 +1,147 @@
+package templates
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func newTestRepository(t *testing.T) (string, *git.Repository) {
+	dir := t.TempDir()
+	repo, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")},
+	})
+	if err != nil {
+		t.Fatalf("failed creating repository: %v", err)
+	}
+	return dir, repo
+}
+
+func commitFile(t *testing.T, repo *git.Repository, dir, name, content string) plumbing.Hash {
+	filePath := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add(name); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := wt.Commit("update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "hoop", Email: "hoop@localhost", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func createBranch(t *testing.T, repo *git.Repository, name string, hash plumbing.Hash) {
+	ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), hash)
+	if err := repo.Storer.SetReference(ref); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFetchRepoRefs(t *testing.T) {
+	cacheDir = t.TempDir()
+	dir, repo := newTestRepository(t)
+	firstCommit := commitFile(t, repo, dir, "ops/backup.runbook.sh", "echo v1")
+	if _, err := repo.CreateTag("v1", firstCommit, &git.CreateTagOptions{
+		Tagger: &object.Signature{Name: "hoop", Email: "hoop@localhost", When: time.Now()}, Message: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+	mainCommit := commitFile(t, repo, dir, "ops/backup.runbook.sh", "echo v2")
+	createBranch(t, repo, "staging", firstCommit)
+
+	for _, tt := range []struct {
+		msg     string
+		ref     string
+		want    plumbing.Hash
+		wantErr error
+	}{
+		{msg: "it should resolve the main branch when the ref is empty", ref: "", want: mainCommit},
+		{msg: "it should resolve a branch", ref: "staging", want: firstCommit},
+		{msg: "it should resolve a branch by the full reference name", ref: "refs/heads/staging", want: firstCommit},
+		{msg: "it should resolve an annotated tag", ref: "v1", want: firstCommit},
+		{msg: "it should resolve a commit sha", ref: firstCommit.String(), want: firstCommit},
+		{msg: "it should return an error when the ref does not exist", ref: "unknown", wantErr: errRefNotFound},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			c, err := FetchRepo(&RunbookConfig{GitURL: dir, Ref: tt.ref, CacheTTL: time.Hour})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected error %v, got=%v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed fetching repository: %v", err)
+			}
+			if c.Hash != tt.want {
+				t.Errorf("commit does not match, want=%v, got=%v", tt.want, c.Hash)
+			}
+		})
+	}
+}
+
+func TestFetchRepoCache(t *testing.T) {
+	cacheDir = t.TempDir()
+	dir, repo := newTestRepository(t)
+	firstCommit := commitFile(t, repo, dir, "deploy.runbook.sh", "echo v1")
+	config := &RunbookConfig{GitURL: dir, CacheTTL: time.Hour}
+
+	t.Run("it should return the cached commit when the ttl is not expired", func(t *testing.T) {
+		if _, err := FetchRepo(config); err != nil {
+			t.Fatal(err)
+		}
+		commitFile(t, repo, dir, "deploy.runbook.sh", "echo v2")
+		c, err := FetchRepo(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Hash != firstCommit {
+			t.Errorf("expected cached commit %v, got=%v", firstCommit, c.Hash)
+		}
+	})
+
+	t.Run("it should fetch the repository when the ref is not found in the cache", func(t *testing.T) {
+		head, err := repo.Head()
+		if err != nil {
+			t.Fatal(err)
+		}
+		createBranch(t, repo, "feature", head.Hash())
+		c, err := FetchRepo(&RunbookConfig{GitURL: dir, Ref: "feature", CacheTTL: time.Hour})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Hash != head.Hash() {
+			t.Errorf("commit does not match, want=%v, got=%v", head.Hash(), c.Hash)
+		}
+	})
+
+	t.Run("it should return the latest commit after refreshing the repository", func(t *testing.T) {
+		lastCommit := commitFile(t, repo, dir, "deploy.runbook.sh", "echo v3")
+		if err := RefreshRepo(config); err != nil {
+			t.Fatal(err)
+		}
+		c, err := FetchRepo(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Hash != lastCommit {
+			t.Errorf("commit does not match, want=%v, got=%v", lastCommit, c.Hash)
+		}
+	})
+}
@@ -0,0 +1,297 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hoophq/hoop/gateway/api/openapi"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// SchemaFileSuffix is the suffix of the schema file of a runbook, e.g.: ops/backup.runbook.sh.schema.yaml
+	SchemaFileSuffix = ".schema.yaml"
+
+	InputTypeText   = "text"
+	InputTypeInt    = "int"
+	InputTypeEnum   = "enum"
+	InputTypeDate   = "date"
+	InputTypeList   = "list"
+	InputTypeSecret = "secret"
+
+	dateLayout           = "2006-01-02"
+	defaultListSeparator = ","
+)
+
+var regexpInputName = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// SchemaFileName returns the name of the schema file of a runbook
+func SchemaFileName(runbookFile string) string { return runbookFile + SchemaFileSuffix }
+
+// ParseSchema decodes and validates the schema file of a runbook
+func ParseSchema(data []byte) (*openapi.RunbookSchema, error) {
+	var schema openapi.RunbookSchema
+	if err := yaml.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("failed decoding schema: %v", err)
+	}
+	declared := map[string]bool{}
+	for i := range schema.Inputs {
+		in := &schema.Inputs[i]
+		if !regexpInputName.MatchString(in.Name) {
+			return nil, fmt.Errorf("input name %q must contain only letters, numbers or underscore", in.Name)
+		}
+		if declared[in.Name] {
+			return nil, fmt.Errorf("input %v is declared more than once", in.Name)
+		}
+		if in.Type == "" {
+			in.Type = InputTypeText
+		}
+		if err := validateSchemaInput(in); err != nil {
+			return nil, fmt.Errorf("input %v: %v", in.Name, err)
+		}
+		// it prevents circular dependencies
+		for depName := range in.DependsOn {
+			if !declared[depName] {
+				return nil, fmt.Errorf("input %v depends on %v, it must be declared before it", in.Name, depName)
+			}
+		}
+		declared[in.Name] = true
+	}
+	return &schema, nil
+}
+
+func validateSchemaInput(in *openapi.RunbookSchemaInput) error {
+	if in.Pattern != "" {
+		if _, err := regexp.Compile(in.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %v", err)
+		}
+	}
+	switch in.Type {
+	case InputTypeText, InputTypeSecret:
+	case InputTypeEnum:
+		if len(in.Options) == 0 {
+			return fmt.Errorf("enum type requires options")
+		}
+	case InputTypeInt:
+		for _, v := range []string{in.Min, in.Max} {
+			if _, err := strconv.ParseInt(v, 10, 64); v != "" && err != nil {
+				return fmt.Errorf("range (%q) must be an integer", v)
+			}
+		}
+	case InputTypeDate:
+		for _, v := range []string{in.Min, in.Max} {
+			if _, err := time.Parse(dateLayout, v); v != "" && err != nil {
+				return fmt.Errorf("range (%q) must be a date in the format YYYY-MM-DD", v)
+			}
+		}
+	case InputTypeList:
+		for _, v := range []string{in.Min, in.Max} {
+			if n, err := strconv.Atoi(v); v != "" && (err != nil || n < 0) {
+				return fmt.Errorf("range (%q) must be a positive integer", v)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown type %q", in.Type)
+	}
+	if in.Default != "" {
+		if _, err := ValidateInput(*in, in.Default); err != nil {
+			return fmt.Errorf("invalid default value: %v", err)
+		}
+	}
+	return nil
+}
+
+// IsInputEnabled checks if the dependencies of the input are satisfied by the inputs
+func IsInputEnabled(in openapi.RunbookSchemaInput, inputs map[string]string) bool {
+	for depName, values := range in.DependsOn {
+		if !slices.Contains(values, inputs[depName]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateInput validates a non empty value of an input, it returns the normalized value.
+func ValidateInput(in openapi.RunbookSchemaInput, val string) (string, error) {
+	switch in.Type {
+	case InputTypeInt:
+		n, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("must be an integer")
+		}
+		if min, err := strconv.ParseInt(in.Min, 10, 64); err == nil && n < min {
+			return "", fmt.Errorf("must be greater than or equal to %v", min)
+		}
+		if max, err := strconv.ParseInt(in.Max, 10, 64); err == nil && n > max {
+			return "", fmt.Errorf("must be less than or equal to %v", max)
+		}
+		return strconv.FormatInt(n, 10), nil
+	case InputTypeEnum:
+		if !slices.Contains(in.Options, val) {
+			return "", fmt.Errorf("must be one of %v", in.Options)
+		}
+	case InputTypeDate:
+		d, err := time.Parse(dateLayout, strings.TrimSpace(val))
+		if err != nil {
+			return "", fmt.Errorf("must be a date in the format YYYY-MM-DD")
+		}
+		if min, err := time.Parse(dateLayout, in.Min); err == nil && d.Before(min) {
+			return "", fmt.Errorf("must be on or after %v", in.Min)
+		}
+		if max, err := time.Parse(dateLayout, in.Max); err == nil && d.After(max) {
+			return "", fmt.Errorf("must be on or before %v", in.Max)
+		}
+		return d.Format(dateLayout), nil
+	case InputTypeList:
+		separator := in.Separator
+		if separator == "" {
+			separator = defaultListSeparator
+		}
+		var items []string
+		for _, item := range strings.Split(val, separator) {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			if len(in.Options) > 0 && !slices.Contains(in.Options, item) {
+				return "", fmt.Errorf("item %q must be one of %v", item, in.Options)
+			}
+			if err := matchPattern(in.Pattern, item); err != nil {
+				return "", fmt.Errorf("item %q %v", item, err)
+			}
+			items = append(items, item)
+		}
+		if min, err := strconv.Atoi(in.Min); err == nil && len(items) < min {
+			return "", fmt.Errorf("must have at least %v items", min)
+		}
+		if max, err := strconv.Atoi(in.Max); err == nil && len(items) > max {
+			return "", fmt.Errorf("must have at most %v items", max)
+		}
+		return strings.Join(items, separator), nil
+	default:
+		if err := matchPattern(in.Pattern, val); err != nil {
+			return "", err
+		}
+	}
+	return val, nil
+}
+
+func matchPattern(pattern, val string) error {
+	if pattern == "" {
+		return nil
+	}
+	ok, err := regexp.MatchString(pattern, val)
+	if err != nil {
+		return fmt.Errorf("regexp error: %v", err)
+	}
+	if !ok {
+		return fmt.Errorf("pattern didn't match:%s", pattern)
+	}
+	return nil
+}
+
+// ValidateInputs validates the inputs with the schema before rendering the template. It returns the
+// inputs with the default values applied, the disabled inputs are returned as empty values.
+// Inputs not declared in the schema are kept as they are.
+func ValidateInputs(schema *openapi.RunbookSchema, inputs map[string]string) (map[string]string, error) {
+	result := map[string]string{}
+	for key, val := range inputs {
+		result[key] = val
+	}
+	if schema == nil {
+		return result, nil
+	}
+	var errs []string
+	for _, in := range schema.Inputs {
+		val := result[in.Name]
+		if !IsInputEnabled(in, result) {
+			result[in.Name] = ""
+			continue
+		}
+		if val == "" {
+			val = in.Default
+		}
+		if val == "" {
+			if in.Required {
+				errs = append(errs, fmt.Sprintf("%v is required", in.Name))
+			}
+			result[in.Name] = ""
+			continue
+		}
+		normalized, err := ValidateInput(in, val)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v %v", in.Name, err))
+			continue
+		}
+		result[in.Name] = normalized
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid inputs: %v", strings.Join(errs, "; "))
+	}
+	return result, nil
+}
+
+// ValidateSchemaTemplate checks if the template uses the inputs of the schema properly,
+// secret inputs must be passed as environment variables to prevent storing them in the session.
+func ValidateSchemaTemplate(schema *openapi.RunbookSchema, attributes map[string]any) error {
+	if schema == nil {
+		return nil
+	}
+	for _, in := range schema.Inputs {
+		if in.Type != InputTypeSecret {
+			continue
+		}
+		attr, _ := attributes[in.Name].(map[string]any)
+		if envKey, _ := attr["asenv"].(string); attr != nil && envKey == "" {
+			return fmt.Errorf("secret input %v must be passed as an environment variable, e.g.: {{ .%v | asenv \"MY_ENV\" }}",
+				in.Name, in.Name)
+		}
+	}
+	return nil
+}
+
+// SchemaAttributes returns the attributes of the template with the attributes of the schema
+// applied, it maps the types of the schema to the types of the template attributes.
+func SchemaAttributes(schema *openapi.RunbookSchema, attributes map[string]any) map[string]any {
+	if schema == nil {
+		return attributes
+	}
+	result := map[string]any{}
+	for key, val := range attributes {
+		result[key] = val
+	}
+	for _, in := range schema.Inputs {
+		attr := map[string]any{}
+		if templateAttr, ok := result[in.Name].(map[string]any); ok {
+			for key, val := range templateAttr {
+				attr[key] = val
+			}
+		}
+		attr["description"] = in.Description
+		attr["required"] = in.Required
+		switch in.Type {
+		case InputTypeInt:
+			attr["type"] = "number"
+		case InputTypeEnum:
+			attr["type"] = "select"
+			attr["options"] = in.Options
+		case InputTypeDate:
+			attr["type"] = "date"
+		case InputTypeSecret:
+			attr["type"] = "password"
+		default:
+			attr["type"] = "text"
+		}
+		if in.Default != "" {
+			attr["default"] = in.Default
+		}
+		if in.Placeholder != "" {
+			attr["placeholder"] = in.Placeholder
+		}
+		result[in.Name] = attr
+	}
+	return result
+}
@@ -0,0 +1,195 @@
+package templates
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const testSchema = `
+inputs:
+  - name: customer_id
+    type: int
+    required: true
+    min: 1
+    max: 1000
+  - name: env
+    type: enum
+    options: [prod, staging]
+    default: staging
+  - name: start_date
+    type: date
+    min: 2024-01-01
+  - name: wallet_ids
+    type: list
+    pattern: ^[0-9]+$
+    max: 3
+  - name: reason
+    required: true
+    depends_on:
+      env: [prod]
+  - name: db_password
+    type: secret
+`
+
+func TestParseSchema(t *testing.T) {
+	for _, tt := range []struct {
+		msg     string
+		schema  string
+		wantErr error
+	}{
+		{
+			msg:    "it should parse a schema with all input types",
+			schema: testSchema,
+		},
+		{
+			msg:     "it should return an error when the type is unknown",
+			schema:  "inputs: [{name: amount, type: float}]",
+			wantErr: fmt.Errorf(`input amount: unknown type "float"`),
+		},
+		{
+			msg:     "it should return an error when the name has invalid characters",
+			schema:  "inputs: [{name: my-input}]",
+			wantErr: fmt.Errorf(`input name "my-input" must contain only letters, numbers or underscore`),
+		},
+		{
+			msg:     "it should return an error when an input is declared more than once",
+			schema:  "inputs: [{name: amount}, {name: amount}]",
+			wantErr: fmt.Errorf(`input amount is declared more than once`),
+		},
+		{
+			msg:     "it should return an error when an enum does not have options",
+			schema:  "inputs: [{name: env, type: enum}]",
+			wantErr: fmt.Errorf(`input env: enum type requires options`),
+		},
+		{
+			msg:     "it should return an error when the range of an int is not an integer",
+			schema:  "inputs: [{name: amount, type: int, min: abc}]",
+			wantErr: fmt.Errorf(`input amount: range ("abc") must be an integer`),
+		},
+		{
+			msg:     "it should return an error when the default value is not valid",
+			schema:  "inputs: [{name: env, type: enum, options: [prod], default: dev}]",
+			wantErr: fmt.Errorf(`input env: invalid default value: must be one of [prod]`),
+		},
+		{
+			msg:     "it should return an error when it depends on an input declared after it",
+			schema:  "inputs: [{name: reason, depends_on: {env: [prod]}}, {name: env}]",
+			wantErr: fmt.Errorf(`input reason depends on env, it must be declared before it`),
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			_, err := ParseSchema([]byte(tt.schema))
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("expected error %q, got=%v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed parsing schema: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateInputs(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatalf("failed parsing schema: %v", err)
+	}
+	for _, tt := range []struct {
+		msg     string
+		inputs  map[string]string
+		want    map[string]string
+		wantErr error
+	}{
+		{
+			msg:    "it should apply the default values and disable inputs with unmet dependencies",
+			inputs: map[string]string{"customer_id": " 10", "reason": "ignored", "undeclared": "val"},
+			want: map[string]string{"customer_id": "10", "env": "staging", "start_date": "", "wallet_ids": "",
+				"reason": "", "db_password": "", "undeclared": "val"},
+		},
+		{
+			msg: "it should normalize the items of lists and validate dependent inputs",
+			inputs: map[string]string{"customer_id": "1000", "env": "prod", "start_date": "2024-02-10",
+				"wallet_ids": "10, 20,,30", "reason": "incident", "db_password": "secret"},
+			want: map[string]string{"customer_id": "1000", "env": "prod", "start_date": "2024-02-10",
+				"wallet_ids": "10,20,30", "reason": "incident", "db_password": "secret"},
+		},
+		{
+			msg:     "it should return an error when a required input is missing",
+			inputs:  map[string]string{"env": "prod"},
+			wantErr: fmt.Errorf("invalid inputs: customer_id is required; reason is required"),
+		},
+		{
+			msg:     "it should return an error when an input is out of range",
+			inputs:  map[string]string{"customer_id": "1001", "start_date": "2023-12-31"},
+			wantErr: fmt.Errorf("invalid inputs: customer_id must be less than or equal to 1000; start_date must be on or after 2024-01-01"),
+		},
+		{
+			msg:     "it should return an error when the values do not match the types",
+			inputs:  map[string]string{"customer_id": "1.5", "env": "dev", "start_date": "10/02/2024"},
+			wantErr: fmt.Errorf("invalid inputs: customer_id must be an integer; env must be one of [prod staging]; start_date must be a date in the format YYYY-MM-DD"),
+		},
+		{
+			msg:     "it should return an error when the items of a list are not valid",
+			inputs:  map[string]string{"customer_id": "1", "wallet_ids": "1,2,3,4"},
+			wantErr: fmt.Errorf("invalid inputs: wallet_ids must have at most 3 items"),
+		},
+		{
+			msg:     "it should return an error when an item of a list does not match the pattern",
+			inputs:  map[string]string{"customer_id": "1", "wallet_ids": "1,a"},
+			wantErr: fmt.Errorf(`invalid inputs: wallet_ids item "a" pattern didn't match:^[0-9]+$`),
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := ValidateInputs(schema, tt.inputs)
+			if tt.wantErr != nil {
+				if err == nil || err.Error() != tt.wantErr.Error() {
+					t.Fatalf("expected error %q, got=%v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed validating inputs: %v", err)
+			}
+			if !reflect.DeepEqual(tt.want, got) {
+				t.Errorf("inputs does not match, want=%v, got=%v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateSchemaTemplate(t *testing.T) {
+	schema, err := ParseSchema([]byte("inputs: [{name: db_password, type: secret}]"))
+	if err != nil {
+		t.Fatalf("failed parsing schema: %v", err)
+	}
+	for _, tt := range []struct {
+		msg     string
+		tmpl    string
+		wantErr bool
+	}{
+		{
+			msg:  "it should pass when the secret input is passed as an environment variable",
+			tmpl: `psql -c "SELECT 1" {{ .db_password | asenv "PGPASSWORD" }}`,
+		},
+		{
+			msg:     "it should return an error when the secret input is rendered in the template",
+			tmpl:    `psql -c "SELECT 1" -p {{ .db_password }}`,
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			tmpl, err := Parse(tt.tmpl)
+			if err != nil {
+				t.Fatalf("parse error=%v", err)
+			}
+			err = ValidateSchemaTemplate(schema, tmpl.Attributes())
+			if tt.wantErr != (err != nil) {
+				t.Errorf("expected error=%v, got=%v", tt.wantErr, err)
+			}
+		})
+	}
+}
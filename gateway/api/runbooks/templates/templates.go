@@ -136,11 +136,12 @@ func parseNode(node string) map[string]any {
 	return specs
 }
 
-// IsRunbookFile checks if the filePath contains '.runbook.' in its name
+// IsRunbookFile checks if the filePath contains '.runbook.' in its name,
+// the schema files of runbooks are ignored
 func IsRunbookFile(filePath string) bool {
 	parts := strings.Split(filePath, "/")
 	fileName := parts[len(parts)-1]
-	return strings.Contains(fileName, ".runbook.")
+	return strings.Contains(fileName, ".runbook.") && !strings.HasSuffix(fileName, SchemaFileSuffix)
 }
 
 func LookupFile(fileName string, t *object.Tree) *object.File {
@@ -261,6 +261,11 @@ func TestIsRunbookFile(t *testing.T) {
 			filePath: "team/finops/dba/charge.runb.sql",
 			want:     false,
 		},
+		{
+			msg:      "it must not match the schema file of a runbook",
+			filePath: "team/finops/dba/charge.runbook.sql.schema.yaml",
+			want:     false,
+		},
 	} {
 		t.Run(tt.msg, func(t *testing.T) {
 			got := IsRunbookFile(tt.filePath)
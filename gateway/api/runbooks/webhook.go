@@ -0,0 +1,96 @@
+package apirunbooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/gateway/api/runbooks/templates"
+	"github.com/hoophq/hoop/gateway/pgrest"
+	pgplugins "github.com/hoophq/hoop/gateway/pgrest/plugins"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+)
+
+const maxWebhookPayloadSize = 5000000 // 5MB
+
+// RefreshWebhook
+//
+//	@Summary		Runbooks Refresh Webhook
+//	@Description	Refresh the runbooks repository when a change is pushed to it. It must be configured as a push webhook in the git provider with the secret of the plugin configuration (`GIT_WEBHOOK_SECRET`).
+//	@Description	The request is validated with the `X-Hub-Signature-256` (Github, Gitea, Bitbucket) or the `X-Gitlab-Token` (Gitlab) header.
+//	@Tags			Core
+//	@Param			org_id	path	string	true	"The id of the organization"
+//	@Success		204
+//	@Failure		401,404,422,500	{object}	openapi.HTTPError
+//	@Router			/plugins/runbooks/webhooks/{org_id} [post]
+func RefreshWebhook(c *gin.Context) {
+	orgID := c.Param("org_id")
+	if _, err := uuid.Parse(orgID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "plugin runbooks not found"})
+		return
+	}
+	p, err := pgplugins.New().FetchOne(pgrest.NewOrgContext(orgID), plugintypes.PluginRunbooksName)
+	if err != nil {
+		log.Errorf("failed retrieving runbook plugin, reason=%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed retrieving runbook plugin"})
+		return
+	}
+	if p == nil || p.Config == nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "plugin runbooks not found"})
+		return
+	}
+	secret, _ := base64.StdEncoding.DecodeString(p.Config.EnvVars["GIT_WEBHOOK_SECRET"])
+	if len(secret) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"message": "webhook is not configured for the plugin runbooks"})
+		return
+	}
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookPayloadSize))
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"message": "failed reading payload"})
+		return
+	}
+	if !isValidWebhookRequest(c.Request.Header, payload, secret) {
+		log.With("org", orgID).Warnf("runbooks webhook, failed validating request signature")
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "invalid webhook signature"})
+		return
+	}
+	config, err := templates.NewRunbookConfig(p.Config.EnvVars)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"message": err.Error()})
+		return
+	}
+	// git providers expect a fast response, the repository is fetched in background
+	go func() {
+		if err := templates.RefreshRepo(config); err != nil {
+			log.With("org", orgID).Warnf("runbooks webhook, failed refreshing repository, reason=%v", err)
+			return
+		}
+		log.With("org", orgID).Infof("runbooks webhook, repository refreshed")
+	}()
+	c.Status(http.StatusNoContent)
+}
+
+func isValidWebhookRequest(header http.Header, payload, secret []byte) bool {
+	if token := header.Get("X-Gitlab-Token"); token != "" {
+		return subtle.ConstantTimeCompare([]byte(token), secret) == 1
+	}
+	signature, found := strings.CutPrefix(header.Get("X-Hub-Signature-256"), "sha256=")
+	if !found {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(payload)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -357,6 +357,10 @@ func (api *Api) buildRoutes(route *gin.RouterGroup) {
 		api.Authenticate,
 		sessionapi.Get)
 	route.GET("/sessions/:session_id/download", sessionapi.DownloadSession)
+	route.GET("/sessions/:session_id/verify",
+		AdminOnlyAccessRole,
+		api.Authenticate,
+		sessionapi.VerifySession)
 	route.GET("/sessions",
 		api.Authenticate,
 		sessionapi.List)
@@ -396,6 +400,10 @@ func (api *Api) buildRoutes(route *gin.RouterGroup) {
 		api.TrackRequest(analytics.EventExecRunbook),
 		apirunbooks.RunExec)
 
+	// it's validated by the secret of the plugin configuration
+	route.POST("/plugins/runbooks/webhooks/:org_id",
+		apirunbooks.RefreshWebhook)
+
 	route.GET("/webhooks-dashboard",
 		AdminOnlyAccessRole,
 		api.Authenticate,
@@ -22,6 +22,7 @@ import (
 	pgreview "github.com/hoophq/hoop/gateway/pgrest/review"
 	pgsession "github.com/hoophq/hoop/gateway/pgrest/session"
 	pgusers "github.com/hoophq/hoop/gateway/pgrest/users"
+	"github.com/hoophq/hoop/gateway/session/blobstore"
 	"github.com/hoophq/hoop/gateway/storagev2"
 	sessionstorage "github.com/hoophq/hoop/gateway/storagev2/session"
 	"github.com/hoophq/hoop/gateway/storagev2/types"
@@ -354,6 +355,7 @@ func DownloadSession(c *gin.Context) {
 	withEventTime := c.Query("event-time") == "1"
 	jsonFmt := strings.HasSuffix(fileExt, "json")
 	csvFmt := strings.HasSuffix(fileExt, "csv")
+	castFmt := strings.HasSuffix(fileExt, "cast")
 	var eventTypes []string
 	for _, e := range strings.Split(c.Query("events"), ",") {
 		if e == "i" || e == "o" || e == "e" {
@@ -404,7 +406,7 @@ func DownloadSession(c *gin.Context) {
 	log.With(
 		"sid", sid, "ext", fileExt,
 		"line-break", withLineBreak, "event-time", withEventTime,
-		"jsonfmt", jsonFmt, "csvfmt", csvFmt, "event-types", eventTypes).
+		"jsonfmt", jsonFmt, "csvfmt", csvFmt, "castfmt", castFmt, "event-types", eventTypes).
 		Infof("session download request, valid=%v, org=%v, user=%v, groups=%#v, user-agent=%v",
 			token == requestToken, ctx.OrgID, ctx.UserID, ctx.UserGroups, apiutils.NormalizeUserAgent(c.Request.Header.Values))
 	if token != requestToken {
@@ -422,7 +424,32 @@ func DownloadSession(c *gin.Context) {
 		return
 	}
 
-	opts := sessionParseOption{withLineBreak, withEventTime, jsonFmt, csvFmt, eventTypes}
+	opts := sessionParseOption{withLineBreak, withEventTime, jsonFmt, csvFmt, castFmt, eventTypes}
+	// stream from the blob store when available, the session in the database could be truncated
+	if store := blobstore.Default(); store != nil {
+		recording, err := store.Get(c, blobstore.ObjectKey(session.OrgID, sid))
+		switch err {
+		case nil:
+			defer recording.Close()
+			c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", sid, fileExt))
+			c.Header("Content-Type", "application/octet-stream")
+			w := newSessionFileWriter(c.Writer, session, opts)
+			err = blobstore.ReadRecording(recording, w.Write)
+			if err == nil {
+				err = w.Close()
+			}
+			log.With("sid", sid).Infof("session downloaded from blob store, extension=.%v, wrote=%v, success=%v, err=%v",
+				fileExt, c.Writer.Size(), err == nil, err)
+			return
+		case blobstore.ErrNotFound:
+		default:
+			log.With("sid", sid).Errorf("failed fetching session recording, err=%v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  http.StatusInternalServerError,
+				"message": "failed fetching session recording"})
+			return
+		}
+	}
 	output := parseSessionToFile(session, opts)
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", sid, fileExt))
 	c.Header("Content-Type", "application/octet-stream")
@@ -5,10 +5,14 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hoophq/hoop/common/memory"
+	pb "github.com/hoophq/hoop/common/proto"
 	"github.com/hoophq/hoop/gateway/api/openapi"
 	"github.com/hoophq/hoop/gateway/storagev2/types"
 )
@@ -28,51 +32,154 @@ type sessionParseOption struct {
 	withEventTime bool
 	withJsonFmt   bool
 	withCsvFmt    bool
+	withCastFmt   bool
 	events        []string
 }
 
+// asciicast v2 defaults, used when the session doesn't have the size of the terminal
+// https://docs.asciinema.org/manual/asciicast/v2/
+const (
+	castDefaultWidth  = 80
+	castDefaultHeight = 24
+)
+
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
 func WithOption(optKey openapi.SessionOptionKey, val any) *openapi.SessionOption {
 	return &openapi.SessionOption{OptionKey: optKey, OptionVal: val}
 }
 
-func parseSessionToFile(s *types.Session, opts sessionParseOption) (output []byte) {
-	var jsonEventStreamList []map[string]string
+func parseSessionToFile(s *types.Session, opts sessionParseOption) []byte {
+	var output bytes.Buffer
+	w := newSessionFileWriter(&output, s, opts)
 	for _, eventList := range s.EventStream {
-		event := eventList.(types.SessionEventStream)
-		eventTime, _ := event[0].(float64)
-		eventType, _ := event[1].(string)
-		eventData, _ := base64.StdEncoding.DecodeString(event[2].(string))
-		if !slices.Contains(opts.events, eventType) {
-			continue
+		_ = w.Write(eventList.(types.SessionEventStream))
+	}
+	_ = w.Close()
+	return output.Bytes()
+}
+
+// sessionFileWriter writes events in the format of the parse options.
+// It allows streaming large sessions without loading all events in memory.
+type sessionFileWriter struct {
+	w             io.Writer
+	startDate     time.Time
+	title         string
+	opts          sessionParseOption
+	count         int
+	headerWritten bool
+	// the size of the terminal recorded in the session
+	width, height int
+	// exec sessions don't run in a tty, the output doesn't have carriage returns
+	isTTY bool
+}
+
+func newSessionFileWriter(w io.Writer, s *types.Session, opts sessionParseOption) *sessionFileWriter {
+	fw := &sessionFileWriter{w: w, startDate: s.StartSession, title: s.Connection, opts: opts,
+		width: castDefaultWidth, height: castDefaultHeight, isTTY: s.Verb != pb.ClientVerbExec}
+	rows, cols, found := strings.Cut(s.Labels[types.SessionLabelTerminalSize], ",")
+	height, errRows := strconv.Atoi(rows)
+	width, errCols := strconv.Atoi(cols)
+	if found && errRows == nil && errCols == nil && height > 0 && width > 0 {
+		fw.width, fw.height = width, height
+	}
+	return fw
+}
+
+// writeCastHeader writes the first line of an asciicast file
+func (s *sessionFileWriter) writeCastHeader() error {
+	s.headerWritten = true
+	header, err := json.Marshal(castHeader{
+		Version:   2,
+		Width:     s.width,
+		Height:    s.height,
+		Timestamp: s.startDate.Unix(),
+		Title:     s.title,
+		Env:       map[string]string{"TERM": "xterm-256color"},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(append(header, '\n'))
+	return err
+}
+
+func (s *sessionFileWriter) Write(event types.SessionEventStream) error {
+	eventTime, _ := event[0].(float64)
+	eventType, _ := event[1].(string)
+	eventDataEnc, _ := event[2].(string)
+	eventData, _ := base64.StdEncoding.DecodeString(eventDataEnc)
+	if !slices.Contains(s.opts.events, eventType) {
+		return nil
+	}
+	var output []byte
+	switch {
+	case s.opts.withCastFmt:
+		if !s.headerWritten {
+			if err := s.writeCastHeader(); err != nil {
+				return err
+			}
+		}
+		// asciicast only renders output events, errors are part of the terminal output
+		code := "o"
+		if eventType == "i" {
+			code = "i"
 		}
-		if opts.withJsonFmt {
-			jsonEventStreamList = append(jsonEventStreamList, map[string]string{
-				"time":   s.StartSession.Add(time.Second * time.Duration(eventTime)).Format(time.RFC3339),
-				"type":   eventType,
-				"stream": string(eventData),
-			})
-			continue
+		// the output of a tty is replayed as is, the output of exec sessions
+		// requires carriage returns to render line breaks properly
+		data := string(eventData)
+		if !s.isTTY {
+			data = strings.ReplaceAll(strings.ReplaceAll(data, "\r\n", "\n"), "\n", "\r\n")
 		}
-		if opts.withEventTime {
-			eventTime := s.StartSession.Add(time.Second * time.Duration(eventTime)).Format(time.RFC3339)
-			eventTime = fmt.Sprintf("%v ", eventTime)
-			output = append(output, []byte(eventTime)...)
+		output, _ = json.Marshal([]any{eventTime, code, data})
+		output = append(output, '\n')
+	case s.opts.withJsonFmt:
+		output, _ = json.Marshal(map[string]string{
+			"time":   s.startDate.Add(time.Second * time.Duration(eventTime)).Format(time.RFC3339),
+			"type":   eventType,
+			"stream": string(eventData),
+		})
+		delimiter := byte(',')
+		if s.count == 0 {
+			delimiter = '['
 		}
-		switch eventType {
-		case "i":
-			output = append(output, eventData...)
-		case "o", "e":
-			output = append(output, eventData...)
+		output = append([]byte{delimiter}, output...)
+	default:
+		if s.opts.withEventTime {
+			eventTime := s.startDate.Add(time.Second * time.Duration(eventTime)).Format(time.RFC3339)
+			output = append(output, []byte(fmt.Sprintf("%v ", eventTime))...)
 		}
-		if opts.withLineBreak {
+		output = append(output, eventData...)
+		if s.opts.withLineBreak {
 			output = append(output, '\n')
 		}
-		if opts.withCsvFmt {
+		if s.opts.withCsvFmt {
 			output = bytes.ReplaceAll(output, []byte("\t"), []byte(`,`))
 		}
 	}
-	if opts.withJsonFmt {
-		output, _ = json.Marshal(jsonEventStreamList)
+	s.count++
+	_, err := s.w.Write(output)
+	return err
+}
+
+// Close writes the end of the json list when the json format is used
+// or the asciicast header when there are no events. The json of sessions
+// without events is null, the same as encoding an empty list of events.
+func (s *sessionFileWriter) Close() (err error) {
+	switch {
+	case s.opts.withCastFmt && !s.headerWritten:
+		err = s.writeCastHeader()
+	case s.opts.withJsonFmt && s.count == 0:
+		_, err = s.w.Write([]byte(`null`))
+	case s.opts.withJsonFmt:
+		_, err = s.w.Write([]byte(`]`))
 	}
 	return
 }
@@ -0,0 +1,84 @@
+package sessionapi
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoop/gateway/storagev2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSessionToFile(t *testing.T) {
+	startDate := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	newSession := func(events ...types.SessionEventStream) *types.Session {
+		s := &types.Session{StartSession: startDate, Connection: "pg"}
+		for _, ev := range events {
+			s.EventStream = append(s.EventStream, ev)
+		}
+		return s
+	}
+	events := []types.SessionEventStream{
+		{float64(0), "i", base64.StdEncoding.EncodeToString([]byte("SELECT 1"))},
+		{float64(1), "o", base64.StdEncoding.EncodeToString([]byte("1"))},
+	}
+	ttyOutput := types.SessionEventStream{float64(1), "o", base64.StdEncoding.EncodeToString([]byte("\x1b[2J\x1b[H\n\ttop\r\n"))}
+	ttySession := newSession(ttyOutput)
+	ttySession.Verb = "connect"
+	ttySession.Labels = types.SessionLabels{types.SessionLabelTerminalSize: "40,120"}
+	execSession := newSession(ttyOutput)
+	execSession.Verb = "exec"
+	castHeader := func(width, height int) string {
+		return fmt.Sprintf(`{"version":2,"width":%v,"height":%v,"timestamp":%v,"title":"pg","env":{"TERM":"xterm-256color"}}`+"\n",
+			width, height, startDate.Unix())
+	}
+	for _, tt := range []struct {
+		msg     string
+		session *types.Session
+		opts    sessionParseOption
+		want    string
+	}{
+		{
+			msg:     "it should encode the events as a json list",
+			session: newSession(events...),
+			opts:    sessionParseOption{withJsonFmt: true, events: []string{"i", "o"}},
+			want: `[{"stream":"SELECT 1","time":"2026-10-18T12:00:00Z","type":"i"},` +
+				`{"stream":"1","time":"2026-10-18T12:00:01Z","type":"o"}]`,
+		},
+		{
+			msg:     "it should encode sessions without events as null",
+			session: newSession(),
+			opts:    sessionParseOption{withJsonFmt: true, events: []string{"i", "o"}},
+			want:    `null`,
+		},
+		{
+			msg:     "it should encode sessions without the filtered events as null",
+			session: newSession(events...),
+			opts:    sessionParseOption{withJsonFmt: true, events: []string{"e"}},
+			want:    `null`,
+		},
+		{
+			msg:     "it should write the raw events with line breaks",
+			session: newSession(events...),
+			opts:    sessionParseOption{withLineBreak: true, events: []string{"i", "o"}},
+			want:    "SELECT 1\n1\n",
+		},
+		{
+			msg:     "it should write the tty output as is with the recorded terminal size",
+			session: ttySession,
+			opts:    sessionParseOption{withCastFmt: true, events: []string{"i", "o"}},
+			want:    castHeader(120, 40) + `[1,"o","\u001b[2J\u001b[H\n\ttop\r\n"]` + "\n",
+		},
+		{
+			msg:     "it should add carriage returns to the output of exec sessions",
+			session: execSession,
+			opts:    sessionParseOption{withCastFmt: true, events: []string{"i", "o"}},
+			want:    castHeader(80, 24) + `[1,"o","\u001b[2J\u001b[H\r\n\ttop\r\n"]` + "\n",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			assert.Equal(t, tt.want, string(parseSessionToFile(tt.session, tt.opts)))
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package sessionapi
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"github.com/hoophq/hoop/gateway/api/openapi"
+	"github.com/hoophq/hoop/gateway/appconfig"
+	pgusers "github.com/hoophq/hoop/gateway/pgrest/users"
+	"github.com/hoophq/hoop/gateway/session/blobstore"
+	"github.com/hoophq/hoop/gateway/session/integrity"
+	"github.com/hoophq/hoop/gateway/storagev2"
+	sessionstorage "github.com/hoophq/hoop/gateway/storagev2/session"
+	"github.com/hoophq/hoop/gateway/storagev2/types"
+)
+
+// VerifySession
+//
+//	@Summary		Verify Session
+//	@Description	Recompute the chain of hashes of the session events and validate the signed digest. It reports the first tampered event.
+//	@Tags			Core
+//	@Produce		json
+//	@Param			session_id	path		string	true	"The id of the resource"
+//	@Success		200			{object}	openapi.SessionVerifyResult
+//	@Failure		404,500		{object}	openapi.HTTPError
+//	@Router			/sessions/{session_id}/verify [get]
+func VerifySession(c *gin.Context) {
+	ctx := storagev2.ParseContext(c)
+	log := pgusers.ContextLogger(c)
+
+	sessionID := c.Param("session_id")
+	session, err := sessionstorage.FindOne(ctx, sessionID)
+	if err != nil {
+		log.Errorf("failed fetching session, err=%v", err)
+		sentry.CaptureException(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "failed fetching session"})
+		return
+	}
+	if session == nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "not found"})
+		return
+	}
+
+	// prefer the full recording, the event stream in the database could be truncated
+	source := "database"
+	var events []types.SessionEventStream
+	if store := blobstore.Default(); store != nil {
+		recording, err := store.Get(c, blobstore.ObjectKey(session.OrgID, sessionID))
+		switch err {
+		case nil:
+			defer recording.Close()
+			source = "blobstore"
+			err = blobstore.ReadRecording(recording, func(event types.SessionEventStream) error {
+				events = append(events, event)
+				return nil
+			})
+			if err != nil {
+				log.Errorf("failed reading session recording, err=%v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "failed reading session recording"})
+				return
+			}
+		case blobstore.ErrNotFound:
+		default:
+			log.Errorf("failed fetching session recording, err=%v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "failed fetching session recording"})
+			return
+		}
+	}
+
+	if source == "database" {
+		for i, event := range session.EventStream {
+			eventStream, ok := event.(types.SessionEventStream)
+			if !ok {
+				log.With("sid", sessionID).Warnf("session event %v is not in a valid format, type=%T", i, event)
+				c.JSON(http.StatusOK, openapi.SessionVerifyResult{
+					SessionID:          sessionID,
+					Source:             source,
+					EventsCount:        len(session.EventStream),
+					TamperedEventIndex: &i,
+					Reason:             fmt.Sprintf("event %v is not in a valid format", i),
+				})
+				return
+			}
+			events = append(events, eventStream)
+		}
+	}
+
+	var pubKey ed25519.PublicKey
+	if key := appconfig.Get().SessionSigningKey(); key != nil {
+		pubKey = key.Public().(ed25519.PublicKey)
+	}
+	header := integrity.Header{
+		OrgID:          session.OrgID,
+		SessionID:      session.ID,
+		UserID:         session.UserID,
+		ConnectionName: session.Connection,
+		ConnectionType: session.Type,
+		Verb:           session.Verb,
+	}
+	res := integrity.Verify(header, events, session.Integrity, pubKey)
+	var keyID string
+	if session.Integrity != nil {
+		keyID = session.Integrity.KeyID
+	}
+	log.With("sid", sessionID).Infof("session verified, source=%v, verified=%v, events=%v, unverifiable=%v, reason=%v",
+		source, res.Verified, res.EventsCount, res.Unverifiable, res.Reason)
+	c.JSON(http.StatusOK, openapi.SessionVerifyResult{
+		SessionID:          sessionID,
+		Verified:           res.Verified,
+		Source:             source,
+		EventsCount:        res.EventsCount,
+		TamperedEventIndex: res.TamperedEventIndex,
+		Unverifiable:       res.Unverifiable,
+		KeyID:              keyID,
+		Reason:             res.Reason,
+	})
+}
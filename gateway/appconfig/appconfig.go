@@ -1,6 +1,8 @@
 package appconfig
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
@@ -40,6 +42,9 @@ type Config struct {
 	apiHost                 string
 	apiScheme               string
 	webappUsersManagement   string
+	sessionBlobStoreURI     string
+	sessionSigningKey       ed25519.PrivateKey
+	dataMaskingHashSecret   []byte
 
 	isLoaded bool
 }
@@ -87,6 +92,14 @@ func Load() error {
 	if err != nil {
 		return err
 	}
+	sessionSigningKey, err := loadSessionSigningKey()
+	if err != nil {
+		return err
+	}
+	dataMaskingHashSecret, err := loadDataMaskingHashSecret()
+	if err != nil {
+		return err
+	}
 	webappUsersManagement := os.Getenv("WEBAPP_USERS_MANAGEMENT")
 	if webappUsersManagement == "" {
 		webappUsersManagement = "on"
@@ -107,6 +120,9 @@ func Load() error {
 		msPresidioAnonymizerURL: os.Getenv("MSPRESIDIO_ANONYMIZER_URL"),
 		webhookAppKey:           os.Getenv("WEBHOOK_APPKEY"),
 		webappUsersManagement:   webappUsersManagement,
+		sessionBlobStoreURI:     os.Getenv("SESSION_BLOB_STORE_URI"),
+		sessionSigningKey:       sessionSigningKey,
+		dataMaskingHashSecret:   dataMaskingHashSecret,
 		isLoaded:                true,
 	}
 	return nil
@@ -184,6 +200,41 @@ func loadLicensePrivateKey() (string, *rsa.PrivateKey, error) {
 	return allowedOrgID, privkey, nil
 }
 
+// loadSessionSigningKey loads an ed25519 private key in the PKCS #8 PEM format encoded as base64
+func loadSessionSigningKey() (ed25519.PrivateKey, error) {
+	b64EncPrivateKey := os.Getenv("SESSION_SIGNING_KEY")
+	if b64EncPrivateKey == "" {
+		return nil, nil
+	}
+	privKeyBytes, err := base64.StdEncoding.DecodeString(b64EncPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load SESSION_SIGNING_KEY, reason=%v", err)
+	}
+	block, _ := pem.Decode(privKeyBytes)
+	if block == nil {
+		return nil, fmt.Errorf("unable to load SESSION_SIGNING_KEY: it is not in the PEM format")
+	}
+	obj, _ := x509.ParsePKCS8PrivateKey(block.Bytes)
+	privkey, ok := obj.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unable to load SESSION_SIGNING_KEY: it is not an ed25519 private key, got=%T", obj)
+	}
+	return privkey, nil
+}
+
+// loadDataMaskingHashSecret loads the secret of the hash transformation of column masking policies.
+// A random secret is generated when it's not set, the hashes are stable only while the gateway is running.
+func loadDataMaskingHashSecret() ([]byte, error) {
+	if secret := os.Getenv("DATA_MASKING_HASH_SECRET"); secret != "" {
+		return []byte(secret), nil
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("unable to generate the data masking hash secret, reason=%v", err)
+	}
+	return secret, nil
+}
+
 func (c Config) LicenseSigningKey() (string, *rsa.PrivateKey) {
 	return c.licenseSignerOrgID, c.licenseSigningKey
 }
@@ -205,6 +256,15 @@ func (c Config) PostgRESTRole() string           { return c.pgCred.postgrestRole
 
 func (c Config) MigrationPathFiles() string { return c.migrationPathFiles }
 
+// SessionBlobStoreURI is the uri of the storage of the full session recordings
+func (c Config) SessionBlobStoreURI() string { return c.sessionBlobStoreURI }
+
+// SessionSigningKey is the key used to sign the integrity digest of sessions, it's nil when it's not set
+func (c Config) SessionSigningKey() ed25519.PrivateKey { return c.sessionSigningKey }
+
+// DataMaskingHashSecret is the secret used to derive the hash key of each connection
+func (c Config) DataMaskingHashSecret() []byte { return c.dataMaskingHashSecret }
+
 func (c Config) WebappUsersManagement() string { return c.webappUsersManagement }
 func (c Config) IsAskAIAvailable() bool        { return c.askAICredentials != nil }
 func (c Config) AskAIApiURL() (u string) {
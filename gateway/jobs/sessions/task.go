@@ -1,6 +1,7 @@
 package jobsessions
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"os"
@@ -10,8 +11,12 @@ import (
 
 	"github.com/go-co-op/gocron"
 	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/gateway/appconfig"
 	pgsession "github.com/hoophq/hoop/gateway/pgrest/session"
+	"github.com/hoophq/hoop/gateway/session/blobstore"
 	"github.com/hoophq/hoop/gateway/session/eventlog"
+	eventlogv1 "github.com/hoophq/hoop/gateway/session/eventlog/v1"
+	"github.com/hoophq/hoop/gateway/session/integrity"
 	sessionwal "github.com/hoophq/hoop/gateway/session/wal"
 	"github.com/hoophq/hoop/gateway/storagev2"
 	sessionstorage "github.com/hoophq/hoop/gateway/storagev2/session"
@@ -26,6 +31,8 @@ type (
 		nonIndexedEvents types.SessionNonIndexedEventStreamList
 		commitEndDate    *time.Time
 		commitError      string
+		// the chain of hashes of the events, it's nil for legacy event logs
+		chain *integrity.Chain
 	}
 )
 
@@ -57,7 +64,7 @@ func ProcessWalSessions(auditPath string, _ gocron.Job) {
 			log.With("sid", wh.SessionID).Warnf("failed retrieving session, err=%v", err)
 			continue
 		}
-		ev, err := readFullEventStream(walog, *wh.StartDate)
+		ev, err := readFullEventStream(walog, wh)
 		if err != nil {
 			log.With("sid", wh.SessionID).Warnf("failed reading event streams, err=%v", err)
 			continue
@@ -89,6 +96,16 @@ func ProcessWalSessions(auditPath string, _ gocron.Job) {
 		labels["processed-by"] = "job-walsessions"
 		labels["truncated"] = fmt.Sprintf("%v", ev.truncated)
 		labels["commit-error"] = fmt.Sprintf("%v", ev.commitError != "")
+		// store the full recording, the session in the database could be truncated
+		recordingPending := storeRecording(blobstore.Default(), walog, wh, labels)
+		var sessionIntegrity *types.SessionIntegrity
+		switch {
+		case ev.chain != nil && ev.truncated:
+			sessionIntegrity = integrity.SignTruncated(ev.chain, appconfig.Get().SessionSigningKey(),
+				ev.nonIndexedEvents["stream"])
+		case ev.chain != nil:
+			sessionIntegrity = integrity.Sign(ev.chain, appconfig.Get().SessionSigningKey())
+		}
 		err = pgsession.New().Upsert(ctx, types.Session{
 			ID:         wh.SessionID,
 			OrgID:      wh.OrgID,
@@ -105,6 +122,7 @@ func ProcessWalSessions(auditPath string, _ gocron.Job) {
 			// TODO: add metrics
 			NonIndexedStream: ev.nonIndexedEvents,
 			EventSize:        ev.size,
+			Integrity:        sessionIntegrity,
 			StartSession:     *wh.StartDate,
 			EndSession:       &endDate,
 		})
@@ -119,15 +137,33 @@ func ProcessWalSessions(auditPath string, _ gocron.Job) {
 			"truncated", ev.truncated, "success", err == nil, "commit-error", commitErrorMsg != "",
 		).Infof("processed %v/%v, commit-error-msg:[%s]",
 			count, len(walFolders), commitErrorMsg)
-		if err != nil {
+		switch {
+		case err != nil:
 			log.With("sid", wh.SessionID).Warnf("error=%v", err)
-		} else {
+		case recordingPending:
+			log.With("sid", wh.SessionID).Infof("keeping wal log to retry storing the session recording")
+		default:
 			_ = os.RemoveAll(walFolder)
 		}
 	}
 	log.Infof("job finished")
 }
 
+// storeRecording stores the full recording of the session in the blob store. When it fails,
+// the recording is labeled as pending and it returns true, the session must be stored in the
+// database anyway and the wal log kept to retry storing the recording in the next run.
+func storeRecording(store blobstore.SessionBlobStore, walog *sessionwal.WalLog, wh *sessionwal.Header, labels map[string]string) bool {
+	if store == nil {
+		return false
+	}
+	err := blobstore.PutRecording(context.Background(), store, walog, wh)
+	if err != nil {
+		log.With("sid", wh.SessionID).Warnf("failed storing session recording, err=%v", err)
+	}
+	labels["recording-pending"] = fmt.Sprintf("%v", err != nil)
+	return err != nil
+}
+
 func getWalFolders(auditPath string) ([]string, error) {
 	dirEntry, err := os.ReadDir(auditPath)
 	if err != nil {
@@ -154,7 +190,11 @@ func validateAuditPath(auditPath string) error {
 	return nil
 }
 
-func readFullEventStream(walog *sessionwal.WalLog, startDate time.Time) (*eventStreamData, error) {
+func readFullEventStream(walog *sessionwal.WalLog, wh *sessionwal.Header) (*eventStreamData, error) {
+	if wh.EventLogVersion == eventlogv1.Version {
+		return readFullEventStreamV1(walog, wh)
+	}
+	startDate := *wh.StartDate
 	eventSize := int64(0)
 	redactCount := int64(0)
 	var commitEndDate *time.Time
@@ -192,3 +232,66 @@ func readFullEventStream(walog *sessionwal.WalLog, startDate time.Time) (*eventS
 		commitEndDate:    commitEndDate,
 	}, nil
 }
+
+// readFullEventStreamV1 reads the events written by the audit plugin. The chain of hashes
+// is rebuilt from the header and all the events of the log, the same way they were
+// hashed when written. The hash stored in each event is kept, a log changed on disk
+// is reported as tampered when the session is verified.
+func readFullEventStreamV1(walog *sessionwal.WalLog, wh *sessionwal.Header) (*eventStreamData, error) {
+	data := &eventStreamData{chain: integrity.NewChain(integrity.Header{
+		OrgID:          wh.OrgID,
+		SessionID:      wh.SessionID,
+		UserID:         wh.UserID,
+		ConnectionName: wh.ConnectionName,
+		ConnectionType: wh.ConnectionType,
+		Verb:           wh.Verb,
+	})}
+	// the event stream in the database could be truncated, the chain must include all events
+	err := walog.ReadAll(func(b []byte) error {
+		ev, err := eventlogv1.Decode(b)
+		if err != nil {
+			return err
+		}
+		if ev.IsCommitErr() {
+			data.commitEndDate = &ev.EventTime
+			data.commitError = string(ev.Payload)
+			return nil
+		}
+		if len(ev.Payload) == 0 {
+			return nil
+		}
+		data.chain.Next(ev.EventTime.Sub(*wh.StartDate).Seconds(), byte(ev.EventType), ev.Payload)
+		data.size += int64(len(ev.Payload))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var eventStreamList []types.SessionEventStream
+	data.truncated, err = walog.ReadFull(func(b []byte) error {
+		ev, err := eventlogv1.Decode(b)
+		if err != nil {
+			return err
+		}
+		if ev.IsCommitErr() || len(ev.Payload) == 0 {
+			return nil
+		}
+		event := types.SessionEventStream{
+			ev.EventTime.Sub(*wh.StartDate).Seconds(),
+			string(ev.EventType),
+			base64.StdEncoding.EncodeToString(integrity.TruncatePayload(wh.ConnectionType, ev.Payload)),
+			string(ev.GetMetadata(integrity.MetadataKey)),
+		}
+		if metadata := ev.StreamMetadata(); metadata != nil {
+			event = append(event, metadata)
+		}
+		eventStreamList = append(eventStreamList, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	data.nonIndexedEvents = types.SessionNonIndexedEventStreamList{"stream": eventStreamList}
+	return data, nil
+}
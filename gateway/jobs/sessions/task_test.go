@@ -0,0 +1,110 @@
+package jobsessions
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	eventlogv1 "github.com/hoophq/hoop/gateway/session/eventlog/v1"
+	"github.com/hoophq/hoop/gateway/session/integrity"
+	sessionwal "github.com/hoophq/hoop/gateway/session/wal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadFullEventStreamIntegrity(t *testing.T) {
+	startDate := time.Now().UTC()
+	wh := &sessionwal.Header{
+		EventLogVersion: eventlogv1.Version,
+		OrgID:           "org-id",
+		SessionID:       "sid",
+		UserID:          "user-id",
+		ConnectionName:  "pg",
+		ConnectionType:  "database",
+		Verb:            "exec",
+		StartDate:       &startDate,
+	}
+	ih := integrity.Header{OrgID: wh.OrgID, SessionID: wh.SessionID, UserID: wh.UserID,
+		ConnectionName: wh.ConnectionName, ConnectionType: wh.ConnectionType, Verb: wh.Verb}
+	walog, err := sessionwal.OpenWriteHeader(t.TempDir(), wh)
+	assert.NoError(t, err)
+	defer walog.Close()
+
+	// write the events the same way the audit plugin does
+	chain := integrity.NewChain(ih)
+	for _, payload := range []string{"SELECT 1", "", "1 row"} {
+		eventTime := time.Now().UTC()
+		ev := eventlogv1.New(eventTime, eventlogv1.InputType, []byte(payload),
+			map[string][]byte{eventlogv1.PGParamsMetadataKey: []byte(`["1"]`)})
+		if payload != "" {
+			ev.WithMetadata(integrity.MetadataKey,
+				[]byte(chain.Next(eventTime.Sub(startDate.Round(0)).Seconds(), byte(eventlogv1.InputType), []byte(payload))))
+		}
+		assert.NoError(t, walog.Write(ev))
+	}
+	assert.NoError(t, walog.Write(eventlogv1.NewCommitError(time.Now().UTC(), "failed upserting session")))
+
+	// the header is stored as json, it must decode to the same start date
+	wh, err = walog.Header()
+	assert.NoError(t, err)
+	got, err := readFullEventStream(walog, wh)
+	assert.NoError(t, err)
+	assert.Equal(t, "failed upserting session", got.commitError)
+	assert.NotNil(t, got.commitEndDate)
+	assert.Equal(t, chain.Digest(), got.chain.Digest())
+
+	_, privKey, _ := ed25519.GenerateKey(nil)
+	events := got.nonIndexedEvents["stream"]
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, map[string]json.RawMessage{eventlogv1.PGParamsMetadataKey: []byte(`["1"]`)}, events[0][4],
+			"the metadata of the events must be kept in the stream")
+	}
+	res := integrity.Verify(ih, events, integrity.Sign(got.chain, privKey), privKey.Public().(ed25519.PublicKey))
+	assert.True(t, res.Verified, res.Reason)
+	assert.Equal(t, 2, res.EventsCount)
+}
+
+type fakeBlobStore struct {
+	putErr  error
+	objects map[string][]byte
+}
+
+func (s *fakeBlobStore) Put(_ context.Context, key string, r io.Reader, _ int64) error {
+	if s.putErr != nil {
+		return s.putErr
+	}
+	data, err := io.ReadAll(r)
+	s.objects[key] = data
+	return err
+}
+
+func (s *fakeBlobStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func TestStoreRecording(t *testing.T) {
+	startDate := time.Now().UTC()
+	wh := &sessionwal.Header{EventLogVersion: eventlogv1.Version, OrgID: "org-id", SessionID: "sid", UserID: "user-id",
+		ConnectionName: "pg", ConnectionType: "database", Verb: "exec", StartDate: &startDate}
+	walog, err := sessionwal.OpenWriteHeader(t.TempDir(), wh)
+	assert.NoError(t, err)
+	defer walog.Close()
+	assert.NoError(t, walog.Write(eventlogv1.New(startDate, eventlogv1.InputType, []byte("SELECT 1"), nil)))
+
+	labels := map[string]string{}
+	assert.False(t, storeRecording(nil, walog, wh, labels), "it should not be pending without a blob store")
+	assert.Empty(t, labels)
+
+	store := &fakeBlobStore{putErr: fmt.Errorf("connection refused"), objects: map[string][]byte{}}
+	assert.True(t, storeRecording(store, walog, wh, labels), "it should be pending when the upload fails")
+	assert.Equal(t, map[string]string{"recording-pending": "true"}, labels)
+
+	// the next run retries the upload
+	store.putErr = nil
+	assert.False(t, storeRecording(store, walog, wh, labels))
+	assert.Equal(t, map[string]string{"recording-pending": "false"}, labels)
+	assert.Contains(t, string(store.objects["org-id/sid.jsonl"]), "U0VMRUNUIDE=")
+}
@@ -24,6 +24,7 @@ import (
 	pgusers "github.com/hoophq/hoop/gateway/pgrest/users"
 	"github.com/hoophq/hoop/gateway/review"
 	"github.com/hoophq/hoop/gateway/security/idp"
+	"github.com/hoophq/hoop/gateway/session/blobstore"
 	"github.com/hoophq/hoop/gateway/transport"
 
 	// plugins
@@ -48,6 +49,9 @@ func Run() {
 	if err := appconfig.Load(); err != nil {
 		log.Fatalf("failed loading gateway configuration, reason=%v", err)
 	}
+	if err := blobstore.Configure(appconfig.Get().SessionBlobStoreURI()); err != nil {
+		log.Fatalf("failed configuring session blob store, reason=%v", err)
+	}
 	apiURL := appconfig.Get().ApiURL()
 	if err := changeWebappApiURL(apiURL); err != nil {
 		log.Fatal(err)
@@ -106,10 +110,14 @@ func Run() {
 			&review.Service{TransportService: g},
 			idProvider.ApiURL,
 		),
+		// must run before the audit plugin to
+		// record denied statements in the session
+		pluginsrbac.New(),
+		// must run before the audit plugin to record
+		// the data redacted by the builtin provider
+		pluginsdlp.New(),
 		pluginsaudit.New(),
 		pluginsindex.New(),
-		pluginsdlp.New(),
-		pluginsrbac.New(),
 		pluginswebhooks.New(),
 		pluginsslack.New(
 			&review.Service{TransportService: g},
@@ -217,7 +217,7 @@ $$ stable language sql;
 CREATE VIEW sessions AS
     SELECT
         id, org_id, labels, connection, connection_type, verb, user_id, user_name, user_email, status,
-        blob_input_id, blob_stream_id, metadata, metrics, created_at, ended_at
+        blob_input_id, blob_stream_id, metadata, metrics, integrity, created_at, ended_at
     FROM private.sessions;
 
 CREATE VIEW blobs AS
@@ -272,14 +272,16 @@ $$ LANGUAGE SQL;
 CREATE VIEW reviews AS
     SELECT
         id, org_id, session_id, connection_id, connection_name, type, blob_input_id,
-        input_env_vars, input_client_args, access_duration_sec, status,
-        owner_id, owner_email, owner_name, owner_slack_id, created_at, revoked_at
+        input_env_vars, input_client_args, access_duration_sec, access_start_at, status,
+        owner_id, owner_email, owner_name, owner_slack_id, created_at, revoked_at,
+        expire_at, deny_self_approval
     FROM private.reviews;
 
 CREATE VIEW review_groups AS
     SELECT
         id, org_id, review_id, group_name, status,
-        owner_id, owner_email, owner_name, owner_slack_id, reviewed_at
+        owner_id, owner_email, owner_name, owner_slack_id, reviewed_at,
+        stage, quorum, approvals
     FROM private.review_groups;
 
 CREATE FUNCTION blob_input(reviews) RETURNS SETOF blobs ROWS 1 AS $$
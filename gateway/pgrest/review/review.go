@@ -34,6 +34,7 @@ func (r *review) Upsert(rev *types.Review) error {
 		"input_env_vars":      rev.InputEnvVars,
 		"input_client_args":   rev.InputClientArgs,
 		"access_duration_sec": int(rev.AccessDuration.Seconds()),
+		"access_start_at":     rev.AccessStartAt,
 		"blob_input_id":       blobInputID,
 		"status":              rev.Status,
 		"owner_id":            rev.ReviewOwner.Id,
@@ -41,6 +42,8 @@ func (r *review) Upsert(rev *types.Review) error {
 		"owner_name":          rev.ReviewOwner.Name,
 		"owner_slack_id":      rev.ReviewOwner.SlackID,
 		"revoked_at":          rev.RevokeAt,
+		"expire_at":           rev.ExpireAt,
+		"deny_self_approval":  rev.DenySelfApproval,
 		// required only for migrating resources from xtdb to postgrest
 		"created_at": toStringPtr(createdAt),
 	}).Error()
@@ -67,6 +70,9 @@ func (r *review) Upsert(rev *types.Review) error {
 			"group_name":  revgroup.Group,
 			"status":      revgroup.Status,
 			"reviewed_at": revgroup.ReviewDate,
+			"stage":       revgroup.Stage,
+			"quorum":      revgroup.Quorum,
+			"approvals":   revgroup.Approvals,
 		}
 		var reviewedBy types.ReviewOwner
 		if revgroup.ReviewedBy != nil {
@@ -160,10 +166,14 @@ func ToJson(rev types.Review) *types.ReviewJSON {
 		InputEnvVars:     rev.InputEnvVars,
 		InputClientArgs:  rev.InputClientArgs,
 		AccessDuration:   rev.AccessDuration,
+		AccessStartAt:    rev.AccessStartAt,
 		Status:           rev.Status,
 		RevokeAt:         rev.RevokeAt,
 		ReviewOwner:      rev.ReviewOwner,
 		ReviewGroupsData: rev.ReviewGroupsData,
+		CurrentStage:     rev.CurrentStage(),
+		ExpireAt:         rev.ExpireAt,
+		DenySelfApproval: rev.DenySelfApproval,
 		Connection: types.ReviewConnection{
 			Id:   rev.Connection.Id,
 			Name: rev.Connection.Name,
@@ -182,8 +192,10 @@ func parseReview(r Review) *types.Review {
 		InputEnvVars:    r.InputEnvVars,
 		InputClientArgs: r.InputClientArgs,
 		AccessDuration:  r.GetAccessDuration(),
+		AccessStartAt:   r.GetAccessStartAt(),
 		Status:          types.ReviewStatus(r.Status),
 		RevokeAt:        r.GetRevokedAt(),
+		ExpireAt:        r.GetExpireAt(),
 		CreatedBy:       r.OwnerUserID,
 		ReviewOwner: types.ReviewOwner{
 			Id:      r.OwnerUserID,
@@ -197,8 +209,9 @@ func parseReview(r Review) *types.Review {
 		},
 		// the connection id is expanded is used to perform a join on xtdb
 		// when the entity exists this field is a map, otherwise is a string containing the xtid
-		ConnectionId:    r.ConnectionID,
-		ReviewGroupsIds: []string{},
+		ConnectionId:     r.ConnectionID,
+		ReviewGroupsIds:  []string{},
+		DenySelfApproval: r.DenySelfApproval,
 	}
 	for _, rg := range r.ReviewGroups {
 		revGroup := types.ReviewGroup{
@@ -207,6 +220,9 @@ func parseReview(r Review) *types.Review {
 			Status:     types.ReviewStatus(rg.Status),
 			ReviewedBy: nil,
 			ReviewDate: rg.ReviewedAt,
+			Stage:      rg.Stage,
+			Quorum:     rg.Quorum,
+			Approvals:  rg.Approvals,
 		}
 		if rg.OwnerUserID != nil {
 			revGroup.ReviewedBy = &types.ReviewOwner{
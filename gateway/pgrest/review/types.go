@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/hoophq/hoop/gateway/pgrest"
+	"github.com/hoophq/hoop/gateway/storagev2/types"
 )
 
 type ReviewGroup struct {
@@ -18,6 +19,10 @@ type ReviewGroup struct {
 	OwnerName    *string `json:"owner_name"`
 	OwnerSlackID *string `json:"owner_slack_id"`
 	ReviewedAt   *string `json:"reviewed_at"`
+	Stage        int     `json:"stage"`
+	Quorum       int     `json:"quorum"`
+
+	Approvals []types.ReviewOwner `json:"approvals"`
 }
 
 type Review struct {
@@ -31,6 +36,7 @@ type Review struct {
 	InputEnvVars      map[string]string `json:"input_env_vars"`
 	InputClientArgs   []string          `json:"input_client_args"`
 	AccessDurationSec int               `json:"access_duration_sec"`
+	AccessStartAt     *string           `json:"access_start_at"`
 	Status            string            `json:"status"`
 	OwnerUserID       string            `json:"owner_id"`
 	OwnerEmail        string            `json:"owner_email"`
@@ -38,6 +44,8 @@ type Review struct {
 	OwnerSlackID      *string           `json:"owner_slack_id"`
 	CreatedAt         string            `json:"created_at"`
 	RevokedAt         *string           `json:"revoked_at"`
+	ExpireAt          *string           `json:"expire_at"`
+	DenySelfApproval  bool              `json:"deny_self_approval"`
 
 	BlobInput    *pgrest.Blob  `json:"blob_input"`
 	ReviewGroups []ReviewGroup `json:"review_groups"`
@@ -63,6 +71,22 @@ func (r *Review) GetRevokedAt() *time.Time {
 	return nil
 }
 
+func (r *Review) GetAccessStartAt() *time.Time {
+	if r.AccessStartAt != nil {
+		startAt, _ := time.ParseInLocation("2006-01-02T15:04:05", *r.AccessStartAt, time.UTC)
+		return &startAt
+	}
+	return nil
+}
+
+func (r *Review) GetExpireAt() *time.Time {
+	if r.ExpireAt != nil {
+		expireAt, _ := time.ParseInLocation("2006-01-02T15:04:05", *r.ExpireAt, time.UTC)
+		return &expireAt
+	}
+	return nil
+}
+
 func (r *Review) GetBlobInput() (v string) {
 	if r.BlobInput != nil {
 		if len(r.BlobInput.BlobStream) > 0 {
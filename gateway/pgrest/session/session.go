@@ -79,6 +79,7 @@ func (s *session) Upsert(ctx pgrest.OrgContext, sess types.Session) (err error)
 			"ended_at":       sess.EndSession.Format(time.RFC3339Nano),
 			"metadata":       sess.Metadata,
 			"metrics":        sess.Metrics,
+			"integrity":      sess.Integrity,
 		}).Error()
 	default:
 		return fmt.Errorf("unknown session status %q", sess.Status)
@@ -138,6 +139,7 @@ func (s *session) FetchOne(ctx pgrest.OrgContext, sessionID string) (*types.Sess
 		EventStream:      nil,
 		NonIndexedStream: types.SessionNonIndexedEventStreamList{"stream": blobStream},
 		EventSize:        blobStreamSize,
+		Integrity:        sess.Integrity,
 		StartSession:     sess.GetCreatedAt(),
 		EndSession:       sess.GetEndedAt(),
 	}, nil
@@ -1,6 +1,10 @@
 package pgrest
 
-import "encoding/json"
+import (
+	"encoding/json"
+
+	"github.com/hoophq/hoop/gateway/storagev2/types"
+)
 
 type Context interface {
 	OrgContext
@@ -149,22 +153,23 @@ type Blob struct {
 }
 
 type Session struct {
-	ID             string            `json:"id"`
-	OrgID          string            `json:"org_id"`
-	Labels         map[string]string `json:"labels"`
-	Connection     string            `json:"connection"`
-	ConnectionType string            `json:"connection_type"`
-	Verb           string            `json:"verb"`
-	UserID         string            `json:"user_id"`
-	UserName       string            `json:"user_name"`
-	UserEmail      string            `json:"user_email"`
-	Status         string            `json:"status"`
-	BlobInputID    string            `json:"blob_input_id"`
-	BlobStreamID   string            `json:"blob_stream_id"`
-	BlobInput      *Blob             `json:"blob_input"`
-	BlobStream     *Blob             `json:"blob_stream"`
-	Metadata       map[string]any    `json:"metadata"`
-	Metrics        map[string]any    `json:"metrics"`
+	ID             string                  `json:"id"`
+	OrgID          string                  `json:"org_id"`
+	Labels         map[string]string       `json:"labels"`
+	Connection     string                  `json:"connection"`
+	ConnectionType string                  `json:"connection_type"`
+	Verb           string                  `json:"verb"`
+	UserID         string                  `json:"user_id"`
+	UserName       string                  `json:"user_name"`
+	UserEmail      string                  `json:"user_email"`
+	Status         string                  `json:"status"`
+	BlobInputID    string                  `json:"blob_input_id"`
+	BlobStreamID   string                  `json:"blob_stream_id"`
+	BlobInput      *Blob                   `json:"blob_input"`
+	BlobStream     *Blob                   `json:"blob_stream"`
+	Metadata       map[string]any          `json:"metadata"`
+	Metrics        map[string]any          `json:"metrics"`
+	Integrity      *types.SessionIntegrity `json:"integrity"`
 	// TODO: convert to time.Time
 	CreatedAt string  `json:"created_at"`
 	EndedAt   *string `json:"ended_at"`
@@ -56,7 +56,7 @@ func (h *Handler) Put(c *gin.Context) {
 	switch err {
 	case ErrNotFound:
 		c.JSON(http.StatusNotFound, gin.H{"message": "not found"})
-	case ErrNotEligible, ErrWrongState:
+	case ErrNotEligible, ErrWrongState, ErrSelfApproval, ErrExpired, ErrPendingStage, ErrAlreadyReviewed:
 		c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
 	case nil:
 		c.JSON(http.StatusOK, sanitizeReview(review))
@@ -121,6 +121,7 @@ func sanitizeReview(review *types.Review) types.ReviewJSON {
 		InputEnvVars:    review.InputEnvVars,
 		InputClientArgs: review.InputClientArgs,
 		AccessDuration:  review.AccessDuration,
+		AccessStartAt:   review.AccessStartAt,
 		Status:          review.Status,
 		RevokeAt:        review.RevokeAt,
 		ReviewOwner: types.ReviewOwner{
@@ -134,5 +135,8 @@ func sanitizeReview(review *types.Review) types.ReviewJSON {
 			Name: connectionToStringFn("connection/name"),
 		},
 		ReviewGroupsData: review.ReviewGroupsData,
+		CurrentStage:     review.CurrentStage(),
+		ExpireAt:         review.ExpireAt,
+		DenySelfApproval: review.DenySelfApproval,
 	}
 }
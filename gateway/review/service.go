@@ -3,6 +3,7 @@ package review
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -29,6 +30,10 @@ var (
 	ErrWrongState   = errors.New("review in wrong state")
 	ErrNotEligible  = errors.New("not eligible for review")
 	ErrSelfApproval = errors.New("unable to self approve review")
+	ErrExpired      = errors.New("review has expired")
+	// the groups of the user review in a later stage of the workflow
+	ErrPendingStage    = errors.New("review is pending the approval of a previous stage")
+	ErrAlreadyReviewed = errors.New("review already approved by this user")
 )
 
 const (
@@ -70,10 +75,13 @@ func (s *Service) Persist(ctx pgrest.OrgContext, review *types.Review) error {
 		InputEnvVars:     review.InputEnvVars,
 		InputClientArgs:  review.InputClientArgs,
 		AccessDuration:   review.AccessDuration,
+		AccessStartAt:    review.AccessStartAt,
 		RevokeAt:         review.RevokeAt,
 		Status:           review.Status,
 		ReviewGroupsIds:  review.ReviewGroupsIds,
 		ReviewGroupsData: review.ReviewGroupsData,
+		ExpireAt:         review.ExpireAt,
+		DenySelfApproval: review.DenySelfApproval,
 	}
 
 	if err := pgreview.New().Upsert(parsedReview); err != nil {
@@ -108,62 +116,123 @@ func (s *Service) Review(ctx *storagev2.Context, reviewID string, status types.R
 	if rev == nil {
 		return nil, ErrNotFound
 	}
+	if rev.IsExpired(time.Now().UTC()) {
+		rev.Status = types.ReviewStatusRejected
+		if err := s.Persist(ctx, rev); err != nil {
+			return nil, fmt.Errorf("saving review error: %v", err)
+		}
+		if err := s.release(ctx, rev); err != nil {
+			return nil, err
+		}
+		return rev, ErrExpired
+	}
 	if rev.Status != types.ReviewStatusPending {
 		return rev, ErrWrongState
 	}
-	if rev.ReviewOwner.Id == ctx.UserID && !ctx.IsAdmin() {
-		return nil, ErrSelfApproval
+	if err := reviewStage(rev, ctx, status); err != nil {
+		return nil, err
 	}
 
-	isEligibleReviewer := false
-	for _, r := range rev.ReviewGroupsData {
-		if pb.IsInList(r.Group, ctx.UserGroups) {
-			isEligibleReviewer = true
-			break
+	if rev.Status == types.ReviewStatusApproved {
+		rev.RevokeAt = func() *time.Time { t := time.Now().UTC().Add(rev.AccessDuration); return &t }()
+		// scheduled access windows are approved ahead of time
+		if rev.AccessStartAt != nil {
+			rev.RevokeAt = func() *time.Time { t := rev.AccessStartAt.Add(rev.AccessDuration); return &t }()
 		}
 	}
-	if !isEligibleReviewer {
-		return nil, ErrNotEligible
+
+	if err := s.Persist(ctx, rev); err != nil {
+		return nil, fmt.Errorf("saving review error: %v", err)
 	}
 
-	reviewsCount := len(rev.ReviewGroupsData)
-	approvedCount := 0
+	if rev.Status == types.ReviewStatusApproved || rev.Status == types.ReviewStatusRejected {
+		if err := s.release(ctx, rev); err != nil {
+			return nil, err
+		}
+	}
+	return rev, nil
+}
 
-	if status == types.ReviewStatusRejected {
-		rev.Status = status
+// release updates the session of a review that is approved or rejected
+func (s *Service) release(ctx *storagev2.Context, rev *types.Review) error {
+	if err := pgsession.New().UpdateStatus(ctx, rev.Session, types.SessionStatusReady); err != nil {
+		return fmt.Errorf("save sesession as ready error: %v", err)
 	}
+	// release the connection if there's a client waiting
+	s.TransportService.ReviewStatusChange(rev)
+	return nil
+}
 
+// reviewStage applies the status to the groups of the current stage that the user belongs to.
+// A group is approved when it reaches the quorum of distinct approvals and the review
+// is approved when all the groups of all stages are approved.
+func reviewStage(rev *types.Review, ctx *storagev2.Context, status types.ReviewStatus) error {
+	if rev.ReviewOwner.Id == ctx.UserID && (rev.DenySelfApproval || !ctx.IsAdmin()) {
+		return ErrSelfApproval
+	}
+
+	stage := rev.CurrentStage()
+	var isEligibleReviewer, hasNextStageGroups bool
+	var stageGroups []int
 	for i, r := range rev.ReviewGroupsData {
-		if pb.IsInList(r.Group, ctx.UserGroups) {
-			t := time.Now().UTC().Format(time.RFC3339)
-			rev.ReviewGroupsData[i].Status = status
-			rev.ReviewGroupsData[i].ReviewedBy = &types.ReviewOwner{
-				Id:    ctx.UserID,
-				Name:  ctx.UserName,
-				Email: ctx.UserEmail,
-			}
-			rev.ReviewGroupsData[i].ReviewDate = &t
+		if !pb.IsInList(r.Group, ctx.UserGroups) {
+			continue
 		}
-		if rev.ReviewGroupsData[i].Status == types.ReviewStatusApproved {
-			approvedCount++
+		isEligibleReviewer = true
+		switch {
+		case r.Stage > stage:
+			hasNextStageGroups = true
+		case r.Stage == stage && r.Status != types.ReviewStatusApproved:
+			stageGroups = append(stageGroups, i)
 		}
 	}
-
-	if reviewsCount == approvedCount {
-		rev.RevokeAt = func() *time.Time { t := time.Now().UTC().Add(rev.AccessDuration); return &t }()
-		rev.Status = types.ReviewStatusApproved
+	switch {
+	case !isEligibleReviewer:
+		return ErrNotEligible
+	case len(stageGroups) == 0 && hasNextStageGroups:
+		return ErrPendingStage
+	case len(stageGroups) == 0:
+		return ErrAlreadyReviewed
 	}
 
-	if err := s.Persist(ctx, rev); err != nil {
-		return nil, fmt.Errorf("saving review error: %v", err)
+	reviewer := types.ReviewOwner{
+		Id:    ctx.UserID,
+		Name:  ctx.UserName,
+		Email: ctx.UserEmail,
 	}
-
-	if rev.Status == types.ReviewStatusApproved || rev.Status == types.ReviewStatusRejected {
-		if err := pgsession.New().UpdateStatus(ctx, rev.Session, types.SessionStatusReady); err != nil {
-			return nil, fmt.Errorf("save sesession as ready error: %v", err)
+	t := time.Now().UTC().Format(time.RFC3339)
+	approvedCount := 0
+	for _, i := range stageGroups {
+		group := &rev.ReviewGroupsData[i]
+		if status == types.ReviewStatusRejected {
+			group.Status = status
+			group.ReviewedBy = &reviewer
+			group.ReviewDate = &t
+			continue
+		}
+		if slices.ContainsFunc(group.Approvals, func(o types.ReviewOwner) bool { return o.Id == reviewer.Id }) {
+			continue
+		}
+		approvedCount++
+		group.Approvals = append(group.Approvals, reviewer)
+		if len(group.Approvals) >= max(group.Quorum, 1) {
+			group.Status = types.ReviewStatusApproved
+			group.ReviewedBy = &reviewer
+			group.ReviewDate = &t
 		}
-		// release the connection if there's a client waiting
-		s.TransportService.ReviewStatusChange(rev)
 	}
-	return rev, nil
+	if status == types.ReviewStatusRejected {
+		rev.Status = status
+		return nil
+	}
+	if approvedCount == 0 {
+		return ErrAlreadyReviewed
+	}
+	for _, r := range rev.ReviewGroupsData {
+		if r.Status != types.ReviewStatusApproved {
+			return nil
+		}
+	}
+	rev.Status = types.ReviewStatusApproved
+	return nil
 }
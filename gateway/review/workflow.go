@@ -0,0 +1,137 @@
+package review
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hoophq/hoop/gateway/storagev2/types"
+)
+
+// The configuration of the review plugin for a connection is a list of entries,
+// each entry is an approval group or a setting of the workflow. Examples:
+//
+//	sre - a member of the group sre must approve the review
+//	dba;stage=2 - the group dba reviews after the groups of the previous stages approve it
+//	sre;quorum=2 - two distinct members of the group sre must approve the review
+//	expire-after=24h - pending reviews are rejected after 24 hours
+//	self-approval=deny - the owner of a review can't approve it, even when it's an admin
+//
+// Groups without a stage belong to the first stage. The groups of the same stage
+// could approve in any order.
+const (
+	settingExpireAfter  = "expire-after"
+	settingSelfApproval = "self-approval"
+
+	optionStage  = "stage"
+	optionQuorum = "quorum"
+)
+
+// Workflow is the approval workflow of the reviews of a connection
+type Workflow struct {
+	Groups           []WorkflowGroup
+	ExpireAfter      time.Duration
+	DenySelfApproval bool
+}
+
+// WorkflowGroup is a group that must approve a review in a stage
+type WorkflowGroup struct {
+	Name   string
+	Stage  int
+	Quorum int
+}
+
+// ParseWorkflow parses the review plugin configuration of a connection
+func ParseWorkflow(config []string) (*Workflow, error) {
+	w := &Workflow{}
+	seen := map[string]bool{}
+	for _, entry := range config {
+		entry = strings.TrimSpace(entry)
+		if key, val, found := strings.Cut(entry, "="); found && !strings.Contains(entry, ";") {
+			if err := w.parseSetting(strings.TrimSpace(key), strings.TrimSpace(val)); err != nil {
+				return nil, fmt.Errorf("invalid review setting %q, %v", entry, err)
+			}
+			continue
+		}
+		group, err := parseWorkflowGroup(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid review group %q, %v", entry, err)
+		}
+		if seen[group.Name] {
+			return nil, fmt.Errorf("invalid review group %q, the group is defined more than once", entry)
+		}
+		seen[group.Name] = true
+		w.Groups = append(w.Groups, *group)
+	}
+	if len(w.Groups) == 0 {
+		return nil, fmt.Errorf("missing approval groups")
+	}
+	return w, nil
+}
+
+func (w *Workflow) parseSetting(key, val string) (err error) {
+	switch key {
+	case settingExpireAfter:
+		w.ExpireAfter, err = time.ParseDuration(val)
+		if err == nil && w.ExpireAfter <= 0 {
+			err = fmt.Errorf("the duration must be greater than zero")
+		}
+	case settingSelfApproval:
+		switch val {
+		case "deny":
+			w.DenySelfApproval = true
+		case "allow":
+			w.DenySelfApproval = false
+		default:
+			err = fmt.Errorf("expected deny or allow")
+		}
+	default:
+		err = fmt.Errorf("unknown setting")
+	}
+	return
+}
+
+func parseWorkflowGroup(entry string) (*WorkflowGroup, error) {
+	parts := strings.Split(entry, ";")
+	group := &WorkflowGroup{Name: strings.TrimSpace(parts[0]), Stage: 1, Quorum: 1}
+	if group.Name == "" {
+		return nil, fmt.Errorf("missing the name of the group")
+	}
+	for _, opt := range parts[1:] {
+		key, val, _ := strings.Cut(opt, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(val))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("the option %s must be a number greater than zero", strings.TrimSpace(key))
+		}
+		switch strings.TrimSpace(key) {
+		case optionStage:
+			group.Stage = n
+		case optionQuorum:
+			group.Quorum = n
+		default:
+			return nil, fmt.Errorf("unknown option %q", strings.TrimSpace(key))
+		}
+	}
+	return group, nil
+}
+
+// Apply configures the workflow in a new review
+func (w *Workflow) Apply(rev *types.Review) {
+	rev.ReviewGroupsIds = nil
+	rev.ReviewGroupsData = nil
+	for _, g := range w.Groups {
+		rev.ReviewGroupsIds = append(rev.ReviewGroupsIds, g.Name)
+		rev.ReviewGroupsData = append(rev.ReviewGroupsData, types.ReviewGroup{
+			Group:  g.Name,
+			Status: types.ReviewStatusPending,
+			Stage:  g.Stage,
+			Quorum: g.Quorum,
+		})
+	}
+	rev.DenySelfApproval = w.DenySelfApproval
+	if w.ExpireAfter > 0 {
+		expireAt := rev.CreatedAt.Add(w.ExpireAfter)
+		rev.ExpireAt = &expireAt
+	}
+}
@@ -0,0 +1,223 @@
+package review
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoop/gateway/storagev2"
+	"github.com/hoophq/hoop/gateway/storagev2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWorkflow(t *testing.T) {
+	for _, tt := range []struct {
+		msg     string
+		config  []string
+		want    *Workflow
+		wantErr string
+	}{
+		{
+			msg:    "it should parse groups without options in the first stage",
+			config: []string{"sre", "dba"},
+			want: &Workflow{Groups: []WorkflowGroup{
+				{Name: "sre", Stage: 1, Quorum: 1},
+				{Name: "dba", Stage: 1, Quorum: 1}}},
+		},
+		{
+			msg:    "it should parse stages, quorum and settings",
+			config: []string{"team-lead", "dba;stage=2", "sre;quorum=2;stage=2", "expire-after=24h", "self-approval=deny"},
+			want: &Workflow{
+				Groups: []WorkflowGroup{
+					{Name: "team-lead", Stage: 1, Quorum: 1},
+					{Name: "dba", Stage: 2, Quorum: 1},
+					{Name: "sre", Stage: 2, Quorum: 2}},
+				ExpireAfter:      time.Hour * 24,
+				DenySelfApproval: true,
+			},
+		},
+		{
+			msg:     "it should fail with unknown options",
+			config:  []string{"sre;order=1"},
+			wantErr: `invalid review group "sre;order=1", unknown option "order"`,
+		},
+		{
+			msg:     "it should fail with invalid quorum",
+			config:  []string{"sre;quorum=0"},
+			wantErr: `invalid review group "sre;quorum=0", the option quorum must be a number greater than zero`,
+		},
+		{
+			msg:     "it should fail with unknown settings",
+			config:  []string{"sre", "expire=1h"},
+			wantErr: `invalid review setting "expire=1h", unknown setting`,
+		},
+		{
+			msg:     "it should fail with duplicated groups",
+			config:  []string{"sre", "sre;stage=2"},
+			wantErr: `invalid review group "sre;stage=2", the group is defined more than once`,
+		},
+		{
+			msg:     "it should fail without groups",
+			config:  []string{"self-approval=deny"},
+			wantErr: `missing approval groups`,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := ParseWorkflow(tt.config)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func newReviewerContext(userID string, groups ...string) *storagev2.Context {
+	ctx := storagev2.NewContext(userID, "org")
+	ctx.UserGroups = groups
+	return ctx
+}
+
+func newWorkflowReview(config ...string) *types.Review {
+	workflow, _ := ParseWorkflow(config)
+	rev := &types.Review{
+		Status:      types.ReviewStatusPending,
+		ReviewOwner: types.ReviewOwner{Id: "owner"},
+		CreatedAt:   time.Now().UTC(),
+	}
+	workflow.Apply(rev)
+	return rev
+}
+
+func TestReviewStage(t *testing.T) {
+	type review struct {
+		ctx     *storagev2.Context
+		status  types.ReviewStatus
+		wantErr error
+	}
+	for _, tt := range []struct {
+		msg          string
+		rev          *types.Review
+		reviews      []review
+		wantStatus   types.ReviewStatus
+		wantStage    int
+		wantApproval []int
+	}{
+		{
+			msg: "it should approve groups in any order",
+			rev: newWorkflowReview("sre", "dba"),
+			reviews: []review{
+				{ctx: newReviewerContext("u1", "dba")},
+				{ctx: newReviewerContext("u2", "sre")},
+			},
+			wantStatus:   types.ReviewStatusApproved,
+			wantStage:    1,
+			wantApproval: []int{1, 1},
+		},
+		{
+			msg: "it should approve the stages in order",
+			rev: newWorkflowReview("team-lead", "dba;stage=2"),
+			reviews: []review{
+				{ctx: newReviewerContext("u1", "dba"), wantErr: ErrPendingStage},
+				{ctx: newReviewerContext("u2", "team-lead")},
+			},
+			wantStatus:   types.ReviewStatusPending,
+			wantStage:    2,
+			wantApproval: []int{1, 0},
+		},
+		{
+			msg: "it should require distinct approvals to reach the quorum",
+			rev: newWorkflowReview("sre;quorum=2"),
+			reviews: []review{
+				{ctx: newReviewerContext("u1", "sre")},
+				{ctx: newReviewerContext("u1", "sre"), wantErr: ErrAlreadyReviewed},
+				{ctx: newReviewerContext("u2", "sre")},
+			},
+			wantStatus:   types.ReviewStatusApproved,
+			wantStage:    1,
+			wantApproval: []int{2},
+		},
+		{
+			msg: "it should reject the review in the current stage",
+			rev: newWorkflowReview("team-lead", "dba;stage=2"),
+			reviews: []review{
+				{ctx: newReviewerContext("u1", "team-lead"), status: types.ReviewStatusRejected},
+			},
+			wantStatus:   types.ReviewStatusRejected,
+			wantStage:    1,
+			wantApproval: []int{0, 0},
+		},
+		{
+			msg: "it should allow admins to approve their own reviews",
+			rev: newWorkflowReview("admin"),
+			reviews: []review{
+				{ctx: newReviewerContext("owner", types.GroupAdmin)},
+			},
+			wantStatus:   types.ReviewStatusApproved,
+			wantStage:    1,
+			wantApproval: []int{1},
+		},
+		{
+			msg: "it should deny admins to approve their own reviews when self approval is denied",
+			rev: newWorkflowReview("admin", "self-approval=deny"),
+			reviews: []review{
+				{ctx: newReviewerContext("owner", types.GroupAdmin), wantErr: ErrSelfApproval},
+			},
+			wantStatus:   types.ReviewStatusPending,
+			wantStage:    1,
+			wantApproval: []int{0},
+		},
+		{
+			msg: "it should deny users that don't belong to any group",
+			rev: newWorkflowReview("sre"),
+			reviews: []review{
+				{ctx: newReviewerContext("u1", "dba"), wantErr: ErrNotEligible},
+			},
+			wantStatus:   types.ReviewStatusPending,
+			wantStage:    1,
+			wantApproval: []int{0},
+		},
+		{
+			msg: "it should approve reviews created without stages",
+			rev: &types.Review{
+				Status: types.ReviewStatusPending,
+				ReviewGroupsData: []types.ReviewGroup{
+					{Group: "sre", Status: types.ReviewStatusPending},
+					{Group: "dba", Status: types.ReviewStatusPending},
+				},
+			},
+			reviews: []review{
+				{ctx: newReviewerContext("u1", "sre", "dba")},
+			},
+			wantStatus:   types.ReviewStatusApproved,
+			wantStage:    0,
+			wantApproval: []int{1, 1},
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			for _, r := range tt.reviews {
+				status := r.status
+				if status == "" {
+					status = types.ReviewStatusApproved
+				}
+				assert.Equal(t, r.wantErr, reviewStage(tt.rev, r.ctx, status))
+			}
+			assert.Equal(t, tt.wantStatus, tt.rev.Status)
+			assert.Equal(t, tt.wantStage, tt.rev.CurrentStage())
+			var approvals []int
+			for _, g := range tt.rev.ReviewGroupsData {
+				approvals = append(approvals, len(g.Approvals))
+			}
+			assert.Equal(t, tt.wantApproval, approvals)
+		})
+	}
+}
+
+func TestReviewIsExpired(t *testing.T) {
+	rev := newWorkflowReview("sre", "expire-after=1h")
+	assert.False(t, rev.IsExpired(rev.CreatedAt.Add(time.Minute*59)))
+	assert.True(t, rev.IsExpired(rev.CreatedAt.Add(time.Minute*61)))
+	rev.Status = types.ReviewStatusApproved
+	assert.False(t, rev.IsExpired(rev.CreatedAt.Add(time.Minute*61)))
+}
@@ -0,0 +1,80 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrNotFound = errors.New("blob not found")
+
+	defaultStore SessionBlobStore
+)
+
+// SessionBlobStore stores the full recording of sessions outside the database
+type SessionBlobStore interface {
+	// Put stores the content of r with size bytes under the key
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get returns a reader of the content stored under the key,
+	// it's up to the caller to close it. It returns ErrNotFound
+	// when the key does not exists.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// Configure initializes the default store based on the uri scheme:
+//
+//	file:///opt/hoop/recordings - stores in the local filesystem
+//	s3://bucket/prefix?region=us-east-1&endpoint=http://127.0.0.1:9000 - stores in a S3 compatible storage
+//
+// The credentials of the S3 store are obtained from the user info of the uri or
+// from the default credential chain of AWS (e.g.: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+// and AWS_SESSION_TOKEN environment variables, web identity, ECS or EC2 roles).
+// An empty uri disables storing recordings outside the database.
+func Configure(uri string) error {
+	if uri == "" {
+		defaultStore = nil
+		return nil
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return fmt.Errorf("failed parsing session blob store uri, reason=%v", err)
+	}
+	var store SessionBlobStore
+	switch u.Scheme {
+	case "file":
+		store, err = NewFilesystem(u.Path)
+	case "s3":
+		var accessKeyID, secretAccessKey string
+		if u.User != nil {
+			accessKeyID = u.User.Username()
+			secretAccessKey, _ = u.User.Password()
+		}
+		store, err = NewS3(S3Options{
+			Endpoint:        u.Query().Get("endpoint"),
+			Region:          u.Query().Get("region"),
+			Bucket:          u.Host,
+			Prefix:          strings.Trim(u.Path, "/"),
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+		})
+	default:
+		return fmt.Errorf("unknown session blob store scheme %q, accept only: file or s3", u.Scheme)
+	}
+	if err != nil {
+		return err
+	}
+	defaultStore = store
+	return nil
+}
+
+// Default returns the configured store, it's nil when it's not configured
+func Default() SessionBlobStore { return defaultStore }
+
+// ObjectKey returns the key of the recording of a session
+func ObjectKey(orgID, sessionID string) string {
+	return fmt.Sprintf("%s/%s.jsonl", orgID, sessionID)
+}
@@ -0,0 +1,193 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	eventlogv1 "github.com/hoophq/hoop/gateway/session/eventlog/v1"
+	sessionwal "github.com/hoophq/hoop/gateway/session/wal"
+	"github.com/hoophq/hoop/gateway/storagev2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeS3Server is a minimal stand-in of a S3 compatible storage
+func newFakeS3Server() *httptest.Server {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access-key/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			if int64(len(data)) != r.ContentLength {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			objects[r.URL.Path] = data
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(data)
+		}
+	}))
+}
+
+func TestStorePutGet(t *testing.T) {
+	fakeS3 := newFakeS3Server()
+	defer fakeS3.Close()
+	s3Store, err := NewS3(S3Options{
+		Endpoint:        fakeS3.URL,
+		Bucket:          "recordings",
+		Prefix:          "hoop",
+		AccessKeyID:     "access-key",
+		SecretAccessKey: "secret-key",
+	})
+	assert.NoError(t, err)
+	fsStore, err := NewFilesystem(t.TempDir())
+	assert.NoError(t, err)
+
+	for _, tt := range []struct {
+		msg   string
+		store SessionBlobStore
+	}{
+		{msg: "it should put and get objects from the filesystem", store: fsStore},
+		{msg: "it should put and get objects from a s3 compatible storage", store: s3Store},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			ctx := context.Background()
+			key := ObjectKey("org-id", uuid.NewString())
+			_, err := tt.store.Get(ctx, key)
+			assert.Equal(t, ErrNotFound, err)
+
+			content := []byte(`[0.1,"o","aGVsbG8="]`)
+			err = tt.store.Put(ctx, key, bytes.NewReader(content), int64(len(content)))
+			assert.NoError(t, err)
+
+			r, err := tt.store.Get(ctx, key)
+			assert.NoError(t, err)
+			defer r.Close()
+			got, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, content, got)
+		})
+	}
+}
+
+func TestFilesystemInvalidKey(t *testing.T) {
+	fsStore, err := NewFilesystem(t.TempDir())
+	assert.NoError(t, err)
+	err = fsStore.Put(context.Background(), "../escape.jsonl", bytes.NewReader(nil), 0)
+	assert.ErrorContains(t, err, "invalid blob key")
+}
+
+func TestPutReadRecording(t *testing.T) {
+	startDate := time.Now().UTC()
+	wh := &sessionwal.Header{
+		EventLogVersion: eventlogv1.Version,
+		OrgID:           "org-id",
+		SessionID:       uuid.NewString(),
+		ConnectionName:  "pg",
+		ConnectionType:  "database",
+		StartDate:       &startDate,
+	}
+	walog, err := sessionwal.OpenWriteHeader(filepath.Join(t.TempDir(), "test-wal"), wh)
+	assert.NoError(t, err)
+	defer walog.Close()
+
+	events := []*eventlogv1.EventLog{
+		eventlogv1.New(startDate.Add(time.Second), eventlogv1.InputType, []byte(`SELECT $1`),
+			map[string][]byte{eventlogv1.PGParamsMetadataKey: []byte(`["1",null]`)}),
+		eventlogv1.New(startDate.Add(time.Second*2), eventlogv1.OutputType, bytes.Repeat([]byte("a"), 1024*1024), nil),
+		eventlogv1.NewCommitError(startDate.Add(time.Second*3), "failed committing session"),
+	}
+	for _, ev := range events {
+		assert.NoError(t, walog.Write(ev))
+	}
+
+	store, err := NewFilesystem(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, PutRecording(context.Background(), store, walog, wh))
+
+	r, err := store.Get(context.Background(), ObjectKey(wh.OrgID, wh.SessionID))
+	assert.NoError(t, err)
+	defer r.Close()
+	var got []types.SessionEventStream
+	err = ReadRecording(r, func(event types.SessionEventStream) error {
+		got = append(got, event)
+		return nil
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, types.SessionEventStream{float64(1), "i", "U0VMRUNUICQx", "",
+			map[string]any{eventlogv1.PGParamsMetadataKey: []any{"1", nil}}}, got[0])
+		assert.Len(t, got[1], 4, "the events without metadata must keep the same format")
+		assert.Equal(t, float64(2), got[1][0])
+		assert.Equal(t, "o", got[1][1])
+	}
+}
+
+func TestS3DefaultCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "access-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret-key")
+	t.Setenv("AWS_SESSION_TOKEN", "session-token")
+	var sessionToken string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access-key/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		sessionToken = r.Header.Get("X-Amz-Security-Token")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+	store, err := NewS3(S3Options{Endpoint: srv.URL, Bucket: "recordings"})
+	assert.NoError(t, err)
+	_, err = store.Get(context.Background(), "org/sid.jsonl")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, "session-token", sessionToken, "the temporary credentials must be signed with the session token")
+}
+
+func TestConfigure(t *testing.T) {
+	defer func() { _ = Configure("") }()
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	for _, tt := range []struct {
+		msg     string
+		uri     string
+		wantErr string
+	}{
+		{msg: "it should disable the store with an empty uri", uri: ""},
+		{msg: "it should configure the filesystem store", uri: "file://" + t.TempDir()},
+		{msg: "it should configure the s3 store with credentials in the uri", uri: "s3://key:secret@bucket/prefix?endpoint=http://127.0.0.1:9000"},
+		{msg: "it should configure the s3 store with the default credential chain", uri: "s3://bucket"},
+		{msg: "it should fail when the s3 store has incomplete credentials", uri: "s3://key@bucket", wantErr: "missing credentials"},
+		{msg: "it should fail with unknown schemes", uri: "gs://bucket", wantErr: "unknown session blob store scheme"},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			err := Configure(tt.uri)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.uri == "", Default() == nil)
+		})
+	}
+}
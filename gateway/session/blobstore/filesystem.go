@@ -0,0 +1,69 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type filesystem struct {
+	basePath string
+}
+
+// NewFilesystem returns a store that saves blobs as files under basePath
+func NewFilesystem(basePath string) (*filesystem, error) {
+	if basePath == "" {
+		return nil, fmt.Errorf("base path for the filesystem store is empty")
+	}
+	if err := os.MkdirAll(basePath, 0755); err != nil {
+		return nil, fmt.Errorf("failed creating base path %v, reason=%v", basePath, err)
+	}
+	return &filesystem{basePath: filepath.Clean(basePath)}, nil
+}
+
+func (f *filesystem) Put(_ context.Context, key string, r io.Reader, _ int64) error {
+	filePath, err := f.filePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	// write to a temporary file first to avoid readers obtaining partial content
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), ".blob-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := io.Copy(tmpFile, r); err != nil {
+		_ = tmpFile.Close()
+		return fmt.Errorf("failed writing blob %v, reason=%v", key, err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), filePath)
+}
+
+func (f *filesystem) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	filePath, err := f.filePath(key)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return fd, err
+}
+
+func (f *filesystem) filePath(key string) (string, error) {
+	filePath := filepath.Join(f.basePath, filepath.FromSlash(key))
+	if !strings.HasPrefix(filePath, f.basePath+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filePath, nil
+}
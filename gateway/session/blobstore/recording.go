@@ -0,0 +1,103 @@
+package blobstore
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hoophq/hoop/gateway/session/eventlog"
+	eventlogv1 "github.com/hoophq/hoop/gateway/session/eventlog/v1"
+	"github.com/hoophq/hoop/gateway/session/integrity"
+	sessionwal "github.com/hoophq/hoop/gateway/session/wal"
+	"github.com/hoophq/hoop/gateway/storagev2/types"
+)
+
+// PutRecording stores all the events of the wal log in the store, without truncating it.
+// Each event is encoded as a json line with the same format of the event stream
+// stored in the database: [<elapsed-seconds>, <event-type>, <base64-payload>, <integrity-hash>, <metadata>].
+// The metadata is present only in events with metadata, e.g.: the parameters of postgres statements.
+func PutRecording(ctx context.Context, store SessionBlobStore, walog *sessionwal.WalLog, wh *sessionwal.Header) error {
+	tmpFile, err := os.CreateTemp("", "hoop-recording-*")
+	if err != nil {
+		return fmt.Errorf("failed creating temporary recording file, reason=%v", err)
+	}
+	defer func() { _ = tmpFile.Close(); _ = os.Remove(tmpFile.Name()) }()
+
+	w := bufio.NewWriter(tmpFile)
+	enc := json.NewEncoder(w)
+	err = walog.ReadAll(func(data []byte) error {
+		event, err := decodeEventStream(wh, data)
+		if err != nil || event == nil {
+			return err
+		}
+		return enc.Encode(event)
+	})
+	if err != nil {
+		return fmt.Errorf("failed encoding recording, reason=%v", err)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	size, err := tmpFile.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmpFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return store.Put(ctx, ObjectKey(wh.OrgID, wh.SessionID), tmpFile, size)
+}
+
+// ReadRecording decodes each event of a recording calling fn until it reaches the end of r
+func ReadRecording(r io.Reader, fn func(event types.SessionEventStream) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		var event types.SessionEventStream
+		if err := dec.Decode(&event); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed decoding recording event, reason=%v", err)
+		}
+		if len(event) < 3 {
+			return fmt.Errorf("recording event in wrong format, got=%v items", len(event))
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeEventStream decodes the event log based on the version of the header,
+// it returns a nil event for commit errors and events without payload.
+func decodeEventStream(wh *sessionwal.Header, data []byte) (types.SessionEventStream, error) {
+	if wh.EventLogVersion == eventlogv1.Version {
+		ev, err := eventlogv1.Decode(data)
+		if err != nil || ev.IsCommitErr() || len(ev.Payload) == 0 {
+			return nil, err
+		}
+		event := types.SessionEventStream{
+			ev.EventTime.Sub(*wh.StartDate).Seconds(),
+			string(ev.EventType),
+			base64.StdEncoding.EncodeToString(ev.Payload),
+			string(ev.GetMetadata(integrity.MetadataKey)),
+		}
+		if metadata := ev.StreamMetadata(); metadata != nil {
+			event = append(event, metadata)
+		}
+		return event, nil
+	}
+	ev, err := eventlog.DecodeLatest(data)
+	if err != nil || ev.CommitEndDate != nil || ev.CommitError != "" {
+		return nil, err
+	}
+	return types.SessionEventStream{
+		ev.EventTime.Sub(*wh.StartDate).Seconds(),
+		string(ev.EventType),
+		base64.StdEncoding.EncodeToString(ev.Data),
+	}, nil
+}
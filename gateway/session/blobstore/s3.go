@@ -0,0 +1,116 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	awss3 "github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const defaultS3Region = "us-east-1"
+
+type S3Options struct {
+	// Endpoint is the url of a S3 compatible storage (e.g.: http://127.0.0.1:9000),
+	// it defaults to the AWS S3 endpoint of the region
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to all keys
+	Prefix string
+	// AccessKeyID and SecretAccessKey are static credentials, when they are empty
+	// the credentials are obtained from the default credential chain of AWS
+	// (environment variables, shared config, web identity, ECS or EC2 roles)
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+type s3 struct {
+	bucket string
+	prefix string
+	client *awss3.Client
+}
+
+// NewS3 returns a store that saves blobs in a S3 compatible storage.
+// Custom endpoints use path style requests which are supported by most
+// of the compatible implementations (MinIO, Ceph, etc).
+func NewS3(opts S3Options) (*s3, error) {
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("bucket for the s3 store is empty")
+	}
+	if (opts.AccessKeyID == "") != (opts.SecretAccessKey == "") {
+		return nil, fmt.Errorf("missing credentials for the s3 store, it requires the access key id and the secret access key")
+	}
+	if opts.Endpoint != "" {
+		endpoint, err := url.Parse(opts.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing s3 endpoint, reason=%v", err)
+		}
+		if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+			return nil, fmt.Errorf("s3 endpoint must be an http or https url, got=%v", opts.Endpoint)
+		}
+	}
+	var loadOpts []func(*config.LoadOptions) error
+	if opts.Region != "" {
+		loadOpts = append(loadOpts, config.WithRegion(opts.Region))
+	}
+	if opts.AccessKeyID != "" {
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(opts.AccessKeyID, opts.SecretAccessKey, "")))
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background(), loadOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed loading aws config for the s3 store, reason=%v", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultS3Region
+	}
+	client := awss3.NewFromConfig(cfg, func(o *awss3.Options) {
+		if opts.Endpoint != "" {
+			o.BaseEndpoint = aws.String(opts.Endpoint)
+			o.UsePathStyle = true
+		}
+		// the content is streamed without computing its hash, it allows
+		// uploading readers that can't be rewinded to plain http endpoints
+		o.APIOptions = append(o.APIOptions, v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware)
+	})
+	return &s3{bucket: opts.Bucket, prefix: opts.Prefix, client: client}, nil
+}
+
+func (s *s3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, &awss3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(path.Join(s.prefix, key)),
+		Body:          r,
+		ContentLength: aws.Int64(size),
+		ContentType:   aws.String("application/octet-stream"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed storing s3 object %v, reason=%v", key, err)
+	}
+	return nil
+}
+
+func (s *s3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &awss3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(path.Join(s.prefix, key)),
+	})
+	if err != nil {
+		var respErr *awshttp.ResponseError
+		if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed obtaining s3 object %v, reason=%v", key, err)
+	}
+	return out.Body, nil
+}
@@ -3,6 +3,7 @@ package eventlogv1
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -26,9 +27,16 @@ const (
 
 	commitErrKeyName string = "__commit_error"
 
+	// PGParamsMetadataKey is the key of the metadata containing the parameters bound
+	// to a statement as a json list, null values are kept as null.
+	PGParamsMetadataKey string = "postgres.params"
+
 	Version = "v1"
 )
 
+// streamMetadataKeys are the metadata kept with the event in the event stream of the session
+var streamMetadataKeys = []string{PGParamsMetadataKey}
+
 type EventLog struct {
 	EventTime time.Time
 	EventType EventType
@@ -80,6 +88,23 @@ func (e *EventLog) GetMetadata(key string) []byte {
 	return e.metadata[key]
 }
 
+// StreamMetadata returns the metadata stored with the event in the event stream of
+// the session, the values are json documents. It returns nil when there's no metadata.
+func (e *EventLog) StreamMetadata() map[string]json.RawMessage {
+	var metadata map[string]json.RawMessage
+	for _, key := range streamMetadataKeys {
+		val := e.GetMetadata(key)
+		if len(val) == 0 || !json.Valid(val) {
+			continue
+		}
+		if metadata == nil {
+			metadata = map[string]json.RawMessage{}
+		}
+		metadata[key] = val
+	}
+	return metadata
+}
+
 func (e *EventLog) IsCommitErr() bool { return len(e.GetMetadata(commitErrKeyName)) > 0 }
 func (e *EventLog) String() string {
 	return fmt.Sprintf("time=%v,type=%v,commit-err=%v,metadata=%v,payload=%v",
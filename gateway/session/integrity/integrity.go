@@ -0,0 +1,232 @@
+package integrity
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math"
+
+	pb "github.com/hoophq/hoop/common/proto"
+	"github.com/hoophq/hoop/gateway/storagev2/types"
+)
+
+const (
+	// MetadataKey is the key of the event log metadata containing the chained hash of the event
+	MetadataKey string = "integrity.hash"
+
+	Version string = "v1"
+
+	// MaxTCPPayloadSize is the max size of tcp and ssh events stored in the event stream of the session,
+	// it avoids auditing blob content (files, images, etc)
+	MaxTCPPayloadSize = 5000
+)
+
+// Header contains the attributes of the session that are part of the chain.
+// All of them are immutable after the session is created.
+type Header struct {
+	OrgID          string
+	SessionID      string
+	UserID         string
+	ConnectionName string
+	ConnectionType string
+	Verb           string
+}
+
+// Chain computes a chain of hashes of the events of a session. The first hash
+// is computed from the header and each event hash includes the hash of the previous
+// one. Changing, removing or reordering any event changes all the subsequent hashes.
+type Chain struct {
+	prev           []byte
+	start          string
+	connectionType string
+}
+
+func NewChain(h Header) *Chain {
+	hasher := sha256.New()
+	for _, field := range []string{Version, h.OrgID, h.SessionID, h.UserID, h.ConnectionName, h.ConnectionType, h.Verb} {
+		writeField(hasher, []byte(field))
+	}
+	prev := hasher.Sum(nil)
+	return &Chain{prev: prev, start: hex.EncodeToString(prev), connectionType: h.ConnectionType}
+}
+
+// TruncatePayload returns the bytes of the payload stored in the event stream of the session
+func TruncatePayload(connectionType string, payload []byte) []byte {
+	if len(payload) > MaxTCPPayloadSize &&
+		(connectionType == pb.ConnectionTypeTCP.String() || connectionType == pb.ConnectionTypeSSH.String()) {
+		return payload[0:MaxTCPPayloadSize]
+	}
+	return payload
+}
+
+// Next computes the hash of the event chaining it with the previous hash.
+// The elapsed time is the amount of seconds since the start of the session. Only the
+// stored bytes of the payload are hashed, the events could be verified from the database
+// or from the full recording of the session.
+func (c *Chain) Next(elapsed float64, eventType byte, payload []byte) string {
+	payload = TruncatePayload(c.connectionType, payload)
+	hasher := sha256.New()
+	hasher.Write(c.prev)
+	var header [9]byte
+	binary.BigEndian.PutUint64(header[0:8], math.Float64bits(elapsed))
+	header[8] = eventType
+	hasher.Write(header[:])
+	writeField(hasher, payload)
+	c.prev = hasher.Sum(nil)
+	return hex.EncodeToString(c.prev)
+}
+
+// Digest returns the last hash of the chain
+func (c *Chain) Digest() string { return hex.EncodeToString(c.prev) }
+
+// writeField writes the length of the data before it to avoid ambiguous concatenations
+func writeField(h hash.Hash, data []byte) {
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(data)))
+	h.Write(size[:])
+	h.Write(data)
+}
+
+// Sign returns the integrity record of the chain, the digest is signed when key is not nil
+func Sign(c *Chain, key ed25519.PrivateKey) *types.SessionIntegrity {
+	return sign(&types.SessionIntegrity{Version: Version, Digest: c.Digest()}, key)
+}
+
+// SignTruncated returns the integrity record of a chain whose event stream is stored truncated.
+// The number of stored events and the hash of the last one are signed with the digest,
+// the stored events are verified against them.
+func SignTruncated(c *Chain, key ed25519.PrivateKey, stored []types.SessionEventStream) *types.SessionIntegrity {
+	storedDigest := c.start
+	if len(stored) > 0 && len(stored[len(stored)-1]) > 3 {
+		storedDigest, _ = stored[len(stored)-1][3].(string)
+	}
+	storedEvents := len(stored)
+	return sign(&types.SessionIntegrity{
+		Version:      Version,
+		Digest:       c.Digest(),
+		StoredEvents: &storedEvents,
+		StoredDigest: storedDigest,
+	}, key)
+}
+
+func sign(si *types.SessionIntegrity, key ed25519.PrivateKey) *types.SessionIntegrity {
+	if key != nil {
+		si.KeyID = KeyID(key.Public().(ed25519.PublicKey))
+		si.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, signedMessage(si)))
+	}
+	return si
+}
+
+// signedMessage returns the bytes of the integrity record covered by the signature.
+// The digest is signed as is, the truncation point is hashed with it when it's present.
+func signedMessage(si *types.SessionIntegrity) []byte {
+	digest, _ := hex.DecodeString(si.Digest)
+	if si.StoredEvents == nil {
+		return digest
+	}
+	hasher := sha256.New()
+	writeField(hasher, digest)
+	var storedEvents [8]byte
+	binary.BigEndian.PutUint64(storedEvents[:], uint64(*si.StoredEvents))
+	writeField(hasher, storedEvents[:])
+	writeField(hasher, []byte(si.StoredDigest))
+	return hasher.Sum(nil)
+}
+
+// KeyID returns a short identifier of a public key
+func KeyID(pubKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(pubKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+type Result struct {
+	// Verified is true when the chain and the signature of the digest are valid
+	Verified bool
+	// EventsCount is the number of events verified
+	EventsCount int
+	// TamperedEventIndex is the index of the first event that doesn't match the chain,
+	// it's equal to EventsCount when events were appended or removed at the end of the stream.
+	TamperedEventIndex *int
+	// Unverifiable is true when the event stream is truncated and the digest
+	// could not be verified, all the stored events matched the signed truncation point.
+	Unverifiable bool
+	Reason       string
+}
+
+// Verify recomputes the chain of the events and compares it to the hash of each event,
+// then it validates the digest and its signature. The events must be in the format
+// [<elapsed-seconds>, <event-type>, <base64-payload>, <hash>]. When the integrity record has a
+// truncation point, the events stored until it are verified and the session is reported as unverifiable.
+func Verify(h Header, events []types.SessionEventStream, si *types.SessionIntegrity, pubKey ed25519.PublicKey) *Result {
+	res := &Result{EventsCount: len(events)}
+	if si == nil || si.Digest == "" {
+		res.Reason = "session does not have an integrity record"
+		return res
+	}
+	if si.Version != Version {
+		res.Reason = fmt.Sprintf("unknown integrity version %q", si.Version)
+		return res
+	}
+	tampered := func(idx int, format string, a ...any) *Result {
+		res.TamperedEventIndex = &idx
+		res.Reason = fmt.Sprintf(format, a...)
+		return res
+	}
+	chain := NewChain(h)
+	for i, event := range events {
+		if len(event) < 4 {
+			return tampered(i, "event %v does not have a hash", i)
+		}
+		elapsed, _ := event[0].(float64)
+		eventType, _ := event[1].(string)
+		payloadEnc, _ := event[2].(string)
+		eventHash, _ := event[3].(string)
+		payload, err := base64.StdEncoding.DecodeString(payloadEnc)
+		if err != nil || len(eventType) != 1 {
+			return tampered(i, "event %v is not in a valid format", i)
+		}
+		if got := chain.Next(elapsed, eventType[0], payload); got != eventHash {
+			return tampered(i, "event %v hash mismatch, expected=%v, got=%v", i, got, eventHash)
+		}
+	}
+	truncated := false
+	if chain.Digest() != si.Digest {
+		if si.StoredEvents == nil {
+			return tampered(len(events), "digest mismatch, events were removed or appended to the end of the session")
+		}
+		if len(events) != *si.StoredEvents || chain.Digest() != si.StoredDigest {
+			return tampered(min(len(events), *si.StoredEvents),
+				"stored digest mismatch, events were removed or appended to the end of the truncated session")
+		}
+		truncated = true
+	}
+	if si.Signature == "" {
+		res.Reason = "digest is not signed"
+		return res
+	}
+	if pubKey == nil {
+		res.Reason = "unable to verify the signature, the gateway does not have a signing key"
+		return res
+	}
+	if keyID := KeyID(pubKey); keyID != si.KeyID {
+		res.Reason = fmt.Sprintf("digest was signed with a distinct key, key-id=%v, gateway-key-id=%v", si.KeyID, keyID)
+		return res
+	}
+	sig, _ := base64.StdEncoding.DecodeString(si.Signature)
+	if !ed25519.Verify(pubKey, signedMessage(si), sig) {
+		res.Reason = "digest signature is invalid"
+		return res
+	}
+	if truncated {
+		res.Unverifiable = true
+		res.Reason = fmt.Sprintf("the event stream is truncated, the %v stored events were verified "+
+			"but the digest of the session could not be verified", len(events))
+		return res
+	}
+	res.Verified = true
+	return res
+}
@@ -0,0 +1,140 @@
+package integrity
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	eventlogv1 "github.com/hoophq/hoop/gateway/session/eventlog/v1"
+	"github.com/hoophq/hoop/gateway/storagev2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var testHeader = Header{
+	OrgID:          "org-id",
+	SessionID:      "session-id",
+	UserID:         "user-id",
+	ConnectionName: "pg",
+	ConnectionType: "database",
+	Verb:           "connect",
+}
+
+func newEvents(chain *Chain, payloads ...string) []types.SessionEventStream {
+	var events []types.SessionEventStream
+	for i, payload := range payloads {
+		elapsed := float64(i) + 0.5
+		hash := chain.Next(elapsed, 'i', []byte(payload))
+		events = append(events, types.SessionEventStream{
+			elapsed, "i", base64.StdEncoding.EncodeToString([]byte(payload)), hash})
+	}
+	return events
+}
+
+func idx(i int) *int { return &i }
+
+func TestVerify(t *testing.T) {
+	_, privKey, _ := ed25519.GenerateKey(nil)
+	_, otherKey, _ := ed25519.GenerateKey(nil)
+	pubKey := privKey.Public().(ed25519.PublicKey)
+	chain := NewChain(testHeader)
+	events := newEvents(chain, "SELECT 1", "SELECT 2", "SELECT 3")
+	signed := Sign(chain, privKey)
+
+	tamperedPayload := append([]types.SessionEventStream{}, events...)
+	tamperedPayload[1] = types.SessionEventStream{events[1][0], "i", base64.StdEncoding.EncodeToString([]byte("DROP TABLE")), events[1][3]}
+	tamperedHeader := testHeader
+	tamperedHeader.UserID = "other-user"
+	tamperedSignature := *signed
+	tamperedSignature.Signature = Sign(chain, otherKey).Signature
+	truncated := SignTruncated(chain, privKey, events[:2])
+	tamperedTruncation := *truncated
+	tamperedTruncation.StoredEvents = idx(1)
+	tamperedTruncation.StoredDigest = events[0][3].(string)
+
+	for _, tt := range []struct {
+		msg              string
+		header           Header
+		events           []types.SessionEventStream
+		si               *types.SessionIntegrity
+		pubKey           ed25519.PublicKey
+		wantVerified     bool
+		wantTampered     *int
+		wantUnverifiable bool
+		wantReason       string
+	}{
+		{msg: "it should verify the session", header: testHeader, events: events, si: signed, pubKey: pubKey, wantVerified: true},
+		{msg: "it should report the tampered event", header: testHeader, events: tamperedPayload, si: signed, pubKey: pubKey,
+			wantTampered: idx(1), wantReason: "event 1 hash mismatch"},
+		{msg: "it should report the first event when the header is tampered", header: tamperedHeader, events: events, si: signed, pubKey: pubKey,
+			wantTampered: idx(0), wantReason: "event 0 hash mismatch"},
+		{msg: "it should report removed events at the end of the session", header: testHeader, events: events[:2], si: signed, pubKey: pubKey,
+			wantTampered: idx(2), wantReason: "digest mismatch"},
+		{msg: "it should report truncated sessions as unverifiable", header: testHeader, events: events[:2], si: truncated, pubKey: pubKey,
+			wantUnverifiable: true, wantReason: "the event stream is truncated, the 2 stored events were verified"},
+		{msg: "it should verify the full recording of truncated sessions", header: testHeader, events: events, si: truncated, pubKey: pubKey,
+			wantVerified: true},
+		{msg: "it should report the tampered event of truncated sessions", header: testHeader, events: tamperedPayload[:2], si: truncated, pubKey: pubKey,
+			wantTampered: idx(1), wantReason: "event 1 hash mismatch"},
+		{msg: "it should report removed events at the end of truncated sessions", header: testHeader, events: events[:1], si: truncated, pubKey: pubKey,
+			wantTampered: idx(1), wantReason: "stored digest mismatch"},
+		{msg: "it should fail when the truncation point is changed", header: testHeader, events: events[:1], si: &tamperedTruncation, pubKey: pubKey,
+			wantReason: "digest signature is invalid"},
+		{msg: "it should report removed events when the session is not truncated", header: testHeader, events: events[:1], si: signed, pubKey: pubKey,
+			wantTampered: idx(1), wantReason: "digest mismatch, events were removed"},
+		{msg: "it should fail with an invalid signature", header: testHeader, events: events, si: &tamperedSignature, pubKey: pubKey,
+			wantReason: "digest signature is invalid"},
+		{msg: "it should fail when the digest is signed by another key", header: testHeader, events: events, si: Sign(chain, otherKey), pubKey: pubKey,
+			wantReason: "digest was signed with a distinct key"},
+		{msg: "it should fail when the digest is not signed", header: testHeader, events: events, si: Sign(chain, nil), pubKey: pubKey,
+			wantReason: "digest is not signed"},
+		{msg: "it should fail without an integrity record", header: testHeader, events: events,
+			wantReason: "session does not have an integrity record"},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got := Verify(tt.header, tt.events, tt.si, tt.pubKey)
+			assert.Equal(t, tt.wantVerified, got.Verified)
+			assert.Equal(t, tt.wantTampered, got.TamperedEventIndex)
+			assert.Equal(t, tt.wantUnverifiable, got.Unverifiable)
+			assert.Contains(t, got.Reason, tt.wantReason)
+		})
+	}
+}
+
+// The hashes are computed when the events are written and verified from the stored
+// event stream, the elapsed time must be the same after encoding and decoding them.
+func TestVerifyEncodedEvents(t *testing.T) {
+	startDate := time.Now().UTC()
+	var encStartDate time.Time
+	startDateJSON, _ := json.Marshal(startDate)
+	assert.NoError(t, json.Unmarshal(startDateJSON, &encStartDate))
+
+	chain := NewChain(testHeader)
+	var events []types.SessionEventStream
+	for _, payload := range []string{"SELECT 1", "SELECT 2"} {
+		eventTime := time.Now().UTC()
+		hash := chain.Next(eventTime.Sub(startDate.Round(0)).Seconds(), byte(eventlogv1.InputType), []byte(payload))
+		data, err := eventlogv1.New(eventTime, eventlogv1.InputType, []byte(payload), nil).
+			WithMetadata(MetadataKey, []byte(hash)).
+			Encode()
+		assert.NoError(t, err)
+
+		ev, err := eventlogv1.Decode(data)
+		assert.NoError(t, err)
+		event := types.SessionEventStream{
+			ev.EventTime.Sub(encStartDate).Seconds(),
+			string(ev.EventType),
+			base64.StdEncoding.EncodeToString(ev.Payload),
+			string(ev.GetMetadata(MetadataKey)),
+		}
+		// simulate storing the event stream as json
+		eventJSON, _ := json.Marshal(event)
+		var storedEvent types.SessionEventStream
+		assert.NoError(t, json.Unmarshal(eventJSON, &storedEvent))
+		events = append(events, storedEvent)
+	}
+	got := Verify(testHeader, events, Sign(chain, nil), nil)
+	assert.Nil(t, got.TamperedEventIndex)
+	assert.Equal(t, "digest is not signed", got.Reason)
+}
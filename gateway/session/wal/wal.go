@@ -132,4 +132,20 @@ func (w *WalLog) ReadAtMost(max uint32, readerFn ReaderFunc) (bool, error) {
 func (w *WalLog) ReadFull(readerFn ReaderFunc) (bool, error) {
 	return w.ReadAtMost(DefaultMaxRead, readerFn)
 }
+
+// ReadAll reads all events from the write ahead log without any size limit.
+func (w *WalLog) ReadAll(readerFn ReaderFunc) error {
+	for i := defaultDataIndex; ; i++ {
+		eventStreamBytes, err := w.wlog.Read(uint64(i))
+		if err == wal.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := readerFn(eventStreamBytes); err != nil {
+			return err
+		}
+	}
+}
 func (w *WalLog) Close() error { return w.wlog.Close() }
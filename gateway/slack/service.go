@@ -79,16 +79,24 @@ type MessageReviewRequest struct {
 	Name           string
 	Email          string
 	UserGroups     []string
-	ApprovalGroups []string
+	ApprovalGroups []ApprovalGroup
 	Connection     string
 	ConnectionType string
 	Script         string
 	SessionTime    *time.Duration
+	SessionStartAt *time.Time
 	WebappURL      string
 	SessionID      string
 	SlackChannels  []string
 }
 
+// ApprovalGroup is a group that must approve the review in a stage of the workflow
+type ApprovalGroup struct {
+	Name   string
+	Stage  int
+	Quorum int
+}
+
 type MessageReviewResponse struct {
 	ID        string
 	EventKind string
@@ -102,6 +110,11 @@ type MessageReviewResponse struct {
 
 func (m *MessageReviewRequest) sessionTime() string {
 	if m.SessionTime != nil {
+		if m.SessionStartAt != nil {
+			endAt := m.SessionStartAt.Add(*m.SessionTime)
+			return fmt.Sprintf("%s - %s", m.SessionStartAt.Format("Mon, 02 Jan 2006 15:04 MST"),
+				endAt.Format("Mon, 02 Jan 2006 15:04 MST"))
+		}
 		minutes := m.SessionTime.Minutes()
 		switch {
 		case minutes < 60:
@@ -113,6 +126,22 @@ func (m *MessageReviewRequest) sessionTime() string {
 	return "-"
 }
 
+// groupText describes the stage and the quorum of a group when the review has a workflow
+func (m *MessageReviewRequest) groupText(group ApprovalGroup) string {
+	stages := 0
+	for _, g := range m.ApprovalGroups {
+		stages = max(stages, g.Stage)
+	}
+	text := fmt.Sprintf("group *%s*", group.Name)
+	if stages > 1 {
+		text += fmt.Sprintf(" - stage %v of %v", group.Stage, stages)
+	}
+	if group.Quorum > 1 {
+		text += fmt.Sprintf(" - requires %v approvals", group.Quorum)
+	}
+	return text
+}
+
 func (s *SlackService) SendMessageReview(msg *MessageReviewRequest) error {
 	title := "Review"
 
@@ -131,10 +160,14 @@ func (s *SlackService) SendMessageReview(msg *MessageReviewRequest) error {
 		{Type: slack.MarkdownType, Text: fmt.Sprintf("groups\n*%s*", groupList)},
 	}, nil)
 
+	sessionTimeLabel := "session time"
+	if msg.SessionStartAt != nil {
+		sessionTimeLabel = "access window"
+	}
 	// email, session time metadata
 	metaSection2 := slack.NewSectionBlock(nil, []*slack.TextBlockObject{
 		{Type: slack.MarkdownType, Text: fmt.Sprintf("email\n*%s*", msg.Email)},
-		{Type: slack.MarkdownType, Text: fmt.Sprintf("session time\n*%s*", msg.sessionTime())},
+		{Type: slack.MarkdownType, Text: fmt.Sprintf("%s\n*%s*", sessionTimeLabel, msg.sessionTime())},
 	}, nil)
 
 	// connection metadata
@@ -178,14 +211,14 @@ func (s *SlackService) SendMessageReview(msg *MessageReviewRequest) error {
 	}
 
 	// add groups button
-	for i, groupName := range msg.ApprovalGroups {
-		key := fmt.Sprintf("%s:%s", msg.ID, groupName)
+	for i, group := range msg.ApprovalGroups {
+		key := fmt.Sprintf("%s:%s", msg.ID, group.Name)
 		blockID := fmt.Sprintf("%s:%s", key, strconv.Itoa(i))
 
 		blocks = append(blocks,
 			slack.NewSectionBlock(&slack.TextBlockObject{
 				Type: slack.MarkdownType,
-				Text: fmt.Sprintf("group *%s*", groupName),
+				Text: msg.groupText(group),
 			}, nil, nil),
 			slack.NewActionBlock(
 				blockID,
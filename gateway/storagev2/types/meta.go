@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"time"
 
 	pb "github.com/hoophq/hoop/common/proto"
 )
@@ -30,3 +31,24 @@ func (p *PluginConnection) SetName() {
 		p.Name = p.Connection.Name
 	}
 }
+
+// CurrentStage returns the lowest stage with groups that didn't approve the review yet,
+// it returns the last stage when all groups approved it.
+func (r *Review) CurrentStage() int {
+	current, last := -1, 0
+	for _, g := range r.ReviewGroupsData {
+		last = max(last, g.Stage)
+		if g.Status != ReviewStatusApproved && (current == -1 || g.Stage < current) {
+			current = g.Stage
+		}
+	}
+	if current == -1 {
+		return last
+	}
+	return current
+}
+
+// IsExpired reports if a pending review has passed its expiration time
+func (r *Review) IsExpired(t time.Time) bool {
+	return r.Status == ReviewStatusPending && r.ExpireAt != nil && r.ExpireAt.Before(t)
+}
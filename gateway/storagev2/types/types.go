@@ -126,6 +126,10 @@ type ReviewGroup struct {
 	Status     ReviewStatus `json:"status"      edn:"review-group/status"`
 	ReviewedBy *ReviewOwner `json:"reviewed_by" edn:"review-group/reviewed-by"`
 	ReviewDate *string      `json:"review_date" edn:"review-group/review_date"`
+	Stage      int          `json:"stage"       edn:"review-group/stage"`
+	Quorum     int          `json:"quorum"      edn:"review-group/quorum"`
+	// the distinct approvals of the group until the quorum is reached
+	Approvals []ReviewOwner `json:"approvals" edn:"review-group/approvals"`
 }
 
 type Review struct {
@@ -138,6 +142,7 @@ type Review struct {
 	InputEnvVars     map[string]string `edn:"review/input-envvars"`
 	InputClientArgs  []string          `edn:"review/input-clientargs"`
 	AccessDuration   time.Duration     `edn:"review/access-duration"`
+	AccessStartAt    *time.Time        `edn:"review/access-start-at"`
 	Status           ReviewStatus      `edn:"review/status"`
 	RevokeAt         *time.Time        `edn:"review/revoke-at"`
 	CreatedBy        any               `edn:"review/created-by"`
@@ -146,6 +151,8 @@ type Review struct {
 	Connection       ReviewConnection  `edn:"review/review-connection"`
 	ReviewGroupsIds  []string          `edn:"review/review-groups"`
 	ReviewGroupsData []ReviewGroup     `edn:"review/review-groups-data"`
+	ExpireAt         *time.Time        `edn:"review/expire-at"`
+	DenySelfApproval bool              `edn:"review/deny-self-approval"`
 }
 
 type ReviewJSON struct {
@@ -158,11 +165,15 @@ type ReviewJSON struct {
 	InputEnvVars     map[string]string `json:"input_envvars"`
 	InputClientArgs  []string          `json:"input_clientargs"`
 	AccessDuration   time.Duration     `json:"access_duration"`
+	AccessStartAt    *time.Time        `json:"access_start_at"`
 	Status           ReviewStatus      `json:"status"`
 	RevokeAt         *time.Time        `json:"revoke_at"`
 	ReviewOwner      ReviewOwner       `json:"review_owner"`
 	Connection       ReviewConnection  `json:"review_connection"`
 	ReviewGroupsData []ReviewGroup     `json:"review_groups_data"`
+	CurrentStage     int               `json:"current_stage"`
+	ExpireAt         *time.Time        `json:"expire_at"`
+	DenySelfApproval bool              `json:"deny_self_approval"`
 }
 
 type SessionEventStream []any
@@ -170,6 +181,10 @@ type SessionNonIndexedEventStreamList map[edn.Keyword][]SessionEventStream
 type SessionScript map[edn.Keyword]string
 type SessionLabels map[string]string
 
+// SessionLabelTerminalSize is the label with the size of the terminal
+// when an interactive session starts, in the format <rows>,<cols>
+const SessionLabelTerminalSize = "terminal.size"
+
 type SessionOptionKey string
 type SessionOption struct {
 	OptionKey SessionOptionKey
@@ -211,10 +226,24 @@ type Session struct {
 	// Must NOT index streams (all top keys are indexed in xtdb)
 	NonIndexedStream SessionNonIndexedEventStreamList `json:"-"`
 	EventSize        int64                            `json:"event_size"`
+	Integrity        *SessionIntegrity                `json:"integrity"`
 	StartSession     time.Time                        `json:"start_date"`
 	EndSession       *time.Time                       `json:"end_date"`
 }
 
+// SessionIntegrity is the digest of the chain of hashes of the session events
+type SessionIntegrity struct {
+	Version   string `json:"version"`
+	Digest    string `json:"digest"`
+	KeyID     string `json:"key_id"`
+	Signature string `json:"signature"`
+	// StoredEvents and StoredDigest are set when the event stream stored in the
+	// database is truncated, they are the number of stored events and the hash
+	// of the last one. Both are covered by the signature.
+	StoredEvents *int   `json:"stored_events,omitempty"`
+	StoredDigest string `json:"stored_digest,omitempty"`
+}
+
 type User struct {
 	Id      string         `json:"id"       edn:"xt/id"`
 	Org     string         `json:"-"        edn:"user/org"`
@@ -75,6 +75,18 @@ func (s *Server) listenAgentMessages(pctx *plugintypes.Context, stream *streamcl
 		if proxyStream == nil {
 			continue
 		}
+		// the output held by the plugins is sent before the session is closed
+		if pb.PacketType(pkt.Type) == pbclient.SessionClose {
+			for _, flushPkt := range proxyStream.PluginExecOnFlush(*pctx) {
+				if _, err := proxyStream.PluginExecOnReceive(*pctx, flushPkt); err != nil {
+					log.With("sid", pctx.SID).Warnf("plugin reject flushed packet, err=%v", err)
+					continue
+				}
+				if err = proxyStream.Send(flushPkt); err != nil {
+					log.With("sid", pctx.SID).Debugf("failed to send flushed packet to proxy stream, err=%v", err)
+				}
+			}
+		}
 		if _, err := proxyStream.PluginExecOnReceive(*pctx, pkt); err != nil {
 			log.Warnf("plugin reject packet, err=%v", err)
 			sentry.CaptureException(err)
@@ -6,6 +6,7 @@ import (
 
 	"github.com/getsentry/sentry-go"
 	"github.com/hoophq/hoop/common/apiutils"
+	"github.com/hoophq/hoop/common/dlp"
 	"github.com/hoophq/hoop/common/grpc"
 	"github.com/hoophq/hoop/common/log"
 	pb "github.com/hoophq/hoop/common/proto"
@@ -146,6 +147,7 @@ func (s *Server) listenClientMessages(stream *streamclient.ProxyStream) error {
 			pkt.Spec = make(map[string][]byte)
 		}
 		pkt.Spec[pb.SpecGatewaySessionID] = []byte(pctx.SID)
+		removeAgentSpecs(pkt)
 		shouldProcessClientPacket := true
 		connectResponse, err := stream.PluginExecOnReceive(pctx, pkt)
 		switch v := err.(type) {
@@ -167,6 +169,9 @@ func (s *Server) listenClientMessages(stream *streamclient.ProxyStream) error {
 				_ = stream.Send(connectResponse.ClientPacket)
 				shouldProcessClientPacket = false
 			}
+			if connectResponse.Discard {
+				shouldProcessClientPacket = false
+			}
 		}
 		if shouldProcessClientPacket {
 			err = s.processClientPacket(stream, pkt, pctx)
@@ -190,7 +195,9 @@ func (s *Server) processClientPacket(stream *streamclient.ProxyStream, pkt *pb.P
 			spec[pb.SpecAgentGCPRawCredentialsKey] = []byte(jsonCred)
 		}
 
-		if dlpProvider := appconfig.Get().DlpProvider(); dlpProvider != "" {
+		// the builtin provider redacts the data in the gateway (dlp plugin)
+		dlpProvider := appconfig.Get().DlpProvider()
+		if dlpProvider != "" && dlpProvider != dlp.ProviderBuiltin {
 			spec[pb.SpecAgentDlpProvider] = []byte(dlpProvider)
 		}
 
@@ -211,17 +218,23 @@ func (s *Server) processClientPacket(stream *streamclient.ProxyStream, pkt *pb.P
 			return pb.ErrAgentOffline
 		}
 		clientArgs := clientArgsDecode(pkt.Spec)
+		var infoTypes []string
+		if dlpProvider != dlp.ProviderBuiltin {
+			infoTypes = stream.GetRedactInfoTypes()
+		}
 		connParams, err := pb.GobEncode(&pb.AgentConnectionParams{
-			ConnectionName: pctx.ConnectionName,
-			ConnectionType: pb.ToConnectionType(pctx.ConnectionType, pctx.ConnectionSubType).String(),
-			UserID:         pctx.UserID,
-			UserEmail:      pctx.UserEmail,
-			EnvVars:        pctx.ConnectionSecret,
-			CmdList:        pctx.ConnectionCommand,
-			ClientArgs:     clientArgs,
-			ClientVerb:     pctx.ClientVerb,
-			ClientOrigin:   pctx.ClientOrigin,
-			DLPInfoTypes:   stream.GetRedactInfoTypes(),
+			ConnectionName:      pctx.ConnectionName,
+			ConnectionType:      pb.ToConnectionType(pctx.ConnectionType, pctx.ConnectionSubType).String(),
+			UserID:              pctx.UserID,
+			UserEmail:           pctx.UserEmail,
+			EnvVars:             pctx.ConnectionSecret,
+			CmdList:             pctx.ConnectionCommand,
+			ClientArgs:          clientArgs,
+			ClientVerb:          pctx.ClientVerb,
+			ClientOrigin:        pctx.ClientOrigin,
+			DLPInfoTypes:        infoTypes,
+			DataMaskingPolicies: stream.GetDataMaskingPolicies(),
+			DataMaskingHashKey:  dlp.NewHashKey(appconfig.Get().DataMaskingHashSecret(), pctx.OrgID, pctx.ConnectionID),
 		})
 		if err != nil {
 			return fmt.Errorf("failed encoding connection params err=%v", err)
@@ -244,6 +257,21 @@ func (s *Server) processClientPacket(stream *streamclient.ProxyStream, pkt *pb.P
 	}
 }
 
+// agentSpecKeys are the keys of the spec set only by the agent,
+// e.g.: the session labels recorded by the audit plugin
+var agentSpecKeys = []string{
+	pb.SpecKubernetesNamespace,
+	pb.SpecKubernetesPod,
+	pb.SpecKubernetesService,
+}
+
+// removeAgentSpecs removes the keys of the spec that a client must not send
+func removeAgentSpecs(pkt *pb.Packet) {
+	for _, key := range agentSpecKeys {
+		delete(pkt.Spec, key)
+	}
+}
+
 func clientArgsDecode(spec map[string][]byte) []string {
 	var clientArgs []string
 	if spec != nil {
@@ -0,0 +1,24 @@
+package transport
+
+import (
+	"testing"
+
+	pb "github.com/hoophq/hoop/common/proto"
+	pbagent "github.com/hoophq/hoop/common/proto/agent"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoveAgentSpecs(t *testing.T) {
+	pkt := &pb.Packet{
+		Type: pbagent.ExecWriteStdin,
+		Spec: map[string][]byte{
+			pb.SpecGatewaySessionID:    []byte("sid"),
+			pb.SpecKubernetesNamespace: []byte("kube-system"),
+			pb.SpecKubernetesPod:       []byte("etcd-0"),
+			pb.SpecKubernetesService:   []byte("etcd"),
+		},
+	}
+	removeAgentSpecs(pkt)
+	// it should not allow the client to overwrite the session labels sent by the agent
+	assert.Equal(t, map[string][]byte{pb.SpecGatewaySessionID: []byte("sid")}, pkt.Spec)
+}
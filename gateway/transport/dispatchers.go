@@ -4,12 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/hoophq/hoop/common/log"
 	pb "github.com/hoophq/hoop/common/proto"
+	pbclient "github.com/hoophq/hoop/common/proto/client"
 	"github.com/hoophq/hoop/gateway/storagev2/types"
+	"github.com/hoophq/hoop/gateway/transport/streamclient"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -24,17 +29,53 @@ type openSessionResponse struct {
 	err error
 }
 
-type dispatcherState struct {
-	requestCh  chan *types.Client
+type openSessionRequest struct {
+	client     *types.Client
 	responseCh chan openSessionResponse
-	cancelFn   context.CancelFunc
+}
+
+// sendResponse back to who's listening the response channel.
+// It will make the function DispatchOpenSession
+// to return if someone is calling it and waiting for a response.
+func (r openSessionRequest) sendResponse(pkt *pb.Packet, err error) {
+	select {
+	case r.responseCh <- openSessionResponse{pkt, err}:
+	default:
+		log.Warnf("response already sent back to api client")
+	}
+}
+
+// Tunnel is a session of a connection opened by the proxy manager,
+// each tunnel listens in a distinct port in the client.
+type Tunnel struct {
+	SessionID      string
+	ConnectionName string
+	Port           string
+	AccessDuration time.Duration
+	ConnectedAt    time.Time
+}
+
+type tunnel struct {
+	Tunnel
+	// the stream is nil while the session is being opened
+	stream *streamclient.ProxyStream
+}
+
+type dispatcherState struct {
+	requestCh chan openSessionRequest
+	cancelFn  context.CancelFunc
+
+	mu       sync.RWMutex
+	tunnels  map[string]*tunnel
+	idleTime time.Time
 }
 
 func newDispatcherState(cancelFn context.CancelFunc) *dispatcherState {
 	return &dispatcherState{
-		make(chan *types.Client),
-		make(chan openSessionResponse),
-		cancelFn,
+		requestCh: make(chan openSessionRequest),
+		cancelFn:  cancelFn,
+		tunnels:   map[string]*tunnel{},
+		idleTime:  time.Now().UTC(),
 	}
 }
 
@@ -54,20 +95,129 @@ func getDispatcherState(key string) *dispatcherState {
 	return val
 }
 
-func removeDispatcherState(key string) {
+// removeDispatcherState removes the state only if it's the same instance,
+// a new proxy manager of the same user may have replaced it.
+func removeDispatcherState(key string, val *dispatcherState) {
 	dispatcherStateLock.Lock()
 	defer dispatcherStateLock.Unlock()
-	delete(dipatcherStateMap, key)
+	if current, ok := dipatcherStateMap[key]; ok && (val == nil || current == val) {
+		delete(dipatcherStateMap, key)
+	}
 }
 
-// sendResponse back to who's listening the response channel.
-// It will make the function DispatchOpenSession
-// to return if someone is calling it and waiting for a response.
-func (d *dispatcherState) sendResponse(pkt *pb.Packet, err error) {
-	select {
-	case d.responseCh <- openSessionResponse{pkt, err}:
-	case <-time.After(time.Second * 2): // hang if doesn't send in 2 seconds
-		log.Warnf("timeout (2s) sending response back to api client")
+// reserveTunnel registers a tunnel for the requested connection. It returns an error
+// if the connection or the port is already in use by another tunnel of the client.
+func (d *dispatcherState) reserveTunnel(req *types.Client) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, t := range d.tunnels {
+		if t.ConnectionName == req.RequestConnectionName {
+			return status.Errorf(codes.AlreadyExists, "connection %v is already connected", req.RequestConnectionName)
+		}
+		if t.Port == req.RequestPort {
+			return status.Errorf(codes.AlreadyExists, "port %v is already in use by the connection %v", req.RequestPort, t.ConnectionName)
+		}
+	}
+	d.tunnels[req.RequestConnectionName] = &tunnel{Tunnel: Tunnel{
+		ConnectionName: req.RequestConnectionName,
+		Port:           req.RequestPort,
+		AccessDuration: req.RequestAccessDuration,
+	}}
+	return nil
+}
+
+// setTunnelStream marks the tunnel as connected with the stream of the opened session
+func (d *dispatcherState) setTunnelStream(connectionName string, stream *streamclient.ProxyStream) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if t, ok := d.tunnels[connectionName]; ok {
+		t.SessionID = stream.PluginContext().SID
+		t.ConnectedAt = time.Now().UTC()
+		t.stream = stream
+	}
+}
+
+// removeTunnel removes the tunnel of the connection if it belongs to the session,
+// an empty session id removes a tunnel that is not connected yet.
+func (d *dispatcherState) removeTunnel(connectionName, sid string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if t, ok := d.tunnels[connectionName]; !ok || t.SessionID != sid {
+		return
+	}
+	delete(d.tunnels, connectionName)
+	if len(d.tunnels) == 0 {
+		d.idleTime = time.Now().UTC()
+	}
+}
+
+// getTunnelStream returns the stream of a connected tunnel by its session id
+func (d *dispatcherState) getTunnelStream(sid string) *streamclient.ProxyStream {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, t := range d.tunnels {
+		if t.stream != nil && t.SessionID == sid {
+			return t.stream
+		}
+	}
+	return nil
+}
+
+// idleDuration returns for how long the client doesn't have any tunnels
+func (d *dispatcherState) idleDuration() time.Duration {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if len(d.tunnels) > 0 {
+		return 0
+	}
+	return time.Since(d.idleTime)
+}
+
+func (d *dispatcherState) listTunnels() []Tunnel {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	var items []Tunnel
+	for _, t := range d.tunnels {
+		if t.stream != nil {
+			items = append(items, t.Tunnel)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ConnectedAt.Before(items[j].ConnectedAt) })
+	return items
+}
+
+// closeTunnel ends the session of the tunnel informing the client with the reason.
+// It returns false if the tunnel doesn't exist or it's not connected yet.
+func (d *dispatcherState) closeTunnel(connectionName string, reason error) bool {
+	d.mu.RLock()
+	t, ok := d.tunnels[connectionName]
+	d.mu.RUnlock()
+	if !ok || t.stream == nil {
+		return false
+	}
+	var payload []byte
+	if reason != nil {
+		payload = []byte(reason.Error())
+	}
+	_ = t.stream.Send(&pb.Packet{
+		Type:    pbclient.SessionClose,
+		Spec:    map[string][]byte{pb.SpecGatewaySessionID: []byte(t.SessionID)},
+		Payload: payload,
+	})
+	_ = t.stream.Close(reason)
+	d.removeTunnel(connectionName, t.SessionID)
+	return true
+}
+
+func (d *dispatcherState) closeAllTunnels(reason error) {
+	d.mu.RLock()
+	var names []string
+	for name := range d.tunnels {
+		names = append(names, name)
+	}
+	d.mu.RUnlock()
+	for _, name := range names {
+		_ = d.closeTunnel(name, reason)
 	}
 }
 
@@ -81,8 +231,9 @@ func DispatchOpenSession(req *types.Client) (*pb.Packet, error) {
 		return nil, fmt.Errorf("proxy manager state %s not found", req.ID)
 	}
 	// it will trigger the open session phase logic
+	openReq := openSessionRequest{client: req, responseCh: make(chan openSessionResponse, 1)}
 	select {
-	case state.requestCh <- req:
+	case state.requestCh <- openReq:
 	case <-time.After(time.Millisecond * 500):
 		// the channel is closed or busy, cancel the underline context and
 		// indicate the caller that it's safe to reconnect it.
@@ -93,7 +244,7 @@ func DispatchOpenSession(req *types.Client) (*pb.Packet, error) {
 
 	// then wait for the response
 	select {
-	case resp := <-state.responseCh:
+	case resp := <-openReq.responseCh:
 		return resp.obj, resp.err
 	case <-time.After(time.Second * 10):
 		return nil, fmt.Errorf("timeout (10s) waiting to open a session")
@@ -107,7 +258,29 @@ func DispatchDisconnect(req *types.Client) error {
 	if state == nil {
 		return fmt.Errorf("proxy manager state %s not found", req.ID)
 	}
-	removeDispatcherState(req.ID)
+	removeDispatcherState(req.ID, state)
 	state.cancelFn()
 	return nil
 }
+
+// DispatchCloseTunnel ends the session of a single connection
+// keeping the client and the other tunnels connected.
+func DispatchCloseTunnel(req *types.Client, connectionName string) error {
+	state := getDispatcherState(req.ID)
+	if state == nil {
+		return fmt.Errorf("proxy manager state %s not found", req.ID)
+	}
+	if !state.closeTunnel(connectionName, fmt.Errorf("disconnected by the user")) {
+		return status.Errorf(codes.NotFound, "tunnel for connection %v not found", connectionName)
+	}
+	return nil
+}
+
+// ListTunnels returns the connected tunnels of a proxy manager client
+func ListTunnels(clientID string) []Tunnel {
+	state := getDispatcherState(clientID)
+	if state == nil {
+		return nil
+	}
+	return state.listTunnels()
+}
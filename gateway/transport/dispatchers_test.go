@@ -10,8 +10,10 @@ func TestDispatchOpenSession(t *testing.T) {
 	// _, cancelFn := context.WithCancel(context.Background())
 	state := newDispatcherState(nil)
 	addDispatcherStateEntry("123", state)
-	go func() { <-state.requestCh }() // noop receiver
-	go func() { state.responseCh <- openSessionResponse{nil, nil} }()
+	go func() {
+		req := <-state.requestCh
+		req.sendResponse(nil, nil)
+	}()
 	pkt, err := DispatchOpenSession(&types.Client{ID: "123"})
 	if err != nil {
 		t.Fatal("it must not return return error")
@@ -51,7 +53,6 @@ func TestDispatchOpenSessionErr(t *testing.T) {
 			if tt.noopReceiver != nil {
 				tt.noopReceiver(tt.state)
 			}
-			go func() { tt.state.responseCh <- openSessionResponse{nil, nil} }()
 			_, err := DispatchOpenSession(&types.Client{ID: tt.stateID})
 			if err == nil {
 				t.Fatal("want state error, got=nil")
@@ -66,3 +67,49 @@ func TestDispatchOpenSessionErr(t *testing.T) {
 		})
 	}
 }
+
+func TestDispatcherReserveTunnel(t *testing.T) {
+	state := newDispatcherState(func() {})
+	if err := state.reserveTunnel(&types.Client{RequestConnectionName: "pgdemo", RequestPort: "5432"}); err != nil {
+		t.Fatalf("did not expect error, got=%v", err)
+	}
+	for _, tt := range []struct {
+		msg     string
+		req     *types.Client
+		wantErr string
+	}{
+		{
+			msg:     "it must return error when the connection is already in use",
+			req:     &types.Client{RequestConnectionName: "pgdemo", RequestPort: "5433"},
+			wantErr: "rpc error: code = AlreadyExists desc = connection pgdemo is already connected",
+		},
+		{
+			msg:     "it must return error when the port is already in use",
+			req:     &types.Client{RequestConnectionName: "mysqldemo", RequestPort: "5432"},
+			wantErr: "rpc error: code = AlreadyExists desc = port 5432 is already in use by the connection pgdemo",
+		},
+		{
+			msg: "it must reserve a tunnel for a distinct connection and port",
+			req: &types.Client{RequestConnectionName: "mysqldemo", RequestPort: "3306"},
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			err := state.reserveTunnel(tt.req)
+			var gotErr string
+			if err != nil {
+				gotErr = err.Error()
+			}
+			if gotErr != tt.wantErr {
+				t.Errorf("want error=%q, got=%q", tt.wantErr, gotErr)
+			}
+		})
+	}
+	if tunnels := state.listTunnels(); len(tunnels) != 0 {
+		t.Errorf("it must not list tunnels that are not connected, got=%v", tunnels)
+	}
+	state.removeTunnel("pgdemo", "")
+	state.removeTunnel("mysqldemo", "")
+	if state.idleDuration() == 0 {
+		t.Errorf("it must be idle when there are no tunnels")
+	}
+}
@@ -1,7 +1,19 @@
 package accesscontrol
 
 import (
+	"bytes"
+	"fmt"
+
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/common/memory"
+	"github.com/hoophq/hoop/common/mongotypes"
+	"github.com/hoophq/hoop/common/mssqltypes"
+	"github.com/hoophq/hoop/common/mysqltypes"
+	"github.com/hoophq/hoop/common/pgtypes"
 	pb "github.com/hoophq/hoop/common/proto"
+	pbagent "github.com/hoophq/hoop/common/proto/agent"
+	pbclient "github.com/hoophq/hoop/common/proto/client"
+	"github.com/hoophq/hoop/common/redistypes"
 	"github.com/hoophq/hoop/gateway/storagev2/types"
 	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
 )
@@ -9,17 +21,293 @@ import (
 const Name string = "access_control"
 
 type plugin struct {
-	name string
+	name         string
+	sessionStore memory.Store
 }
 
-func New() *plugin { return &plugin{name: Name} }
+func New() *plugin { return &plugin{name: Name, sessionStore: memory.New()} }
 
 func (r *plugin) Name() string                          { return r.name }
 func (r *plugin) OnStartup(_ plugintypes.Context) error { return nil }
 func (p *plugin) OnUpdate(_, _ *types.Plugin) error     { return nil }
 func (r *plugin) OnConnect(_ plugintypes.Context) error { return nil }
-func (r *plugin) OnReceive(_ plugintypes.Context, _ *pb.Packet) (*plugintypes.ConnectResponse, error) {
+
+// OnReceive evaluates the guardrail rules against the statements decoded from
+// database protocol packets. When a statement is denied, the packet is not sent
+// to the agent and an error packet native to the protocol is sent back to the client.
+//
+// The packets of mysql, mssql and mongodb connections are held until the protocol
+// messages are complete, the agent receives the complete messages after they are evaluated.
+// The packets holding incomplete messages are dropped.
+func (r *plugin) OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plugintypes.ConnectResponse, error) {
+	switch pkt.Type {
+	case pbclient.SessionClose:
+		r.sessionStore.Del(pctx.SID)
+		return nil, nil
+	case pbagent.TCPConnectionClose, pbclient.TCPConnectionClose:
+		if ss, ok := r.sessionStore.Get(pctx.SID).(*sessionState); ok {
+			ss.mu.Lock()
+			delete(ss.connections, string(pkt.Spec[pb.SpecClientConnectionID]))
+			ss.mu.Unlock()
+		}
+		return nil, nil
+	case pbagent.PGConnectionWrite,
+		pbclient.PGConnectionWrite,
+		pbagent.MySQLConnectionWrite,
+		pbagent.MSSQLConnectionWrite,
+		pbagent.MongoDBConnectionWrite,
+		pbagent.RedisConnectionWrite:
+	default:
+		return nil, nil
+	}
+	rules, err := parseRules(pctx.PluginConnectionConfig)
+	if err != nil {
+		return nil, plugintypes.InvalidArgument("access control: %v", err)
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	if pkt.Type == pbclient.PGConnectionWrite {
+		ss := r.session(pctx.SID)
+		ss.mu.Lock()
+		pkt.Payload = ss.connection(string(pkt.Spec[pb.SpecClientConnectionID])).pg.onServerPacket(pkt.Payload)
+		ss.mu.Unlock()
+		return nil, nil
+	}
+	var errMsg string
+	// the rules can't be evaluated when the statements are not decoded, deny it to not fail open
+	stmts, err := r.decodePacket(pctx, pkt)
+	if err == nil && len(pkt.Payload) == 0 {
+		// the payload is held until the protocol messages are complete
+		return dropPacket(pkt), nil
+	}
+	switch deniedRule := matchRules(rules, stmts); {
+	case err != nil:
+		errMsg = "statement blocked by access control, unable to decode it"
+		log.With("sid", pctx.SID, "connection", pctx.ConnectionName, "user", pctx.UserEmail).
+			Warnf("access control denied packet, unable to decode statements, reason=%v", err)
+	case deniedRule != nil:
+		errMsg = fmt.Sprintf("statement blocked by access control rule %q", deniedRule.String())
+		log.With("sid", pctx.SID, "connection", pctx.ConnectionName, "user", pctx.UserEmail).
+			Infof("access control denied statement, rule=%v", deniedRule)
+	}
+	if pkt.Type == pbagent.PGConnectionWrite {
+		return r.onPGClientPacket(pctx, pkt, errMsg), nil
+	}
+	if errMsg == "" {
+		return nil, nil
+	}
+	clientPkt, err := newDeniedPacket(pkt, errMsg)
+	if err != nil {
+		return nil, plugintypes.InternalErr("failed encoding access control error packet", err)
+	}
+	// indicate to other plugins (audit) that this packet was denied
+	pkt.Spec[pb.SpecPluginAccessControlDeniedKey] = []byte(errMsg)
+	return &plugintypes.ConnectResponse{Context: nil, ClientPacket: clientPkt}, nil
+}
+func (r *plugin) OnDisconnect(pctx plugintypes.Context, _ error) error {
+	r.sessionStore.Del(pctx.SID)
+	return nil
+}
+func (r *plugin) OnShutdown() {}
+
+func (r *plugin) session(sid string) *sessionState {
+	ss, ok := r.sessionStore.Get(sid).(*sessionState)
+	if !ok {
+		ss = &sessionState{connections: map[string]*connState{}}
+		r.sessionStore.Set(sid, ss)
+	}
+	return ss
+}
+
+// onPGClientPacket keeps the extended query protocol in sync when a message is denied, the
+// messages of a denied extended query are not sent to the server, see pgState.
+func (r *plugin) onPGClientPacket(pctx plugintypes.Context, pkt *pb.Packet, errMsg string) *plugintypes.ConnectResponse {
+	ss := r.session(pctx.SID)
+	ss.mu.Lock()
+	payload := ss.connection(string(pkt.Spec[pb.SpecClientConnectionID])).pg.onClientPacket(pkt.Payload, errMsg)
+	ss.mu.Unlock()
+	if payload == nil {
+		return nil
+	}
+	if errMsg != "" {
+		// indicate to other plugins (audit) that this packet was denied
+		pkt.Spec[pb.SpecPluginAccessControlDeniedKey] = []byte(errMsg)
+	}
+	if len(payload) == 0 {
+		return dropPacket(pkt)
+	}
+	return &plugintypes.ConnectResponse{Context: nil, ClientPacket: newClientPacket(pbclient.PGConnectionWrite, pkt, payload)}
+}
+
+// decodePacket returns the statements of the packet, the payload of the packets of stream
+// protocols is replaced by the complete messages buffered in the connection.
+func (r *plugin) decodePacket(pctx plugintypes.Context, pkt *pb.Packet) ([]statement, error) {
+	switch pkt.Type {
+	case pbagent.MySQLConnectionWrite, pbagent.MSSQLConnectionWrite, pbagent.MongoDBConnectionWrite:
+	default:
+		return decodeStatements(pkt)
+	}
+	ss := r.session(pctx.SID)
+	ss.mu.Lock()
+	raw, msgs, err := ss.connection(string(pkt.Spec[pb.SpecClientConnectionID])).next(pkt.Type, pkt.Payload)
+	ss.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	pkt.Payload = raw
+	var stmts []statement
+	for _, msg := range msgs {
+		msgStmts, err := decodeStatements(&pb.Packet{Type: pkt.Type, Payload: msg})
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, msgStmts...)
+	}
+	return stmts, nil
+}
+
+func decodeStatements(pkt *pb.Packet) ([]statement, error) {
+	switch pkt.Type {
+	case pbagent.PGConnectionWrite:
+		if len(pkt.Payload) == 0 {
+			return nil, nil
+		}
+		switch pgtypes.PacketType(pkt.Payload[0]) {
+		case pgtypes.ClientSimpleQuery:
+			_, queryBytes, err := pgtypes.SimpleQueryContent(pkt.Payload)
+			if err != nil {
+				return nil, err
+			}
+			return parseSQL(string(queryBytes), dialectPostgres), nil
+		case pgtypes.ClientParse:
+			pgPkt, err := pgtypes.Decode(bytes.NewReader(pkt.Payload))
+			if err != nil {
+				return nil, err
+			}
+			msg, err := pgtypes.DecodeParse(pgPkt.Frame())
+			if err != nil {
+				return nil, err
+			}
+			return parseSQL(msg.Query, dialectPostgres), nil
+		}
+	case pbagent.MySQLConnectionWrite:
+		decodeFn := mysqltypes.DecodeQuery
+		if len(pkt.Payload) > 4 && mysqltypes.PacketType(pkt.Payload[4]) == mysqltypes.ComStmtPrepare {
+			decodeFn = mysqltypes.DecodeStmtPrepare
+		}
+		queryBytes, err := decodeFn(pkt.Payload)
+		if err != nil || queryBytes == nil {
+			return nil, err
+		}
+		return parseSQL(string(queryBytes), dialectMySQL), nil
+	case pbagent.MSSQLConnectionWrite:
+		if len(pkt.Payload) == 0 {
+			return nil, nil
+		}
+		var query string
+		var err error
+		switch mssqltypes.PacketType(pkt.Payload[0]) {
+		case mssqltypes.PacketSQLBatchType:
+			query, err = mssqltypes.DecodeSQLBatchToRawQuery(pkt.Payload)
+		case mssqltypes.PacketRPCRequestType:
+			query, err = mssqltypes.DecodeRpcRequestToRawQuery(pkt.Payload)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return parseSQL(query, dialectMSSQL), nil
+	case pbagent.MongoDBConnectionWrite:
+		mongoPkt, err := mongotypes.Decode(bytes.NewReader(pkt.Payload))
+		if err != nil {
+			return nil, err
+		}
+		command, err := mongotypes.DecodeCommandToJSON(mongoPkt)
+		if err != nil || command == nil {
+			return nil, err
+		}
+		stmt, err := parseMongoCommand(command)
+		if err != nil {
+			return nil, err
+		}
+		return []statement{*stmt}, nil
+	case pbagent.RedisConnectionWrite:
+		commands, err := redistypes.DecodeCommands(pkt.Payload)
+		if err != nil {
+			return nil, err
+		}
+		var stmts []statement
+		for _, cmd := range commands {
+			stmts = append(stmts, parseRedisCommand(cmd))
+		}
+		return stmts, nil
+	}
 	return nil, nil
 }
-func (r *plugin) OnDisconnect(_ plugintypes.Context, _ error) error { return nil }
-func (r *plugin) OnShutdown()                                       {}
+
+// newDeniedPacket returns the packet to be sent to the client containing an error
+// response encoded with the protocol of the received packet. The denied packets
+// of postgres connections are answered by onPGClientPacket.
+func newDeniedPacket(pkt *pb.Packet, errMsg string) (*pb.Packet, error) {
+	var pktType string
+	var payload []byte
+	switch pkt.Type {
+	case pbagent.MySQLConnectionWrite:
+		pktType = pbclient.MySQLConnectionWrite
+		// the response sequence must follow the command sequence
+		var seq uint8 = 1
+		if len(pkt.Payload) > 3 {
+			seq = pkt.Payload[3] + 1
+		}
+		payload = mysqltypes.NewErrPacket(seq, mysqltypes.ErrSpecificAccessDeniedCode, "42000", errMsg).Encode()
+	case pbagent.MSSQLConnectionWrite:
+		pktType = pbclient.MSSQLConnectionWrite
+		// class 14 indicates a security related error (permission denied)
+		payload = mssqltypes.NewErrorResponse(mssqltypes.ErrPermissionDeniedNumber, 14, errMsg).Encode()
+	case pbagent.MongoDBConnectionWrite:
+		pktType = pbclient.MongoDBConnectionWrite
+		mongoPkt, err := mongotypes.Decode(bytes.NewReader(pkt.Payload))
+		if err != nil {
+			return nil, err
+		}
+		mongoPkt, err = mongotypes.Decompress(mongoPkt)
+		if err != nil {
+			return nil, err
+		}
+		newError := mongotypes.NewOpMsgError
+		// legacy queries expects an OP_REPLY response
+		if mongoPkt.OpCode == mongotypes.OpQueryType {
+			newError = mongotypes.NewOpReplyError
+		}
+		replyPkt, err := newError(mongoPkt.RequestID,
+			mongotypes.ErrUnauthorizedCode, mongotypes.ErrUnauthorizedCodeName, errMsg)
+		if err != nil {
+			return nil, err
+		}
+		payload = replyPkt.Encode()
+	case pbagent.RedisConnectionWrite:
+		pktType = pbclient.RedisConnectionWrite
+		payload = redistypes.NewError("NOPERM " + errMsg)
+	default:
+		return nil, fmt.Errorf("unsupported packet type %v", pkt.Type)
+	}
+	return newClientPacket(pktType, pkt, payload), nil
+}
+
+// dropPacket returns a response that prevents the packet from being sent to the agent,
+// the packet is marked to indicate to other plugins (audit) that it was dropped.
+func dropPacket(pkt *pb.Packet) *plugintypes.ConnectResponse {
+	pkt.Spec[pb.SpecPluginAccessControlDropKey] = []byte("true")
+	return &plugintypes.ConnectResponse{Context: nil, Discard: true}
+}
+
+func newClientPacket(pktType string, pkt *pb.Packet, payload []byte) *pb.Packet {
+	return &pb.Packet{
+		Type:    pktType,
+		Payload: payload,
+		Spec: map[string][]byte{
+			pb.SpecGatewaySessionID:   pkt.Spec[pb.SpecGatewaySessionID],
+			pb.SpecClientConnectionID: pkt.Spec[pb.SpecClientConnectionID],
+		},
+	}
+}
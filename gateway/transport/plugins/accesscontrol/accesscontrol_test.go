@@ -0,0 +1,713 @@
+package accesscontrol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"slices"
+	"testing"
+
+	"github.com/hoophq/hoop/common/mssqltypes"
+	"github.com/hoophq/hoop/common/mysqltypes"
+	"github.com/hoophq/hoop/common/pgtypes"
+	pb "github.com/hoophq/hoop/common/proto"
+	pbagent "github.com/hoophq/hoop/common/proto/agent"
+	pbclient "github.com/hoophq/hoop/common/proto/client"
+	"github.com/hoophq/hoop/common/redistypes"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRules(t *testing.T) {
+	for _, tt := range []struct {
+		msg     string
+		config  []string
+		want    []rule
+		wantErr bool
+	}{
+		{
+			msg:    "it should ignore groups and parse rules",
+			config: []string{"sre", "rule:deny-statement:drop", "rule:deny-no-where:update", "rule:deny-schema-write:billing"},
+			want: []rule{
+				{typ: ruleDenyStatement, val: "DROP"},
+				{typ: ruleDenyNoWhere, val: "UPDATE"},
+				{typ: ruleDenySchemaWrite, val: "billing"},
+			},
+		},
+		{
+			msg:     "it should fail with unknown rule types",
+			config:  []string{"rule:allow-statement:SELECT"},
+			wantErr: true,
+		},
+		{
+			msg:     "it should fail when deny-no-where is not an update or delete",
+			config:  []string{"rule:deny-no-where:INSERT"},
+			wantErr: true,
+		},
+		{
+			msg:     "it should fail when the rule has no value",
+			config:  []string{"rule:deny-statement"},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := parseRules(tt.config)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGroups(t *testing.T) {
+	config := []string{"sre", "rule:deny-statement:DROP", "dba"}
+	assert.Equal(t, []string{"sre", "dba"}, Groups(config))
+	assert.Equal(t, []string{"sre", "rule:deny-statement:DROP", "dba"}, config, "the configuration must not be changed")
+	assert.NoError(t, ValidateRules(config))
+	assert.ErrorContains(t, ValidateRules([]string{"sre", "rule:deny-everything:DROP"}), "unknown rule type")
+}
+
+func TestParseSQL(t *testing.T) {
+	for _, tt := range []struct {
+		msg     string
+		query   string
+		dialect sqlDialect
+		want    []statement
+	}{
+		{
+			msg:   "it should parse multiple statements",
+			query: "select 1; DROP TABLE billing.invoices;",
+			want: []statement{
+				{verb: "SELECT"},
+				{verb: "DROP", schema: "BILLING", write: true},
+			},
+		},
+		{
+			msg:   "it should ignore keywords in comments and literals",
+			query: "-- drop table\n/* truncate */ SELECT 'DROP TABLE x; DELETE FROM y' FROM t WHERE a = 'it''s'",
+			want:  []statement{{verb: "SELECT", hasWhere: true}},
+		},
+		{
+			msg:   "it should not consider where clauses inside subqueries",
+			query: `UPDATE "Billing"."users" SET a = (SELECT b FROM c WHERE c.id = 1)`,
+			want:  []statement{{verb: "UPDATE", schema: "Billing", write: true}},
+		},
+		{
+			msg:   "it should find the main verb of common table expressions",
+			query: "WITH x AS (SELECT id FROM t) DELETE FROM app.t USING x WHERE t.id = x.id",
+			want:  []statement{{verb: "DELETE", schema: "APP", write: true, hasWhere: true}},
+		},
+		{
+			msg:   "it should parse the schema of insert and ddl statements",
+			query: "INSERT INTO `crm`.`leads` VALUES (1); CREATE TABLE IF NOT EXISTS [dbo].[t] (id int); DROP SCHEMA audit CASCADE",
+			want: []statement{
+				{verb: "INSERT", schema: "crm", write: true},
+				{verb: "CREATE", schema: "dbo", write: true},
+				{verb: "DROP", schema: "AUDIT", write: true},
+			},
+		},
+		{
+			msg:   "it should skip dollar quoted strings",
+			query: "SELECT $body$ DROP TABLE x; $body$; TRUNCATE ONLY public.t",
+			want: []statement{
+				{verb: "SELECT"},
+				{verb: "TRUNCATE", schema: "PUBLIC", write: true},
+			},
+		},
+		{
+			msg:   "it should skip the keywords before the object of delete and insert statements",
+			query: "DELETE FROM ONLY billing.invoices; MERGE INTO ONLY crm.leads USING x ON true WHEN MATCHED THEN DELETE",
+			want: []statement{
+				{verb: "DELETE", schema: "BILLING", write: true},
+				{verb: "MERGE", schema: "CRM", write: true},
+			},
+		},
+		{
+			msg:   "it should parse copy from statements as writes",
+			query: "COPY billing.invoices (id, total) FROM STDIN; COPY billing.invoices TO STDOUT; COPY (SELECT 1) TO STDOUT",
+			want: []statement{
+				{verb: "COPY", schema: "BILLING", write: true},
+				{verb: "COPY"},
+				{verb: "COPY"},
+			},
+		},
+		{
+			msg:   "it should parse the data modifying statements of common table expressions",
+			query: "WITH x AS (DELETE FROM billing.t RETURNING *), y AS (SELECT 1), z AS (WITH w AS (UPDATE u SET a = 1 WHERE id = 1 RETURNING *) SELECT 1) SELECT * FROM x",
+			want: []statement{
+				{verb: "SELECT"},
+				{verb: "DELETE", schema: "BILLING", write: true},
+				{verb: "SELECT"},
+				{verb: "UPDATE", write: true, hasWhere: true},
+			},
+		},
+		{
+			msg:   "it should parse the statements that run other statements as scripts",
+			query: "DO $$ BEGIN DELETE FROM t; END $$; PREPARE p AS DELETE FROM t; EXECUTE p; CALL cleanup(); EXPLAIN ANALYZE DELETE FROM t; EXPLAIN DELETE FROM t",
+			want: []statement{
+				{verb: "DO", script: true},
+				{verb: "PREPARE", script: true},
+				{verb: "EXECUTE", script: true},
+				{verb: "CALL", script: true},
+				{verb: "EXPLAIN", script: true},
+				{verb: "EXPLAIN"},
+			},
+		},
+		{
+			msg:   "it should parse copy from program statements as scripts",
+			query: "COPY t FROM PROGRAM 'psql -c \"DROP TABLE t\"'",
+			want:  []statement{{verb: "COPY", write: true, script: true}},
+		},
+		{
+			msg:   "it should not escape quotes with backslashes in postgres standard strings",
+			query: `SELECT 'a\'; DROP TABLE t; --'`,
+			want:  []statement{{verb: "SELECT"}, {verb: "DROP", write: true}},
+		},
+		{
+			msg:   "it should escape quotes with backslashes in postgres escape strings",
+			query: `SELECT E'a\'; DROP TABLE t; --'`,
+			want:  []statement{{verb: "SELECT"}},
+		},
+		{
+			msg:     "it should not escape quotes with backslashes in mssql strings",
+			query:   `SELECT 'a\'; DROP TABLE t; --'`,
+			dialect: dialectMSSQL,
+			want:    []statement{{verb: "SELECT"}, {verb: "DROP", write: true}},
+		},
+		{
+			msg:     "it should escape quotes with backslashes in mysql strings",
+			query:   `SELECT 'a\'; DROP TABLE t; --', "b\""; DELETE FROM t`,
+			dialect: dialectMySQL,
+			want:    []statement{{verb: "SELECT"}, {verb: "DELETE", write: true}},
+		},
+		{
+			msg:     "it should parse the body of mysql executable comments",
+			query:   "SELECT 1 /*! ; DROP TABLE t */; /*!50000 TRUNCATE app.t */; /*M!100100 DELETE FROM t */ /* DROP TABLE x */",
+			dialect: dialectMySQL,
+			want: []statement{
+				{verb: "SELECT"},
+				{verb: "DROP", write: true},
+				{verb: "TRUNCATE", schema: "APP", write: true},
+				{verb: "DELETE", write: true},
+			},
+		},
+		{
+			msg:     "it should split mssql batches without semicolons",
+			query:   "SELECT 1 DROP TABLE users IF 1=1 DELETE FROM dbo.t WHERE id = 1 ELSE TRUNCATE TABLE t",
+			dialect: dialectMSSQL,
+			want: []statement{
+				{verb: "SELECT"},
+				{verb: "DROP", write: true},
+				{verb: "IF"},
+				{verb: "DELETE", schema: "DBO", write: true, hasWhere: true},
+				{verb: "ELSE"},
+				{verb: "TRUNCATE", write: true},
+			},
+		},
+		{
+			msg: "it should not split mssql statements on the keywords that are part of the statement",
+			query: "INSERT INTO dbo.t SELECT a FROM b UNION ALL SELECT a FROM c; DROP TABLE IF EXISTS app.t; " +
+				"GRANT SELECT, UPDATE ON t TO u; ALTER TABLE t ADD FOREIGN KEY (a) REFERENCES b (id) ON DELETE CASCADE; " +
+				"MERGE INTO t USING s ON t.id = s.id WHEN MATCHED THEN UPDATE SET a = 1 WHEN NOT MATCHED THEN INSERT (a) VALUES (1)",
+			dialect: dialectMSSQL,
+			want: []statement{
+				{verb: "INSERT", schema: "DBO", write: true},
+				{verb: "SELECT"},
+				{verb: "DROP", schema: "APP", write: true},
+				{verb: "GRANT"},
+				{verb: "ALTER", write: true},
+				{verb: "MERGE", write: true},
+			},
+		},
+		{
+			msg:   "it should ignore executable comments in postgres",
+			query: "SELECT 1 /*!50000 DROP TABLE t */",
+			want:  []statement{{verb: "SELECT"}},
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseSQL(tt.query, tt.dialect))
+		})
+	}
+}
+
+func TestMatchRules(t *testing.T) {
+	rules, err := parseRules([]string{"rule:deny-statement:TRUNCATE", "rule:deny-no-where:DELETE", "rule:deny-schema-write:billing"})
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		msg  string
+		stmt []statement
+		want *rule
+	}{
+		{
+			msg:  "it should allow select statements",
+			stmt: parseSQL("SELECT * FROM billing.invoices", dialectPostgres),
+		},
+		{
+			msg:  "it should deny truncate statements",
+			stmt: parseSQL("truncate table app.users", dialectPostgres),
+			want: &rule{typ: ruleDenyStatement, val: "TRUNCATE"},
+		},
+		{
+			msg:  "it should deny delete statements without where",
+			stmt: parseSQL("DELETE FROM users", dialectPostgres),
+			want: &rule{typ: ruleDenyNoWhere, val: "DELETE"},
+		},
+		{
+			msg:  "it should allow delete statements with where",
+			stmt: parseSQL("DELETE FROM users WHERE id = 1", dialectPostgres),
+		},
+		{
+			msg:  "it should deny writes to the schema",
+			stmt: parseSQL("UPDATE billing.invoices SET paid = true WHERE id = 1", dialectPostgres),
+			want: &rule{typ: ruleDenySchemaWrite, val: "billing"},
+		},
+		{
+			msg:  "it should deny copy statements to the schema",
+			stmt: parseSQL("COPY billing.invoices FROM '/tmp/invoices.csv'", dialectPostgres),
+			want: &rule{typ: ruleDenySchemaWrite, val: "billing"},
+		},
+		{
+			msg:  "it should deny writes to the schema of delete only statements",
+			stmt: parseSQL("DELETE FROM ONLY billing.invoices WHERE id = 1", dialectPostgres),
+			want: &rule{typ: ruleDenySchemaWrite, val: "billing"},
+		},
+		{
+			msg:  "it should deny mongo commands without filters",
+			stmt: mustParseMongoCommand(t, `{"delete":"users","deletes":[{"q":{},"limit":0}],"$db":"app"}`),
+			want: &rule{typ: ruleDenyNoWhere, val: "DELETE"},
+		},
+		{
+			msg:  "it should deny mongo commands when the statements are missing",
+			stmt: mustParseMongoCommand(t, `{"delete":"users","$db":"app"}`),
+			want: &rule{typ: ruleDenyNoWhere, val: "DELETE"},
+		},
+		{
+			msg:  "it should allow mongo commands with filters",
+			stmt: mustParseMongoCommand(t, `{"delete":"users","$db":"app","deletes":[{"q":{"id":1},"limit":1}]}`),
+		},
+		{
+			msg:  "it should deny mongo writes to the database",
+			stmt: mustParseMongoCommand(t, `{"insert":"invoices","documents":[{"a":1}],"$db":"billing"}`),
+			want: &rule{typ: ruleDenySchemaWrite, val: "billing"},
+		},
+		{
+			msg:  "it should deny mongo aggregations writing to the database",
+			stmt: mustParseMongoCommand(t, `{"aggregate":"invoices","pipeline":[{"$match":{}},{"$out":"copy"}],"$db":"billing"}`),
+			want: &rule{typ: ruleDenySchemaWrite, val: "billing"},
+		},
+		{
+			msg:  "it should deny mongo aggregations merging into another database",
+			stmt: mustParseMongoCommand(t, `{"aggregate":"users","pipeline":[{"$merge":{"into":{"db":"billing","coll":"x"}}}],"$db":"app"}`),
+			want: &rule{typ: ruleDenySchemaWrite, val: "billing"},
+		},
+		{
+			msg:  "it should allow mongo aggregations without write stages",
+			stmt: mustParseMongoCommand(t, `{"aggregate":"invoices","pipeline":[{"$match":{}}],"$db":"billing"}`),
+		},
+		{
+			msg:  "it should deny mssql statements of batches without semicolons",
+			stmt: parseSQL("IF 1=1 TRUNCATE TABLE users", dialectMSSQL),
+			want: &rule{typ: ruleDenyStatement, val: "TRUNCATE"},
+		},
+		{
+			msg:  "it should deny data modifying statements of common table expressions",
+			stmt: parseSQL("WITH x AS (DELETE FROM users RETURNING *) SELECT 1", dialectPostgres),
+			want: &rule{typ: ruleDenyNoWhere, val: "DELETE"},
+		},
+		{
+			msg:  "it should deny writes to the schema of common table expressions",
+			stmt: parseSQL("WITH x AS (INSERT INTO billing.invoices VALUES (1) RETURNING *) SELECT * FROM x", dialectPostgres),
+			want: &rule{typ: ruleDenySchemaWrite, val: "billing"},
+		},
+		{
+			msg:  "it should deny explain analyze statements, they execute the statement",
+			stmt: parseSQL("EXPLAIN (ANALYZE, BUFFERS) DELETE FROM users", dialectPostgres),
+			want: &rule{typ: ruleDenyStatement, val: "TRUNCATE"},
+		},
+		{
+			msg:  "it should allow explain statements",
+			stmt: parseSQL("EXPLAIN DELETE FROM users", dialectPostgres),
+		},
+		{
+			msg:  "it should deny do blocks",
+			stmt: parseSQL("DO $$ BEGIN DELETE FROM users; END $$", dialectPostgres),
+			want: &rule{typ: ruleDenyStatement, val: "TRUNCATE"},
+		},
+		{
+			msg:  "it should deny prepared statements",
+			stmt: parseSQL("PREPARE p AS DELETE FROM users; EXECUTE p", dialectPostgres),
+			want: &rule{typ: ruleDenyStatement, val: "TRUNCATE"},
+		},
+		{
+			msg:  "it should deny executing prepared statements",
+			stmt: parseSQL("EXECUTE p", dialectPostgres),
+			want: &rule{typ: ruleDenyStatement, val: "TRUNCATE"},
+		},
+		{
+			msg:  "it should deny calling procedures",
+			stmt: parseSQL("CALL app.cleanup()", dialectPostgres),
+			want: &rule{typ: ruleDenyStatement, val: "TRUNCATE"},
+		},
+		{
+			msg:  "it should deny copy from program statements",
+			stmt: parseSQL("COPY users FROM PROGRAM 'curl http://example.com/users.csv'", dialectPostgres),
+			want: &rule{typ: ruleDenyStatement, val: "TRUNCATE"},
+		},
+		{
+			msg:  "it should deny statements after a postgres string ending with a backslash",
+			stmt: parseSQL(`SELECT 'a\'; TRUNCATE TABLE t; --'`, dialectPostgres),
+			want: &rule{typ: ruleDenyStatement, val: "TRUNCATE"},
+		},
+		{
+			msg:  "it should deny statements in mysql executable comments",
+			stmt: parseSQL("/*!50000 TRUNCATE TABLE t */", dialectMySQL),
+			want: &rule{typ: ruleDenyStatement, val: "TRUNCATE"},
+		},
+		{
+			msg:  "it should deny mongo find and modify commands that remove documents without filters",
+			stmt: mustParseMongoCommand(t, `{"findAndModify":"users","query":{},"remove":true,"$db":"app"}`),
+			want: &rule{typ: ruleDenyNoWhere, val: "DELETE"},
+		},
+		{
+			msg:  "it should allow mongo find and modify commands that remove documents with filters",
+			stmt: mustParseMongoCommand(t, `{"findAndModify":"users","query":{"id":1},"remove":true,"$db":"app"}`),
+		},
+		{
+			msg:  "it should allow mongo reads",
+			stmt: mustParseMongoCommand(t, `{"find":"invoices","filter":{},"$db":"billing"}`),
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchRules(rules, tt.stmt))
+		})
+	}
+}
+
+func TestMatchRedisRules(t *testing.T) {
+	rules, err := parseRules([]string{"rule:deny-statement:flushall", "rule:deny-statement:CONFIG"})
+	assert.NoError(t, err)
+	for _, tt := range []struct {
+		msg  string
+		cmd  *redistypes.Command
+		want *rule
+	}{
+		{
+			msg:  "it should deny commands of the deny list",
+			cmd:  redistypes.NewCommand("flushall", "async"),
+			want: &rule{typ: ruleDenyStatement, val: "FLUSHALL"},
+		},
+		{
+			msg:  "it should deny commands with subcommands",
+			cmd:  redistypes.NewCommand("CONFIG", "SET", "requirepass", "x"),
+			want: &rule{typ: ruleDenyStatement, val: "CONFIG"},
+		},
+		{
+			msg:  "it should deny scripts, they could run the denied commands",
+			cmd:  redistypes.NewCommand("EVAL", "return redis.call('FLUSHALL')", "0"),
+			want: &rule{typ: ruleDenyStatement, val: "FLUSHALL"},
+		},
+		{
+			msg: "it should allow other commands",
+			cmd: redistypes.NewCommand("DEL", "users"),
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchRules(rules, []statement{parseRedisCommand(tt.cmd)}))
+		})
+	}
+}
+
+func mustParseMongoCommand(t *testing.T, command string) []statement {
+	stmt, err := parseMongoCommand([]byte(command))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []statement{*stmt}
+}
+
+func TestDecodeStatements(t *testing.T) {
+	// Parse: statement name, query and the number of parameter types
+	parseFrame := append([]byte("stmt1\x00DELETE FROM users\x00"), 0x00, 0x00)
+	// sp_executesql rpc request with the statement SELECT TOP 0 1 AS "_" FROM "dbo"."ErrorLog" WHERE 1 <> 1
+	rpcRequest, _ := hex.DecodeString("030100a20035010016000000120000000200000000000000000001000000ffff0a0000000000e7401f0904d000347200530045004c00450043005400200054004f0050002000300020003100200041005300200022005f0022002000460052004f004d0020002200640062006f0022002e0022004500720072006f0072004c006f00670022002000570048004500520045002000310020003c003e00200031002000")
+	for _, tt := range []struct {
+		msg     string
+		pkt     *pb.Packet
+		want    []statement
+		wantErr bool
+	}{
+		{
+			msg:  "it should decode postgres simple queries",
+			pkt:  &pb.Packet{Type: pbagent.PGConnectionWrite, Payload: pgtypes.NewPacket(pgtypes.ClientSimpleQuery, []byte("DROP TABLE users\x00")).Encode()},
+			want: []statement{{verb: "DROP", write: true}},
+		},
+		{
+			msg:  "it should decode the query of postgres parse packets",
+			pkt:  &pb.Packet{Type: pbagent.PGConnectionWrite, Payload: pgtypes.NewPacket(pgtypes.ClientParse, parseFrame).Encode()},
+			want: []statement{{verb: "DELETE", write: true}},
+		},
+		{
+			msg: "it should ignore other postgres packets",
+			pkt: &pb.Packet{Type: pbagent.PGConnectionWrite, Payload: pgtypes.NewPacket(pgtypes.ClientSync, nil).Encode()},
+		},
+		{
+			msg:  "it should decode the statement of mysql prepare packets",
+			pkt:  &pb.Packet{Type: pbagent.MySQLConnectionWrite, Payload: mysqltypes.NewPacket(0, append([]byte{mysqltypes.ComStmtPrepare.Byte()}, "TRUNCATE t"...)).Encode()},
+			want: []statement{{verb: "TRUNCATE", write: true}},
+		},
+		{
+			msg:  "it should decode the statement of mssql rpc requests",
+			pkt:  &pb.Packet{Type: pbagent.MSSQLConnectionWrite, Payload: rpcRequest},
+			want: []statement{{verb: "SELECT", hasWhere: true}},
+		},
+		{
+			msg: "it should decode all the commands of a redis pipeline",
+			pkt: &pb.Packet{Type: pbagent.RedisConnectionWrite, Payload: append(
+				redistypes.NewCommand("GET", "a").Encode(), redistypes.NewCommand("FLUSHALL").Encode()...)},
+			want: []statement{{verb: "GET", hasWhere: true}, {verb: "FLUSHALL", write: true, hasWhere: true}},
+		},
+		{
+			msg:     "it should return an error when a postgres parse packet is malformed",
+			pkt:     &pb.Packet{Type: pbagent.PGConnectionWrite, Payload: pgtypes.NewPacket(pgtypes.ClientParse, []byte("stmt1")).Encode()},
+			wantErr: true,
+		},
+		{
+			msg:     "it should return an error when a mysql query packet is too short",
+			pkt:     &pb.Packet{Type: pbagent.MySQLConnectionWrite, Payload: mysqltypes.NewPacket(0, []byte{mysqltypes.ComQuery.Byte()}).Encode()},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := decodeStatements(tt.pkt)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOnReceiveDenyDecodeError(t *testing.T) {
+	pctx := plugintypes.Context{SID: "sid", PluginConnectionConfig: []string{"rule:deny-statement:DROP"}}
+	pkt := &pb.Packet{
+		Type:    pbagent.PGConnectionWrite,
+		Payload: pgtypes.NewPacket(pgtypes.ClientParse, []byte("stmt1")).Encode(),
+		Spec:    map[string][]byte{pb.SpecGatewaySessionID: []byte("sid")},
+	}
+	resp, err := New().OnReceive(pctx, pkt)
+	assert.NoError(t, err)
+	// the error is sent to the client when the extended query is synced
+	if assert.NotNil(t, resp) {
+		assert.True(t, resp.Discard)
+	}
+	assert.Contains(t, string(pkt.Spec[pb.SpecPluginAccessControlDeniedKey]), "unable to decode")
+}
+
+func newMySQLQuery(query string) []byte {
+	return mysqltypes.NewPacket(0, append([]byte{mysqltypes.ComQuery.Byte()}, query...)).Encode()
+}
+
+// newSQLBatchPackets returns the tds packets of a sql batch message split in frames of size bytes
+func newSQLBatchPackets(query string, size int) [][]byte {
+	frame := binary.LittleEndian.AppendUint32(nil, 4)
+	for _, r := range query {
+		frame = binary.LittleEndian.AppendUint16(frame, uint16(r))
+	}
+	var packets [][]byte
+	for id := 1; len(frame) > 0; id++ {
+		n := min(size, len(frame))
+		header := []byte{byte(mssqltypes.PacketSQLBatchType), 0x00, 0, 0, 0, 0, byte(id), 0}
+		binary.BigEndian.PutUint16(header[2:4], uint16(n+8))
+		if n == len(frame) {
+			header[1] = 0x01
+		}
+		packets = append(packets, append(header, frame[:n]...))
+		frame = frame[n:]
+	}
+	return packets
+}
+
+func TestOnReceiveReassemblePackets(t *testing.T) {
+	dropQuery := newMySQLQuery("DROP TABLE users")
+	for _, tt := range []struct {
+		msg         string
+		pktType     string
+		payloads    [][]byte
+		wantPayload [][]byte
+		wantDropped int
+		wantDenied  bool
+	}{
+		{
+			msg:         "it should hold a mysql query split in many packets and deny it",
+			pktType:     pbagent.MySQLConnectionWrite,
+			payloads:    [][]byte{dropQuery[:3], dropQuery[3:10], dropQuery[10:]},
+			wantPayload: [][]byte{},
+			wantDropped: 2,
+			wantDenied:  true,
+		},
+		{
+			msg:         "it should deny mysql queries sent in the same packet",
+			pktType:     pbagent.MySQLConnectionWrite,
+			payloads:    [][]byte{append(newMySQLQuery("SELECT 1"), dropQuery...)},
+			wantPayload: [][]byte{},
+			wantDenied:  true,
+		},
+		{
+			msg:         "it should forward the complete mysql queries and hold the incomplete ones",
+			pktType:     pbagent.MySQLConnectionWrite,
+			payloads:    [][]byte{append(newMySQLQuery("SELECT 1"), dropQuery[:5]...)},
+			wantPayload: [][]byte{newMySQLQuery("SELECT 1")},
+		},
+		{
+			msg:         "it should deny a mssql batch that spans many tds packets",
+			pktType:     pbagent.MSSQLConnectionWrite,
+			payloads:    newSQLBatchPackets("SELECT 1; DROP TABLE users", 16),
+			wantPayload: [][]byte{},
+			wantDropped: 3,
+			wantDenied:  true,
+		},
+		{
+			msg:         "it should forward the tds packets of a mssql batch when it's complete",
+			pktType:     pbagent.MSSQLConnectionWrite,
+			payloads:    newSQLBatchPackets("SELECT * FROM users", 16),
+			wantPayload: [][]byte{bytes.Join(newSQLBatchPackets("SELECT * FROM users", 16), nil)},
+			wantDropped: 2,
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			p := New()
+			pctx := plugintypes.Context{SID: "sid", PluginConnectionConfig: []string{"rule:deny-statement:DROP"}}
+			var resp *plugintypes.ConnectResponse
+			var gotPayloads [][]byte
+			var gotDropped int
+			for _, payload := range tt.payloads {
+				pkt := &pb.Packet{Type: tt.pktType, Payload: payload, Spec: map[string][]byte{
+					pb.SpecGatewaySessionID: []byte("sid"), pb.SpecClientConnectionID: []byte("1")}}
+				var err error
+				resp, err = p.OnReceive(pctx, pkt)
+				assert.NoError(t, err)
+				switch {
+				case resp == nil:
+					gotPayloads = append(gotPayloads, pkt.Payload)
+				case resp.Discard:
+					assert.Contains(t, pkt.Spec, pb.SpecPluginAccessControlDropKey)
+					gotDropped++
+				}
+			}
+			assert.Equal(t, tt.wantDropped, gotDropped)
+			if tt.wantDenied {
+				if assert.NotNil(t, resp) {
+					assert.NotNil(t, resp.ClientPacket)
+				}
+			}
+			assert.Equal(t, len(tt.wantPayload), len(gotPayloads))
+			for i := range min(len(tt.wantPayload), len(gotPayloads)) {
+				assert.Equal(t, hex.EncodeToString(tt.wantPayload[i]), hex.EncodeToString(gotPayloads[i]))
+			}
+		})
+	}
+}
+
+func TestOnReceivePGExtendedQuery(t *testing.T) {
+	newParse := func(query string) []byte {
+		return pgtypes.NewPacket(pgtypes.ClientParse, append([]byte("\x00"+query+"\x00"), 0x00, 0x00)).Encode()
+	}
+	bind := pgtypes.NewPacket(pgtypes.ClientBind, []byte("\x00\x00\x00\x00\x00\x00\x00\x00")).Encode()
+	execute := pgtypes.NewPacket(pgtypes.ClientExecute, []byte("\x00\x00\x00\x00\x00")).Encode()
+	sync := pgtypes.NewPacket(pgtypes.ClientSync, nil).Encode()
+	errMsg := `statement blocked by access control rule "deny-statement:DROP"`
+	errResponse := pgtypes.NewErrorResponse(pgtypes.ErrCodeInsufficientPrivilege, "%s", errMsg).Encode()
+	parseBindComplete := append(pgtypes.NewPacket('1', nil).Encode(), pgtypes.NewPacket('2', nil).Encode()...)
+
+	type step struct {
+		pktType string
+		payload []byte
+		// wantPayload is the payload sent to the destination of the packet,
+		// wantReply is the payload sent back to the client instead of it
+		wantPayload []byte
+		wantReply   []byte
+		wantDrop    bool
+	}
+	for _, tt := range []struct {
+		msg   string
+		steps []step
+	}{
+		{
+			msg: "it should drop the messages of a denied parse and reply the sync with the transaction status",
+			steps: []step{
+				{pktType: pbagent.PGConnectionWrite, payload: pgtypes.NewPacket(pgtypes.ClientSimpleQuery, []byte("BEGIN\x00")).Encode(),
+					wantPayload: pgtypes.NewPacket(pgtypes.ClientSimpleQuery, []byte("BEGIN\x00")).Encode()},
+				{pktType: pbclient.PGConnectionWrite, payload: pgtypes.NewReadyForQuery(pgtypes.ServerTransactionBlock).Encode(),
+					wantPayload: pgtypes.NewReadyForQuery(pgtypes.ServerTransactionBlock).Encode()},
+				{pktType: pbagent.PGConnectionWrite, payload: newParse("DROP TABLE users"), wantDrop: true},
+				{pktType: pbagent.PGConnectionWrite, payload: bind, wantDrop: true},
+				{pktType: pbagent.PGConnectionWrite, payload: execute, wantDrop: true},
+				{pktType: pbagent.PGConnectionWrite, payload: sync,
+					wantReply: append(slices.Clone(errResponse), pgtypes.NewReadyForQuery(pgtypes.ServerTransactionBlock).Encode()...)},
+				{pktType: pbagent.PGConnectionWrite, payload: newParse("SELECT 1"), wantPayload: newParse("SELECT 1")},
+			},
+		},
+		{
+			msg: "it should send the error before the ready for query of the messages sent to the server",
+			steps: []step{
+				{pktType: pbagent.PGConnectionWrite, payload: newParse("SELECT 1"), wantPayload: newParse("SELECT 1")},
+				{pktType: pbagent.PGConnectionWrite, payload: bind, wantPayload: bind},
+				{pktType: pbagent.PGConnectionWrite, payload: newParse("DROP TABLE users"), wantDrop: true},
+				{pktType: pbagent.PGConnectionWrite, payload: execute, wantDrop: true},
+				{pktType: pbagent.PGConnectionWrite, payload: sync, wantPayload: sync},
+				{pktType: pbclient.PGConnectionWrite, payload: parseBindComplete, wantPayload: parseBindComplete},
+				{pktType: pbclient.PGConnectionWrite, payload: pgtypes.NewReadyForQuery(pgtypes.ServerIdle).Encode(),
+					wantPayload: append(slices.Clone(errResponse), pgtypes.NewReadyForQuery(pgtypes.ServerIdle).Encode()...)},
+			},
+		},
+		{
+			msg: "it should reply denied simple queries with the transaction status",
+			steps: []step{
+				{pktType: pbagent.PGConnectionWrite, payload: pgtypes.NewPacket(pgtypes.ClientSimpleQuery, []byte("BEGIN\x00")).Encode(),
+					wantPayload: pgtypes.NewPacket(pgtypes.ClientSimpleQuery, []byte("BEGIN\x00")).Encode()},
+				// the ready for query message split in two packets
+				{pktType: pbclient.PGConnectionWrite, payload: pgtypes.NewReadyForQuery(pgtypes.ServerTransactionBlock).Encode()[:3],
+					wantPayload: pgtypes.NewReadyForQuery(pgtypes.ServerTransactionBlock).Encode()[:3]},
+				{pktType: pbclient.PGConnectionWrite, payload: pgtypes.NewReadyForQuery(pgtypes.ServerTransactionBlock).Encode()[3:],
+					wantPayload: pgtypes.NewReadyForQuery(pgtypes.ServerTransactionBlock).Encode()[3:]},
+				{pktType: pbagent.PGConnectionWrite, payload: pgtypes.NewPacket(pgtypes.ClientSimpleQuery, []byte("DROP TABLE users\x00")).Encode(),
+					wantReply: append(slices.Clone(errResponse), pgtypes.NewReadyForQuery(pgtypes.ServerTransactionBlock).Encode()...)},
+			},
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			p := New()
+			pctx := plugintypes.Context{SID: "sid", PluginConnectionConfig: []string{"rule:deny-statement:DROP"}}
+			for i, s := range tt.steps {
+				pkt := &pb.Packet{Type: s.pktType, Payload: s.payload, Spec: map[string][]byte{
+					pb.SpecGatewaySessionID: []byte("sid"), pb.SpecClientConnectionID: []byte("1")}}
+				resp, err := p.OnReceive(pctx, pkt)
+				assert.NoError(t, err)
+				if s.wantDrop {
+					if assert.NotNil(t, resp, "step %v", i) {
+						assert.True(t, resp.Discard, "step %v", i)
+						assert.Nil(t, resp.ClientPacket, "step %v", i)
+					}
+					assert.Contains(t, pkt.Spec, pb.SpecPluginAccessControlDropKey, "step %v", i)
+					continue
+				}
+				if s.wantReply == nil {
+					assert.Nil(t, resp, "step %v", i)
+					assert.Equal(t, hex.EncodeToString(s.wantPayload), hex.EncodeToString(pkt.Payload), "step %v", i)
+					continue
+				}
+				if assert.NotNil(t, resp, "step %v", i) && assert.NotNil(t, resp.ClientPacket, "step %v", i) {
+					assert.Equal(t, pbclient.PGConnectionWrite, resp.ClientPacket.Type)
+					assert.Equal(t, hex.EncodeToString(s.wantReply), hex.EncodeToString(resp.ClientPacket.Payload), "step %v", i)
+				}
+			}
+		})
+	}
+}
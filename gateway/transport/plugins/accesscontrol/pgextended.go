@@ -0,0 +1,122 @@
+package accesscontrol
+
+import (
+	"encoding/binary"
+
+	"github.com/hoophq/hoop/common/pgtypes"
+)
+
+const pgServerReadyForQuery byte = 'Z'
+
+// pgState tracks the messages of a postgres connection. When a message of an extended query
+// is denied, the server must not receive the remaining messages of the query, thus they are
+// discarded until the Sync message, which is answered with the transaction status of the server.
+type pgState struct {
+	// started is true after the first query of the client,
+	// the server stream is aligned with the protocol messages from that point
+	started bool
+	// txStatus is the transaction status of the last ReadyForQuery of the server
+	txStatus byte
+	// the header of the server message being read and the size of its body left to read
+	header  []byte
+	msgType byte
+	bodyLen int
+
+	// denied is the reason of the denied extended query, the
+	// messages of the client are discarded until the next Sync
+	denied string
+	// forwarded indicates the server received messages since the last Sync
+	forwarded bool
+	// pendingErr is sent to the client before the next ReadyForQuery of the server
+	pendingErr []byte
+}
+
+func (s *pgState) status() byte {
+	if s.txStatus == 0 {
+		return pgtypes.ServerIdle
+	}
+	return s.txStatus
+}
+
+// onServerPacket reads the transaction status of the ReadyForQuery messages sent by the
+// server, the pending error is placed before the next ReadyForQuery of the payload.
+func (s *pgState) onServerPacket(payload []byte) []byte {
+	if !s.started {
+		return payload
+	}
+	var out []byte
+	last := 0
+	for i := 0; i < len(payload); {
+		if s.bodyLen > 0 {
+			n := min(s.bodyLen, len(payload)-i)
+			if s.msgType == pgServerReadyForQuery {
+				s.txStatus = payload[i]
+			}
+			s.bodyLen -= n
+			i += n
+			continue
+		}
+		if len(s.header) == 0 && payload[i] == pgServerReadyForQuery && s.pendingErr != nil {
+			out = append(out, payload[last:i]...)
+			out = append(out, s.pendingErr...)
+			s.pendingErr = nil
+			last = i
+		}
+		n := min(5-len(s.header), len(payload)-i)
+		s.header = append(s.header, payload[i:i+n]...)
+		i += n
+		if len(s.header) == 5 {
+			// type(1), length(4) - the length includes itself
+			s.msgType = s.header[0]
+			s.bodyLen = max(int(binary.BigEndian.Uint32(s.header[1:]))-4, 0)
+			s.header = s.header[:0]
+		}
+	}
+	if out == nil {
+		return payload
+	}
+	return append(out, payload[last:]...)
+}
+
+// onClientPacket returns the payload to send to the client instead of sending the message to
+// the server, errMsg is the reason when the message is denied. It returns a nil payload when the
+// message must be sent to the server and an empty payload when the message must be dropped.
+func (s *pgState) onClientPacket(payload []byte, errMsg string) []byte {
+	if len(payload) == 0 {
+		return nil
+	}
+	typ := pgtypes.PacketType(payload[0])
+	switch typ {
+	case pgtypes.ClientSimpleQuery, pgtypes.ClientParse:
+		s.started = true
+	}
+	switch {
+	case s.denied != "" && typ != pgtypes.ClientSync:
+		return []byte{}
+	case s.denied != "":
+		errResponse := pgtypes.NewErrorResponse(pgtypes.ErrCodeInsufficientPrivilege, "%s", s.denied).Encode()
+		s.denied = ""
+		if s.forwarded {
+			// the server has the responses of the messages before the denied one,
+			// the error is sent to the client before the ReadyForQuery of the server
+			s.pendingErr = errResponse
+			s.forwarded = false
+			return nil
+		}
+		return append(errResponse, pgtypes.NewReadyForQuery(s.status()).Encode()...)
+	case errMsg == "":
+		switch typ {
+		case pgtypes.ClientSync:
+			s.forwarded = false
+		case pgtypes.ClientParse, pgtypes.ClientBind, pgtypes.ClientDescribe,
+			pgtypes.ClientExecute, pgtypes.ClientClose, pgtypes.ClientFlush:
+			s.forwarded = true
+		}
+		return nil
+	case typ == pgtypes.ClientSimpleQuery:
+		return append(pgtypes.NewErrorResponse(pgtypes.ErrCodeInsufficientPrivilege, "%s", errMsg).Encode(),
+			pgtypes.NewReadyForQuery(s.status()).Encode()...)
+	}
+	s.denied = errMsg
+	return []byte{}
+}
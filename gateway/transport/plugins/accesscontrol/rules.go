@@ -0,0 +1,108 @@
+package accesscontrol
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// The guardrail rules are configured alongside the groups of the plugin connection
+// configuration. A rule has the format rule:<type>:<value>, examples:
+//
+//	rule:deny-statement:DROP - deny any statement starting with DROP
+//	rule:deny-no-where:UPDATE - deny UPDATE statements without a WHERE clause
+//	rule:deny-schema-write:billing - deny writing to any object of the billing schema
+//	rule:deny-statement:FLUSHALL - deny the FLUSHALL command of redis connections
+//
+// The statements that run scripts or other statements which can't be evaluated, e.g.:
+// redis EVAL, DO blocks, PREPARE, EXECUTE, CALL or EXPLAIN ANALYZE are denied by any rule,
+// they could run the denied statements.
+const rulePrefix = "rule:"
+
+type ruleType string
+
+const (
+	ruleDenyStatement   ruleType = "deny-statement"
+	ruleDenyNoWhere     ruleType = "deny-no-where"
+	ruleDenySchemaWrite ruleType = "deny-schema-write"
+)
+
+type rule struct {
+	typ ruleType
+	val string
+}
+
+func (r rule) String() string { return fmt.Sprintf("%s:%s", r.typ, r.val) }
+
+// parseRules returns the guardrail rules from the plugin connection configuration.
+// Entries without the rule prefix are groups and are ignored.
+func parseRules(config []string) ([]rule, error) {
+	var rules []rule
+	for _, entry := range config {
+		if !strings.HasPrefix(entry, rulePrefix) {
+			continue
+		}
+		typ, val, found := strings.Cut(entry[len(rulePrefix):], ":")
+		if !found || val == "" {
+			return nil, fmt.Errorf("invalid rule format %q, expected rule:<type>:<value>", entry)
+		}
+		r := rule{typ: ruleType(typ), val: val}
+		switch r.typ {
+		case ruleDenyStatement:
+			r.val = strings.ToUpper(val)
+		case ruleDenyNoWhere:
+			r.val = strings.ToUpper(val)
+			if r.val != "UPDATE" && r.val != "DELETE" {
+				return nil, fmt.Errorf("invalid rule %q, accept only UPDATE or DELETE", entry)
+			}
+		case ruleDenySchemaWrite:
+		default:
+			return nil, fmt.Errorf("unknown rule type %q, accept only: %v", typ,
+				[]ruleType{ruleDenyStatement, ruleDenyNoWhere, ruleDenySchemaWrite})
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// ValidateRules returns an error when a rule of the plugin connection configuration is invalid
+func ValidateRules(config []string) error {
+	_, err := parseRules(config)
+	return err
+}
+
+// Groups returns the groups of the plugin connection configuration that are allowed
+// to access the connection, the rules are not groups and they are removed.
+func Groups(config []string) []string {
+	return slices.DeleteFunc(slices.Clone(config), func(entry string) bool {
+		return strings.HasPrefix(entry, rulePrefix)
+	})
+}
+
+// deny reports if the statement is denied by the rule
+func (r rule) deny(stmt statement) bool {
+	if stmt.script {
+		return true
+	}
+	switch r.typ {
+	case ruleDenyStatement:
+		return stmt.verb == r.val
+	case ruleDenyNoWhere:
+		return stmt.verb == r.val && !stmt.hasWhere
+	case ruleDenySchemaWrite:
+		return stmt.write && strings.EqualFold(stmt.schema, r.val)
+	}
+	return false
+}
+
+// matchRules returns the first rule that denies any of the statements
+func matchRules(rules []rule, stmts []statement) *rule {
+	for _, stmt := range stmts {
+		for _, r := range rules {
+			if r.deny(stmt) {
+				return &r
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,536 @@
+package accesscontrol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/hoophq/hoop/common/redistypes"
+)
+
+// statement is a normalized representation of a decoded
+// query or command that rules are evaluated against
+type statement struct {
+	// the main keyword of the statement in upper case, e.g.: SELECT, UPDATE
+	verb string
+	// the schema (or database) of the object being written,
+	// it's empty when the object is not qualified
+	schema string
+	// indicates if the statement changes data or structure
+	write bool
+	// indicates if the statement has a filter (WHERE clause)
+	hasWhere bool
+	// indicates if the statement runs a script or other statements in the server,
+	// e.g.: redis EVAL, DO blocks or prepared statements. The statements it runs
+	// are not known, thus they can't be evaluated.
+	script bool
+}
+
+var (
+	writeVerbs = []string{"INSERT", "UPDATE", "DELETE", "MERGE", "REPLACE",
+		"TRUNCATE", "DROP", "ALTER", "CREATE", "RENAME"}
+	// keywords that could appear between a ddl verb and the object name
+	objectKeywords = []string{"TABLE", "VIEW", "INDEX", "SCHEMA", "DATABASE", "IF", "EXISTS", "NOT",
+		"OR", "REPLACE", "UNIQUE", "MATERIALIZED", "TEMP", "TEMPORARY", "UNLOGGED", "SEQUENCE",
+		"FUNCTION", "PROCEDURE", "TRIGGER", "TYPE", "ONLY", "CONCURRENTLY", "EXTENSION", "ROLE",
+		"USER", "LOW_PRIORITY", "IGNORE", "QUICK"}
+	// statements that run other statements or code which are not known by the parser
+	scriptVerbs = []string{"DO", "PREPARE", "EXECUTE", "EXEC", "CALL"}
+	// verbs of the data modifying statements that could be nested in parenthesis
+	nestedVerbs = []string{"WITH", "INSERT", "UPDATE", "DELETE", "MERGE"}
+	// keywords that start a statement in a T-SQL batch
+	mssqlStatementVerbs = []string{"SELECT", "INSERT", "UPDATE", "DELETE", "MERGE", "TRUNCATE",
+		"DROP", "ALTER", "CREATE", "EXEC", "EXECUTE", "IF", "ELSE", "WHILE", "BEGIN",
+		"DECLARE", "USE", "GRANT", "REVOKE", "DENY", "BACKUP", "RESTORE", "DBCC"}
+	// keywords that precede a statement verb which is part of the current statement
+	mssqlContinuationKeywords = []string{"UNION", "ALL", "EXCEPT", "INTERSECT", "THEN",
+		"GRANT", "REVOKE", "DENY", "ON", "FOR", "AFTER", "OF", ","}
+)
+
+// sqlDialect is the database protocol of a query, it changes how
+// the tokenizer handles escapes in string literals and comments
+type sqlDialect int
+
+const (
+	dialectPostgres sqlDialect = iota
+	dialectMySQL
+	dialectMSSQL
+)
+
+// parseSQL splits a query into statements. It's a best effort parser which
+// understands comments, string literals and quoted identifiers well enough
+// to find the main verb, the target object and the presence of a WHERE clause.
+//
+// T-SQL batches don't require a semicolon between statements, the mssql
+// queries are also split before the keywords that start a new statement.
+func parseSQL(query string, dialect sqlDialect) []statement {
+	var stmts []statement
+	var tokens []string
+	depth := 0
+	for _, tok := range tokenize(query, dialect) {
+		switch tok {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+		isBatchStart := dialect == dialectMSSQL && depth == 0 && len(tokens) > 0 &&
+			isMSSQLStatementStart(tokens[len(tokens)-1], tok)
+		if tok == ";" || isBatchStart {
+			if len(tokens) > 0 {
+				stmts = append(stmts, parseStatements(tokens)...)
+			}
+			tokens = nil
+			if tok == ";" {
+				depth = 0
+				continue
+			}
+		}
+		tokens = append(tokens, tok)
+	}
+	if len(tokens) > 0 {
+		stmts = append(stmts, parseStatements(tokens)...)
+	}
+	return stmts
+}
+
+// isMSSQLStatementStart reports if the keyword tok starts a new statement of a T-SQL batch,
+// the previous token tells when the keyword is part of the current statement, e.g.:
+// UNION SELECT, GRANT UPDATE ON, ON DELETE CASCADE, WHEN MATCHED THEN DELETE or DROP TABLE IF EXISTS
+func isMSSQLStatementStart(prev, tok string) bool {
+	if !slices.Contains(mssqlStatementVerbs, tok) {
+		return false
+	}
+	if tok == "IF" && slices.Contains(objectKeywords, prev) {
+		return false
+	}
+	return !slices.Contains(mssqlContinuationKeywords, prev)
+}
+
+// parseStatements returns the statement of the tokens followed by the data modifying
+// statements nested in parenthesis, e.g.: WITH x AS (DELETE FROM t RETURNING *) SELECT 1
+func parseStatements(tokens []string) []statement {
+	stmts := []statement{parseStatement(tokens)}
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i] != "(" || !slices.Contains(nestedVerbs, tokens[i+1]) {
+			continue
+		}
+		end := closingParenthesis(tokens, i)
+		stmts = append(stmts, parseStatements(tokens[i+1:end])...)
+		i = end
+	}
+	return stmts
+}
+
+func parseStatement(tokens []string) statement {
+	verbIdx := 0
+	// common table expressions: find the main verb outside the parenthesis
+	if tokens[0] == "WITH" {
+		verbIdx = indexAtDepth(tokens, 1, "SELECT", "INSERT", "UPDATE", "DELETE", "MERGE")
+		if verbIdx == -1 {
+			return statement{verb: "WITH"}
+		}
+	}
+	stmt := statement{verb: tokens[verbIdx]}
+	stmt.write = slices.Contains(writeVerbs, stmt.verb)
+	stmt.script = slices.Contains(scriptVerbs, stmt.verb)
+	stmt.hasWhere = indexAtDepth(tokens, verbIdx+1, "WHERE") > -1
+
+	var target string
+	isSchemaObject := false
+	switch stmt.verb {
+	case "INSERT", "REPLACE", "MERGE":
+		if idx := indexAtDepth(tokens, verbIdx+1, "INTO"); idx > -1 {
+			target = objectName(tokens, idx+1)
+		}
+	case "DELETE":
+		if idx := indexAtDepth(tokens, verbIdx+1, "FROM"); idx > -1 {
+			target = objectName(tokens, idx+1)
+		} else {
+			target = objectName(tokens, verbIdx+1)
+		}
+	case "COPY":
+		// COPY table FROM loads data into the table, COPY ... TO only reads it
+		if idx := indexAtDepth(tokens, verbIdx+1, "FROM"); idx > -1 {
+			stmt.write = true
+			stmt.script = idx+1 < len(tokens) && tokens[idx+1] == "PROGRAM"
+			target = objectName(tokens, verbIdx+1)
+		}
+	case "EXPLAIN":
+		// the ANALYZE option executes the statement
+		stmt.script = slices.Contains(tokens, "ANALYZE") || slices.Contains(tokens, "ANALYSE")
+	case "UPDATE", "TRUNCATE", "DROP", "ALTER", "CREATE", "RENAME":
+		for i := verbIdx + 1; i < len(tokens); i++ {
+			tok := tokens[i]
+			if tok == "SCHEMA" || tok == "DATABASE" {
+				isSchemaObject = true
+			}
+			if tok == "INDEX" {
+				// the index is created or altered on a table
+				if idx := indexAtDepth(tokens, i+1, "ON"); idx > -1 && idx+1 < len(tokens) {
+					target = tokens[idx+1]
+					break
+				}
+			}
+			if !slices.Contains(objectKeywords, tok) {
+				target = tok
+				break
+			}
+		}
+	}
+	parts := splitIdentifier(target)
+	switch {
+	case isSchemaObject && len(parts) > 0:
+		stmt.schema = parts[len(parts)-1]
+	case len(parts) > 1:
+		stmt.schema = parts[len(parts)-2]
+	}
+	return stmt
+}
+
+// objectName returns the first token starting at position start which is not an object keyword
+func objectName(tokens []string, start int) string {
+	for i := start; i < len(tokens); i++ {
+		if !slices.Contains(objectKeywords, tokens[i]) {
+			return tokens[i]
+		}
+	}
+	return ""
+}
+
+// closingParenthesis returns the index of the parenthesis that closes the one
+// opened at position start or the length of the tokens when it's not closed
+func closingParenthesis(tokens []string, start int) int {
+	depth := 0
+	for i := start; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens)
+}
+
+// indexAtDepth returns the index of the first keyword found at the top
+// level of the statement (outside of parenthesis) starting at position start
+func indexAtDepth(tokens []string, start int, keywords ...string) int {
+	depth := 0
+	for i, tok := range tokens {
+		switch tok {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+		if i >= start && depth == 0 && slices.Contains(keywords, tok) {
+			return i
+		}
+	}
+	return -1
+}
+
+// tokenize splits the query into words, keywords are returned in upper case
+// and quoted identifiers are kept as is. Comments and string literals are
+// discarded and punctuation characters are returned as their own tokens.
+//
+// The body of mysql executable comments (/*! ... */, /*!50000 ... */ and /*M! ... */)
+// is tokenized because the server runs it. Backslashes escape quotes only in mysql
+// strings and in postgres escape strings (E'...'), the other dialects treat them
+// as ordinary characters.
+func tokenize(query string, dialect sqlDialect) []string {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			w := word.String()
+			if !strings.ContainsAny(w, "\"`[") {
+				w = strings.ToUpper(w)
+			}
+			tokens = append(tokens, w)
+			word.Reset()
+		}
+	}
+	inExecComment := false
+	src := []rune(query)
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '-' && i+1 < len(src) && src[i+1] == '-':
+			flush()
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '*' && inExecComment && i+1 < len(src) && src[i+1] == '/':
+			flush()
+			inExecComment = false
+			i++
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			flush()
+			if dialect == dialectMySQL && !inExecComment {
+				if end, ok := execCommentStart(src, i); ok {
+					inExecComment = true
+					i = end - 1
+					continue
+				}
+			}
+			i += 2
+			for i+1 < len(src) && !(src[i] == '*' && src[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == '\'':
+			escapes := dialect == dialectMySQL ||
+				(dialect == dialectPostgres && strings.EqualFold(word.String(), "E"))
+			flush()
+			i = skipQuoted(src, i, '\'', escapes)
+		case c == '"' && dialect == dialectMySQL:
+			// mysql double quoted strings are literals unless ANSI_QUOTES is enabled
+			flush()
+			i = skipQuoted(src, i, '"', true)
+		case c == '$' && word.Len() == 0:
+			// dollar quoted strings: $$ ... $$ or $tag$ ... $tag$
+			end := i + 1
+			for end < len(src) && (unicode.IsLetter(src[end]) || unicode.IsDigit(src[end]) || src[end] == '_') {
+				end++
+			}
+			if end < len(src) && src[end] == '$' {
+				tag := src[i : end+1]
+				if idx := indexRunes(src[end+1:], tag); idx > -1 {
+					i = end + idx + len(tag)
+				} else {
+					i = len(src)
+				}
+				continue
+			}
+			word.WriteRune(c)
+		case c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			end := skipQuoted(src, i, closing, false)
+			word.WriteString(string(src[i:min(end+1, len(src))]))
+			i = end
+		case c == ';' || c == '(' || c == ')' || c == ',':
+			flush()
+			tokens = append(tokens, string(c))
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.' || c == '$' || c == '@' || c == '#':
+			word.WriteRune(c)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// execCommentStart reports if the comment starting at pos is a mysql executable
+// comment and returns the position where its body starts, after the optional version
+func execCommentStart(src []rune, pos int) (int, bool) {
+	i := pos + 2
+	if i < len(src) && src[i] == 'M' {
+		i++
+	}
+	if i >= len(src) || src[i] != '!' {
+		return 0, false
+	}
+	i++
+	for i < len(src) && unicode.IsDigit(src[i]) {
+		i++
+	}
+	return i, true
+}
+
+// skipQuoted returns the position of the closing quote of a quoted section
+// starting at pos. Doubled quotes are skipped and backslash escapes are
+// skipped when escapes is set.
+func skipQuoted(src []rune, pos int, closing rune, escapes bool) int {
+	for i := pos + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			if escapes {
+				i++
+			}
+		case closing:
+			if i+1 < len(src) && src[i+1] == closing {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(src)
+}
+
+func indexRunes(s, sep []rune) int {
+	for i := 0; i+len(sep) <= len(s); i++ {
+		if slices.Equal(s[i:i+len(sep)], sep) {
+			return i
+		}
+	}
+	return -1
+}
+
+// splitIdentifier splits a qualified identifier (schema.table) removing any quotes
+func splitIdentifier(ident string) []string {
+	if ident == "" {
+		return nil
+	}
+	var parts []string
+	var part strings.Builder
+	var quote rune
+	for _, c := range ident {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			part.WriteRune(c)
+		case c == '"' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '.':
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteRune(c)
+		}
+	}
+	return append(parts, part.String())
+}
+
+// mongoVerbs maps commands to their sql equivalent verbs,
+// it allows using the same set of rules for any protocol.
+var mongoVerbs = map[string]string{
+	"insert":           "INSERT",
+	"update":           "UPDATE",
+	"findAndModify":    "UPDATE",
+	"delete":           "DELETE",
+	"drop":             "DROP",
+	"dropDatabase":     "DROP",
+	"dropIndexes":      "DROP",
+	"create":           "CREATE",
+	"createIndexes":    "CREATE",
+	"collMod":          "ALTER",
+	"renameCollection": "RENAME",
+}
+
+// parseMongoCommand parses an OP_MSG command decoded as json.
+// The database of the command is used as the schema.
+func parseMongoCommand(data []byte) (*statement, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("command is not a json document")
+	}
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding command name: %v", err)
+	}
+	commandName, _ := tok.(string)
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed decoding command: %v", err)
+	}
+	stmt := &statement{verb: strings.ToUpper(commandName), hasWhere: true}
+	if verb, ok := mongoVerbs[commandName]; ok {
+		stmt.verb = verb
+		stmt.write = true
+	}
+	_ = json.Unmarshal(doc["$db"], &stmt.schema)
+	switch commandName {
+	case "update":
+		stmt.hasWhere = hasMongoFilters(doc["updates"])
+	case "delete":
+		stmt.hasWhere = hasMongoFilters(doc["deletes"])
+	case "findAndModify":
+		var opts struct {
+			Query  map[string]any `json:"query"`
+			Remove bool           `json:"remove"`
+		}
+		_ = json.Unmarshal(data, &opts)
+		// the matched document is removed instead of updated
+		if opts.Remove {
+			stmt.verb = "DELETE"
+		}
+		stmt.hasWhere = len(opts.Query) > 0
+	case "aggregate":
+		// the $out and $merge stages write the results into a collection
+		var opts struct {
+			Pipeline []map[string]json.RawMessage `json:"pipeline"`
+		}
+		_ = json.Unmarshal(data, &opts)
+		for _, stage := range opts.Pipeline {
+			for _, name := range []string{"$out", "$merge"} {
+				target, ok := stage[name]
+				if !ok {
+					continue
+				}
+				stmt.write = true
+				// the target could be in another database: {"$out": {"db": "app", "coll": "users"}}
+				var into struct {
+					DB   string          `json:"db"`
+					Into json.RawMessage `json:"into"`
+				}
+				_ = json.Unmarshal(target, &into)
+				_ = json.Unmarshal(into.Into, &into)
+				if into.DB != "" {
+					stmt.schema = into.DB
+				}
+			}
+		}
+	}
+	return stmt, nil
+}
+
+// hasMongoFilters reports if all statements of an update or delete command have a filter.
+// The statements sent as document sequences are decoded as part of the command, when they
+// are missing or malformed the statements are considered not filtered.
+func hasMongoFilters(data json.RawMessage) bool {
+	var items []struct {
+		Q map[string]any `json:"q"`
+	}
+	if err := json.Unmarshal(data, &items); err != nil || len(items) == 0 {
+		return false
+	}
+	for _, item := range items {
+		if len(item.Q) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// redisWriteCommands are the commands that change data or the configuration of the server
+var redisWriteCommands = []string{
+	"SET", "SETEX", "PSETEX", "SETNX", "MSET", "MSETNX", "GETSET", "GETDEL", "APPEND",
+	"INCR", "INCRBY", "INCRBYFLOAT", "DECR", "DECRBY", "DEL", "UNLINK", "EXPIRE",
+	"PEXPIRE", "EXPIREAT", "PEXPIREAT", "PERSIST", "RENAME", "RENAMENX", "COPY", "MOVE",
+	"HSET", "HSETNX", "HMSET", "HDEL", "HINCRBY", "HINCRBYFLOAT",
+	"LPUSH", "RPUSH", "LPUSHX", "RPUSHX", "LPOP", "RPOP", "LSET", "LREM", "LTRIM", "LINSERT", "LMOVE",
+	"SADD", "SREM", "SPOP", "SMOVE", "ZADD", "ZREM", "ZINCRBY", "ZPOPMIN", "ZPOPMAX",
+	"XADD", "XDEL", "XTRIM", "RESTORE", "FLUSHDB", "FLUSHALL", "CONFIG", "EVAL", "EVALSHA",
+	"FUNCTION", "SCRIPT", "SWAPDB", "MIGRATE",
+}
+
+// redisScriptCommands are the commands that run lua scripts or functions in the server
+var redisScriptCommands = []string{"EVAL", "EVALSHA", "EVAL_RO", "EVALSHA_RO", "FCALL", "FCALL_RO"}
+
+// parseRedisCommand uses the name of the command as the verb, it allows denying
+// dangerous commands with the same rules, e.g.: rule:deny-statement:FLUSHALL
+func parseRedisCommand(cmd *redistypes.Command) statement {
+	verb := cmd.Name()
+	return statement{
+		verb:     verb,
+		write:    slices.Contains(redisWriteCommands, verb),
+		hasWhere: true,
+		script:   slices.Contains(redisScriptCommands, verb),
+	}
+}
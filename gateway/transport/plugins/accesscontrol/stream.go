@@ -0,0 +1,124 @@
+package accesscontrol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"sync"
+
+	pbagent "github.com/hoophq/hoop/common/proto/agent"
+)
+
+const (
+	// maxBufferSize is the maximum size of a message buffered until it's complete
+	maxBufferSize = 64 * 1024 * 1024
+	// mysqlMaxPayloadLength indicates the command continues in the next packet
+	mysqlMaxPayloadLength = 1<<24 - 1
+	// mongoMaxMessageSize is the maximum size of a message accepted by mongodb servers
+	mongoMaxMessageSize = 48 * 1000 * 1000
+	// tdsStatusEOM indicates the last packet of a tds message
+	tdsStatusEOM byte = 0x01
+)
+
+// sessionState has the state of the client connections of a session
+type sessionState struct {
+	mu          sync.Mutex
+	connections map[string]*connState
+}
+
+func (s *sessionState) connection(connectionID string) *connState {
+	conn, ok := s.connections[connectionID]
+	if !ok {
+		conn = &connState{}
+		s.connections[connectionID] = conn
+	}
+	return conn
+}
+
+// connState is the state of a client connection. The client proxy of mysql, mssql and
+// mongodb connections forwards the bytes as they are read, a protocol packet could be split
+// in many stream packets or a stream packet could have many protocol packets.
+type connState struct {
+	// buf has the bytes of the messages that are not complete yet
+	buf []byte
+	// pg is the state of the extended queries of postgres connections
+	pg pgState
+}
+
+// next appends the payload to the buffer of the connection and returns the bytes of the complete
+// messages and each message decoded as a single protocol packet. The messages of mssql could
+// span many tds packets, their packets are held until the last one is received.
+func (c *connState) next(pktType string, payload []byte) (raw []byte, msgs [][]byte, err error) {
+	c.buf = append(c.buf, payload...)
+	var tdsMessage []byte
+	pos, end := 0, 0
+	for {
+		size, err := packetSize(pktType, c.buf[end:])
+		if err != nil {
+			c.buf = nil
+			return nil, nil, err
+		}
+		if size == 0 || len(c.buf)-end < size {
+			break
+		}
+		pkt := c.buf[end : end+size]
+		end += size
+		if pktType != pbagent.MSSQLConnectionWrite {
+			msgs = append(msgs, pkt)
+			pos = end
+			continue
+		}
+		if tdsMessage == nil {
+			tdsMessage = slices.Clone(pkt)
+		} else {
+			tdsMessage = append(tdsMessage, pkt[8:]...)
+		}
+		if pkt[1]&tdsStatusEOM != 0 {
+			msgs = append(msgs, tdsMessage)
+			tdsMessage = nil
+			pos = end
+		}
+	}
+	if len(c.buf)-pos > maxBufferSize {
+		c.buf = nil
+		return nil, nil, fmt.Errorf("reached max buffer size (%v)", maxBufferSize)
+	}
+	raw = slices.Clone(c.buf[:pos])
+	c.buf = slices.Clone(c.buf[pos:])
+	return raw, msgs, nil
+}
+
+// packetSize returns the size of the protocol packet at the start of data,
+// it returns zero when the data doesn't have the header of the packet yet.
+func packetSize(pktType string, data []byte) (int, error) {
+	switch pktType {
+	case pbagent.MySQLConnectionWrite:
+		if len(data) < 4 {
+			return 0, nil
+		}
+		length := int(uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16)
+		if length == mysqlMaxPayloadLength {
+			return 0, fmt.Errorf("mysql commands larger than 16MB are not supported")
+		}
+		return length + 4, nil
+	case pbagent.MSSQLConnectionWrite:
+		if len(data) < 8 {
+			return 0, nil
+		}
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if length < 8 {
+			return 0, fmt.Errorf("invalid tds packet length (%v)", length)
+		}
+		return length, nil
+	case pbagent.MongoDBConnectionWrite:
+		if len(data) < 4 {
+			return 0, nil
+		}
+		length := int(int32(binary.LittleEndian.Uint32(data[:4])))
+		if length < 16 || length > mongoMaxMessageSize {
+			return 0, fmt.Errorf("invalid mongodb message length (%v)", length)
+		}
+		return length, nil
+	}
+	return 0, fmt.Errorf("packet type %v is not supported", pktType)
+}
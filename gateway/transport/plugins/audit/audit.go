@@ -10,6 +10,8 @@ import (
 	"github.com/hoophq/hoop/common/log"
 	"github.com/hoophq/hoop/common/memory"
 	mssqltypes "github.com/hoophq/hoop/common/mssqltypes"
+	"github.com/hoophq/hoop/common/mysqltypes"
+	"github.com/hoophq/hoop/common/oracletypes"
 	pgtypes "github.com/hoophq/hoop/common/pgtypes"
 	pb "github.com/hoophq/hoop/common/proto"
 	pbagent "github.com/hoophq/hoop/common/proto/agent"
@@ -85,7 +87,23 @@ func (p *auditPlugin) OnConnect(pctx plugintypes.Context) error {
 }
 
 func (p *auditPlugin) OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plugintypes.ConnectResponse, error) {
+	// the packet is not sent to the agent, the messages dropped by the access
+	// control plugin are recorded only when they are denied
+	_, isDropped := pkt.Spec[pb.SpecPluginAccessControlDropKey]
+	if _, isDenied := pkt.Spec[pb.SpecPluginAccessControlDeniedKey]; isDropped && !isDenied {
+		return nil, nil
+	}
 	eventMetadata := parseSpecAsEventMetadata(pkt)
+	p.writeLabels(pctx.SID, pkt.Spec)
+	// the statement was denied by the access control plugin,
+	// record the reason after the input event
+	if deniedMsg, ok := pkt.Spec[pb.SpecPluginAccessControlDeniedKey]; ok {
+		defer func() {
+			if err := p.writeOnReceive(pctx.SID, eventlogv1.ErrorType, deniedMsg, nil); err != nil {
+				log.With("sid", pctx.SID).Warnf("failed writing access control denied event, err=%v", err)
+			}
+		}()
+	}
 	switch pb.PacketType(pkt.GetType()) {
 	case pbagent.SessionOpen:
 		// The session is never cleaned properly when the connection has a review
@@ -99,12 +117,28 @@ func (p *auditPlugin) OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plug
 		}
 	case pbclient.PGConnectionWrite, pbclient.MySQLConnectionWrite:
 		if len(eventMetadata) > 0 {
-			return nil, p.writeOnReceive(pctx.SID, eventlogv1.OutputType, nil, eventMetadata)
+			if err := p.writeOnReceive(pctx.SID, eventlogv1.OutputType, nil, eventMetadata); err != nil {
+				return nil, err
+			}
 		}
+		return nil, p.writeOnResultSet(pctx, pkt)
+	case pbclient.MSSQLConnectionWrite:
+		return nil, p.writeOnResultSet(pctx, pkt)
 	case pbagent.PGConnectionWrite:
+		if err := p.writeOnResultSet(pctx, pkt); err != nil {
+			return nil, err
+		}
 		isSimpleQuery, queryBytes, err := pgtypes.SimpleQueryContent(pkt.Payload)
+		if _, ok := pkt.Spec[pb.SpecPluginAccessControlDeniedKey]; ok && !isSimpleQuery {
+			// the denied statement is not prepared in the server, it must not be executed by the next messages
+			if query := decodePGParseQuery(pkt.Payload); query != nil {
+				return nil, p.writeOnReceive(pctx.SID, eventlogv1.InputType, query, eventMetadata)
+			}
+			return nil, nil
+		}
 		if !isSimpleQuery {
-			break
+			connectionID := string(pkt.Spec[pb.SpecClientConnectionID])
+			return nil, p.writeOnPGExtendedQuery(pctx.SID, connectionID, pkt.Payload, eventMetadata)
 		}
 		if err != nil {
 			log.With("sid", pctx.SID).Errorf("failed parsing simple query data, err=%v", err)
@@ -112,10 +146,21 @@ func (p *auditPlugin) OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plug
 		}
 		return nil, p.writeOnReceive(pctx.SID, eventlogv1.InputType, queryBytes, eventMetadata)
 	case pbagent.MySQLConnectionWrite:
-		if queryBytes := decodeMySQLCommandQuery(pkt.Payload); queryBytes != nil {
+		if err := p.writeOnResultSet(pctx, pkt); err != nil {
+			return nil, err
+		}
+		queryBytes, err := mysqltypes.DecodeQuery(pkt.Payload)
+		if err != nil {
+			log.With("sid", pctx.SID).Warnf("failed decoding mysql query, reason=%v", err)
+			return nil, nil
+		}
+		if queryBytes != nil {
 			return nil, p.writeOnReceive(pctx.SID, eventlogv1.InputType, queryBytes, eventMetadata)
 		}
 	case pbagent.MSSQLConnectionWrite:
+		if err := p.writeOnResultSet(pctx, pkt); err != nil {
+			return nil, err
+		}
 		var mssqlPacketType mssqltypes.PacketType
 		if len(pkt.Payload) > 0 {
 			mssqlPacketType = mssqltypes.PacketType(pkt.Payload[0])
@@ -130,8 +175,28 @@ func (p *auditPlugin) OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plug
 				return nil, p.writeOnReceive(pctx.SID, eventlogv1.InputType, []byte(query), eventMetadata)
 			}
 		}
+	case pbagent.OracleDBConnectionWrite:
+		if stmt := oracletypes.DecodeSQLStatement(pkt.Payload); stmt != "" {
+			return nil, p.writeOnReceive(pctx.SID, eventlogv1.InputType, []byte(stmt), eventMetadata)
+		}
+	case pbagent.RedisConnectionWrite:
+		if command := decodeRedisCommand(pkt.Payload); command != "" {
+			return nil, p.writeOnReceive(pctx.SID, eventlogv1.InputType, []byte(command), eventMetadata)
+		}
+	case pbagent.SSHConnectionWrite:
+		if event := decodeSSHInput(pkt.Payload); len(event) > 0 {
+			return nil, p.writeOnReceive(pctx.SID, eventlogv1.InputType, event, eventMetadata)
+		}
+	case pbclient.SSHConnectionWrite:
+		if event := decodeSSHOutput(pkt.Payload); len(event) > 0 {
+			return nil, p.writeOnReceive(pctx.SID, eventlogv1.OutputType, event, eventMetadata)
+		}
+	case pbagent.HTTPConnectionWrite:
+		return nil, p.writeOnHTTPEvent(pctx, eventlogv1.InputType, pkt.Payload, eventMetadata)
+	case pbclient.HTTPConnectionWrite:
+		return nil, p.writeOnHTTPEvent(pctx, eventlogv1.OutputType, pkt.Payload, eventMetadata)
 	case pbagent.MongoDBConnectionWrite:
-		decJSONPayload, err := decodeClientMongoOpMsgPacket(pkt.Payload)
+		decJSONPayload, err := decodeClientMongoPacket(pkt.Payload)
 		if err != nil {
 			return nil, err
 		}
@@ -151,6 +216,10 @@ func (p *auditPlugin) OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plug
 			return nil, nil
 		}
 		p.closeSession(pctx, nil)
+	case pbagent.TerminalResizeTTY:
+		p.writeTerminalSize(pctx.SID, pkt.Payload)
+	case pbagent.TCPConnectionClose, pbclient.TCPConnectionClose:
+		p.dropConnection(pctx.SID, string(pkt.Spec[pb.SpecClientConnectionID]))
 	case pbagent.ExecWriteStdin,
 		pbagent.TerminalWriteStdin,
 		pbagent.TCPConnectionWrite:
@@ -0,0 +1,380 @@
+package audit
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/common/mssqltypes"
+	"github.com/hoophq/hoop/common/mysqltypes"
+	"github.com/hoophq/hoop/common/pgtypes"
+	pb "github.com/hoophq/hoop/common/proto"
+	pbagent "github.com/hoophq/hoop/common/proto/agent"
+	pbclient "github.com/hoophq/hoop/common/proto/client"
+	"github.com/hoophq/hoop/common/proto/spectypes"
+	eventlogv1 "github.com/hoophq/hoop/gateway/session/eventlog/v1"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+)
+
+// The capture of result sets is configured alongside the groups of the plugin connection
+// configuration. The limits are applied for each client connection, examples:
+//
+//	capture-rows:100 - capture up to 100 rows, it enables capturing result sets
+//	capture-bytes:65536 - capture up to 65536 bytes of column values, defaults to 1MB
+const (
+	captureRowsPrefix  = "capture-rows:"
+	captureBytesPrefix = "capture-bytes:"
+
+	defaultCaptureMaxBytes = 1024 * 1024
+	// maxCaptureBufferSize is the max size of a protocol message waiting to be decoded
+	maxCaptureBufferSize = 1 << 24
+)
+
+const (
+	// outputFormatMetadataKey is the key of the event log metadata with the format
+	// of the output event, result sets are json encoded.
+	outputFormatMetadataKey string = "output.format"
+	outputFormatResultSet   string = "result-set"
+)
+
+type captureConfig struct {
+	maxRows  int
+	maxBytes int
+}
+
+// parseCaptureConfig returns the capture limits from the plugin connection configuration,
+// it returns nil when capturing result sets is disabled.
+func parseCaptureConfig(config []string) (*captureConfig, error) {
+	cfg := &captureConfig{maxBytes: defaultCaptureMaxBytes}
+	for _, entry := range config {
+		var prefix string
+		switch {
+		case strings.HasPrefix(entry, captureRowsPrefix):
+			prefix = captureRowsPrefix
+		case strings.HasPrefix(entry, captureBytesPrefix):
+			prefix = captureBytesPrefix
+		default:
+			continue
+		}
+		val, err := strconv.Atoi(entry[len(prefix):])
+		if err != nil || val <= 0 {
+			return nil, fmt.Errorf("invalid capture config %q, expected a positive number", entry)
+		}
+		if prefix == captureRowsPrefix {
+			cfg.maxRows = val
+		} else {
+			cfg.maxBytes = val
+		}
+	}
+	if cfg.maxRows == 0 {
+		return nil, nil
+	}
+	return cfg, nil
+}
+
+// resultSet is the payload of the output events of captured result sets
+type resultSet struct {
+	Columns []string    `json:"columns"`
+	Rows    [][]*string `json:"rows"`
+	// Truncated is true when the capture limit was reached before the end of the result set
+	Truncated bool `json:"truncated"`
+}
+
+// resultCapture decodes the result sets sent by the database to a client connection
+type resultCapture struct {
+	config   captureConfig
+	connType pb.ConnectionType
+	rows     int
+	bytes    int
+	// started is true after the first query of the client,
+	// the server stream is aligned with the protocol messages from that point.
+	started bool
+	// disabled is true when the limits are reached or the stream could not be decoded
+	disabled bool
+	buf      []byte
+	current  *resultSet
+	// completed are the result sets ready to be written
+	completed []*resultSet
+
+	mysqlResultSet mysqltypes.TextResultSet
+
+	mssqlTokens  []byte
+	mssqlDecoder mssqltypes.TokenDecoder
+}
+
+func newResultCapture(cfg captureConfig, connType pb.ConnectionType) *resultCapture {
+	return &resultCapture{config: cfg, connType: connType}
+}
+
+// onClientPacket inspects the packets sent by the client to the database,
+// the result sets are captured only for queries.
+func (c *resultCapture) onClientPacket(payload []byte) {
+	if len(payload) == 0 {
+		return
+	}
+	switch c.connType {
+	case pb.ConnectionTypePostgres:
+		switch pgtypes.PacketType(payload[0]) {
+		case pgtypes.ClientSimpleQuery, pgtypes.ClientParse:
+			c.started = true
+		}
+	case pb.ConnectionTypeMySQL:
+		if c.mysqlResultSet.OnClientPacket(payload) {
+			c.started = true
+		}
+	case pb.ConnectionTypeMSSQL:
+		switch mssqltypes.PacketType(payload[0]) {
+		case mssqltypes.PacketSQLBatchType, mssqltypes.PacketRPCRequestType:
+			c.started = true
+		}
+	}
+}
+
+// onServerPacket decodes the packets sent by the database and returns the completed result sets
+func (c *resultCapture) onServerPacket(payload []byte) ([]*resultSet, error) {
+	if !c.started || c.disabled {
+		return nil, nil
+	}
+	c.buf = append(c.buf, payload...)
+	var err error
+	switch c.connType {
+	case pb.ConnectionTypePostgres:
+		err = c.decodePostgres()
+	case pb.ConnectionTypeMySQL:
+		err = c.decodeMySQL()
+	case pb.ConnectionTypeMSSQL:
+		err = c.decodeMSSQL()
+	}
+	if err == nil && len(c.buf) > maxCaptureBufferSize {
+		err = fmt.Errorf("max buffer size (%v) reached", maxCaptureBufferSize)
+	}
+	if err != nil || c.disabled {
+		c.disabled = true
+		c.buf = nil
+		c.mssqlTokens = nil
+	}
+	// release the memory of decoded messages
+	if len(c.buf) == 0 {
+		c.buf = nil
+	}
+	completed := c.completed
+	c.completed = nil
+	return completed, err
+}
+
+func (c *resultCapture) decodePostgres() error {
+	for !c.disabled && len(c.buf) >= 5 {
+		// type(1), length(4) - the length includes itself
+		size := int(binary.BigEndian.Uint32(c.buf[1:5])) + 1
+		if size < 5 {
+			return fmt.Errorf("invalid packet length %v", size)
+		}
+		if len(c.buf) < size {
+			return nil
+		}
+		typ, frame := pgtypes.PacketType(c.buf[0]), c.buf[5:size]
+		c.buf = c.buf[size:]
+		switch typ {
+		case pgtypes.ServerRowDescription:
+			columns, err := pgtypes.DecodeRowDescription(frame)
+			if err != nil {
+				return err
+			}
+			c.OnColumns(columns)
+		case pgtypes.ServerDataRow:
+			values, err := pgtypes.DecodeDataRow(frame)
+			if err != nil {
+				return err
+			}
+			c.OnRow(values)
+		case pgtypes.ServerCommandComplete, pgtypes.ServerErrorResponse:
+			c.OnDone()
+		}
+	}
+	return nil
+}
+
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_query_response_text_resultset.html
+func (c *resultCapture) decodeMySQL() error {
+	for !c.disabled && len(c.buf) >= 4 {
+		size := int(uint32(c.buf[0])|uint32(c.buf[1])<<8|uint32(c.buf[2])<<16) + 4
+		if len(c.buf) < size {
+			return nil
+		}
+		frame := c.buf[4:size]
+		c.buf = c.buf[size:]
+		if err := c.decodeMySQLPacket(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *resultCapture) decodeMySQLPacket(frame []byte) error {
+	kind, err := c.mysqlResultSet.Next(frame)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case mysqltypes.ResultPacketColumns:
+		c.OnColumns(c.mysqlResultSet.Columns())
+	case mysqltypes.ResultPacketRow:
+		values, err := mysqltypes.DecodeTextRow(frame, len(c.mysqlResultSet.Columns()))
+		if err != nil {
+			return err
+		}
+		c.OnRow(values)
+	case mysqltypes.ResultPacketEnd:
+		c.OnDone()
+	}
+	return nil
+}
+
+// https://learn.microsoft.com/en-us/openspecs/windows_protocols/ms-tds/7af53667-1b72-4703-8258-7984e838f746
+func (c *resultCapture) decodeMSSQL() error {
+	for !c.disabled && len(c.buf) >= 8 {
+		// type(1), status(1), length(2) - the length includes the header
+		size := int(binary.BigEndian.Uint16(c.buf[2:4]))
+		if size < 8 {
+			return fmt.Errorf("invalid packet length %v", size)
+		}
+		if len(c.buf) < size {
+			return nil
+		}
+		typ, status, frame := mssqltypes.PacketType(c.buf[0]), c.buf[1], c.buf[8:size]
+		c.buf = c.buf[size:]
+		if typ != mssqltypes.PacketReplyType {
+			continue
+		}
+		c.mssqlTokens = append(c.mssqlTokens, frame...)
+		consumed, err := c.mssqlDecoder.Decode(c.mssqlTokens, c)
+		if err != nil {
+			return err
+		}
+		c.mssqlTokens = c.mssqlTokens[consumed:]
+		// end of message
+		if status&0x01 == 0x01 {
+			c.mssqlTokens = nil
+			c.mssqlDecoder.Reset()
+			c.OnDone()
+		}
+	}
+	return nil
+}
+
+// OnColumns starts a new result set, implements mssqltypes.ResultSetHandler
+func (c *resultCapture) OnColumns(names []string) {
+	c.complete(false)
+	c.current = &resultSet{Columns: names, Rows: [][]*string{}}
+}
+
+// OnRow adds a row to the current result set until the capture limits are reached
+func (c *resultCapture) OnRow(values [][]byte) {
+	if c.current == nil || c.disabled {
+		return
+	}
+	row := make([]*string, len(values))
+	var rowSize int
+	for i, val := range values {
+		if val == nil {
+			continue
+		}
+		v := string(val)
+		if !utf8.Valid(val) {
+			v = `\x` + hex.EncodeToString(val)
+		}
+		row[i] = &v
+		rowSize += len(v)
+	}
+	if c.rows >= c.config.maxRows || c.bytes+rowSize > c.config.maxBytes {
+		c.complete(true)
+		c.disabled = true
+		return
+	}
+	c.rows++
+	c.bytes += rowSize
+	c.current.Rows = append(c.current.Rows, row)
+}
+
+// OnDone ends the current result set
+func (c *resultCapture) OnDone() { c.complete(false) }
+
+func (c *resultCapture) complete(truncated bool) {
+	if c.current == nil {
+		return
+	}
+	c.current.Truncated = truncated
+	c.completed = append(c.completed, c.current)
+	c.current = nil
+}
+
+// writeOnResultSet captures the result sets of a connection and write them as output events
+func (p *auditPlugin) writeOnResultSet(pctx plugintypes.Context, pkt *pb.Packet) error {
+	cfg, err := parseCaptureConfig(pctx.PluginConnectionConfig)
+	if err != nil {
+		log.With("sid", pctx.SID).Warnf("failed parsing capture config, reason=%v", err)
+		return nil
+	}
+	if cfg == nil {
+		return nil
+	}
+	walogm, ok := p.walSessionStore.Get(pctx.SID).(*walLogRWMutex)
+	if !ok {
+		return fmt.Errorf("failed obtaining write ahead log for session %v", pctx.SID)
+	}
+	walogm.mu.Lock()
+	defer walogm.mu.Unlock()
+	var connType pb.ConnectionType
+	switch pb.PacketType(pkt.Type) {
+	case pbagent.PGConnectionWrite, pbclient.PGConnectionWrite:
+		connType = pb.ConnectionTypePostgres
+	case pbagent.MySQLConnectionWrite, pbclient.MySQLConnectionWrite:
+		connType = pb.ConnectionTypeMySQL
+	case pbagent.MSSQLConnectionWrite, pbclient.MSSQLConnectionWrite:
+		connType = pb.ConnectionTypeMSSQL
+	default:
+		return nil
+	}
+	connectionID := string(pkt.Spec[pb.SpecClientConnectionID])
+	capture, ok := walogm.captures[connectionID]
+	if !ok {
+		capture = newResultCapture(*cfg, connType)
+		walogm.captures[connectionID] = capture
+	}
+	switch pb.PacketType(pkt.Type) {
+	case pbagent.PGConnectionWrite, pbagent.MySQLConnectionWrite, pbagent.MSSQLConnectionWrite:
+		// the denied packets are not sent to the server, they don't have a result set
+		if _, ok := pkt.Spec[pb.SpecPluginAccessControlDeniedKey]; !ok {
+			capture.onClientPacket(pkt.Payload)
+		}
+		return nil
+	}
+	// the rows would be stored without the redaction of the data masking provider
+	if _, ok := pkt.Spec[spectypes.DataMaskingUnsupportedKey]; ok && !capture.disabled {
+		log.With("sid", pctx.SID, "conn", connectionID).
+			Warnf("the data masking provider does not redact %v result sets, capture is disabled for this connection", connType)
+		capture.disabled = true
+		return nil
+	}
+	resultSets, err := capture.onServerPacket(pkt.Payload)
+	if err != nil {
+		log.With("sid", pctx.SID, "conn", connectionID).
+			Warnf("failed decoding result set, capture is disabled for this connection, reason=%v", err)
+	}
+	metadata := map[string][]byte{outputFormatMetadataKey: []byte(outputFormatResultSet)}
+	for _, rs := range resultSets {
+		data, err := json.Marshal(rs)
+		if err != nil {
+			return fmt.Errorf("failed encoding result set, reason=%v", err)
+		}
+		if err := walogm.write(eventlogv1.OutputType, data, metadata); err != nil {
+			return err
+		}
+	}
+	return nil
+}
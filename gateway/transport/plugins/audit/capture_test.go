@@ -0,0 +1,266 @@
+package audit
+
+import (
+	"encoding/binary"
+	"maps"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/hoophq/hoop/common/memory"
+	"github.com/hoophq/hoop/common/mssqltypes"
+	"github.com/hoophq/hoop/common/mysqltypes"
+	"github.com/hoophq/hoop/common/pgtypes"
+	pb "github.com/hoophq/hoop/common/proto"
+	pbagent "github.com/hoophq/hoop/common/proto/agent"
+	pbclient "github.com/hoophq/hoop/common/proto/client"
+	"github.com/hoophq/hoop/common/proto/spectypes"
+	eventlogv1 "github.com/hoophq/hoop/gateway/session/eventlog/v1"
+	"github.com/hoophq/hoop/gateway/session/integrity"
+	sessionwal "github.com/hoophq/hoop/gateway/session/wal"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCaptureConfig(t *testing.T) {
+	for _, tt := range []struct {
+		msg     string
+		config  []string
+		want    *captureConfig
+		wantErr string
+	}{
+		{msg: "it should be disabled without config", config: []string{"admin", "sre"}},
+		{msg: "it should be disabled when only the bytes limit is set", config: []string{"capture-bytes:1024"}},
+		{msg: "it should use the default bytes limit", config: []string{"sre", "capture-rows:10"},
+			want: &captureConfig{maxRows: 10, maxBytes: defaultCaptureMaxBytes}},
+		{msg: "it should parse both limits", config: []string{"capture-rows:10", "capture-bytes:1024"},
+			want: &captureConfig{maxRows: 10, maxBytes: 1024}},
+		{msg: "it should fail with invalid limits", config: []string{"capture-rows:-1"},
+			wantErr: `invalid capture config "capture-rows:-1"`},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := parseCaptureConfig(tt.config)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr+", expected a positive number")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func newPGServerPackets() []byte {
+	rowDesc := binary.BigEndian.AppendUint16(nil, 2)
+	for _, name := range []string{"id", "name"} {
+		rowDesc = append(rowDesc, cstring(name)...)
+		rowDesc = append(rowDesc, make([]byte, 18)...)
+	}
+	newDataRow := func(values ...[]byte) []byte {
+		frame := binary.BigEndian.AppendUint16(nil, uint16(len(values)))
+		for _, v := range values {
+			if v == nil {
+				frame = binary.BigEndian.AppendUint32(frame, 0xFFFFFFFF)
+				continue
+			}
+			frame = binary.BigEndian.AppendUint32(frame, uint32(len(v)))
+			frame = append(frame, v...)
+		}
+		return pgtypes.NewPacket(pgtypes.ServerDataRow, frame).Encode()
+	}
+	var data []byte
+	data = append(data, pgtypes.NewPacket(pgtypes.ServerRowDescription, rowDesc).Encode()...)
+	data = append(data, newDataRow([]byte("1"), []byte("john"))...)
+	data = append(data, newDataRow([]byte("2"), nil)...)
+	data = append(data, pgtypes.NewPacket(pgtypes.ServerCommandComplete, cstring("SELECT 2")).Encode()...)
+	data = append(data, pgtypes.NewReadyForQuery(pgtypes.ServerIdle).Encode()...)
+	return data
+}
+
+func newMySQLServerPackets() []byte {
+	lenencStr := func(v string) []byte { return append([]byte{byte(len(v))}, v...) }
+	var data []byte
+	seq := uint8(1)
+	appendPacket := func(frame []byte) {
+		data = append(data, mysqltypes.NewPacket(seq, frame).Encode()...)
+		seq++
+	}
+	eof := []byte{0xfe, 0x00, 0x00, 0x02, 0x00}
+	appendPacket([]byte{0x02})
+	for _, name := range []string{"id", "name"} {
+		var colDef []byte
+		for _, v := range []string{"def", "testdb", "users", "users", name, name} {
+			colDef = append(colDef, lenencStr(v)...)
+		}
+		appendPacket(colDef)
+	}
+	appendPacket(eof)
+	appendPacket(append(lenencStr("1"), lenencStr("john")...))
+	appendPacket(append(lenencStr("2"), 0xfb))
+	appendPacket(eof)
+	return data
+}
+
+func newMSSQLServerPackets() []byte {
+	ucs2 := func(v string) []byte {
+		var b []byte
+		for _, r := range utf16.Encode([]rune(v)) {
+			b = binary.LittleEndian.AppendUint16(b, r)
+		}
+		return b
+	}
+	bVarchar := func(v string) []byte { return append([]byte{byte(len(v))}, ucs2(v)...) }
+	tokens := []byte{0x81}
+	tokens = binary.LittleEndian.AppendUint16(tokens, 2)
+	// user type(4), flags(2), INTN(4)
+	tokens = append(tokens, 0, 0, 0, 0, 0, 0, 0x26, 0x04)
+	tokens = append(tokens, bVarchar("id")...)
+	// user type(4), flags(2), NVARCHAR(100), collation(5)
+	tokens = append(tokens, 0, 0, 0, 0, 0, 0, 0xe7, 100, 0, 0, 0, 0, 0, 0)
+	tokens = append(tokens, bVarchar("name")...)
+	for _, row := range []struct {
+		id   []byte
+		name string
+	}{{[]byte{0x04, 0x01, 0x00, 0x00, 0x00}, "john"}, {[]byte{0x00}, "ana"}} {
+		tokens = append(tokens, 0xd1)
+		tokens = append(tokens, row.id...)
+		tokens = binary.LittleEndian.AppendUint16(tokens, uint16(len(row.name)*2))
+		tokens = append(tokens, ucs2(row.name)...)
+	}
+	tokens = append(tokens, 0xfd)
+	tokens = append(tokens, make([]byte, 12)...)
+
+	// split the token stream in two packets of the same message
+	tdsPacket := func(status byte, frame []byte) []byte {
+		pkt := mssqltypes.New(mssqltypes.PacketReplyType, frame).Encode()
+		pkt[1] = status
+		return pkt
+	}
+	return append(tdsPacket(0x00, tokens[:20]), tdsPacket(0x01, tokens[20:])...)
+}
+
+func TestResultCapture(t *testing.T) {
+	str := func(v string) *string { return &v }
+	for _, tt := range []struct {
+		msg        string
+		connType   pb.ConnectionType
+		config     captureConfig
+		query      []byte
+		serverData []byte
+		want       []*resultSet
+	}{
+		{
+			msg:        "it should capture postgres result sets",
+			connType:   pb.ConnectionTypePostgres,
+			config:     captureConfig{maxRows: 10, maxBytes: 1024},
+			query:      pgtypes.NewPacket(pgtypes.ClientSimpleQuery, cstring("SELECT id, name FROM users")).Encode(),
+			serverData: newPGServerPackets(),
+			want: []*resultSet{{Columns: []string{"id", "name"},
+				Rows: [][]*string{{str("1"), str("john")}, {str("2"), nil}}}},
+		},
+		{
+			msg:        "it should capture mysql result sets",
+			connType:   pb.ConnectionTypeMySQL,
+			config:     captureConfig{maxRows: 10, maxBytes: 1024},
+			query:      mysqltypes.NewPacket(0, append([]byte{mysqltypes.ComQuery.Byte()}, "SELECT id, name FROM users"...)).Encode(),
+			serverData: newMySQLServerPackets(),
+			want: []*resultSet{{Columns: []string{"id", "name"},
+				Rows: [][]*string{{str("1"), str("john")}, {str("2"), nil}}}},
+		},
+		{
+			msg:        "it should capture mssql result sets",
+			connType:   pb.ConnectionTypeMSSQL,
+			config:     captureConfig{maxRows: 10, maxBytes: 1024},
+			query:      mssqltypes.New(mssqltypes.PacketSQLBatchType, nil).Encode(),
+			serverData: newMSSQLServerPackets(),
+			want: []*resultSet{{Columns: []string{"id", "name"},
+				Rows: [][]*string{{str("1"), str("john")}, {nil, str("ana")}}}},
+		},
+		{
+			msg:        "it should truncate the result set when the rows limit is reached",
+			connType:   pb.ConnectionTypePostgres,
+			config:     captureConfig{maxRows: 1, maxBytes: 1024},
+			query:      pgtypes.NewPacket(pgtypes.ClientSimpleQuery, cstring("SELECT id, name FROM users")).Encode(),
+			serverData: newPGServerPackets(),
+			want: []*resultSet{{Columns: []string{"id", "name"},
+				Rows: [][]*string{{str("1"), str("john")}}, Truncated: true}},
+		},
+		{
+			msg:        "it should truncate the result set when the bytes limit is reached",
+			connType:   pb.ConnectionTypeMySQL,
+			config:     captureConfig{maxRows: 10, maxBytes: 3},
+			query:      mysqltypes.NewPacket(0, append([]byte{mysqltypes.ComQuery.Byte()}, "SELECT id, name FROM users"...)).Encode(),
+			serverData: newMySQLServerPackets(),
+			want:       []*resultSet{{Columns: []string{"id", "name"}, Rows: [][]*string{}, Truncated: true}},
+		},
+		{
+			msg:        "it should not capture before the client sends a query",
+			connType:   pb.ConnectionTypePostgres,
+			config:     captureConfig{maxRows: 10, maxBytes: 1024},
+			serverData: newPGServerPackets(),
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			c := newResultCapture(tt.config, tt.connType)
+			c.onClientPacket(tt.query)
+			// the packets could be split at any position
+			var got []*resultSet
+			for i := 0; i < len(tt.serverData); i += 7 {
+				resultSets, err := c.onServerPacket(tt.serverData[i:min(i+7, len(tt.serverData))])
+				assert.NoError(t, err)
+				got = append(got, resultSets...)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWriteOnResultSetDataMaskingUnsupported(t *testing.T) {
+	for _, tt := range []struct {
+		msg        string
+		spec       map[string][]byte
+		wantEvents int
+	}{
+		{msg: "it should capture the result sets", wantEvents: 1},
+		{msg: "it should not capture result sets that are not redacted by the data masking provider",
+			spec: map[string][]byte{spectypes.DataMaskingUnsupportedKey: []byte("1")}},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			startDate := time.Now().UTC()
+			wh := &sessionwal.Header{
+				EventLogVersion: eventlogv1.Version,
+				OrgID:           "org-id",
+				SessionID:       "sid",
+				ConnectionName:  "mssql",
+				ConnectionType:  pb.ConnectionTypeMSSQL.String(),
+				StartDate:       &startDate,
+			}
+			walog, err := sessionwal.OpenWriteHeader(filepath.Join(t.TempDir(), "sid-wal"), wh)
+			assert.NoError(t, err)
+			defer walog.Close()
+			p := &auditPlugin{walSessionStore: memory.New()}
+			p.walSessionStore.Set("sid", &walLogRWMutex{
+				log:       walog,
+				startDate: startDate.Round(0),
+				chain:     integrity.NewChain(newIntegrityHeader(wh)),
+				captures:  map[string]*resultCapture{},
+			})
+			pctx := plugintypes.Context{SID: "sid", PluginConnectionConfig: []string{"capture-rows:10"}}
+			spec := map[string][]byte{pb.SpecClientConnectionID: []byte("1")}
+			query := &pb.Packet{Type: pbagent.MSSQLConnectionWrite, Payload: mssqltypes.New(mssqltypes.PacketSQLBatchType, nil).Encode(), Spec: spec}
+			assert.NoError(t, p.writeOnResultSet(pctx, query))
+			spec = maps.Clone(spec)
+			maps.Copy(spec, tt.spec)
+			assert.NoError(t, p.writeOnResultSet(pctx, &pb.Packet{Type: pbclient.MSSQLConnectionWrite, Payload: newMSSQLServerPackets(), Spec: spec}))
+
+			var events int
+			_, err = walog.ReadFull(func(data []byte) error {
+				events++
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantEvents, events)
+		})
+	}
+}
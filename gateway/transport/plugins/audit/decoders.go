@@ -3,44 +3,65 @@ package audit
 import (
 	"bytes"
 	"fmt"
+	"strings"
 
 	"github.com/hoophq/hoop/common/mongotypes"
+	"github.com/hoophq/hoop/common/redistypes"
+	"github.com/hoophq/hoop/common/sshtypes"
 )
 
-// decodeMySQLCommandQuery try to decode a packet to see if it's a COMM_QUERY type
-// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_query.html
-func decodeMySQLCommandQuery(payload []byte) []byte {
-	if len(payload) < 5 {
-		return nil
+// decodeClientMongoPacket returns the command sent by the client in OP_MSG
+// and OP_QUERY packets, compressed packets are decompressed before decoding it.
+func decodeClientMongoPacket(payload []byte) ([]byte, error) {
+	pkt, err := mongotypes.Decode(bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding mongodb packet: %v", err)
 	}
-	// type packet
-	pos := 4
+	return mongotypes.DecodeCommandToJSON(pkt)
+}
 
-	if payload[pos] != 0x03 {
-		return nil
+// decodeRedisCommand returns the commands sent by the client, one per line, the
+// arguments of the authentication commands are not recorded.
+func decodeRedisCommand(payload []byte) string {
+	// the commands decoded before an error are recorded
+	commands, _ := redistypes.DecodeCommands(payload)
+	var lines []string
+	for _, cmd := range commands {
+		switch cmd.Name() {
+		case "AUTH", "HELLO":
+			lines = append(lines, cmd.Name())
+		default:
+			lines = append(lines, cmd.String())
+		}
 	}
+	return strings.Join(lines, "\n")
+}
 
-	if payload[pos+1] == 0x00 {
-		// param count + param set count
-		pos += 2
-	}
-	if len(payload) < pos {
+// decodeSSHInput returns the commands started in the channels and the content
+// sent by the client, the other messages of the channels are not recorded.
+func decodeSSHInput(payload []byte) []byte {
+	msg, err := sshtypes.Decode(payload)
+	if err != nil {
 		return nil
 	}
-	// TODO: must check when parameters is set
-	return payload[pos:]
+	if command := msg.ExecCommand(); command != "" {
+		return []byte(command + "\n")
+	}
+	if msg.Type == sshtypes.MessageData {
+		return msg.Data
+	}
+	return nil
 }
 
-func decodeClientMongoOpMsgPacket(payload []byte) ([]byte, error) {
-	pkt, err := mongotypes.Decode(bytes.NewReader(payload))
+// decodeSSHOutput returns the content of the channels sent by the server
+func decodeSSHOutput(payload []byte) []byte {
+	msg, err := sshtypes.Decode(payload)
 	if err != nil {
-		return nil, fmt.Errorf("failed decoding mongodb packet: %v", err)
+		return nil
 	}
-	switch pkt.OpCode {
-	case mongotypes.OpCompressed:
-		return nil, fmt.Errorf("compression is not supported")
-	case mongotypes.OpMsgType:
-		return mongotypes.DecodeOpMsgToJSON(pkt)
+	switch msg.Type {
+	case sshtypes.MessageData, sshtypes.MessageExtendedData:
+		return msg.Data
 	}
-	return nil, nil
+	return nil
 }
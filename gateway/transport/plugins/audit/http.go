@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hoophq/hoop/common/httptypes"
+	eventlogv1 "github.com/hoophq/hoop/gateway/session/eventlog/v1"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+)
+
+// The bodies of the requests and responses of http connections are recorded when it's
+// enabled alongside the groups of the plugin connection configuration, example:
+//
+//	http-bodies:4096 - record up to 4096 bytes of the bodies
+const httpBodiesPrefix = "http-bodies:"
+
+const (
+	// inputFormatMetadataKey is the key of the event log metadata with the format
+	// of the input event, http requests are json encoded.
+	inputFormatMetadataKey string = "input.format"
+	inputFormatHTTPRequest string = "http-request"
+
+	outputFormatHTTPResponse string = "http-response"
+)
+
+// httpRequestEvent is the payload of the input events of http connections,
+// the headers are not recorded because they may contain credentials.
+type httpRequestEvent struct {
+	Method        string  `json:"method"`
+	Path          string  `json:"path"`
+	Query         string  `json:"query,omitempty"`
+	ContentType   string  `json:"content_type,omitempty"`
+	ContentLength int64   `json:"content_length"`
+	Body          *string `json:"body,omitempty"`
+	BodyTruncated bool    `json:"body_truncated,omitempty"`
+}
+
+// httpResponseEvent is the payload of the output events of http connections
+type httpResponseEvent struct {
+	Status        int     `json:"status"`
+	ContentType   string  `json:"content_type,omitempty"`
+	ContentLength int64   `json:"content_length"`
+	Body          *string `json:"body,omitempty"`
+	BodyTruncated bool    `json:"body_truncated,omitempty"`
+}
+
+// parseHTTPBodiesConfig returns the max size of the bodies to record,
+// it returns zero when recording bodies is disabled.
+func parseHTTPBodiesConfig(config []string) (int, error) {
+	for _, entry := range config {
+		if !strings.HasPrefix(entry, httpBodiesPrefix) {
+			continue
+		}
+		val, err := strconv.Atoi(entry[len(httpBodiesPrefix):])
+		if err != nil || val <= 0 {
+			return 0, fmt.Errorf("invalid http bodies config %q, expected a positive number", entry)
+		}
+		return val, nil
+	}
+	return 0, nil
+}
+
+func decodeHTTPRequestEvent(payload []byte, maxBodySize int) ([]byte, error) {
+	req, err := httptypes.DecodeRequest(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding http request: %v", err)
+	}
+	ev := httpRequestEvent{
+		Method:        req.Method,
+		Path:          req.URL.Path,
+		Query:         req.URL.RawQuery,
+		ContentType:   req.Header.Get("Content-Type"),
+		ContentLength: req.ContentLength,
+	}
+	ev.Body, ev.BodyTruncated = readEventBody(req.Body, maxBodySize)
+	return json.Marshal(ev)
+}
+
+func decodeHTTPResponseEvent(payload []byte, maxBodySize int) ([]byte, error) {
+	resp, err := httptypes.DecodeResponse(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed decoding http response: %v", err)
+	}
+	ev := httpResponseEvent{
+		Status:        resp.StatusCode,
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: resp.ContentLength,
+	}
+	ev.Body, ev.BodyTruncated = readEventBody(resp.Body, maxBodySize)
+	return json.Marshal(ev)
+}
+
+// readEventBody returns up to maxBodySize bytes of the body, it returns nil when it's disabled.
+// The body of responses of HEAD requests is not present, the content is ignored in this case.
+func readEventBody(body io.ReadCloser, maxBodySize int) (*string, bool) {
+	if maxBodySize <= 0 || body == nil || body == http.NoBody {
+		return nil, false
+	}
+	data, err := io.ReadAll(io.LimitReader(body, int64(maxBodySize)+1))
+	if err != nil || len(data) == 0 {
+		return nil, false
+	}
+	truncated := len(data) > maxBodySize
+	if truncated {
+		data = data[:maxBodySize]
+	}
+	v := string(data)
+	return &v, truncated
+}
+
+// writeOnHTTPEvent writes the request or the response of a http connection as a structured event
+func (p *auditPlugin) writeOnHTTPEvent(pctx plugintypes.Context, eventType eventlogv1.EventType, payload []byte, metadata map[string][]byte) error {
+	maxBodySize, err := parseHTTPBodiesConfig(pctx.PluginConnectionConfig)
+	if err != nil {
+		return err
+	}
+	if metadata == nil {
+		metadata = map[string][]byte{}
+	}
+	var event []byte
+	if eventType == eventlogv1.InputType {
+		metadata[inputFormatMetadataKey] = []byte(inputFormatHTTPRequest)
+		event, err = decodeHTTPRequestEvent(payload, maxBodySize)
+	} else {
+		metadata[outputFormatMetadataKey] = []byte(outputFormatHTTPResponse)
+		event, err = decodeHTTPResponseEvent(payload, maxBodySize)
+	}
+	if err != nil {
+		return err
+	}
+	return p.writeOnReceive(pctx.SID, eventType, event, metadata)
+}
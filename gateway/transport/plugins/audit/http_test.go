@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hoophq/hoop/common/httptypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHTTPBodiesConfig(t *testing.T) {
+	for _, tt := range []struct {
+		msg     string
+		config  []string
+		want    int
+		wantErr string
+	}{
+		{msg: "it should be disabled without config", config: []string{"admin", "capture-rows:10"}},
+		{msg: "it should parse the max size of the bodies", config: []string{"sre", "http-bodies:4096"}, want: 4096},
+		{msg: "it should fail with invalid sizes", config: []string{"http-bodies:0"},
+			wantErr: `invalid http bodies config "http-bodies:0", expected a positive number`},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := parseHTTPBodiesConfig(tt.config)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDecodeHTTPRequestEvent(t *testing.T) {
+	payload := []byte("POST /v1/users?dry-run=true HTTP/1.1\r\nHost: localhost\r\nAuthorization: Bearer secret\r\n" +
+		"Content-Type: application/json\r\nContent-Length: 16\r\n\r\n{\"name\":\"alice\"}")
+	for _, tt := range []struct {
+		msg         string
+		maxBodySize int
+		want        httpRequestEvent
+	}{
+		{
+			msg:  "it should not record the body when it's disabled",
+			want: httpRequestEvent{Method: "POST", Path: "/v1/users", Query: "dry-run=true", ContentType: "application/json", ContentLength: 16},
+		},
+		{
+			msg:         "it should record the body truncated by the max size",
+			maxBodySize: 8,
+			want: httpRequestEvent{Method: "POST", Path: "/v1/users", Query: "dry-run=true", ContentType: "application/json", ContentLength: 16,
+				Body: strPtr(`{"name":`), BodyTruncated: true},
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			data, err := decodeHTTPRequestEvent(payload, tt.maxBodySize)
+			assert.NoError(t, err)
+			assert.NotContains(t, string(data), "secret")
+			var got httpRequestEvent
+			assert.NoError(t, json.Unmarshal(data, &got))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDecodeHTTPResponseEvent(t *testing.T) {
+	data, err := decodeHTTPResponseEvent(httptypes.NewErrorResponse(502, "connection refused"), 1024)
+	assert.NoError(t, err)
+	var got httpResponseEvent
+	assert.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, httpResponseEvent{Status: 502, ContentType: "text/plain; charset=utf-8", ContentLength: 18,
+		Body: strPtr("connection refused")}, got)
+}
+
+func strPtr(v string) *string { return &v }
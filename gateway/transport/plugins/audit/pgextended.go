@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/common/pgtypes"
+	eventlogv1 "github.com/hoophq/hoop/gateway/session/eventlog/v1"
+)
+
+// pgExtendedQuery reassembles the statements of the extended query protocol of a connection.
+// Drivers like pgx, psycopg and jdbc send a statement in distinct packets:
+// Parse (statement) -> Bind (parameters) -> Execute (portal).
+type pgExtendedQuery struct {
+	statements map[string]*pgtypes.Parse
+	portals    map[string]*pgPortal
+}
+
+type pgPortal struct {
+	query  string
+	params []*string
+	// executed is true after the first execution, a portal
+	// is executed many times when the result is fetched in batches
+	executed bool
+}
+
+func newPGExtendedQuery() *pgExtendedQuery {
+	return &pgExtendedQuery{
+		statements: map[string]*pgtypes.Parse{},
+		portals:    map[string]*pgPortal{},
+	}
+}
+
+// process decodes a client packet and returns the query and its parameters
+// when a portal is executed for the first time, otherwise it returns a nil query.
+func (q *pgExtendedQuery) process(payload []byte) ([]byte, []*string, error) {
+	if len(payload) == 0 {
+		return nil, nil, nil
+	}
+	switch pgtypes.PacketType(payload[0]) {
+	case pgtypes.ClientParse, pgtypes.ClientBind, pgtypes.ClientExecute, pgtypes.ClientClose:
+	default:
+		return nil, nil, nil
+	}
+	pkt, err := pgtypes.Decode(bytes.NewReader(payload))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed decoding packet, reason=%v", err)
+	}
+	switch pkt.Type() {
+	case pgtypes.ClientParse:
+		msg, err := pgtypes.DecodeParse(pkt.Frame())
+		if err != nil {
+			return nil, nil, err
+		}
+		q.statements[msg.Statement] = msg
+	case pgtypes.ClientBind:
+		msg, err := pgtypes.DecodeBind(pkt.Frame())
+		if err != nil {
+			return nil, nil, err
+		}
+		stmt, ok := q.statements[msg.Statement]
+		if !ok {
+			log.Debugf("bind refers to an unknown prepared statement %q", msg.Statement)
+			delete(q.portals, msg.Portal)
+			return nil, nil, nil
+		}
+		q.portals[msg.Portal] = &pgPortal{query: stmt.Query, params: msg.ParamValues(stmt.ParamOIDs)}
+	case pgtypes.ClientExecute:
+		msg, err := pgtypes.DecodeExecute(pkt.Frame())
+		if err != nil {
+			return nil, nil, err
+		}
+		portal, ok := q.portals[msg.Portal]
+		if !ok || portal.executed {
+			return nil, nil, nil
+		}
+		portal.executed = true
+		return []byte(portal.query), portal.params, nil
+	case pgtypes.ClientClose:
+		msg, err := pgtypes.DecodeClose(pkt.Frame())
+		if err != nil {
+			return nil, nil, err
+		}
+		switch msg.Type {
+		case 'S':
+			delete(q.statements, msg.Name)
+		case 'P':
+			delete(q.portals, msg.Name)
+		}
+	}
+	return nil, nil, nil
+}
+
+// decodePGParseQuery returns the statement of a parse message, it returns nil when
+// the payload is not a parse message or when it fails decoding it.
+func decodePGParseQuery(payload []byte) []byte {
+	if len(payload) == 0 || pgtypes.PacketType(payload[0]) != pgtypes.ClientParse {
+		return nil
+	}
+	pkt, err := pgtypes.Decode(bytes.NewReader(payload))
+	if err != nil {
+		return nil
+	}
+	msg, err := pgtypes.DecodeParse(pkt.Frame())
+	if err != nil {
+		return nil
+	}
+	return []byte(msg.Query)
+}
+
+// writeOnPGExtendedQuery writes an input event when a statement of the extended query protocol
+// is executed, the parameters are added as metadata of the event and they are kept in the event stream.
+func (p *auditPlugin) writeOnPGExtendedQuery(sessionID, connectionID string, payload []byte, metadata map[string][]byte) error {
+	walLogObj := p.walSessionStore.Get(sessionID)
+	walogm, ok := walLogObj.(*walLogRWMutex)
+	if !ok {
+		return fmt.Errorf("failed obtaining write ahead log for session %v", sessionID)
+	}
+	walogm.mu.Lock()
+	defer walogm.mu.Unlock()
+	extQuery, ok := walogm.pgConnections[connectionID]
+	if !ok {
+		extQuery = newPGExtendedQuery()
+		walogm.pgConnections[connectionID] = extQuery
+	}
+	query, params, err := extQuery.process(payload)
+	if err != nil {
+		log.With("sid", sessionID, "conn", connectionID).Errorf("failed parsing extended query data, err=%v", err)
+		return fmt.Errorf("failed obtaining extended query data, reason=%v", err)
+	}
+	if query == nil {
+		return nil
+	}
+	eventMetadata := map[string][]byte{}
+	for key, val := range metadata {
+		eventMetadata[key] = val
+	}
+	if len(params) > 0 {
+		eventMetadata[eventlogv1.PGParamsMetadataKey], err = json.Marshal(params)
+		if err != nil {
+			return fmt.Errorf("failed encoding extended query parameters, reason=%v", err)
+		}
+	}
+	return walogm.write(eventlogv1.InputType, query, eventMetadata)
+}
+
+// dropConnection removes the state of a client connection
+func (p *auditPlugin) dropConnection(sessionID, connectionID string) {
+	walogm, ok := p.walSessionStore.Get(sessionID).(*walLogRWMutex)
+	if !ok {
+		return
+	}
+	walogm.mu.Lock()
+	delete(walogm.pgConnections, connectionID)
+	delete(walogm.captures, connectionID)
+	walogm.mu.Unlock()
+}
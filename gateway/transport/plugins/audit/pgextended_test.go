@@ -0,0 +1,251 @@
+package audit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoop/common/memory"
+	"github.com/hoophq/hoop/common/pgtypes"
+	pb "github.com/hoophq/hoop/common/proto"
+	pbagent "github.com/hoophq/hoop/common/proto/agent"
+	"github.com/hoophq/hoop/gateway/session/blobstore"
+	eventlogv1 "github.com/hoophq/hoop/gateway/session/eventlog/v1"
+	"github.com/hoophq/hoop/gateway/session/integrity"
+	sessionwal "github.com/hoophq/hoop/gateway/session/wal"
+	"github.com/hoophq/hoop/gateway/storagev2/types"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func cstring(v string) []byte { return append([]byte(v), 0x00) }
+
+func newParsePacket(stmt, query string, oids ...uint32) []byte {
+	frame := append(cstring(stmt), cstring(query)...)
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(oids)))
+	for _, oid := range oids {
+		frame = binary.BigEndian.AppendUint32(frame, oid)
+	}
+	return pgtypes.NewPacket(pgtypes.ClientParse, frame).Encode()
+}
+
+// newBindPacket encodes the parameters in text format when formats is empty,
+// nil parameters are encoded as null
+func newBindPacket(portal, stmt string, formats []int16, params ...[]byte) []byte {
+	frame := append(cstring(portal), cstring(stmt)...)
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(formats)))
+	for _, f := range formats {
+		frame = binary.BigEndian.AppendUint16(frame, uint16(f))
+	}
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(params)))
+	for _, p := range params {
+		if p == nil {
+			frame = binary.BigEndian.AppendUint32(frame, 0xFFFFFFFF)
+			continue
+		}
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(p)))
+		frame = append(frame, p...)
+	}
+	frame = binary.BigEndian.AppendUint16(frame, 0)
+	return pgtypes.NewPacket(pgtypes.ClientBind, frame).Encode()
+}
+
+func newExecutePacket(portal string) []byte {
+	frame := binary.BigEndian.AppendUint32(cstring(portal), 0)
+	return pgtypes.NewPacket(pgtypes.ClientExecute, frame).Encode()
+}
+
+func newClosePacket(typ byte, name string) []byte {
+	return pgtypes.NewPacket(pgtypes.ClientClose, append([]byte{typ}, cstring(name)...)).Encode()
+}
+
+func TestPGExtendedQuery(t *testing.T) {
+	str := func(v string) *string { return &v }
+	int4 := binary.BigEndian.AppendUint32(nil, 42)
+	for _, tt := range []struct {
+		msg        string
+		packets    [][]byte
+		wantQuery  string
+		wantParams []*string
+	}{
+		{
+			msg: "it should return the query with text parameters when the portal is executed",
+			packets: [][]byte{
+				newParsePacket("", "SELECT * FROM users WHERE name = $1 AND email = $2"),
+				newBindPacket("", "", nil, []byte("john"), nil),
+				newExecutePacket(""),
+			},
+			wantQuery:  "SELECT * FROM users WHERE name = $1 AND email = $2",
+			wantParams: []*string{str("john"), nil},
+		},
+		{
+			msg: "it should decode binary parameters based on the type of the statement",
+			packets: [][]byte{
+				newParsePacket("stmt1", "SELECT * FROM users WHERE id = $1 AND name = $2", 23, 25),
+				newBindPacket("portal1", "stmt1", []int16{pgtypes.FormatBinary}, int4, []byte("john")),
+				newExecutePacket("portal1"),
+			},
+			wantQuery:  "SELECT * FROM users WHERE id = $1 AND name = $2",
+			wantParams: []*string{str("42"), str("john")},
+		},
+		{
+			msg: "it should hex encode binary parameters of unknown types",
+			packets: [][]byte{
+				newParsePacket("", "SELECT $1"),
+				newBindPacket("", "", []int16{pgtypes.FormatBinary}, []byte{0xca, 0xfe}),
+				newExecutePacket(""),
+			},
+			wantQuery:  "SELECT $1",
+			wantParams: []*string{str(`\xcafe`)},
+		},
+		{
+			msg: "it should not return the query when the portal is not bound",
+			packets: [][]byte{
+				newParsePacket("", "SELECT 1"),
+				newExecutePacket(""),
+			},
+		},
+		{
+			msg: "it should not return the query when the statement is closed",
+			packets: [][]byte{
+				newParsePacket("stmt1", "SELECT 1"),
+				newClosePacket('S', "stmt1"),
+				newBindPacket("", "stmt1", nil),
+				newExecutePacket(""),
+			},
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			q := newPGExtendedQuery()
+			var gotQuery []byte
+			var gotParams []*string
+			for _, pkt := range tt.packets {
+				query, params, err := q.process(pkt)
+				assert.NoError(t, err)
+				if query != nil {
+					gotQuery, gotParams = query, params
+				}
+			}
+			assert.Equal(t, tt.wantQuery, string(gotQuery))
+			assert.Equal(t, tt.wantParams, gotParams)
+		})
+	}
+}
+
+func TestPGExtendedQueryExecuteOnce(t *testing.T) {
+	q := newPGExtendedQuery()
+	for _, pkt := range [][]byte{newParsePacket("", "SELECT 1"), newBindPacket("", "", nil)} {
+		_, _, err := q.process(pkt)
+		assert.NoError(t, err)
+	}
+	query, _, err := q.process(newExecutePacket(""))
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT 1", string(query))
+
+	// fetching the next rows of a portal executes it again
+	query, _, err = q.process(newExecutePacket(""))
+	assert.NoError(t, err)
+	assert.Nil(t, query)
+}
+
+func TestWriteOnPGExtendedQueryParams(t *testing.T) {
+	startDate := time.Now().UTC()
+	wh := &sessionwal.Header{
+		EventLogVersion: eventlogv1.Version,
+		OrgID:           "org-id",
+		SessionID:       "sid",
+		ConnectionName:  "pg",
+		ConnectionType:  pb.ConnectionTypePostgres.String(),
+		StartDate:       &startDate,
+	}
+	walog, err := sessionwal.OpenWriteHeader(filepath.Join(t.TempDir(), "sid-wal"), wh)
+	assert.NoError(t, err)
+	defer walog.Close()
+	p := &auditPlugin{walSessionStore: memory.New()}
+	p.walSessionStore.Set("sid", &walLogRWMutex{
+		log:           walog,
+		startDate:     startDate.Round(0),
+		chain:         integrity.NewChain(newIntegrityHeader(wh)),
+		pgConnections: map[string]*pgExtendedQuery{},
+	})
+	for _, pkt := range [][]byte{
+		newParsePacket("", "SELECT $1, $2", 23, 25),
+		newBindPacket("", "", nil, []byte("42"), nil),
+		newExecutePacket(""),
+	} {
+		assert.NoError(t, p.writeOnPGExtendedQuery("sid", "conn-1", pkt, nil))
+	}
+
+	// the parameters must be kept in the recording of the session
+	store, err := blobstore.NewFilesystem(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, blobstore.PutRecording(context.Background(), store, walog, wh))
+	r, err := store.Get(context.Background(), blobstore.ObjectKey(wh.OrgID, wh.SessionID))
+	assert.NoError(t, err)
+	defer r.Close()
+	var got []types.SessionEventStream
+	assert.NoError(t, blobstore.ReadRecording(r, func(event types.SessionEventStream) error {
+		got = append(got, event)
+		return nil
+	}))
+	if assert.Len(t, got, 1) && assert.Len(t, got[0], 5) {
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("SELECT $1, $2")), got[0][2])
+		assert.Equal(t, map[string]any{eventlogv1.PGParamsMetadataKey: []any{"42", nil}}, got[0][4])
+	}
+}
+
+func TestOnReceivePGDeniedExtendedQuery(t *testing.T) {
+	startDate := time.Now().UTC()
+	wh := &sessionwal.Header{
+		EventLogVersion: eventlogv1.Version,
+		OrgID:           "org-id",
+		SessionID:       "sid",
+		ConnectionName:  "pg",
+		ConnectionType:  pb.ConnectionTypePostgres.String(),
+		StartDate:       &startDate,
+	}
+	walog, err := sessionwal.OpenWriteHeader(filepath.Join(t.TempDir(), "sid-wal"), wh)
+	assert.NoError(t, err)
+	defer walog.Close()
+	p := &auditPlugin{walSessionStore: memory.New()}
+	p.walSessionStore.Set("sid", &walLogRWMutex{
+		log:           walog,
+		startDate:     startDate.Round(0),
+		chain:         integrity.NewChain(newIntegrityHeader(wh)),
+		pgConnections: map[string]*pgExtendedQuery{},
+	})
+	errMsg := []byte(`statement blocked by access control rule "deny-statement:DROP"`)
+	for _, pkt := range []*pb.Packet{
+		{Payload: newParsePacket("", "SELECT 1"), Spec: map[string][]byte{}},
+		{Payload: newBindPacket("", "", nil), Spec: map[string][]byte{}},
+		{Payload: newExecutePacket(""), Spec: map[string][]byte{}},
+		{Payload: newParsePacket("", "DROP TABLE users"), Spec: map[string][]byte{
+			pb.SpecPluginAccessControlDeniedKey: errMsg, pb.SpecPluginAccessControlDropKey: []byte("true")}},
+		{Payload: newBindPacket("", "", nil), Spec: map[string][]byte{pb.SpecPluginAccessControlDropKey: []byte("true")}},
+		{Payload: newExecutePacket(""), Spec: map[string][]byte{pb.SpecPluginAccessControlDropKey: []byte("true")}},
+	} {
+		pkt.Type = pbagent.PGConnectionWrite
+		pkt.Spec[pb.SpecClientConnectionID] = []byte("conn-1")
+		_, err := p.OnReceive(plugintypes.Context{SID: "sid"}, pkt)
+		assert.NoError(t, err)
+	}
+
+	store, err := blobstore.NewFilesystem(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, blobstore.PutRecording(context.Background(), store, walog, wh))
+	r, err := store.Get(context.Background(), blobstore.ObjectKey(wh.OrgID, wh.SessionID))
+	assert.NoError(t, err)
+	defer r.Close()
+	var got []string
+	assert.NoError(t, blobstore.ReadRecording(r, func(event types.SessionEventStream) error {
+		data, _ := base64.StdEncoding.DecodeString(event[2].(string))
+		got = append(got, fmt.Sprintf("%v:%s", event[1], data))
+		return nil
+	}))
+	// the denied statement is recorded, the dropped messages must not execute the previous statement
+	assert.Equal(t, []string{"i:SELECT 1", "i:DROP TABLE users", "e:" + string(errMsg)}, got)
+}
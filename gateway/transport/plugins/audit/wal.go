@@ -1,18 +1,25 @@
 package audit
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"io"
+	"maps"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/hoophq/hoop/common/log"
 	pb "github.com/hoophq/hoop/common/proto"
 	"github.com/hoophq/hoop/common/proto/spectypes"
+	"github.com/hoophq/hoop/gateway/appconfig"
 	pgsession "github.com/hoophq/hoop/gateway/pgrest/session"
+	"github.com/hoophq/hoop/gateway/session/blobstore"
 	eventlogv1 "github.com/hoophq/hoop/gateway/session/eventlog/v1"
+	"github.com/hoophq/hoop/gateway/session/integrity"
 	sessionwal "github.com/hoophq/hoop/gateway/session/wal"
 	"github.com/hoophq/hoop/gateway/storagev2"
 	sessionstorage "github.com/hoophq/hoop/gateway/storagev2/session"
@@ -26,10 +33,37 @@ const (
 	eventLogTypeName string = "_footer_error"
 )
 
+// sessionLabelSpecKeys are the packet specs stored as labels of the session
+var sessionLabelSpecKeys = []string{
+	pb.SpecKubernetesNamespace,
+	pb.SpecKubernetesPod,
+	pb.SpecKubernetesService,
+}
+
 type walLogRWMutex struct {
 	log        *sessionwal.WalLog
 	mu         sync.RWMutex
 	folderName string
+	startDate  time.Time
+	chain      *integrity.Chain
+	// pgConnections keeps the state of the extended query protocol by connection id
+	pgConnections map[string]*pgExtendedQuery
+	// captures keeps the state of the result sets capture by connection id
+	captures map[string]*resultCapture
+	// labels are sent by the agent and merged with the labels of the session when it's closed
+	labels map[string]string
+}
+
+// write adds the event to the log with the chained hash of its content.
+// Events without payload are not part of the chain because they are not stored.
+func (w *walLogRWMutex) write(eventType eventlogv1.EventType, event []byte, metadata map[string][]byte) error {
+	eventTime := time.Now().UTC()
+	ev := eventlogv1.New(eventTime, eventType, event, metadata)
+	if len(event) > 0 {
+		hash := w.chain.Next(eventTime.Sub(w.startDate).Seconds(), byte(eventType), event)
+		ev.WithMetadata(integrity.MetadataKey, []byte(hash))
+	}
+	return w.log.Write(ev)
 }
 
 func (p *auditPlugin) writeOnConnect(pctx plugintypes.Context) error {
@@ -39,7 +73,7 @@ func (p *auditPlugin) writeOnConnect(pctx plugintypes.Context) error {
 		_ = os.RemoveAll(walFolder)
 	}
 
-	walog, err := sessionwal.OpenWriteHeader(walFolder, &sessionwal.Header{
+	wh := &sessionwal.Header{
 		EventLogVersion: eventlogv1.Version,
 		OrgID:           pctx.OrgID,
 		SessionID:       pctx.SID,
@@ -53,14 +87,37 @@ func (p *auditPlugin) writeOnConnect(pctx plugintypes.Context) error {
 		Labels:          pctx.ParamsData.GetString("labels"),
 		Status:          pctx.ParamsData.GetString("status"),
 		StartDate:       pctx.ParamsData.GetTime("start_date"),
-	})
+	}
+	walog, err := sessionwal.OpenWriteHeader(walFolder, wh)
 	if err != nil {
 		return fmt.Errorf("failed opening wal file, err=%v", err)
 	}
-	p.walSessionStore.Set(pctx.SID, &walLogRWMutex{walog, sync.RWMutex{}, walFolder})
+	p.walSessionStore.Set(pctx.SID, &walLogRWMutex{
+		log:        walog,
+		mu:         sync.RWMutex{},
+		folderName: walFolder,
+		// strip the monotonic clock, the elapsed time must be
+		// the same when it's computed from the stored header
+		startDate:     wh.StartDate.Round(0),
+		chain:         integrity.NewChain(newIntegrityHeader(wh)),
+		pgConnections: map[string]*pgExtendedQuery{},
+		captures:      map[string]*resultCapture{},
+		labels:        map[string]string{},
+	})
 	return nil
 }
 
+func newIntegrityHeader(wh *sessionwal.Header) integrity.Header {
+	return integrity.Header{
+		OrgID:          wh.OrgID,
+		SessionID:      wh.SessionID,
+		UserID:         wh.UserID,
+		ConnectionName: wh.ConnectionName,
+		ConnectionType: wh.ConnectionType,
+		Verb:           wh.Verb,
+	}
+}
+
 func (p *auditPlugin) writeOnReceive(sessionID string, eventType eventlogv1.EventType, event []byte, metadata map[string][]byte) error {
 	walLogObj := p.walSessionStore.Get(sessionID)
 	walogm, ok := walLogObj.(*walLogRWMutex)
@@ -69,7 +126,50 @@ func (p *auditPlugin) writeOnReceive(sessionID string, eventType eventlogv1.Even
 	}
 	walogm.mu.Lock()
 	defer walogm.mu.Unlock()
-	return walogm.log.Write(eventlogv1.New(time.Now().UTC(), eventType, event, metadata))
+	return walogm.write(eventType, event, metadata)
+}
+
+// writeLabels keeps the session labels sent by the agent in the spec of the packet
+func (p *auditPlugin) writeLabels(sessionID string, spec map[string][]byte) {
+	labels := map[string]string{}
+	for _, key := range sessionLabelSpecKeys {
+		if val, ok := spec[key]; ok {
+			labels[key] = string(val)
+		}
+	}
+	if len(labels) == 0 {
+		return
+	}
+	walogm, ok := p.walSessionStore.Get(sessionID).(*walLogRWMutex)
+	if !ok {
+		return
+	}
+	walogm.mu.Lock()
+	maps.Copy(walogm.labels, labels)
+	walogm.mu.Unlock()
+}
+
+// writeTerminalSize keeps the size of the terminal when the session starts as a label
+// of the session. The payload of the resize packets is in the format <rows>,<cols>,<x>,<y>
+func (p *auditPlugin) writeTerminalSize(sessionID string, payload []byte) {
+	size := strings.Split(string(payload), ",")
+	if len(size) != 4 {
+		return
+	}
+	for _, val := range size[:2] {
+		if _, err := strconv.Atoi(val); err != nil {
+			return
+		}
+	}
+	walogm, ok := p.walSessionStore.Get(sessionID).(*walLogRWMutex)
+	if !ok {
+		return
+	}
+	walogm.mu.Lock()
+	defer walogm.mu.Unlock()
+	if _, ok := walogm.labels[types.SessionLabelTerminalSize]; !ok {
+		walogm.labels[types.SessionLabelTerminalSize] = size[0] + "," + size[1]
+	}
 }
 
 func (p *auditPlugin) dropWalLog(sid string) {
@@ -97,7 +197,7 @@ func (p *auditPlugin) writeOnClose(pctx plugintypes.Context, errMsg error) error
 	// we could add an attribute to have the last message
 	// propagated as metadata instead inside the stream
 	if errMsg != nil && errMsg != io.EOF {
-		err := walogm.log.Write(eventlogv1.New(time.Now().UTC(), eventlogv1.ErrorType, []byte(errMsg.Error()), nil))
+		err := walogm.write(eventlogv1.ErrorType, []byte(errMsg.Error()), nil)
 		if err != nil {
 			log.With("sid", pctx.SID).Warnf("failed writing end error message, err=%v", err)
 		}
@@ -161,20 +261,34 @@ func (p *auditPlugin) writeOnClose(pctx plugintypes.Context, errMsg error) error
 			return nil
 		}
 
-		// truncate when event is greater than 5000 bytes for tcp type
-		// it avoids auditing blob content for TCP (files, images, etc)
-		eventStream := p.truncateTCPEventStream(ev.Payload, wh.ConnectionType)
-		eventStreamList = append(eventStreamList, types.SessionEventStream{
+		// truncate when event is greater than 5000 bytes for tcp and ssh types
+		// it avoids auditing blob content for TCP and SSH (files, images, etc)
+		eventStream := integrity.TruncatePayload(wh.ConnectionType, ev.Payload)
+		event := types.SessionEventStream{
 			ev.EventTime.Sub(*wh.StartDate).Seconds(),
 			string(ev.EventType),
 			base64.StdEncoding.EncodeToString(eventStream),
-		})
+			string(ev.GetMetadata(integrity.MetadataKey)),
+		}
+		if metadata := ev.StreamMetadata(); metadata != nil {
+			event = append(event, metadata)
+		}
+		eventStreamList = append(eventStreamList, event)
 		return nil
 	})
 	if err != nil {
 		return err
 	}
 
+	// store the full recording, the session in the database could be truncated
+	if store := blobstore.Default(); store != nil {
+		if err := blobstore.PutRecording(context.Background(), store, walogm.log, wh); err != nil {
+			err = fmt.Errorf("failed storing session recording, reason=%v", err)
+			_ = walogm.log.Write(eventlogv1.NewCommitError(time.Now().UTC(), err.Error()))
+			return err
+		}
+	}
+
 	storageContext := storagev2.NewContext(wh.UserID, wh.OrgID)
 	session, err := sessionstorage.FindOne(storageContext, wh.SessionID)
 	if err != nil || session == nil {
@@ -189,8 +303,14 @@ func (p *auditPlugin) writeOnClose(pctx plugintypes.Context, errMsg error) error
 	if err != nil {
 		log.Warnf("failed parsing session metrics to map, reason=%v", err)
 	}
+	maps.Copy(session.Labels, walogm.labels)
 	session.Labels["processed-by"] = "plugin-audit"
 	session.Labels["truncated"] = fmt.Sprintf("%v", truncated)
+	// the truncation point is signed, the stored events are verified against it
+	sessionIntegrity := integrity.Sign(walogm.chain, appconfig.Get().SessionSigningKey())
+	if truncated {
+		sessionIntegrity = integrity.SignTruncated(walogm.chain, appconfig.Get().SessionSigningKey(), eventStreamList)
+	}
 	err = pgsession.New().Upsert(storageContext, types.Session{
 		ID:               wh.SessionID,
 		OrgID:            wh.OrgID,
@@ -207,6 +327,7 @@ func (p *auditPlugin) writeOnClose(pctx plugintypes.Context, errMsg error) error
 		Metrics:          session.Metrics,
 		NonIndexedStream: types.SessionNonIndexedEventStreamList{"stream": eventStreamList},
 		EventSize:        metrics.EventSize,
+		Integrity:        sessionIntegrity,
 		StartSession:     *wh.StartDate,
 		EndSession:       &endDate,
 	})
@@ -220,10 +341,3 @@ func (p *auditPlugin) writeOnClose(pctx plugintypes.Context, errMsg error) error
 	}
 	return err
 }
-
-func (p *auditPlugin) truncateTCPEventStream(eventStream []byte, connType string) []byte {
-	if len(eventStream) > 5000 && connType == pb.ConnectionTypeTCP.String() {
-		return eventStream[0:5000]
-	}
-	return eventStream
-}
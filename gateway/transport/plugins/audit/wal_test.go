@@ -0,0 +1,96 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/hoophq/hoop/common/memory"
+	pb "github.com/hoophq/hoop/common/proto"
+	eventlogv1 "github.com/hoophq/hoop/gateway/session/eventlog/v1"
+	"github.com/hoophq/hoop/gateway/session/integrity"
+	sessionwal "github.com/hoophq/hoop/gateway/session/wal"
+	"github.com/hoophq/hoop/gateway/storagev2/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteLabels(t *testing.T) {
+	p := &auditPlugin{walSessionStore: memory.New()}
+	walogm := &walLogRWMutex{labels: map[string]string{}}
+	p.walSessionStore.Set("sid", walogm)
+
+	p.writeLabels("sid", map[string][]byte{
+		pb.SpecGatewaySessionID:    []byte("sid"),
+		pb.SpecKubernetesNamespace: []byte("default"),
+		pb.SpecKubernetesPod:       []byte("api-5d8f7"),
+	})
+	p.writeLabels("sid", map[string][]byte{pb.SpecKubernetesPod: []byte("api-7c9b2")})
+	// it should ignore sessions without a write ahead log
+	p.writeLabels("unknown", map[string][]byte{pb.SpecKubernetesPod: []byte("api-5d8f7")})
+
+	assert.Equal(t, map[string]string{
+		pb.SpecKubernetesNamespace: "default",
+		pb.SpecKubernetesPod:       "api-7c9b2",
+	}, walogm.labels)
+}
+
+func TestWriteTerminalSize(t *testing.T) {
+	p := &auditPlugin{walSessionStore: memory.New()}
+	walogm := &walLogRWMutex{labels: map[string]string{}}
+	p.walSessionStore.Set("sid", walogm)
+
+	p.writeTerminalSize("sid", []byte("invalid"))
+	p.writeTerminalSize("sid", []byte("40,120,0,0"))
+	// it should keep the size of the terminal when the session starts
+	p.writeTerminalSize("sid", []byte("50,200,0,0"))
+
+	assert.Equal(t, map[string]string{types.SessionLabelTerminalSize: "40,120"}, walogm.labels)
+}
+
+func TestWriteIntegrityTruncatedEvents(t *testing.T) {
+	startDate := time.Now().UTC()
+	wh := &sessionwal.Header{
+		OrgID:          "org-id",
+		SessionID:      "sid",
+		ConnectionName: "tcp",
+		ConnectionType: pb.ConnectionTypeTCP.String(),
+		StartDate:      &startDate,
+	}
+	walog, err := sessionwal.OpenWriteHeader(t.TempDir(), wh)
+	assert.NoError(t, err)
+	defer walog.Close()
+	walogm := &walLogRWMutex{
+		log:       walog,
+		startDate: startDate.Round(0),
+		chain:     integrity.NewChain(newIntegrityHeader(wh)),
+	}
+	blob := bytes.Repeat([]byte("a"), integrity.MaxTCPPayloadSize+100)
+	assert.NoError(t, walogm.write(eventlogv1.InputType, blob, nil))
+	assert.NoError(t, walogm.write(eventlogv1.OutputType, []byte("ok"), nil))
+
+	// it should verify the events stored in the database and in the full recording
+	var stored, recording []types.SessionEventStream
+	_, err = walog.ReadFull(func(data []byte) error {
+		ev, err := eventlogv1.Decode(data)
+		if err != nil {
+			return err
+		}
+		elapsed := ev.EventTime.Sub(*wh.StartDate).Seconds()
+		hash := string(ev.GetMetadata(integrity.MetadataKey))
+		stored = append(stored, types.SessionEventStream{elapsed, string(ev.EventType),
+			base64.StdEncoding.EncodeToString(integrity.TruncatePayload(wh.ConnectionType, ev.Payload)), hash})
+		recording = append(recording, types.SessionEventStream{elapsed, string(ev.EventType),
+			base64.StdEncoding.EncodeToString(ev.Payload), hash})
+		return nil
+	})
+	assert.NoError(t, err)
+	_, privKey, _ := ed25519.GenerateKey(nil)
+	si := integrity.Sign(walogm.chain, privKey)
+	for _, events := range [][]types.SessionEventStream{stored, recording} {
+		got := integrity.Verify(newIntegrityHeader(wh), events, si, privKey.Public().(ed25519.PublicKey))
+		assert.True(t, got.Verified, got.Reason)
+		assert.Equal(t, 2, got.EventsCount)
+	}
+}
@@ -1,8 +1,16 @@
 package dlp
 
 import (
+	"sync"
+
+	"github.com/hoophq/hoop/common/dlp"
 	"github.com/hoophq/hoop/common/license"
+	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/common/memory"
 	pb "github.com/hoophq/hoop/common/proto"
+	pbagent "github.com/hoophq/hoop/common/proto/agent"
+	pbclient "github.com/hoophq/hoop/common/proto/client"
+	"github.com/hoophq/hoop/common/proto/spectypes"
 	"github.com/hoophq/hoop/gateway/appconfig"
 	"github.com/hoophq/hoop/gateway/storagev2/types"
 	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
@@ -10,9 +18,22 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-type plugin struct{}
+type plugin struct {
+	sessionStore memory.Store
+}
 
-func New() *plugin                                      { return &plugin{} }
+// sessionMasking is the state of the builtin provider for a session
+type sessionMasking struct {
+	mu sync.Mutex
+	// engine is nil when none of the info types are supported
+	engine      *dlp.Engine
+	connections map[string]*connMasking
+	// the output streams of exec sessions by packet type
+	outputs map[string]*dlp.StreamRedactor
+	flushed bool
+}
+
+func New() *plugin                                      { return &plugin{sessionStore: memory.New()} }
 func (p *plugin) Name() string                          { return plugintypes.PluginDLPName }
 func (p *plugin) OnStartup(_ plugintypes.Context) error { return nil }
 func (p *plugin) OnUpdate(_, _ *types.Plugin) error     { return nil }
@@ -21,10 +42,182 @@ func (p *plugin) OnConnect(ctx plugintypes.Context) error {
 	if ctx.OrgLicenseType == license.OSSType && isDlpSet {
 		return status.Error(codes.FailedPrecondition, license.ErrDataMaskingUnsupported.Error())
 	}
+	return validateProtocolSupport(appconfig.Get().DlpProvider(), ctx)
+}
+
+// validateProtocolSupport refuses the sessions of protocols that the builtin provider doesn't
+// redact when the connection has info types configured, the responses of these protocols
+// would be sent to the client without being redacted. The output of exec sessions is redacted.
+func validateProtocolSupport(provider string, ctx plugintypes.Context) error {
+	if provider != dlp.ProviderBuiltin || ctx.ClientVerb == pb.ClientVerbExec || dlp.NewEngine(ctx.PluginConnectionConfig) == nil {
+		return nil
+	}
+	switch connType := pb.ToConnectionType(ctx.ConnectionType, ctx.ConnectionSubType); connType {
+	case pb.ConnectionTypeMSSQL, pb.ConnectionTypeMongoDB, pb.ConnectionTypeRedis, pb.ConnectionTypeOracleDB,
+		pb.ConnectionTypeTCP, pb.ConnectionTypeHTTP, pb.ConnectionTypeSSH:
+		return status.Errorf(codes.FailedPrecondition,
+			"data masking is not supported for %v connections, remove the info types of the connection to use it", connType)
+	}
 	return nil
 }
-func (p *plugin) OnReceive(_ plugintypes.Context, _ *pb.Packet) (*plugintypes.ConnectResponse, error) {
+
+// OnReceive redacts the output of sessions when the builtin provider is enabled.
+// The payloads of stdout, stderr and the rows of postgres and mysql connections
+// are redacted in the gateway, other providers redact the data in the agent.
+// The responses of other databases are marked as not redacted, they must not be stored.
+func (p *plugin) OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plugintypes.ConnectResponse, error) {
+	if appconfig.Get().DlpProvider() != dlp.ProviderBuiltin {
+		return nil, nil
+	}
+	p.redact(pctx, pkt)
 	return nil, nil
 }
-func (p *plugin) OnDisconnect(_ plugintypes.Context, _ error) error { return nil }
-func (p *plugin) OnShutdown()                                       {}
+
+// redact redacts the packet with the builtin provider, the summary of the redaction is added to the spec
+func (p *plugin) redact(pctx plugintypes.Context, pkt *pb.Packet) {
+	switch pb.PacketType(pkt.Type) {
+	case pbclient.SessionClose:
+		p.sessionStore.Del(pctx.SID)
+		return
+	case pbclient.WriteStdout, pbclient.WriteStderr,
+		pbclient.PGConnectionWrite, pbclient.MySQLConnectionWrite,
+		pbagent.PGConnectionWrite, pbagent.MySQLConnectionWrite,
+		pbagent.TCPConnectionClose, pbclient.TCPConnectionClose,
+		pbclient.MSSQLConnectionWrite, pbclient.MongoDBConnectionWrite,
+		pbclient.RedisConnectionWrite, pbclient.OracleDBConnectionWrite:
+	default:
+		return
+	}
+	sm, ok := p.sessionStore.Get(pctx.SID).(*sessionMasking)
+	if !ok {
+		sm = &sessionMasking{
+			engine:      dlp.NewEngine(pctx.PluginConnectionConfig),
+			connections: map[string]*connMasking{},
+			outputs:     map[string]*dlp.StreamRedactor{},
+		}
+		p.sessionStore.Set(pctx.SID, sm)
+	}
+	if sm.engine == nil {
+		return
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	connectionID := string(pkt.Spec[pb.SpecClientConnectionID])
+	summary := &dlp.Summary{}
+	switch pb.PacketType(pkt.Type) {
+	case pbagent.TCPConnectionClose, pbclient.TCPConnectionClose:
+		delete(sm.connections, connectionID)
+		return
+	case pbclient.MSSQLConnectionWrite, pbclient.MongoDBConnectionWrite,
+		pbclient.RedisConnectionWrite, pbclient.OracleDBConnectionWrite:
+		if pkt.Spec == nil {
+			pkt.Spec = map[string][]byte{}
+		}
+		pkt.Spec[spectypes.DataMaskingUnsupportedKey] = []byte("1")
+		return
+	case pbclient.WriteStdout, pbclient.WriteStderr:
+		pkt.Payload = sm.redactOutput(pctx.ClientVerb, pkt, summary)
+	case pbagent.PGConnectionWrite, pbagent.MySQLConnectionWrite:
+		// the packets denied or dropped by the access control plugin are not sent to the server
+		_, isDenied := pkt.Spec[pb.SpecPluginAccessControlDeniedKey]
+		_, isDropped := pkt.Spec[pb.SpecPluginAccessControlDropKey]
+		if !isDenied && !isDropped {
+			sm.connection(connectionID, pkt).onClientPacket(pkt.Payload)
+		}
+		return
+	case pbclient.PGConnectionWrite, pbclient.MySQLConnectionWrite:
+		payload, err := sm.connection(connectionID, pkt).redact(pkt.Payload, summary)
+		if err != nil {
+			log.With("sid", pctx.SID, "conn", connectionID).
+				Warnf("failed decoding rows, redacting raw payloads for this connection, reason=%v", err)
+			summary.SetError(err)
+		}
+		pkt.Payload = payload
+	}
+	if summary.IsEmpty() {
+		return
+	}
+	info := summary.DataMaskingInfo()
+	// the agent reports the transformations of column masking policies
+	if agentInfoEnc, ok := pkt.Spec[spectypes.DataMaskingInfoKey]; ok {
+		if agentInfo, err := spectypes.Decode(agentInfoEnc); err == nil {
+			info.Items = append(agentInfo.Items, info.Items...)
+		}
+	}
+	infoEnc, err := info.Encode()
+	if err != nil {
+		log.With("sid", pctx.SID).Warnf("failed encoding data masking info, reason=%v", err)
+		return
+	}
+	if pkt.Spec == nil {
+		pkt.Spec = map[string][]byte{}
+	}
+	pkt.Spec[spectypes.DataMaskingInfoKey] = infoEnc
+}
+
+// redactOutput holds the last line of the output of exec sessions, the findings split across
+// packets are redacted. The output of interactive sessions is redacted per packet, it must be
+// rendered as it arrives.
+func (s *sessionMasking) redactOutput(verb string, pkt *pb.Packet, summary *dlp.Summary) []byte {
+	if verb != pb.ClientVerbExec || s.flushed {
+		return s.engine.Redact(pkt.Payload, summary)
+	}
+	output, ok := s.outputs[pkt.Type]
+	if !ok {
+		output = s.engine.NewStreamRedactor()
+		s.outputs[pkt.Type] = output
+	}
+	return output.Redact(pkt.Payload, summary)
+}
+
+// OnFlush returns the output held for the session, it's called before the agent closes it
+func (p *plugin) OnFlush(pctx plugintypes.Context) []*pb.Packet {
+	sm, ok := p.sessionStore.Get(pctx.SID).(*sessionMasking)
+	if !ok || sm.engine == nil {
+		return nil
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.flushed = true
+	var packets []*pb.Packet
+	for _, pktType := range []string{pbclient.WriteStdout, pbclient.WriteStderr} {
+		output, ok := sm.outputs[pktType]
+		if !ok {
+			continue
+		}
+		summary := &dlp.Summary{}
+		payload := output.Flush(summary)
+		if len(payload) == 0 {
+			continue
+		}
+		pkt := &pb.Packet{Type: pktType, Payload: payload, Spec: map[string][]byte{pb.SpecGatewaySessionID: []byte(pctx.SID)}}
+		if !summary.IsEmpty() {
+			if infoEnc, err := summary.DataMaskingInfo().Encode(); err == nil {
+				pkt.Spec[spectypes.DataMaskingInfoKey] = infoEnc
+			}
+		}
+		packets = append(packets, pkt)
+	}
+	sm.outputs = nil
+	return packets
+}
+
+func (s *sessionMasking) connection(connectionID string, pkt *pb.Packet) *connMasking {
+	conn, ok := s.connections[connectionID]
+	if !ok {
+		connType := pb.ConnectionTypePostgres
+		switch pb.PacketType(pkt.Type) {
+		case pbagent.MySQLConnectionWrite, pbclient.MySQLConnectionWrite:
+			connType = pb.ConnectionTypeMySQL
+		}
+		conn = newConnMasking(s.engine, connType)
+		s.connections[connectionID] = conn
+	}
+	return conn
+}
+
+func (p *plugin) OnDisconnect(pctx plugintypes.Context, _ error) error {
+	p.sessionStore.Del(pctx.SID)
+	return nil
+}
+func (p *plugin) OnShutdown() {}
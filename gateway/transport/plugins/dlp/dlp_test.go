@@ -0,0 +1,132 @@
+package dlp
+
+import (
+	"testing"
+
+	"github.com/hoophq/hoop/common/dlp"
+	pb "github.com/hoophq/hoop/common/proto"
+	pbclient "github.com/hoophq/hoop/common/proto/client"
+	"github.com/hoophq/hoop/common/proto/spectypes"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactPacket(t *testing.T) {
+	for _, tt := range []struct {
+		msg             string
+		infoTypes       []string
+		pktType         string
+		payload         string
+		wantPayload     string
+		wantUnsupported bool
+	}{
+		{
+			msg:         "it should redact the output of the session",
+			infoTypes:   []string{"EMAIL_ADDRESS"},
+			pktType:     pbclient.WriteStdout,
+			payload:     "john@example.com",
+			wantPayload: "[EMAIL_ADDRESS]",
+		},
+		{
+			msg:             "it should mark the responses of databases that are not redacted",
+			infoTypes:       []string{"EMAIL_ADDRESS"},
+			pktType:         pbclient.MSSQLConnectionWrite,
+			payload:         "john@example.com",
+			wantPayload:     "john@example.com",
+			wantUnsupported: true,
+		},
+		{
+			msg:         "it should not mark the responses when the info types are not supported",
+			infoTypes:   []string{"UNKNOWN"},
+			pktType:     pbclient.MongoDBConnectionWrite,
+			payload:     "john@example.com",
+			wantPayload: "john@example.com",
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			p := New()
+			pkt := &pb.Packet{Type: tt.pktType, Payload: []byte(tt.payload)}
+			p.redact(plugintypes.Context{SID: "sid", PluginConnectionConfig: tt.infoTypes}, pkt)
+			assert.Equal(t, tt.wantPayload, string(pkt.Payload))
+			_, unsupported := pkt.Spec[spectypes.DataMaskingUnsupportedKey]
+			assert.Equal(t, tt.wantUnsupported, unsupported)
+		})
+	}
+}
+
+func TestRedactExecOutput(t *testing.T) {
+	p := New()
+	pctx := plugintypes.Context{SID: "sid", ClientVerb: pb.ClientVerbExec, PluginConnectionConfig: []string{"EMAIL_ADDRESS"}}
+	var stdout []byte
+	for _, chunk := range []string{"email: john@exa", "mple.com\nlast: mary@", "example.com"} {
+		pkt := &pb.Packet{Type: pbclient.WriteStdout, Payload: []byte(chunk)}
+		p.redact(pctx, pkt)
+		stdout = append(stdout, pkt.Payload...)
+	}
+	// it should redact the findings split across packets
+	assert.Equal(t, "email: [EMAIL_ADDRESS]\n", string(stdout))
+
+	packets := p.OnFlush(pctx)
+	if assert.Len(t, packets, 1) {
+		assert.Equal(t, pbclient.WriteStdout, packets[0].Type)
+		assert.Equal(t, "last: [EMAIL_ADDRESS]", string(packets[0].Payload))
+		assert.Contains(t, packets[0].Spec, spectypes.DataMaskingInfoKey)
+
+		// the flushed packets are executed by the plugins before being sent
+		p.redact(pctx, packets[0])
+		assert.Equal(t, "last: [EMAIL_ADDRESS]", string(packets[0].Payload))
+	}
+}
+
+func TestValidateProtocolSupport(t *testing.T) {
+	for _, tt := range []struct {
+		msg       string
+		provider  string
+		verb      string
+		connType  string
+		subtype   string
+		infoTypes []string
+		wantErr   string
+	}{
+		{msg: "it should refuse mssql sessions", provider: dlp.ProviderBuiltin, subtype: "mssql",
+			infoTypes: []string{"EMAIL_ADDRESS"}, wantErr: "data masking is not supported for mssql connections"},
+		{msg: "it should refuse mongodb sessions", provider: dlp.ProviderBuiltin, subtype: "mongodb",
+			infoTypes: []string{"EMAIL_ADDRESS"}, wantErr: "data masking is not supported for mongodb connections"},
+		{msg: "it should refuse redis sessions", provider: dlp.ProviderBuiltin, subtype: "redis",
+			infoTypes: []string{"EMAIL_ADDRESS"}, wantErr: "data masking is not supported for redis connections"},
+		{msg: "it should refuse oracle sessions", provider: dlp.ProviderBuiltin, subtype: "oracledb",
+			infoTypes: []string{"EMAIL_ADDRESS"}, wantErr: "data masking is not supported for oracledb connections"},
+		{msg: "it should refuse tcp sessions", provider: dlp.ProviderBuiltin, connType: "application", subtype: "tcp",
+			infoTypes: []string{"EMAIL_ADDRESS"}, wantErr: "data masking is not supported for tcp connections"},
+		{msg: "it should refuse http sessions", provider: dlp.ProviderBuiltin, connType: "application", subtype: "http",
+			infoTypes: []string{"EMAIL_ADDRESS"}, wantErr: "data masking is not supported for http connections"},
+		{msg: "it should refuse ssh sessions", provider: dlp.ProviderBuiltin, connType: "application", subtype: "ssh",
+			infoTypes: []string{"EMAIL_ADDRESS"}, wantErr: "data masking is not supported for ssh connections"},
+		{msg: "it should accept postgres sessions", provider: dlp.ProviderBuiltin, subtype: "postgres",
+			infoTypes: []string{"EMAIL_ADDRESS"}},
+		{msg: "it should accept exec sessions, the output is redacted", provider: dlp.ProviderBuiltin, verb: pb.ClientVerbExec,
+			subtype: "mssql", infoTypes: []string{"EMAIL_ADDRESS"}},
+		{msg: "it should accept sessions without supported info types", provider: dlp.ProviderBuiltin, subtype: "mssql",
+			infoTypes: []string{"UNKNOWN"}},
+		{msg: "it should accept sessions of other providers", provider: "mspresidio", subtype: "mssql",
+			infoTypes: []string{"EMAIL_ADDRESS"}},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			verb := tt.verb
+			if verb == "" {
+				verb = pb.ClientVerbConnect
+			}
+			connType := tt.connType
+			if connType == "" {
+				connType = "database"
+			}
+			err := validateProtocolSupport(tt.provider, plugintypes.Context{ClientVerb: verb,
+				ConnectionType: connType, ConnectionSubType: tt.subtype, PluginConnectionConfig: tt.infoTypes})
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
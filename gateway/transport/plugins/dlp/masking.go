@@ -0,0 +1,49 @@
+package dlp
+
+import (
+	"github.com/hoophq/hoop/common/dlp"
+	pb "github.com/hoophq/hoop/common/proto"
+)
+
+// connMasking redacts the rows sent by the database to a client connection
+type connMasking struct {
+	engine *dlp.Engine
+	rows   *dlp.RowRewriter
+	// fallback is true when the stream could not be decoded, the payloads
+	// are redacted as raw data from that point, breaking the protocol
+	// of the connection instead of leaking sensitive data.
+	fallback bool
+}
+
+func newConnMasking(engine *dlp.Engine, connType pb.ConnectionType) *connMasking {
+	c := &connMasking{engine: engine}
+	c.rows = dlp.NewRowRewriter(connType, c)
+	return c
+}
+
+func (c *connMasking) OnColumns(_ []dlp.Column) {}
+func (c *connMasking) TransformRow(values [][]byte, summary *dlp.Summary) {
+	for i, val := range values {
+		if val != nil {
+			values[i] = c.engine.Redact(val, summary)
+		}
+	}
+}
+
+// onClientPacket inspects the packets sent by the client to the database
+func (c *connMasking) onClientPacket(payload []byte) { c.rows.OnClientPacket(payload) }
+
+// redact returns the complete protocol messages of the payload with the values of the rows redacted,
+// incomplete messages are returned in the next calls.
+func (c *connMasking) redact(payload []byte, summary *dlp.Summary) ([]byte, error) {
+	if c.fallback {
+		return c.engine.Redact(payload, summary), nil
+	}
+	out, err := c.rows.Rewrite(payload, summary)
+	if err != nil {
+		c.fallback = true
+		out = append(out, c.engine.Redact(c.rows.Flush(), summary)...)
+		return out, err
+	}
+	return out, nil
+}
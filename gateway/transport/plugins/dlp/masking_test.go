@@ -0,0 +1,110 @@
+package dlp
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/hoophq/hoop/common/dlp"
+	"github.com/hoophq/hoop/common/mysqltypes"
+	"github.com/hoophq/hoop/common/pgtypes"
+	pb "github.com/hoophq/hoop/common/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func cstring(v string) []byte { return append([]byte(v), 0x00) }
+
+func newPGServerPackets(email []byte) []byte {
+	rowDesc := binary.BigEndian.AppendUint16(nil, 2)
+	for _, name := range []string{"id", "email"} {
+		rowDesc = append(rowDesc, cstring(name)...)
+		rowDesc = append(rowDesc, make([]byte, 18)...)
+	}
+	var data []byte
+	data = append(data, pgtypes.NewPacket(pgtypes.ServerRowDescription, rowDesc).Encode()...)
+	data = append(data, pgtypes.NewPacket(pgtypes.ServerDataRow, pgtypes.EncodeDataRow([][]byte{[]byte("1"), email})).Encode()...)
+	data = append(data, pgtypes.NewPacket(pgtypes.ServerDataRow, pgtypes.EncodeDataRow([][]byte{[]byte("2"), nil})).Encode()...)
+	data = append(data, pgtypes.NewPacket(pgtypes.ServerCommandComplete, cstring("SELECT 2")).Encode()...)
+	data = append(data, pgtypes.NewReadyForQuery(pgtypes.ServerIdle).Encode()...)
+	return data
+}
+
+func newMySQLServerPackets(email []byte) []byte {
+	lenencStr := func(v string) []byte { return append([]byte{byte(len(v))}, v...) }
+	var data []byte
+	seq := uint8(1)
+	appendPacket := func(frame []byte) {
+		data = append(data, mysqltypes.NewPacket(seq, frame).Encode()...)
+		seq++
+	}
+	eof := []byte{0xfe, 0x00, 0x00, 0x02, 0x00}
+	appendPacket([]byte{0x02})
+	for _, name := range []string{"id", "email"} {
+		var colDef []byte
+		for _, v := range []string{"def", "testdb", "users", "users", name, name} {
+			colDef = append(colDef, lenencStr(v)...)
+		}
+		appendPacket(colDef)
+	}
+	appendPacket(eof)
+	appendPacket(mysqltypes.EncodeTextRow([][]byte{[]byte("1"), email}))
+	appendPacket(mysqltypes.EncodeTextRow([][]byte{[]byte("2"), nil}))
+	appendPacket(eof)
+	return data
+}
+
+func TestConnMaskingRedact(t *testing.T) {
+	for _, tt := range []struct {
+		msg        string
+		connType   pb.ConnectionType
+		query      []byte
+		serverData []byte
+		want       []byte
+	}{
+		{
+			msg:        "it should redact postgres rows",
+			connType:   pb.ConnectionTypePostgres,
+			query:      pgtypes.NewPacket(pgtypes.ClientSimpleQuery, cstring("SELECT id, email FROM users")).Encode(),
+			serverData: newPGServerPackets([]byte("john@example.com")),
+			want:       newPGServerPackets([]byte("[EMAIL_ADDRESS]")),
+		},
+		{
+			msg:        "it should redact mysql rows",
+			connType:   pb.ConnectionTypeMySQL,
+			query:      mysqltypes.NewPacket(0, append([]byte{mysqltypes.ComQuery.Byte()}, "SELECT id, email FROM users"...)).Encode(),
+			serverData: newMySQLServerPackets([]byte("john@example.com")),
+			want:       newMySQLServerPackets([]byte("[EMAIL_ADDRESS]")),
+		},
+		{
+			msg:        "it should not redact before the client sends a query",
+			connType:   pb.ConnectionTypePostgres,
+			serverData: newPGServerPackets([]byte("john@example.com")),
+			want:       newPGServerPackets([]byte("john@example.com")),
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			c := newConnMasking(dlp.NewEngine([]string{"EMAIL_ADDRESS"}), tt.connType)
+			c.onClientPacket(tt.query)
+			// the packets could be split at any position
+			var got []byte
+			for i := 0; i < len(tt.serverData); i += 7 {
+				payload, err := c.redact(tt.serverData[i:min(i+7, len(tt.serverData))], &dlp.Summary{})
+				assert.NoError(t, err)
+				got = append(got, payload...)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConnMaskingFallback(t *testing.T) {
+	c := newConnMasking(dlp.NewEngine([]string{"EMAIL_ADDRESS"}), pb.ConnectionTypePostgres)
+	c.onClientPacket(pgtypes.NewPacket(pgtypes.ClientSimpleQuery, cstring("SELECT 1")).Encode())
+	// data row with an invalid column length
+	invalidRow := pgtypes.NewPacket(pgtypes.ServerDataRow, []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x10}).Encode()
+	summary := &dlp.Summary{}
+	_, err := c.redact(invalidRow, summary)
+	assert.Error(t, err)
+	got, err := c.redact([]byte("john@example.com"), summary)
+	assert.NoError(t, err)
+	assert.Equal(t, "[EMAIL_ADDRESS]", string(got))
+}
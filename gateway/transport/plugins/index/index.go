@@ -1,18 +1,24 @@
 package index
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/go-co-op/gocron"
+	"github.com/hoophq/hoop/common/httptypes"
 	"github.com/hoophq/hoop/common/log"
 	"github.com/hoophq/hoop/common/memory"
 	"github.com/hoophq/hoop/common/mssqltypes"
+	"github.com/hoophq/hoop/common/oracletypes"
 	pgtypes "github.com/hoophq/hoop/common/pgtypes"
 	pb "github.com/hoophq/hoop/common/proto"
 	pbagent "github.com/hoophq/hoop/common/proto/agent"
 	pbclient "github.com/hoophq/hoop/common/proto/client"
+	"github.com/hoophq/hoop/common/redistypes"
+	"github.com/hoophq/hoop/common/sshtypes"
 	"github.com/hoophq/hoop/gateway/indexer"
 	eventlogv0 "github.com/hoophq/hoop/gateway/session/eventlog/v0"
 	"github.com/hoophq/hoop/gateway/storagev2/types"
@@ -105,6 +111,30 @@ func (p *indexPlugin) OnReceive(c plugintypes.Context, pkt *pb.Packet) (*plugint
 				return nil, p.writeOnReceive(c.SID, eventlogv0.InputType, []byte(query))
 			}
 		}
+	case pbagent.OracleDBConnectionWrite:
+		if stmt := oracletypes.DecodeSQLStatement(pkt.Payload); stmt != "" {
+			return nil, p.writeOnReceive(c.SID, eventlogv0.InputType, []byte(stmt))
+		}
+	case pbagent.RedisConnectionWrite:
+		cmd, err := redistypes.DecodeCommand(bufio.NewReader(bytes.NewReader(pkt.Payload)))
+		if err != nil || cmd.Name() == "AUTH" || cmd.Name() == "HELLO" {
+			break
+		}
+		return nil, p.writeOnReceive(c.SID, eventlogv0.InputType, []byte(cmd.String()))
+	case pbagent.SSHConnectionWrite:
+		msg, err := sshtypes.Decode(pkt.Payload)
+		if err != nil {
+			break
+		}
+		if command := msg.ExecCommand(); command != "" {
+			return nil, p.writeOnReceive(c.SID, eventlogv0.InputType, []byte(command))
+		}
+	case pbagent.HTTPConnectionWrite:
+		req, err := httptypes.DecodeRequest(pkt.Payload)
+		if err != nil {
+			break
+		}
+		return nil, p.writeOnReceive(c.SID, eventlogv0.InputType, []byte(req.Method+" "+req.URL.RequestURI()))
 	case pbclient.WriteStdout:
 		return nil, p.writeOnReceive(c.SID, eventlogv0.OutputType, pkt.Payload)
 	case pbclient.WriteStderr:
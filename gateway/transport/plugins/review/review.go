@@ -9,6 +9,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/hoophq/hoop/common/license"
 	"github.com/hoophq/hoop/common/log"
+	"github.com/hoophq/hoop/common/memory"
 	pb "github.com/hoophq/hoop/common/proto"
 	pbagent "github.com/hoophq/hoop/common/proto/agent"
 	pbclient "github.com/hoophq/hoop/common/proto/client"
@@ -21,12 +22,15 @@ import (
 type reviewPlugin struct {
 	apiURL    string
 	reviewSvc *review.Service
+	// the cancel functions of the jit sessions, they are called when the session disconnects
+	jitCancelStore memory.Store
 }
 
 func New(reviewSvc *review.Service, apiURL string) *reviewPlugin {
 	return &reviewPlugin{
-		apiURL:    apiURL,
-		reviewSvc: reviewSvc,
+		apiURL:         apiURL,
+		reviewSvc:      reviewSvc,
+		jitCancelStore: memory.New(),
 	}
 }
 
@@ -52,6 +56,14 @@ func (p *reviewPlugin) OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plu
 	if otrev != nil && otrev.Type == review.ReviewTypeOneTime {
 		log.With("id", otrev.Id, "sid", pctx.SID, "user", otrev.ReviewOwner.Email, "org", pctx.OrgID,
 			"status", otrev.Status).Info("one time review")
+		if otrev.IsExpired(time.Now().UTC()) {
+			otrev.Status = types.ReviewStatusRejected
+			if err := p.reviewSvc.Persist(pctx, otrev); err != nil {
+				return nil, plugintypes.InternalErr("failed saving expired review", err)
+			}
+			return nil, plugintypes.InvalidArgument("review %s has expired at %s", otrev.Id,
+				otrev.ExpireAt.Format(time.RFC3339))
+		}
 		if !(otrev.Status == types.ReviewStatusApproved || otrev.Status == types.ReviewStatusProcessing) {
 			reviewURL := fmt.Sprintf("%s/plugins/reviews/%s", p.apiURL, otrev.Id)
 			p.setSpecReview(pkt)
@@ -83,8 +95,11 @@ func (p *reviewPlugin) OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plu
 			log.With("sid", pctx.SID, "id", jitr.Id, "user", jitr.CreatedBy, "org", pctx.OrgID,
 				"revoke-at", jitr.RevokeAt.Format(time.RFC3339),
 				"duration", fmt.Sprintf("%vm", jitr.AccessDuration.Minutes())).Infof("jit access granted")
-			newCtx, _ := context.WithTimeout(pctx.Context, jitr.AccessDuration)
+			newCtx := p.withJitDeadline(pctx, jitr)
 			return &plugintypes.ConnectResponse{Context: newCtx, ClientPacket: nil}, nil
+		case errJitNotStarted:
+			return nil, plugintypes.InvalidArgument("the access to this connection is scheduled to start at %s",
+				jitr.AccessStartAt.Format(time.RFC3339))
 		default:
 			return nil, err
 		}
@@ -93,6 +108,7 @@ func (p *reviewPlugin) OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plu
 		Infof("jit review not found")
 
 	var accessDuration time.Duration
+	var accessStartAt *time.Time
 	reviewType := review.ReviewTypeOneTime
 	durationStr, isJitReview := pkt.Spec[pb.SpecJitTimeout]
 	if isJitReview {
@@ -104,23 +120,19 @@ func (p *reviewPlugin) OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plu
 		if accessDuration.Hours() > 48 {
 			return nil, plugintypes.InvalidArgument("jit access input must not be greater than 48 hours")
 		}
+		if startAtStr, ok := pkt.Spec[pb.SpecJitStartAt]; ok {
+			if accessStartAt, err = parseJitStartAt(string(startAtStr), time.Now().UTC()); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	if len(pctx.PluginConnectionConfig) == 0 {
-		err = fmt.Errorf("missing approval groups for connection")
+	workflow, err := review.ParseWorkflow(pctx.PluginConnectionConfig)
+	if err != nil {
+		err = fmt.Errorf("failed parsing review workflow for connection, reason=%v", err)
 		return nil, plugintypes.InternalErr(err.Error(), err)
 	}
 
-	reviewGroups := make([]types.ReviewGroup, 0)
-	groups := make([]string, 0)
-	for _, s := range pctx.PluginConnectionConfig {
-		groups = append(groups, s)
-		reviewGroups = append(reviewGroups, types.ReviewGroup{
-			Group:  s,
-			Status: types.ReviewStatusPending,
-		})
-	}
-
 	var inputClientArgs []string
 	if encInputClientArgs, ok := pkt.Spec[pb.SpecClientExecArgsKey]; ok {
 		if err := pb.GobDecodeInto(encInputClientArgs, &inputClientArgs); err != nil {
@@ -148,11 +160,11 @@ func (p *reviewPlugin) OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plu
 			Email:   pctx.UserEmail,
 			SlackID: pctx.UserSlackID,
 		},
-		AccessDuration:   accessDuration,
-		Status:           types.ReviewStatusPending,
-		ReviewGroupsIds:  groups,
-		ReviewGroupsData: reviewGroups,
+		AccessDuration: accessDuration,
+		AccessStartAt:  accessStartAt,
+		Status:         types.ReviewStatusPending,
 	}
+	workflow.Apply(newRev)
 
 	if !isJitReview {
 		// only onetime reviews has inputs
@@ -180,14 +192,33 @@ func (p *reviewPlugin) OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plu
 	}}, nil
 }
 
-func (p *reviewPlugin) OnDisconnect(_ plugintypes.Context, errMsg error) error { return nil }
-func (p *reviewPlugin) OnShutdown()                                            {}
+func (p *reviewPlugin) OnDisconnect(pctx plugintypes.Context, errMsg error) error {
+	if cancelFn, ok := p.jitCancelStore.Pop(pctx.SID).(context.CancelFunc); ok {
+		cancelFn()
+	}
+	return nil
+}
+func (p *reviewPlugin) OnShutdown() {}
+
+// withJitDeadline returns a context that is done when the jit access is revoked,
+// the context is canceled when the session disconnects
+func (p *reviewPlugin) withJitDeadline(pctx plugintypes.Context, jitr *types.Review) context.Context {
+	ctx, cancelFn := context.WithDeadline(pctx.Context, jitDeadline(jitr, time.Now().UTC()))
+	p.jitCancelStore.Set(pctx.SID, cancelFn)
+	return ctx
+}
 
 // indicate to other plugins that this packet has the review enabled
 // it will allow applying special logic for these cases
 func (p *reviewPlugin) setSpecReview(pkt *pb.Packet) { pkt.Spec[pb.SpecHasReviewKey] = []byte("true") }
 
-var errJitExpired = errors.New("jit expired")
+// the maximum time ahead to schedule the start of a jit access
+const maxJitStartAtSchedule = time.Hour * 24 * 30
+
+var (
+	errJitExpired    = errors.New("jit expired")
+	errJitNotStarted = errors.New("jit not started")
+)
 
 func validateJit(jit *types.Review, t time.Time) error {
 	if jit.RevokeAt == nil || jit.RevokeAt.IsZero() {
@@ -202,5 +233,33 @@ func validateJit(jit *types.Review, t time.Time) error {
 	if isJitExpired {
 		return errJitExpired
 	}
+	if jit.AccessStartAt != nil && t.Before(*jit.AccessStartAt) {
+		return errJitNotStarted
+	}
 	return nil
 }
+
+// jitDeadline returns when the access of a valid jit ends, scheduled access windows
+// end at the revoke time, otherwise the access lasts the duration since it's granted.
+func jitDeadline(jit *types.Review, t time.Time) time.Time {
+	if jit.AccessStartAt != nil {
+		return *jit.RevokeAt
+	}
+	return t.Add(jit.AccessDuration)
+}
+
+// parseJitStartAt parses the start of a scheduled access window in the RFC3339 format
+func parseJitStartAt(val string, t time.Time) (*time.Time, error) {
+	startAt, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return nil, plugintypes.InvalidArgument("invalid access start time, expected RFC3339 format, got=%v", val)
+	}
+	startAt = startAt.UTC()
+	if startAt.Before(t) {
+		return nil, plugintypes.InvalidArgument("jit access start time must be in the future")
+	}
+	if startAt.Sub(t) > maxJitStartAtSchedule {
+		return nil, plugintypes.InvalidArgument("jit access start time must not be greater than 30 days from now")
+	}
+	return &startAt, nil
+}
@@ -1,7 +1,6 @@
 package review
 
 import (
-	"context"
 	"fmt"
 	"time"
 
@@ -31,8 +30,11 @@ func (r *reviewPlugin) onReceiveOSS(pctx plugintypes.Context, pkt *pb.Packet) (*
 			log.With("sid", pctx.SID, "id", jitr.Id, "user", jitr.CreatedBy, "org", pctx.OrgID,
 				"revoke-at", jitr.RevokeAt.Format(time.RFC3339),
 				"duration", fmt.Sprintf("%vm", jitr.AccessDuration.Minutes())).Infof("jit access granted")
-			newCtx, _ := context.WithTimeout(pctx.Context, jitr.AccessDuration)
+			newCtx := r.withJitDeadline(pctx, jitr)
 			return &plugintypes.ConnectResponse{Context: newCtx, ClientPacket: nil}, nil
+		case errJitNotStarted:
+			return nil, plugintypes.InvalidArgument("the access to this connection is scheduled to start at %s",
+				jitr.AccessStartAt.Format(time.RFC3339))
 		default:
 			return nil, err
 		}
@@ -51,19 +53,16 @@ func (r *reviewPlugin) onReceiveOSS(pctx plugintypes.Context, pkt *pb.Packet) (*
 			return nil, plugintypes.InvalidArgument("jit access input must not be greater than 48 hours")
 		}
 	}
-	if len(pctx.PluginConnectionConfig) == 0 {
-		err = fmt.Errorf("missing approval groups for connection")
-		return nil, plugintypes.InternalErr(err.Error(), err)
+	var accessStartAt *time.Time
+	if startAtStr, ok := pkt.Spec[pb.SpecJitStartAt]; ok {
+		if accessStartAt, err = parseJitStartAt(string(startAtStr), time.Now().UTC()); err != nil {
+			return nil, err
+		}
 	}
-
-	reviewGroups := make([]types.ReviewGroup, 0)
-	groups := make([]string, 0)
-	for _, s := range pctx.PluginConnectionConfig {
-		groups = append(groups, s)
-		reviewGroups = append(reviewGroups, types.ReviewGroup{
-			Group:  s,
-			Status: types.ReviewStatusPending,
-		})
+	workflow, err := review.ParseWorkflow(pctx.PluginConnectionConfig)
+	if err != nil {
+		err = fmt.Errorf("failed parsing review workflow for connection, reason=%v", err)
+		return nil, plugintypes.InternalErr(err.Error(), err)
 	}
 
 	var inputClientArgs []string
@@ -94,11 +93,11 @@ func (r *reviewPlugin) onReceiveOSS(pctx plugintypes.Context, pkt *pb.Packet) (*
 			Email:   pctx.UserEmail,
 			SlackID: pctx.UserSlackID,
 		},
-		AccessDuration:   accessDuration,
-		Status:           types.ReviewStatusPending,
-		ReviewGroupsIds:  groups,
-		ReviewGroupsData: reviewGroups,
+		AccessDuration: accessDuration,
+		AccessStartAt:  accessStartAt,
+		Status:         types.ReviewStatusPending,
 	}
+	workflow.Apply(newRev)
 	log.With("session", pctx.SID, "id", newRev.Id, "user", pctx.UserID, "org", pctx.OrgID,
 		"type", review.ReviewTypeJit, "duration", fmt.Sprintf("%vm", accessDuration.Minutes())).
 		Infof("creating review")
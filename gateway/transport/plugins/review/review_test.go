@@ -36,6 +36,23 @@ func TestValidateJit(t *testing.T) {
 			},
 			err: errJitExpired,
 		},
+		{
+			msg: "it should validate with error if the scheduled access has not started",
+			now: newTime(10, 9),
+			jit: &types.Review{
+				AccessStartAt: newTime(10, 10),
+				RevokeAt:      newTime(10, 20),
+			},
+			err: errJitNotStarted,
+		},
+		{
+			msg: "it should validate without any error when the scheduled access has started",
+			now: newTime(10, 10),
+			jit: &types.Review{
+				AccessStartAt: newTime(10, 10),
+				RevokeAt:      newTime(10, 20),
+			},
+		},
 		{
 			msg: "it should validate with error if revoked at is nil",
 			now: newTime(10, 21),
@@ -63,3 +80,57 @@ func TestValidateJit(t *testing.T) {
 		})
 	}
 }
+
+func TestParseJitStartAt(t *testing.T) {
+	now := newTime(10, 0)
+	for _, tt := range []struct {
+		msg  string
+		val  string
+		want *time.Time
+		err  error
+	}{
+		{
+			msg:  "it should parse the start time in the future",
+			val:  "2024-09-09T12:00:00Z",
+			want: newTime(12, 0),
+		},
+		{
+			msg:  "it should parse the start time with a time zone offset",
+			val:  "2024-09-09T09:30:00-03:00",
+			want: newTime(12, 30),
+		},
+		{
+			msg: "it should return error with an invalid format",
+			val: "2024-09-09 12:00",
+			err: fmt.Errorf("invalid access start time, expected RFC3339 format, got=2024-09-09 12:00"),
+		},
+		{
+			msg: "it should return error when the start time is in the past",
+			val: "2024-09-09T09:59:00Z",
+			err: fmt.Errorf("jit access start time must be in the future"),
+		},
+		{
+			msg: "it should return error when the start time is too far ahead",
+			val: "2024-10-10T10:00:00Z",
+			err: fmt.Errorf("jit access start time must not be greater than 30 days from now"),
+		},
+	} {
+		t.Run(tt.msg, func(t *testing.T) {
+			got, err := parseJitStartAt(tt.val, *now)
+			if tt.err != nil {
+				assert.EqualError(t, err, tt.err.Error())
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestJitDeadline(t *testing.T) {
+	now := newTime(10, 0)
+	jit := &types.Review{AccessDuration: time.Minute * 30, RevokeAt: newTime(10, 20)}
+	assert.Equal(t, *newTime(10, 30), jitDeadline(jit, *now))
+	jit.AccessStartAt = newTime(9, 50)
+	assert.Equal(t, *newTime(10, 20), jitDeadline(jit, *now))
+}
@@ -3,6 +3,7 @@ package slack
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hoophq/hoop/common/log"
 	pb "github.com/hoophq/hoop/common/proto"
@@ -82,7 +83,13 @@ func (p *slackPlugin) performExecReview(ev *event, ctx *storagev2.Context, statu
 			status = strings.ToLower(string(rev.Status))
 		}
 		err = ev.ss.UpdateMessageStatus(ev.msg, fmt.Sprintf("• _review has already been `%s`_", status))
+	case review.ErrSelfApproval, review.ErrNotEligible, review.ErrPendingStage, review.ErrAlreadyReviewed, review.ErrExpired:
+		err = ev.ss.PostEphemeralMessage(ev.msg, fmt.Sprintf("Unable to review this session: %v", err))
 	case nil:
+		if msg := pendingQuorumMessage(rev, ev.msg.GroupName); msg != "" {
+			err = ev.ss.PostEphemeralMessage(ev.msg, msg)
+			break
+		}
 		isApproved := rev.Status == types.ReviewStatusApproved
 		err = ev.ss.UpdateMessage(ev.msg, isApproved)
 
@@ -113,11 +120,22 @@ func (p *slackPlugin) performJitReview(ev *event, ctx *storagev2.Context, status
 			status = strings.ToLower(string(j.Status))
 		}
 		err = ev.ss.UpdateMessageStatus(ev.msg, fmt.Sprintf("• _jit has already been `%s`_", status))
+	case review.ErrSelfApproval, review.ErrNotEligible, review.ErrPendingStage, review.ErrAlreadyReviewed, review.ErrExpired:
+		err = ev.ss.PostEphemeralMessage(ev.msg, fmt.Sprintf("Unable to review this session: %v", err))
 	case nil:
+		if msg := pendingQuorumMessage(j, ev.msg.GroupName); msg != "" {
+			err = ev.ss.PostEphemeralMessage(ev.msg, msg)
+			break
+		}
 		isApproved := j.Status == types.ReviewStatusApproved
 		err = ev.ss.UpdateMessage(ev.msg, isApproved)
 
-		if isApproved {
+		switch {
+		case isApproved && j.AccessStartAt != nil && j.AccessStartAt.After(time.Now().UTC()):
+			ev.ss.PostMessage(j.ReviewOwner.SlackID, fmt.Sprintf("Your interactive session was approved, "+
+				"the access will be available from %s until %s.",
+				j.AccessStartAt.Format(time.RFC1123), j.RevokeAt.Format(time.RFC1123)))
+		case isApproved:
 			ev.ss.PostMessage(j.ReviewOwner.SlackID, fmt.Sprintf("Your interactive session is ready to be executed.\n"+
 				"Please follow this link to execute it: "+
 				"%s/sessions/%s", p.idpProvider.ApiURL, ev.msg.SessionID))
@@ -133,3 +151,18 @@ func (p *slackPlugin) performJitReview(ev *event, ctx *storagev2.Context, status
 		log.With("session", sid).Warnf("failed updating slack jit review, reason=%v", err)
 	}
 }
+
+// pendingQuorumMessage returns a message when the approval was registered
+// but the group still requires the approval of other members
+func pendingQuorumMessage(rev *types.Review, groupName string) string {
+	if rev.Status != types.ReviewStatusPending {
+		return ""
+	}
+	for _, g := range rev.ReviewGroupsData {
+		if g.Group == groupName && g.Status == types.ReviewStatusPending && len(g.Approvals) > 0 {
+			return fmt.Sprintf("Your approval was registered, the group %s has %v of %v required approvals",
+				g.Group, len(g.Approvals), g.Quorum)
+		}
+	}
+	return ""
+}
@@ -224,6 +224,7 @@ func (p *slackPlugin) OnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plug
 		sreq.ApprovalGroups = parseGroups(rev.ReviewGroupsData)
 		if rev.AccessDuration > 0 {
 			sreq.SessionTime = &rev.AccessDuration
+			sreq.SessionStartAt = rev.AccessStartAt
 		}
 		sreq.Script = rev.Input
 	}
@@ -267,10 +268,10 @@ func parseSlackConfig(pconf *types.PluginConfig) (*slackConfig, error) {
 	return &sc, nil
 }
 
-func parseGroups(reviewGroups []types.ReviewGroup) []string {
-	groups := make([]string, 0)
+func parseGroups(reviewGroups []types.ReviewGroup) []slack.ApprovalGroup {
+	groups := make([]slack.ApprovalGroup, 0)
 	for _, g := range reviewGroups {
-		groups = append(groups, g.Group)
+		groups = append(groups, slack.ApprovalGroup{Name: g.Group, Stage: g.Stage, Quorum: g.Quorum})
 	}
 	return groups
 }
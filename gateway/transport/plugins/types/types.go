@@ -63,6 +63,12 @@ type Plugin interface {
 	OnDisconnect(pctx Context, errMsg error) error
 }
 
+// Flusher is implemented by the plugins that hold the output of the agent. The packets
+// returned by OnFlush are sent to the client before the agent closes the session.
+type Flusher interface {
+	OnFlush(pctx Context) []*pb.Packet
+}
+
 type ConnectResponse struct {
 	// The new context to propagate to the client transport layer
 	Context context.Context
@@ -73,6 +79,10 @@ type ConnectResponse struct {
 	// This is useful when a plugin needs to intercept the current flow and
 	// send a packet back to client.
 	ClientPacket *pb.Packet
+	// When this attribute is true, the packet is not sent to the agent.
+	// It's useful when a plugin holds the payload of a packet or when
+	// the packet must be dropped without answering the client.
+	Discard bool
 }
 
 func (c Context) GetOrgID() string        { return c.OrgID }
@@ -24,20 +24,26 @@ import (
 	"google.golang.org/grpc/status"
 )
 
-// type proxyManagerContext
+// proxyManagerIdleTimeout is the max time a proxy manager stays connected without any tunnels
+const proxyManagerIdleTimeout = time.Minute * 30
 
 // proxyManager listen for API REST commands to manage grpc-client connections.
 // The dispatcher functions are used to communicate directly with a channel performing
 // actions directly to a stateful connection. It allows opening a session and
 // disconnecting a client.
 //
+// A single stream multiplexes the sessions of multiple connections (tunnels), each session
+// is opened in a distinct port of the client and has its own plugin context.
+//
 // In order for this to work properly, a grpc-client must be always connected, otherwise
 // the API will fail to manage connections.
 func (s *Server) proxyManager(stream *streamclient.ProxyStream) error {
 	if err := stream.Send(&pb.Packet{Type: pbclient.ProxyManagerConnectOK}); err != nil {
 		return err
 	}
-	err := s.listenProxyManagerMessages(stream)
+	ctx, cancelFn := context.WithCancel(stream.Context())
+	defer cancelFn()
+	err := s.listenProxyManagerMessages(ctx, cancelFn, stream)
 	if status, ok := status.FromError(err); ok && status.Code() == codes.Canceled {
 		log.Infof("grpc client connection canceled")
 	}
@@ -47,10 +53,6 @@ func (s *Server) proxyManager(stream *streamclient.ProxyStream) error {
 	defer func() {
 		_ = stream.Close(err)
 		_, _ = clientstate.Update(pluginCtx, types.ClientStatusDisconnected)
-		stateID := clientstate.DeterministicClientUUID(pluginCtx.GetUserID())
-		if len(stateID) > 0 {
-			removeDispatcherState(stateID)
-		}
 	}()
 	switch v := err.(type) {
 	case *plugintypes.InternalError:
@@ -63,16 +65,33 @@ func (s *Server) proxyManager(stream *streamclient.ProxyStream) error {
 	return err
 }
 
-func (s *Server) listenProxyManagerMessages(stream *streamclient.ProxyStream) error {
-	pctx := stream.PluginContext()
+func (s *Server) listenProxyManagerMessages(ctx context.Context, cancelFn context.CancelFunc, stream *streamclient.ProxyStream) (err error) {
+	var disp *dispatcherState
+	defer func() {
+		if disp == nil {
+			return
+		}
+		stateID := clientstate.DeterministicClientUUID(stream.PluginContext().GetUserID())
+		removeDispatcherState(stateID, disp)
+		disp.closeAllTunnels(err)
+	}()
+
+	idleTicker := time.NewTicker(time.Minute)
+	defer idleTicker.Stop()
 	recvCh := grpc.NewStreamRecv(stream)
 	for {
 		var dstream *grpc.DataStream
 		select {
-		case <-stream.Context().Done():
-			return stream.ContextCauseError()
-		case <-pctx.Context.Done():
-			return status.Error(codes.Aborted, "session ended, reached connection duration")
+		case <-ctx.Done():
+			if err := stream.ContextCauseError(); err != nil {
+				return err
+			}
+			return status.Error(codes.Canceled, "proxy manager disconnected by the api")
+		case <-idleTicker.C:
+			if disp != nil && disp.idleDuration() > proxyManagerIdleTimeout {
+				return fmt.Errorf("timeout (%v) waiting for api requests", proxyManagerIdleTimeout)
+			}
+			continue
 		case dstream = <-recvCh:
 		}
 
@@ -93,35 +112,63 @@ func (s *Server) listenProxyManagerMessages(stream *streamclient.ProxyStream) er
 			pkt.Spec = make(map[string][]byte)
 		}
 
-		pkt.Spec[pb.SpecGatewaySessionID] = []byte(pctx.SID)
 		switch pkt.Type {
 		case pbgateway.KeepAlive: // noop
 		case pbgateway.ProxyManagerConnectOKAck:
-			if err := s.proccessConnectOKAck(stream); err != nil {
+			if disp != nil {
+				continue
+			}
+			if disp, err = s.proccessConnectOKAck(ctx, cancelFn, stream); err != nil {
 				return err
 			}
 		default:
-			connectResponse, err := stream.PluginExecOnReceive(pctx, pkt)
-			if err != nil {
-				return err
+			sid := string(pkt.Spec[pb.SpecGatewaySessionID])
+			var tunnelStream *streamclient.ProxyStream
+			if disp != nil {
+				tunnelStream = disp.getTunnelStream(sid)
 			}
-			if connectResponse != nil {
-				if connectResponse.Context != nil {
-					pctx.Context = connectResponse.Context
-				}
-				if connectResponse.ClientPacket != nil {
-					_ = stream.Send(connectResponse.ClientPacket)
-					continue
+			if tunnelStream == nil {
+				log.With("sid", sid).Debugf("tunnel not found, discarding packet %v", pkt.Type)
+				continue
+			}
+			tpctx := tunnelStream.PluginContext()
+			// the client closes a session when it fails to listen in the requested port
+			if pkt.Type == pbagent.SessionClose {
+				reason := fmt.Errorf("session closed by the client")
+				if len(pkt.Payload) > 0 {
+					reason = fmt.Errorf("%s", pkt.Payload)
 				}
+				log.With("sid", sid, "connection", tpctx.ConnectionName).Infof("closing tunnel, reason=%v", reason)
+				disp.closeTunnel(tpctx.ConnectionName, reason)
+				continue
 			}
-			if err = stream.SendToAgent(pkt); err != nil {
-				return err
+			if err := processTunnelPacket(tunnelStream, pkt); err != nil {
+				log.With("sid", sid, "connection", tpctx.ConnectionName).Infof("closing tunnel, reason=%v", err)
+				disp.closeTunnel(tpctx.ConnectionName, err)
 			}
 		}
 	}
 }
 
-func (s *Server) proccessConnectOKAck(stream *streamclient.ProxyStream) error {
+// processTunnelPacket executes the plugins of the tunnel session and sends the packet to the agent
+func processTunnelPacket(stream *streamclient.ProxyStream, pkt *pb.Packet) error {
+	pctx := stream.PluginContext()
+	removeAgentSpecs(pkt)
+	connectResponse, err := stream.PluginExecOnReceive(pctx, pkt)
+	if err != nil {
+		return err
+	}
+	if connectResponse != nil && connectResponse.ClientPacket != nil {
+		_ = stream.Send(connectResponse.ClientPacket)
+		return nil
+	}
+	if connectResponse != nil && connectResponse.Discard {
+		return nil
+	}
+	return stream.SendToAgent(pkt)
+}
+
+func (s *Server) proccessConnectOKAck(ctx context.Context, cancelFn context.CancelFunc, stream *streamclient.ProxyStream) (*dispatcherState, error) {
 	pctx := stream.PluginContext()
 	newClient, err := clientstate.Update(pctx, types.ClientStatusReady,
 		clientstate.WithOption("session", pctx.SID),
@@ -132,117 +179,138 @@ func (s *Server) proccessConnectOKAck(stream *streamclient.ProxyStream) error {
 	)
 	if err != nil {
 		log.Errorf("failed client state to database, err=%v", err)
-		return err
+		return nil, err
 	}
 
-	logAttrs := []any{"connection", pctx.ConnectionName, "sid", pctx.SID,
-		"mode", pctx.AgentMode, "agent-name", pctx.AgentName, "ua", stream.GetMeta("user-agent")}
+	logAttrs := []any{"sid", pctx.SID, "ua", stream.GetMeta("user-agent")}
 	log.With(logAttrs...).Infof("proxy manager connected: %v", stream)
-	// TODO: add reason to close?
-	disp := newDispatcherState(func() { stream.Close(nil) })
+	disp := newDispatcherState(cancelFn)
 	addDispatcherStateEntry(newClient.ID, disp)
 
-	// wait up to 30 minutes and disconnect the proxy
-	ctx, timeoutCancelFn := context.WithTimeout(context.Background(), time.Minute*30)
-	defer timeoutCancelFn()
-	select {
-	case <-stream.Context().Done():
-		return stream.ContextCauseError()
-	case req := <-disp.requestCh:
-		log.With("session", pctx.SID).Infof("starting connect phase for %s", req.RequestConnectionName)
-		conn, err := apiconnections.FetchByName(pctx, req.RequestConnectionName)
-		if err != nil {
-			log.Errorf("failed retrieving connection, reason=%v", err)
-			disp.sendResponse(nil, err)
-			return err
-		}
-		if conn == nil {
-			disp.sendResponse(nil, status.Errorf(codes.NotFound, ""))
-			return status.Errorf(codes.NotFound, fmt.Sprintf("connection '%v' not found", req.RequestConnectionName))
+	// process the requests concurrently, a slow connection
+	// must not block opening sessions of other connections
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case req := <-disp.requestCh:
+				if err := disp.reserveTunnel(req.client); err != nil {
+					req.sendResponse(nil, err)
+					continue
+				}
+				go func() {
+					pkt, err := s.openProxyManagerTunnel(stream, disp, req.client)
+					// a review packet indicates the session is not opened
+					if err != nil || pkt != nil {
+						disp.removeTunnel(req.client.RequestConnectionName, "")
+					}
+					req.sendResponse(pkt, err)
+				}()
+			}
 		}
+	}()
+	return disp, nil
+}
 
-		if conn.Type != "database" && conn.SubType != "tcp" {
-			disp.sendResponse(nil, ErrUnsupportedType)
-			return fmt.Errorf("connection type %s/%s not supported", conn.Type, conn.SubType)
-		}
+// openProxyManagerTunnel opens a session of the requested connection sharing the stream
+// of the proxy manager. The tunnel is closed when its session ends, it doesn't affect the
+// sessions of other connections.
+func (s *Server) openProxyManagerTunnel(stream *streamclient.ProxyStream, disp *dispatcherState, req *types.Client) (*pb.Packet, error) {
+	pctx := stream.PluginContext()
+	log.With("session", pctx.SID).Infof("starting connect phase for %s", req.RequestConnectionName)
+	conn, err := apiconnections.FetchByName(pctx, req.RequestConnectionName)
+	if err != nil {
+		log.Errorf("failed retrieving connection, reason=%v", err)
+		return nil, err
+	}
+	if conn == nil {
+		return nil, status.Errorf(codes.NotFound, fmt.Sprintf("connection '%v' not found", req.RequestConnectionName))
+	}
 
-		if conn.AccessModeConnect == "disabled" {
-			errorMessage := fmt.Sprintf("the %v connection has the access mode connect (Native) feature disabled", conn.Name)
+	if pb.ToConnectionType(conn.Type, conn.SubType) == pb.ConnectionTypeCommandLine {
+		return nil, ErrUnsupportedType
+	}
 
-			disp.sendResponse(nil, status.Error(codes.FailedPrecondition, errorMessage))
-			return status.Error(codes.FailedPrecondition, errorMessage)
-		}
+	if conn.AccessModeConnect == "disabled" {
+		errorMessage := fmt.Sprintf("the %v connection has the access mode connect (Native) feature disabled", conn.Name)
+		return nil, status.Error(codes.FailedPrecondition, errorMessage)
+	}
 
-		clientOrigin := pb.ConnectionOriginClientProxyManager
-		stream.SetPluginContext(func(pluginCtx *plugintypes.Context) {
-			pluginCtx.ConnectionID = conn.ID
-			pluginCtx.ConnectionName = conn.Name
-			pluginCtx.ConnectionType = conn.Type
-			pluginCtx.ConnectionSubType = conn.SubType
-			pluginCtx.ConnectionCommand = conn.Command
-			pluginCtx.ConnectionSecret = conn.AsSecrets()
-
-			pluginCtx.AgentID = conn.AgentID
-			pluginCtx.AgentMode = conn.Agent.Mode
-			pluginCtx.AgentName = conn.Agent.Name
-		})
-		pctx = stream.PluginContext()
-		if err := requestProxyConnection(stream); err != nil {
-			return err
-		}
+	clientOrigin := pb.ConnectionOriginClientProxyManager
+	tunnelStream := stream.NewTunnel(func(pluginCtx *plugintypes.Context) {
+		pluginCtx.ConnectionID = conn.ID
+		pluginCtx.ConnectionName = conn.Name
+		pluginCtx.ConnectionType = conn.Type
+		pluginCtx.ConnectionSubType = conn.SubType
+		pluginCtx.ConnectionCommand = conn.Command
+		pluginCtx.ConnectionSecret = conn.AsSecrets()
 
-		if err := stream.Save(); err != nil {
-			disp.sendResponse(nil, err)
-			return err
-		}
-		userAgent := apiutils.NormalizeUserAgent(func(key string) []string {
-			return []string{stream.GetMeta("user-agent")}
-		})
-		analytics.New().Track(pctx.UserEmail, analytics.EventGrpcConnect, map[string]any{
-			"connection-name":    req.RequestConnectionName,
-			"connection-type":    conn.Type,
-			"connection-subtype": conn.SubType,
-			"client-version":     stream.GetMeta("version"),
-			"platform":           stream.GetMeta("platform"),
-			"hostname":           stream.GetMeta("hostname"),
-			"user-agent":         userAgent,
-			"origin":             clientOrigin,
-			"verb":               pb.ClientVerbConnect,
-		})
-
-		log.With("session", pctx.SID).Infof("proxymanager - starting open session phase")
-		onOpenSessionPkt := &pb.Packet{
-			Type: pbagent.SessionOpen,
-			Spec: map[string][]byte{
-				pb.SpecJitTimeout:        []byte(req.RequestAccessDuration.String()),
-				pb.SpecGatewaySessionID:  []byte(pctx.SID),
-				pb.SpecClientRequestPort: []byte(req.RequestPort),
-			},
-		}
-		connectResponse, err := stream.PluginExecOnReceive(pctx, onOpenSessionPkt)
-		if err != nil {
-			disp.sendResponse(nil, err)
-			return err
+		pluginCtx.AgentID = conn.AgentID
+		pluginCtx.AgentMode = conn.Agent.Mode
+		pluginCtx.AgentName = conn.Agent.Name
+	})
+	tpctx := tunnelStream.PluginContext()
+	if err := requestProxyConnection(tunnelStream); err != nil {
+		return nil, err
+	}
+
+	if err := tunnelStream.Save(); err != nil {
+		return nil, err
+	}
+	userAgent := apiutils.NormalizeUserAgent(func(key string) []string {
+		return []string{stream.GetMeta("user-agent")}
+	})
+	analytics.New().Track(tpctx.UserEmail, analytics.EventGrpcConnect, map[string]any{
+		"connection-name":    req.RequestConnectionName,
+		"connection-type":    conn.Type,
+		"connection-subtype": conn.SubType,
+		"client-version":     stream.GetMeta("version"),
+		"platform":           stream.GetMeta("platform"),
+		"hostname":           stream.GetMeta("hostname"),
+		"user-agent":         userAgent,
+		"origin":             clientOrigin,
+		"verb":               pb.ClientVerbConnect,
+	})
+
+	log.With("session", tpctx.SID).Infof("proxymanager - starting open session phase")
+	onOpenSessionPkt := &pb.Packet{
+		Type: pbagent.SessionOpen,
+		Spec: map[string][]byte{
+			pb.SpecJitTimeout:        []byte(req.RequestAccessDuration.String()),
+			pb.SpecGatewaySessionID:  []byte(tpctx.SID),
+			pb.SpecClientRequestPort: []byte(req.RequestPort),
+		},
+	}
+	connectResponse, err := tunnelStream.PluginExecOnReceive(tpctx, onOpenSessionPkt)
+	if err != nil {
+		_ = tunnelStream.Close(err)
+		return nil, err
+	}
+	if connectResponse != nil {
+		if connectResponse.Context != nil {
+			tpctx.Context = connectResponse.Context
 		}
-		if connectResponse != nil {
-			if connectResponse.Context != nil {
-				pctx.Context = connectResponse.Context
-			}
-			if connectResponse.ClientPacket != nil {
-				_ = stream.Send(connectResponse.ClientPacket)
-				disp.sendResponse(connectResponse.ClientPacket, nil)
-				return nil
-			}
+		if connectResponse.ClientPacket != nil {
+			_ = tunnelStream.Send(connectResponse.ClientPacket)
+			_ = tunnelStream.Close(nil)
+			return connectResponse.ClientPacket, nil
 		}
+	}
 
-		err = s.processClientPacket(stream, onOpenSessionPkt, pctx)
-		disp.sendResponse(nil, err)
-		if err != nil {
-			return err
-		}
-		log.With("session", pctx.SID).Info("proxymanager - session opened")
-	case <-ctx.Done():
-		return fmt.Errorf("timeout (30m) waiting for api response")
+	if err := s.processClientPacket(tunnelStream, onOpenSessionPkt, tpctx); err != nil {
+		_ = tunnelStream.Close(err)
+		return nil, err
 	}
-	return nil
+	disp.setTunnelStream(req.RequestConnectionName, tunnelStream)
+	go func() {
+		select {
+		case <-tpctx.Context.Done():
+			disp.closeTunnel(tpctx.ConnectionName, fmt.Errorf("session ended, reached connection duration"))
+		case <-tunnelStream.Context().Done():
+		}
+		disp.removeTunnel(tpctx.ConnectionName, tpctx.SID)
+	}()
+	log.With("session", tpctx.SID).Info("proxymanager - session opened")
+	return nil, nil
 }
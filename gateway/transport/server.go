@@ -182,7 +182,7 @@ func (s *Server) Connect(stream pb.Transport_ConnectServer) (err error) {
 
 	switch clientOrigin[0] {
 	case pb.ConnectionOriginClientProxyManager:
-		return s.proxyManager(streamclient.NewProxy(pluginCtx, stream))
+		return s.proxyManager(streamclient.NewProxyManager(pluginCtx, stream))
 	default:
 		return s.subscribeClient(streamclient.NewProxy(pluginCtx, stream))
 	}
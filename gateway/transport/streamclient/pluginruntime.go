@@ -1,6 +1,9 @@
 package streamclient
 
 import (
+	"strings"
+
+	"github.com/hoophq/hoop/common/dlp"
 	"github.com/hoophq/hoop/common/log"
 	pb "github.com/hoophq/hoop/common/proto"
 	pgplugins "github.com/hoophq/hoop/gateway/pgrest/plugins"
@@ -10,6 +13,8 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+const pluginSpecKeyPrefix = "plugin."
+
 type runtimePlugin struct {
 	plugintypes.Plugin
 	config []string
@@ -43,6 +48,7 @@ func loadRuntimePlugins(ctx plugintypes.Context) ([]runtimePlugin, error) {
 					config: config,
 				}
 
+				ctx.PluginConnectionConfig = config
 				if err = p.OnConnect(ctx); err != nil {
 					log.Warnf("plugin %q refused to accept connection %q, err=%v", p1.Name, ctx.SID, err)
 					return pluginsConfig, status.Errorf(codes.FailedPrecondition, err.Error())
@@ -60,6 +66,13 @@ func loadRuntimePlugins(ctx plugintypes.Context) ([]runtimePlugin, error) {
 }
 
 func (s *ProxyStream) PluginExecOnReceive(pctx plugintypes.Context, pkt *pb.Packet) (*plugintypes.ConnectResponse, error) {
+	// the plugin keys are set only by the gateway plugins,
+	// remove the ones that came from the client or the agent
+	for key := range pkt.Spec {
+		if strings.HasPrefix(key, pluginSpecKeyPrefix) {
+			delete(pkt.Spec, key)
+		}
+	}
 	var response *plugintypes.ConnectResponse
 	for _, p := range s.runtimePlugins {
 		pctx.PluginConnectionConfig = p.config
@@ -74,6 +87,18 @@ func (s *ProxyStream) PluginExecOnReceive(pctx plugintypes.Context, pkt *pb.Pack
 	return response, nil
 }
 
+// PluginExecOnFlush returns the packets held by the plugins
+func (s *ProxyStream) PluginExecOnFlush(pctx plugintypes.Context) []*pb.Packet {
+	var packets []*pb.Packet
+	for _, p := range s.runtimePlugins {
+		if f, ok := p.Plugin.(plugintypes.Flusher); ok {
+			pctx.PluginConnectionConfig = p.config
+			packets = append(packets, f.OnFlush(pctx)...)
+		}
+	}
+	return packets
+}
+
 func (s *ProxyStream) PluginExecOnDisconnect(ctx plugintypes.Context, errMsg error) error {
 	for _, p := range s.runtimePlugins {
 		if err := p.OnDisconnect(ctx, errMsg); err != nil {
@@ -88,12 +113,31 @@ func (s *ProxyStream) GetRedactInfoTypes() []string {
 	var infoTypes []string
 	for _, p := range s.runtimePlugins {
 		if p.Plugin.Name() == plugintypes.PluginDLPName {
-			infoTypes = p.config
+			for _, entry := range p.config {
+				if !dlp.IsColumnPolicy(entry) {
+					infoTypes = append(infoTypes, entry)
+				}
+			}
 		}
 	}
 	return infoTypes
 }
 
+// GetDataMaskingPolicies return the in memory column masking policies of the data masking (dlp) plugin
+func (s *ProxyStream) GetDataMaskingPolicies() []string {
+	var policies []string
+	for _, p := range s.runtimePlugins {
+		if p.Plugin.Name() == plugintypes.PluginDLPName {
+			for _, entry := range p.config {
+				if dlp.IsColumnPolicy(entry) {
+					policies = append(policies, entry)
+				}
+			}
+		}
+	}
+	return policies
+}
+
 func removePluginConfigDuplicates(strSlice []string) []string {
 	allKeys := make(map[string]bool)
 	list := make([]string, 0)
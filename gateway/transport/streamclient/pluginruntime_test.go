@@ -0,0 +1,42 @@
+package streamclient
+
+import (
+	"testing"
+
+	pb "github.com/hoophq/hoop/common/proto"
+	pbagent "github.com/hoophq/hoop/common/proto/agent"
+	plugintypes "github.com/hoophq/hoop/gateway/transport/plugins/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorderPlugin struct {
+	plugintypes.Plugin
+	packets []*pb.Packet
+}
+
+func (p *recorderPlugin) OnReceive(_ plugintypes.Context, pkt *pb.Packet) (*plugintypes.ConnectResponse, error) {
+	p.packets = append(p.packets, pkt)
+	return nil, nil
+}
+
+func TestPluginExecOnReceiveStripPluginSpecs(t *testing.T) {
+	audit := &recorderPlugin{}
+	s := &ProxyStream{runtimePlugins: []runtimePlugin{{Plugin: audit}}}
+	pkt := &pb.Packet{
+		Type:    pbagent.PGConnectionWrite,
+		Payload: []byte("DROP TABLE users"),
+		Spec: map[string][]byte{
+			pb.SpecGatewaySessionID:             []byte("sid"),
+			pb.SpecPluginAccessControlDropKey:   []byte("true"),
+			pb.SpecPluginAccessControlDeniedKey: []byte("denied"),
+			pb.SpecPluginDcmDataKey:             []byte("{}"),
+		},
+	}
+	_, err := s.PluginExecOnReceive(plugintypes.Context{SID: "sid"}, pkt)
+	assert.NoError(t, err)
+
+	// it should deliver the packet to the audit plugin without the client supplied plugin keys
+	if assert.Len(t, audit.packets, 1) {
+		assert.Equal(t, map[string][]byte{pb.SpecGatewaySessionID: []byte("sid")}, audit.packets[0].Spec)
+	}
+}
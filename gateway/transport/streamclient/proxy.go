@@ -3,6 +3,7 @@ package streamclient
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -96,6 +97,42 @@ func NewProxy(pluginCtx *plugintypes.Context, s pb.Transport_ConnectServer) *Pro
 	return stream
 }
 
+// NewProxyManager returns a stream that could be shared by the sessions of
+// multiple connections, the packets sent to the client are serialized.
+func NewProxyManager(pluginCtx *plugintypes.Context, s pb.Transport_ConnectServer) *ProxyStream {
+	return NewProxy(pluginCtx, &syncServerStream{Transport_ConnectServer: s})
+}
+
+// NewTunnel returns a stream with a new session that shares the transport of the proxy manager stream.
+// The attributes of the user and the client are copied, the connection is set with the callback function.
+func (s *ProxyStream) NewTunnel(fn func(pctx *plugintypes.Context)) *ProxyStream {
+	ctx, cancelFn := context.WithCancelCause(s.context)
+	pluginCtx := *s.pluginCtx
+	pluginCtx.SID = uuid.NewString()
+	pluginCtx.ParamsData = map[string]any{}
+	fn(&pluginCtx)
+	return &ProxyStream{
+		Transport_ConnectServer: s.Transport_ConnectServer,
+		pluginCtx:               &pluginCtx,
+		context:                 ctx,
+		cancelFn:                cancelFn,
+		metadata:                s.metadata,
+		stateTime:               time.Now().UTC(),
+	}
+}
+
+// syncServerStream serializes the calls of Send, it's safe to use by multiple goroutines
+type syncServerStream struct {
+	pb.Transport_ConnectServer
+	mu sync.Mutex
+}
+
+func (s *syncServerStream) Send(pkt *pb.Packet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Transport_ConnectServer.Send(pkt)
+}
+
 // Override context from transport stream
 func (s *ProxyStream) Context() context.Context { return s.context }
 func (s *ProxyStream) ContextCauseError() error { return context.Cause(s.context) }
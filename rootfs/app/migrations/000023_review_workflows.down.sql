@@ -0,0 +1,12 @@
+BEGIN;
+
+SET search_path TO private;
+
+ALTER TABLE reviews DROP COLUMN expire_at;
+ALTER TABLE reviews DROP COLUMN deny_self_approval;
+
+ALTER TABLE review_groups DROP COLUMN stage;
+ALTER TABLE review_groups DROP COLUMN quorum;
+ALTER TABLE review_groups DROP COLUMN approvals;
+
+COMMIT;
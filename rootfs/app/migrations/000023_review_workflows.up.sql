@@ -0,0 +1,13 @@
+BEGIN;
+
+SET search_path TO private;
+
+ALTER TABLE reviews ADD COLUMN expire_at TIMESTAMP NULL;
+ALTER TABLE reviews ADD COLUMN deny_self_approval BOOLEAN DEFAULT FALSE;
+
+-- groups of old reviews belong to the same stage
+ALTER TABLE review_groups ADD COLUMN stage INT DEFAULT 0;
+ALTER TABLE review_groups ADD COLUMN quorum INT DEFAULT 1;
+ALTER TABLE review_groups ADD COLUMN approvals JSONB NULL;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+SET search_path TO private;
+
+ALTER TABLE reviews DROP COLUMN access_start_at;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+SET search_path TO private;
+
+ALTER TABLE reviews ADD COLUMN access_start_at TIMESTAMP NULL;
+
+COMMIT;